@@ -10,6 +10,6 @@ var version = "dev"
 
 func main() {
 	if err := cli.Execute(version); err != nil {
-		os.Exit(1)
+		os.Exit(cli.ExitCode(err))
 	}
 }
@@ -2,25 +2,230 @@
 package account
 
 import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
+
+	"github.com/delhombre/cxa/internal/schema"
 )
 
+// SchemaVersion is the newest account metadata schema this build
+// understands. Bump it when metadata changes in a way older builds would
+// mishandle.
+const SchemaVersion = 1
+
+// Format is the .account.json file format. Add a migration with every
+// bump of SchemaVersion.
+var Format = &schema.Format{
+	Name:    ".account.json",
+	Version: SchemaVersion,
+	Migrations: []schema.Migration{
+		// 0 to 1: schema_version was added
+		nil,
+	},
+}
+
 // Account represents a Codex CLI account.
 type Account struct {
+	// SchemaVersion is the metadata schema the account was written with.
+	// Zero means it predates versioning.
+	SchemaVersion int `json:"schema_version,omitempty"`
+
 	Name      string    `json:"name"`
 	Email     string    `json:"email,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// LastUsedAt is when the account was last activated or saved as the
+	// active account. It is zero for accounts that were never used.
+	LastUsedAt time.Time `json:"last_used_at"`
+
+	// Reminder is a note shown, and acknowledged, every time the account
+	// is activated.
+	Reminder string `json:"reminder,omitempty"`
+
+	// Description is a one-line summary of what the account is for.
+	Description string `json:"description,omitempty"`
+
+	// Notes is free-form text kept with the account.
+	Notes string `json:"notes,omitempty"`
+
+	// Tags label the account for filtering, e.g. "work" or "client".
+	// They are kept sorted.
+	Tags []string `json:"tags,omitempty"`
+
+	// Protected locks the account: saving over it or deleting it needs
+	// --force, and switching away from it leaves the stored copy as is.
+	Protected bool `json:"protected,omitempty"`
+
+	// Archived hides the account from listings and pickers, and it cannot
+	// be activated until it is unarchived.
+	Archived bool `json:"archived,omitempty"`
+
+	// CodexVersion is the Codex CLI version installed when the account was
+	// last saved, if it could be determined.
+	CodexVersion string `json:"codex_version,omitempty"`
+
+	// Hostname is the machine the account was last saved on.
+	Hostname string `json:"hostname,omitempty"`
+
+	// Size is the total size in bytes of the account's files as of its
+	// last save, and Files how many there were. Both are zero for
+	// accounts saved before they were recorded.
+	Size  int64 `json:"size,omitempty"`
+	Files int   `json:"files,omitempty"`
+
+	// Compressed reports whether the account's sessions and other
+	// directories are packed into an archive. It is read from the account
+	// directory, not stored.
+	Compressed bool `json:"compressed,omitempty"`
+
+	// Encrypted reports whether the account's files are sealed with the
+	// storage passphrase. It is read from the account directory, not
+	// stored.
+	Encrypted bool `json:"encrypted,omitempty"`
+
+	// Keychain reports that the account's auth.json is kept in the
+	// operating system keychain rather than in its directory. It is
+	// written into ~/.codex when the account is activated.
+	Keychain bool `json:"keychain,omitempty"`
 }
 
 // NewAccount creates a new account with the given name.
 func NewAccount(name string) *Account {
 	now := time.Now()
 	return &Account{
-		Name:      name,
-		CreatedAt: now,
-		UpdatedAt: now,
+		SchemaVersion: SchemaVersion,
+		Name:          name,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
+
+// LastUsed returns when the account was last used, falling back to its
+// last update for accounts saved before usage was tracked.
+func (a *Account) LastUsed() time.Time {
+	if a.LastUsedAt.IsZero() {
+		return a.UpdatedAt
+	}
+	return a.LastUsedAt
+}
+
+// SortRecent orders accounts most recently used first, keeping the order
+// of accounts used at the same time.
+func SortRecent(accounts []*Account) {
+	slices.SortStableFunc(accounts, func(a, b *Account) int {
+		return b.LastUsed().Compare(a.LastUsed())
+	})
+}
+
+// SchemaError reports account metadata written by a newer cxa. Such
+// accounts can be read, but changing them could drop fields this build
+// does not know about.
+type SchemaError struct {
+	Name  string
+	Found int
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("account '%s' was written by a newer cxa (schema %d; this cxa understands up to %d) and is read-only here. "+
+		"Upgrade cxa to change it (brew upgrade cxa, or go install github.com/delhombre/cxa/cmd/cxa@latest). "+
+		"To keep using this older cxa, export the account with the newer cxa and import it here",
+		e.Name, e.Found, SchemaVersion)
+}
+
+// CheckWritable returns a *SchemaError if the account was written with a
+// newer metadata schema than this build understands.
+func (a *Account) CheckWritable() error {
+	if a.SchemaVersion > SchemaVersion {
+		return &SchemaError{Name: a.Name, Found: a.SchemaVersion}
 	}
+	return nil
+}
+
+// ProtectedError reports a change to a locked account that was not forced.
+type ProtectedError struct {
+	Name string
+
+	// Op is what --force would do anyway, such as "delete"; empty when
+	// the change cannot be forced.
+	Op string
+}
+
+func (e *ProtectedError) Error() string {
+	if e.Op == "" {
+		return fmt.Sprintf("account '%s' is locked; unlock it first with cxa unlock %s", e.Name, e.Name)
+	}
+	return fmt.Sprintf("account '%s' is locked; pass --force to %s it anyway, or unlock it with cxa unlock %s", e.Name, e.Op, e.Name)
+}
+
+// ValidateName checks that name is usable as an account directory name.
+func ValidateName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("account name cannot be empty")
+	case name == "." || name == "..":
+		return fmt.Errorf("invalid account name '%s'", name)
+	case strings.ContainsAny(name, `/\`):
+		return fmt.Errorf("account name '%s' cannot contain path separators", name)
+	case strings.HasPrefix(name, "."):
+		return fmt.Errorf("account name '%s' cannot start with a dot", name)
+	}
+	return nil
+}
+
+// ValidateTag checks that tag is usable as an account tag.
+func ValidateTag(tag string) error {
+	switch {
+	case tag == "":
+		return fmt.Errorf("tag cannot be empty")
+	case strings.ContainsAny(tag, " \t\n,"):
+		return fmt.Errorf("tag '%s' cannot contain spaces or commas", tag)
+	}
+	return nil
+}
+
+// HasTag reports whether the account is tagged with tag.
+func (a *Account) HasTag(tag string) bool {
+	return slices.Contains(a.Tags, tag)
+}
+
+// AddTag tags the account with tag. It reports false if the account
+// already had it.
+func (a *Account) AddTag(tag string) bool {
+	if a.HasTag(tag) {
+		return false
+	}
+	a.Tags = append(a.Tags, tag)
+	slices.Sort(a.Tags)
+	return true
+}
+
+// FilterTags removes from accounts those not tagged with every one of tags
+// and returns the rest, in order.
+func FilterTags(accounts []*Account, tags []string) []*Account {
+	if len(tags) == 0 {
+		return accounts
+	}
+	return slices.DeleteFunc(accounts, func(acc *Account) bool {
+		return slices.ContainsFunc(tags, func(tag string) bool { return !acc.HasTag(tag) })
+	})
+}
+
+// RemoveTag removes tag from the account. It reports false if the account
+// did not have it.
+func (a *Account) RemoveTag(tag string) bool {
+	i := slices.Index(a.Tags, tag)
+	if i < 0 {
+		return false
+	}
+	a.Tags = slices.Delete(a.Tags, i, i+1)
+	if len(a.Tags) == 0 {
+		a.Tags = nil
+	}
+	return true
 }
 
 // Repository defines the interface for account storage.
@@ -43,3 +248,19 @@ type Repository interface {
 	// Current returns the currently active account name.
 	Current() (string, error)
 }
+
+// ContextRepository is a Repository whose slow operations can be
+// cancelled, such as on Ctrl+C, or given a deadline. Each returns ctx.Err()
+// once ctx is done, leaving accounts and ~/.codex as they were.
+type ContextRepository interface {
+	Repository
+
+	// SaveContext is Save under ctx.
+	SaveContext(ctx context.Context, name string) (*Account, error)
+
+	// DeleteContext is Delete under ctx.
+	DeleteContext(ctx context.Context, name string) error
+
+	// ActivateContext is Activate under ctx.
+	ActivateContext(ctx context.Context, name string) error
+}
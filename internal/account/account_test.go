@@ -0,0 +1,80 @@
+package account_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/delhombre/cxa/internal/account"
+)
+
+func TestSortRecent(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hours int) time.Time { return base.Add(time.Duration(hours) * time.Hour) }
+
+	type entry struct {
+		name       string
+		updatedAt  time.Time
+		lastUsedAt time.Time
+	}
+	tests := []struct {
+		name     string
+		accounts []entry
+		want     []string
+	}{
+		{
+			name: "most recently used first",
+			accounts: []entry{
+				{"a", at(0), at(1)},
+				{"b", at(0), at(3)},
+				{"c", at(0), at(2)},
+			},
+			want: []string{"b", "c", "a"},
+		},
+		{
+			name: "never used falls back to last update",
+			accounts: []entry{
+				{"used", at(0), at(2)},
+				{"updated-later", at(3), time.Time{}},
+				{"updated-earlier", at(1), time.Time{}},
+			},
+			want: []string{"updated-later", "used", "updated-earlier"},
+		},
+		{
+			name: "never used nor updated goes last",
+			accounts: []entry{
+				{"blank", time.Time{}, time.Time{}},
+				{"used", at(0), at(1)},
+			},
+			want: []string{"used", "blank"},
+		},
+		{
+			name: "equal timestamps keep their order",
+			accounts: []entry{
+				{"c", at(0), at(1)},
+				{"a", at(0), at(1)},
+				{"b", at(1), time.Time{}},
+				{"d", at(0), at(2)},
+			},
+			want: []string{"d", "c", "a", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var accounts []*account.Account
+			for _, e := range tt.accounts {
+				accounts = append(accounts, &account.Account{Name: e.name, UpdatedAt: e.updatedAt, LastUsedAt: e.lastUsedAt})
+			}
+			account.SortRecent(accounts)
+
+			var got []string
+			for _, acc := range accounts {
+				got = append(got, acc.Name)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("SortRecent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
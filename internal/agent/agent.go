@@ -0,0 +1,178 @@
+// Package agent keeps the storage passphrase in a background process for a
+// while, so encrypted accounts can be used without typing it for every
+// command. The agent answers on a Unix socket in the state directory that
+// only its owner can open, and exits when its time is up or it is stopped.
+package agent
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	requestGet  = "get"
+	requestStop = "stop"
+
+	// ioTimeout bounds a single exchange, so a stuck client cannot hold
+	// the agent.
+	ioTimeout = 2 * time.Second
+)
+
+var (
+	// ErrNotRunning is returned when no agent answers on the socket.
+	ErrNotRunning = errors.New("agent is not running")
+
+	// ErrRunning is returned by Serve when an agent already answers on
+	// the socket.
+	ErrRunning = errors.New("agent is already running")
+)
+
+// Serve hands secret to every client of the socket at path until ttl has
+// passed or a client asks it to stop.
+func Serve(path string, secret []byte, ttl time.Duration) error {
+	if Running(path) {
+		return ErrRunning
+	}
+	// A socket left by an agent that was killed
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	if err := os.Chmod(path, 0600); err != nil {
+		return err
+	}
+
+	if err := l.SetDeadline(time.Now().Add(ttl)); err != nil {
+		return err
+	}
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if stop := answer(conn, secret); stop {
+			return nil
+		}
+	}
+}
+
+// answer handles one request and reports whether the agent should stop.
+func answer(conn net.Conn, secret []byte) bool {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(ioTimeout))
+
+	request, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	switch strings.TrimSpace(request) {
+	case requestGet:
+		_, _ = conn.Write(secret)
+	case requestStop:
+		return true
+	}
+	return false
+}
+
+// Fetch returns the secret held by the agent at path.
+func Fetch(path string) ([]byte, error) {
+	conn, err := send(path, requestGet)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	secret, err := io.ReadAll(conn)
+	if err != nil {
+		return nil, err
+	}
+	if len(secret) == 0 {
+		return nil, ErrNotRunning
+	}
+	return secret, nil
+}
+
+// Stop asks the agent at path to forget its secret and exit.
+func Stop(path string) error {
+	conn, err := send(path, requestStop)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// Running reports whether an agent answers on the socket at path.
+func Running(path string) bool {
+	conn, err := net.DialTimeout("unix", path, ioTimeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+func send(path, request string) (net.Conn, error) {
+	conn, err := net.DialTimeout("unix", path, ioTimeout)
+	if err != nil {
+		return nil, ErrNotRunning
+	}
+	_ = conn.SetDeadline(time.Now().Add(ioTimeout))
+	if _, err := io.WriteString(conn, request+"\n"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Start runs cmd as a detached agent, handing it secret on its standard
+// input, and waits until it answers on the socket at path.
+func Start(cmd *exec.Cmd, path string, secret []byte) error {
+	detach(cmd)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	_, err = stdin.Write(secret)
+	if closeErr := stdin.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = cmd.Process.Kill()
+		return err
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	deadline := time.After(5 * time.Second)
+	for !Running(path) {
+		select {
+		case err := <-exited:
+			if err == nil {
+				err = errors.New("agent exited at start")
+			}
+			return err
+		case <-deadline:
+			_ = cmd.Process.Kill()
+			return errors.New("agent did not start")
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	return nil
+}
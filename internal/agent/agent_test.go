@@ -0,0 +1,67 @@
+package agent_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/delhombre/cxa/internal/agent"
+)
+
+func TestServe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.sock")
+	if _, err := agent.Fetch(path); !errors.Is(err, agent.ErrNotRunning) {
+		t.Fatalf("Fetch without an agent: got %v, want ErrNotRunning", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- agent.Serve(path, []byte("hunter2"), time.Minute) }()
+	for i := 0; !agent.Running(path); i++ {
+		if i == 100 {
+			t.Fatal("agent did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("socket should only be open to its owner, got %v (%v)", info.Mode(), err)
+	}
+	secret, err := agent.Fetch(path)
+	if err != nil || string(secret) != "hunter2" {
+		t.Errorf("Fetch = %q, %v", secret, err)
+	}
+	if err := agent.Serve(path, []byte("other"), time.Minute); !errors.Is(err, agent.ErrRunning) {
+		t.Errorf("second agent: got %v, want ErrRunning", err)
+	}
+
+	if err := agent.Stop(path); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Serve returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent did not stop")
+	}
+	if agent.Running(path) {
+		t.Error("agent should be gone after Stop")
+	}
+}
+
+func TestServeExpires(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.sock")
+	start := time.Now()
+	if err := agent.Serve(path, []byte("hunter2"), 50*time.Millisecond); err != nil {
+		t.Fatalf("Serve failed: %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("agent outlived its time")
+	}
+	if _, err := agent.Fetch(path); !errors.Is(err, agent.ErrNotRunning) {
+		t.Errorf("Fetch after expiry: got %v, want ErrNotRunning", err)
+	}
+}
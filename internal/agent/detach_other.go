@@ -0,0 +1,7 @@
+//go:build !unix && !windows
+
+package agent
+
+import "os/exec"
+
+func detach(cmd *exec.Cmd) {}
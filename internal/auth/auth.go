@@ -0,0 +1,166 @@
+// Package auth reads Codex CLI credentials from auth.json.
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// File is the content of a Codex auth.json.
+type File struct {
+	APIKey *string `json:"OPENAI_API_KEY"`
+	Tokens *Tokens `json:"tokens"`
+}
+
+// Tokens are the OAuth tokens written by `codex login`.
+type Tokens struct {
+	IDToken      string `json:"id_token"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	AccountID    string `json:"account_id"`
+}
+
+// Identity is who an auth.json authenticates as.
+type Identity struct {
+	Email        string
+	Organization string
+	Plan         string
+	AccountID    string
+	ExpiresAt    time.Time // Zero if unknown
+	APIKey       bool      // Authenticated with an API key rather than OAuth
+	KeySuffix    string    // Last characters of the API key, for display
+}
+
+// keySuffixLen is how much of an API key is kept to tell keys apart.
+const keySuffixLen = 4
+
+// Same reports whether i and other are the same credentials, comparing
+// account IDs, then emails, then API key suffixes.
+func (i *Identity) Same(other *Identity) bool {
+	switch {
+	case i.AccountID != "" && other.AccountID != "":
+		return i.AccountID == other.AccountID
+	case i.Email != "" && other.Email != "":
+		return i.Email == other.Email
+	case i.APIKey && other.APIKey:
+		return i.KeySuffix == other.KeySuffix
+	}
+	return false
+}
+
+// Expired reports whether the identity's token has expired.
+func (i *Identity) Expired() bool {
+	return !i.ExpiresAt.IsZero() && time.Now().After(i.ExpiresAt)
+}
+
+// ErrNoCredentials is returned when auth.json holds neither tokens nor an
+// API key.
+var ErrNoCredentials = errors.New("auth.json has no credentials")
+
+// Load reads the auth.json at path.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid auth.json: %w", err)
+	}
+	return &f, nil
+}
+
+// Identity decodes the identity from the ID token, falling back to the
+// access token for expiry.
+func (f *File) Identity() (*Identity, error) {
+	if f.Tokens == nil || f.Tokens.IDToken == "" {
+		if f.APIKey != nil && *f.APIKey != "" {
+			key := *f.APIKey
+			return &Identity{APIKey: true, KeySuffix: key[max(0, len(key)-keySuffixLen):]}, nil
+		}
+		return nil, ErrNoCredentials
+	}
+
+	claims, err := ParseJWT(f.Tokens.IDToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+
+	id := &Identity{
+		Email:     claims.Email,
+		Plan:      claims.Auth.PlanType,
+		AccountID: claims.Auth.AccountID,
+		ExpiresAt: claims.Expiry(),
+	}
+	if id.AccountID == "" {
+		id.AccountID = f.Tokens.AccountID
+	}
+	for _, org := range claims.Auth.Organizations {
+		if org.IsDefault || id.Organization == "" {
+			id.Organization = org.Title
+		}
+	}
+
+	if f.Tokens.AccessToken != "" {
+		if access, err := ParseJWT(f.Tokens.AccessToken); err == nil && !access.Expiry().IsZero() {
+			id.ExpiresAt = access.Expiry()
+		}
+	}
+
+	return id, nil
+}
+
+// Claims are the JWT claims cxa cares about.
+type Claims struct {
+	Email     string    `json:"email"`
+	ExpiresAt int64     `json:"exp"`
+	Auth      AuthClaim `json:"https://api.openai.com/auth"`
+}
+
+// AuthClaim is the OpenAI-specific claim embedded in Codex tokens.
+type AuthClaim struct {
+	PlanType      string         `json:"chatgpt_plan_type"`
+	AccountID     string         `json:"chatgpt_account_id"`
+	Organizations []Organization `json:"organizations"`
+}
+
+// Organization is an organization the user belongs to.
+type Organization struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	IsDefault bool   `json:"is_default"`
+}
+
+// Expiry returns the token expiry, or zero if the token has none.
+func (c *Claims) Expiry() time.Time {
+	if c.ExpiresAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(c.ExpiresAt, 0)
+}
+
+// ParseJWT decodes the claims of a JWT without verifying its signature.
+// cxa only reads its own local credentials, so the signature is irrelevant.
+func ParseJWT(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("malformed JWT payload: %w", err)
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("malformed JWT claims: %w", err)
+	}
+	return &claims, nil
+}
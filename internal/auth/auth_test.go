@@ -0,0 +1,102 @@
+package auth_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/delhombre/cxa/internal/auth"
+)
+
+// makeJWT builds an unsigned JWT carrying claims.
+func makeJWT(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("failed to marshal claims: %v", err)
+	}
+	return "e30." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+func TestIdentity(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	idToken := makeJWT(t, map[string]any{
+		"email": "me@example.com",
+		"exp":   exp.Unix(),
+		"https://api.openai.com/auth": map[string]any{
+			"chatgpt_plan_type":  "plus",
+			"chatgpt_account_id": "acct-1",
+			"organizations": []map[string]any{
+				{"id": "org-1", "title": "Personal", "is_default": true},
+			},
+		},
+	})
+
+	data, _ := json.Marshal(map[string]any{
+		"tokens": map[string]any{"id_token": idToken},
+	})
+	path := filepath.Join(t.TempDir(), "auth.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("failed to write auth.json: %v", err)
+	}
+
+	f, err := auth.Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	id, err := f.Identity()
+	if err != nil {
+		t.Fatalf("Identity failed: %v", err)
+	}
+
+	if id.Email != "me@example.com" {
+		t.Errorf("expected email 'me@example.com', got '%s'", id.Email)
+	}
+	if id.Plan != "plus" || id.AccountID != "acct-1" || id.Organization != "Personal" {
+		t.Errorf("unexpected identity: %+v", id)
+	}
+	if !id.ExpiresAt.Equal(exp) {
+		t.Errorf("expected expiry %v, got %v", exp, id.ExpiresAt)
+	}
+	if id.Expired() {
+		t.Error("token should not be expired")
+	}
+}
+
+func TestIdentity_NoCredentials(t *testing.T) {
+	f := &auth.File{}
+	if _, err := f.Identity(); !errors.Is(err, auth.ErrNoCredentials) {
+		t.Errorf("expected ErrNoCredentials, got %v", err)
+	}
+}
+
+func TestIdentity_Same(t *testing.T) {
+	tests := []struct {
+		a, b auth.Identity
+		want bool
+	}{
+		{auth.Identity{AccountID: "acct-1", Email: "a@x"}, auth.Identity{AccountID: "acct-1", Email: "b@x"}, true},
+		{auth.Identity{AccountID: "acct-1"}, auth.Identity{AccountID: "acct-2"}, false},
+		{auth.Identity{Email: "a@x"}, auth.Identity{AccountID: "acct-1", Email: "a@x"}, true},
+		{auth.Identity{APIKey: true, KeySuffix: "abcd"}, auth.Identity{APIKey: true, KeySuffix: "abcd"}, true},
+		{auth.Identity{APIKey: true, KeySuffix: "abcd"}, auth.Identity{APIKey: true, KeySuffix: "wxyz"}, false},
+		{auth.Identity{APIKey: true}, auth.Identity{Email: "a@x"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Same(&tt.b); got != tt.want {
+			t.Errorf("%+v.Same(%+v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestParseJWT_Malformed(t *testing.T) {
+	for _, token := range []string{"", "abc", "a.!!!.c", "a.e30x.c"} {
+		if _, err := auth.ParseJWT(token); err == nil {
+			t.Errorf("ParseJWT(%q) should fail", token)
+		}
+	}
+}
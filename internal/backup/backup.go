@@ -0,0 +1,149 @@
+// Package backup snapshots all of cxa's data into a single archive and
+// restores it.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/delhombre/cxa/pkg/codex"
+)
+
+// FormatVersion is the archive layout version written by Create.
+const FormatVersion = 1
+
+// Archive layout: a manifest, then each section under its own prefix.
+const (
+	manifestName   = "cxa-backup.json"
+	accountsPrefix = "accounts"
+	sharedPrefix   = "shared"
+	groupsPrefix   = "groups"
+	statePrefix    = "state"
+)
+
+// Manifest describes a backup archive.
+type Manifest struct {
+	FormatVersion int       `json:"format_version"`
+	CreatedAt     time.Time `json:"created_at"`
+	Accounts      []string  `json:"accounts"`
+}
+
+// stateFiles returns the state directory files worth backing up.
+func stateFiles(paths *codex.Paths) []string {
+	return []string{paths.StateFile(), paths.SharingConfigFile(), paths.ConfigFile()}
+}
+
+// Create writes the named accounts, shared data, and cxa state to w as a
+// tar.gz archive. Symlinks are stored as links, so the layout created by
+// session sharing comes back as it was.
+func Create(w io.Writer, paths *codex.Paths, accounts []string) (*Manifest, error) {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	manifest := &Manifest{
+		FormatVersion: FormatVersion,
+		CreatedAt:     time.Now(),
+		Accounts:      accounts,
+	}
+	if err := writeManifest(tw, manifest); err != nil {
+		return nil, err
+	}
+
+	for _, name := range accounts {
+		if err := addTree(tw, paths.AccountPath(name), accountsPrefix+"/"+name); err != nil {
+			return nil, err
+		}
+	}
+	for _, section := range []struct{ dir, prefix string }{
+		{paths.SharedDir, sharedPrefix},
+		{paths.GroupsDir, groupsPrefix},
+	} {
+		if _, err := os.Lstat(section.dir); os.IsNotExist(err) {
+			continue
+		}
+		if err := addTree(tw, section.dir, section.prefix); err != nil {
+			return nil, err
+		}
+	}
+	for _, file := range stateFiles(paths) {
+		if _, err := os.Lstat(file); os.IsNotExist(err) {
+			continue
+		}
+		if err := addTree(tw, file, statePrefix+"/"+filepath.Base(file)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, gz.Close()
+}
+
+func writeManifest(tw *tar.Writer, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{
+		Name:    manifestName,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: manifest.CreatedAt,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+// addTree adds src to the archive under name without following symlinks.
+func addTree(tw *tar.Writer, src, name string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		entryName := filepath.ToSlash(filepath.Join(name, relPath))
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = entryName
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
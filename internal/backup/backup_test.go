@@ -0,0 +1,185 @@
+package backup_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/delhombre/cxa/internal/backup"
+	"github.com/delhombre/cxa/pkg/codex"
+)
+
+// setup creates a data dir with one account whose history is shared.
+func setup(t *testing.T) *codex.Paths {
+	t.Helper()
+	paths := codex.NewPathsAt(t.TempDir())
+
+	files := map[string]string{
+		filepath.Join(paths.AccountPath("work"), "auth.json"):     `{"OPENAI_API_KEY": "sk-work"}`,
+		filepath.Join(paths.AccountPath("work"), ".account.json"): `{"name": "work"}`,
+		filepath.Join(paths.SharedDir, "history.jsonl"):           "line\n",
+		paths.StateFile():         `{"current": "work"}`,
+		paths.SharingConfigFile(): `{"mode": "global"}`,
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(paths.SharedDir, "history.jsonl"), filepath.Join(paths.AccountPath("work"), "history.jsonl")); err != nil {
+		t.Fatal(err)
+	}
+	return paths
+}
+
+func TestCreate(t *testing.T) {
+	paths := setup(t)
+
+	var buf bytes.Buffer
+	manifest, err := backup.Create(&buf, paths, []string{"work"})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if manifest.FormatVersion != backup.FormatVersion || len(manifest.Accounts) != 1 {
+		t.Errorf("unexpected manifest: %+v", manifest)
+	}
+
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	entries := make(map[string]*tar.Header)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[hdr.Name] = hdr
+	}
+
+	for _, name := range []string{
+		"cxa-backup.json",
+		"accounts/work/auth.json",
+		"shared/history.jsonl",
+		"state/state.json",
+		"state/sharing.json",
+	} {
+		if _, ok := entries[name]; !ok {
+			t.Errorf("archive is missing %s", name)
+		}
+	}
+	if link := entries["accounts/work/history.jsonl"]; link == nil || link.Typeflag != tar.TypeSymlink {
+		t.Error("shared history link should be stored as a symlink")
+	}
+}
+
+func TestNewPlan(t *testing.T) {
+	manifest := &backup.Manifest{Accounts: []string{"work", "personal"}}
+	existing := []string{"work", "client"}
+
+	merge := backup.NewPlan(manifest, existing, backup.Merge)
+	if len(merge.Add) != 1 || merge.Add[0] != "personal" || len(merge.Replace) != 0 || len(merge.Remove) != 0 || len(merge.Keep) != 2 {
+		t.Errorf("unexpected merge plan: %+v", merge)
+	}
+
+	replace := backup.NewPlan(manifest, existing, backup.Replace)
+	if len(replace.Add) != 1 || len(replace.Replace) != 1 || replace.Replace[0] != "work" || len(replace.Remove) != 1 || replace.Remove[0] != "client" {
+		t.Errorf("unexpected replace plan: %+v", replace)
+	}
+}
+
+func TestApply(t *testing.T) {
+	paths := setup(t)
+
+	var buf bytes.Buffer
+	if _, err := backup.Create(&buf, paths, []string{"work"}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	archive := buf.Bytes()
+
+	// Change things after the backup
+	authPath := filepath.Join(paths.AccountPath("work"), "auth.json")
+	if err := os.WriteFile(authPath, []byte("changed"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(paths.AccountPath("client"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := backup.ReadManifest(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	existing := []string{"work", "client"}
+
+	// Merge keeps local changes
+	if err := backup.Apply(bytes.NewReader(archive), paths, backup.NewPlan(manifest, existing, backup.Merge)); err != nil {
+		t.Fatalf("merge Apply failed: %v", err)
+	}
+	if data, _ := os.ReadFile(authPath); string(data) != "changed" {
+		t.Error("merge should keep the local account")
+	}
+
+	// Replace restores the backup exactly
+	if err := backup.Apply(bytes.NewReader(archive), paths, backup.NewPlan(manifest, existing, backup.Replace)); err != nil {
+		t.Fatalf("replace Apply failed: %v", err)
+	}
+	if data, _ := os.ReadFile(authPath); string(data) != `{"OPENAI_API_KEY": "sk-work"}` {
+		t.Errorf("replace should restore the account, got %q", data)
+	}
+	if _, err := os.Stat(paths.AccountPath("client")); !os.IsNotExist(err) {
+		t.Error("replace should remove accounts missing from the backup")
+	}
+	if target, err := os.Readlink(filepath.Join(paths.AccountPath("work"), "history.jsonl")); err != nil || target != filepath.Join(paths.SharedDir, "history.jsonl") {
+		t.Errorf("shared history link should be restored, got %q, %v", target, err)
+	}
+	if _, err := os.Stat(filepath.Join(paths.DataDir, ".cxa-restore")); !os.IsNotExist(err) {
+		t.Error("staging should be cleaned up")
+	}
+}
+
+func TestApply_RejectsEscapingEntries(t *testing.T) {
+	paths := setup(t)
+	outside := filepath.Join(t.TempDir(), "outside")
+	if err := os.MkdirAll(outside, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	writeEntry := func(hdr *tar.Header, body string) {
+		hdr.Size = int64(len(body))
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeEntry(&tar.Header{Name: "cxa-backup.json", Mode: 0644, Typeflag: tar.TypeReg}, `{"format_version": 1, "accounts": ["evil"]}`)
+	writeEntry(&tar.Header{Name: "accounts/evil/link", Typeflag: tar.TypeSymlink, Linkname: outside}, "")
+	writeEntry(&tar.Header{Name: "accounts/evil/link/pwned", Mode: 0644, Typeflag: tar.TypeReg}, "x")
+	tw.Close()
+	gz.Close()
+
+	plan := &backup.Plan{Mode: backup.Merge, Add: []string{"evil"}}
+	if err := backup.Apply(&buf, paths, plan); !errors.Is(err, backup.ErrInvalidArchive) {
+		t.Errorf("expected ErrInvalidArchive, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "pwned")); !os.IsNotExist(err) {
+		t.Error("archive wrote outside the staging directory")
+	}
+}
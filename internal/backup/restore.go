@@ -0,0 +1,293 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/delhombre/cxa/internal/account"
+	"github.com/delhombre/cxa/pkg/codex"
+)
+
+// ErrInvalidArchive is returned for archives that are not cxa backups or
+// that contain unsafe entries.
+var ErrInvalidArchive = errors.New("invalid cxa backup archive")
+
+// restoreStaging is where an archive is unpacked inside the data dir
+// before it is moved into place.
+const restoreStaging = ".cxa-restore"
+
+// Mode chooses how a restore treats existing data.
+type Mode int
+
+const (
+	// Merge keeps existing accounts and state and adds what is missing.
+	Merge Mode = iota
+	// Replace wipes existing accounts and shared data and restores the
+	// backup as it was.
+	Replace
+)
+
+// Plan lists what a restore will change.
+type Plan struct {
+	Mode     Mode      `json:"-"`
+	Manifest *Manifest `json:"manifest"`
+
+	Add     []string `json:"add"`     // Accounts restored that do not exist locally
+	Replace []string `json:"replace"` // Local accounts overwritten from the backup
+	Keep    []string `json:"keep"`    // Local accounts left untouched
+	Remove  []string `json:"remove"`  // Local accounts deleted because the backup lacks them
+}
+
+// NewPlan works out what restoring manifest over the existing accounts
+// does in the given mode.
+func NewPlan(manifest *Manifest, existing []string, mode Mode) *Plan {
+	plan := &Plan{
+		Mode:     mode,
+		Manifest: manifest,
+		Add:      []string{},
+		Replace:  []string{},
+		Keep:     []string{},
+		Remove:   []string{},
+	}
+
+	for _, name := range manifest.Accounts {
+		switch {
+		case !slices.Contains(existing, name):
+			plan.Add = append(plan.Add, name)
+		case mode == Replace:
+			plan.Replace = append(plan.Replace, name)
+		default:
+			plan.Keep = append(plan.Keep, name)
+		}
+	}
+	for _, name := range existing {
+		if slices.Contains(manifest.Accounts, name) {
+			continue
+		}
+		if mode == Replace {
+			plan.Remove = append(plan.Remove, name)
+		} else {
+			plan.Keep = append(plan.Keep, name)
+		}
+	}
+	return plan
+}
+
+// ReadManifest reads the manifest at the start of a backup archive.
+func ReadManifest(r io.Reader) (*Manifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer gz.Close()
+	return readManifest(tar.NewReader(gz))
+}
+
+// Apply unpacks the archive in r and applies plan to the data under paths.
+// The archive is fully unpacked next to the data before anything is
+// changed, so a corrupt archive leaves existing data alone.
+func Apply(r io.Reader, paths *codex.Paths, plan *Plan) error {
+	if err := paths.EnsureDirs(); err != nil {
+		return err
+	}
+	staging := filepath.Join(paths.DataDir, restoreStaging)
+	if err := os.RemoveAll(staging); err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	if err := extract(r, staging); err != nil {
+		return err
+	}
+	for _, name := range append(plan.Add, plan.Replace...) {
+		if _, err := os.Stat(filepath.Join(staging, accountsPrefix, name)); err != nil {
+			return fmt.Errorf("%w: account '%s' is listed but missing", ErrInvalidArchive, name)
+		}
+	}
+
+	if plan.Mode == Replace {
+		for _, name := range append(plan.Remove, plan.Replace...) {
+			if err := os.RemoveAll(paths.AccountPath(name)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, name := range append(plan.Add, plan.Replace...) {
+		if err := os.Rename(filepath.Join(staging, accountsPrefix, name), paths.AccountPath(name)); err != nil {
+			return err
+		}
+	}
+
+	for _, section := range []struct{ dir, prefix string }{
+		{paths.SharedDir, sharedPrefix},
+		{paths.GroupsDir, groupsPrefix},
+	} {
+		if err := restoreItem(filepath.Join(staging, section.prefix), section.dir, plan.Mode); err != nil {
+			return err
+		}
+	}
+	for _, file := range stateFiles(paths) {
+		if err := restoreItem(filepath.Join(staging, statePrefix, filepath.Base(file)), file, plan.Mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreItem moves src to dst. In merge mode an existing dst wins.
+func restoreItem(src, dst string, mode Mode) error {
+	if _, err := os.Lstat(src); os.IsNotExist(err) {
+		return nil
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		if mode == Merge {
+			return nil
+		}
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return os.Rename(src, dst)
+}
+
+func readManifest(tr *tar.Reader) (*Manifest, error) {
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	if hdr.Name != manifestName {
+		return nil, fmt.Errorf("%w: missing %s", ErrInvalidArchive, manifestName)
+	}
+
+	var manifest Manifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("%w: bad manifest: %v", ErrInvalidArchive, err)
+	}
+	if manifest.FormatVersion < 1 || manifest.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("%w: unsupported format version %d (this cxa reads up to %d)",
+			ErrInvalidArchive, manifest.FormatVersion, FormatVersion)
+	}
+	for _, name := range manifest.Accounts {
+		if err := account.ValidateName(name); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+	}
+	return &manifest, nil
+}
+
+// extract unpacks a backup archive into dir, rejecting entries outside the
+// known sections and any that would be written through a symlink.
+func extract(r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	manifest, err := readManifest(tr)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+
+		name, err := entryName(hdr.Name, manifest)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(name))
+		if err := checkParent(dir, dst); err != nil {
+			return err
+		}
+		if err := extractEntry(hdr, tr, dst); err != nil {
+			return err
+		}
+	}
+}
+
+// entryName validates an archive path and returns it cleaned.
+func entryName(name string, manifest *Manifest) (string, error) {
+	clean := path.Clean(name)
+	if clean == ".." || strings.HasPrefix(clean, "../") || path.IsAbs(clean) {
+		return "", fmt.Errorf("%w: unsafe entry %s", ErrInvalidArchive, name)
+	}
+
+	section, rest, _ := strings.Cut(clean, "/")
+	switch section {
+	case accountsPrefix:
+		accountName, _, _ := strings.Cut(rest, "/")
+		if rest != "" && !slices.Contains(manifest.Accounts, accountName) {
+			return "", fmt.Errorf("%w: entry %s is not a listed account", ErrInvalidArchive, name)
+		}
+	case sharedPrefix, groupsPrefix, statePrefix:
+	default:
+		return "", fmt.Errorf("%w: unexpected entry %s", ErrInvalidArchive, name)
+	}
+	return clean, nil
+}
+
+// checkParent makes sure dst's parent directory resolves inside root, so
+// an earlier symlink entry cannot redirect writes elsewhere.
+func checkParent(root, dst string) error {
+	parent := filepath.Dir(dst)
+	for p := parent; p != root && strings.HasPrefix(p, root); p = filepath.Dir(p) {
+		if info, err := os.Lstat(p); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%w: entry %s is inside a symlink", ErrInvalidArchive, dst)
+		}
+	}
+	return nil
+}
+
+func extractEntry(hdr *tar.Header, tr *tar.Reader, dst string) error {
+	mode := os.FileMode(hdr.Mode).Perm()
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		return os.MkdirAll(dst, mode|0700)
+	case tar.TypeSymlink:
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		return os.Symlink(hdr.Linkname, dst)
+	case tar.TypeReg:
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, tr); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		return os.Chtimes(dst, hdr.ModTime, hdr.ModTime)
+	}
+	return fmt.Errorf("%w: unsupported entry type for %s", ErrInvalidArchive, hdr.Name)
+}
@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var (
+	accountsImportFromDir string
+	accountsImportDryRun  bool
+)
+
+var accountsCmd = &cobra.Command{
+	Use:   "accounts",
+	Short: "Bulk account operations",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+var accountsImportCmd = &cobra.Command{
+	Use:   "import --from-dir <dir>",
+	Short: "Import every codex home copy found in a directory",
+	Long:  "Scan a directory for codex home copies (e.g. codex-work, codex-personal) and import each as an account, inferring names and skipping identical copies.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		candidates, err := repo.ScanHomes(accountsImportFromDir)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		type result struct {
+			Path        string `json:"path"`
+			Name        string `json:"name,omitempty"`
+			DuplicateOf string `json:"duplicate_of,omitempty"`
+			Imported    bool   `json:"imported"`
+			Error       string `json:"error,omitempty"`
+		}
+		results := make([]result, 0, len(candidates))
+
+		if len(candidates) == 0 {
+			return out.Result(results, func() {
+				out.Println(styles.MutedStyle.Render("No codex home copies found in " + accountsImportFromDir))
+			})
+		}
+
+		imported := 0
+		for _, c := range candidates {
+			r := result{Path: c.Path, Name: c.Name, DuplicateOf: c.DuplicateOf}
+			results = append(results, r)
+			last := &results[len(results)-1]
+
+			if c.DuplicateOf != "" {
+				out.Printf("  %s %s %s\n",
+					styles.Circle,
+					c.Path,
+					styles.MutedStyle.Render(fmt.Sprintf("(identical to %s, skipped)", c.DuplicateOf)),
+				)
+				continue
+			}
+
+			if accountsImportDryRun {
+				out.Printf("  %s %s %s %s\n", styles.Caret, c.Path, styles.Arrow, styles.PrimaryStyle.Render(c.Name))
+				continue
+			}
+
+			if _, err := repo.ImportDir(c.Name, c.Path); err != nil {
+				last.Error = err.Error()
+				out.Printf("  %s %s %s\n", styles.CrossMark, c.Path, styles.ErrorStyle.Render(err.Error()))
+				continue
+			}
+			last.Imported = true
+			out.Printf("  %s %s %s %s\n", styles.CheckMark, c.Path, styles.Arrow, styles.PrimaryStyle.Render(c.Name))
+			imported++
+		}
+
+		out.Println()
+		if accountsImportDryRun {
+			out.Println(styles.MutedStyle.Render("Dry run: nothing was imported."))
+		} else {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Imported %d account(s)", imported)))
+		}
+		return out.Result(results, nil)
+	},
+}
+
+func init() {
+	accountsImportCmd.Flags().StringVar(&accountsImportFromDir, "from-dir", "", "directory containing codex home copies")
+	accountsImportCmd.Flags().BoolVar(&accountsImportDryRun, "dry-run", false, "show what would be imported")
+	_ = accountsImportCmd.MarkFlagRequired("from-dir")
+
+	accountsCmd.AddCommand(accountsImportCmd)
+	rootCmd.AddCommand(accountsCmd)
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/delhombre/cxa/internal/account"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var archiveCmd = &cobra.Command{
+	Use:   "archive <name>",
+	Short: "Hide an account you only keep for reference",
+	Long:  "Archive an account: it is kept, but left out of cxa list, the picker, and the TUI, and cannot be switched to until it is unarchived. See archived accounts with cxa list --all.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		if current, _ := repo.Current(); current == name {
+			err := fmt.Errorf("'%s' is the current account; switch to another account before archiving it", name)
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		return setArchived(name, true)
+	},
+}
+
+var unarchiveCmd = &cobra.Command{
+	Use:   "unarchive <name>",
+	Short: "Bring an archived account back",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setArchived(args[0], false)
+	},
+}
+
+func setArchived(name string, archived bool) error {
+	if _, err := repo.UpdateMetadata(name, func(acc *account.Account) {
+		acc.Archived = archived
+	}); err != nil {
+		out.Println(styles.RenderError(err.Error()))
+		return err
+	}
+
+	return out.Result(map[string]any{"account": name, "archived": archived}, func() {
+		if archived {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Archived %s", name)))
+		} else {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Unarchived %s", name)))
+		}
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(archiveCmd)
+	rootCmd.AddCommand(unarchiveCmd)
+}
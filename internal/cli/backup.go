@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/delhombre/cxa/internal/backup"
+	"github.com/delhombre/cxa/internal/crypt"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+var (
+	backupOutput  string
+	backupEncrypt bool
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Snapshot all accounts, shared data, and state into one archive",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		accounts, err := repo.List()
+		if err != nil {
+			return err
+		}
+		names := make([]string, 0, len(accounts))
+		for _, acc := range accounts {
+			names = append(names, acc.Name)
+		}
+
+		output := backupOutput
+		if output == "" {
+			output = fmt.Sprintf("cxa-backup-%s.tar.gz", time.Now().Format("20060102-150405"))
+			if backupEncrypt {
+				output += ".enc"
+			}
+		}
+
+		var passphrase []byte
+		if backupEncrypt {
+			if passphrase, err = readPassphrase(true); err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+		}
+
+		out.Printf("%s Backing up %d accounts to %s...\n", styles.Caret, len(names), output)
+
+		f, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		err = writeBackup(f, names, passphrase)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(output)
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		info, err := os.Stat(output)
+		if err != nil {
+			return err
+		}
+
+		return out.Result(map[string]any{
+			"output":    output,
+			"accounts":  names,
+			"encrypted": backupEncrypt,
+			"size":      info.Size(),
+		}, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Backup written (%s)", humanize.Bytes(uint64(info.Size())))))
+			if !backupEncrypt {
+				out.Println(styles.MutedStyle.Render("The archive contains credentials; use --encrypt before storing it off-machine."))
+			}
+		})
+	},
+}
+
+// writeBackup writes a backup of the named accounts to w, encrypting it
+// when a passphrase is given.
+func writeBackup(w io.Writer, names []string, passphrase []byte) error {
+	if passphrase == nil {
+		_, err := backup.Create(w, paths, names)
+		return err
+	}
+
+	cw, err := crypt.NewWriter(w, passphrase)
+	if err != nil {
+		return err
+	}
+	if _, err := backup.Create(cw, paths, names); err != nil {
+		return err
+	}
+	return cw.Close()
+}
+
+func init() {
+	backupCmd.Flags().StringVarP(&backupOutput, "output", "o", "", "archive path (default cxa-backup-<timestamp>.tar.gz)")
+	backupCmd.Flags().BoolVar(&backupEncrypt, "encrypt", false, "encrypt the archive with a passphrase (or $CXA_PASSPHRASE)")
+	rootCmd.AddCommand(backupCmd)
+}
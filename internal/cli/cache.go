@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var cacheSize int
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the warm cache for instant switching",
+	Long:  "Keep pre-staged copies of your most used accounts so switching to them is a near-instant directory swap, at the cost of disk space.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+var cacheWarmCmd = &cobra.Command{
+	Use:   "warm",
+	Short: "Bring the warm cache up to date",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		size := cacheSize
+		if !cmd.Flags().Changed("size") {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			size = cfg.CacheSize
+		}
+		if size <= 0 {
+			err := errors.New("warm cache is disabled; pass --size or set cache_size in the cxa config")
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		out.Printf("%s Warming cache for up to %d accounts...\n", styles.Caret, size)
+
+		warmed, err := repo.WarmCache(size)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		if warmed == nil {
+			warmed = []string{}
+		}
+
+		return out.Result(map[string]any{"cached": warmed}, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Cached %d accounts", len(warmed))))
+			for _, name := range warmed {
+				out.Printf("  %s %s\n", styles.Circle, name)
+			}
+		})
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove all pre-staged accounts",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := repo.ClearCache(); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]bool{"cleared": true}, func() {
+			out.Println(styles.RenderSuccess("Warm cache cleared"))
+		})
+	},
+}
+
+func init() {
+	cacheWarmCmd.Flags().IntVar(&cacheSize, "size", 0, "number of accounts to keep staged (default from cache_size)")
+	cacheCmd.AddCommand(cacheWarmCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// completeAccountNames completes the first argument with saved account
+// names, read from the repository at tab time.
+func completeAccountNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	accounts, err := repo.List()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	var names []string
+	for _, acc := range accounts {
+		if strings.HasPrefix(acc.Name, toComplete) {
+			names = append(names, acc.Name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{switchCmd, deleteCmd, renameCmd, exportCmd, logoutCmd, remindCmd, tryCmd, diffCmd, lintCmd, showCmd, editCmd, execCmd, verifyCmd, historyCmd, lockCmd, unlockCmd, archiveCmd, unarchiveCmd, compressCmd, decompressCmd, encryptCmd, decryptCmd, agentCmd, keychainCmd, snapshotsCmd} {
+		cmd.ValidArgsFunction = completeAccountNames
+	}
+}
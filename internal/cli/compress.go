@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+var compressDays int
+
+var compressCmd = &cobra.Command{
+	Use:   "compress [name...]",
+	Short: "Compress accounts you rarely use",
+	Long: `Pack the sessions and other directories of saved accounts into a
+compressed archive to save disk space. Switching to a compressed account,
+or reading its files with commands such as diff or export, unpacks it
+first, so it takes longer.
+
+With no names, every archived account and every account unused for
+--days days is compressed. The current account is never compressed.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		names := args
+		if len(names) == 0 {
+			accounts, err := repo.List()
+			if err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+			current, _ := repo.Current()
+			idle := time.Duration(compressDays) * 24 * time.Hour
+			for _, acc := range accounts {
+				if acc.Name == current || acc.Compressed {
+					continue
+				}
+				if acc.Archived || time.Since(acc.LastUsed()) >= idle {
+					names = append(names, acc.Name)
+				}
+			}
+		}
+
+		type compressed struct {
+			Account string `json:"account"`
+			Before  int64  `json:"before"`
+			After   int64  `json:"after"`
+			Error   string `json:"error,omitempty"`
+		}
+		results := []compressed{}
+		var failed int
+		for _, name := range names {
+			result := compressed{Account: name}
+			result.Before, _ = repo.AccountSize(name)
+			if err := repo.Compress(name); err != nil {
+				result.Error = err.Error()
+				failed++
+				out.Printf("  %s %s %s\n", styles.CrossMark, name, styles.ErrorStyle.Render(err.Error()))
+			} else {
+				result.After, _ = repo.AccountSize(name)
+				out.Printf("  %s %s %s\n", styles.CheckMark, name, styles.MutedStyle.Render(
+					fmt.Sprintf("%s → %s", humanize.Bytes(uint64(result.Before)), humanize.Bytes(uint64(result.After)))))
+			}
+			results = append(results, result)
+		}
+
+		err := out.Result(results, func() {
+			if len(names) == 0 {
+				out.Println(styles.MutedStyle.Render("Nothing to compress."))
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d accounts failed to compress", failed)
+		}
+		return nil
+	},
+}
+
+var decompressCmd = &cobra.Command{
+	Use:   "decompress <name>",
+	Short: "Unpack a compressed account",
+	Long:  "Unpack an account compressed with cxa compress, so switching to it is fast again.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		acc, err := repo.Get(name)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		if acc.Encrypted {
+			err := fmt.Errorf("'%s' is encrypted; encrypted accounts stay compressed until cxa decrypt", name)
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		if err := repo.Expand(name); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]any{"account": name, "compressed": false}, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Unpacked %s", name)))
+		})
+	},
+}
+
+func init() {
+	compressCmd.Flags().IntVar(&compressDays, "days", 30, "with no names, compress accounts unused for this many days")
+	rootCmd.AddCommand(compressCmd)
+	rootCmd.AddCommand(decompressCmd)
+}
@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/delhombre/cxa/internal/config"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/delhombre/cxa/internal/ui/table"
+	"github.com/muesli/termenv"
+	"github.com/spf13/cobra"
+)
+
+// loadConfig reads the cxa config. Every command reads settings through it.
+func loadConfig() (*config.Config, error) {
+	return config.Load(paths.ConfigFile())
+}
+
+// updateConfig applies fn to the cxa config and saves it.
+func updateConfig(fn func(cfg *config.Config) error) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := fn(cfg); err != nil {
+		return err
+	}
+	return cfg.Save(paths.ConfigFile())
+}
+
+// applyColor forces colors on or off when the config asks to.
+func applyColor(cfg *config.Config) {
+	switch cfg.Color {
+	case "always":
+		lipgloss.SetColorProfile(termenv.TrueColor)
+	case "never":
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+}
+
+// shouldConfirm reports whether a destructive command should ask before
+// acting: not when --yes was passed or confirm is off in the config.
+func shouldConfirm(yes bool) bool {
+	if yes {
+		return false
+	}
+	cfg, err := loadConfig()
+	return err != nil || cfg.ShouldConfirm()
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Read and change cxa settings",
+	Long:  "Read and change cxa's own settings, stored in ~/.codex-switch/config.json.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+var configListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List every setting and its value",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		values := make(map[string]string)
+		t := table.New("KEY", "VALUE", "DESCRIPTION").Indent("  ")
+		for _, s := range config.Settings() {
+			v, _ := cfg.Get(s.Key)
+			values[s.Key] = v
+			t.Row(s.Key, v, s.Description)
+		}
+
+		return out.Result(values, func() {
+			out.Println(t.Style(func(row, col int) lipgloss.Style {
+				if row == -1 || col == 2 {
+					return styles.MutedStyle
+				}
+				return lipgloss.NewStyle()
+			}).Render())
+		})
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the value of a setting",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		v, err := cfg.Get(args[0])
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]string{args[0]: v}, func() {
+			out.Essential(v)
+		})
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Change a setting",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, value := args[0], args[1]
+		err := updateConfig(func(cfg *config.Config) error {
+			return cfg.Set(key, value)
+		})
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]string{key: value}, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Set %s to %s", key, value)))
+		})
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset <key>",
+	Short: "Restore a setting to its default",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key := args[0]
+		var value string
+		err := updateConfig(func(cfg *config.Config) error {
+			if err := cfg.Set(key, ""); err != nil {
+				return err
+			}
+			value, _ = cfg.Get(key)
+			return nil
+		})
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]string{key: value}, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Reset %s (now %s)", key, value)))
+		})
+	},
+}
+
+func completeSettings(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	keys := make([]string, 0, len(config.Settings()))
+	for _, s := range config.Settings() {
+		keys = append(keys, s.Key)
+	}
+	return keys, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	for _, c := range []*cobra.Command{configGetCmd, configSetCmd, configUnsetCmd} {
+		c.ValidArgsFunction = completeSettings
+	}
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/huh"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var (
+	deleteYes   bool
+	deleteForce bool
+)
+
+var deleteCmd = &cobra.Command{
+	Use:     "delete <name>",
+	Short:   "Delete a saved account",
+	Aliases: []string{"rm"},
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		if shouldConfirm(deleteYes) {
+			confirm := false
+			form := huh.NewForm(
+				huh.NewGroup(
+					huh.NewConfirm().
+						Title(fmt.Sprintf("Delete account '%s'?", name)).
+						Description("The saved copy moves to the trash; ~/.codex is left untouched.").
+						Value(&confirm),
+				),
+			)
+			if err := form.Run(); err != nil {
+				return err
+			}
+			if !confirm {
+				return out.Result(map[string]bool{"cancelled": true}, func() {
+					out.Println(styles.MutedStyle.Render("Cancelled."))
+				})
+			}
+		}
+
+		remove := repo.Delete
+		if deleteForce {
+			remove = repo.DeleteForce
+		}
+		if err := remove(name); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]string{"deleted": name}, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Deleted account: %s", name)))
+		})
+	},
+}
+
+var renameCmd = &cobra.Command{
+	Use:     "rename <name> <new-name>",
+	Short:   "Rename a saved account",
+	Aliases: []string{"mv"},
+	Args:    cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		oldName, newName := args[0], args[1]
+
+		if err := repo.Rename(oldName, newName); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]string{"old_name": oldName, "name": newName}, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Renamed %s to %s", oldName, newName)))
+		})
+	},
+}
+
+func init() {
+	deleteCmd.Flags().BoolVarP(&deleteYes, "yes", "y", false, "delete without asking")
+	deleteCmd.Flags().BoolVar(&deleteForce, "force", false, "delete the account even if it is locked")
+	rootCmd.AddCommand(deleteCmd)
+	rootCmd.AddCommand(renameCmd)
+}
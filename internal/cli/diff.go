@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/delhombre/cxa/internal/storage"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <a> [b]",
+	Short: "Compare two saved accounts",
+	Long:  "Show the files that exist in only one of two saved accounts or differ between them, and summarize changed settings in config.toml and settings.json. Without b, compare against the live ~/.codex.",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		right := ""
+		if len(args) == 2 {
+			right = args[1]
+		}
+
+		d, err := repo.Diff(args[0], right)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(d, func() {
+			out.Println()
+			out.Println(styles.RenderTitle(fmt.Sprintf("%s → %s", d.Left, d.Right)))
+			out.Println()
+
+			if d.Empty() {
+				out.Println(styles.MutedStyle.Render("  No differences."))
+				out.Println()
+				return
+			}
+
+			for _, e := range d.Entries {
+				switch e.Kind {
+				case storage.OnlyLeft:
+					out.Printf("  %s %s %s\n", styles.ErrorStyle.Render("-"), e.Path, styles.MutedStyle.Render("only in "+d.Left))
+				case storage.OnlyRight:
+					out.Printf("  %s %s %s\n", styles.SuccessStyle.Render("+"), e.Path, styles.MutedStyle.Render("only in "+d.Right))
+				case storage.Changed:
+					out.Printf("  %s %s %s\n", styles.WarningStyle.Render("~"), e.Path,
+						styles.MutedStyle.Render(fmt.Sprintf("%d → %d bytes", e.LeftSize, e.RightSize)))
+				}
+			}
+
+			if len(d.Settings) > 0 {
+				out.Println()
+				out.Println(styles.RenderTitle("Settings"))
+				out.Println()
+				for _, s := range d.Settings {
+					left, right := s.Left, s.Right
+					if left == "" {
+						left = styles.MutedStyle.Render("unset")
+					}
+					if right == "" {
+						right = styles.MutedStyle.Render("unset")
+					}
+					out.Printf("  %s %s: %s %s %s\n", styles.MutedStyle.Render(s.File), s.Key, left, styles.Arrow, right)
+				}
+			}
+			out.Println()
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+}
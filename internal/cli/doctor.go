@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/delhombre/cxa/internal/doctor"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var doctorFix bool
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose common problems",
+	Long:  "Check ~/.codex, state.json, sharing symlinks, auth.json, and permissions, and suggest fixes. With --fix, apply the automatic ones.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		d := doctor.New(repo)
+
+		if doctorFix {
+			for _, f := range d.Run() {
+				if f.Severity == doctor.OK || !f.CanRepair() {
+					continue
+				}
+				if err := f.Repair(); err != nil {
+					out.Println(styles.RenderError(fmt.Sprintf("Could not fix %s: %v", f.Message, err)))
+					continue
+				}
+				out.Println(styles.RenderSuccess("Fixed: " + f.Message))
+			}
+			out.Println()
+		}
+
+		out.Println(styles.RenderTitle("Doctor"))
+		out.Println()
+
+		results, problems, fixable := printFindings(d.Run())
+
+		if fixable > 0 && !doctorFix {
+			out.Println(styles.MutedStyle.Render(fmt.Sprintf("Run 'cxa doctor --fix' to apply %d automatic fix(es).", fixable)))
+		}
+		if err := out.Result(results, nil); err != nil {
+			return err
+		}
+		if problems > 0 {
+			return fmt.Errorf("doctor found %d problem(s)", problems)
+		}
+		return nil
+	},
+}
+
+// findingResult is the JSON form of a doctor finding.
+type findingResult struct {
+	Check    string          `json:"check"`
+	Severity doctor.Severity `json:"severity"`
+	Message  string          `json:"message"`
+	Fix      string          `json:"fix,omitempty"`
+	Fixable  bool            `json:"fixable"`
+}
+
+// printFindings renders findings as a checklist and returns their JSON
+// form along with the number of problems and automatically fixable ones.
+func printFindings(findings []*doctor.Finding) (results []findingResult, problems, fixable int) {
+	results = []findingResult{}
+	for _, f := range findings {
+		results = append(results, findingResult{
+			Check:    f.Check,
+			Severity: f.Severity,
+			Message:  f.Message,
+			Fix:      f.Fix,
+			Fixable:  f.CanRepair(),
+		})
+
+		switch f.Severity {
+		case doctor.OK:
+			out.Printf("  %s %s %s\n", styles.CheckMark, styles.MutedStyle.Render(f.Check+":"), f.Message)
+			continue
+		case doctor.Warning:
+			out.Printf("  %s %s %s\n", styles.WarningStyle.Render("!"), styles.MutedStyle.Render(f.Check+":"), f.Message)
+		case doctor.Problem:
+			problems++
+			out.Printf("  %s %s %s\n", styles.CrossMark, styles.MutedStyle.Render(f.Check+":"), f.Message)
+		}
+		if f.Fix != "" {
+			out.Printf("      %s %s\n", styles.Arrow, styles.MutedStyle.Render(f.Fix))
+		}
+		if f.CanRepair() {
+			fixable++
+		}
+	}
+	out.Println()
+	return results, problems, fixable
+}
+
+func init() {
+	doctorCmd.Flags().BoolVar(&doctorFix, "fix", false, "apply automatic fixes")
+	rootCmd.AddCommand(doctorCmd)
+}
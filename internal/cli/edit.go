@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+	"github.com/delhombre/cxa/internal/account"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var (
+	editEditor      bool
+	editDescription string
+	editNotes       string
+)
+
+var editCmd = &cobra.Command{
+	Use:   "edit <name>",
+	Short: "Edit an account's description and notes",
+	Long:  "Edit the one-line description and free-form notes kept with an account, in a form or, with --editor, in $EDITOR. --description and --notes set them without prompting.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		acc, err := repo.Get(name)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		description, notes := acc.Description, acc.Notes
+
+		setDescription := cmd.Flags().Changed("description")
+		setNotes := cmd.Flags().Changed("notes")
+		switch {
+		case setDescription || setNotes:
+			if setDescription {
+				description = editDescription
+			}
+			if setNotes {
+				notes = editNotes
+			}
+		case editEditor:
+			if description, notes, err = editInEditor(name, description, notes); err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+		case out.Interactive():
+			err := huh.NewForm(
+				huh.NewGroup(
+					huh.NewInput().
+						Title("Description").
+						Description("What is "+name+" for?").
+						Value(&description),
+					huh.NewText().
+						Title("Notes").
+						Value(&notes),
+				),
+			).Run()
+			if err != nil {
+				return err
+			}
+		default:
+			err := fmt.Errorf("nothing to edit: pass --description or --notes, or run in a terminal")
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		acc, err = repo.UpdateMetadata(name, func(acc *account.Account) {
+			acc.Description = strings.TrimSpace(description)
+			acc.Notes = strings.TrimSpace(notes)
+		})
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]string{
+			"account":     name,
+			"description": acc.Description,
+			"notes":       acc.Notes,
+		}, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Updated %s", name)))
+		})
+	},
+}
+
+// editInEditor lets the user edit description and notes in $EDITOR: the
+// first line is the description and everything after it the notes.
+func editInEditor(name, description, notes string) (string, string, error) {
+	f, err := os.CreateTemp("", "cxa-edit-*.txt")
+	if err != nil {
+		return "", "", err
+	}
+	defer os.Remove(f.Name())
+
+	fmt.Fprintf(f, "%s\n\n%s\n", description, notes)
+	fmt.Fprintf(f, "# Editing %s. The first line is the description and the\n", name)
+	fmt.Fprintln(f, "# rest are notes. Lines starting with '#' are ignored.")
+	if err := f.Close(); err != nil {
+		return "", "", err
+	}
+
+	editor := strings.Fields(os.Getenv("VISUAL"))
+	if len(editor) == 0 {
+		editor = strings.Fields(os.Getenv("EDITOR"))
+	}
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+	}
+
+	sub := exec.Command(editor[0], append(editor[1:], f.Name())...)
+	sub.Stdin = os.Stdin
+	sub.Stdout = os.Stdout
+	sub.Stderr = os.Stderr
+	if err := sub.Run(); err != nil {
+		return "", "", fmt.Errorf("editor failed: %w", err)
+	}
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", "", err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return "", "", nil
+	}
+	return lines[0], strings.Join(lines[1:], "\n"), nil
+}
+
+func init() {
+	editCmd.Flags().BoolVar(&editEditor, "editor", false, "edit in $EDITOR instead of a form")
+	editCmd.Flags().StringVar(&editDescription, "description", "", "set the description")
+	editCmd.Flags().StringVar(&editNotes, "notes", "", "set the notes")
+	rootCmd.AddCommand(editCmd)
+}
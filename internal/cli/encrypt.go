@@ -0,0 +1,241 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/delhombre/cxa/internal/agent"
+	"github.com/delhombre/cxa/internal/config"
+	"github.com/delhombre/cxa/internal/storage"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var agentFor time.Duration
+
+var encryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt saved accounts with a passphrase",
+	Long: `Seal every saved account with a passphrase, so the auth tokens and
+sessions in the data directory cannot be read without it, and keep sealing
+accounts whenever they are saved. Switching to an account decrypts it
+straight into ~/.codex.
+
+The passphrase is read from the file named by the key_file setting, from
+CXA_PASSPHRASE, from a running cxa agent, or asked for, in that order. The
+first passphrase used becomes the one for every account; start cxa agent to
+type it once for a while.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		if cfg.Activation == storage.ActivateLink {
+			err := errors.New("encrypted accounts cannot be linked to ~/.codex; run cxa config set activation copy first")
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		err = updateConfig(func(cfg *config.Config) error {
+			cfg.Encrypt = true
+			return nil
+		})
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		repo.SetEncryption(true)
+
+		return eachAccount(repo.Seal, "encrypt", "Encrypted %d accounts")
+	},
+}
+
+var decryptCmd = &cobra.Command{
+	Use:   "decrypt",
+	Short: "Decrypt saved accounts and stop encrypting them",
+	Long:  "Decrypt every account sealed with cxa encrypt, leaving its files readable in the data directory, and stop sealing accounts when they are saved.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := updateConfig(func(cfg *config.Config) error {
+			cfg.Encrypt = false
+			return nil
+		})
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		repo.SetEncryption(false)
+
+		return eachAccount(repo.Unseal, "decrypt", "Decrypted %d accounts")
+	},
+}
+
+// eachAccount applies fn to every saved account and reports how each went.
+// verb describes fn for failures, done formats the count when all went
+// well.
+func eachAccount(fn func(name string) error, verb, done string) error {
+	accounts, err := repo.List()
+	if err != nil {
+		out.Println(styles.RenderError(err.Error()))
+		return err
+	}
+
+	type applied struct {
+		Account string `json:"account"`
+		Error   string `json:"error,omitempty"`
+	}
+	results := []applied{}
+	var failed int
+	for _, acc := range accounts {
+		result := applied{Account: acc.Name}
+		if err := fn(acc.Name); err != nil {
+			result.Error = err.Error()
+			failed++
+			out.Printf("  %s %s %s\n", styles.CrossMark, acc.Name, styles.ErrorStyle.Render(err.Error()))
+		} else {
+			out.Printf("  %s %s\n", styles.CheckMark, acc.Name)
+		}
+		results = append(results, result)
+	}
+
+	err = out.Result(results, func() {
+		out.Println()
+		if failed == 0 {
+			out.Println(styles.RenderSuccess(fmt.Sprintf(done, len(accounts))))
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d accounts failed to %s", failed, verb)
+	}
+	return nil
+}
+
+// stowAfter puts an account back in its stored form, sealed and with its
+// credentials in the keychain as configured, once a command that expanded
+// it in place is done with its files.
+func stowAfter(name string) {
+	if err := repo.Stow(name); err != nil {
+		warningStore().Record("storage", fmt.Sprintf("failed to store '%s' back as configured: %v", name, err))
+	}
+}
+
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Remember the passphrase of encrypted accounts for a while",
+	Long:  "Run a background agent that holds the passphrase of encrypted accounts, so commands that need it do not ask every time. The agent answers only its owner and forgets the passphrase when it exits.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+var agentStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Ask for the passphrase once and remember it",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		socket := paths.AgentSocket()
+		if agent.Running(socket) {
+			err := errors.New("an agent is already running; stop it first with cxa agent stop")
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		key, err := readPassphrase(!repo.HasKey())
+		if err == nil {
+			err = repo.CheckKey(key)
+		}
+		if err == nil {
+			var exe string
+			if exe, err = os.Executable(); err == nil {
+				serve := exec.Command(exe, "agent", "serve", "--for", agentFor.String())
+				err = agent.Start(serve, socket, key)
+			}
+		}
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		until := time.Now().Add(agentFor)
+		return out.Result(map[string]any{"running": true, "until": until}, func() {
+			out.Println(styles.RenderSuccess("Agent started until " + until.Format(time.Kitchen)))
+		})
+	},
+}
+
+var agentStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Forget the passphrase now",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := agent.Stop(paths.AgentSocket())
+		if err != nil && !errors.Is(err, agent.ErrNotRunning) {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]bool{"running": false}, func() {
+			if err != nil {
+				out.Println(styles.MutedStyle.Render("No agent is running."))
+				return
+			}
+			out.Println(styles.RenderSuccess("Agent stopped"))
+		})
+	},
+}
+
+var agentStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether an agent is running",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		running := agent.Running(paths.AgentSocket())
+		return out.Result(map[string]bool{"running": running}, func() {
+			if running {
+				out.Println(styles.RenderSuccess("Agent is running"))
+			} else {
+				out.Println(styles.MutedStyle.Render("No agent is running."))
+			}
+		})
+	},
+}
+
+// agentServeCmd is the agent process itself, started by agent start with
+// the passphrase on its standard input.
+var agentServeCmd = &cobra.Command{
+	Use:    "serve",
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		if len(key) == 0 {
+			return errors.New("no passphrase on standard input")
+		}
+		return agent.Serve(paths.AgentSocket(), key, agentFor)
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{agentStartCmd, agentServeCmd} {
+		c.Flags().DurationVar(&agentFor, "for", 15*time.Minute, "how long to remember the passphrase")
+	}
+	agentCmd.AddCommand(agentStartCmd)
+	agentCmd.AddCommand(agentStopCmd)
+	agentCmd.AddCommand(agentStatusCmd)
+	agentCmd.AddCommand(agentServeCmd)
+	rootCmd.AddCommand(encryptCmd)
+	rootCmd.AddCommand(decryptCmd)
+	rootCmd.AddCommand(agentCmd)
+}
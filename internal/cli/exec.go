@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var execScratch bool
+
+var execCmd = &cobra.Command{
+	Use:   "exec <name> [-- command...]",
+	Short: "Run a command under an account without switching",
+	Long: "Run codex, or any command, with CODEX_HOME pointing at a saved account, leaving ~/.codex and the current account untouched. Use it to work with two accounts at once in different terminals.\n\n" +
+		"Changes the command makes are written to the saved account. With --scratch, it runs on a temporary copy that is discarded afterwards. The current account runs against ~/.codex itself.",
+	Example: "  cxa exec work\n  cxa exec work -- codex exec \"summarize this repo\"",
+	Args:    cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, command := args[0], args[1:]
+		if len(command) > 0 && command[0] == "--" {
+			command = command[1:]
+		}
+		if len(command) == 0 {
+			command = []string{"codex"}
+		}
+
+		if err := repo.Expand(name); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		defer stowAfter(name)
+		dir, err := repo.AccountDir(name)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		current, _ := repo.Current()
+		if name == current {
+			dir = paths.Home
+		}
+
+		if execScratch {
+			if name == current {
+				if _, err := repo.Save(name); err != nil {
+					out.Println(styles.RenderError(err.Error()))
+					return err
+				}
+			}
+			if dir, err = repo.Clone(name); err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+			defer os.RemoveAll(dir)
+		}
+
+		sub := exec.Command(command[0], command[1:]...)
+		sub.Env = append(os.Environ(), "CODEX_HOME="+dir, "CXA_ACCOUNT="+name)
+		sub.Stdin = os.Stdin
+		sub.Stdout = os.Stdout
+		sub.Stderr = os.Stderr
+		if err := sub.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				// The command already reported its failure; just pass on
+				// its exit status
+				cmd.SilenceErrors = true
+				return &exitError{code: exitErr.ExitCode()}
+			}
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		return nil
+	},
+}
+
+// exitError carries the exit status of a command cxa ran on the user's
+// behalf, so that cxa exits with it too.
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+func init() {
+	execCmd.Flags().BoolVar(&execScratch, "scratch", false, "run on a temporary copy that is discarded afterwards")
+	execCmd.Flags().SetInterspersed(false)
+	rootCmd.AddCommand(execCmd)
+}
@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+)
+
+// Exit codes, documented for scripts. Commands run through cxa exec exit
+// with the command's own status instead.
+const (
+	exitFailure   = 1 // the command failed
+	exitUsage     = 2 // bad flags or arguments
+	exitUnhealthy = 3 // a check such as cxa share status found a problem
+)
+
+// usageError marks a mistake in how cxa was invoked.
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string { return e.err.Error() }
+func (e *usageError) Unwrap() error { return e.err }
+
+// ExitCode returns the process exit code for an error returned by Execute.
+func ExitCode(err error) int {
+	var exitErr *exitError
+	if errors.As(err, &exitErr) && exitErr.code > 0 {
+		return exitErr.code
+	}
+	var usageErr *usageError
+	if errors.As(err, &usageErr) {
+		return exitUsage
+	}
+	return exitFailure
+}
+
+// markUsageErrors makes flag and argument errors of cmd and its
+// subcommands usage errors.
+func markUsageErrors(cmd *cobra.Command) {
+	cmd.SetFlagErrorFunc(func(c *cobra.Command, err error) error {
+		return &usageError{err: err}
+	})
+	if validate := cmd.Args; validate != nil {
+		cmd.Args = func(c *cobra.Command, args []string) error {
+			if err := validate(c, args); err != nil {
+				return &usageError{err: err}
+			}
+			return nil
+		}
+	}
+	for _, sub := range cmd.Commands() {
+		markUsageErrors(sub)
+	}
+}
@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/delhombre/cxa/internal/account"
+	"github.com/delhombre/cxa/internal/crypt"
+	"github.com/delhombre/cxa/internal/policy"
+	"github.com/delhombre/cxa/internal/transfer"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportOutput     string
+	exportNoSessions bool
+	exportEncrypt    bool
+	exportOneTime    bool
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export <name>",
+	Short: "Export an account to a portable tar.gz archive",
+	Long: "Export an account, credentials included, to an archive cxa import reads on another machine.\n\n" +
+		"With --encrypt, the archive is encrypted and authenticated with a passphrase (or $CXA_PASSPHRASE), so the OAuth tokens in it are safe to send over email or a shared drive, and any tampering is caught on import. " +
+		"Encrypted archives are age files, which age -d also decrypts with the passphrase. " +
+		"--one-time generates a random passphrase for this archive alone and prints it, to pass on separately from the file. " +
+		"--output - writes the archive to stdout, for piping to cxa import - elsewhere.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		acc, err := repo.Get(name)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		if err := repo.Expand(name); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		defer stowAfter(name)
+		dir, err := repo.AccountDir(name)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		encrypt := exportEncrypt || exportOneTime
+		var passphrase []byte
+		switch {
+		case exportOneTime:
+			if passphrase, err = oneTimePassphrase(); err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+		case encrypt:
+			if passphrase, err = readPassphrase(true); err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+		}
+
+		// A policy that cannot be loaded keeps credentials out too
+		sysPolicy, _ := policy.System()
+		opts := transfer.ExportOptions{
+			ExcludeSessions: exportNoSessions,
+			ExcludeSecrets:  sysPolicy.DisallowSecretExport,
+		}
+		output := exportOutput
+		if output == "-" {
+			// The archive is the only thing written to stdout
+			if err := writeExport(os.Stdout, dir, acc, opts, passphrase); err != nil {
+				return err
+			}
+			if exportOneTime {
+				fmt.Fprintf(os.Stderr, "Passphrase: %s\n", passphrase)
+			}
+			return nil
+		}
+		if output == "" {
+			output = fmt.Sprintf("%s-%s.tar.gz", name, time.Now().Format("20060102"))
+			if encrypt {
+				output += ".enc"
+			}
+		}
+
+		out.Printf("%s Exporting %s to %s...\n",
+			styles.Caret,
+			styles.PrimaryStyle.Render(name),
+			output,
+		)
+
+		f, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		err = writeExport(f, dir, acc, opts, passphrase)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(output)
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		result := map[string]any{"account": name, "output": output, "sessions": !exportNoSessions, "secrets": !opts.ExcludeSecrets, "encrypted": encrypt}
+		if exportOneTime {
+			result["passphrase"] = string(passphrase)
+		}
+		return out.Result(result, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Exported %s", name)))
+			if exportOneTime {
+				out.Printf("  Passphrase: %s\n", styles.BoldStyle.Render(string(passphrase)))
+				out.Println(styles.MutedStyle.Render("  It is shown only once; send it separately from the archive."))
+			} else if !encrypt && !opts.ExcludeSecrets {
+				out.Println(styles.MutedStyle.Render("The archive contains credentials; use --encrypt before sending it to another machine."))
+			}
+			if exportNoSessions {
+				out.Println(styles.MutedStyle.Render("Sessions were not included."))
+			}
+			if opts.ExcludeSecrets {
+				out.Println(styles.MutedStyle.Render("Credentials were not included (disallowed by policy)."))
+			}
+		})
+	},
+}
+
+// writeExport writes the account at dir to w, encrypting it when a
+// passphrase is given.
+func writeExport(w io.Writer, dir string, acc *account.Account, opts transfer.ExportOptions, passphrase []byte) error {
+	if passphrase == nil {
+		return transfer.Export(w, dir, acc, opts)
+	}
+
+	cw, err := crypt.NewWriter(w, passphrase)
+	if err != nil {
+		return err
+	}
+	if err := transfer.Export(cw, dir, acc, opts); err != nil {
+		return err
+	}
+	return cw.Close()
+}
+
+// oneTimePassphrase returns a random passphrase of five groups of five
+// letters and digits, easy to read out or type on another machine.
+func oneTimePassphrase() ([]byte, error) {
+	// 32 symbols, so every one is equally likely; no l, o, 0, or 1
+	const alphabet = "abcdefghijkmnpqrstuvwxyz23456789"
+	random := make([]byte, 25)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	for i, r := range random {
+		if i > 0 && i%5 == 0 {
+			b.WriteByte('-')
+		}
+		b.WriteByte(alphabet[int(r)%len(alphabet)])
+	}
+	return []byte(b.String()), nil
+}
+
+func init() {
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "archive path, or - for stdout (default <name>-<date>.tar.gz)")
+	exportCmd.Flags().BoolVar(&exportNoSessions, "no-sessions", false, "leave sessions out of the archive")
+	exportCmd.Flags().BoolVar(&exportEncrypt, "encrypt", false, "encrypt the archive with a passphrase (or $CXA_PASSPHRASE)")
+	exportCmd.Flags().BoolVar(&exportOneTime, "one-time", false, "encrypt with a generated passphrase, printed once")
+	rootCmd.AddCommand(exportCmd)
+}
@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+var gcDryRun bool
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove session blobs no account uses any more",
+	Long: "With dedup on, session files identical across accounts are stored once in the blob store and linked from each account. " +
+		"Blobs stay behind when the last account, snapshot, or deleted account using them goes; gc removes them.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		result, err := repo.GC(gcDryRun)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(result, func() {
+			switch {
+			case result.Blobs == 0:
+				out.Println(styles.MutedStyle.Render("No unused blobs."))
+			case gcDryRun:
+				out.Printf("%s Would remove %d unused blobs, freeing %s\n", styles.Caret, result.Blobs, humanize.Bytes(uint64(result.Bytes)))
+			default:
+				out.Println(styles.RenderSuccess(fmt.Sprintf("Removed %d unused blobs, freeing %s", result.Blobs, humanize.Bytes(uint64(result.Bytes)))))
+			}
+		})
+	},
+}
+
+func init() {
+	gcCmd.Flags().BoolVar(&gcDryRun, "dry-run", false, "report what would be removed without removing it")
+	rootCmd.AddCommand(gcCmd)
+}
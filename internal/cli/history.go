@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"github.com/charmbracelet/lipgloss"
+	"github.com/delhombre/cxa/internal/history"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/delhombre/cxa/internal/ui/table"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+var historyLimit int
+
+var historyCmd = &cobra.Command{
+	Use:   "history [name]",
+	Short: "Show recent saves, switches, renames, and deletions",
+	Long:  "Show the latest account operations, newest first. With a name, only show operations involving that account.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		account := ""
+		if len(args) == 1 {
+			account = args[0]
+		}
+
+		entries, err := history.NewLog(paths.HistoryFile()).Recent(account, historyLimit)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(entries, func() {
+			if len(entries) == 0 {
+				out.Println(styles.MutedStyle.Render("No history recorded yet."))
+				return
+			}
+
+			out.Println(styles.RenderTitle("History"))
+			out.Println()
+
+			t := table.New("WHEN", "OPERATION", "ACCOUNT", "DETAIL").Indent("  ")
+			for _, e := range entries {
+				detail := ""
+				switch {
+				case e.Op == history.OpSwitch && e.From != "":
+					detail = "from " + e.From
+				case e.Op == history.OpRename, e.Op == history.OpRestore && e.From != e.Account:
+					detail = "was " + e.From
+				case e.Op == history.OpRollback:
+					detail = "to " + e.Snapshot
+				}
+				t.Row(humanize.Time(e.Time), string(e.Op), e.Account, detail)
+			}
+			out.Println(t.Style(func(row, col int) lipgloss.Style {
+				switch {
+				case row == -1 || col == 0 || col == 3:
+					return styles.MutedStyle
+				case col == 1 && entries[row].Op == history.OpDelete:
+					return styles.ErrorStyle
+				}
+				return lipgloss.NewStyle()
+			}).Render())
+			out.Println()
+		})
+	},
+}
+
+func init() {
+	historyCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "how many operations to show (0 for all)")
+	rootCmd.AddCommand(historyCmd)
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/delhombre/cxa/internal/sharing"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/delhombre/cxa/internal/ui/table"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historySessionsAccount string
+	historySessionsLimit   int
+)
+
+var historySessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Show the sessions of the shared history and the accounts they came from",
+	Long: "Show the sessions of the history.jsonl the current account shares, most recent first, with the account each came from. " +
+		"cxa attributes what is added to the shared history to the account in ~/.codex when it switches away, " +
+		"so sessions written before sharing recorded accounts show none. With --account, only show that account's sessions.",
+	Example: "  cxa history sessions\n  cxa history sessions --account work",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := loadSharing()
+		if err != nil {
+			return err
+		}
+
+		current, _ := repo.Current()
+		sessions, err := manager.HistorySessions(current)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		if historySessionsAccount != "" {
+			sessions = slices.DeleteFunc(sessions, func(s sharing.HistorySession) bool {
+				return s.Account != historySessionsAccount
+			})
+		}
+		if historySessionsLimit > 0 && len(sessions) > historySessionsLimit {
+			sessions = sessions[:historySessionsLimit]
+		}
+
+		return out.Result(sessions, func() {
+			if len(sessions) == 0 {
+				if historySessionsAccount != "" {
+					out.Println(styles.MutedStyle.Render(fmt.Sprintf("No shared history from '%s'.", historySessionsAccount)))
+					return
+				}
+				out.Println(styles.MutedStyle.Render("No shared history yet."))
+				return
+			}
+
+			out.Println(styles.RenderTitle("Shared History"))
+			out.Println()
+
+			t := table.New("WHEN", "ACCOUNT", "PROMPTS", "FIRST PROMPT").Indent("  ")
+			for _, s := range sessions {
+				account := s.Account
+				if account == "" {
+					account = "-"
+				}
+				t.Row(humanize.Time(s.Last), account, fmt.Sprint(s.Entries), strings.Join(strings.Fields(s.Text), " "))
+			}
+			out.Println(t.Style(func(row, col int) lipgloss.Style {
+				if row == -1 || col == 0 || (col == 1 && sessions[row].Account == "") {
+					return styles.MutedStyle
+				}
+				return lipgloss.NewStyle()
+			}).Render())
+			out.Println()
+		})
+	},
+}
+
+func init() {
+	historySessionsCmd.Flags().StringVar(&historySessionsAccount, "account", "", "only show sessions that came from this account")
+	historySessionsCmd.Flags().IntVarP(&historySessionsLimit, "limit", "n", 20, "how many sessions to show (0 for all)")
+	historySessionsCmd.RegisterFlagCompletionFunc("account", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return completeAccountNames(cmd, nil, toComplete)
+	})
+	historyCmd.AddCommand(historySessionsCmd)
+}
@@ -0,0 +1,177 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/delhombre/cxa/internal/config"
+	"github.com/delhombre/cxa/internal/storage"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/delhombre/cxa/pkg/codex"
+	"github.com/spf13/cobra"
+)
+
+var homeFlag string
+
+var homeCmd = &cobra.Command{
+	Use:   "home",
+	Short: "Manage other Codex homes, each with its own accounts",
+	Long: `Some codex installations run with their own CODEX_HOME. Add each such
+home under a name, then work on its accounts with --home <name> or by
+setting CXA_HOME=<name>; they are kept apart from the accounts of ~/.codex
+and of every other home. The cxa config is shared by all of them.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+var homeAddCmd = &cobra.Command{
+	Use:   "add <name> <dir>",
+	Short: "Add a Codex home",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		dir, err := filepath.Abs(args[1])
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\=, `) {
+			err := &usageError{err: fmt.Errorf("invalid home name '%s': use letters, digits, - and _", name)}
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		err = updateHomes(func(homes map[string]string) error {
+			if _, ok := homes[name]; ok {
+				return fmt.Errorf("home '%s' already exists", name)
+			}
+			for other, d := range homes {
+				if d == dir {
+					return fmt.Errorf("%s is already the home '%s'", dir, other)
+				}
+			}
+			homes[name] = dir
+			return nil
+		})
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]string{"name": name, "dir": dir}, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Added home '%s' (%s)", name, dir)))
+			out.Println(styles.MutedStyle.Render(fmt.Sprintf("  Use it with: cxa --home %s <command>, or %s=%s", name, codex.EnvHome, name)))
+		})
+	},
+}
+
+var homeRemoveCmd = &cobra.Command{
+	Use:     "remove <name>",
+	Aliases: []string{"rm"},
+	Short:   "Remove a Codex home",
+	Long:    "Remove a Codex home from the cxa config. Its directory and the accounts saved for it are left in place, and come back if it is added again under the same name.",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		err := updateHomes(func(homes map[string]string) error {
+			if _, ok := homes[name]; !ok {
+				return fmt.Errorf("home '%s' not found", name)
+			}
+			delete(homes, name)
+			return nil
+		})
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]string{"removed": name}, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Removed home '%s'", name)))
+		})
+	},
+}
+
+var homeListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List Codex homes",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		names := make([]string, 0, len(cfg.Homes))
+		for name := range cfg.Homes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		homes := cfg.Homes
+		if homes == nil {
+			homes = map[string]string{}
+		}
+		return out.Result(map[string]any{"current": paths.HomeName(), "homes": homes}, func() {
+			if len(names) == 0 {
+				out.Println(styles.MutedStyle.Render("No other homes configured. Add one with: cxa home add <name> <dir>"))
+				return
+			}
+			for _, name := range names {
+				label := styles.BoldStyle.Render(name)
+				if name == paths.HomeName() {
+					label = styles.CurrentAccountStyle.Render(name) + " " + styles.MutedStyle.Render("(in use)")
+				}
+				out.Printf("  %s %s %s\n", styles.Bullet, label, styles.MutedStyle.Render(cfg.Homes[name]))
+			}
+		})
+	},
+}
+
+// updateHomes applies fn to the configured homes and saves the config.
+func updateHomes(fn func(homes map[string]string) error) error {
+	return updateConfig(func(cfg *config.Config) error {
+		if cfg.Homes == nil {
+			cfg.Homes = make(map[string]string)
+		}
+		return fn(cfg.Homes)
+	})
+}
+
+// applyHome switches to the Codex home named by --home, as CXA_HOME would,
+// which also passes it on to the commands cxa runs.
+func applyHome() error {
+	if homeFlag == "" {
+		return nil
+	}
+	if err := os.Setenv(codex.EnvHome, homeFlag); err != nil {
+		return err
+	}
+	*paths = *storage.DefaultPaths()
+	if paths.HomeName() != homeFlag {
+		return fmt.Errorf("unknown Codex home '%s'; add it with cxa home add %s <dir>", homeFlag, homeFlag)
+	}
+	repo = storage.NewDirectoryRepositoryWithPaths(paths)
+	return nil
+}
+
+// checkDefaultHome refuses operations on the data directory as a whole
+// while another Codex home is in use, as its accounts are only part of it.
+func checkDefaultHome() error {
+	if paths.HomeName() != "" {
+		return errors.New("moving storage works on every home at once; run it without --home or " + codex.EnvHome)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&homeFlag, "home", "", "work on the accounts of another Codex home (see cxa home)")
+	homeCmd.AddCommand(homeAddCmd, homeRemoveCmd, homeListCmd)
+	rootCmd.AddCommand(homeCmd)
+}
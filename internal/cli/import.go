@@ -0,0 +1,187 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/charmbracelet/huh"
+	"github.com/delhombre/cxa/internal/account"
+	"github.com/delhombre/cxa/internal/transfer"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importName  string
+	importForce bool
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import <archive>",
+	Short: "Import an account from an exported archive",
+	Long:  "Import an account exported with cxa export. An encrypted archive asks for its passphrase (or reads $CXA_PASSPHRASE), and is refused if it was altered. An archive of - is read from stdin.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if args[0] == "-" {
+			path, err := spoolStdin()
+			if err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+			defer os.Remove(path)
+			args[0] = path
+		}
+
+		// Encrypted archives are decrypted twice (inspect, then import), so
+		// ask for the passphrase once up front
+		var passphrase []byte
+		if encrypted, err := isEncryptedFile(args[0]); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		} else if encrypted {
+			if passphrase, err = readPassphrase(false); err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+		}
+
+		var manifest *transfer.Manifest
+		err := readArchive(args[0], passphrase, func(r io.Reader) (err error) {
+			manifest, err = transfer.Inspect(r)
+			return err
+		})
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		name := importName
+		if name == "" {
+			name = manifest.Account.Name
+		}
+
+		name, err = resolveImportName(name, importForce)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		if name == "" {
+			return out.Result(map[string]bool{"cancelled": true}, func() {
+				out.Println(styles.MutedStyle.Render("Cancelled."))
+			})
+		}
+
+		out.Printf("%s Importing %s...\n",
+			styles.Caret,
+			styles.PrimaryStyle.Render(name),
+		)
+
+		var acc *account.Account
+		err = readArchive(args[0], passphrase, func(r io.Reader) (err error) {
+			if importForce {
+				acc, err = repo.ImportForce(name, r)
+			} else {
+				acc, err = repo.Import(name, r)
+			}
+			return err
+		})
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(acc, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Imported account: %s", name)))
+			if len(manifest.Excluded) > 0 {
+				out.Println(styles.MutedStyle.Render(fmt.Sprintf("Not included in the archive: %v", manifest.Excluded)))
+			}
+		})
+	},
+}
+
+// spoolStdin copies stdin to a private temporary file and returns its
+// path, as an archive is read more than once.
+func spoolStdin() (string, error) {
+	f, err := os.CreateTemp("", "cxa-import-*.tar.gz")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(f, os.Stdin)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// resolveImportName asks how to handle an existing account with the same
+// name. It returns the name to import under, or "" if the user cancelled.
+func resolveImportName(name string, force bool) (string, error) {
+	return resolveClash(name, force, func(name string) bool {
+		_, err := repo.Get(name)
+		return err == nil
+	})
+}
+
+// resolveClash asks how to handle an account arriving under a name exists
+// says is taken: under another name, overwriting, or not at all. It
+// returns the name to use, or "" if the user cancelled.
+func resolveClash(name string, force bool, exists func(name string) bool) (string, error) {
+	for {
+		if err := account.ValidateName(name); err != nil {
+			return "", err
+		}
+		if force || !exists(name) {
+			return name, nil
+		}
+		if !out.Interactive() {
+			return "", fmt.Errorf("account '%s' already exists; pass --force to overwrite it", name)
+		}
+
+		choice := "rename"
+		form := huh.NewForm(
+			huh.NewGroup(
+				huh.NewSelect[string]().
+					Title(fmt.Sprintf("Account '%s' already exists", name)).
+					Options(
+						huh.NewOption("Import under a different name", "rename"),
+						huh.NewOption("Overwrite the existing account", "overwrite"),
+						huh.NewOption("Cancel", "cancel"),
+					).
+					Value(&choice),
+			),
+		)
+		if err := form.Run(); err != nil {
+			return "", err
+		}
+
+		switch choice {
+		case "overwrite":
+			return name, nil
+		case "cancel":
+			return "", nil
+		}
+
+		form = huh.NewForm(
+			huh.NewGroup(
+				huh.NewInput().
+					Title("New account name").
+					Validate(account.ValidateName).
+					Value(&name),
+			),
+		)
+		if err := form.Run(); err != nil {
+			return "", err
+		}
+	}
+}
+
+func init() {
+	importCmd.Flags().StringVar(&importName, "name", "", "import under this name instead of the archived one")
+	importCmd.Flags().BoolVarP(&importForce, "force", "f", false, "overwrite an existing account, even a locked one, without asking")
+	rootCmd.AddCommand(importCmd)
+}
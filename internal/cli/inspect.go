@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/delhombre/cxa/internal/storage"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var (
+	inspectKeep  bool
+	inspectClean bool
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect <name> | --clean",
+	Short: "Browse a saved account's files without activating it",
+	Long: "Open a subshell in a read-only copy of a saved account, to look through its sessions or grab a prompt from an old transcript without switching to it. The copy leaves out credentials, and is removed when the shell exits.\n\n" +
+		"With --keep, print the path of the copy instead and leave it in place for a file browser or editor; cxa inspect --clean removes every copy kept this way. The current account is shown as last saved; ~/.codex itself holds its live files.",
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if inspectClean {
+			if len(args) > 0 {
+				err := &usageError{err: errors.New("--clean takes no account name")}
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+			return cleanViews()
+		}
+		if len(args) == 0 {
+			err := &usageError{err: errors.New("name the account to inspect")}
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		name := args[0]
+		dir, err := repo.Inspect(name)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		defer stowAfter(name)
+
+		if inspectKeep {
+			return out.Result(map[string]string{"account": name, "dir": dir}, func() {
+				if out.Quiet() {
+					out.Essential(dir)
+					return
+				}
+				out.Printf("%s Read-only copy of %s at:\n", styles.Caret, styles.PrimaryStyle.Render(name))
+				out.Essential("  " + dir)
+				out.Println(styles.MutedStyle.Render("  Remove it with: cxa inspect --clean"))
+			})
+		}
+		defer func() {
+			if err := storage.RemoveView(dir); err != nil {
+				out.Println(styles.RenderWarning(fmt.Sprintf("failed to remove %s: %v", dir, err)))
+			}
+		}()
+
+		shell := os.Getenv("SHELL")
+		if shell == "" {
+			shell = "/bin/sh"
+		}
+		out.Printf("%s Inspecting %s in a read-only copy. Exit the shell to finish.\n",
+			styles.Caret,
+			styles.PrimaryStyle.Render(name),
+		)
+
+		sub := exec.Command(shell)
+		sub.Dir = dir
+		sub.Env = append(os.Environ(), "CXA_INSPECT="+name)
+		sub.Stdin = os.Stdin
+		sub.Stdout = os.Stdout
+		sub.Stderr = os.Stderr
+		if err := sub.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+		}
+		return out.Result(map[string]string{"account": name}, nil)
+	},
+}
+
+// cleanViews removes the read-only copies left by inspect --keep.
+func cleanViews() error {
+	views, err := repo.Views()
+	if err != nil {
+		out.Println(styles.RenderError(err.Error()))
+		return err
+	}
+	for _, dir := range views {
+		if err := storage.RemoveView(dir); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+	}
+
+	if views == nil {
+		views = []string{}
+	}
+	return out.Result(map[string][]string{"removed": views}, func() {
+		if len(views) == 0 {
+			out.Println(styles.MutedStyle.Render("No inspected copies to remove."))
+			return
+		}
+		out.Println(styles.RenderSuccess(fmt.Sprintf("Removed %d inspected copies", len(views))))
+	})
+}
+
+func init() {
+	inspectCmd.Flags().BoolVar(&inspectKeep, "keep", false, "leave the copy in place and print its path")
+	inspectCmd.Flags().BoolVar(&inspectClean, "clean", false, "remove the copies left by --keep")
+	rootCmd.AddCommand(inspectCmd)
+}
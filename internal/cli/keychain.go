@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"github.com/delhombre/cxa/internal/config"
+	"github.com/delhombre/cxa/internal/keychain"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var keychainCmd = &cobra.Command{
+	Use:   "keychain",
+	Short: "Keep account credentials in the system keychain",
+	Long: `Keep the auth.json of saved accounts, which holds their OAuth tokens, in
+the macOS Keychain, the Secret Service (libsecret) on Linux, or the Windows
+Credential Manager instead of in the data directory. It is written into
+~/.codex only when an account is activated.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+var keychainEnableCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Move account credentials into the keychain",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := keychain.New().Check(); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		return setAuthStore("keychain", "move credentials into the keychain", "Moved the credentials of %d accounts into the keychain")
+	},
+}
+
+var keychainDisableCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Move account credentials back into the data directory",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setAuthStore("", "move credentials out of the keychain", "Moved the credentials of %d accounts back into the data directory")
+	},
+}
+
+// setAuthStore records where credentials are kept and moves those of every
+// account there.
+func setAuthStore(store, verb, done string) error {
+	err := updateConfig(func(cfg *config.Config) error {
+		cfg.AuthStore = store
+		return nil
+	})
+	if err != nil {
+		out.Println(styles.RenderError(err.Error()))
+		return err
+	}
+	repo.SetKeyring(keychain.New(), store == "keychain")
+
+	return eachAccount(repo.Stow, verb, done)
+}
+
+func init() {
+	keychainCmd.AddCommand(keychainEnableCmd)
+	keychainCmd.AddCommand(keychainDisableCmd)
+	rootCmd.AddCommand(keychainCmd)
+}
@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/delhombre/cxa/internal/doctor"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint [name...]",
+	Short: "Check account configs for mistakes",
+	Long:  "Validate each account's config.toml and MCP server definitions: syntax, unknown settings, server commands that cannot be found, and model settings that contradict each other. Without names, lint every saved account.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		linted, err := doctor.New(repo).Lint(args...)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		type accountResult struct {
+			Account  string          `json:"account"`
+			Findings []findingResult `json:"findings"`
+		}
+		results := []accountResult{}
+		problems := 0
+
+		out.Println()
+		out.Println(styles.RenderTitle("Lint"))
+		out.Println()
+		for _, a := range linted {
+			out.Println(styles.BoldStyle.Render(a.Account))
+			findings, n, _ := printFindings(a.Findings)
+			results = append(results, accountResult{Account: a.Account, Findings: findings})
+			problems += n
+		}
+		if len(linted) == 0 {
+			out.Println(styles.MutedStyle.Render("  No saved accounts."))
+			out.Println()
+		}
+
+		if err := out.Result(results, nil); err != nil {
+			return err
+		}
+		if problems > 0 {
+			return fmt.Errorf("lint found %d problem(s)", problems)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/delhombre/cxa/internal/account"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var lockCmd = &cobra.Command{
+	Use:   "lock <name>",
+	Short: "Protect an account from being overwritten or deleted",
+	Long:  "Lock an account so that saving over it or deleting it needs --force. Switching away from a locked account leaves its stored copy as it was, discarding changes made while it was active.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setProtected(args[0], true)
+	},
+}
+
+var unlockCmd = &cobra.Command{
+	Use:   "unlock <name>",
+	Short: "Remove the protection from a locked account",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setProtected(args[0], false)
+	},
+}
+
+func setProtected(name string, protected bool) error {
+	if _, err := repo.UpdateMetadata(name, func(acc *account.Account) {
+		acc.Protected = protected
+	}); err != nil {
+		out.Println(styles.RenderError(err.Error()))
+		return err
+	}
+
+	return out.Result(map[string]any{"account": name, "protected": protected}, func() {
+		if protected {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Locked %s", name)))
+		} else {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Unlocked %s", name)))
+		}
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(lockCmd)
+	rootCmd.AddCommand(unlockCmd)
+}
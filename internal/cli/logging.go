@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"io"
+	"os"
+
+	"github.com/delhombre/cxa/internal/logging"
+	"github.com/spf13/cobra"
+)
+
+var (
+	verboseFlag bool
+
+	logger    = logging.Discard
+	logCloser io.Closer
+)
+
+// setupLogging opens the debug log, echoing it on stderr with --verbose,
+// and hands it to the repository.
+func setupLogging(cmd *cobra.Command, args []string) {
+	var verbose io.Writer
+	if verboseFlag {
+		verbose = os.Stderr
+	}
+	logger, logCloser = logging.Open(paths.LogFile(), verbose)
+	repo.SetLogger(logger)
+	logger.Info("run", "command", cmd.CommandPath(), "args", args, "version", version)
+}
+
+// finishLogging records how the run ended and closes the debug log.
+func finishLogging(err error) {
+	if err != nil {
+		logger.Error("command failed", "err", err, "exit", ExitCode(err))
+	} else {
+		logger.Debug("command succeeded")
+	}
+	if logCloser != nil {
+		logCloser.Close()
+	}
+}
+
+func init() {
+	rootCmd.PersistentFlags().BoolVarP(&verboseFlag, "verbose", "v", false, "print debug logs on stderr")
+}
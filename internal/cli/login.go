@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/delhombre/cxa/internal/account"
+	"github.com/delhombre/cxa/internal/sharing"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+// loginBackupSuffix names where an untracked ~/.codex is kept while a new
+// account logs in.
+const loginBackupSuffix = ".pre-login"
+
+var loginForce bool
+
+var loginCmd = &cobra.Command{
+	Use:   "login <name>",
+	Short: "Log in to a new account with codex and save it",
+	Long:  "Save the current account, start from an empty ~/.codex, run `codex login`, and save the fresh credentials under the given name.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		if err := account.ValidateName(name); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		if _, err := repo.AccountDir(name); err == nil && !loginForce {
+			err := fmt.Errorf("account '%s' already exists (use --force to replace it)", name)
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		codexBin, err := exec.LookPath("codex")
+		if err != nil {
+			err := errors.New("codex not found in PATH - install the Codex CLI first")
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		current, _ := repo.Current()
+
+		// Keep the current account safe before clearing ~/.codex
+		backup := ""
+		if paths.CodexExists() {
+			if current != "" {
+				out.Printf("%s Saving %s...\n", styles.Caret, styles.PrimaryStyle.Render(current))
+				if _, err := repo.Save(current); err != nil {
+					out.Println(styles.RenderError(err.Error()))
+					return err
+				}
+				if err := os.RemoveAll(paths.Home); err != nil {
+					return err
+				}
+			} else {
+				backup = paths.Home + loginBackupSuffix
+				if err := os.RemoveAll(backup); err != nil {
+					return err
+				}
+				if err := os.Rename(paths.Home, backup); err != nil {
+					return err
+				}
+			}
+		}
+
+		restore := func() {
+			_ = os.RemoveAll(paths.Home)
+			switch {
+			case backup != "":
+				_ = os.Rename(backup, paths.Home)
+			case current != "":
+				_ = repo.Activate(current)
+			}
+		}
+
+		if err := os.MkdirAll(paths.Home, 0755); err != nil {
+			restore()
+			return err
+		}
+
+		out.Printf("%s Running codex login for %s...\n", styles.Caret, styles.PrimaryStyle.Render(name))
+		login := exec.Command(codexBin, "login")
+		login.Stdin = os.Stdin
+		login.Stdout = os.Stderr
+		login.Stderr = os.Stderr
+		if err := login.Run(); err != nil {
+			restore()
+			err := fmt.Errorf("codex login failed: %w", err)
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		if _, err := os.Stat(paths.AuthFile()); err != nil {
+			restore()
+			err := errors.New("codex login finished without writing auth.json")
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		// Bring back shared sessions and history for the new account
+		manager := sharing.NewManagerWithPaths(paths)
+		if err := manager.LoadConfig(); err == nil && manager.IsEnabled() {
+			if out.Interactive() {
+				manager.SetResolver(resolveShareConflict)
+			}
+			if err := manager.SetupSymlinks(name); err != nil {
+				warningStore().Record("sharing", fmt.Sprintf("failed to set up sharing for '%s': %v", name, err))
+			}
+		}
+
+		acc, err := repo.Save(name)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(acc, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Logged in and saved account: %s", name)))
+			if backup != "" {
+				out.Println(styles.MutedStyle.Render(fmt.Sprintf("Your previous unsaved ~/.codex was moved to %s", backup)))
+			}
+		})
+	},
+}
+
+func init() {
+	loginCmd.Flags().BoolVarP(&loginForce, "force", "f", false, "replace an existing account with the same name")
+	rootCmd.AddCommand(loginCmd)
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/delhombre/cxa/pkg/codex"
+	"github.com/spf13/cobra"
+)
+
+var logoutCmd = &cobra.Command{
+	Use:   "logout [name]",
+	Short: "Remove credentials but keep sessions and history",
+	Long:  "Delete auth.json and license.secret from a saved account, or from the live ~/.codex when no name is given, without touching sessions or history.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var (
+			target  = "~/.codex"
+			removed []string
+			err     error
+		)
+		if len(args) == 1 {
+			target = args[0]
+			removed, err = repo.RemoveItems(target, codex.AccountSpecificItems...)
+		} else {
+			removed, err = repo.RemoveActiveItems(codex.AccountSpecificItems...)
+		}
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		if removed == nil {
+			removed = []string{}
+		}
+
+		return out.Result(map[string]any{"target": target, "removed": removed}, func() {
+			if len(removed) == 0 {
+				out.Println(styles.MutedStyle.Render(fmt.Sprintf("%s has no credentials to remove.", target)))
+				return
+			}
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Logged out %s", target)))
+			for _, item := range removed {
+				out.Printf("  %s removed %s\n", styles.CrossMark, item)
+			}
+			out.Println(styles.MutedStyle.Render("Sessions and history were kept."))
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(logoutCmd)
+}
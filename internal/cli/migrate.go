@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var (
+	migrateFrom   string
+	migrateDryRun bool
+	migrateRemove bool
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Convert accounts from the legacy zip storage",
+	Long:  "Find accounts kept as <name>.zip archives by earlier zip-based versions, unpack each into directory storage, and reconstruct its metadata. Archives whose account already exists are skipped. The archives are kept unless --remove is given.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		archives, err := repo.FindLegacyArchives(migrateFrom)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		type result struct {
+			Archive  string `json:"archive"`
+			Name     string `json:"name"`
+			Email    string `json:"email,omitempty"`
+			Migrated bool   `json:"migrated"`
+			Skipped  string `json:"skipped,omitempty"`
+			Error    string `json:"error,omitempty"`
+		}
+		results := make([]result, 0, len(archives))
+
+		if len(archives) == 0 {
+			return out.Result(results, func() {
+				out.Println(styles.MutedStyle.Render("No legacy zip archives found."))
+			})
+		}
+
+		migrated, failed := 0, 0
+		for _, a := range archives {
+			r := result{Archive: a.Path, Name: a.Name}
+			switch {
+			case a.Exists:
+				r.Skipped = "account already exists"
+				out.Printf("  %s %s %s\n", styles.Circle, a.Path, styles.MutedStyle.Render("("+r.Skipped+")"))
+			case migrateDryRun:
+				out.Printf("  %s %s %s %s\n", styles.Caret, a.Path, styles.Arrow, styles.PrimaryStyle.Render(a.Name))
+			default:
+				acc, err := repo.MigrateArchive(a)
+				if err != nil {
+					failed++
+					r.Error = err.Error()
+					out.Printf("  %s %s %s\n", styles.CrossMark, a.Path, styles.ErrorStyle.Render(err.Error()))
+					break
+				}
+				migrated++
+				r.Migrated = true
+				r.Email = acc.Email
+				out.Printf("  %s %s %s %s\n", styles.CheckMark, a.Path, styles.Arrow, styles.PrimaryStyle.Render(a.Name))
+				if migrateRemove {
+					if err := os.Remove(a.Path); err != nil {
+						out.Println(styles.RenderWarning(fmt.Sprintf("Could not remove %s: %v", a.Path, err)))
+					}
+				}
+			}
+			results = append(results, r)
+		}
+
+		out.Println()
+		if !migrateDryRun {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Migrated %d account(s)", migrated)))
+		}
+		if err := out.Result(results, nil); err != nil {
+			return err
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d archive(s) could not be migrated", failed)
+		}
+		return nil
+	},
+}
+
+func init() {
+	migrateCmd.Flags().StringVar(&migrateFrom, "from", "", "directory holding the archives (default: the data directory)")
+	migrateCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "show what would be migrated without changing anything")
+	migrateCmd.Flags().BoolVar(&migrateRemove, "remove", false, "delete each archive after migrating it")
+	rootCmd.AddCommand(migrateCmd)
+}
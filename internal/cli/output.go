@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/delhombre/cxa/internal/account"
+)
+
+// output is the shared writer every command prints through. Human-oriented
+// text goes through Println and Printf and is suppressed in JSON and quiet
+// modes; command results go through Result, which renders either styled
+// text or JSON. In quiet mode, renderers print only what a script needs
+// through Essential.
+type output struct {
+	w     io.Writer
+	json  bool
+	quiet bool
+}
+
+var out = &output{w: os.Stdout}
+
+// Println prints human-oriented text.
+func (o *output) Println(a ...any) {
+	progress.clear()
+	if !o.json && !o.quiet {
+		fmt.Fprintln(o.w, a...)
+	}
+}
+
+// Printf prints formatted human-oriented text.
+func (o *output) Printf(format string, a ...any) {
+	progress.clear()
+	if !o.json && !o.quiet {
+		fmt.Fprintf(o.w, format, a...)
+	}
+}
+
+// Quiet reports whether only essential output is wanted.
+func (o *output) Quiet() bool {
+	return o.quiet && !o.json
+}
+
+// Essential prints a plain line of result that scripts rely on, such as a
+// name or a value. It is the only text printed in quiet mode.
+func (o *output) Essential(a ...any) {
+	progress.clear()
+	if !o.json {
+		fmt.Fprintln(o.w, a...)
+	}
+}
+
+// Interactive reports whether the user can answer prompts: output is for
+// humans and stdin is a terminal.
+func (o *output) Interactive() bool {
+	return !o.json && term.IsTerminal(os.Stdin.Fd())
+}
+
+// Result emits v as JSON in JSON mode, and otherwise calls render, which
+// may be nil when the human output was already printed.
+func (o *output) Result(v any, render func()) error {
+	if o.json {
+		enc := json.NewEncoder(o.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	if render != nil {
+		render()
+	}
+	return nil
+}
+
+// accountJSON is the JSON form of an account in listings.
+type accountJSON struct {
+	*account.Account
+	Current bool `json:"current"`
+}
+
+func accountsJSON(accounts []*account.Account, current string) []accountJSON {
+	list := make([]accountJSON, 0, len(accounts))
+	for _, acc := range accounts {
+		list = append(list, accountJSON{Account: acc, Current: acc.Name == current})
+	}
+	return list
+}
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&out.json, "json", false, "print machine-readable JSON output")
+	rootCmd.PersistentFlags().BoolVarP(&out.quiet, "quiet", "q", false, "print only essential results, without colors or decoration")
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/huh"
+	"github.com/delhombre/cxa/internal/agent"
+	"github.com/delhombre/cxa/internal/storage"
+)
+
+// passphraseEnv supplies passphrases to scripts that cannot answer prompts.
+const passphraseEnv = "CXA_PASSPHRASE"
+
+// readPassphrase returns the passphrase from the environment or asks for
+// it, twice when confirm is set so a typo cannot lock the user out.
+func readPassphrase(confirm bool) ([]byte, error) {
+	if p := os.Getenv(passphraseEnv); p != "" {
+		return []byte(p), nil
+	}
+	if !out.Interactive() {
+		return nil, errors.New("a passphrase is required - set " + passphraseEnv + " when running non-interactively")
+	}
+
+	var passphrase, again string
+	fields := []huh.Field{
+		huh.NewInput().
+			Title("Passphrase").
+			EchoMode(huh.EchoModePassword).
+			Validate(func(s string) error {
+				if s == "" {
+					return errors.New("passphrase cannot be empty")
+				}
+				return nil
+			}).
+			Value(&passphrase),
+	}
+	if confirm {
+		fields = append(fields, huh.NewInput().
+			Title("Repeat passphrase").
+			EchoMode(huh.EchoModePassword).
+			Validate(func(s string) error {
+				if s != passphrase {
+					return errors.New("passphrases do not match")
+				}
+				return nil
+			}).
+			Value(&again))
+	}
+
+	if err := huh.NewForm(huh.NewGroup(fields...)).Run(); err != nil {
+		return nil, err
+	}
+	return []byte(passphrase), nil
+}
+
+// storageKey returns where the passphrase of encrypted accounts comes from:
+// the key file from the config, the environment, a running agent, or else
+// a prompt.
+func storageKey(keyFile string) storage.KeySource {
+	return func() ([]byte, error) {
+		if keyFile != "" {
+			data, err := os.ReadFile(keyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read key_file: %w", err)
+			}
+			return bytes.TrimRight(data, "\r\n"), nil
+		}
+		if os.Getenv(passphraseEnv) == "" {
+			if key, err := agent.Fetch(paths.AgentSocket()); err == nil {
+				return key, nil
+			}
+		}
+		return readPassphrase(!repo.HasKey())
+	}
+}
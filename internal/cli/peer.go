@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/delhombre/cxa/internal/policy"
+	"github.com/delhombre/cxa/internal/remote"
+	"github.com/delhombre/cxa/internal/transfer"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var (
+	peerCommand string
+	peerForce   bool
+	peerAll     bool
+)
+
+var pushCmd = &cobra.Command{
+	Use:   "push <host> [name...]",
+	Short: "Copy accounts to another machine running cxa, over SSH",
+	Long: "Copy saved accounts straight to the cxa on another machine, reached with ssh, so two machines can share accounts without a cloud service or a sync remote. " +
+		"An account the other machine already has asks whether to push it under another name, overwrite it, or skip it; --force overwrites.\n\n" +
+		"<host> is anything ssh connects to, such as laptop or me@laptop.local. Use --cxa when cxa is not on the PATH there.",
+	Example: "  cxa push laptop work\n  cxa push me@desktop --all",
+	Args:    cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		peer := &remote.Peer{Host: args[0], Command: peerCommand}
+		names, err := peerNames(args[1:], func() ([]string, error) {
+			accounts, err := repo.List()
+			if err != nil {
+				return nil, err
+			}
+			names := make([]string, 0, len(accounts))
+			for _, acc := range accounts {
+				names = append(names, acc.Name)
+			}
+			return names, nil
+		})
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		theirs, err := peer.Accounts()
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		pushed := map[string]string{}
+		for _, name := range names {
+			target, err := resolveClash(name, peerForce, func(name string) bool {
+				return slices.Contains(theirs, name)
+			})
+			if err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+			if target == "" {
+				out.Println(styles.MutedStyle.Render(fmt.Sprintf("Skipped %s.", name)))
+				continue
+			}
+
+			out.Printf("%s Pushing %s to %s...\n", styles.Caret, styles.PrimaryStyle.Render(name), args[0])
+			if err := pushAccount(peer, name, target); err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+			pushed[name] = target
+			theirs = append(theirs, target)
+		}
+
+		return out.Result(map[string]any{"host": args[0], "pushed": pushed}, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Pushed %d accounts to %s", len(pushed), args[0])))
+		})
+	},
+}
+
+var pullCmd = &cobra.Command{
+	Use:   "pull <host> [name...]",
+	Short: "Copy accounts from another machine running cxa, over SSH",
+	Long: "Copy saved accounts straight from the cxa on another machine, reached with ssh. " +
+		"An account already saved here asks whether to pull it under another name, overwrite it, or skip it; --force overwrites.\n\n" +
+		"<host> is anything ssh connects to, such as laptop or me@laptop.local. Use --cxa when cxa is not on the PATH there.",
+	Example: "  cxa pull laptop work\n  cxa pull me@desktop --all",
+	Args:    cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		peer := &remote.Peer{Host: args[0], Command: peerCommand}
+		theirs, err := peer.Accounts()
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		names, err := peerNames(args[1:], func() ([]string, error) { return theirs, nil })
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		for _, name := range names {
+			if !slices.Contains(theirs, name) {
+				err := fmt.Errorf("account '%s' not found on %s", name, args[0])
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+		}
+
+		pulled := map[string]string{}
+		for _, name := range names {
+			target, err := resolveImportName(name, peerForce)
+			if err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+			if target == "" {
+				out.Println(styles.MutedStyle.Render(fmt.Sprintf("Skipped %s.", name)))
+				continue
+			}
+
+			out.Printf("%s Pulling %s from %s...\n", styles.Caret, styles.PrimaryStyle.Render(name), args[0])
+			if err := pullAccount(peer, name, target); err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+			pulled[name] = target
+		}
+
+		return out.Result(map[string]any{"host": args[0], "pulled": pulled}, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Pulled %d accounts from %s", len(pulled), args[0])))
+		})
+	},
+}
+
+// peerNames returns the accounts named on the command line, or with --all
+// every account all lists.
+func peerNames(names []string, all func() ([]string, error)) ([]string, error) {
+	switch {
+	case peerAll && len(names) > 0:
+		return nil, &usageError{err: errors.New("--all takes no account names")}
+	case peerAll:
+		return all()
+	case len(names) == 0:
+		return nil, &usageError{err: errors.New("name the accounts to copy, or pass --all")}
+	}
+	return names, nil
+}
+
+// pushAccount streams the export archive of the named account to the peer,
+// which saves it as target.
+func pushAccount(peer *remote.Peer, name, target string) error {
+	acc, err := repo.Get(name)
+	if err != nil {
+		return err
+	}
+	if err := repo.Expand(name); err != nil {
+		return err
+	}
+	defer stowAfter(name)
+	dir, err := repo.AccountDir(name)
+	if err != nil {
+		return err
+	}
+
+	sysPolicy, _ := policy.System()
+	opts := transfer.ExportOptions{ExcludeSecrets: sysPolicy.DisallowSecretExport}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(transfer.Export(pw, dir, acc, opts))
+	}()
+	err = peer.Import(target, pr)
+	pr.CloseWithError(err)
+	return err
+}
+
+// pullAccount streams the export archive of the named account from the
+// peer and saves it here as target.
+func pullAccount(peer *remote.Peer, name, target string) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(peer.Export(name, pw))
+	}()
+	var err error
+	if peerForce {
+		_, err = repo.ImportForce(target, pr)
+	} else {
+		_, err = repo.Import(target, pr)
+	}
+	pr.CloseWithError(err)
+	return err
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{pushCmd, pullCmd} {
+		cmd.Flags().StringVar(&peerCommand, "cxa", "cxa", "how to run cxa on the other machine")
+		cmd.Flags().BoolVarP(&peerForce, "force", "f", false, "overwrite accounts of the same name without asking")
+		cmd.Flags().BoolVarP(&peerAll, "all", "a", false, "copy every account")
+		rootCmd.AddCommand(cmd)
+	}
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"errors"
+
+	"github.com/charmbracelet/huh"
+	"github.com/delhombre/cxa/internal/account"
+	"github.com/delhombre/cxa/internal/ui/styles"
+)
+
+// pickerHeight caps how many accounts the picker shows at once.
+const pickerHeight = 10
+
+// pickAccount lets the user choose a saved account from a list they can
+// filter by typing, most recently used first. Archived accounts are left
+// out.
+func pickAccount(title string) (string, error) {
+	accounts, err := repo.List()
+	if err != nil {
+		return "", err
+	}
+	if len(accounts) == 0 {
+		return "", errors.New("no accounts saved yet - save one with 'cxa save <name>'")
+	}
+
+	account.SortRecent(accounts)
+
+	current, _ := repo.Current()
+	options := make([]huh.Option[string], 0, len(accounts))
+	for _, acc := range accounts {
+		if acc.Archived {
+			continue
+		}
+		label := acc.Name
+		if acc.Name == current {
+			label += " " + styles.MutedStyle.Render("(current)")
+		} else if acc.Email != "" {
+			label += " " + styles.MutedStyle.Render(acc.Email)
+		}
+		if len(acc.Tags) > 0 {
+			label += " " + styles.PrimaryStyle.Render(formatTags(acc.Tags))
+		}
+		options = append(options, huh.NewOption(label, acc.Name))
+	}
+	if len(options) == 0 {
+		return "", errors.New("every account is archived - see them with 'cxa list --all'")
+	}
+
+	var name string
+	err = huh.NewSelect[string]().
+		Title(title).
+		Options(options...).
+		Filtering(true).
+		Height(min(len(options), pickerHeight) + 2).
+		Value(&name).
+		Run()
+	return name, err
+}
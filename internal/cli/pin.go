@@ -0,0 +1,171 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/delhombre/cxa/internal/decision"
+	"github.com/delhombre/cxa/internal/pin"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var pinCmd = &cobra.Command{
+	Use:   "pin <name>",
+	Short: "Pin the current directory to an account",
+	Long: "Write a .cxa file naming the account to use in this directory and everything below it. " +
+		"cxa switch --auto activates the account of the nearest .cxa file; install the shell hook (cxa hook --help) to do that on every cd.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		if _, err := repo.Get(name); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		cwd, err := os.Getwd()
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		p, err := pin.Write(cwd, name)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(p, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Pinned %s to %s", cwd, name)))
+			out.Println(styles.MutedStyle.Render("  Commit " + pin.FileName + " to share it, or add it to .gitignore to keep it to yourself."))
+		})
+	},
+}
+
+var unpinCmd = &cobra.Command{
+	Use:   "unpin",
+	Short: "Remove the .cxa file from the current directory",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := os.Remove(pin.FileName); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				err = errors.New("this directory has no " + pin.FileName + " file")
+			}
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]bool{"unpinned": true}, func() {
+			out.Println(styles.RenderSuccess("Removed " + pin.FileName))
+		})
+	},
+}
+
+// autoSwitch activates the account pinned by the nearest .cxa file. It
+// prints nothing when there is no pin or the pinned account is already
+// active, so the shell hook can run it on every cd.
+func autoSwitch() error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		out.Println(styles.RenderError(err.Error()))
+		return err
+	}
+	p, err := pin.Find(cwd)
+	if err != nil {
+		out.Println(styles.RenderError(err.Error()))
+		return err
+	}
+	current, _ := repo.Current()
+	if p == nil || p.Account == current {
+		return out.Result(map[string]any{"pin": p, "current": current, "switched": false}, nil)
+	}
+
+	ok, err := acknowledgeReminder(p.Account, switchAck)
+	if err != nil {
+		out.Println(styles.RenderError(err.Error()))
+		return err
+	}
+	if !ok {
+		return out.Result(map[string]bool{"cancelled": true}, func() {
+			out.Println(styles.MutedStyle.Render("Cancelled."))
+		})
+	}
+
+	out.Printf("%s Switching to %s %s...\n",
+		styles.Caret,
+		styles.PrimaryStyle.Render(p.Account),
+		styles.MutedStyle.Render("(pinned by "+p.Path+")"),
+	)
+	warnCodexMismatch(p.Account)
+	if err := repo.Activate(p.Account); err != nil {
+		out.Println(styles.RenderError(err.Error()))
+		return err
+	}
+
+	err = decisionLog().Write(&decision.Decision{
+		Trigger:  "pin",
+		Rule:     p.Path,
+		From:     current,
+		To:       p.Account,
+		Override: fmt.Sprintf("run cxa unpin in %s, or edit the file", filepath.Dir(p.Path)),
+	})
+	if err != nil {
+		warningStore().Record("pin", fmt.Sprintf("failed to record the switch to '%s': %v", p.Account, err))
+	}
+
+	return out.Result(map[string]any{"pin": p, "current": p.Account, "switched": true}, func() {
+		out.Println(styles.RenderSuccess(fmt.Sprintf("Switched to %s", p.Account)))
+	})
+}
+
+// hookScripts run cxa switch --auto whenever the working directory changes.
+var hookScripts = map[string]string{
+	"bash": `_cxa_auto() {
+  if [ "$PWD" != "${_CXA_LAST_PWD:-}" ]; then
+    _CXA_LAST_PWD="$PWD"
+    cxa switch --auto
+  fi
+}
+case ";${PROMPT_COMMAND:-};" in
+  *";_cxa_auto;"*) ;;
+  *) PROMPT_COMMAND="_cxa_auto${PROMPT_COMMAND:+;$PROMPT_COMMAND}" ;;
+esac
+`,
+	"zsh": `_cxa_auto() { cxa switch --auto }
+autoload -Uz add-zsh-hook
+add-zsh-hook chpwd _cxa_auto
+_cxa_auto
+`,
+	"fish": `function _cxa_auto --on-variable PWD
+    cxa switch --auto
+end
+_cxa_auto
+`,
+}
+
+var hookCmd = &cobra.Command{
+	Use:       "hook <bash|zsh|fish>",
+	Short:     "Print a shell hook that follows .cxa pins",
+	Long:      "Print a snippet that runs cxa switch --auto whenever you change directory, so the account pinned with cxa pin is activated as you move between projects.",
+	Example:   "  eval \"$(cxa hook bash)\"   # in ~/.bashrc\n  eval \"$(cxa hook zsh)\"    # in ~/.zshrc\n  cxa hook fish | source    # in ~/.config/fish/config.fish",
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"bash", "zsh", "fish"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		script, ok := hookScripts[args[0]]
+		if !ok {
+			err := fmt.Errorf("unsupported shell '%s': use bash, zsh, or fish", args[0])
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		fmt.Fprint(cmd.OutOrStdout(), script)
+		return nil
+	},
+}
+
+func init() {
+	pinCmd.ValidArgsFunction = completeAccountNames
+	rootCmd.AddCommand(pinCmd)
+	rootCmd.AddCommand(unpinCmd)
+	rootCmd.AddCommand(hookCmd)
+}
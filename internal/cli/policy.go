@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/delhombre/cxa/internal/policy"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+// checkPolicy refuses to run commands the system policy does not allow,
+// and every command but those always allowed when the policy file cannot
+// be loaded.
+func checkPolicy(cmd *cobra.Command) error {
+	top := cmd
+	for top.HasParent() && top.Parent().HasParent() {
+		top = top.Parent()
+	}
+	p, err := policy.System()
+	if !top.HasParent() || p.Allows(top.Name()) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("'cxa %s' is disabled until the system policy is fixed: %w", top.Name(), err)
+	}
+	return fmt.Errorf("'cxa %s' is disabled by the system policy (see cxa policy show)", top.Name())
+}
+
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Inspect the administrator policy",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+var policyShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Explain the constraints set by the system policy",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := policy.Load(policy.DefaultPath)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(p, func() {
+			if !p.Active() {
+				out.Println(styles.MutedStyle.Render("No policy in effect."))
+				out.Println(styles.MutedStyle.Render("Administrators can set one in " + policy.DefaultPath))
+				return
+			}
+
+			out.Println(styles.RenderTitle("Policy"))
+			out.Println(styles.MutedStyle.Render(p.Path))
+			out.Println()
+			if p.DataDir != "" {
+				out.Printf("  %s Accounts are stored in %s\n", styles.Bullet, p.DataDir)
+			}
+			if p.RequireEncryption {
+				out.Printf("  %s Account data must be encrypted at rest; saving is refused until cxa encrypt is on\n", styles.Bullet)
+			}
+			if p.DisallowSecretExport {
+				out.Printf("  %s Exports leave out credentials (auth.json, license.secret)\n", styles.Bullet)
+			}
+			if len(p.AllowedCommands) > 0 {
+				out.Printf("  %s Only these commands may run: %s\n", styles.Bullet, strings.Join(p.AllowedCommands, ", "))
+			}
+			out.Println()
+		})
+	},
+}
+
+func init() {
+	policyCmd.AddCommand(policyShowCmd)
+	rootCmd.AddCommand(policyCmd)
+}
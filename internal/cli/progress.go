@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/delhombre/cxa/internal/sharing"
+	"github.com/delhombre/cxa/internal/storage"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/dustin/go-humanize"
+)
+
+// progressWidth is the width of the progress bar in cells.
+const progressWidth = 24
+
+// progressLine draws the progress of long saves, switches, and moves to
+// the shared location on one line of stderr, cleared by the next output.
+// It draws nothing in JSON or quiet mode, or when stderr is not a
+// terminal.
+type progressLine struct {
+	mu    sync.Mutex
+	drawn bool
+}
+
+var progress = &progressLine{}
+
+// show draws p over the previous line.
+func (l *progressLine) show(p storage.Progress) {
+	if out.json || out.quiet || !term.IsTerminal(os.Stderr.Fd()) {
+		return
+	}
+
+	verb := "Saving"
+	if p.Op == "switch" {
+		verb = "Switching to"
+	}
+	detail := fmt.Sprintf("%d/%d files, %s/%s", p.Files, p.TotalFiles,
+		humanize.Bytes(uint64(p.Bytes)), humanize.Bytes(uint64(p.TotalBytes)))
+	l.draw(verb+" "+p.Account, p.Percent(), detail)
+}
+
+// showShare draws p, the progress of moving an item to the shared
+// location, over the previous line.
+func (l *progressLine) showShare(p sharing.Progress) {
+	if out.json || out.quiet || !term.IsTerminal(os.Stderr.Fd()) {
+		return
+	}
+	detail := fmt.Sprintf("%s/%s", humanize.Bytes(uint64(p.Bytes)), humanize.Bytes(uint64(p.TotalBytes)))
+	l.draw("Moving "+p.Item, p.Percent(), detail)
+}
+
+// draw draws a bar percent full, between label and detail.
+func (l *progressLine) draw(label string, percent int, detail string) {
+	filled := percent * progressWidth / 100
+	bar := styles.PrimaryStyle.Render(strings.Repeat("█", filled)) +
+		styles.MutedStyle.Render(strings.Repeat("░", progressWidth-filled))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(os.Stderr, "\r\033[K%s %s %s %3d%% %s", styles.Caret, label, bar, percent, styles.MutedStyle.Render(detail))
+	l.drawn = true
+}
+
+// clear removes the line, if one is drawn.
+func (l *progressLine) clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.drawn {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+		l.drawn = false
+	}
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/huh"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pruneDays int
+	pruneYes  bool
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove stale or broken accounts",
+	Long:  "Find empty account directories, accounts with missing or corrupt metadata, and accounts not used in a while, then choose which to delete.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		staleAfter := time.Duration(pruneDays) * 24 * time.Hour
+		candidates, err := repo.FindPrunable(staleAfter)
+		if err != nil {
+			return err
+		}
+
+		deleted := []string{}
+		if len(candidates) == 0 {
+			return out.Result(map[string]any{"deleted": deleted}, func() {
+				out.Println(styles.MutedStyle.Render("Nothing to prune."))
+			})
+		}
+
+		selected := make([]string, 0, len(candidates))
+		options := make([]huh.Option[string], 0, len(candidates))
+		for _, c := range candidates {
+			selected = append(selected, c.Name)
+			options = append(options, huh.NewOption(fmt.Sprintf("%s (%s)", c.Name, c.Reason), c.Name))
+		}
+
+		if !pruneYes {
+			form := huh.NewForm(
+				huh.NewGroup(
+					huh.NewMultiSelect[string]().
+						Title("Select accounts to delete").
+						Options(options...).
+						Value(&selected),
+				),
+			)
+			if err := form.Run(); err != nil {
+				return err
+			}
+		}
+
+		if len(selected) == 0 {
+			return out.Result(map[string]any{"deleted": deleted}, func() {
+				out.Println(styles.MutedStyle.Render("Nothing deleted."))
+			})
+		}
+
+		for _, name := range selected {
+			if err := repo.Delete(name); err != nil {
+				out.Printf("  %s %s %s\n", styles.CrossMark, name, styles.ErrorStyle.Render(err.Error()))
+				continue
+			}
+			deleted = append(deleted, name)
+			out.Printf("  %s Deleted %s\n", styles.CheckMark, name)
+		}
+		return out.Result(map[string]any{"deleted": deleted}, nil)
+	},
+}
+
+func init() {
+	pruneCmd.Flags().IntVar(&pruneDays, "days", 90, "consider accounts unused for this many days stale (0 to disable)")
+	pruneCmd.Flags().BoolVarP(&pruneYes, "yes", "y", false, "delete every candidate without asking")
+	rootCmd.AddCommand(pruneCmd)
+}
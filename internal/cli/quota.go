@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/delhombre/cxa/internal/config"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/dustin/go-humanize"
+)
+
+// quotaHint points at the commands that free space in the data directory.
+const quotaHint = "free space with cxa prune, cxa compress, cxa trash empty, or cxa gc"
+
+// diskQuota returns the configured quota in bytes, or 0 when none is set
+// or it cannot be read.
+func diskQuota() int64 {
+	cfg, err := loadConfig()
+	if err != nil {
+		return 0
+	}
+	quota, _ := config.ParseQuota(cfg.Quota)
+	return quota
+}
+
+// warnQuota warns when the data directory has grown past the quota
+// setting. It runs after saves and switches, which are what grow it.
+func warnQuota() {
+	quota := diskQuota()
+	if quota == 0 {
+		return
+	}
+	usage, err := repo.DiskUsage()
+	if err != nil || usage <= quota {
+		return
+	}
+	out.Println(styles.RenderWarning(fmt.Sprintf("cxa data uses %s, over the %s quota; %s",
+		humanize.Bytes(uint64(usage)), humanize.Bytes(uint64(quota)), quotaHint)))
+}
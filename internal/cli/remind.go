@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+	"github.com/delhombre/cxa/internal/account"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var remindClear bool
+
+var remindCmd = &cobra.Command{
+	Use:   "remind <name> [text]",
+	Short: "Attach a reminder shown whenever an account is activated",
+	Long:  "Attach a note to an account, such as \"this client forbids uploading code snippets\". It is shown every time you switch to the account and must be acknowledged. Without text, show the current reminder.",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		text := strings.Join(args[1:], " ")
+
+		if text == "" && !remindClear {
+			acc, err := repo.Get(name)
+			if err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+			return out.Result(map[string]string{"account": name, "reminder": acc.Reminder}, func() {
+				if acc.Reminder == "" {
+					out.Println(styles.MutedStyle.Render(fmt.Sprintf("%s has no reminder.", name)))
+					return
+				}
+				printReminder(acc)
+			})
+		}
+
+		acc, err := repo.UpdateMetadata(name, func(acc *account.Account) {
+			acc.Reminder = text
+		})
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]string{"account": name, "reminder": acc.Reminder}, func() {
+			if acc.Reminder == "" {
+				out.Println(styles.RenderSuccess(fmt.Sprintf("Cleared reminder for %s", name)))
+				return
+			}
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Reminder set for %s", name)))
+		})
+	},
+}
+
+// printReminder shows an account's reminder prominently.
+func printReminder(acc *account.Account) {
+	out.Println(styles.RenderBox(
+		styles.WarningStyle.Render("Reminder for "+acc.Name) + "\n\n" + acc.Reminder,
+	))
+}
+
+// acknowledgeReminder shows the reminder of the account about to be
+// activated and requires the user to acknowledge it, either interactively
+// or up front with ack. It reports false if the user declined.
+func acknowledgeReminder(name string, ack bool) (bool, error) {
+	acc, err := repo.Get(name)
+	if err != nil || acc.Reminder == "" {
+		return true, nil
+	}
+
+	printReminder(acc)
+	if ack {
+		return true, nil
+	}
+	if !out.Interactive() {
+		return false, fmt.Errorf("account '%s' has a reminder that must be acknowledged: %q (pass --ack)", name, acc.Reminder)
+	}
+
+	confirmed := false
+	err = huh.NewConfirm().
+		Title("Switch anyway?").
+		Affirmative("Understood").
+		Negative("Cancel").
+		Value(&confirmed).
+		Run()
+	return confirmed, err
+}
+
+func init() {
+	remindCmd.Flags().BoolVar(&remindClear, "clear", false, "remove the reminder")
+	rootCmd.AddCommand(remindCmd)
+}
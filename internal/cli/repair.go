@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/delhombre/cxa/internal/storage"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var repairDryRun bool
+
+var repairCmd = &cobra.Command{
+	Use:   "repair",
+	Short: "Fix accounts with missing metadata and stale state",
+	Long: "Scan the accounts directory for account directories without metadata, such as ones copied in by hand, and write it for them, dated from the directory and with the email from auth.json. Metadata naming another account is corrected, and state.json is cleared of accounts that no longer exist.\n\n" +
+		"Entries that cannot be read are reported and left alone; remove them with cxa prune.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		results, err := repo.Repair(repairDryRun)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		unreadable := 0
+		for _, result := range results {
+			if result.Kind == storage.RepairUnreadable {
+				unreadable++
+			}
+		}
+
+		err = out.Result(results, func() {
+			if len(results) == 0 {
+				out.Println(styles.MutedStyle.Render("Nothing to repair."))
+				return
+			}
+
+			out.Println()
+			out.Println(styles.RenderTitle("Repair"))
+			out.Println()
+			for _, result := range results {
+				label := result.Name
+				if label == "" {
+					label = "state.json"
+				}
+				mark := styles.CheckMark
+				switch {
+				case result.Kind == storage.RepairUnreadable:
+					mark = styles.CrossMark
+				case !result.Fixed:
+					mark = styles.WarningStyle.Render("!")
+				}
+				out.Printf("  %s %s %s\n", mark, label, styles.MutedStyle.Render("("+result.Detail+")"))
+			}
+			out.Println()
+			if repairDryRun {
+				out.Println(styles.MutedStyle.Render("Dry run; nothing was changed."))
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if unreadable > 0 {
+			return fmt.Errorf("%d entry(s) could not be repaired", unreadable)
+		}
+		return nil
+	},
+}
+
+func init() {
+	repairCmd.Flags().BoolVar(&repairDryRun, "dry-run", false, "report what would be repaired without changing anything")
+	rootCmd.AddCommand(repairCmd)
+}
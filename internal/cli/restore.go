@@ -0,0 +1,174 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/charmbracelet/huh"
+	"github.com/delhombre/cxa/internal/backup"
+	"github.com/delhombre/cxa/internal/crypt"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var (
+	restoreMerge   bool
+	restoreReplace bool
+	restoreDryRun  bool
+	restoreYes     bool
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <backup>",
+	Short: "Restore a backup created by cxa backup",
+	Long:  "Restore accounts, shared data, and state from a backup. --merge (the default) keeps existing accounts and adds missing ones; --replace wipes existing accounts and restores the backup as it was.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if restoreMerge && restoreReplace {
+			return errors.New("--merge and --replace cannot be combined")
+		}
+		mode := backup.Merge
+		if restoreReplace {
+			mode = backup.Replace
+		}
+
+		// Encrypted backups are decrypted twice (plan, then apply), so ask
+		// for the passphrase once up front
+		var passphrase []byte
+		if encrypted, err := isEncryptedFile(args[0]); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		} else if encrypted {
+			if passphrase, err = readPassphrase(false); err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+		}
+
+		var manifest *backup.Manifest
+		err := readArchive(args[0], passphrase, func(r io.Reader) (err error) {
+			manifest, err = backup.ReadManifest(r)
+			return err
+		})
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		accounts, err := repo.List()
+		if err != nil {
+			return err
+		}
+		existing := make([]string, 0, len(accounts))
+		for _, acc := range accounts {
+			existing = append(existing, acc.Name)
+		}
+
+		plan := backup.NewPlan(manifest, existing, mode)
+		printRestorePlan(plan)
+
+		if restoreDryRun {
+			return out.Result(plan, nil)
+		}
+
+		if mode == backup.Replace && shouldConfirm(restoreYes) {
+			if !out.Interactive() {
+				err := errors.New("--replace deletes existing data; pass --yes to confirm")
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+			confirmed := false
+			if err := huh.NewConfirm().
+				Title("Replace all existing accounts with the backup?").
+				Value(&confirmed).
+				Run(); err != nil {
+				return err
+			}
+			if !confirmed {
+				return out.Result(map[string]bool{"cancelled": true}, func() {
+					out.Println(styles.MutedStyle.Render("Cancelled."))
+				})
+			}
+		}
+
+		out.Printf("%s Restoring...\n", styles.Caret)
+		err = readArchive(args[0], passphrase, func(r io.Reader) error {
+			return backup.Apply(r, paths, plan)
+		})
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(plan, func() {
+			out.Println(styles.RenderSuccess("Backup restored"))
+			if current, _ := repo.Current(); current != "" {
+				out.Println(styles.MutedStyle.Render(fmt.Sprintf("Load the restored account into ~/.codex with: cxa switch %s", current)))
+			}
+		})
+	},
+}
+
+func printRestorePlan(plan *backup.Plan) {
+	out.Println()
+	out.Printf("  Backup from %s with %d account(s)\n", plan.Manifest.CreatedAt.Format("2006-01-02 15:04"), len(plan.Manifest.Accounts))
+	out.Println()
+	for _, name := range plan.Add {
+		out.Printf("  %s %s %s\n", styles.SuccessStyle.Render("+"), name, styles.MutedStyle.Render("(add)"))
+	}
+	for _, name := range plan.Replace {
+		out.Printf("  %s %s %s\n", styles.WarningStyle.Render("~"), name, styles.MutedStyle.Render("(replace)"))
+	}
+	for _, name := range plan.Remove {
+		out.Printf("  %s %s %s\n", styles.ErrorStyle.Render("-"), name, styles.MutedStyle.Render("(remove)"))
+	}
+	for _, name := range plan.Keep {
+		out.Printf("  %s %s %s\n", styles.Circle, name, styles.MutedStyle.Render("(keep)"))
+	}
+	out.Println()
+}
+
+func isEncryptedFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	return crypt.IsEncrypted(bufio.NewReader(f)), nil
+}
+
+// readArchive opens the archive at path, a backup or an exported account,
+// decrypting it with passphrase when one is given, and passes it to fn.
+func readArchive(path string, passphrase []byte, fn func(io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if passphrase == nil {
+		return fn(f)
+	}
+	r, err := crypt.NewReader(f, passphrase)
+	if err != nil {
+		return err
+	}
+	if err := fn(r); err != nil {
+		return err
+	}
+	// Read to the end, so the last chunk is authenticated and a truncated
+	// archive is caught even if fn stopped early
+	_, err = io.Copy(io.Discard, r)
+	return err
+}
+
+func init() {
+	restoreCmd.Flags().BoolVar(&restoreMerge, "merge", false, "keep existing accounts and add missing ones (default)")
+	restoreCmd.Flags().BoolVar(&restoreReplace, "replace", false, "wipe existing accounts and restore the backup as it was")
+	restoreCmd.Flags().BoolVar(&restoreDryRun, "dry-run", false, "show what would change without restoring")
+	restoreCmd.Flags().BoolVarP(&restoreYes, "yes", "y", false, "do not ask for confirmation")
+	rootCmd.AddCommand(restoreCmd)
+}
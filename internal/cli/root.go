@@ -2,24 +2,56 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/delhombre/cxa/internal/account"
+	"github.com/delhombre/cxa/internal/fscopy"
+	"github.com/delhombre/cxa/internal/keychain"
 	"github.com/delhombre/cxa/internal/storage"
 	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/delhombre/cxa/internal/ui/table"
 	"github.com/delhombre/cxa/internal/ui/tui"
+	"github.com/delhombre/cxa/pkg/codex"
+	"github.com/dustin/go-humanize"
+	"github.com/muesli/termenv"
 	"github.com/spf13/cobra"
 )
 
 var (
-	repo    = storage.NewDirectoryRepository()
+	// paths is shared by everything the CLI touches, so a moved data
+	// directory is seen everywhere at once.
+	paths   = storage.DefaultPaths()
+	repo    = storage.NewDirectoryRepositoryWithPaths(paths)
 	version string
+
+	listLong    bool
+	listNoTrunc bool
+	listTags    []string
+	listAll     bool
+	listRecent  bool
+	switchAck   bool
+	switchAuto  bool
+	saveForce   bool
+	saveInclude []string
+	saveExclude []string
 )
 
 // Execute runs the CLI.
 func Execute(v string) error {
 	version = v
-	return rootCmd.Execute()
+	markUsageErrors(rootCmd)
+	err := rootCmd.Execute()
+	progress.clear()
+	finishLogging(err)
+	return err
 }
 
 var rootCmd = &cobra.Command{
@@ -32,8 +64,50 @@ var rootCmd = &cobra.Command{
   \___|(_)  |_|  \___/
 
 `) + "Manage multiple OpenAI Codex CLI accounts with ease.",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyHome(); err != nil {
+			return err
+		}
+		setupLogging(cmd, args)
+		if err := checkPolicy(cmd); err != nil {
+			return err
+		}
+		if err := codex.CheckEnv(paths); err != nil {
+			return err
+		}
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		applyColor(cfg)
+		repo.SetActivation(cfg.Activation)
+		fscopy.SetWorkers(cfg.CopyWorkers)
+		repo.SetExcludes(cfg.Exclude)
+		repo.SetEncryption(cfg.Encrypt)
+		repo.SetKeySource(storageKey(cfg.KeyFile))
+		repo.SetKeyring(keychain.New(), cfg.AuthStore == "keychain")
+		repo.SetSnapshots(cfg.KeptSnapshots())
+		repo.SetTrashRetention(cfg.TrashRetention())
+		repo.SetProgress(progress.show)
+		repo.SetDedup(cfg.Dedup)
+		repo.SetCacheSize(cfg.CacheSize)
+		if out.Interactive() {
+			repo.SetShareResolver(resolveShareConflict)
+		}
+		// A backend that cannot be opened must not keep cxa config from
+		// fixing it
+		if err := repo.SetBackend(cfg.Backend, cfg.BackendLocation()); err != nil && cmd.Parent() != configCmd {
+			return err
+		}
+		if out.Quiet() {
+			lipgloss.SetColorProfile(termenv.Ascii)
+		}
+		return applyIOLimit(cmd, cfg)
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		// No args = launch TUI
+		// No args = launch TUI, which owns the terminal, so sharing
+		// conflicts are moved aside rather than asked about
+		repo.SetShareResolver(nil)
 		return tui.Run(repo)
 	},
 }
@@ -47,80 +121,194 @@ var listCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		saved := len(accounts)
+		if !listAll {
+			accounts = slices.DeleteFunc(accounts, func(acc *account.Account) bool { return acc.Archived })
+		}
+		accounts = account.FilterTags(accounts, listTags)
+
+		if listRecent {
+			account.SortRecent(accounts)
+		}
 
 		current, _ := repo.Current()
 
-		if len(accounts) == 0 {
-			fmt.Println(styles.MutedStyle.Render("No accounts saved yet."))
-			fmt.Println(styles.MutedStyle.Render("Save your current account with: cxa save <name>"))
-			return nil
-		}
-
-		fmt.Println(styles.RenderTitle("Saved Accounts"))
-		fmt.Println()
-
-		for _, acc := range accounts {
-			if acc.Name == current {
-				fmt.Printf("  %s %s %s\n",
-					styles.Bullet,
-					styles.CurrentAccountStyle.Render(acc.Name),
-					styles.MutedStyle.Render("(current)"),
-				)
-			} else {
-				fmt.Printf("  %s %s\n",
-					styles.Circle,
-					acc.Name,
-				)
+		return out.Result(accountsJSON(accounts, current), func() {
+			if out.Quiet() {
+				for _, acc := range accounts {
+					out.Essential(acc.Name)
+				}
+				return
+			}
+			if len(accounts) == 0 && len(listTags) > 0 {
+				out.Println(styles.MutedStyle.Render("No accounts tagged " + strings.Join(listTags, ", ") + "."))
+				return
+			}
+			if len(accounts) == 0 && saved > 0 {
+				out.Println(styles.MutedStyle.Render("All accounts are archived. Show them with: cxa list --all"))
+				return
+			}
+			if len(accounts) == 0 {
+				out.Println(styles.MutedStyle.Render("No accounts saved yet."))
+				out.Println(styles.MutedStyle.Render("Save your current account with: cxa save <name>"))
+				return
+			}
+
+			out.Println(styles.RenderTitle("Saved Accounts"))
+			out.Println()
+
+			if listLong {
+				out.Println(renderAccountTable(accounts, current))
+				return
 			}
-		}
-		fmt.Println()
 
-		return nil
+			for _, acc := range accounts {
+				tags := ""
+				if acc.Protected {
+					tags = " " + styles.Lock
+				}
+				if acc.Archived {
+					tags += " " + styles.MutedStyle.Render("(archived)")
+				}
+				if acc.Compressed {
+					tags += " " + styles.MutedStyle.Render("(compressed)")
+				}
+				if acc.Encrypted {
+					tags += " " + styles.MutedStyle.Render("(encrypted)")
+				}
+				if len(acc.Tags) > 0 {
+					tags += " " + styles.PrimaryStyle.Render(formatTags(acc.Tags))
+				}
+				if acc.Name == current {
+					out.Printf("  %s %s %s%s\n",
+						styles.Bullet,
+						styles.CurrentAccountStyle.Render(acc.Name),
+						styles.MutedStyle.Render("(current)"),
+						tags,
+					)
+				} else {
+					out.Printf("  %s %s%s\n",
+						styles.Circle,
+						acc.Name,
+						tags,
+					)
+				}
+			}
+			out.Println()
+		})
 	},
 }
 
 var switchCmd = &cobra.Command{
-	Use:     "switch <name>",
+	Use:     "switch [name]",
 	Short:   "Switch to a different account",
+	Long:    "Switch to the named account, or pick one from a filterable list when no name is given. With --auto, switch to the account pinned by the nearest .cxa file (see cxa pin).",
 	Aliases: []string{"sw", "use"},
-	Args:    cobra.ExactArgs(1),
+	Args:    cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		name := args[0]
+		if switchAuto {
+			if len(args) > 0 {
+				err := &usageError{err: errors.New("--auto takes no account name")}
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+			return autoSwitch()
+		}
+
+		var name string
+		if len(args) == 1 {
+			name = args[0]
+		} else {
+			picked, err := pickAccount("Switch to")
+			if err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+			name = picked
+		}
 
-		fmt.Printf("%s Switching to %s...\n",
+		ok, err := acknowledgeReminder(name, switchAck)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		if !ok {
+			return out.Result(map[string]bool{"cancelled": true}, func() {
+				out.Println(styles.MutedStyle.Render("Cancelled."))
+			})
+		}
+
+		out.Printf("%s Switching to %s...\n",
 			styles.Caret,
 			styles.PrimaryStyle.Render(name),
 		)
+		warnCodexMismatch(name)
 
-		if err := repo.Activate(name); err != nil {
-			fmt.Println(styles.RenderError(err.Error()))
+		ctx, stop := interruptible()
+		defer stop()
+		if err := repo.ActivateContext(ctx, name); err != nil {
+			if errors.Is(err, context.Canceled) {
+				err = errors.New("switch interrupted; ~/.codex is unchanged")
+			}
+			out.Println(styles.RenderError(err.Error()))
 			return err
 		}
 
-		fmt.Println(styles.RenderSuccess(fmt.Sprintf("Switched to %s", name)))
-		return nil
+		return out.Result(map[string]string{"current": name}, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Switched to %s", name)))
+			warnQuota()
+		})
 	},
 }
 
 var saveCmd = &cobra.Command{
 	Use:   "save <name>",
 	Short: "Save the current ~/.codex as an account",
-	Args:  cobra.ExactArgs(1),
+	Long: "Save the current ~/.codex as an account, replacing what was saved under that name before.\n\n" +
+		"With --include or --exclude, only part of ~/.codex is saved: for example --include auth.json --include config.toml updates the credentials and settings of an account and leaves its saved sessions alone. " +
+		"Whatever is not saved stays in the account as it was. Patterns are written as for the exclude setting.",
+	Example: "  cxa save work\n  cxa save work --include auth.json --include config.toml\n  cxa save work --exclude sessions/",
+	Args:    cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
+		for _, p := range append(slices.Clone(saveInclude), saveExclude...) {
+			if err := fscopy.CheckPattern(p); err != nil {
+				err = &usageError{err: err}
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+		}
 
-		fmt.Printf("%s Saving current session as %s...\n",
+		out.Printf("%s Saving current session as %s...\n",
 			styles.Caret,
 			styles.PrimaryStyle.Render(name),
 		)
 
-		if _, err := repo.Save(name); err != nil {
-			fmt.Println(styles.RenderError(err.Error()))
+		save := repo.SaveContext
+		if saveForce {
+			save = repo.SaveForceContext
+		}
+		if len(saveInclude) > 0 || len(saveExclude) > 0 {
+			sel := storage.Selection{Include: saveInclude, Exclude: saveExclude}
+			save = func(ctx context.Context, name string) (*account.Account, error) {
+				return repo.SaveSelected(ctx, name, sel, saveForce)
+			}
+		}
+		ctx, stop := interruptible()
+		defer stop()
+		acc, err := save(ctx, name)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				err = fmt.Errorf("save interrupted; %s is unchanged", name)
+			}
+			out.Println(styles.RenderError(err.Error()))
 			return err
 		}
 
-		fmt.Println(styles.RenderSuccess(fmt.Sprintf("Saved account: %s", name)))
-		return nil
+		return out.Result(acc, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Saved account: %s", name)))
+			warnQuota()
+		})
 	},
 }
 
@@ -133,30 +321,108 @@ var currentCmd = &cobra.Command{
 			return err
 		}
 
-		if current == "" {
-			fmt.Println(styles.MutedStyle.Render("No active account tracked."))
-			return nil
-		}
+		return out.Result(map[string]string{"current": current}, func() {
+			if out.Quiet() {
+				if current != "" {
+					out.Essential(current)
+				}
+				return
+			}
+			if current == "" {
+				out.Println(styles.MutedStyle.Render("No active account tracked."))
+				return
+			}
 
-		fmt.Printf("%s Current account: %s\n",
-			styles.Bullet,
-			styles.CurrentAccountStyle.Render(current),
-		)
-		return nil
+			out.Printf("%s Current account: %s\n",
+				styles.Bullet,
+				styles.CurrentAccountStyle.Render(current),
+			)
+		})
 	},
 }
 
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print the version",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("cxa version %s\n", version)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return out.Result(map[string]string{"version": version}, func() {
+			if out.Quiet() {
+				out.Essential(version)
+				return
+			}
+			out.Printf("cxa version %s\n", version)
+		})
 	},
 }
 
+// warnCodexMismatch warns when the account was saved with a Codex CLI
+// version different enough from the installed one that its config or
+// sessions may not carry over.
+func warnCodexMismatch(name string) {
+	saved, installed, mismatch := repo.CodexMismatch(name)
+	if !mismatch {
+		return
+	}
+	out.Println(styles.RenderWarning(fmt.Sprintf(
+		"%s was saved with codex %s, but codex %s is installed; check its config with cxa lint %s",
+		name, saved, installed, name)))
+}
+
+// interruptible returns a context done on Ctrl+C or SIGTERM, for
+// operations that stop cleanly partway instead of being killed.
+func interruptible() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// renderAccountTable renders accounts as a detailed table for list -l.
+func renderAccountTable(accounts []*account.Account, current string) string {
+	t := table.New("", "NAME", "EMAIL", "TAGS", "LAST USED", "CREATED", "SIZE", "SAVED ON").Indent("  ")
+	if listNoTrunc {
+		t.MaxWidth(0)
+	}
+
+	for _, acc := range accounts {
+		marker := "○"
+		if acc.Name == current {
+			marker = "●"
+		}
+		lastUsed := "never"
+		if !acc.LastUsed().IsZero() {
+			lastUsed = humanize.Time(acc.LastUsed())
+		}
+		size := ""
+		if acc.Size > 0 {
+			size = humanize.Bytes(uint64(acc.Size))
+		}
+		t.Row(marker, acc.Name, acc.Email, strings.Join(acc.Tags, ","), lastUsed, acc.CreatedAt.Format("2006-01-02"), size, acc.Hostname)
+	}
+
+	return t.Style(func(row, col int) lipgloss.Style {
+		switch {
+		case row == -1:
+			return styles.MutedStyle
+		case accounts[row].Name == current && (col == 0 || col == 1):
+			return styles.CurrentAccountStyle
+		case col == 0:
+			return styles.MutedStyle
+		}
+		return lipgloss.NewStyle()
+	}).Render()
+}
+
 func init() {
+	listCmd.Flags().BoolVarP(&listLong, "long", "l", false, "show details in a table")
+	listCmd.Flags().BoolVarP(&listAll, "all", "a", false, "include archived accounts")
+	listCmd.Flags().StringArrayVar(&listTags, "tag", nil, "only list accounts with this tag (repeatable; all must match)")
+	listCmd.Flags().BoolVar(&listRecent, "recent", false, "list the most recently used accounts first")
+	listCmd.Flags().BoolVar(&listNoTrunc, "no-trunc", false, "do not truncate table columns to the terminal width")
 	rootCmd.AddCommand(listCmd)
+	switchCmd.Flags().BoolVar(&switchAck, "ack", false, "acknowledge the account's reminder without prompting")
+	switchCmd.Flags().BoolVar(&switchAuto, "auto", false, "switch to the account pinned by the nearest .cxa file")
 	rootCmd.AddCommand(switchCmd)
+	saveCmd.Flags().BoolVar(&saveForce, "force", false, "save over the account even if it is locked")
+	saveCmd.Flags().StringArrayVar(&saveInclude, "include", nil, "only save paths matching this glob, such as auth.json or sessions (repeatable)")
+	saveCmd.Flags().StringArrayVar(&saveExclude, "exclude", nil, "don't save paths matching this glob (repeatable)")
 	rootCmd.AddCommand(saveCmd)
 	rootCmd.AddCommand(currentCmd)
 	rootCmd.AddCommand(versionCmd)
@@ -2,10 +2,12 @@ package cli
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/charmbracelet/huh"
 	"github.com/delhombre/cxa/internal/sharing"
 	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/dustin/go-humanize"
 	"github.com/spf13/cobra"
 )
 
@@ -18,58 +20,180 @@ var shareCmd = &cobra.Command{
 	},
 }
 
+var (
+	shareMode     string
+	shareStrategy string
+	shareAccounts []string
+	shareSettings bool
+	shareDryRun   bool
+	shareFresh    bool
+)
+
 var shareEnableCmd = &cobra.Command{
 	Use:   "enable",
 	Short: "Enable session sharing",
+	Long: "Enable session sharing. In global mode every account shares one set of sessions, threads, and history. " +
+		"In group mode only accounts in the same group share them, and accounts outside every group share nothing; see cxa share group. " +
+		"In selected mode only the accounts chosen with --accounts or cxa share add share them.\n\n" +
+		"Shared items are symlinks in ~/.codex by default. Some tools resolve symlinks and then misbehave; with --strategy hardlink the items stay real directories whose files are hardlinks to the shared copies, brought back in line on every switch. " +
+		"Hardlinks need ~/.codex and the data directory on the same filesystem.\n\n" +
+		"The sessions and history already in ~/.codex move to the shared location, with a progress bar when that copies them to another filesystem. " +
+		"With --fresh they are backed up under backups/ in the data directory instead, and sharing starts without them.\n\n" +
+		"On Windows, where symlinks need Developer Mode or administrator rights, the default is --strategy junction: directories are junctions and files hardlinks, or copies synced on every switch where they cannot be hardlinked.\n\n" +
+		"With --strategy read-only, each account reads the shared sessions and history but writes its own: the shared files are copied into ~/.codex on every switch, and what Codex writes stays with the account until cxa share promote shares it.",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		manager := sharing.NewManager()
-		if err := manager.LoadConfig(); err != nil {
+		mode := sharing.Mode(shareMode)
+		if mode != sharing.ModeGlobal && mode != sharing.ModeGroup && mode != sharing.ModeSelected {
+			err := &usageError{err: fmt.Errorf("invalid --mode '%s': use global, group, or selected", shareMode)}
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		manager, err := loadSharing()
+		if err != nil {
+			return err
+		}
+		strategy := manager.Strategy()
+		if cmd.Flags().Changed("strategy") {
+			strategy = sharing.Strategy(shareStrategy)
+		}
+		if strategy != sharing.StrategySymlink && strategy != sharing.StrategyHardlink && strategy != sharing.StrategyJunction && strategy != sharing.StrategyReadOnly {
+			err := &usageError{err: fmt.Errorf("invalid --strategy '%s': use symlink, hardlink, junction, or read-only", shareStrategy)}
+			out.Println(styles.RenderError(err.Error()))
 			return err
 		}
 
-		if manager.IsEnabled() {
-			fmt.Println(styles.RenderWarning(fmt.Sprintf("Sharing is already enabled (mode: %s)", manager.GetMode())))
-			return nil
+		if len(shareAccounts) > 0 {
+			if mode != sharing.ModeSelected {
+				err := &usageError{err: fmt.Errorf("--accounts needs --mode selected")}
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+			for _, name := range shareAccounts {
+				if _, err := repo.Get(name); err != nil {
+					out.Println(styles.RenderError(err.Error()))
+					return err
+				}
+			}
+		}
+
+		includeSettings := manager.IncludesSettings()
+		if cmd.Flags().Changed("settings") {
+			includeSettings = shareSettings
+		}
+		if shareDryRun {
+			current, _ := repo.Current()
+			plan, err := manager.PlanEnable(mode, strategy, includeSettings, current, shareAccounts)
+			if err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+			return out.Result(plan, func() {
+				renderSharePlan(plan, "Enabling sharing")
+			})
 		}
 
-		fmt.Println()
-		fmt.Println(styles.RenderTitle("Session Sharing Setup"))
-		fmt.Println()
-		fmt.Println("This will share sessions, threads, and history between all your accounts.")
-		fmt.Println(styles.MutedStyle.Render("Authentication (auth.json) remains private to each account."))
-		fmt.Println()
+		if len(shareAccounts) > 0 {
+			current, _ := repo.Current()
+			for _, name := range shareAccounts {
+				if err := manager.Select(name, true, current); err != nil {
+					out.Println(styles.RenderError(err.Error()))
+					return err
+				}
+			}
+		}
+
+		if manager.GetMode() == mode && manager.Strategy() == strategy {
+			return out.Result(map[string]any{"mode": manager.GetMode(), "accounts": manager.Selected()}, func() {
+				if mode == sharing.ModeSelected && len(shareAccounts) > 0 {
+					out.Println(styles.RenderSuccess("Sharing between: " + strings.Join(manager.Selected(), ", ")))
+					return
+				}
+				out.Println(styles.RenderWarning(fmt.Sprintf("Sharing is already enabled (mode: %s)", manager.GetMode())))
+			})
+		}
+
+		out.Println()
+		out.Println(styles.RenderTitle("Session Sharing Setup"))
+		out.Println()
+		if mode == sharing.ModeGroup {
+			out.Println("This will share sessions, threads, and history between the accounts of each group.")
+		} else if mode == sharing.ModeSelected {
+			out.Println("This will share sessions, threads, and history between the accounts you choose.")
+		} else {
+			out.Println("This will share sessions, threads, and history between all your accounts.")
+		}
+		out.Println(styles.MutedStyle.Render("Authentication (auth.json) remains private to each account."))
+		out.Println()
 
 		// Interactive form
-		var includeSettings bool
-		var confirmMigrate bool
+		confirmMigrate := !shareFresh
 
-		form := huh.NewForm(
-			huh.NewGroup(
+		var fields []huh.Field
+		if !cmd.Flags().Changed("fresh") {
+			fields = append(fields, huh.NewConfirm().
+				Title("Migrate existing sessions to shared location?").
+				Description("Recommended: keeps your current sessions accessible. "+
+					"Otherwise they are backed up and sharing starts without them.").
+				Value(&confirmMigrate))
+		}
+		if !cmd.Flags().Changed("settings") {
+			fields = append([]huh.Field{
 				huh.NewConfirm().
 					Title("Also share settings (config.toml, settings.json)?").
 					Value(&includeSettings),
-				huh.NewConfirm().
-					Title("Migrate existing sessions to shared location?").
-					Description("Recommended: keeps your current sessions accessible").
-					Value(&confirmMigrate),
-			),
-		)
-
-		if err := form.Run(); err != nil {
-			return err
+			}, fields...)
+		}
+		if len(fields) > 0 {
+			if err := huh.NewForm(huh.NewGroup(fields...)).Run(); err != nil {
+				return err
+			}
 		}
 
-		fmt.Printf("%s Enabling session sharing...\n", styles.Caret)
+		out.Printf("%s Enabling session sharing...\n", styles.Caret)
 
-		if err := manager.Enable(includeSettings); err != nil {
-			fmt.Println(styles.RenderError(err.Error()))
+		backup := ""
+		if !confirmMigrate {
+			if backup, err = manager.SetAside(); err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+		}
+
+		current, _ := repo.Current()
+		if err := manager.EnableMode(mode, strategy, includeSettings, current); err != nil {
+			out.Println(styles.RenderError(err.Error()))
 			return err
 		}
+		if target, _ := manager.SharedItems(current); strategy == sharing.StrategyReadOnly && confirmMigrate && target != "" {
+			// Read-only sharing keeps what is in ~/.codex local; migrating
+			// means promoting it
+			if _, err := manager.Promote(current, nil, false); err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+		}
+
+		out.Println(styles.RenderSuccess(fmt.Sprintf("Session sharing enabled (%s mode)", mode)))
+		switch mode {
+		case sharing.ModeGroup:
+			out.Println(styles.MutedStyle.Render("Accounts in the same group will now share sessions, threads, and history."))
+			out.Println(styles.MutedStyle.Render("  Create a group with: cxa share group create <group>"))
+		case sharing.ModeSelected:
+			out.Println(styles.MutedStyle.Render("The chosen accounts will now share sessions, threads, and history."))
+			if accounts := manager.Selected(); len(accounts) > 0 {
+				out.Println(styles.MutedStyle.Render("  Sharing between: " + strings.Join(accounts, ", ")))
+			} else {
+				out.Println(styles.MutedStyle.Render("  Choose accounts with: cxa share add <account>..."))
+			}
+		default:
+			out.Println(styles.MutedStyle.Render("All accounts will now share sessions, threads, and history."))
+		}
 
-		fmt.Println(styles.RenderSuccess("Session sharing enabled (global mode)"))
-		fmt.Println(styles.MutedStyle.Render("All accounts will now share sessions, threads, and history."))
+		if backup != "" {
+			out.Println(styles.MutedStyle.Render("  Your existing sessions were backed up to " + backup))
+		}
 
-		return nil
+		return out.Result(map[string]any{"mode": manager.GetMode(), "backup": backup}, nil)
 	},
 }
 
@@ -77,18 +201,30 @@ var shareDisableCmd = &cobra.Command{
 	Use:   "disable",
 	Short: "Disable session sharing",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		manager := sharing.NewManager()
+		manager := sharing.NewManagerWithPaths(paths)
 		if err := manager.LoadConfig(); err != nil {
 			return err
 		}
 
 		if !manager.IsEnabled() {
-			fmt.Println(styles.MutedStyle.Render("Sharing is already disabled."))
-			return nil
+			return out.Result(map[string]any{"mode": manager.GetMode()}, func() {
+				out.Println(styles.MutedStyle.Render("Sharing is already disabled."))
+			})
 		}
 
-		fmt.Println()
-		fmt.Println("Disabling sharing will copy current shared data to your account's local storage.")
+		if shareDryRun {
+			plan, err := manager.PlanDisable()
+			if err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+			return out.Result(plan, func() {
+				renderSharePlan(plan, "Disabling sharing")
+			})
+		}
+
+		out.Println()
+		out.Println("Disabling sharing will copy current shared data to your account's local storage.")
 
 		var confirm bool
 		form := huh.NewForm(
@@ -104,73 +240,198 @@ var shareDisableCmd = &cobra.Command{
 		}
 
 		if !confirm {
-			fmt.Println(styles.MutedStyle.Render("Cancelled."))
+			out.Println(styles.MutedStyle.Render("Cancelled."))
 			return nil
 		}
 
 		if err := manager.Disable(); err != nil {
-			fmt.Println(styles.RenderError(err.Error()))
+			out.Println(styles.RenderError(err.Error()))
 			return err
 		}
 
-		fmt.Println(styles.RenderSuccess("Session sharing disabled"))
-		fmt.Println(styles.MutedStyle.Render("Your sessions have been copied locally."))
+		out.Println(styles.RenderSuccess("Session sharing disabled"))
+		out.Println(styles.MutedStyle.Render("Your sessions have been copied locally."))
 
-		return nil
+		return out.Result(map[string]any{"mode": manager.GetMode()}, nil)
 	},
 }
 
 var shareStatusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show sharing configuration",
+	Long: "Show the sharing mode and where the current account shares, and check each shareable item in ~/.codex.\n\n" +
+		"cxa share status exits with 3 when an item is not as sharing expects it: a broken symlink, a symlink to the wrong place, or an item that should be shared but is not. " +
+		"With --json, each item's state is one of shared, layered, local, missing, broken, or stray.",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		manager := sharing.NewManager()
+		manager := sharing.NewManagerWithPaths(paths)
 		if err := manager.LoadConfig(); err != nil {
+			out.Println(styles.RenderError(err.Error()))
 			return err
 		}
 
-		mode, sharedDir, symlinks := manager.Status()
+		current, _ := repo.Current()
+		mode, target, items := manager.Status(current)
+		unhealthy := 0
+		for _, item := range items {
+			if !item.Healthy() {
+				unhealthy++
+			}
+		}
+		group, _ := manager.Group(current)
+
+		err := out.Result(map[string]any{
+			"mode":       mode,
+			"strategy":   manager.Strategy(),
+			"account":    current,
+			"group":      group,
+			"accounts":   manager.Selected(),
+			"filters":    manager.SessionFilters(),
+			"shared_dir": target,
+			"healthy":    unhealthy == 0,
+			"items":      items,
+		}, func() {
+			out.Println()
+			out.Println(styles.RenderTitle("Sharing Status"))
+			out.Println()
 
-		fmt.Println()
-		fmt.Println(styles.RenderTitle("Sharing Status"))
-		fmt.Println()
+			// Mode
+			modeStr := string(mode)
+			if mode == sharing.ModeDisabled {
+				modeStr = styles.MutedStyle.Render(modeStr)
+			} else {
+				modeStr = styles.SuccessStyle.Render(modeStr)
+			}
+			out.Printf("  Mode: %s\n", modeStr)
 
-		// Mode
-		modeStr := string(mode)
-		if mode == sharing.ModeDisabled {
-			modeStr = styles.MutedStyle.Render(modeStr)
-		} else {
-			modeStr = styles.SuccessStyle.Render(modeStr)
+			if mode != sharing.ModeDisabled {
+				out.Printf("  Strategy: %s\n", manager.Strategy())
+			}
+			if mode == sharing.ModeGroup && current != "" {
+				if group == "" {
+					group = styles.MutedStyle.Render("none")
+				}
+				out.Printf("  Group: %s %s\n", group, styles.MutedStyle.Render("("+current+")"))
+			}
+			if mode == sharing.ModeSelected {
+				accounts := styles.MutedStyle.Render("none")
+				if selected := manager.Selected(); len(selected) > 0 {
+					accounts = strings.Join(selected, ", ")
+				}
+				out.Printf("  Accounts: %s\n", accounts)
+			}
+			if target != "" {
+				out.Printf("  Location: %s\n", styles.MutedStyle.Render(target))
+			}
+			if filters := manager.SessionFilters(); len(filters) > 0 {
+				out.Printf("  Sessions: matching %s\n", strings.Join(filters, " or "))
+			}
+
+			out.Println()
+			out.Println("  Items:")
+			for _, item := range items {
+				mark := styles.Circle
+				switch {
+				case !item.Healthy():
+					mark = styles.CrossMark
+				case item.State == sharing.ItemShared || item.State == sharing.ItemLayered:
+					mark = styles.CheckMark
+				}
+				detail := styles.MutedStyle.Render("(" + string(item.State) + ")")
+				if item.Target != "" {
+					detail = styles.Arrow + " " + styles.MutedStyle.Render(item.Target)
+					if item.State != sharing.ItemShared {
+						detail += " " + styles.MutedStyle.Render("("+string(item.State)+")")
+					}
+				}
+				out.Printf("  %s %s %s\n", mark, item.Item, detail)
+			}
+			out.Println()
+
+			if unhealthy > 0 {
+				out.Println(styles.RenderWarning(fmt.Sprintf("%d item(s) are not as sharing expects; run 'cxa share repair' to fix them", unhealthy)))
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if unhealthy > 0 {
+			cmd.SilenceErrors = true
+			return &exitError{code: exitUnhealthy}
 		}
-		fmt.Printf("  Mode: %s\n", modeStr)
+		return nil
+	},
+}
 
-		if sharedDir != "" {
-			fmt.Printf("  Location: %s\n", styles.MutedStyle.Render(sharedDir))
+var shareRepairCmd = &cobra.Command{
+	Use:   "repair",
+	Short: "Fix broken or stray sharing symlinks",
+	Long: "Put the shared items in ~/.codex back the way sharing expects them, after an OS restore or manual edits left symlinks pointing to missing or wrong places. " +
+		"Missing shared copies are recreated empty. Symlinks of your own to places outside cxa's sharing directories are left alone. Running it again changes nothing.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := loadSharing()
+		if err != nil {
+			return err
 		}
 
-		fmt.Println()
-		fmt.Println("  Symlinks:")
-		for item, target := range symlinks {
-			var status string
-			switch target {
-			case "(local)":
-				status = fmt.Sprintf("  %s %s %s", styles.Circle, item, styles.MutedStyle.Render(target))
-			case "(missing)":
-				status = fmt.Sprintf("  %s %s %s", styles.CrossMark, item, styles.MutedStyle.Render(target))
-			default:
-				status = fmt.Sprintf("  %s %s %s %s", styles.CheckMark, item, styles.Arrow, styles.MutedStyle.Render(target))
-			}
-			fmt.Println(status)
+		current, _ := repo.Current()
+		changes, err := manager.Repair(current)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
 		}
-		fmt.Println()
 
-		return nil
+		return out.Result(map[string]any{"account": current, "changes": changes}, func() {
+			if len(changes) == 0 {
+				out.Println(styles.RenderSuccess("Sharing is intact, nothing to repair"))
+				return
+			}
+			for _, change := range changes {
+				out.Printf("  %s %s %s\n", styles.CheckMark, change.Item,
+					styles.MutedStyle.Render(fmt.Sprintf("%s %s %s", change.Before, styles.Arrow, change.After)))
+			}
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Repaired %d item(s)", len(changes))))
+		})
 	},
 }
 
+// renderSharePlan prints what doing would change, one action a line.
+func renderSharePlan(plan *sharing.Plan, doing string) {
+	if len(plan.Actions) == 0 {
+		out.Println(styles.MutedStyle.Render(doing + " would change nothing."))
+		return
+	}
+	out.Printf("%s would:\n", doing)
+	for _, action := range plan.Actions {
+		detail := action.From
+		switch {
+		case action.From != "" && action.To != "":
+			detail = action.From + " " + styles.Arrow + " " + action.To
+		case action.To != "":
+			detail = action.To
+		}
+		size := ""
+		if action.Bytes > 0 {
+			size = " " + styles.MutedStyle.Render("("+humanize.Bytes(uint64(action.Bytes))+")")
+		}
+		out.Printf("  %-9s %s %s%s\n", action.Op, action.Item, styles.MutedStyle.Render(detail), size)
+	}
+	if plan.Bytes > 0 {
+		out.Printf("%s %s moved or copied\n", styles.Caret, humanize.Bytes(uint64(plan.Bytes)))
+	}
+}
+
 func init() {
+	shareEnableCmd.Flags().StringVar(&shareMode, "mode", string(sharing.ModeGlobal), "share between all accounts (global), within groups (group), or between chosen accounts (selected)")
+	shareEnableCmd.Flags().StringSliceVar(&shareAccounts, "accounts", nil, "the accounts to share between in selected mode")
+	shareEnableCmd.Flags().BoolVar(&shareSettings, "settings", false, "also share config.toml and settings.json, without asking")
+	shareEnableCmd.Flags().BoolVar(&shareDryRun, "dry-run", false, "show what would be moved and linked without changing anything")
+	shareEnableCmd.Flags().BoolVar(&shareFresh, "fresh", false, "back up the sessions and history in ~/.codex and share without them, without asking")
+	shareDisableCmd.Flags().BoolVar(&shareDryRun, "dry-run", false, "show what would be copied back without changing anything")
+	shareEnableCmd.Flags().StringVar(&shareStrategy, "strategy", string(sharing.DefaultStrategy), "put shared items in ~/.codex as symlinks, hardlinks, or junctions, or attach them read-only (read-only)")
 	shareCmd.AddCommand(shareEnableCmd)
 	shareCmd.AddCommand(shareDisableCmd)
 	shareCmd.AddCommand(shareStatusCmd)
+	shareCmd.AddCommand(shareRepairCmd)
 	rootCmd.AddCommand(shareCmd)
 }
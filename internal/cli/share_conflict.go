@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/huh"
+	"github.com/delhombre/cxa/internal/sharing"
+	"github.com/delhombre/cxa/internal/ui/styles"
+)
+
+// resolveShareConflict asks how to settle an item whose local copy holds
+// what the shared one does not. Cancelling leaves the item unshared.
+func resolveShareConflict(c sharing.Conflict) (sharing.Resolution, error) {
+	out.Println(styles.RenderWarning(fmt.Sprintf("%s in ~/.codex differs from the shared one", c.Item)))
+	out.Println(styles.MutedStyle.Render(fmt.Sprintf("  %d file(s) only here, %d with different contents", c.OnlyLocal, c.Differ)))
+	out.Println(styles.MutedStyle.Render("  Shared: " + c.Shared))
+
+	var choice sharing.Resolution
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[sharing.Resolution]().
+				Title(fmt.Sprintf("How should %s be shared?", c.Item)).
+				Options(
+					huh.NewOption("Merge the local copy into the shared one", sharing.ResolveMerge),
+					huh.NewOption("Keep the shared copy, deleting the local one", sharing.ResolveKeepShared),
+					huh.NewOption("Keep the local copy, replacing the shared one", sharing.ResolveKeepLocal),
+					huh.NewOption("Cancel, leaving it unshared", sharing.Resolution("")),
+				).
+				Value(&choice),
+		),
+	)
+	if err := form.Run(); err != nil {
+		return "", err
+	}
+	if choice == "" {
+		return "", sharing.ErrLeftUnshared
+	}
+	return choice, nil
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/delhombre/cxa/internal/sharing"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var shareFilterCmd = &cobra.Command{
+	Use:   "filter",
+	Short: "Choose which sessions are shared",
+	Long: `Session filters share only the sessions they match; the rest stay with
+the account that had them, so a personal project's sessions need not show
+up in a work account. A rule is key=pattern, matched against the metadata
+each session file starts with:
+
+  cwd=<path>          sessions run in path or anywhere under it; * and **
+                      work as in exclude patterns, and ~/ is your home
+  <key>=<pattern>     any other metadata, such as git.repository_url or
+                      originator, where * stands for any text
+
+A rule without a key is a cwd rule. A session matching any rule is shared.
+Sessions already shared stay shared.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+var shareFilterAddCmd = &cobra.Command{
+	Use:     "add <rule>",
+	Short:   "Share only the sessions matching rule, and those of the other rules",
+	Example: "  cxa share filter add ~/work\n  cxa share filter add 'git.repository_url=*github.com/acme/*'",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := loadSharing()
+		if err != nil {
+			return err
+		}
+		dir, _ := os.Getwd()
+		rule, err := sharing.ParseSessionFilter(args[0], dir)
+		if err == nil {
+			current, _ := repo.Current()
+			err = manager.AddSessionFilter(rule, current)
+		}
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]any{"added": rule, "filters": manager.SessionFilters()}, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Sharing sessions matching %s", rule)))
+			if len(manager.SessionFilters()) == 1 {
+				out.Println(styles.MutedStyle.Render("  Sessions matching no filter now stay with their account."))
+			}
+		})
+	},
+}
+
+var shareFilterRemoveCmd = &cobra.Command{
+	Use:     "remove <rule>",
+	Aliases: []string{"rm"},
+	Short:   "Remove a session filter",
+	Long:    "Remove a session filter, as cxa share filter list shows it. Once none are left, every session is shared again from the next switch on.",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := loadSharing()
+		if err != nil {
+			return err
+		}
+		if err := manager.RemoveSessionFilter(args[0]); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]any{"removed": args[0], "filters": manager.SessionFilters()}, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Removed session filter %s", args[0])))
+		})
+	},
+}
+
+var shareFilterListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List session filters",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := loadSharing()
+		if err != nil {
+			return err
+		}
+
+		filters := manager.SessionFilters()
+		return out.Result(map[string]any{"filters": filters}, func() {
+			if len(filters) == 0 {
+				out.Println(styles.MutedStyle.Render("No session filters; every session is shared. Add one with: cxa share filter add <rule>"))
+				return
+			}
+			for _, rule := range filters {
+				out.Printf("  %s\n", rule)
+			}
+		})
+	},
+}
+
+func init() {
+	shareFilterCmd.AddCommand(shareFilterAddCmd, shareFilterRemoveCmd, shareFilterListCmd)
+	shareCmd.AddCommand(shareFilterCmd)
+}
@@ -0,0 +1,214 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+	"github.com/delhombre/cxa/internal/sharing"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var shareGroupDeleteYes bool
+
+var shareGroupCmd = &cobra.Command{
+	Use:   "group",
+	Short: "Manage sharing groups",
+	Long: `In group mode (cxa share enable --mode group), accounts share sessions,
+threads, and history only with the other accounts of their group. Create
+groups, then assign accounts to them; an account outside every group keeps
+its sessions to itself. An assignment takes effect the next time the
+account is switched to, or at once for the current account.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+var shareGroupCreateCmd = &cobra.Command{
+	Use:   "create <group>",
+	Short: "Create a sharing group",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := loadSharing()
+		if err != nil {
+			return err
+		}
+		if err := manager.CreateGroup(args[0]); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]string{"created": args[0]}, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Created group '%s'", args[0])))
+			out.Println(styles.MutedStyle.Render(fmt.Sprintf("  Add accounts with: cxa share group assign <account> %s", args[0])))
+			if manager.GetMode() != sharing.ModeGroup {
+				out.Println(styles.MutedStyle.Render("  Groups are used once sharing is in group mode: cxa share enable --mode group"))
+			}
+		})
+	},
+}
+
+var shareGroupDeleteCmd = &cobra.Command{
+	Use:     "delete <group>",
+	Aliases: []string{"rm"},
+	Short:   "Delete a sharing group and what was shared in it",
+	Long:    "Delete a sharing group, with the sessions, threads, and history its accounts shared. Take its accounts out of it first with cxa share group unassign.",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		manager, err := loadSharing()
+		if err != nil {
+			return err
+		}
+
+		if shouldConfirm(shareGroupDeleteYes) {
+			confirm := false
+			form := huh.NewForm(
+				huh.NewGroup(
+					huh.NewConfirm().
+						Title(fmt.Sprintf("Delete group '%s'?", name)).
+						Description("The sessions, threads, and history shared in it are deleted too.").
+						Value(&confirm),
+				),
+			)
+			if err := form.Run(); err != nil {
+				return err
+			}
+			if !confirm {
+				return out.Result(map[string]bool{"cancelled": true}, func() {
+					out.Println(styles.MutedStyle.Render("Cancelled."))
+				})
+			}
+		}
+
+		if err := manager.DeleteGroup(name); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]string{"deleted": name}, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Deleted group '%s'", name)))
+		})
+	},
+}
+
+var shareGroupListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List sharing groups and their accounts",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := loadSharing()
+		if err != nil {
+			return err
+		}
+		groups, err := manager.Groups()
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		names := make([]string, 0, len(groups))
+		for name := range groups {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		return out.Result(map[string]any{"mode": manager.GetMode(), "groups": groups}, func() {
+			if len(names) == 0 {
+				out.Println(styles.MutedStyle.Render("No sharing groups. Create one with: cxa share group create <group>"))
+				return
+			}
+			for _, name := range names {
+				accounts := styles.MutedStyle.Render("no accounts")
+				if len(groups[name]) > 0 {
+					accounts = strings.Join(groups[name], ", ")
+				}
+				out.Printf("  %s %s %s\n", styles.Bullet, styles.BoldStyle.Render(name), accounts)
+			}
+			if manager.GetMode() != sharing.ModeGroup {
+				out.Println()
+				out.Println(styles.MutedStyle.Render("Groups are used once sharing is in group mode: cxa share enable --mode group"))
+			}
+		})
+	},
+}
+
+var shareGroupAssignCmd = &cobra.Command{
+	Use:   "assign <account> <group>",
+	Short: "Put an account in a sharing group",
+	Long:  "Put an account in a sharing group, taking it out of any other. It shares the group's sessions, threads, and history from the next time it is switched to, or at once if it is the current account.",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return assignGroup(args[0], args[1])
+	},
+}
+
+var shareGroupUnassignCmd = &cobra.Command{
+	Use:   "unassign <account>",
+	Short: "Take an account out of its sharing group",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return assignGroup(args[0], "")
+	},
+}
+
+// assignGroup puts account in group, or takes it out of its group when
+// group is empty.
+func assignGroup(name, group string) error {
+	if _, err := repo.Get(name); err != nil {
+		out.Println(styles.RenderError(err.Error()))
+		return err
+	}
+	manager, err := loadSharing()
+	if err != nil {
+		return err
+	}
+	previous, _ := manager.Group(name)
+	if group == "" && previous == "" {
+		err := fmt.Errorf("account '%s' is not in a group", name)
+		out.Println(styles.RenderError(err.Error()))
+		return err
+	}
+
+	current, _ := repo.Current()
+	if err := manager.Assign(name, group, current); err != nil {
+		out.Println(styles.RenderError(err.Error()))
+		return err
+	}
+
+	return out.Result(map[string]string{"account": name, "group": group, "previous": previous}, func() {
+		if group == "" {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Took %s out of group '%s'", name, previous)))
+			return
+		}
+		out.Println(styles.RenderSuccess(fmt.Sprintf("Assigned %s to group '%s'", name, group)))
+		if manager.GetMode() != sharing.ModeGroup {
+			out.Println(styles.MutedStyle.Render("  Groups are used once sharing is in group mode: cxa share enable --mode group"))
+		}
+	})
+}
+
+// loadSharing returns a sharing manager with its configuration loaded,
+// asking how to settle conflicts when the user can answer and drawing the
+// progress of long moves.
+func loadSharing() (*sharing.Manager, error) {
+	manager := sharing.NewManagerWithPaths(paths)
+	if err := manager.LoadConfig(); err != nil {
+		out.Println(styles.RenderError(err.Error()))
+		return nil, err
+	}
+	manager.SetProgress(progress.showShare)
+	if out.Interactive() {
+		manager.SetResolver(resolveShareConflict)
+	}
+	return manager, nil
+}
+
+func init() {
+	shareGroupDeleteCmd.Flags().BoolVarP(&shareGroupDeleteYes, "yes", "y", false, "delete without asking")
+	shareGroupCmd.AddCommand(shareGroupCreateCmd, shareGroupDeleteCmd, shareGroupListCmd, shareGroupAssignCmd, shareGroupUnassignCmd)
+	shareCmd.AddCommand(shareGroupCmd)
+}
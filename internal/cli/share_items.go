@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var shareAddItemCmd = &cobra.Command{
+	Use:   "add-item <path>",
+	Short: "Share another file or directory of ~/.codex",
+	Long: "Share a file or directory of ~/.codex beyond sessions, threads, and history, such as prompts/ or mcp.json. " +
+		"<path> is relative to ~/.codex, or absolute inside it. auth.json and the other files that stay with each account cannot be shared. " +
+		"If sharing is enabled, the item is shared at once for the current account, whose copy becomes the shared one when there is none yet.",
+	Example: "  cxa share add-item prompts\n  cxa share add-item mcp.json",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := loadSharing()
+		if err != nil {
+			return err
+		}
+
+		current, _ := repo.Current()
+		item, err := manager.AddItem(args[0], current)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]any{"added": item, "items": manager.CustomItems()}, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Sharing %s", item)))
+			if !manager.IsEnabled() {
+				out.Println(styles.MutedStyle.Render("  It is shared once sharing is enabled: cxa share enable"))
+			}
+		})
+	},
+}
+
+var shareRemoveItemCmd = &cobra.Command{
+	Use:   "remove-item <path>",
+	Short: "Stop sharing a file or directory added with add-item",
+	Long:  "Stop sharing a file or directory added with cxa share add-item. The current account keeps a copy of what was shared.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := loadSharing()
+		if err != nil {
+			return err
+		}
+
+		item, err := manager.RemoveItem(args[0])
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]any{"removed": item, "items": manager.CustomItems()}, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Stopped sharing %s", item)))
+		})
+	},
+}
+
+func init() {
+	shareCmd.AddCommand(shareAddItemCmd, shareRemoveItemCmd)
+}
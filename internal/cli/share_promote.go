@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var sharePromoteDryRun bool
+
+var sharePromoteCmd = &cobra.Command{
+	Use:   "promote [item]...",
+	Short: "Share what the current account wrote under read-only sharing",
+	Long: "Under the read-only strategy, what Codex writes stays with the account. " +
+		"Promote copies it to the shared copies: the files only the current account has, the lines its history and session files have that the shared ones lack, and any other file it changed since. " +
+		"Name shared items, such as sessions or history.jsonl, to promote only those.",
+	Example: "  cxa share promote\n  cxa share promote sessions --dry-run",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := loadSharing()
+		if err != nil {
+			return err
+		}
+
+		current, _ := repo.Current()
+		files, err := manager.Promote(current, args, sharePromoteDryRun)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]any{"account": current, "files": files, "dry_run": sharePromoteDryRun}, func() {
+			if len(files) == 0 {
+				out.Println(styles.MutedStyle.Render("Nothing to promote; the shared copies have everything already."))
+				return
+			}
+			for _, file := range files {
+				out.Printf("  %s %s\n", styles.CheckMark, file)
+			}
+			if sharePromoteDryRun {
+				out.Println(styles.MutedStyle.Render(fmt.Sprintf("%d file(s) would be promoted", len(files))))
+				return
+			}
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Promoted %d file(s) from '%s'", len(files), current)))
+		})
+	},
+}
+
+func init() {
+	sharePromoteCmd.Flags().BoolVar(&sharePromoteDryRun, "dry-run", false, "list what would be promoted without copying anything")
+	shareCmd.AddCommand(sharePromoteCmd)
+}
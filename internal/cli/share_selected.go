@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/delhombre/cxa/internal/sharing"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var shareAddCmd = &cobra.Command{
+	Use:   "add <account>...",
+	Short: "Share sessions between the chosen accounts",
+	Long: "In selected mode (cxa share enable --mode selected), only the chosen accounts share sessions, threads, and history; every other account keeps its own. " +
+		"Add an account to the chosen ones. It shares from the next time it is switched to, or at once if it is the current account.",
+	Example: "  cxa share enable --mode selected\n  cxa share add work-a work-b",
+	Args:    cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return selectAccounts(args, true)
+	},
+}
+
+var shareRemoveCmd = &cobra.Command{
+	Use:     "remove <account>...",
+	Aliases: []string{"rm"},
+	Short:   "Stop sharing sessions with the chosen accounts",
+	Long:    "Take accounts out of the ones that share in selected mode. An account taken out keeps a copy of what was shared.",
+	Args:    cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return selectAccounts(args, false)
+	},
+}
+
+// selectAccounts adds the named accounts to the ones that share in selected
+// mode, or takes them out when selected is false.
+func selectAccounts(names []string, selected bool) error {
+	for _, name := range names {
+		if _, err := repo.Get(name); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+	}
+	manager, err := loadSharing()
+	if err != nil {
+		return err
+	}
+
+	current, _ := repo.Current()
+	for _, name := range names {
+		if err := manager.Select(name, selected, current); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+	}
+
+	return out.Result(map[string]any{"mode": manager.GetMode(), "accounts": manager.Selected()}, func() {
+		if selected {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Sharing sessions with %s", strings.Join(names, ", "))))
+		} else {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Stopped sharing sessions with %s", strings.Join(names, ", "))))
+		}
+		if accounts := manager.Selected(); len(accounts) > 0 {
+			out.Println(styles.MutedStyle.Render("  Sharing between: " + strings.Join(accounts, ", ")))
+		}
+		if manager.GetMode() != sharing.ModeSelected {
+			out.Println(styles.MutedStyle.Render("  The chosen accounts are used once sharing is in selected mode: cxa share enable --mode selected"))
+		}
+	})
+}
+
+func init() {
+	shareCmd.AddCommand(shareAddCmd, shareRemoveCmd)
+}
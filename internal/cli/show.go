@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/delhombre/cxa/internal/account"
+	"github.com/delhombre/cxa/internal/sharing"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+var showCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Show everything about a saved account",
+	Long:  "Show an account's metadata, disk size, credentials and token expiry, and which items it shares under the current sharing configuration. For the current account, credentials are read from ~/.codex.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		acc, err := repo.Get(name)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		dir, err := repo.AccountDir(name)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		// Recorded at save time; only older accounts need walking
+		size := acc.Size
+		if size == 0 {
+			if size, err = repo.AccountSize(name); err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+		}
+
+		current, _ := repo.Current()
+		authPath := filepath.Join(dir, "auth.json")
+		if name == current {
+			authPath = paths.AuthFile()
+		}
+		token := readToken(authPath)
+
+		manager := sharing.NewManagerWithPaths(paths)
+		if err := manager.LoadConfig(); err != nil {
+			return err
+		}
+		shareTarget, shared := manager.SharedItems(name)
+		if shared == nil {
+			shared = []string{}
+		}
+
+		return out.Result(map[string]any{
+			"account":      acc,
+			"current":      name == current,
+			"path":         dir,
+			"size":         size,
+			"token":        token,
+			"sharing":      manager.GetMode(),
+			"share_target": shareTarget,
+			"shared_items": shared,
+		}, func() {
+			renderAccount(acc, name == current, dir, size, token)
+
+			out.Println()
+			mode := string(manager.GetMode())
+			switch {
+			case shareTarget != "":
+				out.Printf("  %-10s %s %s\n", "Sharing:", styles.SuccessStyle.Render(mode), styles.MutedStyle.Render("→ "+shareTarget))
+				out.Printf("  %-10s %s\n", "Shares:", strings.Join(shared, ", "))
+			case manager.IsEnabled():
+				out.Printf("  %-10s %s\n", "Sharing:", styles.MutedStyle.Render(mode+", but not for this account"))
+			default:
+				out.Printf("  %-10s %s\n", "Sharing:", styles.MutedStyle.Render(mode))
+			}
+			out.Println()
+
+			if acc.Notes != "" {
+				out.Println(styles.MutedStyle.Render("  Notes:"))
+				for _, line := range strings.Split(acc.Notes, "\n") {
+					out.Println("    " + line)
+				}
+				out.Println()
+			}
+			if acc.Reminder != "" {
+				printReminder(acc)
+				out.Println()
+			}
+		})
+	},
+}
+
+// renderAccount prints the metadata and credentials part of cxa show.
+func renderAccount(acc *account.Account, current bool, dir string, size int64, token tokenInfo) {
+	printField := func(label, value string) {
+		out.Printf("  %-10s %s\n", label+":", value)
+	}
+	printTime := func(label string, t time.Time) {
+		if t.IsZero() {
+			printField(label, styles.MutedStyle.Render("never"))
+			return
+		}
+		printField(label, humanize.Time(t)+" "+styles.MutedStyle.Render("("+t.Format(time.DateTime)+")"))
+	}
+
+	title := acc.Name
+	if current {
+		title += " " + styles.CurrentAccountStyle.Render("(current)")
+	}
+	out.Println()
+	out.Println(styles.RenderTitle(title))
+	out.Println()
+
+	email := acc.Email
+	if email == "" {
+		email = token.Email
+	}
+	if email == "" {
+		email = styles.MutedStyle.Render("unknown")
+	}
+	printField("Email", email)
+	if acc.Description != "" {
+		printField("About", acc.Description)
+	}
+	if len(acc.Tags) > 0 {
+		printField("Tags", styles.PrimaryStyle.Render(formatTags(acc.Tags)))
+	}
+	if acc.Protected {
+		printField("Locked", styles.Lock+" saving over or deleting needs --force")
+	}
+	printTime("Created", acc.CreatedAt)
+	printTime("Updated", acc.UpdatedAt)
+	printTime("Last used", acc.LastUsedAt)
+	if acc.Compressed {
+		printField("Storage", "compressed "+styles.MutedStyle.Render("(unpacked on the next switch)"))
+	}
+	if acc.Keychain {
+		printField("Auth", "in the system keychain")
+	}
+	if acc.Encrypted {
+		printField("Storage", "encrypted "+styles.MutedStyle.Render("(decrypted into ~/.codex on switch)"))
+	}
+	if acc.CodexVersion != "" {
+		printField("Codex", acc.CodexVersion)
+	}
+	if acc.Hostname != "" {
+		printField("Saved on", acc.Hostname)
+	}
+	if acc.Files > 0 {
+		printField("Size", fmt.Sprintf("%s %s", humanize.Bytes(uint64(size)), styles.MutedStyle.Render(fmt.Sprintf("(%d files)", acc.Files))))
+	} else {
+		printField("Size", humanize.Bytes(uint64(size)))
+	}
+	printField("Path", styles.MutedStyle.Render(dir))
+	printField("Token", tokenStatus(token))
+}
+
+func init() {
+	rootCmd.AddCommand(showCmd)
+}
@@ -0,0 +1,328 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/delhombre/cxa/internal/config"
+	"github.com/delhombre/cxa/internal/schedule"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var (
+	snapshotScheduleOff bool
+	snapshotUnitKind    string
+	snapshotUnitInstall bool
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Snapshot the current account now or on a schedule",
+	Long: `Save ~/.codex into the current account, which keeps the version it
+replaces as a snapshot (see cxa snapshots). cxa snapshot run does it once;
+cxa snapshot daemon does it on the schedule set with cxa snapshot schedule,
+and cxa snapshot unit starts the daemon with your login session.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+var snapshotScheduleCmd = &cobra.Command{
+	Use:   "schedule [cron expression]",
+	Short: "Show or set when scheduled snapshots are taken",
+	Long: `Set the snapshot_schedule setting to a cron expression of five fields,
+minute hour day month weekday, such as "0 */4 * * *" for every four hours,
+or to @hourly, @daily, or @weekly. With no expression, show the schedule
+and when it next fires. --off stops scheduled snapshots.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 1 || snapshotScheduleOff {
+			expr := ""
+			if len(args) == 1 {
+				if snapshotScheduleOff {
+					err := &usageError{err: errors.New("--off takes no cron expression")}
+					out.Println(styles.RenderError(err.Error()))
+					return err
+				}
+				expr = args[0]
+			}
+			err := updateConfig(func(cfg *config.Config) error {
+				return cfg.Set("snapshot_schedule", expr)
+			})
+			if err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		result := map[string]any{"schedule": cfg.SnapshotSchedule}
+		var next time.Time
+		if cfg.SnapshotSchedule != "" {
+			s, err := schedule.Parse(cfg.SnapshotSchedule)
+			if err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+			if next = s.Next(time.Now()); !next.IsZero() {
+				result["next"] = next
+			}
+		}
+
+		return out.Result(result, func() {
+			if cfg.SnapshotSchedule == "" {
+				out.Println(styles.MutedStyle.Render("No snapshot schedule set."))
+				return
+			}
+			out.Printf("%s Snapshot schedule: %s\n", styles.Bullet, styles.BoldStyle.Render(cfg.SnapshotSchedule))
+			if next.IsZero() {
+				out.Println(styles.RenderWarning("The schedule never fires."))
+			} else {
+				out.Println(styles.MutedStyle.Render("  Next: " + next.Format(time.DateTime)))
+			}
+			if cfg.KeptSnapshots() == 0 {
+				out.Println(styles.RenderWarning("snapshots is 0, so no snapshots are kept; set it with cxa config set snapshots <n>"))
+			}
+			out.Println(styles.MutedStyle.Render("  Snapshots are taken while cxa snapshot daemon runs; see cxa snapshot unit."))
+		})
+	},
+}
+
+var snapshotRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Save ~/.codex into the current account now",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := interruptible()
+		defer stop()
+		name, err := scheduledSnapshot(ctx)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]string{"account": name}, func() {
+			if name == "" {
+				out.Println(styles.MutedStyle.Render("No current account to snapshot."))
+				return
+			}
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Saved %s", name)))
+		})
+	},
+}
+
+var snapshotDaemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Take snapshots on the configured schedule",
+	Long: `Run until interrupted, saving ~/.codex into the current account
+whenever snapshot_schedule fires. The schedule is read again after every
+run, so changes to it take effect without a restart. Saves honour
+--io-limit, and io_limit with --background.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := interruptible()
+		defer stop()
+
+		out.Printf("%s Taking scheduled snapshots (press Ctrl+C to stop)...\n", styles.Caret)
+		runs, failures := 0, 0
+		for {
+			next, err := nextSnapshot()
+			if err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+			wait := time.Minute
+			if !next.IsZero() {
+				wait = time.Until(next)
+			}
+			select {
+			case <-ctx.Done():
+				return out.Result(map[string]int{"runs": runs, "failures": failures}, func() {
+					out.Println(styles.MutedStyle.Render(fmt.Sprintf("Stopped after %d snapshots.", runs)))
+				})
+			case <-time.After(wait):
+			}
+			if next.IsZero() {
+				// No schedule yet; look again shortly
+				continue
+			}
+
+			stamp := styles.MutedStyle.Render(time.Now().Format("15:04:05"))
+			name, err := scheduledSnapshot(ctx)
+			switch {
+			case errors.Is(err, context.Canceled):
+			case err != nil:
+				failures++
+				out.Printf("  %s %s %s\n", stamp, styles.CrossMark, styles.ErrorStyle.Render(err.Error()))
+			case name != "":
+				runs++
+				out.Printf("  %s %s Saved %s\n", stamp, styles.CheckMark, styles.BoldStyle.Render(name))
+			}
+		}
+	},
+}
+
+var snapshotUnitCmd = &cobra.Command{
+	Use:   "unit",
+	Short: "Print a launchd or systemd unit running the snapshot daemon",
+	Long: `Print a launchd agent (macOS) or systemd user service (elsewhere) that
+runs cxa snapshot daemon in the background for as long as you are logged
+in. --install writes it where launchd or systemd looks for it and prints
+the command that starts it.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		unit, err := snapshotUnit(snapshotUnitKind)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		if !snapshotUnitInstall {
+			return out.Result(unit, func() {
+				out.Essential(strings.TrimSuffix(unit.Content, "\n"))
+			})
+		}
+
+		if err := os.MkdirAll(filepath.Dir(unit.Path), 0755); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		if err := os.WriteFile(unit.Path, []byte(unit.Content), 0644); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		return out.Result(unit, func() {
+			out.Println(styles.RenderSuccess("Wrote " + unit.Path))
+			out.Println(styles.MutedStyle.Render("  Start it with: " + unit.Start))
+		})
+	},
+}
+
+// scheduledSnapshot saves ~/.codex into the current account and returns
+// its name, or an empty name when there is no current account.
+func scheduledSnapshot(ctx context.Context) (string, error) {
+	name, err := repo.Current()
+	if err != nil || name == "" {
+		return "", err
+	}
+	if _, err := repo.SaveContext(ctx, name); err != nil {
+		return name, fmt.Errorf("failed to snapshot %s: %w", name, err)
+	}
+	return name, nil
+}
+
+// nextSnapshot returns when the configured schedule next fires, or the
+// zero time when none is set.
+func nextSnapshot() (time.Time, error) {
+	cfg, err := loadConfig()
+	if err != nil || cfg.SnapshotSchedule == "" {
+		return time.Time{}, err
+	}
+	s, err := schedule.Parse(cfg.SnapshotSchedule)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := s.Next(time.Now())
+	if next.IsZero() {
+		return next, fmt.Errorf("snapshot_schedule %q never fires", cfg.SnapshotSchedule)
+	}
+	return next, nil
+}
+
+// serviceUnit is a service definition for the snapshot daemon.
+type serviceUnit struct {
+	Kind    string `json:"kind"`
+	Path    string `json:"path"`
+	Content string `json:"content"`
+	Start   string `json:"start"`
+}
+
+// snapshotLabel names the snapshot daemon to launchd and systemd.
+const snapshotLabel = "io.github.delhombre.cxa.snapshot"
+
+// snapshotUnit returns the unit of kind "launchd" or "systemd", or the
+// one for this platform when kind is empty.
+func snapshotUnit(kind string) (*serviceUnit, error) {
+	if kind == "" {
+		kind = "systemd"
+		if runtime.GOOS == "darwin" {
+			kind = "launchd"
+		}
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case "launchd":
+		path := filepath.Join(home, "Library", "LaunchAgents", snapshotLabel+".plist")
+		return &serviceUnit{
+			Kind: kind,
+			Path: path,
+			Content: fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>%s</string>
+		<string>snapshot</string>
+		<string>daemon</string>
+		<string>--background</string>
+	</array>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<true/>
+	<key>ProcessType</key>
+	<string>Background</string>
+</dict>
+</plist>
+`, snapshotLabel, exe),
+			Start: "launchctl load -w " + path,
+		}, nil
+	case "systemd":
+		return &serviceUnit{
+			Kind: kind,
+			Path: filepath.Join(home, ".config", "systemd", "user", "cxa-snapshot.service"),
+			Content: fmt.Sprintf(`[Unit]
+Description=cxa scheduled snapshots
+
+[Service]
+ExecStart=%s snapshot daemon --background
+Restart=on-failure
+Nice=10
+
+[Install]
+WantedBy=default.target
+`, exe),
+			Start: "systemctl --user daemon-reload && systemctl --user enable --now cxa-snapshot.service",
+		}, nil
+	}
+	return nil, &usageError{err: fmt.Errorf("unknown unit kind '%s' (expected launchd or systemd)", kind)}
+}
+
+func init() {
+	snapshotScheduleCmd.Flags().BoolVar(&snapshotScheduleOff, "off", false, "stop scheduled snapshots")
+	snapshotUnitCmd.Flags().StringVar(&snapshotUnitKind, "kind", "", "launchd or systemd (default: this platform's)")
+	snapshotUnitCmd.Flags().BoolVar(&snapshotUnitInstall, "install", false, "write the unit instead of printing it")
+	snapshotCmd.AddCommand(snapshotScheduleCmd, snapshotRunCmd, snapshotDaemonCmd, snapshotUnitCmd)
+	rootCmd.AddCommand(snapshotCmd)
+}
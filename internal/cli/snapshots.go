@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/delhombre/cxa/internal/ui/table"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+var snapshotsCmd = &cobra.Command{
+	Use:   "snapshots <name>",
+	Short: "List earlier versions of an account",
+	Long: `List the snapshots kept of an account, newest first. A snapshot of the
+stored account is taken whenever saving, importing, or committing a clone
+replaces it with different files; the snapshots setting says how many are
+kept. Restore one with cxa rollback.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		if _, err := repo.Get(name); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		snapshots, err := repo.Snapshots(name)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(snapshots, func() {
+			if len(snapshots) == 0 {
+				out.Println(styles.MutedStyle.Render(fmt.Sprintf("No snapshots of %s yet.", name)))
+				return
+			}
+
+			out.Println(styles.RenderTitle("Snapshots of " + name))
+			out.Println()
+
+			t := table.New("SNAPSHOT", "TAKEN", "SIZE").Indent("  ")
+			for _, s := range snapshots {
+				t.Row(s.ID, humanize.Time(s.Time), humanize.Bytes(uint64(s.Size)))
+			}
+			out.Println(t.Style(func(row, col int) lipgloss.Style {
+				if row == -1 || col > 0 {
+					return styles.MutedStyle
+				}
+				return lipgloss.NewStyle()
+			}).Render())
+			out.Println()
+		})
+	},
+}
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback <name> <snapshot>",
+	Short: "Restore an account from a snapshot",
+	Long: `Replace the saved files of an account with one of its snapshots, as
+listed by cxa snapshots. The files being replaced are kept as a snapshot
+first, so a rollback can itself be undone. Rolling back the current account
+saves it first and switches to the restored files.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, id := args[0], args[1]
+		if err := repo.Rollback(name, id); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]string{"account": name, "snapshot": id}, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Rolled back %s to %s", name, id)))
+		})
+	},
+}
+
+// completeSnapshots completes an account name first and then the IDs of
+// its snapshots.
+func completeSnapshots(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return completeAccountNames(cmd, args, toComplete)
+	}
+	if len(args) > 1 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	snapshots, err := repo.Snapshots(args[0])
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var ids []string
+	for _, s := range snapshots {
+		if strings.HasPrefix(s.ID, toComplete) {
+			ids = append(ids, s.ID)
+		}
+	}
+	return ids, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+}
+
+func init() {
+	rollbackCmd.ValidArgsFunction = completeSnapshots
+	rootCmd.AddCommand(snapshotsCmd)
+	rootCmd.AddCommand(rollbackCmd)
+}
@@ -0,0 +1,167 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/delhombre/cxa/internal/auth"
+	"github.com/delhombre/cxa/internal/sharing"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/delhombre/cxa/internal/warnings"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show an overview of accounts, sharing, and credentials",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		state, err := repo.State()
+		if err != nil {
+			return err
+		}
+
+		accounts, err := repo.List()
+		if err != nil {
+			return err
+		}
+
+		usage, err := repo.DiskUsage()
+		if err != nil {
+			return err
+		}
+
+		manager := sharing.NewManagerWithPaths(paths)
+		if err := manager.LoadConfig(); err != nil {
+			return err
+		}
+
+		out.Println()
+		out.Println(styles.RenderTitle("Status"))
+		out.Println()
+
+		printField := func(label, value string) {
+			out.Printf("  %-10s %s\n", label+":", value)
+		}
+		none := styles.MutedStyle.Render("none")
+
+		if paths.HomeName() != "" {
+			printField("Home", paths.HomeName()+" "+styles.MutedStyle.Render("("+paths.Home+")"))
+		}
+
+		current := none
+		if state.Current != "" {
+			current = styles.CurrentAccountStyle.Render(state.Current)
+		}
+		printField("Current", current)
+
+		previous := none
+		if state.Previous != "" {
+			previous = state.Previous
+		}
+		printField("Previous", previous)
+
+		mode := string(manager.GetMode())
+		if manager.IsEnabled() {
+			mode = styles.SuccessStyle.Render(mode)
+		} else {
+			mode = styles.MutedStyle.Render(mode)
+		}
+		printField("Sharing", mode)
+
+		printField("Accounts", fmt.Sprintf("%d saved", len(accounts)))
+		quota := diskQuota()
+		disk := humanize.Bytes(uint64(usage))
+		if quota > 0 {
+			disk = fmt.Sprintf("%s of %s quota", disk, humanize.Bytes(uint64(quota)))
+			if usage > quota {
+				disk = styles.WarningStyle.Render(disk) + "\n" + fmt.Sprintf("  %-10s %s", "", styles.MutedStyle.Render(quotaHint))
+			}
+		}
+		printField("Disk", disk)
+		token := readToken(paths.AuthFile())
+		printField("Token", tokenStatus(token))
+
+		list, _ := warningStore().List()
+		if len(list) > 0 {
+			out.Println()
+			out.Printf("  %s\n", styles.WarningStyle.Render(fmt.Sprintf("%d warning(s):", len(list))))
+			printWarnings(list)
+			out.Println(styles.MutedStyle.Render("  Clear them with: cxa warnings ack"))
+		}
+		out.Println()
+
+		if list == nil {
+			list = []warnings.Warning{}
+		}
+		return out.Result(map[string]any{
+			"home":       paths.HomeName(),
+			"current":    state.Current,
+			"previous":   state.Previous,
+			"sharing":    manager.GetMode(),
+			"accounts":   len(accounts),
+			"disk_usage": usage,
+			"quota":      quota,
+			"token":      token,
+			"warnings":   list,
+		}, nil)
+	},
+}
+
+// tokenInfo describes the credentials in an auth.json.
+type tokenInfo struct {
+	LoggedIn  bool       `json:"logged_in"`
+	APIKey    bool       `json:"api_key"`
+	Email     string     `json:"email,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Expired   bool       `json:"expired"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// readToken inspects the auth.json at path.
+func readToken(path string) tokenInfo {
+	f, err := auth.Load(path)
+	if err != nil {
+		return tokenInfo{}
+	}
+
+	info := tokenInfo{LoggedIn: true}
+	id, err := f.Identity()
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+
+	info.APIKey = id.APIKey
+	info.Email = id.Email
+	info.Expired = id.Expired()
+	if !id.ExpiresAt.IsZero() {
+		info.ExpiresAt = &id.ExpiresAt
+	}
+	return info
+}
+
+// tokenStatus renders token expiry for humans.
+func tokenStatus(info tokenInfo) string {
+	switch {
+	case !info.LoggedIn:
+		return styles.MutedStyle.Render("not logged in")
+	case info.Error != "":
+		return styles.ErrorStyle.Render(info.Error)
+	case info.APIKey:
+		return "API key (no expiry)"
+	case info.ExpiresAt == nil:
+		return styles.MutedStyle.Render("unknown expiry")
+	case info.Expired:
+		return styles.WarningStyle.Render(fmt.Sprintf("expired %s", humanize.Time(*info.ExpiresAt)))
+	default:
+		return fmt.Sprintf("expires %s %s",
+			humanize.Time(*info.ExpiresAt),
+			styles.MutedStyle.Render("("+info.ExpiresAt.Format(time.DateTime)+")"),
+		)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+}
@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/huh"
+	"github.com/delhombre/cxa/internal/agent"
+	"github.com/delhombre/cxa/internal/config"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/delhombre/cxa/pkg/codex"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+var storageMoveYes bool
+
+var storageCmd = &cobra.Command{
+	Use:   "storage",
+	Short: "Manage where accounts are stored",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+var storageMoveCmd = &cobra.Command{
+	Use:   "move <path>",
+	Short: "Move the data directory somewhere else",
+	Long:  "Move saved accounts and shared sessions to another directory, such as an external drive or a synced folder. The data is copied and verified before the new location is recorded in the cxa config; only then is the old directory removed.",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkDefaultHome(); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		src := paths.DataDir
+		usage, err := repo.DiskUsage()
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		if shouldConfirm(storageMoveYes) && out.Interactive() {
+			proceed := false
+			err := huh.NewConfirm().
+				Title(fmt.Sprintf("Move %s of account data to %s?", humanize.Bytes(uint64(usage)), args[0])).
+				Description("From " + src).
+				Value(&proceed).
+				Run()
+			if err != nil {
+				return err
+			}
+			if !proceed {
+				out.Println(styles.MutedStyle.Render("Cancelled."))
+				return out.Result(map[string]bool{"cancelled": true}, nil)
+			}
+		}
+
+		out.Println(styles.MutedStyle.Render(fmt.Sprintf("Copying %s from %s...", humanize.Bytes(uint64(usage)), src)))
+		err = repo.MoveData(args[0], func(dataDir string) error {
+			return updateConfig(func(cfg *config.Config) error {
+				cfg.DataDir = dataDir
+				return nil
+			})
+		})
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		dst := paths.DataDir
+		return out.Result(map[string]any{"from": src, "to": dst, "size": usage}, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Moved account data to %s", dst)))
+		})
+	},
+}
+
+var storageReindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "Rebuild the index of account metadata",
+	Long:  "Rebuild index.json in the state directory, the cache of account metadata that keeps cxa list fast, from the account directories. It is kept up to date on its own; rebuild it if listings look wrong.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		n, err := repo.RebuildIndex()
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]int{"accounts": n}, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Indexed %d accounts", n)))
+		})
+	},
+}
+
+var storageMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Move data out of $HOME into the XDG directories",
+	Long: `Move ~/codex-data to $XDG_DATA_HOME/cxa and ~/.codex-switch to
+$XDG_STATE_HOME/cxa (by default ~/.local/share/cxa and ~/.local/state/cxa),
+the layout fresh installs use. A data directory moved elsewhere with cxa
+storage move stays where it is. A running cxa agent is stopped.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkDefaultHome(); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		home, err := os.UserHomeDir()
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		legacy, xdg := codex.NewPathsAt(home), codex.NewXDGPathsAt(home)
+		if paths.StateDir != legacy.StateDir {
+			return out.Result(map[string]any{"migrated": false, "state_dir": paths.StateDir, "data_dir": paths.DataDir}, func() {
+				out.Println(styles.MutedStyle.Render("Already using the XDG directories, or a state directory set by " + codex.EnvStateDir + "."))
+			})
+		}
+
+		_ = agent.Stop(paths.AgentSocket())
+		if paths.DataDir == legacy.DataDir {
+			out.Println(styles.MutedStyle.Render(fmt.Sprintf("Moving %s to %s...", legacy.DataDir, xdg.DataDir)))
+			err = repo.MoveData(xdg.DataDir, func(dataDir string) error {
+				return updateConfig(func(cfg *config.Config) error {
+					cfg.DataDir = dataDir
+					return nil
+				})
+			})
+		}
+		if err == nil {
+			out.Println(styles.MutedStyle.Render(fmt.Sprintf("Moving %s to %s...", legacy.StateDir, xdg.StateDir)))
+			err = repo.MoveState(xdg.StateDir)
+		}
+		if err == nil {
+			// The XDG data directory is the default now
+			err = updateConfig(func(cfg *config.Config) error {
+				if cfg.DataDir == xdg.DataDir {
+					cfg.DataDir = ""
+				}
+				return nil
+			})
+		}
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]any{"migrated": true, "state_dir": paths.StateDir, "data_dir": paths.DataDir}, func() {
+			out.Println(styles.RenderSuccess("Moved cxa data into the XDG directories"))
+		})
+	},
+}
+
+func init() {
+	storageMoveCmd.Flags().BoolVarP(&storageMoveYes, "yes", "y", false, "move without confirmation")
+	storageCmd.AddCommand(storageMoveCmd)
+	storageCmd.AddCommand(storageReindexCmd)
+	storageCmd.AddCommand(storageMigrateCmd)
+	rootCmd.AddCommand(storageCmd)
+}
@@ -0,0 +1,271 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/delhombre/cxa/internal/config"
+	"github.com/delhombre/cxa/internal/remote"
+	"github.com/delhombre/cxa/internal/storage"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var (
+	syncDryRun   bool
+	syncPrefer   string
+	syncAccounts []string
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync [remote]",
+	Short: "Sync saved accounts with a remote",
+	Long: `Push and pull saved accounts to a remote so several machines share them.
+
+A remote is a directory, such as a synced folder or mounted drive,
+host:path on a machine reached with rsync over SSH, or s3://bucket/prefix
+in an S3-compatible bucket. Add one with cxa sync remote add. Accounts changed on one side since the last sync are
+copied to the other; accounts changed on both sides are conflicts, left
+alone unless --prefer picks a side.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch syncPrefer {
+		case "", storage.PreferLocal, storage.PreferRemote, storage.PreferNewer:
+		default:
+			err := fmt.Errorf("invalid --prefer %q: use local, remote or newer", syncPrefer)
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		name, err := pickRemote(cfg, args)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		t, err := remote.Open(cfg.Remotes[name])
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		if syncDryRun {
+			out.Printf("%s Planning sync with %s (dry run)...\n", styles.Caret, name)
+		} else {
+			out.Printf("%s Syncing with %s...\n", styles.Caret, name)
+		}
+		results, err := repo.Sync(t, name, storage.SyncOptions{
+			Accounts: syncAccounts,
+			Prefer:   syncPrefer,
+			DryRun:   syncDryRun,
+		})
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		var conflicts, failed int
+		for _, r := range results {
+			switch {
+			case r.Error != "":
+				failed++
+			case r.Action == storage.SyncConflict:
+				conflicts++
+			}
+		}
+
+		err = out.Result(map[string]any{"remote": name, "dry_run": syncDryRun, "accounts": results}, func() {
+			for _, r := range results {
+				out.Println(renderSyncResult(r))
+			}
+			out.Println()
+			switch {
+			case failed > 0:
+				out.Println(styles.RenderError(fmt.Sprintf("%d accounts failed to sync", failed)))
+			case conflicts > 0:
+				out.Println(styles.RenderWarning(fmt.Sprintf("%d accounts changed on both sides; rerun with --prefer local, remote or newer", conflicts)))
+			case syncDryRun:
+				out.Println(styles.MutedStyle.Render("Dry run; nothing was changed."))
+			default:
+				out.Println(styles.RenderSuccess(fmt.Sprintf("Synced with %s", name)))
+			}
+		})
+		if err != nil {
+			return err
+		}
+		switch {
+		case failed > 0:
+			return fmt.Errorf("%d accounts failed to sync", failed)
+		case conflicts > 0:
+			return fmt.Errorf("%d sync conflicts", conflicts)
+		}
+		return nil
+	},
+}
+
+// pickRemote returns the remote named in args, or the only configured
+// remote when none is named.
+func pickRemote(cfg *config.Config, args []string) (string, error) {
+	if len(args) == 1 {
+		if _, ok := cfg.Remotes[args[0]]; !ok {
+			return "", fmt.Errorf("remote '%s' not found; add it with cxa sync remote add", args[0])
+		}
+		return args[0], nil
+	}
+	switch len(cfg.Remotes) {
+	case 0:
+		return "", errors.New("no remotes configured; add one with cxa sync remote add <name> <url>")
+	case 1:
+		for name := range cfg.Remotes {
+			return name, nil
+		}
+	}
+	return "", errors.New("several remotes configured; name the one to sync with")
+}
+
+func renderSyncResult(r storage.SyncResult) string {
+	name := styles.BoldStyle.Render(r.Account)
+	if r.Error != "" {
+		return fmt.Sprintf("  %s %s %s", styles.CrossMark, name, styles.ErrorStyle.Render(r.Error))
+	}
+
+	var line string
+	switch r.Action {
+	case storage.SyncNone:
+		line = fmt.Sprintf("  %s %s %s", styles.CheckMark, name, styles.MutedStyle.Render("in sync"))
+	case storage.SyncPush:
+		line = fmt.Sprintf("  %s %s %s", styles.Arrow, name, "pushed")
+	case storage.SyncPull:
+		line = fmt.Sprintf("  %s %s %s", styles.Arrow, name, "pulled")
+	case storage.SyncConflict:
+		return fmt.Sprintf("  %s %s %s", styles.CrossMark, name, styles.WarningStyle.Render("changed on both sides"))
+	}
+	if r.Resolved {
+		line += styles.MutedStyle.Render(" (conflict resolved)")
+	}
+	return line
+}
+
+var syncRemoteCmd = &cobra.Command{
+	Use:   "remote",
+	Short: "Manage sync remotes",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+var syncRemoteAddCmd = &cobra.Command{
+	Use:   "add <name> <url>",
+	Short: "Add a sync remote",
+	Long:  "Add a sync remote: a local directory such as ~/Dropbox/cxa, or host:path (or ssh://host/path) for a directory on another machine reached with rsync over SSH, or s3://bucket/prefix for an S3, MinIO, or Google Cloud Storage bucket.",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, url := args[0], args[1]
+		if _, err := remote.Open(url); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		err := updateRemotes(func(remotes map[string]string) error {
+			if _, ok := remotes[name]; ok {
+				return fmt.Errorf("remote '%s' already exists", name)
+			}
+			remotes[name] = url
+			return nil
+		})
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]string{"name": name, "url": url}, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Added remote '%s' (%s)", name, url)))
+		})
+	},
+}
+
+var syncRemoteRemoveCmd = &cobra.Command{
+	Use:     "remove <name>",
+	Aliases: []string{"rm"},
+	Short:   "Remove a sync remote",
+	Long:    "Remove a sync remote from the cxa config. Nothing on the remote itself is deleted.",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		err := updateRemotes(func(remotes map[string]string) error {
+			if _, ok := remotes[name]; !ok {
+				return fmt.Errorf("remote '%s' not found", name)
+			}
+			delete(remotes, name)
+			return nil
+		})
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]string{"removed": name}, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Removed remote '%s'", name)))
+		})
+	},
+}
+
+var syncRemoteListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List sync remotes",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		names := make([]string, 0, len(cfg.Remotes))
+		for name := range cfg.Remotes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		remotes := cfg.Remotes
+		if remotes == nil {
+			remotes = map[string]string{}
+		}
+		return out.Result(remotes, func() {
+			if len(names) == 0 {
+				out.Println(styles.MutedStyle.Render("No remotes configured. Add one with: cxa sync remote add <name> <url>"))
+				return
+			}
+			for _, name := range names {
+				out.Printf("  %s %s %s\n", styles.Bullet, styles.BoldStyle.Render(name), styles.MutedStyle.Render(cfg.Remotes[name]))
+			}
+		})
+	},
+}
+
+// updateRemotes applies fn to the configured remotes and saves the config.
+func updateRemotes(fn func(remotes map[string]string) error) error {
+	return updateConfig(func(cfg *config.Config) error {
+		if cfg.Remotes == nil {
+			cfg.Remotes = make(map[string]string)
+		}
+		return fn(cfg.Remotes)
+	})
+}
+
+func init() {
+	syncCmd.Flags().BoolVar(&syncDryRun, "dry-run", false, "show what would be pushed and pulled without changing anything")
+	syncCmd.Flags().StringVar(&syncPrefer, "prefer", "", "resolve conflicts in favour of local, remote or newer")
+	syncCmd.Flags().StringArrayVar(&syncAccounts, "account", nil, "sync only this account (repeatable)")
+	syncRemoteCmd.AddCommand(syncRemoteAddCmd)
+	syncRemoteCmd.AddCommand(syncRemoteRemoveCmd)
+	syncRemoteCmd.AddCommand(syncRemoteListCmd)
+	syncCmd.AddCommand(syncRemoteCmd)
+	rootCmd.AddCommand(syncCmd)
+}
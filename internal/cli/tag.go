@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/delhombre/cxa/internal/account"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var tagCmd = &cobra.Command{
+	Use:   "tag",
+	Short: "Label accounts with tags",
+	Long:  "Label accounts with tags such as work or client, then filter with cxa list --tag.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+var tagAddCmd = &cobra.Command{
+	Use:   "add <name> <tag>...",
+	Short: "Tag an account",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateTags(args[0], args[1:], (*account.Account).AddTag)
+	},
+}
+
+var tagRemoveCmd = &cobra.Command{
+	Use:     "remove <name> <tag>...",
+	Short:   "Remove tags from an account",
+	Aliases: []string{"rm"},
+	Args:    cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateTags(args[0], args[1:], (*account.Account).RemoveTag)
+	},
+}
+
+// updateTags applies change to each tag of the named account and reports
+// the resulting tags.
+func updateTags(name string, tags []string, change func(*account.Account, string) bool) error {
+	for _, tag := range tags {
+		if err := account.ValidateTag(tag); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+	}
+
+	acc, err := repo.UpdateMetadata(name, func(acc *account.Account) {
+		for _, tag := range tags {
+			change(acc, tag)
+		}
+	})
+	if err != nil {
+		out.Println(styles.RenderError(err.Error()))
+		return err
+	}
+
+	result := acc.Tags
+	if result == nil {
+		result = []string{}
+	}
+	return out.Result(map[string]any{"account": name, "tags": result}, func() {
+		if len(acc.Tags) == 0 {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("%s has no tags", name)))
+			return
+		}
+		out.Println(styles.RenderSuccess(fmt.Sprintf("%s is tagged %s", name, strings.Join(acc.Tags, ", "))))
+	})
+}
+
+// completeTags completes an account name first and then the tags it
+// already carries.
+func completeTags(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return completeAccountNames(cmd, args, toComplete)
+	}
+
+	acc, err := repo.Get(args[0])
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var tags []string
+	for _, tag := range acc.Tags {
+		if strings.HasPrefix(tag, toComplete) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, cobra.ShellCompDirectiveNoFileComp
+}
+
+// formatTags renders tags for listings, or an empty string without any.
+func formatTags(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return "#" + strings.Join(tags, " #")
+}
+
+func init() {
+	tagAddCmd.ValidArgsFunction = completeAccountNames
+	tagRemoveCmd.ValidArgsFunction = completeTags
+	tagCmd.AddCommand(tagAddCmd, tagRemoveCmd)
+	rootCmd.AddCommand(tagCmd)
+}
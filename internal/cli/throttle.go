@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"github.com/delhombre/cxa/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ioLimitFlag    string
+	backgroundFlag bool
+)
+
+// applyIOLimit sets the repository copy throttle. An explicit --io-limit
+// wins; otherwise --background applies the io_limit from the cxa config,
+// which is how scheduled jobs and daemons keep off the disk while codex is
+// busy.
+func applyIOLimit(cmd *cobra.Command, cfg *config.Config) error {
+	limit := ioLimitFlag
+	if !cmd.Flags().Changed("io-limit") && backgroundFlag {
+		limit = cfg.IOLimit
+	}
+
+	bytesPerSec, err := config.ParseIOLimit(limit)
+	if err != nil {
+		return err
+	}
+	repo.SetIOLimit(bytesPerSec)
+	return nil
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&ioLimitFlag, "io-limit", "", "cap copy throughput, e.g. 20MB (per second)")
+	rootCmd.PersistentFlags().BoolVar(&backgroundFlag, "background", false, "run as a background job using the configured io_limit")
+}
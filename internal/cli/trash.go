@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/delhombre/cxa/internal/ui/table"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+var (
+	trashRestoreAs string
+	trashEmptyYes  bool
+)
+
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "Restore or remove deleted accounts",
+	Long: `Deleted accounts are kept in the trash, with their snapshots and
+credentials, for the number of days set by trash_days, then removed for
+good the next time an account is deleted.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+var trashListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List deleted accounts",
+	Aliases: []string{"ls"},
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := repo.Trash()
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(entries, func() {
+			if len(entries) == 0 {
+				out.Println(styles.MutedStyle.Render("The trash is empty."))
+				return
+			}
+
+			out.Println(styles.RenderTitle("Trash"))
+			out.Println()
+
+			t := table.New("ID", "ACCOUNT", "DELETED", "SIZE").Indent("  ")
+			for _, e := range entries {
+				t.Row(e.ID, e.Name, humanize.Time(e.DeletedAt), humanize.Bytes(uint64(e.Size)))
+			}
+			out.Println(t.Style(func(row, col int) lipgloss.Style {
+				if row == -1 || col > 1 {
+					return styles.MutedStyle
+				}
+				return lipgloss.NewStyle()
+			}).Render())
+			out.Println()
+		})
+	},
+}
+
+var trashRestoreCmd = &cobra.Command{
+	Use:   "restore <id>",
+	Short: "Put a deleted account back",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		acc, err := repo.RestoreTrash(args[0], trashRestoreAs)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(acc, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Restored account: %s", acc.Name)))
+		})
+	},
+}
+
+var trashEmptyCmd = &cobra.Command{
+	Use:   "empty",
+	Short: "Remove every deleted account for good",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if shouldConfirm(trashEmptyYes) {
+			confirm := false
+			form := huh.NewForm(
+				huh.NewGroup(
+					huh.NewConfirm().
+						Title("Empty the trash?").
+						Description("Deleted accounts can no longer be restored.").
+						Value(&confirm),
+				),
+			)
+			if err := form.Run(); err != nil {
+				return err
+			}
+			if !confirm {
+				return out.Result(map[string]bool{"cancelled": true}, func() {
+					out.Println(styles.MutedStyle.Render("Cancelled."))
+				})
+			}
+		}
+
+		n, err := repo.EmptyTrash()
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]int{"removed": n}, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Removed %d deleted accounts", n)))
+		})
+	},
+}
+
+// completeTrash completes the IDs of deleted accounts.
+func completeTrash(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	entries, err := repo.Trash()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var ids []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.ID, toComplete) {
+			ids = append(ids, e.ID)
+		}
+	}
+	return ids, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+}
+
+func init() {
+	trashRestoreCmd.Flags().StringVar(&trashRestoreAs, "as", "", "restore under another name")
+	trashRestoreCmd.ValidArgsFunction = completeTrash
+	trashEmptyCmd.Flags().BoolVarP(&trashEmptyYes, "yes", "y", false, "empty without asking")
+	trashCmd.AddCommand(trashListCmd, trashRestoreCmd, trashEmptyCmd)
+	rootCmd.AddCommand(trashCmd)
+}
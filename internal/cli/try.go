@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/charmbracelet/huh"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var (
+	tryCommit  bool
+	tryDiscard bool
+)
+
+var tryCmd = &cobra.Command{
+	Use:   "try <name>",
+	Short: "Open a shell on a scratch copy of an account",
+	Long: "Open a subshell with CODEX_HOME pointing at a scratch copy of the account. Experiment freely; on exit, choose whether to commit the changes back to the saved account or discard them.\n\n" +
+		"Sessions and history shared through session sharing stay shared and are not isolated.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		if tryCommit && tryDiscard {
+			return fmt.Errorf("--commit and --discard cannot be combined")
+		}
+
+		// Start from the live state when trying the active account
+		current, _ := repo.Current()
+		if name == current {
+			if _, err := repo.Save(name); err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+		}
+
+		dir, err := repo.Clone(name)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		defer os.RemoveAll(dir)
+
+		shell := os.Getenv("SHELL")
+		if shell == "" {
+			shell = "/bin/sh"
+		}
+
+		out.Printf("%s Trying %s in a scratch copy. Exit the shell to finish.\n",
+			styles.Caret,
+			styles.PrimaryStyle.Render(name),
+		)
+		out.Println(styles.MutedStyle.Render("  CODEX_HOME=" + dir))
+
+		sub := exec.Command(shell)
+		sub.Env = append(os.Environ(), "CODEX_HOME="+dir, "CXA_TRY="+name)
+		sub.Stdin = os.Stdin
+		sub.Stdout = os.Stdout
+		sub.Stderr = os.Stderr
+		if err := sub.Run(); err != nil {
+			// A non-zero exit from the last command in the shell is not a
+			// reason to lose the experiment; still offer to commit
+			if _, ok := err.(*exec.ExitError); !ok {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+		}
+
+		changed, err := repo.CloneChanged(name, dir)
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		if !changed {
+			return out.Result(map[string]any{"account": name, "changed": false, "committed": false}, func() {
+				out.Println(styles.MutedStyle.Render("No changes; nothing to commit."))
+			})
+		}
+
+		commit := tryCommit
+		if !tryCommit && !tryDiscard {
+			if !out.Interactive() {
+				out.Println(styles.RenderWarning("Changes discarded (pass --commit to keep them)"))
+			} else if err := huh.NewConfirm().
+				Title(fmt.Sprintf("Commit changes back to %s?", name)).
+				Affirmative("Commit").
+				Negative("Discard").
+				Value(&commit).
+				Run(); err != nil {
+				return err
+			}
+		}
+
+		if commit {
+			if err := repo.Commit(name, dir); err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+			// Bring ~/.codex in line when the active account changed
+			if name == current {
+				if err := repo.Activate(name); err != nil {
+					out.Println(styles.RenderError(err.Error()))
+					return err
+				}
+			}
+		}
+
+		return out.Result(map[string]any{"account": name, "changed": true, "committed": commit}, func() {
+			if commit {
+				out.Println(styles.RenderSuccess(fmt.Sprintf("Committed changes to %s", name)))
+			} else {
+				out.Println(styles.MutedStyle.Render("Changes discarded."))
+			}
+		})
+	},
+}
+
+func init() {
+	tryCmd.Flags().BoolVar(&tryCommit, "commit", false, "commit changes without asking")
+	tryCmd.Flags().BoolVar(&tryDiscard, "discard", false, "discard changes without asking")
+	rootCmd.AddCommand(tryCmd)
+}
@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/huh"
+	"github.com/delhombre/cxa/internal/account"
+	"github.com/delhombre/cxa/internal/sharing"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var (
+	uninstallYes   bool
+	uninstallPurge bool
+)
+
+// completionMarker identifies completion scripts generated by cxa.
+var completionMarker = []byte("cxa")
+
+var uninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Remove cxa state and leave a plain ~/.codex behind",
+	Long:  "Undo everything cxa set up: restore the most recent account into ~/.codex, replace sharing symlinks with real copies, remove installed completions, and delete or keep saved accounts.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		recent := mostRecentAccount()
+		restore := recent != ""
+		keepData := !uninstallPurge
+
+		if !uninstallYes {
+			var fields []huh.Field
+			if recent != "" {
+				fields = append(fields, huh.NewConfirm().
+					Title(fmt.Sprintf("Restore '%s' into ~/.codex?", recent)).
+					Description("Leaves you logged in with your most recently used account.").
+					Value(&restore))
+			}
+			fields = append(fields, huh.NewConfirm().
+				Title("Keep saved accounts in "+paths.DataDir+"?").
+				Description("Choose no to delete every saved account permanently.").
+				Affirmative("Keep").
+				Negative("Delete").
+				Value(&keepData))
+
+			proceed := false
+			fields = append(fields, huh.NewConfirm().Title("Uninstall cxa now?").Value(&proceed))
+
+			if err := huh.NewForm(huh.NewGroup(fields...)).Run(); err != nil {
+				return err
+			}
+			if !proceed {
+				out.Println(styles.MutedStyle.Render("Cancelled."))
+				return out.Result(map[string]bool{"cancelled": true}, nil)
+			}
+		}
+
+		var removed []string
+
+		if restore {
+			out.Printf("%s Restoring %s...\n", styles.Caret, styles.PrimaryStyle.Render(recent))
+			if err := repo.Activate(recent); err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+		}
+
+		// A linked ~/.codex points into the data directory; copy it back
+		if err := repo.Materialize(); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		// Replace sharing symlinks with real copies so ~/.codex stands alone
+		manager := sharing.NewManagerWithPaths(paths)
+		if err := manager.LoadConfig(); err == nil && manager.IsEnabled() {
+			out.Printf("%s Replacing sharing symlinks with local copies...\n", styles.Caret)
+			if err := manager.Disable(); err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+			removed = append(removed, "sharing symlinks")
+		}
+
+		for _, path := range completionPaths() {
+			data, err := os.ReadFile(path)
+			if err != nil || !bytes.Contains(data, completionMarker) {
+				continue
+			}
+			if err := os.Remove(path); err == nil {
+				removed = append(removed, path)
+			}
+		}
+
+		if err := os.RemoveAll(paths.StateDir); err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		removed = append(removed, paths.StateDir)
+
+		var remaining []string
+		if keepData {
+			remaining = append(remaining, paths.DataDir+" (saved accounts)")
+		} else {
+			if err := os.RemoveAll(paths.DataDir); err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+			removed = append(removed, paths.DataDir)
+		}
+		if paths.CodexExists() {
+			remaining = append(remaining, paths.Home+" (plain Codex home)")
+		}
+		if exe, err := os.Executable(); err == nil {
+			remaining = append(remaining, exe+" (the cxa binary; remove it with your package manager)")
+		}
+
+		out.Println()
+		out.Println(styles.RenderSuccess("cxa uninstalled"))
+		out.Println()
+		out.Println("  Removed:")
+		for _, item := range removed {
+			out.Printf("    %s %s\n", styles.CheckMark, item)
+		}
+		out.Println("  Remaining:")
+		for _, item := range remaining {
+			out.Printf("    %s %s\n", styles.Circle, item)
+		}
+		out.Println()
+
+		return out.Result(map[string]any{"removed": removed, "remaining": remaining}, nil)
+	},
+}
+
+// mostRecentAccount returns the current account, or else the most recently
+// used saved account, or "" if there are none.
+func mostRecentAccount() string {
+	if current, _ := repo.Current(); current != "" {
+		if _, err := repo.AccountDir(current); err == nil {
+			return current
+		}
+	}
+
+	accounts, err := repo.List()
+	if err != nil || len(accounts) == 0 {
+		return ""
+	}
+	account.SortRecent(accounts)
+	return accounts[0].Name
+}
+
+func init() {
+	uninstallCmd.Flags().BoolVarP(&uninstallYes, "yes", "y", false, "do not ask; restore the most recent account and keep saved accounts")
+	uninstallCmd.Flags().BoolVar(&uninstallPurge, "purge", false, "delete saved accounts too")
+	rootCmd.AddCommand(uninstallCmd)
+}
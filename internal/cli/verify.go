@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/delhombre/cxa/internal/storage"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [name]",
+	Short: "Check saved accounts for corruption or tampering",
+	Long: "Compare saved accounts with the manifest of file hashes recorded when they were last saved, reporting missing, modified, and unexpected files. Without a name, every account is checked.\n\n" +
+		"~/.codex is also compared with the current account. Codex changes some files during normal use, such as refreshing the token in auth.json, so differences there are reported as warnings and do not fail the check.",
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var names []string
+		if len(args) == 1 {
+			names = args
+		} else {
+			accounts, err := repo.List()
+			if err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+			for _, acc := range accounts {
+				names = append(names, acc.Name)
+			}
+		}
+
+		out.Println()
+		out.Println(styles.RenderTitle("Verify"))
+		out.Println()
+
+		results := []*storage.VerifyResult{}
+		failed := 0
+		for _, name := range names {
+			result, err := repo.Verify(name)
+			if err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+			results = append(results, result)
+			if !printVerifyResult(result, name, false) {
+				failed++
+			}
+		}
+
+		current, _ := repo.Current()
+		var active *storage.VerifyResult
+		if current != "" && (len(args) == 0 || args[0] == current) {
+			var err error
+			if active, err = repo.VerifyActive(); err != nil {
+				out.Println(styles.RenderError(err.Error()))
+				return err
+			}
+			printVerifyResult(active, "~/.codex", true)
+		}
+		out.Println()
+
+		err := out.Result(map[string]any{"accounts": results, "active": active}, nil)
+		if err != nil {
+			return err
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d account(s) failed verification", failed)
+		}
+		return nil
+	},
+}
+
+// printVerifyResult renders one verification and reports whether it
+// passed. Issues in the live home are warnings rather than failures.
+func printVerifyResult(result *storage.VerifyResult, label string, live bool) bool {
+	switch {
+	case !result.HasManifest:
+		out.Printf("  %s %s %s\n", styles.Circle, label, styles.MutedStyle.Render("(no manifest; save the account to create one)"))
+		return true
+	case result.OK():
+		out.Printf("  %s %s\n", styles.CheckMark, label)
+		return true
+	}
+
+	mark, note := styles.CrossMark, fmt.Sprintf("(%d problem(s))", len(result.Issues))
+	if live {
+		mark, note = styles.WarningStyle.Render("!"), fmt.Sprintf("(%d change(s) since %s was saved)", len(result.Issues), result.Account)
+	}
+	out.Printf("  %s %s %s\n", mark, label, styles.MutedStyle.Render(note))
+	for _, issue := range result.Issues {
+		out.Printf("      %s %s\n", styles.MutedStyle.Render(issue.Kind+":"), issue.Path)
+	}
+	return live
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+}
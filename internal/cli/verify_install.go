@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/delhombre/cxa/internal/doctor"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var verifyInstallSHA256 string
+
+// completionPaths lists the usual per-user locations for shell completion
+// scripts, where the README suggests installing them.
+func completionPaths() []string {
+	home, _ := os.UserHomeDir()
+	return []string{
+		filepath.Join(home, ".local", "share", "bash-completion", "completions", "cxa"),
+		filepath.Join(home, ".bash_completion.d", "cxa"),
+		filepath.Join(home, ".zfunc", "_cxa"),
+		filepath.Join(home, ".zsh", "completions", "_cxa"),
+		filepath.Join(home, ".config", "fish", "completions", "cxa.fish"),
+	}
+}
+
+// systemCompletionPaths lists where package managers install completion
+// scripts.
+var systemCompletionPaths = []string{
+	"/opt/homebrew/share/zsh/site-functions/_cxa",
+	"/opt/homebrew/etc/bash_completion.d/cxa",
+	"/opt/homebrew/share/fish/vendor_completions.d/cxa.fish",
+	"/usr/local/share/zsh/site-functions/_cxa",
+	"/usr/local/etc/bash_completion.d/cxa",
+	"/usr/local/share/fish/vendor_completions.d/cxa.fish",
+	"/usr/share/bash-completion/completions/cxa",
+	"/usr/share/zsh/site-functions/_cxa",
+	"/usr/share/fish/vendor_completions.d/cxa.fish",
+}
+
+var verifyInstallCmd = &cobra.Command{
+	Use:   "verify-install",
+	Short: "Check that cxa is installed correctly",
+	Long:  "Verify the binary, look for other cxa copies shadowing it on PATH, check that completions are installed and the data directories are writable. Intended for package-manager post-install hooks; combine with --json for machine-readable results. Exits non-zero if a problem is found.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		exe, err := os.Executable()
+		if err != nil {
+			return err
+		}
+
+		findings := doctor.New(repo).VerifyInstall(doctor.InstallOptions{
+			Executable:      exe,
+			Path:            os.Getenv("PATH"),
+			SHA256:          verifyInstallSHA256,
+			CompletionPaths: append(completionPaths(), systemCompletionPaths...),
+		})
+
+		out.Println(styles.RenderTitle("Install Verification"))
+		out.Println()
+
+		results, problems, _ := printFindings(findings)
+		if err := out.Result(results, nil); err != nil {
+			return err
+		}
+		if problems > 0 {
+			return fmt.Errorf("install verification found %d problem(s)", problems)
+		}
+		return nil
+	},
+}
+
+func init() {
+	verifyInstallCmd.Flags().StringVar(&verifyInstallSHA256, "sha256", "", "expected checksum of the cxa binary")
+	rootCmd.AddCommand(verifyInstallCmd)
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/delhombre/cxa/internal/warnings"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+func warningStore() *warnings.Store {
+	return warnings.NewStore(paths.WarningsFile())
+}
+
+// printWarnings renders warnings as an indented list.
+func printWarnings(list []warnings.Warning) {
+	for _, w := range list {
+		seen := humanize.Time(w.LastSeen)
+		if w.Count > 1 {
+			seen = fmt.Sprintf("%dx, last %s", w.Count, seen)
+		}
+		out.Printf("  %s %s %s\n",
+			styles.WarningStyle.Render("!"),
+			w.Message,
+			styles.MutedStyle.Render(fmt.Sprintf("(%s, %s)", w.Source, seen)),
+		)
+	}
+}
+
+var warningsCmd = &cobra.Command{
+	Use:   "warnings",
+	Short: "Show warnings recorded by previous commands",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		list, err := warningStore().List()
+		if err != nil {
+			return err
+		}
+
+		if list == nil {
+			list = []warnings.Warning{}
+		}
+
+		return out.Result(list, func() {
+			if len(list) == 0 {
+				out.Println(styles.MutedStyle.Render("No warnings."))
+				return
+			}
+
+			out.Println(styles.RenderTitle("Warnings"))
+			out.Println()
+			printWarnings(list)
+			out.Println()
+			out.Println(styles.MutedStyle.Render("Clear them with: cxa warnings ack"))
+		})
+	},
+}
+
+var warningsAckCmd = &cobra.Command{
+	Use:   "ack",
+	Short: "Acknowledge and clear all warnings",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		n, err := warningStore().Ack()
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]int{"acknowledged": n}, func() {
+			out.Println(styles.RenderSuccess(fmt.Sprintf("Acknowledged %d warning(s)", n)))
+		})
+	},
+}
+
+func init() {
+	warningsCmd.AddCommand(warningsAckCmd)
+	rootCmd.AddCommand(warningsCmd)
+}
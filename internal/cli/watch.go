@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/delhombre/cxa/internal/storage"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchInterval time.Duration
+	watchSettle   time.Duration
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Keep the current account saved as ~/.codex changes",
+	Long: "Watch ~/.codex and save it into the current account shortly after every change, so a crash or an unexpected switch never loses the latest sessions. " +
+		"Runs until interrupted; leave it in a terminal or start it from your login items. Saves honour --io-limit and io_limit in the cxa config.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		current, _ := repo.Current()
+		if current == "" {
+			out.Println(styles.RenderWarning("No current account yet; changes are saved once you switch to one."))
+		}
+		out.Printf("%s Watching ~/.codex (press Ctrl+C to stop)...\n", styles.Caret)
+
+		saves, failures := 0, 0
+		err := repo.Watch(ctx, storage.WatchOptions{
+			Interval: watchInterval,
+			Settle:   watchSettle,
+			OnSave: func(name string, err error) {
+				stamp := styles.MutedStyle.Render(time.Now().Format("15:04:05"))
+				if err != nil {
+					failures++
+					out.Printf("  %s %s %s\n", stamp, styles.CrossMark, styles.ErrorStyle.Render(fmt.Sprintf("failed to save %s: %v", name, err)))
+					return
+				}
+				saves++
+				out.Printf("  %s %s Saved %s\n", stamp, styles.CheckMark, styles.BoldStyle.Render(name))
+			},
+		})
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]int{"saves": saves, "failures": failures}, func() {
+			out.Println(styles.MutedStyle.Render(fmt.Sprintf("Stopped after %d saves.", saves)))
+		})
+	},
+}
+
+func init() {
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", 2*time.Second, "how often to check ~/.codex for changes")
+	watchCmd.Flags().DurationVar(&watchSettle, "settle", 10*time.Second, "how long ~/.codex must stay unchanged before saving")
+	rootCmd.AddCommand(watchCmd)
+}
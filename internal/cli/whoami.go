@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/delhombre/cxa/internal/auth"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/spf13/cobra"
+)
+
+var whoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show who the active credentials belong to",
+	Long:  "Decode ~/.codex/auth.json to show the email, organization, plan, and token expiry of the live credentials, and check them against the account cxa believes is current.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := auth.Load(paths.AuthFile())
+		if err != nil {
+			err := fmt.Errorf("no active credentials: %w", err)
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+		id, err := f.Identity()
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		current, _ := repo.Current()
+		matches := matchingAccounts(id)
+		mismatch := current != "" && !slices.Contains(matches, current)
+
+		var expiresAt *time.Time
+		if !id.ExpiresAt.IsZero() {
+			expiresAt = &id.ExpiresAt
+		}
+
+		return out.Result(map[string]any{
+			"email":        id.Email,
+			"organization": id.Organization,
+			"plan":         id.Plan,
+			"account_id":   id.AccountID,
+			"api_key":      id.APIKey,
+			"expires_at":   expiresAt,
+			"expired":      id.Expired(),
+			"current":      current,
+			"matches":      matches,
+			"mismatch":     mismatch,
+		}, func() {
+			if out.Quiet() {
+				out.Essential(id.Email)
+				return
+			}
+			out.Println()
+			out.Println(styles.RenderTitle("Active Credentials"))
+			out.Println()
+
+			printField := func(label, value string) {
+				if value == "" {
+					value = styles.MutedStyle.Render("unknown")
+				}
+				out.Printf("  %-13s %s\n", label+":", value)
+			}
+			if id.APIKey {
+				printField("API key", "…"+id.KeySuffix)
+			} else {
+				printField("Email", id.Email)
+				printField("Organization", id.Organization)
+				printField("Plan", id.Plan)
+				printField("Account ID", id.AccountID)
+			}
+			printField("Token", tokenStatus(readToken(paths.AuthFile())))
+
+			tracked := current
+			if tracked == "" {
+				tracked = styles.MutedStyle.Render("none")
+			}
+			printField("cxa current", tracked)
+			out.Println()
+
+			switch {
+			case mismatch && len(matches) > 0:
+				out.Println(styles.RenderWarning(fmt.Sprintf("These credentials belong to '%s', not '%s'.", matches[0], current)))
+				out.Println(styles.MutedStyle.Render(fmt.Sprintf("  Fix tracking with: cxa switch %s", matches[0])))
+			case mismatch:
+				out.Println(styles.RenderWarning(fmt.Sprintf("These credentials do not match the saved '%s' account.", current)))
+				out.Println(styles.MutedStyle.Render(fmt.Sprintf("  Update it with: cxa save %s", current)))
+			case current == "" && len(matches) > 0:
+				out.Println(styles.MutedStyle.Render(fmt.Sprintf("These credentials match the saved '%s' account.", matches[0])))
+			case current == "":
+				out.Println(styles.MutedStyle.Render("These credentials are not saved yet. Save them with: cxa save <name>"))
+			}
+		})
+	},
+}
+
+// matchingAccounts returns the saved accounts whose stored credentials are
+// the same identity as id.
+func matchingAccounts(id *auth.Identity) []string {
+	matches := []string{}
+	accounts, err := repo.List()
+	if err != nil {
+		return matches
+	}
+	for _, acc := range accounts {
+		dir, err := repo.AccountDir(acc.Name)
+		if err != nil {
+			continue
+		}
+		f, err := auth.Load(filepath.Join(dir, "auth.json"))
+		if err != nil {
+			continue
+		}
+		if saved, err := f.Identity(); err == nil && saved.Same(id) {
+			matches = append(matches, acc.Name)
+		}
+	}
+	return matches
+}
+
+func init() {
+	rootCmd.AddCommand(whoamiCmd)
+}
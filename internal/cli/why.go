@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/delhombre/cxa/internal/decision"
+	"github.com/delhombre/cxa/internal/ui/styles"
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+func decisionLog() *decision.Log {
+	return decision.NewLog(paths.DecisionFile())
+}
+
+var whyCmd = &cobra.Command{
+	Use:   "why",
+	Short: "Explain the last automatic account switch",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		last, err := decisionLog().Last()
+		if err != nil {
+			out.Println(styles.RenderError(err.Error()))
+			return err
+		}
+
+		return out.Result(map[string]any{"decision": last}, func() {
+			if last == nil {
+				out.Println(styles.MutedStyle.Render("cxa has not switched accounts automatically."))
+				out.Println(styles.MutedStyle.Render("Every switch so far was one you asked for."))
+				return
+			}
+
+			from := last.From
+			if from == "" {
+				from = "none"
+			}
+
+			out.Println()
+			out.Printf("%s Switched %s %s %s %s\n",
+				styles.Bullet,
+				from,
+				styles.Arrow,
+				styles.CurrentAccountStyle.Render(last.To),
+				styles.MutedStyle.Render(humanize.Time(last.Time)),
+			)
+			out.Println()
+			out.Printf("  %-13s %s\n", "Trigger:", last.Trigger)
+			out.Printf("  %-13s %s\n", "Rule:", last.Rule)
+			if len(last.Alternatives) > 0 {
+				out.Printf("  %-13s %s\n", "Alternatives:", strings.Join(last.Alternatives, ", "))
+			}
+			if last.Override != "" {
+				out.Println()
+				out.Println(styles.MutedStyle.Render(fmt.Sprintf("  To override: %s", last.Override)))
+			}
+			out.Println()
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(whyCmd)
+}
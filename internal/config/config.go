@@ -0,0 +1,586 @@
+// Package config loads and stores cxa's own settings.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/delhombre/cxa/internal/fscopy"
+	"github.com/delhombre/cxa/internal/schedule"
+	"github.com/dustin/go-humanize"
+)
+
+// Config holds cxa settings persisted in the state directory.
+type Config struct {
+	// IOLimit caps copy throughput for background operations, written as a
+	// size per second such as "20MB". Empty means unlimited.
+	IOLimit string `json:"io_limit,omitempty"`
+
+	// CacheSize is how many recently used accounts are kept pre-staged so
+	// switching to them is a directory swap. Zero disables the warm cache.
+	CacheSize int `json:"cache_size,omitempty"`
+
+	// DataDir is where accounts are stored when moved away from the
+	// default ~/codex-data with cxa storage move.
+	DataDir string `json:"data_dir,omitempty"`
+
+	// Homes maps names of other Codex homes, for codex installations run
+	// with their own CODEX_HOME, to their directories. Each has accounts
+	// of its own, worked on with --home or CXA_HOME.
+	Homes map[string]string `json:"homes,omitempty"`
+
+	// Remotes maps sync remote names to their URLs, a local directory or
+	// host:path for rsync over SSH, or s3://bucket/prefix.
+	Remotes map[string]string `json:"remotes,omitempty"`
+
+	// Confirm controls whether destructive commands ask before acting.
+	// Nil means the default, which is to ask.
+	Confirm *bool `json:"confirm,omitempty"`
+
+	// Color is "auto" (the default), "always", or "never".
+	Color string `json:"color,omitempty"`
+
+	// CopyWorkers is how many files are copied at once. Zero picks a
+	// count from the number of CPUs.
+	CopyWorkers int `json:"copy_workers,omitempty"`
+
+	// Dedup stores session files identical across accounts once, linked
+	// from each account.
+	Dedup bool `json:"dedup,omitempty"`
+
+	// Exclude lists glob patterns for paths in ~/.codex that saving leaves
+	// out of accounts, such as "cache/" or "sessions/**/*.log".
+	Exclude []string `json:"exclude,omitempty"`
+
+	// Activation is how switching puts an account in place: "copy" (the
+	// default) copies it into ~/.codex, "symlink" links ~/.codex to it.
+	Activation string `json:"activation,omitempty"`
+
+	// Encrypt seals accounts with the storage passphrase whenever they are
+	// saved. It is turned on and off with cxa encrypt and cxa decrypt.
+	Encrypt bool `json:"encrypt,omitempty"`
+
+	// AuthStore is where saved accounts keep their auth.json: "file" (the
+	// default) in the account directory, or "keychain" in the operating
+	// system keychain. It is changed with cxa keychain.
+	AuthStore string `json:"auth_store,omitempty"`
+
+	// KeyFile is a file holding the storage passphrase, read instead of
+	// asking for it.
+	KeyFile string `json:"key_file,omitempty"`
+
+	// Quota is a soft limit on the size of the data directory, written as
+	// a size such as "5GB". Saving and switching warn when it is exceeded.
+	// Empty means no limit.
+	Quota string `json:"quota,omitempty"`
+
+	// Snapshots is how many earlier versions of each account are kept.
+	// Nil means DefaultSnapshots; zero keeps none.
+	Snapshots *int `json:"snapshots,omitempty"`
+
+	// SnapshotSchedule is a cron expression saying when cxa snapshot daemon
+	// saves ~/.codex into the current account, which snapshots the version
+	// it replaces. Empty means never.
+	SnapshotSchedule string `json:"snapshot_schedule,omitempty"`
+
+	// TrashDays is how many days deleted accounts are kept in the trash.
+	// Nil means DefaultTrashDays; zero deletes accounts at once.
+	TrashDays *int `json:"trash_days,omitempty"`
+
+	// Backend names the storage backend accounts are kept with. Empty
+	// means "directory", the data directory alone.
+	Backend string `json:"backend,omitempty"`
+
+	// BackendURL tells the backend where to keep accounts, in a form it
+	// understands. A name from Remotes stands for that remote's URL.
+	BackendURL string `json:"backend_url,omitempty"`
+
+	// Hooks maps events in HookEvents to shell commands run after them,
+	// alongside the hook scripts of the same name.
+	Hooks map[string]string `json:"hooks,omitempty"`
+}
+
+// Events hooks run on.
+const (
+	// HookShareEnable follows enabling sharing, or changing its mode or
+	// strategy.
+	HookShareEnable = "share-enable"
+	// HookShareDisable follows disabling sharing.
+	HookShareDisable = "share-disable"
+	// HookShareRepair follows a repair of sharing that changed something.
+	HookShareRepair = "share-repair"
+)
+
+// HookEvents lists every event hooks run on.
+var HookEvents = []string{HookShareEnable, HookShareDisable, HookShareRepair}
+
+// DefaultSnapshots is how many earlier versions of each account are kept
+// unless configured otherwise.
+const DefaultSnapshots = 5
+
+// DefaultTrashDays is how many days deleted accounts are kept unless
+// configured otherwise.
+const DefaultTrashDays = 30
+
+// TrashRetention returns how long deleted accounts are kept in the trash.
+func (c *Config) TrashRetention() time.Duration {
+	days := DefaultTrashDays
+	if c.TrashDays != nil {
+		days = *c.TrashDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// KeptSnapshots returns how many earlier versions of each account are kept.
+func (c *Config) KeptSnapshots() int {
+	if c.Snapshots == nil {
+		return DefaultSnapshots
+	}
+	return *c.Snapshots
+}
+
+// BackendLocation returns the backend URL, resolving a remote name.
+func (c *Config) BackendLocation() string {
+	if url, ok := c.Remotes[c.BackendURL]; ok {
+		return url
+	}
+	return c.BackendURL
+}
+
+// ShouldConfirm reports whether destructive commands should ask first.
+func (c *Config) ShouldConfirm() bool {
+	return c.Confirm == nil || *c.Confirm
+}
+
+// Setting describes a key handled by cxa config.
+type Setting struct {
+	Key         string
+	Description string
+
+	// SetWith names the command that changes the setting when it cannot
+	// be set directly.
+	SetWith string
+
+	get func(c *Config) string
+	set func(c *Config, value string) error
+}
+
+var settings = []Setting{
+	{
+		Key:         "activation",
+		Description: "how switching puts an account in place: copy or symlink",
+		get: func(c *Config) string {
+			if c.Activation == "" {
+				return "copy"
+			}
+			return c.Activation
+		},
+		set: func(c *Config, v string) error {
+			switch v {
+			case "", "copy":
+				c.Activation = ""
+			case "symlink":
+				c.Activation = v
+			default:
+				return fmt.Errorf("invalid activation %q: expected copy or symlink", v)
+			}
+			return nil
+		},
+	},
+	{
+		Key:         "auth_store",
+		Description: "where accounts keep auth.json: file or keychain",
+		SetWith:     "cxa keychain enable or cxa keychain disable",
+		get: func(c *Config) string {
+			if c.AuthStore == "" {
+				return "file"
+			}
+			return c.AuthStore
+		},
+	},
+	{
+		Key:         "backend",
+		Description: "storage backend accounts are kept with: directory, git, remote, or s3",
+		get: func(c *Config) string {
+			if c.Backend == "" {
+				return "directory"
+			}
+			return c.Backend
+		},
+		set: func(c *Config, v string) error {
+			if v == "directory" {
+				v = ""
+			}
+			if strings.ContainsAny(v, " /\\") {
+				return fmt.Errorf("invalid backend %q: expected a backend name", v)
+			}
+			c.Backend = v
+			return nil
+		},
+	},
+	{
+		Key:         "backend_url",
+		Description: "where the storage backend keeps accounts, or a remote name",
+		get:         func(c *Config) string { return c.BackendURL },
+		set: func(c *Config, v string) error {
+			c.BackendURL = v
+			return nil
+		},
+	},
+	{
+		Key:         "cache_size",
+		Description: "accounts kept pre-staged for instant switching (0 disables)",
+		get:         func(c *Config) string { return strconv.Itoa(c.CacheSize) },
+		set: func(c *Config, v string) error {
+			if v == "" {
+				c.CacheSize = 0
+				return nil
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid cache_size %q: expected a number of accounts", v)
+			}
+			c.CacheSize = n
+			return nil
+		},
+	},
+	{
+		Key:         "color",
+		Description: "colored output: auto, always, or never",
+		get: func(c *Config) string {
+			if c.Color == "" {
+				return "auto"
+			}
+			return c.Color
+		},
+		set: func(c *Config, v string) error {
+			switch v {
+			case "", "auto":
+				c.Color = ""
+			case "always", "never":
+				c.Color = v
+			default:
+				return fmt.Errorf("invalid color %q: expected auto, always, or never", v)
+			}
+			return nil
+		},
+	},
+	{
+		Key:         "confirm",
+		Description: "ask before deleting, restoring over, or moving accounts",
+		get:         func(c *Config) string { return strconv.FormatBool(c.ShouldConfirm()) },
+		set: func(c *Config, v string) error {
+			if v == "" {
+				c.Confirm = nil
+				return nil
+			}
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("invalid confirm %q: expected true or false", v)
+			}
+			c.Confirm = &b
+			return nil
+		},
+	},
+	{
+		Key:         "copy_workers",
+		Description: "files copied at once (0 picks from the CPU count)",
+		get:         func(c *Config) string { return strconv.Itoa(c.CopyWorkers) },
+		set: func(c *Config, v string) error {
+			if v == "" {
+				c.CopyWorkers = 0
+				return nil
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid copy_workers %q: expected a number of files", v)
+			}
+			c.CopyWorkers = n
+			return nil
+		},
+	},
+	{
+		Key:         "data_dir",
+		Description: "where accounts are stored (empty means ~/codex-data)",
+		SetWith:     "cxa storage move <path>",
+		get:         func(c *Config) string { return c.DataDir },
+	},
+	{
+		Key:         "dedup",
+		Description: "store session files shared by accounts once (cxa gc drops unused ones)",
+		get:         func(c *Config) string { return strconv.FormatBool(c.Dedup) },
+		set: func(c *Config, v string) error {
+			if v == "" {
+				c.Dedup = false
+				return nil
+			}
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("invalid dedup %q: expected true or false", v)
+			}
+			c.Dedup = b
+			return nil
+		},
+	},
+	{
+		Key:         "encrypt",
+		Description: "seal accounts with a passphrase when they are saved",
+		SetWith:     "cxa encrypt or cxa decrypt",
+		get:         func(c *Config) string { return strconv.FormatBool(c.Encrypt) },
+	},
+	{
+		Key:         "exclude",
+		Description: "comma-separated globs left out when saving, e.g. cache/,*.log",
+		get:         func(c *Config) string { return strings.Join(c.Exclude, ",") },
+		set: func(c *Config, v string) error {
+			var patterns []string
+			for _, p := range strings.Split(v, ",") {
+				if p = strings.TrimSpace(p); p == "" {
+					continue
+				}
+				if err := fscopy.CheckPattern(p); err != nil {
+					return err
+				}
+				patterns = append(patterns, p)
+			}
+			c.Exclude = patterns
+			return nil
+		},
+	},
+	{
+		Key:         "homes",
+		Description: "other Codex homes, as name=dir",
+		SetWith:     "cxa home add|remove",
+		get: func(c *Config) string {
+			pairs := make([]string, 0, len(c.Homes))
+			for name, dir := range c.Homes {
+				pairs = append(pairs, name+"="+dir)
+			}
+			sort.Strings(pairs)
+			return strings.Join(pairs, ",")
+		},
+	},
+	hookSetting(HookShareDisable),
+	hookSetting(HookShareEnable),
+	hookSetting(HookShareRepair),
+	{
+		Key:         "io_limit",
+		Description: "copy throughput cap for background jobs, e.g. 20MB",
+		get:         func(c *Config) string { return c.IOLimit },
+		set: func(c *Config, v string) error {
+			if _, err := ParseIOLimit(v); err != nil {
+				return err
+			}
+			c.IOLimit = v
+			return nil
+		},
+	},
+	{
+		Key:         "key_file",
+		Description: "file holding the passphrase of encrypted accounts",
+		get:         func(c *Config) string { return c.KeyFile },
+		set: func(c *Config, v string) error {
+			if v != "" && !filepath.IsAbs(v) {
+				return fmt.Errorf("invalid key_file %q: expected an absolute path", v)
+			}
+			c.KeyFile = v
+			return nil
+		},
+	},
+	{
+		Key:         "quota",
+		Description: "data directory size to warn beyond, e.g. 5GB",
+		get:         func(c *Config) string { return c.Quota },
+		set: func(c *Config, v string) error {
+			if _, err := ParseQuota(v); err != nil {
+				return err
+			}
+			c.Quota = v
+			return nil
+		},
+	},
+	{
+		Key:         "remotes",
+		Description: "sync remotes, as name=url",
+		SetWith:     "cxa sync remote add|remove",
+		get: func(c *Config) string {
+			pairs := make([]string, 0, len(c.Remotes))
+			for name, url := range c.Remotes {
+				pairs = append(pairs, name+"="+url)
+			}
+			sort.Strings(pairs)
+			return strings.Join(pairs, ",")
+		},
+	},
+	{
+		Key:         "snapshots",
+		Description: "earlier versions kept of each account (0 disables)",
+		get:         func(c *Config) string { return strconv.Itoa(c.KeptSnapshots()) },
+		set: func(c *Config, v string) error {
+			if v == "" {
+				c.Snapshots = nil
+				return nil
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid snapshots %q: expected a number of versions", v)
+			}
+			c.Snapshots = &n
+			return nil
+		},
+	},
+	{
+		Key:         "snapshot_schedule",
+		Description: "cron expression for scheduled snapshots, e.g. @hourly",
+		get:         func(c *Config) string { return c.SnapshotSchedule },
+		set: func(c *Config, v string) error {
+			if v != "" {
+				if _, err := schedule.Parse(v); err != nil {
+					return err
+				}
+			}
+			c.SnapshotSchedule = v
+			return nil
+		},
+	},
+	{
+		Key:         "trash_days",
+		Description: "days deleted accounts are kept in the trash (0 deletes at once)",
+		get: func(c *Config) string {
+			if c.TrashDays == nil {
+				return strconv.Itoa(DefaultTrashDays)
+			}
+			return strconv.Itoa(*c.TrashDays)
+		},
+		set: func(c *Config, v string) error {
+			if v == "" {
+				c.TrashDays = nil
+				return nil
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid trash_days %q: expected a number of days", v)
+			}
+			c.TrashDays = &n
+			return nil
+		},
+	},
+}
+
+// hookSetting returns the setting holding the hook command of event.
+func hookSetting(event string) Setting {
+	return Setting{
+		Key:         "hooks." + event,
+		Description: "shell command run after " + strings.ReplaceAll(event, "-", " "),
+		get:         func(c *Config) string { return c.Hooks[event] },
+		set: func(c *Config, v string) error {
+			if v == "" {
+				delete(c.Hooks, event)
+				return nil
+			}
+			if c.Hooks == nil {
+				c.Hooks = make(map[string]string)
+			}
+			c.Hooks[event] = v
+			return nil
+		},
+	}
+}
+
+// Settings returns every setting, sorted by key.
+func Settings() []Setting {
+	return settings
+}
+
+func lookup(key string) (*Setting, error) {
+	for i := range settings {
+		if settings[i].Key == key {
+			return &settings[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown setting '%s'", key)
+}
+
+// Get returns the value of the setting key, or its default when unset.
+func (c *Config) Get(key string) (string, error) {
+	s, err := lookup(key)
+	if err != nil {
+		return "", err
+	}
+	return s.get(c), nil
+}
+
+// Set validates value and stores it as the setting key. An empty value
+// restores the default.
+func (c *Config) Set(key, value string) error {
+	s, err := lookup(key)
+	if err != nil {
+		return err
+	}
+	if s.set == nil {
+		return fmt.Errorf("%s cannot be set directly; use %s", key, s.SetWith)
+	}
+	return s.set(c, value)
+}
+
+// Load reads the config at path. A missing file yields the defaults.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Config{}, nil
+		}
+		return nil, err
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// Save writes the config to path.
+func (c *Config) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// ParseIOLimit converts a size-per-second string such as "20MB" into bytes
+// per second. An empty string means unlimited and returns 0.
+func ParseIOLimit(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := humanize.ParseBytes(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid IO limit %q: %w", s, err)
+	}
+	return int64(n), nil
+}
+
+// ParseQuota converts a size string such as "5GB" into bytes. An empty
+// string means no quota and returns 0.
+func ParseQuota(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := humanize.ParseBytes(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quota %q: %w", s, err)
+	}
+	return int64(n), nil
+}
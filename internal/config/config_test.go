@@ -0,0 +1,105 @@
+package config_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/delhombre/cxa/internal/config"
+)
+
+func TestLoad_MissingFileYieldsDefaults(t *testing.T) {
+	cfg, err := config.Load(filepath.Join(t.TempDir(), "config.json"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.IOLimit != "" {
+		t.Errorf("expected no IO limit, got %q", cfg.IOLimit)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "config.json")
+
+	cfg := &config.Config{IOLimit: "20MB"}
+	if err := cfg.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	loaded, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.IOLimit != "20MB" {
+		t.Errorf("expected IO limit '20MB', got %q", loaded.IOLimit)
+	}
+}
+
+func TestParseIOLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"20MB", 20_000_000, false},
+		{"1MiB", 1 << 20, false},
+		{"fast", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := config.ParseIOLimit(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseIOLimit(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseIOLimit(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestConfig_GetAndSet(t *testing.T) {
+	cfg := &config.Config{}
+
+	if v, _ := cfg.Get("confirm"); v != "true" {
+		t.Errorf("confirm should default to true, got %q", v)
+	}
+	if err := cfg.Set("confirm", "false"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if cfg.ShouldConfirm() {
+		t.Error("confirm should be off after setting it to false")
+	}
+	if err := cfg.Set("confirm", ""); err != nil || !cfg.ShouldConfirm() {
+		t.Errorf("an empty value should restore the default, got %v", err)
+	}
+
+	if err := cfg.Set("cache_size", "3"); err != nil || cfg.CacheSize != 3 {
+		t.Errorf("expected cache_size 3, got %d (%v)", cfg.CacheSize, err)
+	}
+
+	if err := cfg.Set("exclude", "cache/, sessions/**/*.log"); err != nil || len(cfg.Exclude) != 2 {
+		t.Errorf("expected two exclude patterns, got %v (%v)", cfg.Exclude, err)
+	}
+
+	if err := cfg.Set("quota", "5GB"); err != nil {
+		t.Errorf("Set quota failed: %v", err)
+	} else if n, _ := config.ParseQuota(cfg.Quota); n != 5_000_000_000 {
+		t.Errorf("expected a 5GB quota, got %d", n)
+	}
+
+	for _, tt := range []struct{ key, value string }{
+		{"cache_size", "-1"},
+		{"exclude", "sessions/["},
+		{"color", "purple"},
+		{"io_limit", "fast"},
+		{"quota", "lots"},
+		{"snapshot_schedule", "every hour"},
+		{"data_dir", "/mnt"},
+		{"theme", "dark"},
+	} {
+		if err := cfg.Set(tt.key, tt.value); err == nil {
+			t.Errorf("Set(%q, %q) should fail", tt.key, tt.value)
+		}
+	}
+}
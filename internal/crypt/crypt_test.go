@@ -0,0 +1,100 @@
+package crypt_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/delhombre/cxa/internal/crypt"
+)
+
+func init() {
+	// Keep tests fast; the format records the work factor
+	crypt.WorkFactor = 10
+}
+
+func encrypt(t *testing.T, plain []byte, passphrase string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := crypt.NewWriter(&buf, []byte(passphrase))
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	if _, err := w.Write(plain); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func decrypt(data []byte, passphrase string) ([]byte, error) {
+	r, err := crypt.NewReader(bytes.NewReader(data), []byte(passphrase))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, 64 * 1024, 64*1024 + 1, 200_000} {
+		plain := bytes.Repeat([]byte("x"), size)
+		data := encrypt(t, plain, "secret")
+
+		if !crypt.IsEncrypted(bufio.NewReader(bytes.NewReader(data))) {
+			t.Errorf("size %d: stream should be recognized as encrypted", size)
+		}
+		got, err := decrypt(data, "secret")
+		if err != nil {
+			t.Fatalf("size %d: decrypt failed: %v", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("size %d: round trip mismatch", size)
+		}
+	}
+}
+
+func TestWrongPassphrase(t *testing.T) {
+	data := encrypt(t, []byte("hello"), "secret")
+	if _, err := decrypt(data, "guess"); !errors.Is(err, crypt.ErrDecrypt) {
+		t.Errorf("expected ErrDecrypt, got %v", err)
+	}
+}
+
+func TestTamperingDetected(t *testing.T) {
+	data := encrypt(t, bytes.Repeat([]byte("y"), 150_000), "secret")
+
+	// Dropping the final chunk must not look like a shorter valid stream
+	lastChunk := (150_000 - 2*64*1024) + 16
+	if _, err := decrypt(data[:len(data)-lastChunk], "secret"); !errors.Is(err, crypt.ErrDecrypt) {
+		t.Errorf("truncation: expected ErrDecrypt, got %v", err)
+	}
+
+	flipped := bytes.Clone(data)
+	flipped[len(flipped)-1] ^= 1
+	if _, err := decrypt(flipped, "secret"); !errors.Is(err, crypt.ErrDecrypt) {
+		t.Errorf("bit flip: expected ErrDecrypt, got %v", err)
+	}
+
+	if _, err := decrypt([]byte("plain tar data, not encrypted"), "secret"); !errors.Is(err, crypt.ErrNotEncrypted) {
+		t.Errorf("expected ErrNotEncrypted, got %v", err)
+	}
+}
+
+func TestOversizedWorkFactorRefused(t *testing.T) {
+	data := encrypt(t, []byte("hello"), "secret")
+
+	// The work factor ends the scrypt line of the header, unauthenticated
+	line := bytes.Index(data, []byte(" 10\n"))
+	if !bytes.HasPrefix(data[len(crypt.Magic):], []byte("-> scrypt ")) || line < 0 {
+		t.Fatalf("unexpected header %q", data[:min(len(data), 80)])
+	}
+	crafted := bytes.Clone(data)
+	copy(crafted[line:], " 30\n")
+	if _, err := decrypt(crafted, "secret"); !errors.Is(err, crypt.ErrDecrypt) {
+		t.Errorf("expected a stream asking for too much work to be refused, got %v", err)
+	}
+}
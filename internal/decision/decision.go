@@ -0,0 +1,76 @@
+// Package decision records why cxa changed the active account on its own,
+// so users can find out with `cxa why` instead of being surprised.
+package decision
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Decision explains an automatic account switch.
+type Decision struct {
+	Time time.Time `json:"time"`
+
+	// Trigger is the feature that made the switch, such as "pin",
+	// "schedule", or "rotation".
+	Trigger string `json:"trigger"`
+
+	// Rule describes the rule that fired, such as the pin file or schedule
+	// entry that matched.
+	Rule string `json:"rule"`
+
+	From string `json:"from,omitempty"`
+	To   string `json:"to"`
+
+	// Alternatives are the other accounts that were considered.
+	Alternatives []string `json:"alternatives,omitempty"`
+
+	// Override tells the user how to prevent or undo this decision.
+	Override string `json:"override,omitempty"`
+}
+
+// Log is the last-decision file on disk.
+type Log struct {
+	path string
+}
+
+// NewLog returns a log backed by the file at path.
+func NewLog(path string) *Log {
+	return &Log{path: path}
+}
+
+// Write replaces the last decision with d, stamping it with the current
+// time if it has none.
+func (l *Log) Write(d *Decision) error {
+	if d.Time.IsZero() {
+		d.Time = time.Now()
+	}
+
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(l.path, data, 0644)
+}
+
+// Last returns the most recent decision, or nil if none was recorded.
+func (l *Log) Last() (*Decision, error) {
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var d Decision
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
@@ -0,0 +1,34 @@
+package decision_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/delhombre/cxa/internal/decision"
+)
+
+func TestLog(t *testing.T) {
+	log := decision.NewLog(filepath.Join(t.TempDir(), "state", "last-decision.json"))
+
+	last, err := log.Last()
+	if err != nil || last != nil {
+		t.Fatalf("expected no decision, got %+v, %v", last, err)
+	}
+
+	for _, to := range []string{"work", "personal"} {
+		if err := log.Write(&decision.Decision{Trigger: "pin", Rule: ".cxa", To: to}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	last, err = log.Last()
+	if err != nil {
+		t.Fatalf("Last failed: %v", err)
+	}
+	if last.To != "personal" || last.Trigger != "pin" {
+		t.Errorf("unexpected decision: %+v", last)
+	}
+	if last.Time.IsZero() {
+		t.Error("decision should be timestamped")
+	}
+}
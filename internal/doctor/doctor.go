@@ -0,0 +1,405 @@
+// Package doctor diagnoses and repairs common cxa problems.
+package doctor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/delhombre/cxa/internal/account"
+	"github.com/delhombre/cxa/internal/auth"
+	"github.com/delhombre/cxa/internal/schema"
+	"github.com/delhombre/cxa/internal/sharing"
+	"github.com/delhombre/cxa/internal/storage"
+	"github.com/delhombre/cxa/pkg/codex"
+)
+
+// Severity ranks a finding.
+type Severity int
+
+const (
+	OK Severity = iota
+	Warning
+	Problem
+)
+
+// String returns the lowercase name of the severity.
+func (s Severity) String() string {
+	switch s {
+	case OK:
+		return "ok"
+	case Warning:
+		return "warning"
+	default:
+		return "problem"
+	}
+}
+
+// MarshalText encodes the severity by name.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Finding is the outcome of a single check.
+type Finding struct {
+	Check    string
+	Severity Severity
+	Message  string
+	Fix      string // Actionable advice, empty when nothing needs doing
+
+	repair func() error
+}
+
+// CanRepair reports whether Repair can fix the finding automatically.
+func (f *Finding) CanRepair() bool {
+	return f.repair != nil
+}
+
+// Repair applies the automatic fix for the finding.
+func (f *Finding) Repair() error {
+	if f.repair == nil {
+		return errors.New("no automatic fix available")
+	}
+	return f.repair()
+}
+
+// Doctor runs health checks against the cxa and Codex directories.
+type Doctor struct {
+	paths   *codex.Paths
+	repo    *storage.DirectoryRepository
+	sharing *sharing.Manager
+}
+
+// New creates a doctor operating on the locations of repo.
+func New(repo *storage.DirectoryRepository) *Doctor {
+	return &Doctor{
+		paths:   repo.Paths(),
+		repo:    repo,
+		sharing: sharing.NewManagerWithPaths(repo.Paths()),
+	}
+}
+
+// Run executes every check and returns the findings in order.
+func (d *Doctor) Run() []*Finding {
+	var findings []*Finding
+	findings = append(findings, d.checkCodexHome()...)
+	findings = append(findings, d.checkState()...)
+	findings = append(findings, d.checkSharing()...)
+	findings = append(findings, d.checkAuth()...)
+	findings = append(findings, d.checkPermissions()...)
+	findings = append(findings, d.checkSchema()...)
+	return findings
+}
+
+func (d *Doctor) checkCodexHome() []*Finding {
+	const check = "codex home"
+
+	info, err := os.Stat(d.paths.Home)
+	if os.IsNotExist(err) {
+		f := &Finding{
+			Check:    check,
+			Severity: Problem,
+			Message:  "~/.codex not found",
+			Fix:      "Log in with 'codex login', then save the account with 'cxa save <name>'",
+		}
+		if current, _ := d.repo.Current(); current != "" {
+			if _, err := d.repo.AccountDir(current); err == nil {
+				f.Fix = fmt.Sprintf("Restore it from the saved account with 'cxa switch %s'", current)
+				f.repair = func() error { return d.repo.Activate(current) }
+			}
+		}
+		return []*Finding{f}
+	}
+	if err != nil {
+		return []*Finding{{Check: check, Severity: Problem, Message: err.Error()}}
+	}
+	if !info.IsDir() {
+		return []*Finding{{
+			Check:    check,
+			Severity: Problem,
+			Message:  "~/.codex is not a directory",
+			Fix:      "Move the file out of the way and log in again with 'codex login'",
+		}}
+	}
+	if _, err := os.ReadDir(d.paths.Home); err != nil {
+		return []*Finding{{
+			Check:    check,
+			Severity: Problem,
+			Message:  "~/.codex is not readable",
+			Fix:      "Restore owner permissions with 'chmod u+rwx ~/.codex'",
+			repair:   func() error { return os.Chmod(d.paths.Home, info.Mode().Perm()|0700) },
+		}}
+	}
+
+	return []*Finding{{Check: check, Severity: OK, Message: "~/.codex is present and readable"}}
+}
+
+func (d *Doctor) checkState() []*Finding {
+	const check = "state"
+
+	data, err := os.ReadFile(d.paths.StateFile())
+	if os.IsNotExist(err) {
+		return []*Finding{{Check: check, Severity: OK, Message: "no state recorded yet"}}
+	}
+	if err != nil {
+		return []*Finding{{Check: check, Severity: Problem, Message: err.Error()}}
+	}
+
+	var state storage.State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return []*Finding{{
+			Check:    check,
+			Severity: Problem,
+			Message:  "state.json is corrupt",
+			Fix:      "Rebuild it from the saved accounts",
+			repair: func() error {
+				_, err := d.repo.RebuildState()
+				return err
+			},
+		}}
+	}
+
+	var findings []*Finding
+	if state.Current != "" {
+		if _, err := d.repo.AccountDir(state.Current); err != nil {
+			findings = append(findings, &Finding{
+				Check:    check,
+				Severity: Problem,
+				Message:  fmt.Sprintf("current account '%s' has no saved copy", state.Current),
+				Fix:      fmt.Sprintf("Save the active session with 'cxa save %s', or clear the stale entry", state.Current),
+				repair: func() error {
+					return d.repo.SetState(&storage.State{Previous: state.Previous})
+				},
+			})
+		}
+	}
+	if state.Previous != "" {
+		if _, err := d.repo.AccountDir(state.Previous); err != nil {
+			findings = append(findings, &Finding{
+				Check:    check,
+				Severity: Warning,
+				Message:  fmt.Sprintf("previous account '%s' no longer exists", state.Previous),
+				Fix:      "Clear the stale entry",
+				repair: func() error {
+					current, err := d.repo.State()
+					if err != nil {
+						return err
+					}
+					current.Previous = ""
+					return d.repo.SetState(current)
+				},
+			})
+		}
+	}
+
+	if len(findings) == 0 {
+		findings = append(findings, &Finding{Check: check, Severity: OK, Message: "state.json matches saved accounts"})
+	}
+	return findings
+}
+
+func (d *Doctor) checkSharing() []*Finding {
+	const check = "sharing"
+
+	if err := d.sharing.LoadConfig(); err != nil {
+		return []*Finding{{
+			Check:    check,
+			Severity: Problem,
+			Message:  fmt.Sprintf("sharing.json is unreadable: %v", err),
+			Fix:      "Run 'cxa share disable' and 'cxa share enable' to rewrite it",
+		}}
+	}
+	if !d.sharing.IsEnabled() {
+		return []*Finding{{Check: check, Severity: OK, Message: "sharing disabled"}}
+	}
+
+	current, _ := d.repo.Current()
+	repair := func() error {
+		_, err := d.sharing.Repair(current)
+		return err
+	}
+	var findings []*Finding
+	_, _, items := d.sharing.Status(current)
+	for _, item := range items {
+		if item.Healthy() {
+			continue
+		}
+		switch item.State {
+		case sharing.ItemBroken:
+			findings = append(findings, &Finding{
+				Check:    check,
+				Severity: Problem,
+				Message:  fmt.Sprintf("%s points to missing %s", item.Item, item.Target),
+				Fix:      "Recreate the shared target and symlink",
+				repair:   repair,
+			})
+		case sharing.ItemStray:
+			findings = append(findings, &Finding{
+				Check:    check,
+				Severity: Warning,
+				Message:  fmt.Sprintf("%s points to %s instead of the sharing location", item.Item, item.Target),
+				Fix:      "Recreate the sharing symlinks",
+				repair:   repair,
+			})
+		default:
+			findings = append(findings, &Finding{
+				Check:    check,
+				Severity: Warning,
+				Message:  fmt.Sprintf("%s is not shared", item.Item),
+				Fix:      "Recreate the sharing symlinks",
+				repair:   repair,
+			})
+		}
+	}
+
+	if len(findings) == 0 {
+		findings = append(findings, &Finding{Check: check, Severity: OK, Message: "sharing symlinks are intact"})
+	}
+	return findings
+}
+
+func (d *Doctor) checkAuth() []*Finding {
+	const check = "auth"
+
+	f, err := auth.Load(d.paths.AuthFile())
+	if os.IsNotExist(err) {
+		return []*Finding{{
+			Check:    check,
+			Severity: Warning,
+			Message:  "not logged in (no auth.json)",
+			Fix:      "Log in with 'codex login'",
+		}}
+	}
+	if err != nil {
+		return []*Finding{{
+			Check:    check,
+			Severity: Problem,
+			Message:  err.Error(),
+			Fix:      "Log in again with 'codex login'",
+		}}
+	}
+
+	id, err := f.Identity()
+	if err != nil {
+		return []*Finding{{
+			Check:    check,
+			Severity: Problem,
+			Message:  err.Error(),
+			Fix:      "Log in again with 'codex login'",
+		}}
+	}
+	if id.Expired() {
+		return []*Finding{{
+			Check:    check,
+			Severity: Warning,
+			Message:  fmt.Sprintf("token expired at %s", id.ExpiresAt.Format("2006-01-02 15:04")),
+			Fix:      "Codex refreshes it on next use; if that fails, run 'codex login'",
+		}}
+	}
+
+	who := id.Email
+	if id.APIKey {
+		who = "API key"
+	}
+	return []*Finding{{Check: check, Severity: OK, Message: fmt.Sprintf("auth.json parses (%s)", who)}}
+}
+
+func (d *Doctor) checkPermissions() []*Finding {
+	const check = "permissions"
+
+	var findings []*Finding
+	for _, dir := range []string{d.paths.DataDir, d.paths.StateDir, d.paths.AccountsDir()} {
+		info, err := os.Stat(dir)
+		if err != nil || info.Mode().Perm()&0700 == 0700 {
+			continue
+		}
+		dir, mode := dir, info.Mode().Perm()
+		findings = append(findings, &Finding{
+			Check:    check,
+			Severity: Problem,
+			Message:  fmt.Sprintf("%s is not fully accessible to you (%s)", dir, mode),
+			Fix:      fmt.Sprintf("Run 'chmod u+rwx %s'", dir),
+			repair:   func() error { return os.Chmod(dir, mode|0700) },
+		})
+	}
+
+	authFiles := []string{d.paths.AuthFile()}
+	if accounts, err := os.ReadDir(d.paths.AccountsDir()); err == nil {
+		for _, entry := range accounts {
+			if entry.IsDir() {
+				authFiles = append(authFiles, filepath.Join(d.paths.AccountPath(entry.Name()), "auth.json"))
+			}
+		}
+	}
+	for _, path := range authFiles {
+		info, err := os.Lstat(path)
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0077 == 0 {
+			continue
+		}
+		path := path
+		findings = append(findings, &Finding{
+			Check:    check,
+			Severity: Warning,
+			Message:  fmt.Sprintf("%s is readable by other users (%s)", path, info.Mode().Perm()),
+			Fix:      fmt.Sprintf("Run 'chmod 600 %s'", path),
+			repair:   func() error { return os.Chmod(path, 0600) },
+		})
+	}
+
+	if len(findings) == 0 {
+		findings = append(findings, &Finding{Check: check, Severity: OK, Message: "directory and credential permissions look sane"})
+	}
+	return findings
+}
+
+func (d *Doctor) checkSchema() []*Finding {
+	const check = "schema"
+
+	var findings []*Finding
+	for _, file := range []struct {
+		format *schema.Format
+		path   string
+	}{
+		{storage.StateFormat, d.paths.StateFile()},
+		{sharing.Format, d.paths.SharingConfigFile()},
+	} {
+		var newer *schema.NewerError
+		if errors.As(file.format.CheckWritable(file.path), &newer) {
+			findings = append(findings, &Finding{
+				Check:    check,
+				Severity: Warning,
+				Message:  fmt.Sprintf("%s uses schema %d, newer than this cxa supports (%d); it is read-only", newer.File, newer.Found, newer.Supported),
+				Fix:      "Upgrade cxa to the version that wrote it",
+			})
+		}
+	}
+
+	entries, _ := os.ReadDir(d.paths.AccountsDir())
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		acc, err := d.repo.Get(entry.Name())
+		if err == nil {
+			err = acc.CheckWritable()
+		}
+		var schemaErr *account.SchemaError
+		if !errors.As(err, &schemaErr) {
+			continue
+		}
+		findings = append(findings, &Finding{
+			Check:    check,
+			Severity: Warning,
+			Message:  fmt.Sprintf("account '%s' uses metadata schema %d, newer than this cxa supports (%d); it is read-only", entry.Name(), schemaErr.Found, account.SchemaVersion),
+			Fix:      "Upgrade cxa to the version that wrote it",
+		})
+	}
+
+	if len(findings) == 0 {
+		findings = append(findings, &Finding{Check: check, Severity: OK, Message: "all state and account metadata is understood by this cxa"})
+	}
+	return findings
+}
@@ -0,0 +1,192 @@
+package doctor_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/delhombre/cxa/internal/doctor"
+	"github.com/delhombre/cxa/internal/storage"
+	"github.com/delhombre/cxa/pkg/cxatest"
+)
+
+func countSeverity(findings []*doctor.Finding, severity doctor.Severity) int {
+	n := 0
+	for _, f := range findings {
+		if f.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
+
+func TestDoctor_DetectsAndRepairs(t *testing.T) {
+	home := cxatest.Home(t)
+
+	repo := storage.NewDirectoryRepository()
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// Point state at an account that does not exist and loosen auth.json
+	if err := repo.SetState(&storage.State{Current: "ghost", Previous: "work"}); err != nil {
+		t.Fatalf("SetState failed: %v", err)
+	}
+	authPath := filepath.Join(home, ".codex", "auth.json")
+	if err := os.Chmod(authPath, 0644); err != nil {
+		t.Fatalf("failed to chmod auth.json: %v", err)
+	}
+
+	d := doctor.New(repo)
+	findings := d.Run()
+	if countSeverity(findings, doctor.Problem) == 0 {
+		t.Fatal("expected the stale current account to be reported as a problem")
+	}
+
+	for _, f := range findings {
+		if f.Severity != doctor.OK && f.CanRepair() {
+			if err := f.Repair(); err != nil {
+				t.Fatalf("Repair(%s) failed: %v", f.Message, err)
+			}
+		}
+	}
+
+	if n := countSeverity(d.Run(), doctor.Problem); n != 0 {
+		t.Errorf("expected no problems after repair, got %d", n)
+	}
+
+	state, _ := repo.State()
+	if state.Current != "" || state.Previous != "work" {
+		t.Errorf("unexpected state after repair: %+v", state)
+	}
+
+	info, err := os.Stat(authPath)
+	if err != nil {
+		t.Fatalf("failed to stat auth.json: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected auth.json mode 0600, got %s", info.Mode().Perm())
+	}
+}
+
+func TestDoctor_VerifyInstall(t *testing.T) {
+	home := cxatest.Home(t)
+
+	binDir := filepath.Join(home, "bin")
+	otherDir := filepath.Join(home, "other")
+	for _, dir := range []string{binDir, otherDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "cxa"), []byte("binary"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	completion := filepath.Join(home, ".zfunc", "_cxa")
+
+	d := doctor.New(storage.NewDirectoryRepository())
+	opts := doctor.InstallOptions{
+		Executable:      filepath.Join(binDir, "cxa"),
+		Path:            binDir + string(os.PathListSeparator) + otherDir,
+		CompletionPaths: []string{completion},
+	}
+
+	findings := d.VerifyInstall(opts)
+	if countSeverity(findings, doctor.Problem) != 0 {
+		t.Errorf("expected no problems, got %+v", findings)
+	}
+	// Shadowed copy and missing completion
+	if n := countSeverity(findings, doctor.Warning); n != 2 {
+		t.Errorf("expected 2 warnings, got %d", n)
+	}
+
+	// Another cxa first on PATH and a bad checksum are problems
+	opts.Path = otherDir + string(os.PathListSeparator) + binDir
+	opts.SHA256 = "0000"
+	if n := countSeverity(d.VerifyInstall(opts), doctor.Problem); n != 2 {
+		t.Errorf("expected 2 problems, got %d", n)
+	}
+}
+
+func TestLintConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+
+	lint := func(content string) []*doctor.Finding {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write config.toml: %v", err)
+		}
+		return doctor.LintConfig(path)
+	}
+
+	findings := lint(`# a clean config
+model = "gpt-5"
+model_reasoning_effort = "high" # trailing comment
+profile = "fast"
+
+[profiles.fast]
+model_provider = "openai"
+
+[mcp_servers."shell tools"]
+command = "sh"
+args = [
+  "-c", # comment inside an array
+  "true",
+]
+env = { A = "1" }
+
+[mcp_servers.remote]
+url = "https://example.com/mcp"
+`)
+	if countSeverity(findings, doctor.OK) != 1 || len(findings) != 1 {
+		for _, f := range findings {
+			t.Errorf("unexpected finding: %s: %s", f.Check, f.Message)
+		}
+	}
+
+	findings = lint(`modle = "gpt-5"
+model_provider = "azure"
+approval_policy = "sometimes"
+profile = "missing"
+
+[mcp_servers.broken]
+command = "cxa-no-such-server"
+
+[mcp_servers.empty]
+args = []
+`)
+	want := map[string]doctor.Severity{
+		`unknown setting "modle"`:                      doctor.Warning,
+		`model_provider "azure" is not defined`:        doctor.Problem,
+		`approval_policy = "sometimes" is not valid`:   doctor.Problem,
+		`profile "missing" is not defined`:             doctor.Problem,
+		`command "cxa-no-such-server" cannot be found`: doctor.Problem,
+		`server has neither command nor url`:           doctor.Problem,
+	}
+	if len(findings) != len(want) {
+		t.Errorf("expected %d findings, got %d", len(want), len(findings))
+	}
+	for _, f := range findings {
+		found := false
+		for msg, severity := range want {
+			if strings.Contains(f.Message, msg) && f.Severity == severity {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("unexpected finding: %s: %s (%s)", f.Check, f.Message, f.Severity)
+		}
+	}
+
+	findings = lint("model = \"gpt-5\nfoo = 1\n")
+	if len(findings) != 1 || findings[0].Severity != doctor.Problem {
+		t.Errorf("expected a single parse problem, got %d findings", len(findings))
+	}
+
+	os.Remove(path)
+	if findings := doctor.LintConfig(path); findings[0].Severity != doctor.OK {
+		t.Errorf("expected a missing config.toml to be fine, got %s", findings[0].Message)
+	}
+}
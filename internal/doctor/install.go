@@ -0,0 +1,194 @@
+package doctor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// InstallOptions describes the installation to verify.
+type InstallOptions struct {
+	// Executable is the installed cxa binary.
+	Executable string
+
+	// Path is the PATH to search for cxa, in os.PathListSeparator form.
+	Path string
+
+	// SHA256 is the expected binary checksum, if the installer knows it.
+	SHA256 string
+
+	// CompletionPaths are where shell completion scripts may be installed.
+	CompletionPaths []string
+}
+
+// VerifyInstall checks that a package-manager install left a working cxa:
+// an intact binary, no other cxa shadowing it on PATH, completions
+// installed, and writable data directories.
+func (d *Doctor) VerifyInstall(opts InstallOptions) []*Finding {
+	var findings []*Finding
+	findings = append(findings, checkBinary(opts)...)
+	findings = append(findings, checkPath(opts)...)
+	findings = append(findings, checkCompletion(opts)...)
+	findings = append(findings, d.checkWritable()...)
+	return findings
+}
+
+func checkBinary(opts InstallOptions) []*Finding {
+	const check = "binary"
+
+	info, err := os.Stat(opts.Executable)
+	if err != nil {
+		return []*Finding{{Check: check, Severity: Problem, Message: err.Error()}}
+	}
+	if !info.Mode().IsRegular() || (runtime.GOOS != "windows" && info.Mode().Perm()&0111 == 0) {
+		return []*Finding{{
+			Check:    check,
+			Severity: Problem,
+			Message:  fmt.Sprintf("%s is not an executable file (%s)", opts.Executable, info.Mode()),
+			Fix:      "Reinstall cxa with your package manager",
+		}}
+	}
+
+	sum, err := fileSHA256(opts.Executable)
+	if err != nil {
+		return []*Finding{{Check: check, Severity: Problem, Message: err.Error()}}
+	}
+	if opts.SHA256 != "" && !strings.EqualFold(opts.SHA256, sum) {
+		return []*Finding{{
+			Check:    check,
+			Severity: Problem,
+			Message:  fmt.Sprintf("checksum mismatch: expected %s, got %s", opts.SHA256, sum),
+			Fix:      "The download may be corrupt; reinstall cxa",
+		}}
+	}
+
+	return []*Finding{{Check: check, Severity: OK, Message: fmt.Sprintf("%s (sha256 %s)", opts.Executable, sum)}}
+}
+
+func checkPath(opts InstallOptions) []*Finding {
+	const check = "path"
+
+	self := resolve(opts.Executable)
+	var found []string
+	for _, dir := range filepath.SplitList(opts.Path) {
+		candidate := filepath.Join(dir, binaryName())
+		info, err := os.Stat(candidate)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		resolved := resolve(candidate)
+		if !slices.Contains(found, resolved) {
+			found = append(found, resolved)
+		}
+	}
+
+	switch {
+	case len(found) == 0:
+		return []*Finding{{
+			Check:    check,
+			Severity: Warning,
+			Message:  "cxa is not on PATH",
+			Fix:      fmt.Sprintf("Add %s to PATH", filepath.Dir(opts.Executable)),
+		}}
+	case found[0] != self:
+		return []*Finding{{
+			Check:    check,
+			Severity: Problem,
+			Message:  fmt.Sprintf("'cxa' on PATH runs %s, not this install (%s)", found[0], self),
+			Fix:      fmt.Sprintf("Remove the other copy or put %s earlier in PATH", filepath.Dir(opts.Executable)),
+		}}
+	case len(found) > 1:
+		return []*Finding{{
+			Check:    check,
+			Severity: Warning,
+			Message:  fmt.Sprintf("other copies of cxa are shadowed on PATH: %s", strings.Join(found[1:], ", ")),
+			Fix:      "Remove stale copies to avoid confusion",
+		}}
+	}
+	return []*Finding{{Check: check, Severity: OK, Message: "cxa on PATH is this install"}}
+}
+
+func checkCompletion(opts InstallOptions) []*Finding {
+	const check = "completion"
+
+	for _, path := range opts.CompletionPaths {
+		if _, err := os.Stat(path); err == nil {
+			return []*Finding{{Check: check, Severity: OK, Message: fmt.Sprintf("completion installed at %s", path)}}
+		}
+	}
+	return []*Finding{{
+		Check:    check,
+		Severity: Warning,
+		Message:  "no shell completion installed",
+		Fix:      "See 'cxa completion --help' to install it for your shell",
+	}}
+}
+
+func (d *Doctor) checkWritable() []*Finding {
+	const check = "data dir"
+
+	var findings []*Finding
+	for _, dir := range []string{d.paths.DataDir, d.paths.StateDir} {
+		if err := probeWritable(dir); err != nil {
+			findings = append(findings, &Finding{
+				Check:    check,
+				Severity: Problem,
+				Message:  fmt.Sprintf("%s is not writable: %v", dir, err),
+				Fix:      fmt.Sprintf("Check the ownership of %s", dir),
+			})
+		}
+	}
+	if len(findings) == 0 {
+		findings = append(findings, &Finding{Check: check, Severity: OK, Message: "data and state directories are writable"})
+	}
+	return findings
+}
+
+// probeWritable creates dir if needed and writes a scratch file in it.
+func probeWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".cxa-probe-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// resolve follows symlinks such as package-manager shims, falling back to
+// the path itself.
+func resolve(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved
+	}
+	return path
+}
+
+func binaryName() string {
+	if runtime.GOOS == "windows" {
+		return "cxa.exe"
+	}
+	return "cxa"
+}
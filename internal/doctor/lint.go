@@ -0,0 +1,532 @@
+package doctor
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AccountFindings are the lint findings for one account's configuration.
+type AccountFindings struct {
+	Account  string
+	Findings []*Finding
+}
+
+// Lint validates the config.toml of each named account, or of every saved
+// account when names is empty. The current account is checked in ~/.codex,
+// since that is the copy codex reads.
+func (d *Doctor) Lint(names ...string) ([]AccountFindings, error) {
+	if len(names) == 0 {
+		accounts, err := d.repo.List()
+		if err != nil {
+			return nil, err
+		}
+		for _, acc := range accounts {
+			names = append(names, acc.Name)
+		}
+	}
+	current, _ := d.repo.Current()
+
+	var results []AccountFindings
+	for _, name := range names {
+		dir, err := d.repo.AccountDir(name)
+		if err != nil {
+			return nil, err
+		}
+		if name == current {
+			dir = d.paths.Home
+		}
+		results = append(results, AccountFindings{
+			Account:  name,
+			Findings: LintConfig(filepath.Join(dir, "config.toml")),
+		})
+	}
+	return results, nil
+}
+
+// LintConfig checks a codex config.toml for syntax errors, unknown keys,
+// MCP servers whose command cannot be found, and model settings that
+// contradict each other. A missing file is fine: codex uses its defaults.
+func LintConfig(path string) []*Finding {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return []*Finding{{Check: "config", Severity: OK, Message: "no config.toml (codex defaults)"}}
+	}
+	if err != nil {
+		return []*Finding{{Check: "config", Severity: Problem, Message: err.Error()}}
+	}
+
+	cfg, err := parseConfig(data)
+	if err != nil {
+		return []*Finding{{
+			Check:    "config",
+			Severity: Problem,
+			Message:  fmt.Sprintf("config.toml does not parse: %v", err),
+			Fix:      "Fix the syntax error; codex refuses to start with an invalid config",
+		}}
+	}
+
+	var findings []*Finding
+	findings = append(findings, lintKeys(cfg)...)
+	findings = append(findings, lintMCPServers(cfg)...)
+	findings = append(findings, lintModel(cfg)...)
+	if len(findings) == 0 {
+		findings = append(findings, &Finding{Check: "config", Severity: OK, Message: "config.toml looks good"})
+	}
+	return findings
+}
+
+// knownKeys are the top-level config.toml settings codex understands.
+// Keys starting with experimental_ are always accepted.
+var knownKeys = []string{
+	"approval_policy", "chatgpt_base_url", "cli_auth_credentials_store",
+	"disable_response_storage", "features", "file_opener",
+	"forced_chatgpt_workspace_id", "forced_login_method",
+	"hide_agent_reasoning", "history", "include_apply_patch_tool",
+	"include_plan_tool", "instructions", "mcp_oauth_credentials_store",
+	"mcp_servers", "model", "model_auto_compact_token_limit",
+	"model_context_window", "model_max_output_tokens", "model_provider",
+	"model_providers", "model_reasoning_effort", "model_reasoning_summary",
+	"model_reasoning_summary_format", "model_supports_reasoning_summaries",
+	"model_verbosity", "notice", "notify", "otel", "preferred_auth_method",
+	"profile", "profiles", "project_doc_fallback_filenames",
+	"project_doc_max_bytes", "projects", "review_model",
+	"sandbox_mode", "sandbox_workspace_write", "shell_environment_policy",
+	"show_raw_agent_reasoning", "tools", "tui",
+	"windows_wsl_setup_acknowledged",
+}
+
+// knownMCPKeys are the settings of an [mcp_servers.<name>] table.
+var knownMCPKeys = []string{
+	"args", "bearer_token", "bearer_token_env_var", "command", "cwd",
+	"disabled_tools", "enabled", "enabled_tools", "env", "env_http_headers",
+	"env_vars", "http_headers", "startup_timeout_ms", "startup_timeout_sec",
+	"tool_timeout_sec", "url",
+}
+
+// builtinProviders need no [model_providers.<name>] table.
+var builtinProviders = []string{"lmstudio", "ollama", "openai", "oss"}
+
+// allowedValues constrains the enumerated model settings, which codex also
+// accepts inside profiles.
+var allowedValues = map[string][]string{
+	"approval_policy":        {"never", "on-failure", "on-request", "untrusted"},
+	"sandbox_mode":           {"danger-full-access", "read-only", "workspace-write"},
+	"model_reasoning_effort": {"high", "low", "medium", "minimal", "none", "xhigh"},
+	"model_verbosity":        {"high", "low", "medium"},
+}
+
+func lintKeys(cfg *config) []*Finding {
+	var findings []*Finding
+	for _, e := range cfg.entries {
+		top := e.path[0]
+		if slices.Contains(knownKeys, top) || strings.HasPrefix(top, "experimental_") {
+			continue
+		}
+		findings = append(findings, &Finding{
+			Check:    "keys",
+			Severity: Warning,
+			Message:  fmt.Sprintf("line %d: unknown setting %q", e.line, strings.Join(e.path, ".")),
+			Fix:      "Check the spelling against the codex configuration reference; codex ignores or rejects unknown settings",
+		})
+	}
+	return findings
+}
+
+func lintMCPServers(cfg *config) []*Finding {
+	servers := cfg.tables("mcp_servers")
+	var findings []*Finding
+	for _, name := range servers {
+		check := "mcp " + name
+		server := cfg.table("mcp_servers", name)
+
+		for key, e := range server {
+			if !slices.Contains(knownMCPKeys, key) {
+				findings = append(findings, &Finding{
+					Check:    check,
+					Severity: Warning,
+					Message:  fmt.Sprintf("line %d: unknown server setting %q", e.line, key),
+				})
+			}
+		}
+
+		command, hasCommand := server["command"]
+		_, hasURL := server["url"]
+		switch {
+		case !hasCommand && !hasURL:
+			findings = append(findings, &Finding{
+				Check:    check,
+				Severity: Problem,
+				Message:  "server has neither command nor url",
+				Fix:      "Set command for a stdio server or url for an HTTP server",
+			})
+			continue
+		case hasCommand && hasURL:
+			findings = append(findings, &Finding{
+				Check:    check,
+				Severity: Problem,
+				Message:  "server sets both command and url",
+				Fix:      "Keep command for a stdio server or url for an HTTP server, not both",
+			})
+			continue
+		case hasURL:
+			continue
+		}
+
+		if enabled, ok := server["enabled"]; ok && enabled.value == "false" {
+			continue
+		}
+		cmd, err := unquote(command.value)
+		if err != nil {
+			findings = append(findings, &Finding{
+				Check:    check,
+				Severity: Problem,
+				Message:  fmt.Sprintf("line %d: command must be a string", command.line),
+			})
+			continue
+		}
+		if _, err := exec.LookPath(expandHome(cmd)); err != nil {
+			findings = append(findings, &Finding{
+				Check:    check,
+				Severity: Problem,
+				Message:  fmt.Sprintf("command %q cannot be found", cmd),
+				Fix:      "Install it or use an absolute path; codex starts MCP servers without your shell's PATH tweaks",
+			})
+		}
+	}
+	return findings
+}
+
+func lintModel(cfg *config) []*Finding {
+	const check = "model"
+
+	providers := append(slices.Clone(builtinProviders), cfg.tables("model_providers")...)
+	profiles := cfg.tables("profiles")
+
+	// Model settings apply at the top level and within each profile.
+	scopes := map[string]map[string]entry{"": cfg.table()}
+	for _, name := range profiles {
+		scopes["profile "+name+": "] = cfg.table("profiles", name)
+	}
+	prefixes := make([]string, 0, len(scopes))
+	for prefix := range scopes {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	var findings []*Finding
+	for _, prefix := range prefixes {
+		settings := scopes[prefix]
+
+		if e, ok := settings["model_provider"]; ok {
+			if name, _ := unquote(e.value); !slices.Contains(providers, name) {
+				findings = append(findings, &Finding{
+					Check:    check,
+					Severity: Problem,
+					Message:  fmt.Sprintf("%sline %d: model_provider %q is not defined", prefix, e.line, name),
+					Fix:      fmt.Sprintf("Add a [model_providers.%s] table or use one of %s", name, strings.Join(builtinProviders, ", ")),
+				})
+			}
+		}
+
+		keys := make([]string, 0, len(allowedValues))
+		for key := range allowedValues {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			e, ok := settings[key]
+			if !ok {
+				continue
+			}
+			if value, _ := unquote(e.value); !slices.Contains(allowedValues[key], value) {
+				findings = append(findings, &Finding{
+					Check:    check,
+					Severity: Problem,
+					Message:  fmt.Sprintf("%sline %d: %s = %s is not valid", prefix, e.line, key, e.value),
+					Fix:      "Use one of " + strings.Join(allowedValues[key], ", "),
+				})
+			}
+		}
+	}
+
+	if e, ok := cfg.table()["sandbox_mode"]; ok && len(cfg.table("sandbox_workspace_write")) > 0 {
+		if mode, _ := unquote(e.value); mode != "workspace-write" {
+			findings = append(findings, &Finding{
+				Check:    check,
+				Severity: Warning,
+				Message:  fmt.Sprintf("[sandbox_workspace_write] is ignored because sandbox_mode is %q", mode),
+			})
+		}
+	}
+
+	if e, ok := cfg.table()["profile"]; ok {
+		if name, _ := unquote(e.value); !slices.Contains(profiles, name) {
+			findings = append(findings, &Finding{
+				Check:    check,
+				Severity: Problem,
+				Message:  fmt.Sprintf("line %d: profile %q is not defined", e.line, name),
+				Fix:      fmt.Sprintf("Add a [profiles.%s] table or remove the profile setting", name),
+			})
+		}
+	}
+	return findings
+}
+
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
+// config is a config.toml reduced to its key paths and raw values, enough
+// to lint without a full TOML implementation.
+type config struct {
+	headers [][]string
+	entries []entry
+}
+
+type entry struct {
+	path  []string
+	value string
+	line  int
+}
+
+// table returns the settings directly inside the table at path, keyed by
+// their final name.
+func (c *config) table(path ...string) map[string]entry {
+	settings := make(map[string]entry)
+	for _, e := range c.entries {
+		if len(e.path) == len(path)+1 && slices.Equal(e.path[:len(path)], path) {
+			settings[e.path[len(path)]] = e
+		}
+	}
+	return settings
+}
+
+// tables returns the sorted names of the subtables of the table at path.
+func (c *config) tables(path ...string) []string {
+	var names []string
+	add := func(p []string, minLen int) {
+		if len(p) >= minLen && slices.Equal(p[:len(path)], path) {
+			if name := p[len(path)]; !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	for _, header := range c.headers {
+		add(header, len(path)+1)
+	}
+	for _, e := range c.entries {
+		add(e.path, len(path)+2)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func parseConfig(data []byte) (*config, error) {
+	cfg := &config{}
+	var section []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			header, ok := strings.CutPrefix(line, "[[")
+			if ok {
+				header, ok = strings.CutSuffix(header, "]]")
+			} else {
+				header, ok = strings.CutSuffix(line[1:], "]")
+			}
+			if !ok {
+				return nil, fmt.Errorf("line %d: malformed table header %s", n, line)
+			}
+			var err error
+			if section, err = splitKey(header); err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			cfg.headers = append(cfg.headers, section)
+			continue
+		}
+
+		rawKey, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+		key, err := splitKey(rawKey)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return nil, fmt.Errorf("line %d: missing value for %s", n, strings.TrimSpace(rawKey))
+		}
+
+		// Values may continue over several lines: multi-line strings until
+		// their closing delimiter, arrays and inline tables until balanced.
+		start := n
+		multiline := strings.HasPrefix(value, `"""`) || strings.HasPrefix(value, "'''")
+		for !complete(value) {
+			if !scanner.Scan() {
+				return nil, fmt.Errorf("line %d: unterminated value for %s", start, strings.TrimSpace(rawKey))
+			}
+			n++
+			next := scanner.Text()
+			if !multiline {
+				next = stripComment(next)
+			}
+			value += "\n" + next
+		}
+
+		if !multiline && !validScalar(value) {
+			return nil, fmt.Errorf("line %d: invalid value %s", start, value)
+		}
+
+		path := append(slices.Clone(section), key...)
+		cfg.entries = append(cfg.entries, entry{path: path, value: value, line: start})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// complete reports whether value holds a whole TOML value.
+func complete(value string) bool {
+	for _, delim := range []string{`"""`, `'''`} {
+		if strings.HasPrefix(value, delim) {
+			return len(value) >= 6 && strings.Contains(value[3:], delim)
+		}
+	}
+
+	depth := 0
+	var quote rune
+	escaped := false
+	for _, r := range value {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if r == '\\' && quote == '"' {
+				escaped = true
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '[' || r == '{':
+			depth++
+		case r == ']' || r == '}':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+// validScalar reports whether value is a plausible TOML value. Arrays and
+// inline tables are taken on trust; strings, booleans, and numbers are
+// checked, which catches unquoted strings such as model = gpt-5.
+func validScalar(value string) bool {
+	switch {
+	case strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{"):
+		return true
+	case strings.HasPrefix(value, `"`):
+		_, err := strconv.Unquote(value)
+		return err == nil
+	case strings.HasPrefix(value, "'"):
+		return len(value) >= 2 && strings.HasSuffix(value, "'") && !strings.Contains(value[1:len(value)-1], "'")
+	case value == "true" || value == "false":
+		return true
+	}
+
+	number := strings.ReplaceAll(value, "_", "")
+	if _, err := strconv.ParseFloat(number, 64); err == nil {
+		return true
+	}
+	if _, err := strconv.ParseInt(number, 0, 64); err == nil {
+		return true
+	}
+	// Dates and times start with a digit and contain no spaces but the
+	// optional one separating date from time.
+	return value[0] >= '0' && value[0] <= '9' && strings.Count(value, " ") <= 1
+}
+
+// splitKey splits a dotted key, honouring quoted parts.
+func splitKey(key string) ([]string, error) {
+	var parts []string
+	rest := strings.TrimSpace(key)
+	for {
+		var part string
+		switch {
+		case strings.HasPrefix(rest, `"`) || strings.HasPrefix(rest, "'"):
+			end := strings.IndexByte(rest[1:], rest[0])
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted key %s", key)
+			}
+			part, rest = rest[1:end+1], strings.TrimSpace(rest[end+2:])
+		default:
+			end := strings.IndexByte(rest, '.')
+			if end < 0 {
+				end = len(rest)
+			}
+			part, rest = strings.TrimSpace(rest[:end]), rest[end:]
+			if part == "" || strings.ContainsAny(part, " \t\"'") {
+				return nil, fmt.Errorf("invalid key %s", strings.TrimSpace(key))
+			}
+		}
+		parts = append(parts, part)
+
+		if rest == "" {
+			return parts, nil
+		}
+		if !strings.HasPrefix(rest, ".") {
+			return nil, fmt.Errorf("invalid key %s", strings.TrimSpace(key))
+		}
+		rest = strings.TrimSpace(rest[1:])
+	}
+}
+
+// stripComment removes a # comment that is not inside a string.
+func stripComment(line string) string {
+	var quote rune
+	escaped := false
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if r == '\\' && quote == '"' {
+				escaped = true
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// unquote returns the contents of a basic or literal TOML string.
+func unquote(value string) (string, error) {
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return value[1 : len(value)-1], nil
+	}
+	return strconv.Unquote(value)
+}
@@ -0,0 +1,93 @@
+// Package flock keeps cxa invocations from changing accounts at the same
+// time. Mutating operations hold an advisory lock on a file in the state
+// directory, so a script switching accounts while the TUI saves one waits
+// for it, or fails cleanly, instead of interleaving copies.
+package flock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrBusy is returned when another process holds the lock for longer than
+// the caller is willing to wait.
+var ErrBusy = errors.New("another cxa operation is in progress; try again when it finishes")
+
+// DefaultWait is how long operations wait for another cxa process to
+// finish before giving up with ErrBusy.
+const DefaultWait = 5 * time.Second
+
+// pollInterval is how often a held lock is retried.
+const pollInterval = 50 * time.Millisecond
+
+type held struct {
+	file  *os.File
+	count int
+}
+
+var (
+	mu    sync.Mutex
+	locks = make(map[string]*held)
+)
+
+// Acquire takes the lock on the file at path, creating it if needed, and
+// waits up to wait for another process to release it. The lock is
+// reentrant within a process, so an operation holding it can call others
+// that take it too. Call release once the operation is done.
+func Acquire(path string, wait time.Duration) (release func(), err error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if h, ok := locks[path]; ok {
+		h.count++
+		return func() { releaseHeld(path) }, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		ok, err := tryLock(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		if ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, ErrBusy
+		}
+		time.Sleep(pollInterval)
+	}
+
+	locks[path] = &held{file: file, count: 1}
+	return func() { releaseHeld(path) }, nil
+}
+
+func releaseHeld(path string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	h, ok := locks[path]
+	if !ok {
+		return
+	}
+	h.count--
+	if h.count > 0 {
+		return
+	}
+	delete(locks, path)
+	unlock(h.file)
+	h.file.Close()
+}
@@ -0,0 +1,85 @@
+package flock_test
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/delhombre/cxa/internal/flock"
+)
+
+// TestHelperHoldLock is not a real test: it holds the lock named by
+// CXA_FLOCK_PATH until stdin closes, standing in for another cxa process.
+func TestHelperHoldLock(t *testing.T) {
+	path := os.Getenv("CXA_FLOCK_PATH")
+	if path == "" {
+		t.Skip("helper process")
+	}
+	release, err := flock.Acquire(path, 0)
+	if err != nil {
+		t.Fatalf("helper failed to lock: %v", err)
+	}
+	defer release()
+	os.Stdout.WriteString("locked\n")
+	bufio.NewReader(os.Stdin).ReadString('\n')
+}
+
+func TestAcquire_OtherProcess(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperHoldLock$")
+	cmd.Env = append(os.Environ(), "CXA_FLOCK_PATH="+path)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if line, _ := bufio.NewReader(stdout).ReadString('\n'); line != "locked\n" {
+		t.Fatalf("helper did not take the lock: %q", line)
+	}
+
+	if _, err := flock.Acquire(path, 100*time.Millisecond); !errors.Is(err, flock.ErrBusy) {
+		t.Errorf("expected ErrBusy while another process holds the lock, got %v", err)
+	}
+
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("helper failed: %v", err)
+	}
+	release, err := flock.Acquire(path, time.Second)
+	if err != nil {
+		t.Fatalf("Acquire after the other process exited failed: %v", err)
+	}
+	release()
+}
+
+func TestAcquire_Reentrant(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "lock")
+
+	outer, err := flock.Acquire(path, 0)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	inner, err := flock.Acquire(path, 0)
+	if err != nil {
+		t.Fatalf("nested Acquire in the same process failed: %v", err)
+	}
+	inner()
+	outer()
+
+	again, err := flock.Acquire(path, 0)
+	if err != nil {
+		t.Fatalf("Acquire after release failed: %v", err)
+	}
+	again()
+}
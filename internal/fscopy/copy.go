@@ -0,0 +1,209 @@
+package fscopy
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Options tunes Tree.
+type Options struct {
+	// Root is where the copy will finally live, when dst is a staging
+	// directory renamed into place afterwards. Empty means dst.
+	Root string
+
+	// Wrap, if set, wraps the writer of every copied file, for example to
+	// throttle it.
+	Wrap func(io.Writer) io.Writer
+}
+
+// Tree copies the directory src to dst, keeping mode bits, modification
+// times, and symlinks. Absolute symlink targets inside src are rewritten to
+// the same place under the copy, so links within the tree keep pointing
+// into it; other targets are kept as they are. Special files such as
+// sockets are skipped. Files are copied on a Pool.
+func Tree(src, dst string, opts Options) error {
+	root := opts.Root
+	if root == "" {
+		root = dst
+	}
+
+	pool := NewPool()
+	var dirs DirTimes
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := pool.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			return Symlink(path, target, src, root)
+		case info.IsDir():
+			if err := os.MkdirAll(target, info.Mode().Perm()); err != nil {
+				return err
+			}
+			dirs.Add(target, info)
+			return os.Chmod(target, info.Mode().Perm())
+		case !info.Mode().IsRegular():
+			return nil
+		}
+
+		pool.Go(func() error {
+			return File(path, target, 0, opts.Wrap)
+		})
+		return nil
+	})
+	if waitErr := pool.Wait(); err == nil {
+		err = waitErr
+	}
+	if err != nil {
+		return err
+	}
+	return dirs.Apply()
+}
+
+// CloneTree makes dst a copy-on-write clone of the directory src in a
+// single step, which APFS supports: it takes no time and no space until
+// either side changes, however large src is. Like Tree, absolute symlinks
+// inside src are rewritten to point under root, or dst when root is empty.
+// dst must not exist. Elsewhere it fails with errors.ErrUnsupported, and
+// callers copy with Tree instead, which still clones file by file where
+// the filesystem can (btrfs, XFS).
+func CloneTree(src, dst, root string) error {
+	if root == "" {
+		root = dst
+	}
+	if err := reflinkTree(src, dst); err != nil {
+		return err
+	}
+	return filepath.Walk(dst, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			return err
+		}
+		return Symlink(path, path, src, root)
+	})
+}
+
+// File copies the regular file src to dst, giving dst the mode bits and
+// modification time of src. A copy from offset zero is reflinked where the
+// filesystem allows it (APFS, btrfs, XFS), which shares blocks instead of
+// copying them; anywhere else, including across filesystems, the bytes are
+// copied. A non-zero offset keeps the first offset bytes already in dst and
+// copies the rest, to resume an interrupted copy. Wrap, if set, wraps the
+// writer of dst.
+func File(src, dst string, offset int64, wrap func(io.Writer) io.Writer) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	srcInfo, err := srcFile.Stat()
+	if err != nil {
+		return err
+	}
+	mode := srcInfo.Mode().Perm()
+
+	if offset == 0 && reflink(src, dst, mode) == nil {
+		return finish(dst, srcInfo)
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY
+	if offset == 0 {
+		flags |= os.O_TRUNC
+	}
+	dstFile, err := os.OpenFile(dst, flags, mode)
+	if err != nil {
+		return err
+	}
+	defer dstFile.Close()
+
+	if offset > 0 {
+		if err := dstFile.Truncate(offset); err != nil {
+			return err
+		}
+		if _, err := srcFile.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := dstFile.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	var w io.Writer = dstFile
+	if wrap != nil {
+		w = wrap(dstFile)
+	}
+	if _, err := io.Copy(w, srcFile); err != nil {
+		return err
+	}
+	if err := dstFile.Close(); err != nil {
+		return err
+	}
+	return finish(dst, srcInfo)
+}
+
+// finish gives dst the mode bits, unaffected by the umask, and the
+// modification time of the file described by info.
+func finish(dst string, info os.FileInfo) error {
+	if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// Symlink recreates at dst the symlink at src. If its target is absolute
+// and inside srcRoot, it is rewritten to the same place under dstRoot.
+// Anything already at dst is replaced.
+func Symlink(src, dst, srcRoot, dstRoot string) error {
+	link, err := os.Readlink(src)
+	if err != nil {
+		return err
+	}
+	if filepath.IsAbs(link) {
+		if rel, err := filepath.Rel(srcRoot, link); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			link = filepath.Join(dstRoot, rel)
+		}
+	}
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	return os.Symlink(link, dst)
+}
+
+// DirTimes collects directory modification times to restore once a copy
+// has finished writing into the directories, which changes them.
+type DirTimes struct {
+	dirs []dirTime
+}
+
+type dirTime struct {
+	path    string
+	modTime time.Time
+}
+
+// Add records that the directory at path should get the modification time
+// of the source directory described by info.
+func (d *DirTimes) Add(path string, info os.FileInfo) {
+	d.dirs = append(d.dirs, dirTime{path: path, modTime: info.ModTime()})
+}
+
+// Apply sets the recorded times.
+func (d *DirTimes) Apply() error {
+	for _, dir := range d.dirs {
+		if err := os.Chtimes(dir.path, dir.modTime, dir.modTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
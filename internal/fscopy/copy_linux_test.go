@@ -0,0 +1,77 @@
+package fscopy_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/delhombre/cxa/internal/fscopy"
+	"golang.org/x/sys/unix"
+)
+
+// noReflinkDir returns a temporary directory on a filesystem that refuses
+// FICLONE, trying the test's own and then tmpfs, or skips the test.
+func noReflinkDir(t *testing.T) string {
+	t.Helper()
+	for _, parent := range []string{"", "/dev/shm"} {
+		dir, err := os.MkdirTemp(parent, "fscopy-")
+		if err != nil {
+			continue
+		}
+		t.Cleanup(func() { os.RemoveAll(dir) })
+
+		src, err := os.Create(filepath.Join(dir, "probe-src"))
+		if err != nil {
+			continue
+		}
+		src.WriteString("probe")
+		dst, err := os.Create(filepath.Join(dir, "probe-dst"))
+		if err != nil {
+			src.Close()
+			continue
+		}
+		err = unix.IoctlFileClone(int(dst.Fd()), int(src.Fd()))
+		src.Close()
+		dst.Close()
+		if err != nil {
+			return dir
+		}
+	}
+	t.Skip("no filesystem without reflink support available")
+	return ""
+}
+
+func TestFile_FallsBackWithoutReflink(t *testing.T) {
+	dir := noReflinkDir(t)
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	if err := os.WriteFile(src, []byte("hello world"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(src, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	// A stale, longer dst must be replaced, not partly overwritten
+	if err := os.WriteFile(dst, []byte("stale content that is longer"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fscopy.File(src, dst, 0, nil); err != nil {
+		t.Fatalf("File failed: %v", err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "hello world" {
+		t.Errorf("expected the bytes copied, got %q", data)
+	}
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("expected modification time %v, got %v", mtime, info.ModTime())
+	}
+}
@@ -0,0 +1,123 @@
+package fscopy_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/delhombre/cxa/internal/fscopy"
+)
+
+func TestTree(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "src")
+	dst := filepath.Join(tmp, "dst")
+	if err := os.MkdirAll(filepath.Join(src, "sessions"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "auth.json"), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "sessions", "a.jsonl"), []byte("turn\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, p := range []string{filepath.Join(src, "auth.json"), filepath.Join(src, "sessions")} {
+		if err := os.Chtimes(p, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	links := map[string]string{
+		"inside":   filepath.Join(src, "sessions"),
+		"relative": "sessions/a.jsonl",
+		"outside":  filepath.Join(tmp, "shared"),
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(src, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := fscopy.Tree(src, dst, fscopy.Options{}); err != nil {
+		t.Fatalf("Tree failed: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(dst, "auth.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+	if !info.ModTime().Equal(old) {
+		t.Errorf("expected file time %v, got %v", old, info.ModTime())
+	}
+	if info, err := os.Stat(filepath.Join(dst, "sessions")); err != nil || !info.ModTime().Equal(old) {
+		t.Errorf("expected directory time %v, got %v (%v)", old, info.ModTime(), err)
+	}
+
+	want := map[string]string{
+		"inside":   filepath.Join(dst, "sessions"),
+		"relative": "sessions/a.jsonl",
+		"outside":  filepath.Join(tmp, "shared"),
+	}
+	for name, target := range want {
+		if got, err := os.Readlink(filepath.Join(dst, name)); err != nil || got != target {
+			t.Errorf("link %s: expected %s, got %s (%v)", name, target, got, err)
+		}
+	}
+}
+
+func TestCloneTree(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "src")
+	dst := filepath.Join(tmp, "dst")
+	if err := os.MkdirAll(filepath.Join(src, "sessions"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "sessions", "run.jsonl"), []byte("turn"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(src, "sessions"), filepath.Join(src, "latest")); err != nil {
+		t.Fatal(err)
+	}
+
+	err := fscopy.CloneTree(src, dst, "")
+	if errors.Is(err, errors.ErrUnsupported) {
+		if _, err := os.Lstat(dst); !os.IsNotExist(err) {
+			t.Error("an unsupported clone should leave nothing behind")
+		}
+		t.Skip("whole-tree clones are not supported here")
+	}
+	if err != nil {
+		t.Fatalf("CloneTree failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "sessions", "run.jsonl")); err != nil || string(data) != "turn" {
+		t.Errorf("expected the file cloned, got %q (%v)", data, err)
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "latest")); err != nil || link != filepath.Join(dst, "sessions") {
+		t.Errorf("expected the link rewritten into the clone, got %q (%v)", link, err)
+	}
+}
+
+func TestFile_Resumes(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "src")
+	dst := filepath.Join(tmp, "dst")
+	if err := os.WriteFile(src, []byte("hello world"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fscopy.File(src, dst, 5, nil); err != nil {
+		t.Fatalf("File failed: %v", err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "hello world" {
+		t.Errorf("expected the rest of the file to be appended, got %q", data)
+	}
+}
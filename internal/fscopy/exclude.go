@@ -0,0 +1,93 @@
+package fscopy
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Excludes is a list of glob patterns naming paths to leave out of a copy,
+// relative to its root and written with forward slashes:
+//
+//   - a pattern ending in "/" matches directories only;
+//   - a pattern with no other "/" matches a name at any depth, so "*.log"
+//     matches every log file;
+//   - "**" as a whole segment matches any number of directories, so
+//     "sessions/**/*.log" matches logs anywhere under sessions;
+//   - anything else, including a pattern starting with "/", is matched
+//     from the root.
+//
+// Everything under an excluded directory is excluded too.
+type Excludes []string
+
+// CheckPattern reports whether pattern is a valid exclude pattern.
+func CheckPattern(pattern string) error {
+	trimmed := strings.Trim(pattern, "/")
+	if trimmed == "" {
+		return fmt.Errorf("invalid exclude pattern %q: it matches nothing", pattern)
+	}
+	for _, seg := range strings.Split(trimmed, "/") {
+		if _, err := path.Match(seg, ""); err != nil {
+			return fmt.Errorf("invalid exclude pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Match reports whether rel, a slash-separated path relative to the copy
+// root that names a directory if dir is true, is excluded.
+func (e Excludes) Match(rel string, dir bool) bool {
+	if len(e) == 0 || rel == "." || rel == "" {
+		return false
+	}
+	segs := strings.Split(rel, "/")
+	for _, pattern := range e {
+		// Any ancestor matching excludes everything under it
+		for i := 1; i < len(segs); i++ {
+			if matchPattern(pattern, segs[:i], true) {
+				return true
+			}
+		}
+		if matchPattern(pattern, segs, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPattern(pattern string, segs []string, dir bool) bool {
+	if strings.HasSuffix(pattern, "/") {
+		if !dir {
+			return false
+		}
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, segs[len(segs)-1])
+		return ok
+	}
+	return matchSegments(strings.Split(strings.TrimPrefix(pattern, "/"), "/"), segs)
+}
+
+// matchSegments matches pattern segments against path segments, with "**"
+// standing for zero or more path segments.
+func matchSegments(pattern, segs []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segs); i++ {
+				if matchSegments(pattern[1:], segs[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segs) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segs[0]); !ok {
+			return false
+		}
+		pattern, segs = pattern[1:], segs[1:]
+	}
+	return len(segs) == 0
+}
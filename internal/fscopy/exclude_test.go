@@ -0,0 +1,46 @@
+package fscopy_test
+
+import (
+	"testing"
+
+	"github.com/delhombre/cxa/internal/fscopy"
+)
+
+func TestExcludes_Match(t *testing.T) {
+	excludes := fscopy.Excludes{"sessions/**/*.log", "cache/", "*.tmp", "/log"}
+	cases := []struct {
+		path string
+		dir  bool
+		want bool
+	}{
+		{"sessions/2024/05/run.log", false, true},
+		{"sessions/run.log", false, true},
+		{"sessions/2024/run.jsonl", false, false},
+		{"cache", true, true},
+		{"cache/models/x.bin", false, true},
+		{"cache", false, false},
+		{"sessions/cache/x", false, true},
+		{"deep/nested/scratch.tmp", false, true},
+		{"log", true, true},
+		{"sessions/log", true, false},
+		{"auth.json", false, false},
+	}
+	for _, c := range cases {
+		if got := excludes.Match(c.path, c.dir); got != c.want {
+			t.Errorf("Match(%q, dir=%v) = %v, want %v", c.path, c.dir, got, c.want)
+		}
+	}
+}
+
+func TestCheckPattern(t *testing.T) {
+	for _, p := range []string{"cache/", "sessions/**/*.log", "*.tmp"} {
+		if err := fscopy.CheckPattern(p); err != nil {
+			t.Errorf("CheckPattern(%q) failed: %v", p, err)
+		}
+	}
+	for _, p := range []string{"", "/", "sessions/[", "a/[b/c"} {
+		if err := fscopy.CheckPattern(p); err == nil {
+			t.Errorf("CheckPattern(%q) should fail", p)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Package fscopy copies files and directory trees faithfully, keeping mode
+// bits, modification times, and symlinks, for every part of cxa that copies
+// accounts. Trees are walked on one goroutine, which creates directories in
+// order, and each file is handed to a bounded pool of workers; thousands of
+// small session files copy several times faster that way than one after
+// another.
+package fscopy
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// MaxAutoWorkers caps the worker count chosen when none is configured.
+const MaxAutoWorkers = 8
+
+var workers atomic.Int64
+
+// SetWorkers sets how many files are copied at once. Zero or less picks a
+// count from the number of CPUs, up to MaxAutoWorkers; one copies files
+// one at a time.
+func SetWorkers(n int) {
+	workers.Store(int64(n))
+}
+
+// Workers returns how many files are copied at once.
+func Workers() int {
+	if n := int(workers.Load()); n > 0 {
+		return n
+	}
+	return min(runtime.NumCPU(), MaxAutoWorkers)
+}
+
+// Pool runs copies on at most Workers goroutines and collects the first
+// error.
+type Pool struct {
+	group *errgroup.Group
+	ctx   context.Context
+}
+
+// NewPool returns an empty pool.
+func NewPool() *Pool {
+	group, ctx := errgroup.WithContext(context.Background())
+	group.SetLimit(Workers())
+	return &Pool{group: group, ctx: ctx}
+}
+
+// Go runs fn on a worker, waiting for one to be free.
+func (p *Pool) Go(fn func() error) {
+	p.group.Go(fn)
+}
+
+// Err returns the first error a copy failed with so far, so the walk
+// feeding the pool can stop early.
+func (p *Pool) Err() error {
+	if p.ctx.Err() != nil {
+		return context.Cause(p.ctx)
+	}
+	return nil
+}
+
+// Wait waits for every copy and returns the first error.
+func (p *Pool) Wait() error {
+	return p.group.Wait()
+}
@@ -0,0 +1,60 @@
+package fscopy_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/delhombre/cxa/internal/fscopy"
+)
+
+func TestPool_LimitsWorkers(t *testing.T) {
+	fscopy.SetWorkers(2)
+	t.Cleanup(func() { fscopy.SetWorkers(0) })
+
+	var running, peak atomic.Int64
+	release := make(chan struct{})
+	pool := fscopy.NewPool()
+	for i := 0; i < 6; i++ {
+		pool.Go(func() error {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			if i == 0 {
+				close(release)
+			}
+			<-release
+			running.Add(-1)
+			return nil
+		})
+	}
+	if err := pool.Wait(); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if peak.Load() > 2 {
+		t.Errorf("expected at most 2 copies at once, saw %d", peak.Load())
+	}
+}
+
+func TestPool_ReportsFirstError(t *testing.T) {
+	errCopy := errors.New("disk full")
+	pool := fscopy.NewPool()
+	pool.Go(func() error { return errCopy })
+	if err := pool.Wait(); !errors.Is(err, errCopy) {
+		t.Errorf("expected the copy error, got %v", err)
+	}
+	if err := pool.Err(); !errors.Is(err, errCopy) {
+		t.Errorf("Err should report the copy error, got %v", err)
+	}
+}
+
+func TestWorkers_DefaultsFromCPUs(t *testing.T) {
+	fscopy.SetWorkers(0)
+	if n := fscopy.Workers(); n < 1 || n > fscopy.MaxAutoWorkers {
+		t.Errorf("expected between 1 and %d workers, got %d", fscopy.MaxAutoWorkers, n)
+	}
+}
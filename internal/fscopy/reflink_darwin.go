@@ -0,0 +1,23 @@
+package fscopy
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// reflink makes dst a copy-on-write clone of src with clonefile(2), which
+// APFS supports. The clone shares src's blocks until either file is
+// written, so it costs no copying.
+func reflink(src, dst string, mode os.FileMode) error {
+	if err := unix.Clonefile(src, dst, unix.CLONE_NOFOLLOW); err != nil {
+		return err
+	}
+	return os.Chmod(dst, mode)
+}
+
+// reflinkTree clones the whole directory tree src to dst with one
+// clonefile(2) call. dst must not exist.
+func reflinkTree(src, dst string) error {
+	return unix.Clonefile(src, dst, unix.CLONE_NOFOLLOW)
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !darwin
+
+package fscopy
+
+import (
+	"errors"
+	"os"
+)
+
+// reflink is not supported on this platform; files are always copied.
+func reflink(src, dst string, mode os.FileMode) error {
+	return errors.ErrUnsupported
+}
+
+// reflinkTree is not supported on this platform either.
+func reflinkTree(src, dst string) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,109 @@
+// Package history keeps an append-only log of account operations, so
+// `cxa history` can show what happened when.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Op is a kind of recorded operation.
+type Op string
+
+const (
+	OpSave     Op = "save"
+	OpSwitch   Op = "switch"
+	OpDelete   Op = "delete"
+	OpRename   Op = "rename"
+	OpRollback Op = "rollback"
+	OpRestore  Op = "restore"
+)
+
+// Entry is one recorded operation.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Op      Op        `json:"op"`
+	Account string    `json:"account"`
+
+	// From is the previously active account for a switch and the old name
+	// for a rename or a restore.
+	From string `json:"from,omitempty"`
+
+	// Snapshot is the snapshot an account was rolled back to.
+	Snapshot string `json:"snapshot,omitempty"`
+}
+
+// Log is the history file on disk, one JSON entry per line.
+type Log struct {
+	path string
+}
+
+// NewLog returns a log backed by the file at path.
+func NewLog(path string) *Log {
+	return &Log{path: path}
+}
+
+// Append adds e to the log, stamping it with the current time if it has
+// none.
+func (l *Log) Append(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Recent returns up to limit of the latest entries, newest first. With an
+// account, only entries involving it are returned. A limit of zero or less
+// returns everything. Lines that do not parse, such as one cut short by a
+// crash, are skipped.
+func (l *Log) Recent(account string, limit int) ([]Entry, error) {
+	f, err := os.Open(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Entry{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		if account != "" && e.Account != account && e.From != account {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	recent := make([]Entry, 0, len(entries))
+	for i := len(entries) - 1; i >= 0 && (limit <= 0 || len(recent) < limit); i-- {
+		recent = append(recent, entries[i])
+	}
+	return recent, nil
+}
@@ -0,0 +1,57 @@
+package history_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/delhombre/cxa/internal/history"
+)
+
+func TestLog_AppendAndRecent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "history.log")
+	log := history.NewLog(path)
+
+	entries, err := log.Recent("", 10)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("expected an empty history, got %v, %v", entries, err)
+	}
+
+	for _, e := range []history.Entry{
+		{Op: history.OpSave, Account: "work"},
+		{Op: history.OpSwitch, Account: "personal", From: "work"},
+		{Op: history.OpSave, Account: "personal"},
+		{Op: history.OpDelete, Account: "old"},
+	} {
+		if err := log.Append(e); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	// A line cut short by a crash is skipped
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op": "sa`)
+	f.Close()
+
+	entries, err = log.Recent("", 2)
+	if err != nil {
+		t.Fatalf("Recent failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Op != history.OpDelete || entries[1].Account != "personal" {
+		t.Errorf("expected the two newest entries first, got %+v", entries)
+	}
+	if entries[0].Time.IsZero() {
+		t.Error("entries should be timestamped")
+	}
+
+	entries, err = log.Recent("work", 0)
+	if err != nil {
+		t.Fatalf("Recent failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Op != history.OpSwitch || entries[1].Op != history.OpSave {
+		t.Errorf("expected the switch away from work and its save, got %+v", entries)
+	}
+}
@@ -0,0 +1,64 @@
+// Package keychain keeps secrets in the operating system's credential
+// store: the macOS Keychain, the Secret Service (GNOME Keyring, KWallet)
+// through libsecret on Linux, or the Windows Credential Manager. Secrets
+// are filed under a service name and an account name.
+package keychain
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNotFound is returned when the store holds no secret for an
+	// account.
+	ErrNotFound = errors.New("secret not found in the keychain")
+
+	// ErrUnsupported is returned on platforms without a supported store.
+	ErrUnsupported = errors.New("no keychain is supported on this platform")
+)
+
+// Keychain stores secrets under Service in the system credential store.
+type Keychain struct {
+	Service string
+}
+
+// New returns the keychain holding cxa's secrets.
+func New() *Keychain {
+	return &Keychain{Service: "cxa"}
+}
+
+// Set stores secret for account, replacing any secret already there.
+func (k *Keychain) Set(account string, secret []byte) error {
+	if err := set(k.Service, account, secret); err != nil {
+		return fmt.Errorf("failed to store '%s' in the keychain: %w", account, err)
+	}
+	return nil
+}
+
+// Get returns the secret stored for account, or ErrNotFound.
+func (k *Keychain) Get(account string) ([]byte, error) {
+	secret, err := get(k.Service, account)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("failed to read '%s' from the keychain: %w", account, err)
+	}
+	return secret, err
+}
+
+// Check reports whether the keychain can be used, by looking up a secret
+// that is not there.
+func (k *Keychain) Check() error {
+	if _, err := get(k.Service, "cxa-check"); err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("the keychain is not available: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the secret stored for account. Deleting a secret that is
+// not there is not an error.
+func (k *Keychain) Delete(account string) error {
+	if err := del(k.Service, account); err != nil && !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("failed to remove '%s' from the keychain: %w", account, err)
+	}
+	return nil
+}
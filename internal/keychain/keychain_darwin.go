@@ -0,0 +1,61 @@
+//go:build darwin
+
+package keychain
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// errItemNotFound is the exit status of security(1) for a missing item.
+const errItemNotFound = 44
+
+// Secrets are stored base64-encoded, as security(1) prints binary or
+// multi-line passwords in a form that cannot be read back reliably. They
+// are passed on standard input rather than as arguments, where other users
+// could see them.
+func set(service, account string, secret []byte) error {
+	command := fmt.Sprintf("add-generic-password -U -s %q -a %q -w %q\n",
+		service, account, base64.StdEncoding.EncodeToString(secret))
+	return run(exec.Command("security", "-i"), command)
+}
+
+func get(service, account string) ([]byte, error) {
+	var stdout bytes.Buffer
+	cmd := exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w")
+	cmd.Stdout = &stdout
+	if err := run(cmd, ""); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimSpace(stdout.String()))
+}
+
+func del(service, account string) error {
+	return run(exec.Command("security", "delete-generic-password", "-s", service, "-a", account), "")
+}
+
+func run(cmd *exec.Cmd, stdin string) error {
+	var stderr bytes.Buffer
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == errItemNotFound:
+		return ErrNotFound
+	case err != nil:
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	// security -i reports failures of its commands on stderr only
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return errors.New(msg)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+//go:build linux
+
+package keychain
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// The Secret Service is reached with secret-tool(1) from libsecret, which
+// reads the secret from standard input. Secrets are stored base64-encoded
+// so trailing newlines survive.
+func set(service, account string, secret []byte) error {
+	cmd := exec.Command("secret-tool", "store", "--label", service+": "+account, "service", service, "account", account)
+	_, err := run(cmd, base64.StdEncoding.EncodeToString(secret))
+	return err
+}
+
+func get(service, account string) ([]byte, error) {
+	out, err := run(exec.Command("secret-tool", "lookup", "service", service, "account", account), "")
+	if err != nil {
+		return nil, err
+	}
+	if out == "" {
+		return nil, ErrNotFound
+	}
+	return base64.StdEncoding.DecodeString(out)
+}
+
+func del(service, account string) error {
+	_, err := run(exec.Command("secret-tool", "clear", "service", service, "account", account), "")
+	return err
+}
+
+func run(cmd *exec.Cmd, stdin string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if errors.Is(err, exec.ErrNotFound) {
+		return "", errors.New("secret-tool not found; install libsecret-tools")
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && stderr.Len() == 0 {
+		// lookup fails silently when there is no such secret
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,9 @@
+//go:build !darwin && !linux && !windows
+
+package keychain
+
+func set(service, account string, secret []byte) error { return ErrUnsupported }
+
+func get(service, account string) ([]byte, error) { return nil, ErrUnsupported }
+
+func del(service, account string) error { return ErrUnsupported }
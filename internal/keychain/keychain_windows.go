@@ -0,0 +1,140 @@
+//go:build windows
+
+package keychain
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+
+	// maxBlob is CRED_MAX_CREDENTIAL_BLOB_SIZE. Longer secrets, such as
+	// auth.json with its tokens, are split over numbered credentials.
+	maxBlob = 5 * 512
+)
+
+var (
+	advapi32       = windows.NewLazySystemDLL("advapi32.dll")
+	procCredWrite  = advapi32.NewProc("CredWriteW")
+	procCredRead   = advapi32.NewProc("CredReadW")
+	procCredDelete = advapi32.NewProc("CredDeleteW")
+	procCredFree   = advapi32.NewProc("CredFree")
+)
+
+// credential mirrors CREDENTIALW.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        windows.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+func target(service, account string, part int) string {
+	if part == 0 {
+		return service + ":" + account
+	}
+	return fmt.Sprintf("%s:%s#%d", service, account, part)
+}
+
+func set(service, account string, secret []byte) error {
+	if err := del(service, account); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	for part := 0; part == 0 || len(secret) > 0; part++ {
+		chunk := secret[:min(len(secret), maxBlob)]
+		secret = secret[len(chunk):]
+		if err := write(target(service, account, part), account, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func write(name, user string, blob []byte) error {
+	targetName, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+	userName, err := windows.UTF16PtrFromString(user)
+	if err != nil {
+		return err
+	}
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         targetName,
+		UserName:           userName,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+	if ret, _, err := procCredWrite.Call(uintptr(unsafe.Pointer(&cred)), 0); ret == 0 {
+		return err
+	}
+	return nil
+}
+
+func get(service, account string) ([]byte, error) {
+	var secret []byte
+	for part := 0; ; part++ {
+		chunk, err := read(target(service, account, part))
+		if errors.Is(err, ErrNotFound) && part > 0 {
+			return secret, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		secret = append(secret, chunk...)
+	}
+}
+
+func read(name string) ([]byte, error) {
+	targetName, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	var cred *credential
+	ret, _, err := procCredRead.Call(uintptr(unsafe.Pointer(targetName)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if ret == 0 {
+		if errors.Is(err, windows.ERROR_NOT_FOUND) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+	return append([]byte(nil), unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)...), nil
+}
+
+func del(service, account string) error {
+	for part := 0; ; part++ {
+		targetName, err := windows.UTF16PtrFromString(target(service, account, part))
+		if err != nil {
+			return err
+		}
+		ret, _, err := procCredDelete.Call(uintptr(unsafe.Pointer(targetName)), credTypeGeneric, 0)
+		if ret == 0 {
+			if errors.Is(err, windows.ERROR_NOT_FOUND) {
+				if part == 0 {
+					return ErrNotFound
+				}
+				return nil
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Package logging records cxa's diagnostics. Every run appends debug
+// records to a log file in the state directory, so a failed switch can be
+// reported with what led up to it; --verbose also prints them on stderr.
+package logging
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// MaxSize is the size past which the log file is rotated. One rotated file
+// is kept next to it, with a .1 suffix.
+const MaxSize = 1 << 20
+
+// Discard is a logger that drops every record.
+var Discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// Open returns a logger writing debug records to the log file at path, and
+// to verbose as well unless it is nil. The file is rotated first if it has
+// grown past MaxSize. If the file cannot be opened, records only go to
+// verbose: diagnostics are never worth failing a command over.
+func Open(path string, verbose io.Writer) (*slog.Logger, io.Closer) {
+	var writers []io.Writer
+	var closer io.Closer = nopCloser{}
+
+	if f, err := openFile(path); err == nil {
+		writers = append(writers, f)
+		closer = f
+	}
+	if verbose != nil {
+		writers = append(writers, verbose)
+	}
+	if len(writers) == 0 {
+		return Discard, closer
+	}
+
+	handler := slog.NewTextHandler(io.MultiWriter(writers...), &slog.HandlerOptions{Level: slog.LevelDebug})
+	return slog.New(handler), closer
+}
+
+func openFile(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(path); err == nil && info.Size() > MaxSize {
+		if err := os.Rename(path, path+".1"); err != nil {
+			return nil, err
+		}
+	}
+	return os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,49 @@
+package logging_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/delhombre/cxa/internal/logging"
+)
+
+func TestOpen_WritesFileAndVerbose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "debug.log")
+	var verbose bytes.Buffer
+
+	log, closer := logging.Open(path, &verbose)
+	log.Debug("copying", "account", "work")
+	closer.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("log file not written: %v", err)
+	}
+	for _, got := range []string{string(data), verbose.String()} {
+		if !strings.Contains(got, "level=DEBUG") || !strings.Contains(got, "account=work") {
+			t.Errorf("expected a debug record, got %q", got)
+		}
+	}
+}
+
+func TestOpen_Rotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "debug.log")
+	if err := os.WriteFile(path, bytes.Repeat([]byte("x"), logging.MaxSize+1), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	log, closer := logging.Open(path, nil)
+	log.Info("fresh")
+	closer.Close()
+
+	if info, err := os.Stat(path + ".1"); err != nil || info.Size() <= logging.MaxSize {
+		t.Errorf("expected the full log to be rotated, got %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "msg=fresh") || len(data) > 1000 {
+		t.Errorf("expected a fresh log, got %d bytes", len(data))
+	}
+}
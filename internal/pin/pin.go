@@ -0,0 +1,79 @@
+// Package pin reads and writes .cxa project files, which name the account
+// a directory tree should be worked on with.
+package pin
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileName is the name of a project file.
+const FileName = ".cxa"
+
+// Pin is a project file and the account it names.
+type Pin struct {
+	Path    string `json:"path"`
+	Account string `json:"account"`
+}
+
+// Write pins dir to the account name.
+func Write(dir, name string) (*Pin, error) {
+	path := filepath.Join(dir, FileName)
+	content := "# cxa account for this directory; see cxa pin\n" + name + "\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return nil, err
+	}
+	return &Pin{Path: path, Account: name}, nil
+}
+
+// Read reads the project file at path. The account is the first line that
+// is neither blank nor a # comment.
+func Read(path string) (*Pin, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		return &Pin{Path: path, Account: line}, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%s does not name an account", path)
+}
+
+// Find returns the project file nearest to dir, looking in dir and then
+// each parent in turn, or nil if there is none.
+func Find(dir string) (*Pin, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		path := filepath.Join(dir, FileName)
+		info, err := os.Stat(path)
+		switch {
+		case err == nil && !info.IsDir():
+			return Read(path)
+		case err != nil && !errors.Is(err, os.ErrNotExist):
+			return nil, err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,50 @@
+package pin_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/delhombre/cxa/internal/pin"
+)
+
+func TestFind(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "src", "app")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if p, err := pin.Find(nested); err != nil || p != nil {
+		t.Fatalf("expected no pin, got %+v, %v", p, err)
+	}
+
+	if _, err := pin.Write(root, "work"); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	p, err := pin.Find(nested)
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if p == nil || p.Account != "work" || p.Path != filepath.Join(root, pin.FileName) {
+		t.Errorf("expected the root pin for work, got %+v", p)
+	}
+
+	// The nearest file wins
+	if _, err := pin.Write(filepath.Join(root, "src"), "client"); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if p, _ := pin.Find(nested); p == nil || p.Account != "client" {
+		t.Errorf("expected the nearer pin for client, got %+v", p)
+	}
+}
+
+func TestRead_Empty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), pin.FileName)
+	if err := os.WriteFile(path, []byte("# nothing here\n\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pin.Read(path); err == nil {
+		t.Error("a file without an account should be an error")
+	}
+}
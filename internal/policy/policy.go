@@ -0,0 +1,223 @@
+// Package policy loads the system-wide policy that administrators use to
+// constrain cxa on managed machines.
+package policy
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultPath is where the system policy is read from.
+const DefaultPath = "/etc/cxa/policy.toml"
+
+// ErrEncryptionRequired is returned when the policy requires encryption at
+// rest and the operation would store account data unencrypted.
+var ErrEncryptionRequired = errors.New("policy requires account data to be encrypted at rest; run 'cxa encrypt' to turn encryption on first")
+
+// Policy is a set of constraints imposed by an administrator. The zero
+// value imposes none.
+type Policy struct {
+	// Path is the file the policy was loaded from, or "" if none exists.
+	Path string `json:"path,omitempty"`
+
+	// DataDir pins where accounts are stored.
+	DataDir string `json:"data_dir,omitempty"`
+
+	// RequireEncryption refuses to store account data unencrypted.
+	RequireEncryption bool `json:"require_encryption"`
+
+	// DisallowSecretExport keeps credentials out of exported archives.
+	DisallowSecretExport bool `json:"disallow_secret_export"`
+
+	// AllowedCommands restricts which top-level commands may run. Empty
+	// allows all.
+	AllowedCommands []string `json:"allowed_commands,omitempty"`
+
+	// err is why the policy file could not be loaded, for the closed
+	// policy LoadSystem puts in its place.
+	err error
+}
+
+// alwaysAllowed are commands that stay available under any policy so users
+// can find out why something is blocked.
+var alwaysAllowed = map[string]bool{
+	"help":       true,
+	"version":    true,
+	"policy":     true,
+	"completion": true,
+	"__complete": true,
+}
+
+var (
+	systemOnce   sync.Once
+	systemPolicy *Policy
+	systemErr    error
+)
+
+// System returns the policy at DefaultPath, as LoadSystem does, loading it
+// once.
+func System() (*Policy, error) {
+	systemOnce.Do(func() {
+		systemPolicy, systemErr = LoadSystem(DefaultPath)
+	})
+	return systemPolicy, systemErr
+}
+
+// LoadSystem reads the policy at path as Load does, but fails closed: a
+// policy file that exists but cannot be read or parsed yields, along with
+// the error, a policy that allows only the always-allowed commands,
+// refuses to store account data, and keeps credentials out of exports,
+// rather than one imposing nothing.
+func LoadSystem(path string) (*Policy, error) {
+	p, err := Load(path)
+	if err != nil {
+		return &Policy{Path: path, RequireEncryption: true, DisallowSecretExport: true, err: err}, err
+	}
+	return p, nil
+}
+
+// Active reports whether any constraint is in effect.
+func (p *Policy) Active() bool {
+	return p.DataDir != "" || p.RequireEncryption || p.DisallowSecretExport || len(p.AllowedCommands) > 0
+}
+
+// Allows reports whether the top-level command may run.
+func (p *Policy) Allows(command string) bool {
+	if alwaysAllowed[command] {
+		return true
+	}
+	if p.err != nil {
+		return false
+	}
+	if len(p.AllowedCommands) == 0 {
+		return true
+	}
+	for _, allowed := range p.AllowedCommands {
+		if allowed == command {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckStore returns ErrEncryptionRequired if account data may not be
+// written to storage that is encrypted or not as encrypted says, or why
+// the policy could not be loaded.
+func (p *Policy) CheckStore(encrypted bool) error {
+	if p.err != nil {
+		return fmt.Errorf("the system policy could not be loaded: %w", p.err)
+	}
+	if p.RequireEncryption && !encrypted {
+		return ErrEncryptionRequired
+	}
+	return nil
+}
+
+// Load reads the policy at path. A missing file yields the empty policy.
+//
+// Policy files use a flat subset of TOML: one key per line with a string,
+// boolean, or array of strings as the value, and # comments.
+func Load(path string) (*Policy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Policy{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	p := &Policy{Path: path}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, n)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		if err := p.set(key, value); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Policy) set(key, value string) error {
+	var err error
+	switch key {
+	case "data_dir":
+		p.DataDir, err = parseString(value)
+	case "require_encryption":
+		p.RequireEncryption, err = strconv.ParseBool(value)
+	case "allow_secret_export":
+		var allow bool
+		allow, err = strconv.ParseBool(value)
+		p.DisallowSecretExport = !allow
+	case "allowed_commands":
+		p.AllowedCommands, err = parseStringArray(value)
+	default:
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	return nil
+}
+
+// stripComment removes a # comment that is not inside a string.
+func stripComment(line string) string {
+	inString := false
+	for i, r := range line {
+		switch r {
+		case '"':
+			inString = !inString
+		case '#':
+			if !inString {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+func parseString(value string) (string, error) {
+	s, err := strconv.Unquote(value)
+	if err != nil || !strings.HasPrefix(value, `"`) {
+		return "", fmt.Errorf("expected a quoted string, got %s", value)
+	}
+	return s, nil
+}
+
+func parseStringArray(value string) ([]string, error) {
+	if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+		return nil, fmt.Errorf("expected an array, got %s", value)
+	}
+	var items []string
+	for _, item := range strings.Split(value[1:len(value)-1], ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		s, err := parseString(item)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, s)
+	}
+	return items, nil
+}
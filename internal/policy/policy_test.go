@@ -0,0 +1,100 @@
+package policy_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/delhombre/cxa/internal/policy"
+)
+
+func TestLoad_MissingFileImposesNothing(t *testing.T) {
+	p, err := policy.Load(filepath.Join(t.TempDir(), "policy.toml"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if p.Active() {
+		t.Error("expected no constraints")
+	}
+	if !p.Allows("export") {
+		t.Error("expected every command to be allowed")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.toml")
+	content := `# Managed by IT
+data_dir = "/srv/cxa # shared"
+require_encryption = true
+allow_secret_export = false
+allowed_commands = ["list", "switch"] # no export
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := policy.Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if p.DataDir != "/srv/cxa # shared" {
+		t.Errorf("unexpected data dir %q", p.DataDir)
+	}
+	if !p.RequireEncryption || p.CheckStore(false) != policy.ErrEncryptionRequired || p.CheckStore(true) != nil {
+		t.Error("expected encryption to be required")
+	}
+	if !p.DisallowSecretExport {
+		t.Error("expected secret export to be disallowed")
+	}
+	if !p.Allows("switch") || p.Allows("export") {
+		t.Errorf("unexpected command restrictions %v", p.AllowedCommands)
+	}
+	if !p.Allows("policy") {
+		t.Error("policy command must stay available")
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := []string{
+		"data_dir = /srv/cxa",
+		"require_encryption = maybe",
+		"allowed_commands = list",
+		"unknown = true",
+		"just a line",
+	}
+	for _, content := range tests {
+		path := filepath.Join(t.TempDir(), "policy.toml")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := policy.Load(path); err == nil {
+			t.Errorf("expected error for %q", content)
+		}
+	}
+}
+
+func TestLoadSystem_InvalidFailsClosed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.toml")
+	if err := os.WriteFile(path, []byte("require_encryption = ture\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := policy.LoadSystem(path)
+	if err == nil {
+		t.Fatal("expected the parse error")
+	}
+	if p.Allows("list") || !p.Allows("policy") {
+		t.Error("expected only the always-allowed commands to run")
+	}
+	if p.CheckStore(true) == nil {
+		t.Error("expected storing account data to be refused")
+	}
+	if !p.DisallowSecretExport {
+		t.Error("expected credentials kept out of exports")
+	}
+
+	missing, err := policy.LoadSystem(filepath.Join(t.TempDir(), "policy.toml"))
+	if err != nil || missing.Active() {
+		t.Errorf("expected a missing policy to impose nothing, got %+v (%v)", missing, err)
+	}
+}
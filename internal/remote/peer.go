@@ -0,0 +1,73 @@
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// Peer is another machine running cxa, reached over SSH. Unlike a sync
+// remote, it keeps no files of its own: accounts are streamed one at a
+// time between the cxa on each end, as cxa export and cxa import archives.
+type Peer struct {
+	// Host is what ssh connects to: host, user@host, or a Host alias from
+	// ~/.ssh/config.
+	Host string
+
+	// Command is how cxa is run on the host, "cxa" unless set, for a cxa
+	// that is not on the PATH of non-interactive shells there.
+	Command string
+}
+
+// Accounts returns the names of the accounts saved on the peer, archived
+// ones included.
+func (p *Peer) Accounts() ([]string, error) {
+	var stdout bytes.Buffer
+	if err := p.run(nil, &stdout, "--quiet", "list", "--all"); err != nil {
+		return nil, err
+	}
+	var names []string
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		if name := strings.TrimSpace(scanner.Text()); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, scanner.Err()
+}
+
+// Export writes the export archive of the named account on the peer to w.
+func (p *Peer) Export(name string, w io.Writer) error {
+	return p.run(nil, w, "--quiet", "export", name, "--output", "-")
+}
+
+// Import saves the export archive read from r as the named account on the
+// peer, replacing an account of that name.
+func (p *Peer) Import(name string, r io.Reader) error {
+	return p.run(r, io.Discard, "--quiet", "import", "-", "--name", name, "--force")
+}
+
+// run runs cxa with args on the peer.
+func (p *Peer) run(stdin io.Reader, stdout io.Writer, args ...string) error {
+	command := p.Command
+	if command == "" {
+		command = "cxa"
+	}
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+
+	cmd := exec.Command("ssh", p.Host, command+" "+strings.Join(quoted, " "))
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ssh %s: %w: %s", p.Host, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
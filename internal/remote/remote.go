@@ -0,0 +1,241 @@
+// Package remote moves accounts to and from a sync remote: a directory on
+// this machine, such as a synced folder or mounted drive, a directory on
+// another host reached with rsync over SSH, or an S3-compatible bucket.
+//
+// A remote mirrors the accounts layout of the data directory and keeps an
+// index recording the content hash of every account pushed to it, so
+// machines can tell which side changed without downloading anything.
+package remote
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/delhombre/cxa/internal/fscopy"
+)
+
+// indexName is the file at the root of a remote listing its accounts.
+const indexName = "index.json"
+
+// IndexEntry describes the copy of an account on a remote.
+type IndexEntry struct {
+	// Hash is the content hash of the account when it was pushed.
+	Hash string `json:"hash"`
+
+	// UpdatedAt is the account's UpdatedAt when it was pushed.
+	UpdatedAt time.Time `json:"updated_at"`
+
+	PushedAt time.Time `json:"pushed_at"`
+	PushedBy string    `json:"pushed_by,omitempty"`
+}
+
+// Index lists the accounts on a remote.
+type Index struct {
+	Accounts map[string]IndexEntry `json:"accounts"`
+}
+
+// Transport reads and writes a remote.
+type Transport interface {
+	// ReadIndex returns the remote's index, empty if it has none yet.
+	ReadIndex() (*Index, error)
+
+	// WriteIndex replaces the remote's index.
+	WriteIndex(index *Index) error
+
+	// Push replaces the remote copy of the account with the directory src.
+	Push(name, src string) error
+
+	// Pull copies the remote copy of the account into the empty or
+	// missing directory dst.
+	Pull(name, dst string) error
+}
+
+// Open returns the transport for a remote URL: host:path, user@host:path,
+// or ssh://[user@]host/path for rsync over SSH, s3://bucket/prefix for an
+// S3-compatible bucket, and anything else for a local directory.
+func Open(url string) (Transport, error) {
+	if url == "" {
+		return nil, fmt.Errorf("remote URL cannot be empty")
+	}
+
+	if strings.HasPrefix(url, "s3://") {
+		return openS3(url)
+	}
+
+	if rest, ok := strings.CutPrefix(url, "ssh://"); ok {
+		host, path, ok := strings.Cut(rest, "/")
+		if !ok || host == "" {
+			return nil, fmt.Errorf("invalid SSH remote %q: expected ssh://host/path", url)
+		}
+		return &rsyncTransport{host: host, root: "/" + path}, nil
+	}
+
+	// scp-style host:path, as long as the part before the colon is not a
+	// path itself (./a:b) or a Windows drive letter (C:\a)
+	if host, path, ok := strings.Cut(url, ":"); ok && len(host) > 1 && !strings.ContainsAny(host, `/\`) {
+		return &rsyncTransport{host: host, root: path}, nil
+	}
+
+	root, err := expandHome(url)
+	if err != nil {
+		return nil, err
+	}
+	return &localTransport{root: root}, nil
+}
+
+func expandHome(path string) (string, error) {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, rest)
+	}
+	return filepath.Abs(path)
+}
+
+func decodeIndex(data []byte) (*Index, error) {
+	index := &Index{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, index); err != nil {
+			return nil, fmt.Errorf("invalid remote index: %w", err)
+		}
+	}
+	if index.Accounts == nil {
+		index.Accounts = make(map[string]IndexEntry)
+	}
+	return index, nil
+}
+
+// localTransport keeps the remote in a directory on this machine.
+type localTransport struct {
+	root string
+}
+
+func (t *localTransport) accountPath(name string) string {
+	return filepath.Join(t.root, "accounts", name)
+}
+
+func (t *localTransport) ReadIndex() (*Index, error) {
+	data, err := os.ReadFile(filepath.Join(t.root, indexName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return decodeIndex(data)
+}
+
+func (t *localTransport) WriteIndex(index *Index) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(t.root, 0755); err != nil {
+		return err
+	}
+
+	// Synced folders may pick up a half-written file; write then rename
+	path := filepath.Join(t.root, indexName)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (t *localTransport) Push(name, src string) error {
+	dst := t.accountPath(name)
+	staging := dst + ".pushing"
+	if err := os.RemoveAll(staging); err != nil {
+		return err
+	}
+	if err := fscopy.Tree(src, staging, fscopy.Options{Root: dst}); err != nil {
+		os.RemoveAll(staging)
+		return err
+	}
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+	return os.Rename(staging, dst)
+}
+
+func (t *localTransport) Pull(name, dst string) error {
+	src := t.accountPath(name)
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("account '%s' is not on the remote: %w", name, err)
+	}
+	return fscopy.Tree(src, dst, fscopy.Options{})
+}
+
+// rsyncTransport keeps the remote on another host, copying with rsync and
+// reading the index with ssh.
+type rsyncTransport struct {
+	host string
+	root string
+}
+
+func (t *rsyncTransport) remotePath(parts ...string) string {
+	return t.host + ":" + filepath.ToSlash(filepath.Join(append([]string{t.root}, parts...)...))
+}
+
+func (t *rsyncTransport) ReadIndex() (*Index, error) {
+	path := filepath.ToSlash(filepath.Join(t.root, indexName))
+	data, err := t.ssh(nil, "if [ -f "+shellQuote(path)+" ]; then cat "+shellQuote(path)+"; fi")
+	if err != nil {
+		return nil, err
+	}
+	return decodeIndex(data)
+}
+
+func (t *rsyncTransport) WriteIndex(index *Index) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.ToSlash(filepath.Join(t.root, indexName))
+	_, err = t.ssh(strings.NewReader(string(data)),
+		"mkdir -p "+shellQuote(t.root)+" && cat > "+shellQuote(path+".tmp")+" && mv "+shellQuote(path+".tmp")+" "+shellQuote(path))
+	return err
+}
+
+func (t *rsyncTransport) Push(name, src string) error {
+	if _, err := t.ssh(nil, "mkdir -p "+shellQuote(filepath.ToSlash(filepath.Join(t.root, "accounts")))); err != nil {
+		return err
+	}
+	return rsync(strings.TrimSuffix(src, "/")+"/", t.remotePath("accounts", name)+"/")
+}
+
+func (t *rsyncTransport) Pull(name, dst string) error {
+	return rsync(t.remotePath("accounts", name)+"/", strings.TrimSuffix(dst, "/")+"/")
+}
+
+func (t *rsyncTransport) ssh(stdin io.Reader, command string) ([]byte, error) {
+	cmd := exec.Command("ssh", t.host, command)
+	cmd.Stdin = stdin
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ssh %s: %w: %s", t.host, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+func rsync(src, dst string) error {
+	cmd := exec.Command("rsync", "--archive", "--delete", "--compress", src, dst)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("rsync: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// shellQuote quotes s for a POSIX shell on the remote host.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,186 @@
+package remote_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/delhombre/cxa/internal/remote"
+)
+
+func TestOpen_ChoosesTransport(t *testing.T) {
+	for _, url := range []string{"/mnt/drive/cxa", "./relative:dir", "~/Dropbox/cxa", `C:\cxa`} {
+		if _, err := remote.Open(url); err != nil {
+			t.Errorf("Open(%q) failed: %v", url, err)
+		}
+	}
+	if _, err := remote.Open("ssh://host"); err == nil {
+		t.Error("an SSH remote without a path should be rejected")
+	}
+	if _, err := remote.Open(""); err == nil {
+		t.Error("an empty remote should be rejected")
+	}
+}
+
+func TestLocalTransport(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "remote")
+	tr, err := remote.Open(root)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	index, err := tr.ReadIndex()
+	if err != nil || len(index.Accounts) != 0 {
+		t.Fatalf("expected an empty index, got %v, %v", index, err)
+	}
+
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "sessions"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "sessions", "a.jsonl"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/elsewhere", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tr.Push("work", src); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	index.Accounts["work"] = remote.IndexEntry{Hash: "abc", UpdatedAt: time.Now()}
+	if err := tr.WriteIndex(index); err != nil {
+		t.Fatalf("WriteIndex failed: %v", err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "work")
+	if err := tr.Pull("work", dst); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "sessions", "a.jsonl")); err != nil || string(data) != "{}" {
+		t.Errorf("pulled file mismatch: %q, %v", data, err)
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "link")); err != nil || link != "/elsewhere" {
+		t.Errorf("symlink should survive the round trip, got %q, %v", link, err)
+	}
+
+	index, err = tr.ReadIndex()
+	if err != nil || index.Accounts["work"].Hash != "abc" {
+		t.Errorf("index should round-trip, got %v, %v", index, err)
+	}
+	if err := tr.Pull("missing", filepath.Join(t.TempDir(), "x")); err == nil {
+		t.Error("pulling an account the remote does not have should fail")
+	}
+}
+
+// fakeS3 serves objects from memory, refusing unsigned requests and bodies
+// that do not match their signed hash, as S3 does.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	meta    map[string]string
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+		http.Error(w, "<Error><Code>AccessDenied</Code><Message>unsigned</Message></Error>", http.StatusForbidden)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		sum := sha256.Sum256(data)
+		if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+			http.Error(w, "<Error><Code>XAmzContentSHA256Mismatch</Code></Error>", http.StatusBadRequest)
+			return
+		}
+		s.objects[r.URL.Path] = data
+		s.meta[r.URL.Path] = r.Header.Get("X-Amz-Meta-Sha256")
+	case http.MethodGet:
+		data, ok := s.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		if sum := s.meta[r.URL.Path]; sum != "" {
+			w.Header().Set("X-Amz-Meta-Sha256", sum)
+		}
+		w.Write(data)
+	}
+}
+
+func TestS3Transport(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{}, meta: map[string]string{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	t.Setenv("AWS_ENDPOINT_URL", server.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	if _, err := remote.Open("s3://"); err == nil {
+		t.Error("an S3 remote without a bucket should be rejected")
+	}
+	tr, err := remote.Open("s3://bucket/team/cxa")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	index, err := tr.ReadIndex()
+	if err != nil || len(index.Accounts) != 0 {
+		t.Fatalf("expected an empty index, got %v, %v", index, err)
+	}
+
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "sessions"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "sessions", "a.jsonl"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/elsewhere", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Push("work", src); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if _, ok := fake.objects["/bucket/team/cxa/accounts/work.tar.gz"]; !ok {
+		t.Errorf("expected a bundle under the prefix, got %v", fake.objects)
+	}
+	index.Accounts["work"] = remote.IndexEntry{Hash: "abc", UpdatedAt: time.Now()}
+	if err := tr.WriteIndex(index); err != nil {
+		t.Fatalf("WriteIndex failed: %v", err)
+	}
+	if index, err = tr.ReadIndex(); err != nil || index.Accounts["work"].Hash != "abc" {
+		t.Errorf("index should round-trip, got %v, %v", index, err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "work")
+	if err := tr.Pull("work", dst); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "sessions", "a.jsonl")); err != nil || string(data) != "{}" {
+		t.Errorf("pulled file mismatch: %q, %v", data, err)
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "link")); err != nil || link != "/elsewhere" {
+		t.Errorf("symlink should survive the round trip, got %q, %v", link, err)
+	}
+
+	// A bundle damaged at rest fails its checksum
+	bundle := fake.objects["/bucket/team/cxa/accounts/work.tar.gz"]
+	bundle[len(bundle)/2] ^= 0xff
+	if err := tr.Pull("work", filepath.Join(t.TempDir(), "work")); err == nil || !strings.Contains(err.Error(), "corrupt") {
+		t.Errorf("expected a checksum error, got %v", err)
+	}
+	if err := tr.Pull("missing", filepath.Join(t.TempDir(), "x")); err == nil {
+		t.Error("pulling an account the remote does not have should fail")
+	}
+}
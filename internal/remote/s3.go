@@ -0,0 +1,456 @@
+package remote
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// bundleChecksum is the object metadata holding the SHA-256 of a bundle,
+// checked after every download.
+const bundleChecksum = "X-Amz-Meta-Sha256"
+
+// s3Transport keeps the remote in an S3-compatible bucket: AWS S3, MinIO,
+// or Google Cloud Storage through its XML API. Each account is one
+// tar.gz bundle under <prefix>/accounts/, next to the index.
+//
+// Credentials come from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN, the region from AWS_REGION or AWS_DEFAULT_REGION,
+// and another service is reached by setting AWS_ENDPOINT_URL.
+type s3Transport struct {
+	client *http.Client
+	bucket string
+	prefix string
+
+	endpoint  *url.URL
+	pathStyle bool
+	region    string
+
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// openS3 returns the transport for s3://bucket[/prefix].
+func openS3(rawURL string) (Transport, error) {
+	rest := strings.TrimPrefix(rawURL, "s3://")
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("invalid S3 remote %q: expected s3://bucket/prefix", rawURL)
+	}
+
+	t := &s3Transport{
+		client:       &http.Client{Timeout: 10 * time.Minute},
+		bucket:       bucket,
+		prefix:       strings.Trim(prefix, "/"),
+		region:       firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"),
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if t.accessKey == "" || t.secretKey == "" {
+		return nil, fmt.Errorf("S3 remote %q needs AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY", rawURL)
+	}
+	if t.region == "" {
+		t.region = "us-east-1"
+	}
+
+	// Services other than AWS address buckets by path, as MinIO needs
+	endpoint := firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL")
+	if endpoint == "" {
+		endpoint = "https://s3." + t.region + ".amazonaws.com"
+	} else {
+		t.pathStyle = true
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+	}
+	t.endpoint = u
+	return t, nil
+}
+
+func firstEnv(keys ...string) string {
+	for _, key := range keys {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func (t *s3Transport) key(parts ...string) string {
+	return path.Join(append([]string{t.prefix}, parts...)...)
+}
+
+func (t *s3Transport) bundleKey(name string) string {
+	return t.key("accounts", name+".tar.gz")
+}
+
+func (t *s3Transport) ReadIndex() (*Index, error) {
+	resp, err := t.do(http.MethodGet, t.key(indexName), nil, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return decodeIndex(nil)
+	}
+	if err := s3Error(resp); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return decodeIndex(data)
+}
+
+func (t *s3Transport) WriteIndex(index *Index) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	resp, err := t.do(http.MethodPut, t.key(indexName), strings.NewReader(string(data)), hex.EncodeToString(sum[:]), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return s3Error(resp)
+}
+
+// Push uploads the account as a bundle. The request is signed with the
+// bundle's hash, so the service rejects it if it arrives damaged.
+func (t *s3Transport) Push(name, src string) error {
+	f, err := os.CreateTemp("", "cxa-bundle-*.tar.gz")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	h := sha256.New()
+	if err := writeBundle(io.MultiWriter(f, h), src); err != nil {
+		return fmt.Errorf("failed to bundle '%s': %w", name, err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	resp, err := t.do(http.MethodPut, t.bundleKey(name), f, sum, http.Header{bundleChecksum: {sum}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return s3Error(resp)
+}
+
+// Pull downloads the bundle of the account and unpacks it into dst once
+// it matches the checksum recorded when it was pushed.
+func (t *s3Transport) Pull(name, dst string) error {
+	resp, err := t.do(http.MethodGet, t.bundleKey(name), nil, "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("account '%s' is not on the remote", name)
+	}
+	if err := s3Error(resp); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp("", "cxa-bundle-*.tar.gz")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), resp.Body); err != nil {
+		return fmt.Errorf("failed to download '%s': %w", name, err)
+	}
+	if want := resp.Header.Get(bundleChecksum); want != "" && want != hex.EncodeToString(h.Sum(nil)) {
+		return fmt.Errorf("bundle of '%s' on the remote is corrupt: checksum mismatch", name)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return readBundle(f, dst)
+}
+
+// do sends a request for the object at key, signed with AWS Signature
+// Version 4. payloadHash is the hex SHA-256 of body; empty means no body.
+func (t *s3Transport) do(method, key string, body io.Reader, payloadHash string, header http.Header) (*http.Response, error) {
+	u := *t.endpoint
+	objectPath := "/" + key
+	if t.pathStyle {
+		objectPath = "/" + t.bucket + objectPath
+	} else {
+		u.Host = t.bucket + "." + u.Host
+	}
+	u.Path = path.Join(u.Path, objectPath)
+	u.RawPath = s3Escape(u.Path)
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if f, ok := body.(*os.File); ok {
+		info, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		req.ContentLength = info.Size()
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if payloadHash == "" {
+		sum := sha256.Sum256(nil)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	t.sign(req, payloadHash, time.Now().UTC())
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3 %s %s: %w", method, key, err)
+	}
+	return resp, nil
+}
+
+// sign adds the Signature Version 4 authorization to req.
+func (t *s3Transport) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if t.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", t.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		if lower := strings.ToLower(k); strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + t.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+t.secretKey), date)
+	for _, part := range []string{t.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		t.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape escapes an object path as Signature Version 4 expects: every
+// byte but unreserved characters and slashes.
+func s3Escape(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3Error turns an unsuccessful response into an error carrying the
+// service's message.
+func s3Error(resp *http.Response) error {
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if xml.Unmarshal(data, &body) == nil && body.Code != "" {
+		return fmt.Errorf("s3 %s %s: %s: %s", resp.Request.Method, resp.Request.URL.Path, body.Code, body.Message)
+	}
+	return fmt.Errorf("s3 %s %s: %s", resp.Request.Method, resp.Request.URL.Path, resp.Status)
+}
+
+// writeBundle writes the tree at dir to w as a tar.gz archive, keeping
+// symlinks as they are.
+func writeBundle(w io.Writer, dir string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		} else if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.CopyN(tw, f, info.Size())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// readBundle unpacks a bundle written by writeBundle into dst, refusing
+// entries that would land outside it.
+func readBundle(r io.Reader, dst string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("invalid bundle: %w", err)
+	}
+	defer gz.Close()
+	if err := os.MkdirAll(dst, 0700); err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid bundle: %w", err)
+		}
+		name := path.Clean(hdr.Name)
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return fmt.Errorf("invalid bundle: unsafe path %q", hdr.Name)
+		}
+		target := filepath.Join(dst, filepath.FromSlash(name))
+		// Never write through a symlink unpacked earlier
+		if err := checkNoSymlinks(dst, filepath.Dir(target)); err != nil {
+			return err
+		}
+
+		mode := os.FileMode(hdr.Mode).Perm()
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, mode|0700); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(f, tr); err != nil {
+				f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// checkNoSymlinks fails if any directory from root down to dir is a
+// symlink.
+func checkNoSymlinks(root, dir string) error {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return err
+	}
+	p := root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if part == "." {
+			continue
+		}
+		p = filepath.Join(p, part)
+		info, err := os.Lstat(p)
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("invalid bundle: %s is a symlink", p)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+// Package schedule parses cron expressions and works out when they next
+// fire, for jobs cxa runs on its own such as scheduled snapshots.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression of five fields: minute, hour, day
+// of month, month, and day of week, with Sunday as 0 or 7.
+type Schedule struct {
+	expr string
+
+	minute, hour, dom, month, dow uint64
+
+	// domAny and dowAny record a * day field. As in cron, when both day
+	// fields are restricted a day matching either one fires.
+	domAny, dowAny bool
+}
+
+// shorthands are the @ names cron accepts in place of the five fields.
+var shorthands = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// field is the range of values one field of an expression takes.
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// Parse parses a cron expression such as "0 */4 * * *" or "@daily". Each
+// field is *, a number, a range a-b, or a list of them separated by
+// commas, any of which may take a step such as */15 or 9-17/2.
+func Parse(expr string) (*Schedule, error) {
+	spec := strings.TrimSpace(expr)
+	if s, ok := shorthands[spec]; ok {
+		spec = s
+	}
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 fields (minute hour day month weekday) or one of @hourly, @daily, @weekly, @monthly", expr)
+	}
+
+	sets := make([]uint64, len(fields))
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", expr, err)
+		}
+		sets[i] = set
+	}
+	// Sunday is both 0 and 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+
+	return &Schedule{
+		expr:   strings.TrimSpace(expr),
+		minute: sets[0],
+		hour:   sets[1],
+		dom:    sets[2],
+		month:  sets[3],
+		dow:    sets[4],
+		domAny: parts[2] == "*",
+		dowAny: parts[4] == "*",
+	}, nil
+}
+
+// parseField returns the values part of an expression selects in f, as a
+// bit set.
+func parseField(part string, f field) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(part, ",") {
+		rng, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("bad step in %s field %q", f.name, item)
+			}
+			rng, step = item[:i], n
+		}
+
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			var err error
+			a, b, isRange := strings.Cut(rng, "-")
+			if lo, err = strconv.Atoi(a); err != nil {
+				return 0, fmt.Errorf("bad %s %q", f.name, item)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(b); err != nil {
+					return 0, fmt.Errorf("bad %s %q", f.name, item)
+				}
+			} else if step > 1 {
+				// 5/15 means from 5 on, every 15
+				hi = f.max
+			}
+			if lo < f.min || hi > f.max || lo > hi {
+				return 0, fmt.Errorf("%s %q out of range %d-%d", f.name, item, f.min, f.max)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// String returns the expression the schedule was parsed from.
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// Next returns the first time after t the schedule fires, to the minute.
+// It returns the zero time for a schedule that never fires, such as one
+// naming February 30.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// Every combination of fields recurs within a few years
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,56 @@
+package schedule_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/delhombre/cxa/internal/schedule"
+)
+
+func TestParse(t *testing.T) {
+	for _, expr := range []string{"* * * * *", "0 */4 * * *", "30 9-17/2 * * 1-5", "0 0 1,15 * *", "@daily", "0 0 * * 7"} {
+		if _, err := schedule.Parse(expr); err != nil {
+			t.Errorf("Parse(%q) failed: %v", expr, err)
+		}
+	}
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "@often"} {
+		if _, err := schedule.Parse(expr); err == nil {
+			t.Errorf("Parse(%q) should fail", expr)
+		}
+	}
+}
+
+func TestSchedule_Next(t *testing.T) {
+	// A Wednesday
+	from := time.Date(2025, 1, 15, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2025, 1, 15, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, 1, 15, 10, 15, 0, 0, time.UTC)},
+		{"0 */4 * * *", time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{"0 9 * * 0", time.Date(2025, 1, 19, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2025, 1, 19, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+		// Either restricted day field fires
+		{"0 0 20 * 5", time.Date(2025, 1, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		s, err := schedule.Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", tt.expr, err)
+		}
+		if got := s.Next(from); !got.Equal(tt.want) {
+			t.Errorf("Next(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	s, _ := schedule.Parse("0 0 30 2 *")
+	if got := s.Next(from); !got.IsZero() {
+		t.Errorf("a schedule that never fires should return the zero time, got %v", got)
+	}
+}
@@ -0,0 +1,102 @@
+// Package schema versions the JSON files cxa keeps its state in, and
+// upgrades files written by older builds as they are loaded, so a change
+// to a format never leaves an existing install misreading its own files.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Key is the field a versioned file records its schema version in. Files
+// without it predate versioning and are version 0.
+const Key = "schema_version"
+
+// Migration upgrades a decoded file by one version, in place. It does not
+// set Key; Upgrade does.
+type Migration func(doc map[string]any) error
+
+// Format is one versioned file format.
+type Format struct {
+	// Name names the file in errors, such as "state.json".
+	Name string
+
+	// Version is the newest version this build reads and writes.
+	Version int
+
+	// Migrations[i] upgrades version i to version i+1, so there is one for
+	// every version before Version. A nil migration only bumps the
+	// version, for changes older builds would mishandle but that need no
+	// rewriting, such as a new field.
+	Migrations []Migration
+}
+
+// NewerError reports a file written by a newer cxa. Such files can be
+// read, as far as this build understands them, but writing them could drop
+// what it does not.
+type NewerError struct {
+	File      string
+	Found     int
+	Supported int
+}
+
+func (e *NewerError) Error() string {
+	return fmt.Sprintf("%s was written by a newer cxa (schema %d; this cxa understands up to %d) and is read-only here; upgrade cxa to change it",
+		e.File, e.Found, e.Supported)
+}
+
+// Version returns the schema version recorded in data, 0 when there is
+// none or data is not a JSON object.
+func Version(data []byte) int {
+	var v struct {
+		Version int `json:"schema_version"`
+	}
+	_ = json.Unmarshal(data, &v)
+	return v.Version
+}
+
+// Upgrade returns data brought up to f.Version by running the migrations
+// from its recorded version on. Data already at f.Version comes back as it
+// is. Data from a newer version also comes back as it is, with a
+// *NewerError for callers that must not write it back.
+func (f *Format) Upgrade(data []byte) ([]byte, error) {
+	if len(f.Migrations) != f.Version {
+		panic(fmt.Sprintf("schema: %s has %d migrations for version %d", f.Name, len(f.Migrations), f.Version))
+	}
+
+	found := Version(data)
+	switch {
+	case found == f.Version:
+		return data, nil
+	case found > f.Version:
+		return data, &NewerError{File: f.Name, Found: found, Supported: f.Version}
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s is corrupt: %w", f.Name, err)
+	}
+	for v := found; v < f.Version; v++ {
+		if migrate := f.Migrations[v]; migrate != nil {
+			if err := migrate(doc); err != nil {
+				return nil, fmt.Errorf("failed to upgrade %s from schema %d: %w", f.Name, v, err)
+			}
+		}
+	}
+	doc[Key] = f.Version
+	return json.Marshal(doc)
+}
+
+// CheckWritable returns a *NewerError when the file at path was written by
+// a newer version of f. A missing or unreadable file is writable.
+func (f *Format) CheckWritable(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	if found := Version(data); found > f.Version {
+		return &NewerError{File: f.Name, Found: found, Supported: f.Version}
+	}
+	return nil
+}
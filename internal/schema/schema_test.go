@@ -0,0 +1,84 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/delhombre/cxa/internal/schema"
+)
+
+func TestFormat_Upgrade(t *testing.T) {
+	f := &schema.Format{
+		Name:    "test.json",
+		Version: 2,
+		Migrations: []schema.Migration{
+			nil,
+			// Version 2 renamed "user" to "name"
+			func(doc map[string]any) error {
+				doc["name"] = doc["user"]
+				delete(doc, "user")
+				return nil
+			},
+		},
+	}
+
+	data, err := f.Upgrade([]byte(`{"user": "work"}`))
+	if err != nil {
+		t.Fatalf("Upgrade failed: %v", err)
+	}
+	var doc struct {
+		Version int    `json:"schema_version"`
+		Name    string `json:"name"`
+		User    string `json:"user"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Version != 2 || doc.Name != "work" || doc.User != "" {
+		t.Errorf("expected version 2 naming work, got %+v", doc)
+	}
+
+	current := []byte(`{"schema_version": 2, "name": "work"}`)
+	if data, err := f.Upgrade(current); err != nil || string(data) != string(current) {
+		t.Errorf("a current file should come back unchanged, got %s, %v", data, err)
+	}
+
+	newer := []byte(`{"schema_version": 3, "name": "work"}`)
+	data, err = f.Upgrade(newer)
+	var newerErr *schema.NewerError
+	if !errors.As(err, &newerErr) || newerErr.Found != 3 {
+		t.Errorf("expected a NewerError for schema 3, got %v", err)
+	}
+	if string(data) != string(newer) {
+		t.Errorf("a newer file should come back unchanged, got %s", data)
+	}
+
+	if _, err := f.Upgrade([]byte(`{`)); err == nil {
+		t.Error("a corrupt file should fail to upgrade")
+	}
+}
+
+func TestFormat_CheckWritable(t *testing.T) {
+	f := &schema.Format{Name: "test.json", Version: 1, Migrations: []schema.Migration{nil}}
+	path := filepath.Join(t.TempDir(), "test.json")
+
+	if err := f.CheckWritable(path); err != nil {
+		t.Errorf("a missing file should be writable, got %v", err)
+	}
+	if err := os.WriteFile(path, []byte(`{"schema_version": 1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.CheckWritable(path); err != nil {
+		t.Errorf("a current file should be writable, got %v", err)
+	}
+	if err := os.WriteFile(path, []byte(`{"schema_version": 2}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var newerErr *schema.NewerError
+	if err := f.CheckWritable(path); !errors.As(err, &newerErr) {
+		t.Errorf("a newer file should not be writable, got %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package sharing
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/delhombre/cxa/internal/fscopy"
+)
+
+// Resolution is how a Conflict is settled.
+type Resolution string
+
+const (
+	// ResolveMerge copies what only the local copy has into the shared
+	// one. Of a JSON Lines file both have, such as history.jsonl, the
+	// shared copy gains the lines only the local one has; of any other
+	// file both have with different contents, the newer wins.
+	ResolveMerge Resolution = "merge"
+	// ResolveKeepShared discards the local copy.
+	ResolveKeepShared Resolution = "keep-shared"
+	// ResolveKeepLocal replaces the shared copy with the local one.
+	ResolveKeepLocal Resolution = "keep-local"
+)
+
+// Conflict is an item about to be shared whose local copy in ~/.codex
+// holds what its shared copy does not.
+type Conflict struct {
+	Item   string
+	Local  string
+	Shared string
+
+	// OnlyLocal counts the files only the local copy has, and Differ
+	// those both have with different contents.
+	OnlyLocal int
+	Differ    int
+}
+
+// ErrLeftUnshared is returned by a Resolver to leave the item unshared, as
+// the user chose, rather than because settling it failed. SetupSymlinks
+// then goes on with the other items, and the manager neither asks about
+// the item again nor reports it as broken.
+var ErrLeftUnshared = errors.New("left unshared")
+
+// Resolver chooses how to settle a conflict. An error leaves the item
+// unshared.
+type Resolver func(Conflict) (Resolution, error)
+
+// SetResolver sets how conflicts are settled. Without one, the local copy
+// is moved aside under the data directory, with a warning, and the shared
+// copy kept; nothing is deleted without a choice.
+func (m *Manager) SetResolver(resolve Resolver) {
+	m.resolve = resolve
+}
+
+// settle makes way for the shared copy of item at dest by removing its
+// local copy at src, once what only src holds is taken care of.
+func (m *Manager) settle(item, src, dest string) error {
+	if m.unshared[item] {
+		return ErrLeftUnshared
+	}
+	c, err := diverge(src, dest)
+	if err != nil {
+		return err
+	}
+	if c.OnlyLocal == 0 && c.Differ == 0 {
+		// The shared copy has everything already
+		return os.RemoveAll(src)
+	}
+	c.Item, c.Local, c.Shared = item, src, dest
+
+	if m.resolve == nil {
+		aside := filepath.Join(m.paths.DataDir, "conflicts", time.Now().Format("20060102-150405"), item)
+		if err := os.MkdirAll(filepath.Dir(aside), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(src, aside); err != nil {
+			return err
+		}
+		m.warnings.Record("sharing", fmt.Sprintf("kept the shared %s; the local copy, which differs, was moved to %s", item, aside))
+		return nil
+	}
+
+	resolution, err := m.resolve(c)
+	if errors.Is(err, ErrLeftUnshared) {
+		if m.unshared == nil {
+			m.unshared = make(map[string]bool)
+		}
+		m.unshared[item] = true
+		return ErrLeftUnshared
+	}
+	if err != nil {
+		return err
+	}
+	switch resolution {
+	case ResolveMerge:
+		if err := mergeTree(src, dest); err != nil {
+			return err
+		}
+		return os.RemoveAll(src)
+	case ResolveKeepShared:
+		return os.RemoveAll(src)
+	case ResolveKeepLocal:
+		if err := os.RemoveAll(dest); err != nil {
+			return err
+		}
+		return os.Rename(src, dest)
+	}
+	return fmt.Errorf("unknown resolution '%s'", resolution)
+}
+
+// diverge compares the local copy of an item at src with its shared copy
+// at dest, file by file.
+func diverge(src, dest string) (Conflict, error) {
+	var c Conflict
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		same, err := sameContents(path, filepath.Join(dest, rel))
+		switch {
+		case os.IsNotExist(err):
+			c.OnlyLocal++
+		case err != nil:
+			return err
+		case !same:
+			c.Differ++
+		}
+		return nil
+	})
+	return c, err
+}
+
+// sameContents reports whether the files a and b hold the same bytes.
+func sameContents(a, b string) (bool, error) {
+	infoB, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	if infoA.Size() != infoB.Size() || infoB.IsDir() {
+		return false, nil
+	}
+	dataA, err := os.ReadFile(a)
+	if err != nil {
+		return false, err
+	}
+	dataB, err := os.ReadFile(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(dataA, dataB), nil
+}
+
+// mergeTree copies each file under src into dest where dest lacks it or
+// holds an older, different one, and merges the lines of JSON Lines files
+// both have.
+func mergeTree(src, dest string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		if existing, err := os.Stat(target); err == nil {
+			if filepath.Ext(path) == ".jsonl" {
+				return mergeLines(path, target)
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if !info.ModTime().After(existing.ModTime()) {
+				return nil
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return fscopy.File(path, target, 0, nil)
+	})
+}
+
+// mergeLines appends to dest the lines of src it does not have, in order.
+func mergeLines(src, dest string) error {
+	extra, err := extraLines(src, dest)
+	if err != nil || len(extra) == 0 {
+		return err
+	}
+	f, err := os.OpenFile(dest, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(extra); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// extraLines returns the lines of src that dest does not have, in order,
+// ready to append to dest: each ends in a newline, and a newline ends the
+// last line of dest first if it has none.
+func extraLines(src, dest string) ([]byte, error) {
+	local, err := os.ReadFile(src)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := os.ReadFile(dest)
+	if err != nil {
+		return nil, err
+	}
+	have := make(map[string]bool)
+	for _, line := range bytes.Split(shared, []byte("\n")) {
+		have[string(line)] = true
+	}
+	var extra []byte
+	for _, line := range bytes.Split(local, []byte("\n")) {
+		if len(line) > 0 && !have[string(line)] {
+			extra = append(append(extra, line...), '\n')
+		}
+	}
+	if len(extra) > 0 && len(shared) > 0 && shared[len(shared)-1] != '\n' {
+		extra = append([]byte("\n"), extra...)
+	}
+	return extra, nil
+}
@@ -0,0 +1,190 @@
+package sharing
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/delhombre/cxa/internal/fscopy"
+	"github.com/delhombre/cxa/pkg/codex"
+)
+
+// sessionsItem is the shareable item session filters choose from.
+const sessionsItem = "sessions"
+
+// cwdKey is the session metadata key holding the project a session ran
+// in.
+const cwdKey = "cwd"
+
+// SessionFilters returns the rules choosing which sessions are shared.
+// None means every session is.
+func (m *Manager) SessionFilters() []string {
+	return slices.Clone(m.config.SessionFilters)
+}
+
+// filtered reports whether only the sessions session filters match are
+// shared.
+func (m *Manager) filtered() bool {
+	return len(m.config.SessionFilters) > 0
+}
+
+// ParseSessionFilter checks a session filter rule and returns it as
+// stored. A rule is key=pattern, matched against the metadata a session
+// file starts with: cwd=<path> matches sessions run in path or anywhere
+// under it, with * and ** as in exclude patterns, and any other key, such
+// as git.repository_url or originator, a value where * stands for any
+// text. A rule without a key is a cwd rule. A cwd starting with ~/ is
+// under the home directory, and a relative one is made absolute against
+// dir.
+func ParseSessionFilter(rule, dir string) (string, error) {
+	key, pattern, ok := strings.Cut(rule, "=")
+	if !ok {
+		key, pattern = cwdKey, rule
+	}
+	key, pattern = strings.TrimSpace(key), strings.TrimSpace(pattern)
+	if key == "" || pattern == "" {
+		return "", fmt.Errorf("invalid session filter %q: expected key=pattern", rule)
+	}
+	if key == cwdKey {
+		if rest, ok := strings.CutPrefix(pattern, "~/"); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			pattern = filepath.Join(home, rest)
+		}
+		if !filepath.IsAbs(pattern) && !strings.HasPrefix(pattern, "*") {
+			pattern = filepath.Join(dir, pattern)
+		}
+		pattern = filepath.ToSlash(pattern)
+		if err := fscopy.CheckPattern(pattern); err != nil {
+			return "", fmt.Errorf("invalid session filter %q: %w", rule, err)
+		}
+	}
+	return key + "=" + pattern, nil
+}
+
+// AddSessionFilter adds a rule, as ParseSessionFilter returns it, and
+// saves the configuration. When sharing is enabled, ~/.codex, holding
+// current, is set up again at once.
+func (m *Manager) AddSessionFilter(rule, current string) error {
+	if slices.Contains(m.config.SessionFilters, rule) {
+		return fmt.Errorf("session filter %s already exists", rule)
+	}
+	m.config.SessionFilters = append(m.config.SessionFilters, rule)
+	if err := m.SaveConfig(); err != nil {
+		return err
+	}
+	return m.SetupSymlinks(current)
+}
+
+// RemoveSessionFilter removes a rule and saves the configuration. When the
+// last one goes, every session is shared again from the next setup on.
+func (m *Manager) RemoveSessionFilter(rule string) error {
+	if !slices.Contains(m.config.SessionFilters, rule) {
+		return fmt.Errorf("no session filter %s", rule)
+	}
+	m.config.SessionFilters = slices.DeleteFunc(m.config.SessionFilters, func(r string) bool {
+		return r == rule
+	})
+	return m.SaveConfig()
+}
+
+// sessionShared reports whether the session file at path matches a
+// session filter. A session whose metadata cannot be read yet, as while
+// Codex starts writing it, is not.
+func (m *Manager) sessionShared(path string) bool {
+	meta, err := codex.SessionMeta(path)
+	if err != nil {
+		return false
+	}
+	for _, rule := range m.config.SessionFilters {
+		key, pattern, _ := strings.Cut(rule, "=")
+		value, ok := metaValue(meta, key)
+		if !ok {
+			continue
+		}
+		if key == cwdKey {
+			rel := strings.TrimPrefix(filepath.ToSlash(value), "/")
+			if (fscopy.Excludes{pattern}).Match(rel, true) {
+				return true
+			}
+		} else if globMatch(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// metaValue looks up the dotted key in session metadata, returning the
+// string it holds.
+func metaValue(meta map[string]any, key string) (string, bool) {
+	var value any = meta
+	for _, part := range strings.Split(key, ".") {
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return "", false
+		}
+		if value, ok = obj[part]; !ok {
+			return "", false
+		}
+	}
+	s, ok := value.(string)
+	return s, ok
+}
+
+// globMatch reports whether value matches pattern, where * stands for any
+// text, including slashes, and ? for one character.
+func globMatch(pattern, value string) bool {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+	ok, _ := regexp.MatchString("^"+expr+"$", value)
+	return ok
+}
+
+// setupFiltered shares the sessions matching the session filters between
+// ~/.codex and targetDir, file by file: shared sessions are linked into
+// ~/.codex, as are matching sessions of its own into targetDir, with
+// hardlinks or, where those cannot be made, synced copies. Sessions that
+// match no filter stay in ~/.codex alone.
+func (m *Manager) setupFiltered(targetDir string) error {
+	src := filepath.Join(m.paths.Home, sessionsItem)
+	dest := filepath.Join(targetDir, sessionsItem)
+
+	// A symlink to the whole of the shared sessions is replaced by a
+	// directory of their own
+	if info, err := os.Lstat(src); err == nil && info.Mode()&(os.ModeSymlink|os.ModeIrregular) != 0 {
+		if err := os.Remove(src); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(src, 0755); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(dest); os.IsNotExist(err) {
+		if err := createTarget(sessionsItem, dest); err != nil {
+			return err
+		}
+	}
+
+	if err := mirrorTree(dest, src, false, linkOrCopy); err != nil {
+		return err
+	}
+	return mirrorTree(src, dest, true, func(path, target string) error {
+		if _, err := os.Stat(target); os.IsNotExist(err) && !m.sessionShared(path) {
+			return nil
+		}
+		return linkOrCopy(path, target)
+	})
+}
+
+// linkedItem reports whether item is shared in ~/.codex as a real file or
+// directory whose files are linked with, or copies of, the shared ones,
+// rather than as a link to the shared copy.
+func (m *Manager) linkedItem(item string) bool {
+	return m.Strategy() != StrategySymlink || (item == sessionsItem && m.filtered())
+}
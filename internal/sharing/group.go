@@ -0,0 +1,124 @@
+package sharing
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ValidateGroupName checks that name is usable as a group, which becomes a
+// directory name.
+func ValidateGroupName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("group name cannot be empty")
+	case name == "." || name == "..":
+		return fmt.Errorf("invalid group name '%s'", name)
+	case strings.ContainsAny(name, `/\`):
+		return fmt.Errorf("group name '%s' cannot contain path separators", name)
+	case strings.HasPrefix(name, "."):
+		return fmt.Errorf("group name '%s' cannot start with a dot", name)
+	}
+	return nil
+}
+
+// Groups returns every group, each with the accounts assigned to it in
+// name order. A group exists once it has a directory, so groups without
+// accounts are listed too.
+func (m *Manager) Groups() (map[string][]string, error) {
+	groups := make(map[string][]string)
+	entries, err := os.ReadDir(m.paths.GroupsDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			groups[entry.Name()] = []string{}
+		}
+	}
+	for account, group := range m.config.Groups {
+		groups[group] = append(groups[group], account)
+	}
+	for _, accounts := range groups {
+		sort.Strings(accounts)
+	}
+	return groups, nil
+}
+
+// Group returns the group account is assigned to, if any.
+func (m *Manager) Group(account string) (string, bool) {
+	group, ok := m.config.Groups[account]
+	return group, ok
+}
+
+// CreateGroup creates an empty group.
+func (m *Manager) CreateGroup(name string) error {
+	if err := ValidateGroupName(name); err != nil {
+		return err
+	}
+	dir := filepath.Join(m.paths.GroupsDir, name)
+	if _, err := os.Stat(dir); err == nil {
+		return fmt.Errorf("group '%s' already exists", name)
+	}
+	return os.MkdirAll(dir, 0755)
+}
+
+// DeleteGroup deletes a group and the sessions and history shared in it.
+// Accounts must be taken out of it first.
+func (m *Manager) DeleteGroup(name string) error {
+	if err := m.checkGroup(name); err != nil {
+		return err
+	}
+	var members []string
+	for account, group := range m.config.Groups {
+		if group == name {
+			members = append(members, account)
+		}
+	}
+	if len(members) > 0 {
+		sort.Strings(members)
+		return fmt.Errorf("group '%s' still has accounts: %s", name, strings.Join(members, ", "))
+	}
+	return os.RemoveAll(filepath.Join(m.paths.GroupsDir, name))
+}
+
+// Assign puts account in group, or takes it out of its group when group
+// is empty, and saves the configuration. It takes effect the next time
+// the account is switched to, or at once for current, the account in
+// ~/.codex.
+func (m *Manager) Assign(account, group, current string) error {
+	if group != "" {
+		if err := m.checkGroup(group); err != nil {
+			return err
+		}
+	}
+	if m.config.Groups == nil {
+		m.config.Groups = make(map[string]string)
+	}
+	if group == "" {
+		delete(m.config.Groups, account)
+	} else {
+		m.config.Groups[account] = group
+	}
+	if err := m.SaveConfig(); err != nil {
+		return err
+	}
+	if account == current && m.config.Mode == ModeGroup {
+		return m.SetupSymlinks(current)
+	}
+	return nil
+}
+
+// checkGroup returns an error if the group does not exist.
+func (m *Manager) checkGroup(name string) error {
+	if err := ValidateGroupName(name); err != nil {
+		return err
+	}
+	info, err := os.Stat(filepath.Join(m.paths.GroupsDir, name))
+	if os.IsNotExist(err) || (err == nil && !info.IsDir()) {
+		return fmt.Errorf("group '%s' not found", name)
+	}
+	return err
+}
@@ -0,0 +1,266 @@
+package sharing
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/delhombre/cxa/internal/fscopy"
+)
+
+// Strategy is how shared items are put in ~/.codex.
+type Strategy string
+
+const (
+	// StrategySymlink makes each shared item in ~/.codex a symlink to the
+	// shared copy.
+	StrategySymlink Strategy = "symlink"
+
+	// StrategyHardlink keeps each shared item in ~/.codex a real file or
+	// directory whose files are hardlinks to the shared copies, for
+	// tools that resolve symlinks and then misbehave. Files Codex creates
+	// or replaces break the link; Reconcile brings both sides together
+	// again, and runs on every switch.
+	StrategyHardlink Strategy = "hardlink"
+
+	// StrategyJunction shares directories as junctions and files as
+	// hardlinks, which Windows lets any user create, unlike symlinks.
+	// Files are reconciled like under StrategyHardlink, and are synced by
+	// copying where they cannot be hardlinked, such as across volumes. It
+	// is the default on Windows; elsewhere directories are symlinks.
+	StrategyJunction Strategy = "junction"
+
+	// StrategyReadOnly attaches the shared copies read-only: each shared
+	// item in ~/.codex is a real file or directory of the account's own,
+	// into which the shared files it lacks are copied on every switch.
+	// What Codex writes, such as new sessions, stays with the account
+	// until Promote copies it to the shared copy.
+	StrategyReadOnly Strategy = "read-only"
+)
+
+// linkSuffix names the temporary link made while a file is relinked.
+const linkSuffix = ".cxa-link"
+
+// Strategy returns how shared items are put in ~/.codex.
+func (m *Manager) Strategy() Strategy {
+	if m.config.Strategy == "" {
+		return DefaultStrategy
+	}
+	return m.config.Strategy
+}
+
+// Reconcile brings the shared items of ~/.codex and their shared copies
+// back in line under the hardlink and junction strategies, for account,
+// the account in ~/.codex, then attributes what it added to the shared
+// history to it. Nothing needs bringing in line under the symlink
+// strategy, where there is only one copy unless session filters leave the
+// sessions to be linked file by file, nor the read-only one, where the
+// account keeps what it writes.
+func (m *Manager) Reconcile(account string) error {
+	if !m.IsEnabled() {
+		return nil
+	}
+	if (m.Strategy() != StrategySymlink || m.filtered()) && m.Strategy() != StrategyReadOnly {
+		if err := m.SetupSymlinks(account); err != nil {
+			return err
+		}
+	}
+	return m.Attribute(account)
+}
+
+// setupHardlink shares item between ~/.codex and targetDir with hardlinks.
+func (m *Manager) setupHardlink(item, targetDir string) error {
+	return m.setupLinked(item, targetDir, relink)
+}
+
+// setupLinked shares item between ~/.codex and targetDir as two trees
+// whose files place puts in line.
+func (m *Manager) setupLinked(item, targetDir string, place func(src, dst string) error) error {
+	src := filepath.Join(m.paths.Home, item)
+	dest := filepath.Join(targetDir, item)
+
+	// A symlink left by the symlink strategy points at the shared copy,
+	// which has everything already
+	if info, err := os.Lstat(src); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(src); err != nil {
+			return err
+		}
+	}
+
+	_, srcErr := os.Lstat(src)
+	_, destErr := os.Lstat(dest)
+	if os.IsNotExist(srcErr) && os.IsNotExist(destErr) {
+		if err := createTarget(item, dest); err != nil {
+			return err
+		}
+	}
+	if err := linkTrees(src, dest, place); err != nil {
+		var linkErr *os.LinkError
+		if errors.As(err, &linkErr) {
+			return fmt.Errorf("%w (hardlink sharing needs ~/.codex and the shared directory on the same filesystem)", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// linkTrees makes every regular file under live and shared a hardlink of
+// its counterpart on the other side, with place. A file on one side only
+// is linked into the other; where both sides hold different files, the one
+// modified last wins. Nothing is deleted, so a file removed on one side
+// comes back.
+func linkTrees(live, shared string, place func(src, dst string) error) error {
+	if err := mirrorTree(shared, live, false, place); err != nil {
+		return err
+	}
+	return mirrorTree(live, shared, true, place)
+}
+
+// mirrorTree places each regular file under from into to, where to lacks
+// it or holds a different, older file. With newer false, a file of the
+// same age in to is replaced too.
+func mirrorTree(from, to string, newer bool, place func(src, dst string) error) error {
+	if _, err := os.Lstat(from); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.Walk(from, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(to, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+		if !info.Mode().IsRegular() || filepath.Ext(path) == linkSuffix {
+			return nil
+		}
+		existing, err := os.Lstat(target)
+		switch {
+		case os.IsNotExist(err):
+		case err != nil:
+			return err
+		case os.SameFile(info, existing):
+			return nil
+		case newer && !info.ModTime().After(existing.ModTime()):
+			return nil
+		case !newer && info.ModTime().Before(existing.ModTime()):
+			return nil
+		}
+		return place(path, target)
+	})
+}
+
+// relink makes dst a hardlink of src, replacing what is there in one
+// step.
+func relink(src, dst string) error {
+	tmp := dst + linkSuffix
+	if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Link(src, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// linkOrCopy makes dst a hardlink of src or, where that cannot be done,
+// a copy of it with the same modification time, which then stands in for
+// the link until the next reconcile. A copy already up to date is kept.
+func linkOrCopy(src, dst string) error {
+	err := relink(src, dst)
+	var linkErr *os.LinkError
+	if !errors.As(err, &linkErr) {
+		return err
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if existing, err := os.Stat(dst); err == nil && existing.Size() == info.Size() && existing.ModTime().Equal(info.ModTime()) {
+		return nil
+	}
+	tmp := dst + linkSuffix
+	if err := fscopy.File(src, tmp, 0, nil); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chtimes(tmp, info.ModTime(), info.ModTime()); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// unlinkTree gives every file under live that is a hardlink of its
+// counterpart under one of shared a copy of its own, so ~/.codex stops
+// writing into what is shared.
+func unlinkTree(live string, shared []string) error {
+	if _, err := os.Lstat(live); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.WalkDir(live, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(live, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		for _, dir := range shared {
+			other, err := os.Lstat(filepath.Join(dir, rel))
+			if err != nil || !os.SameFile(info, other) {
+				continue
+			}
+			tmp := path + linkSuffix
+			if err := fscopy.File(path, tmp, 0, nil); err != nil {
+				os.Remove(tmp)
+				return err
+			}
+			return os.Rename(tmp, path)
+		}
+		return nil
+	})
+}
+
+// shareTargets returns every directory items may be shared in: the shared
+// directory and the directory of each group.
+func (m *Manager) shareTargets() []string {
+	targets := []string{m.paths.SharedDir}
+	entries, _ := os.ReadDir(m.paths.GroupsDir)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			targets = append(targets, filepath.Join(m.paths.GroupsDir, entry.Name()))
+		}
+	}
+	return targets
+}
+
+// inShareTarget reports whether path is in one of the directories items
+// may be shared in.
+func (m *Manager) inShareTarget(path string) bool {
+	for _, dir := range m.shareTargets() {
+		if rel, err := filepath.Rel(dir, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
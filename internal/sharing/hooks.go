@@ -0,0 +1,62 @@
+package sharing
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/delhombre/cxa/internal/config"
+)
+
+// fire runs the hooks of event, after sharing changed underneath whatever
+// runs on ~/.codex: the executables named for the event in the hooks
+// directory, with or without an extension, then the shell command the cxa
+// config holds for it. account is the account in ~/.codex, if known.
+// They run with the sharing setup in their environment and their output
+// on stderr. A hook that fails is recorded as a warning; what it follows
+// has happened already.
+func (m *Manager) fire(event, account string) {
+	var hooks []*exec.Cmd
+	entries, _ := os.ReadDir(m.paths.HooksDir())
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.TrimSuffix(name, filepath.Ext(name)) != event {
+			continue
+		}
+		hooks = append(hooks, exec.Command(filepath.Join(m.paths.HooksDir(), name)))
+	}
+	if cfg, err := config.Load(m.paths.ConfigFile()); err == nil && cfg.Hooks[event] != "" {
+		if runtime.GOOS == "windows" {
+			hooks = append(hooks, exec.Command("cmd", "/C", cfg.Hooks[event]))
+		} else {
+			hooks = append(hooks, exec.Command("/bin/sh", "-c", cfg.Hooks[event]))
+		}
+	}
+	if len(hooks) == 0 {
+		return
+	}
+
+	target := ""
+	if account != "" {
+		target = m.getShareTarget(account)
+	}
+	env := append(os.Environ(),
+		"CXA_HOOK="+event,
+		"CXA_ACCOUNT="+account,
+		"CXA_SHARE_MODE="+string(m.config.Mode),
+		"CXA_SHARE_STRATEGY="+string(m.Strategy()),
+		"CXA_SHARE_DIR="+target,
+		"CODEX_HOME="+m.paths.Home,
+	)
+	for _, hook := range hooks {
+		hook.Env = env
+		hook.Stdout = os.Stderr
+		hook.Stderr = os.Stderr
+		if err := hook.Run(); err != nil {
+			m.warnings.Record("hooks", fmt.Sprintf("%s hook %s failed: %v", event, strings.Join(hook.Args, " "), err))
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package sharing
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/delhombre/cxa/internal/flock"
+	"github.com/delhombre/cxa/pkg/codex"
+)
+
+// Items returns every item that may be shared: the built-in ones, the
+// settings, and those added with AddItem.
+func (m *Manager) Items() []string {
+	items := append(slices.Clone(codex.ShareableItems), codex.OptionalShareableItems...)
+	return append(items, m.config.Items...)
+}
+
+// CustomItems returns the items added with AddItem.
+func (m *Manager) CustomItems() []string {
+	return slices.Clone(m.config.Items)
+}
+
+// ValidateItem checks that path can be shared and returns it as a slash
+// separated path relative to ~/.codex. path may be relative to ~/.codex or
+// absolute inside it. Items that stay with each account, cxa's own files,
+// and items already shareable, within one, or holding one are refused.
+func (m *Manager) ValidateItem(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("item path cannot be empty")
+	}
+	rel := path
+	if filepath.IsAbs(path) {
+		var err error
+		if rel, err = filepath.Rel(m.paths.Home, path); err != nil {
+			return "", fmt.Errorf("%s is not under %s", path, m.paths.Home)
+		}
+	}
+	rel = filepath.ToSlash(filepath.Clean(rel))
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") || filepath.IsAbs(rel) {
+		return "", fmt.Errorf("%s is not under %s", path, m.paths.Home)
+	}
+
+	top, _, _ := strings.Cut(rel, "/")
+	switch {
+	case slices.Contains(codex.AccountSpecificItems, top):
+		return "", fmt.Errorf("%s stays with each account and cannot be shared", top)
+	case top == ".account.json" || top == codex.ConfigOverrideFile:
+		return "", fmt.Errorf("%s belongs to the account and cannot be shared", top)
+	}
+	for _, item := range m.Items() {
+		switch {
+		case rel == item:
+			return "", fmt.Errorf("%s is already shareable", rel)
+		case strings.HasPrefix(rel, item+"/"):
+			return "", fmt.Errorf("%s is inside %s, which is shareable", rel, item)
+		case strings.HasPrefix(item, rel+"/"):
+			return "", fmt.Errorf("%s holds %s, which is shareable", rel, item)
+		}
+	}
+	return rel, nil
+}
+
+// AddItem makes path shareable and saves the configuration. When sharing
+// is enabled it is shared at once for current, the account in ~/.codex,
+// and from then on like the built-in items. It returns the item as stored.
+func (m *Manager) AddItem(path, current string) (string, error) {
+	item, err := m.ValidateItem(path)
+	if err != nil {
+		return "", err
+	}
+	m.config.Items = append(m.config.Items, item)
+	if err := m.SaveConfig(); err != nil {
+		return "", err
+	}
+	return item, m.SetupSymlinks(current)
+}
+
+// RemoveItem stops sharing an item added with AddItem and saves the
+// configuration. ~/.codex keeps a copy of what was shared.
+func (m *Manager) RemoveItem(path string) (string, error) {
+	item := filepath.ToSlash(filepath.Clean(path))
+	if filepath.IsAbs(path) {
+		if rel, err := filepath.Rel(m.paths.Home, path); err == nil {
+			item = filepath.ToSlash(rel)
+		}
+	}
+	if !slices.Contains(m.config.Items, item) {
+		if slices.Contains(m.Items(), item) {
+			return "", fmt.Errorf("%s is built in and cannot be removed", item)
+		}
+		return "", fmt.Errorf("%s is not a shareable item", item)
+	}
+	unlock, err := flock.Acquire(m.paths.LockFile(), flock.DefaultWait)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	if err := m.unshareItem(item); err != nil {
+		return "", err
+	}
+	m.config.Items = slices.DeleteFunc(m.config.Items, func(name string) bool {
+		return name == item
+	})
+	return item, m.SaveConfig()
+}
@@ -0,0 +1,28 @@
+package sharing
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// setupJunction shares a directory item as a junction to its shared copy
+// in targetDir, and a file item as a hardlink, or a synced copy where no
+// hardlink can be made.
+func (m *Manager) setupJunction(item, targetDir string) error {
+	if isDirItem(filepath.Join(m.paths.Home, item), filepath.Join(targetDir, item)) {
+		return m.setupLink(item, targetDir, createJunction)
+	}
+	return m.setupLinked(item, targetDir, linkOrCopy)
+}
+
+// isDirItem reports whether the shareable item at src, shared at dest, is
+// a directory: whichever of them exists, or else as createTarget would
+// make it.
+func isDirItem(src, dest string) bool {
+	for _, path := range []string{src, dest} {
+		if info, err := os.Stat(path); err == nil {
+			return info.IsDir()
+		}
+	}
+	return filepath.Ext(dest) == ""
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package sharing
+
+import "os"
+
+// DefaultStrategy is the strategy used until another is chosen.
+const DefaultStrategy = StrategySymlink
+
+// createJunction makes link a symlink to the directory target; junctions
+// only exist on Windows.
+func createJunction(target, link string) error {
+	return os.Symlink(target, link)
+}
@@ -0,0 +1,70 @@
+//go:build windows
+
+package sharing
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/windows"
+)
+
+// DefaultStrategy is the strategy used until another is chosen. Creating
+// symlinks on Windows needs Developer Mode or administrator rights, and
+// junctions do not.
+const DefaultStrategy = StrategyJunction
+
+// createJunction makes link a junction to the directory target.
+func createJunction(target, link string) error {
+	target, err := filepath.Abs(target)
+	if err != nil {
+		return err
+	}
+	substitute, err := windows.UTF16FromString(`\??\` + target)
+	if err != nil {
+		return err
+	}
+	printName, err := windows.UTF16FromString(target)
+	if err != nil {
+		return err
+	}
+
+	// A mount point reparse buffer: tag, data length, reserved, then the
+	// offsets and lengths of the two names, which follow NUL-terminated
+	pathBytes := 2 * (len(substitute) + len(printName))
+	buf := make([]byte, 16+pathBytes)
+	binary.LittleEndian.PutUint32(buf[0:], windows.IO_REPARSE_TAG_MOUNT_POINT)
+	binary.LittleEndian.PutUint16(buf[4:], uint16(8+pathBytes))
+	binary.LittleEndian.PutUint16(buf[8:], 0)
+	binary.LittleEndian.PutUint16(buf[10:], uint16(2*(len(substitute)-1)))
+	binary.LittleEndian.PutUint16(buf[12:], uint16(2*len(substitute)))
+	binary.LittleEndian.PutUint16(buf[14:], uint16(2*(len(printName)-1)))
+	for i, c := range append(substitute, printName...) {
+		binary.LittleEndian.PutUint16(buf[16+2*i:], c)
+	}
+
+	if err := os.Mkdir(link, 0755); err != nil {
+		return err
+	}
+	name, err := windows.UTF16PtrFromString(link)
+	if err != nil {
+		os.Remove(link)
+		return err
+	}
+	handle, err := windows.CreateFile(name, windows.GENERIC_WRITE, 0, nil, windows.OPEN_EXISTING,
+		windows.FILE_FLAG_OPEN_REPARSE_POINT|windows.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		os.Remove(link)
+		return &os.LinkError{Op: "junction", Old: target, New: link, Err: err}
+	}
+
+	var returned uint32
+	err = windows.DeviceIoControl(handle, windows.FSCTL_SET_REPARSE_POINT, &buf[0], uint32(len(buf)), nil, 0, &returned, nil)
+	windows.CloseHandle(handle)
+	if err != nil {
+		os.Remove(link)
+		return &os.LinkError{Op: "junction", Old: target, New: link, Err: err}
+	}
+	return nil
+}
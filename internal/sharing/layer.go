@@ -0,0 +1,344 @@
+package sharing
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/delhombre/cxa/pkg/codex"
+)
+
+// configItem is the shareable item an account may layer its own settings
+// over.
+const configItem = "config.toml"
+
+// layered reports whether the config.toml of ~/.codex is layered: settings
+// are shared and the account has its own overrides.
+func (m *Manager) layered() bool {
+	if !m.config.IncludeSettings {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(m.paths.Home, codex.ConfigOverrideFile))
+	return err == nil
+}
+
+// setupLayered writes the config.toml of ~/.codex as the shared one in
+// targetDir with the account's overrides applied. Changes made to the
+// written file last until the next switch; lasting ones go in the shared
+// config.toml or the overrides.
+func (m *Manager) setupLayered(targetDir string) error {
+	src := filepath.Join(m.paths.Home, configItem)
+	dest := filepath.Join(targetDir, configItem)
+
+	info, err := os.Lstat(src)
+	switch {
+	case err == nil && info.Mode()&os.ModeSymlink != 0:
+		if err := os.Remove(src); err != nil {
+			return err
+		}
+	case err == nil:
+		// A config.toml of the account's own becomes the shared one if
+		// there is none yet, as setupSymlink migrates it
+		if _, err := os.Stat(dest); os.IsNotExist(err) {
+			if err := os.Rename(src, dest); err != nil {
+				return err
+			}
+		}
+	case !os.IsNotExist(err):
+		return err
+	}
+	if _, err := os.Stat(dest); os.IsNotExist(err) {
+		if err := createTarget(configItem, dest); err != nil {
+			return err
+		}
+	}
+
+	base, err := os.ReadFile(dest)
+	if err != nil {
+		return err
+	}
+	overrides, err := os.ReadFile(filepath.Join(m.paths.Home, codex.ConfigOverrideFile))
+	if err != nil {
+		return err
+	}
+	merged := LayerConfig(base, overrides)
+	if current, err := os.ReadFile(src); err == nil && bytes.Equal(current, merged) {
+		return nil
+	}
+	return os.WriteFile(src, merged, 0644)
+}
+
+// LayerConfig returns the config.toml base with the settings of overrides
+// applied: a setting in both takes its value from overrides, and one only
+// in overrides is added to its table. Everything else in base, comments
+// included, is kept as written. Arrays of tables in overrides are added
+// whole. It works on key paths and raw values, not a full TOML
+// implementation.
+func LayerConfig(base, overrides []byte) []byte {
+	blocks := splitBlocks(base)
+	over := splitBlocks(overrides)
+
+	// Replace the settings base has too
+	used := make([]bool, len(over))
+	for i, b := range blocks {
+		if b.path == nil {
+			continue
+		}
+		for j, o := range over {
+			if o.path != nil && slices.Equal(o.path, b.path) {
+				blocks[i].lines = append([]string{b.key + " = " + o.value[0]}, o.value[1:]...)
+				used[j] = true
+			}
+		}
+	}
+
+	// Add the rest to their tables, creating those base lacks
+	var section []string
+	var appended []string
+	inArray := false
+	for j, o := range over {
+		switch {
+		case o.header != nil:
+			section, inArray = o.header, o.array
+			if inArray {
+				appended = append(appended, o.lines...)
+			}
+			continue
+		case inArray:
+			appended = append(appended, o.lines...)
+			continue
+		case o.path == nil || used[j]:
+			continue
+		}
+
+		at := tableEnd(blocks, section)
+		if at < 0 {
+			header := "[" + strings.Join(quoteKeys(section), ".") + "]"
+			blocks = append(blocks, block{lines: []string{"", header}, header: section})
+			at = len(blocks)
+		}
+		blocks = slices.Insert(blocks, at, block{lines: o.lines, path: o.path})
+	}
+	if len(appended) > 0 {
+		appended = append([]string{""}, appended...)
+	}
+
+	var buf bytes.Buffer
+	for _, b := range blocks {
+		for _, line := range b.lines {
+			buf.WriteString(line)
+			buf.WriteByte('\n')
+		}
+	}
+	for _, line := range appended {
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// block is a table header, a setting with its continuation lines, or any
+// other line of a config.toml.
+type block struct {
+	lines []string
+
+	// header is the path of a table header; array is set for an array of
+	// tables, whose settings are never layered.
+	header []string
+	array  bool
+
+	// path is the full key path of a setting, key its key as written and
+	// value its value lines as written.
+	path  []string
+	key   string
+	value []string
+}
+
+// splitBlocks splits a config.toml into blocks. Lines it cannot make
+// sense of are kept as they are.
+func splitBlocks(data []byte) []block {
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+
+	var blocks []block
+	var section []string
+	array := false
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(stripComment(lines[i]))
+		switch {
+		case line == "":
+			blocks = append(blocks, block{lines: lines[i : i+1]})
+			continue
+		case strings.HasPrefix(line, "[["):
+			array = true
+			section = nil
+			blocks = append(blocks, block{lines: lines[i : i+1], header: []string{}, array: true})
+			continue
+		case strings.HasPrefix(line, "["):
+			path := splitKey(strings.TrimSuffix(line[1:], "]"))
+			if path == nil {
+				blocks = append(blocks, block{lines: lines[i : i+1]})
+				continue
+			}
+			array = false
+			section = path
+			blocks = append(blocks, block{lines: lines[i : i+1], header: path})
+			continue
+		}
+
+		rawKey, value, ok := strings.Cut(lines[i], "=")
+		key := splitKey(rawKey)
+		if !ok || key == nil {
+			blocks = append(blocks, block{lines: lines[i : i+1]})
+			continue
+		}
+		start := i
+		value = strings.TrimSpace(value)
+		for !complete(stripComment(value)) && i+1 < len(lines) {
+			i++
+			value += "\n" + lines[i]
+		}
+		b := block{lines: lines[start : i+1]}
+		if !array {
+			b.path = append(slices.Clone(section), key...)
+			b.key = strings.TrimSpace(rawKey)
+			b.value = strings.Split(value, "\n")
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks
+}
+
+// tableEnd returns where a setting of the table at section goes in
+// blocks: after the last setting of the table, or -1 if blocks lack it.
+// Settings of no table go after the last one before the first header.
+func tableEnd(blocks []block, section []string) int {
+	end := -1
+	in := len(section) == 0
+	if in {
+		end = 0
+	}
+	for i, b := range blocks {
+		switch {
+		case b.header != nil && len(section) == 0:
+			return end
+		case b.header != nil:
+			in = !b.array && slices.Equal(b.header, section)
+			if in {
+				end = i + 1
+			}
+		case in && b.path != nil:
+			end = i + 1
+		}
+	}
+	return end
+}
+
+// complete reports whether value holds a whole TOML value, rather than
+// the first lines of a multi-line string, array, or inline table.
+func complete(value string) bool {
+	for _, delim := range []string{`"""`, `'''`} {
+		if strings.HasPrefix(value, delim) {
+			return len(value) >= 6 && strings.Contains(value[3:], delim)
+		}
+	}
+	depth := 0
+	var quote rune
+	escaped := false
+	for _, r := range value {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if r == '\\' && quote == '"' {
+				escaped = true
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '[' || r == '{':
+			depth++
+		case r == ']' || r == '}':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+// splitKey splits a dotted, possibly quoted, TOML key into its parts, or
+// returns nil if it is not a key.
+func splitKey(key string) []string {
+	var parts []string
+	rest := strings.TrimSpace(key)
+	for rest != "" {
+		var part string
+		if rest[0] == '"' || rest[0] == '\'' {
+			end := strings.IndexByte(rest[1:], rest[0])
+			if end < 0 {
+				return nil
+			}
+			part, rest = rest[1:end+1], strings.TrimSpace(rest[end+2:])
+		} else {
+			end := strings.IndexByte(rest, '.')
+			if end < 0 {
+				end = len(rest)
+			}
+			part, rest = strings.TrimSpace(rest[:end]), rest[end:]
+			if part == "" || strings.ContainsAny(part, " \t\"'[]{}=") {
+				return nil
+			}
+		}
+		parts = append(parts, part)
+		if rest == "" {
+			break
+		}
+		if rest[0] != '.' {
+			return nil
+		}
+		rest = strings.TrimSpace(rest[1:])
+		if rest == "" {
+			return nil
+		}
+	}
+	return parts
+}
+
+// quoteKeys quotes the parts of a key path that need it.
+func quoteKeys(path []string) []string {
+	quoted := make([]string, len(path))
+	for i, part := range path {
+		if strings.ContainsAny(part, " .\"'[]{}=#") {
+			part = `"` + strings.ReplaceAll(part, `"`, `\"`) + `"`
+		}
+		quoted[i] = part
+	}
+	return quoted
+}
+
+// stripComment removes a trailing comment from a line.
+func stripComment(line string) string {
+	var quote rune
+	escaped := false
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0:
+			if r == '\\' && quote == '"' {
+				escaped = true
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -0,0 +1,161 @@
+package sharing_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/delhombre/cxa/internal/sharing"
+	"github.com/delhombre/cxa/pkg/codex"
+)
+
+func TestLayerConfig(t *testing.T) {
+	base := `# shared settings
+model = "o3"
+approval_policy = "on-request"
+
+[tui]
+theme = "dark" # keep it dark
+
+[[notify]]
+command = "say"
+`
+	tests := []struct {
+		name      string
+		overrides string
+		want      string
+	}{
+		{
+			name:      "no overrides",
+			overrides: "",
+			want:      base,
+		},
+		{
+			name:      "replaces a top-level setting",
+			overrides: "model = \"gpt-5\"\n",
+			want: `# shared settings
+model = "gpt-5"
+approval_policy = "on-request"
+
+[tui]
+theme = "dark" # keep it dark
+
+[[notify]]
+command = "say"
+`,
+		},
+		{
+			name:      "replaces a table setting by dotted key",
+			overrides: "tui.theme = \"light\"\n",
+			want: `# shared settings
+model = "o3"
+approval_policy = "on-request"
+
+[tui]
+theme = "light"
+
+[[notify]]
+command = "say"
+`,
+		},
+		{
+			name: "adds settings and tables",
+			overrides: `sandbox_mode = "read-only"
+
+[tui]
+animations = false
+
+[profiles.work]
+model = "gpt-5"
+`,
+			want: `# shared settings
+model = "o3"
+approval_policy = "on-request"
+sandbox_mode = "read-only"
+
+[tui]
+theme = "dark" # keep it dark
+animations = false
+
+[[notify]]
+command = "say"
+
+[profiles.work]
+model = "gpt-5"
+`,
+		},
+		{
+			name: "replaces a multi-line value",
+			overrides: `approval_policy = [
+  "never",
+]
+`,
+			want: `# shared settings
+model = "o3"
+approval_policy = [
+  "never",
+]
+
+[tui]
+theme = "dark" # keep it dark
+
+[[notify]]
+command = "say"
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(sharing.LayerConfig([]byte(base), []byte(tt.overrides)))
+			if got != tt.want {
+				t.Errorf("LayerConfig() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManager_LayeredConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	homeDir := filepath.Join(tmpDir, ".codex")
+	if err := os.MkdirAll(homeDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	paths := codex.NewPathsAt(tmpDir)
+	config := filepath.Join(homeDir, "config.toml")
+	if err := os.WriteFile(config, []byte("model = \"o3\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(homeDir, codex.ConfigOverrideFile), []byte("model = \"gpt-5\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	manager := sharing.NewManagerWithPaths(paths)
+	if err := manager.EnableMode(sharing.ModeGlobal, sharing.StrategySymlink, true, "work"); err != nil {
+		t.Fatalf("EnableMode failed: %v", err)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(paths.SharedDir, "config.toml")); err != nil || string(data) != "model = \"o3\"\n" {
+		t.Errorf("expected the account's config.toml to become the shared base, got %q (%v)", data, err)
+	}
+	if data, err := os.ReadFile(config); err != nil || string(data) != "model = \"gpt-5\"\n" {
+		t.Errorf("expected the overrides applied to config.toml, got %q (%v)", data, err)
+	}
+	_, _, items := manager.Status("work")
+	for _, item := range items {
+		if item.Item == "config.toml" && (item.State != sharing.ItemLayered || !item.Healthy()) {
+			t.Errorf("expected config.toml layered, got %+v", item)
+		}
+	}
+
+	// Without overrides, the next account links the shared base again
+	if err := os.Remove(filepath.Join(homeDir, codex.ConfigOverrideFile)); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.SetupSymlinks("personal"); err != nil {
+		t.Fatalf("SetupSymlinks failed: %v", err)
+	}
+	if link, err := os.Readlink(config); err != nil || link != filepath.Join(paths.SharedDir, "config.toml") {
+		t.Errorf("expected config.toml linked to the shared base, got %q (%v)", link, err)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package sharing
+
+// lockHeld always reports false: this platform has no file locks to find.
+func lockHeld(path string) (bool, error) {
+	return false, nil
+}
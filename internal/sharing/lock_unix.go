@@ -0,0 +1,28 @@
+//go:build unix
+
+package sharing
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockHeld reports whether another process holds a POSIX lock on any part
+// of the file at path, as SQLite takes them.
+func lockHeld(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) || os.IsPermission(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer f.Close()
+
+	lock := unix.Flock_t{Type: unix.F_WRLCK}
+	if err := unix.FcntlFlock(f.Fd(), unix.F_GETLK, &lock); err != nil {
+		return false, err
+	}
+	return lock.Type != unix.F_UNLCK, nil
+}
@@ -0,0 +1,30 @@
+//go:build windows
+
+package sharing
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockHeld reports whether another process has the file at path open, as
+// SQLite keeps a database while it runs: opening it without sharing then
+// fails.
+func lockHeld(path string) (bool, error) {
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return false, err
+	}
+	h, err := windows.CreateFile(name, windows.GENERIC_READ, 0, nil, windows.OPEN_EXISTING, windows.FILE_ATTRIBUTE_NORMAL, 0)
+	switch {
+	case errors.Is(err, windows.ERROR_SHARING_VIOLATION):
+		return true, nil
+	case errors.Is(err, windows.ERROR_FILE_NOT_FOUND), errors.Is(err, windows.ERROR_ACCESS_DENIED):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	windows.CloseHandle(h)
+	return false, nil
+}
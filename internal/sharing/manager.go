@@ -3,10 +3,17 @@ package sharing
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 
+	"github.com/delhombre/cxa/internal/config"
+	"github.com/delhombre/cxa/internal/flock"
+	"github.com/delhombre/cxa/internal/fscopy"
+	"github.com/delhombre/cxa/internal/schema"
+	"github.com/delhombre/cxa/internal/warnings"
 	"github.com/delhombre/cxa/pkg/codex"
 )
 
@@ -17,26 +24,68 @@ const (
 	ModeDisabled Mode = "disabled"
 	ModeGlobal   Mode = "global"
 	ModeGroup    Mode = "group"
+	ModeSelected Mode = "selected"
 )
 
+// SchemaVersion is the newest sharing.json schema this build understands.
+const SchemaVersion = 5
+
+// Format is the sharing.json file format. Add a migration with every bump
+// of SchemaVersion.
+var Format = &schema.Format{
+	Name:    "sharing.json",
+	Version: SchemaVersion,
+	Migrations: []schema.Migration{
+		// 0 to 1: schema_version was added
+		nil,
+		// 1 to 2: strategy was added; older builds would drop it
+		nil,
+		// 2 to 3: accounts and the selected mode were added
+		nil,
+		// 3 to 4: items was added
+		nil,
+		// 4 to 5: session_filters was added
+		nil,
+	},
+}
+
 // Config holds the sharing configuration.
 type Config struct {
+	// SchemaVersion is the sharing.json schema the file was written with.
+	SchemaVersion int `json:"schema_version"`
+
 	Mode            Mode              `json:"mode"`
+	Strategy        Strategy          `json:"strategy,omitempty"`
 	IncludeSettings bool              `json:"include_settings"`
-	Groups          map[string]string `json:"groups"` // account -> group mapping
+	Groups          map[string]string `json:"groups"`             // account -> group mapping
+	Accounts        []string          `json:"accounts,omitempty"` // accounts sharing in selected mode
+	Items           []string          `json:"items,omitempty"`    // user-defined shareable paths under ~/.codex
+
+	// SessionFilters choose the sessions that are shared, as key=pattern
+	// rules matched against their metadata; the rest stay with each
+	// account. None shares every session.
+	SessionFilters []string `json:"session_filters,omitempty"`
 }
 
 // Manager handles session sharing between accounts.
 type Manager struct {
-	paths  *codex.Paths
-	config *Config
+	paths    *codex.Paths
+	config   *Config
+	warnings *warnings.Store
+	resolve  Resolver
+	progress func(Progress)
+
+	// unshared holds the items a Resolver left unshared, which the
+	// manager leaves alone from then on rather than asking again.
+	unshared map[string]bool
 }
 
-// NewManager creates a new sharing manager.
-func NewManager() *Manager {
+// NewManagerWithPaths creates a sharing manager working on paths.
+func NewManagerWithPaths(paths *codex.Paths) *Manager {
 	return &Manager{
-		paths:  codex.NewPaths(),
-		config: &Config{Mode: ModeDisabled},
+		paths:    paths,
+		config:   &Config{Mode: ModeDisabled},
+		warnings: warnings.NewStore(paths.WarningsFile()),
 	}
 }
 
@@ -51,6 +100,12 @@ func (m *Manager) LoadConfig() error {
 		return err
 	}
 
+	// A newer sharing.json is read as far as it is understood; SaveConfig
+	// refuses to overwrite it
+	var newer *schema.NewerError
+	if data, err = Format.Upgrade(data); err != nil && !errors.As(err, &newer) {
+		return err
+	}
 	var config Config
 	if err := json.Unmarshal(data, &config); err != nil {
 		return err
@@ -65,7 +120,11 @@ func (m *Manager) SaveConfig() error {
 	if err := m.paths.EnsureDirs(); err != nil {
 		return err
 	}
+	if err := Format.CheckWritable(m.paths.SharingConfigFile()); err != nil {
+		return err
+	}
 
+	m.config.SchemaVersion = SchemaVersion
 	data, err := json.MarshalIndent(m.config, "", "  ")
 	if err != nil {
 		return err
@@ -76,7 +135,11 @@ func (m *Manager) SaveConfig() error {
 
 // IsEnabled returns true if sharing is enabled.
 func (m *Manager) IsEnabled() bool {
-	return m.config.Mode == ModeGlobal || m.config.Mode == ModeGroup
+	switch m.config.Mode {
+	case ModeGlobal, ModeGroup, ModeSelected:
+		return true
+	}
+	return false
 }
 
 // GetMode returns the current sharing mode.
@@ -91,23 +154,55 @@ func (m *Manager) IncludesSettings() bool {
 
 // Enable enables global sharing.
 func (m *Manager) Enable(includeSettings bool) error {
-	m.config.Mode = ModeGlobal
+	return m.EnableMode(ModeGlobal, m.Strategy(), includeSettings, "")
+}
+
+// EnableMode enables sharing in mode with strategy, setting ~/.codex up
+// for account, the current account. Switching from another mode or
+// strategy first brings what was shared back into ~/.codex, as Disable
+// does, so it moves to its new place. What the shared history holds by
+// then is attributed to account, and the share-enable hooks run once it
+// is done.
+func (m *Manager) EnableMode(mode Mode, strategy Strategy, includeSettings bool, account string) error {
+	if mode != ModeGlobal && mode != ModeGroup && mode != ModeSelected {
+		return fmt.Errorf("unknown sharing mode '%s'", mode)
+	}
+	if strategy != StrategySymlink && strategy != StrategyHardlink && strategy != StrategyJunction && strategy != StrategyReadOnly {
+		return fmt.Errorf("unknown sharing strategy '%s'", strategy)
+	}
+	if m.IsEnabled() && (m.config.Mode != mode || m.Strategy() != strategy) {
+		if err := m.RemoveSymlinks(); err != nil {
+			return err
+		}
+	}
+	m.config.Mode = mode
+	m.config.Strategy = strategy
 	m.config.IncludeSettings = includeSettings
 
 	// Create shared directory
-	if err := os.MkdirAll(m.paths.SharedDir, 0755); err != nil {
-		return err
+	if mode == ModeGlobal || mode == ModeSelected {
+		if err := os.MkdirAll(m.paths.SharedDir, 0755); err != nil {
+			return err
+		}
 	}
 
 	// Setup symlinks
-	if err := m.SetupSymlinks(); err != nil {
+	if err := m.SetupSymlinks(account); err != nil {
 		return err
 	}
 
-	return m.SaveConfig()
+	if err := m.SaveConfig(); err != nil {
+		return err
+	}
+	if err := m.Attribute(account); err != nil {
+		m.warnings.Record("sharing", fmt.Sprintf("failed to attribute the shared history to '%s': %v", account, err))
+	}
+	m.fire(config.HookShareEnable, account)
+	return nil
 }
 
-// Disable disables sharing and copies data locally.
+// Disable disables sharing and copies data locally, then runs the
+// share-disable hooks.
 func (m *Manager) Disable() error {
 	// First, copy shared data back to local
 	if err := m.RemoveSymlinks(); err != nil {
@@ -117,36 +212,73 @@ func (m *Manager) Disable() error {
 	m.config.Mode = ModeDisabled
 	m.config.IncludeSettings = false
 
-	return m.SaveConfig()
+	if err := m.SaveConfig(); err != nil {
+		return err
+	}
+	m.fire(config.HookShareDisable, "")
+	return nil
 }
 
-// SetupSymlinks creates symlinks from ~/.codex to the shared location.
-func (m *Manager) SetupSymlinks() error {
+// SetupSymlinks creates symlinks from ~/.codex to where account, the
+// account in ~/.codex, shares: the shared directory, or in group mode the
+// directory of its group. Under the hardlink strategy the items are
+// hardlinked instead, and under the read-only one attached. An account
+// outside every group gets what its links pointed to copied back instead,
+// and shares nothing.
+func (m *Manager) SetupSymlinks(account string) error {
 	if !m.IsEnabled() {
 		return nil
 	}
 
-	targetDir := m.getShareTarget("")
+	unlock, err := flock.Acquire(m.paths.LockFile(), flock.DefaultWait)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	targetDir := m.getShareTarget(account)
 	if targetDir == "" {
-		return nil
+		return m.RemoveSymlinks()
 	}
 
 	if err := os.MkdirAll(targetDir, 0755); err != nil {
 		return err
 	}
 
-	// Setup symlinks for shareable items
-	for _, item := range codex.ShareableItems {
-		if err := m.setupSymlink(item, targetDir); err != nil {
-			return fmt.Errorf("failed to setup symlink for %s: %w", item, err)
+	setup := m.setupSymlink
+	switch m.Strategy() {
+	case StrategyHardlink:
+		setup = m.setupHardlink
+	case StrategyJunction:
+		setup = m.setupJunction
+	case StrategyReadOnly:
+		setup = m.setupReadOnly
+	}
+
+	// Setup symlinks for shareable items, built in and user-defined
+	for _, item := range append(slices.Clone(codex.ShareableItems), m.config.Items...) {
+		var err error
+		if item == sessionsItem && m.filtered() && m.Strategy() != StrategyReadOnly {
+			err = m.setupFiltered(targetDir)
+		} else {
+			err = setup(item, targetDir)
+		}
+		if err != nil && !errors.Is(err, ErrLeftUnshared) {
+			return fmt.Errorf("failed to share %s: %w", item, err)
 		}
 	}
 
 	// Optionally setup symlinks for settings
 	if m.config.IncludeSettings {
 		for _, item := range codex.OptionalShareableItems {
-			if err := m.setupSymlink(item, targetDir); err != nil {
-				return fmt.Errorf("failed to setup symlink for %s: %w", item, err)
+			var err error
+			if item == configItem && m.layered() {
+				err = m.setupLayered(targetDir)
+			} else {
+				err = setup(item, targetDir)
+			}
+			if err != nil && !errors.Is(err, ErrLeftUnshared) {
+				return fmt.Errorf("failed to share %s: %w", item, err)
 			}
 		}
 	}
@@ -155,75 +287,117 @@ func (m *Manager) SetupSymlinks() error {
 }
 
 func (m *Manager) setupSymlink(item, targetDir string) error {
+	return m.setupLink(item, targetDir, os.Symlink)
+}
+
+// setupLink shares item by making it in ~/.codex a link to its shared copy
+// in targetDir, with makeLink.
+func (m *Manager) setupLink(item, targetDir string, makeLink func(oldname, newname string) error) error {
 	src := filepath.Join(m.paths.Home, item)
 	dest := filepath.Join(targetDir, item)
 
 	// Check if already a symlink to the correct location
 	if link, err := os.Readlink(src); err == nil {
-		if link == dest {
+		_, statErr := os.Stat(dest)
+		if link == dest && statErr == nil {
 			return nil // Already correct
 		}
-		// Wrong symlink, remove it
+		// Wrong or dangling symlink, remove it
 		os.Remove(src)
+		if link == dest {
+			m.warnings.Record("sharing", fmt.Sprintf("recreated missing shared target for %s", item))
+		} else {
+			m.warnings.Record("sharing", fmt.Sprintf("retargeted %s from %s to %s", item, link, dest))
+		}
 	}
 
 	// If source exists and is not a symlink, migrate it
 	if info, err := os.Lstat(src); err == nil && info.Mode()&os.ModeSymlink == 0 {
 		if _, err := os.Stat(dest); os.IsNotExist(err) {
 			// Migrate to shared location
-			if err := os.Rename(src, dest); err != nil {
+			if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
 				return err
 			}
-		} else {
-			// Both exist, remove local copy
-			os.RemoveAll(src)
+			if err := m.move(item, src, dest); err != nil {
+				return err
+			}
+		} else if err := m.settle(item, src, dest); err != nil {
+			// Both exist; the local copy goes once what only it holds is
+			// taken care of
+			return err
 		}
 	}
 
 	// Ensure target exists
 	if _, err := os.Stat(dest); os.IsNotExist(err) {
-		// Create empty target
-		if filepath.Ext(item) != "" {
-			// File
-			if err := os.WriteFile(dest, []byte{}, 0644); err != nil {
-				return err
-			}
-		} else {
-			// Directory
-			if err := os.MkdirAll(dest, 0755); err != nil {
-				return err
-			}
+		if err := createTarget(item, dest); err != nil {
+			return err
 		}
 	}
 
 	// Create symlink
-	return os.Symlink(dest, src)
+	if err := os.MkdirAll(filepath.Dir(src), 0755); err != nil {
+		return err
+	}
+	return makeLink(dest, src)
+}
+
+// createTarget creates an empty shared copy of item at dest.
+func createTarget(item, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	if filepath.Ext(item) != "" {
+		// File
+		return os.WriteFile(dest, []byte{}, 0644)
+	}
+	// Directory
+	return os.MkdirAll(dest, 0755)
 }
 
 // RemoveSymlinks replaces symlinks with copies of the shared data.
 func (m *Manager) RemoveSymlinks() error {
-	allItems := append(codex.ShareableItems, codex.OptionalShareableItems...)
-
-	for _, item := range allItems {
-		src := filepath.Join(m.paths.Home, item)
+	unlock, err := flock.Acquire(m.paths.LockFile(), flock.DefaultWait)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
-		// Check if it's a symlink
-		link, err := os.Readlink(src)
-		if err != nil {
-			continue // Not a symlink
+	for _, item := range m.Items() {
+		if err := m.unshareItem(item); err != nil {
+			return err
 		}
+	}
 
-		// Remove the symlink
-		os.Remove(src)
+	return nil
+}
 
-		// Copy the target data back
-		if _, err := os.Stat(link); err == nil {
-			if err := copyPath(link, src); err != nil {
-				return err
+// unshareItem replaces item in ~/.codex, if shared, with a copy of the
+// shared data.
+func (m *Manager) unshareItem(item string) error {
+	src := filepath.Join(m.paths.Home, item)
+
+	// Check if it's a symlink
+	link, err := os.Readlink(src)
+	if err != nil {
+		// Not a symlink, but its files may be hardlinked
+		if m.linkedItem(item) {
+			var shared []string
+			for _, dir := range m.shareTargets() {
+				shared = append(shared, filepath.Join(dir, item))
 			}
+			return unlinkTree(src, shared)
 		}
+		return nil
 	}
 
+	// Remove the symlink
+	os.Remove(src)
+
+	// Copy the target data back
+	if _, err := os.Stat(link); err == nil {
+		return copyPath(link, src)
+	}
 	return nil
 }
 
@@ -236,30 +410,118 @@ func (m *Manager) getShareTarget(account string) string {
 			return filepath.Join(m.paths.GroupsDir, group)
 		}
 		return ""
+	case ModeSelected:
+		if slices.Contains(m.config.Accounts, account) {
+			return m.paths.SharedDir
+		}
+		return ""
 	default:
 		return ""
 	}
 }
 
-// Status returns the current sharing status.
-func (m *Manager) Status() (mode Mode, sharedDir string, symlinks map[string]string) {
-	mode = m.config.Mode
-	symlinks = make(map[string]string)
+// SharedItems returns where account's shared items live and which items
+// would be shared for it under the current configuration. Both are empty
+// when the account does not share.
+func (m *Manager) SharedItems(account string) (target string, items []string) {
+	target = m.getShareTarget(account)
+	if target == "" {
+		return "", nil
+	}
+
+	items = append(items, codex.ShareableItems...)
+	if m.config.IncludeSettings {
+		items = append(items, codex.OptionalShareableItems...)
+	}
+	items = append(items, m.config.Items...)
+	return target, items
+}
 
-	if mode == ModeGlobal {
-		sharedDir = m.paths.SharedDir
+// ItemState is the state of a shareable item in ~/.codex.
+type ItemState string
+
+const (
+	// ItemShared is an item linked to where its account shares.
+	ItemShared ItemState = "shared"
+	// ItemLocal is a real file or directory of the account's own, or a
+	// symlink of the user's to somewhere outside cxa's sharing directories.
+	ItemLocal ItemState = "local"
+	// ItemMissing is an item ~/.codex does not have.
+	ItemMissing ItemState = "missing"
+	// ItemBroken is a symlink whose target is gone.
+	ItemBroken ItemState = "broken"
+	// ItemStray is a symlink into a sharing directory other than where
+	// its account shares.
+	ItemStray ItemState = "stray"
+	// ItemLayered is a config.toml written from the shared one with the
+	// account's overrides applied.
+	ItemLayered ItemState = "layered"
+)
+
+// ItemStatus is the state of a shareable item in ~/.codex.
+type ItemStatus struct {
+	Item  string    `json:"item"`
+	State ItemState `json:"state"`
+
+	// Target is where the item links to, or under the hardlink strategy
+	// the shared copy it is linked with.
+	Target string `json:"target,omitempty"`
+
+	// Expected is whether the item should be shared.
+	Expected bool `json:"expected"`
+}
+
+// Healthy reports whether the item is as sharing expects it: shared if it
+// is expected to be, and otherwise a plain file or directory, if any.
+func (s ItemStatus) Healthy() bool {
+	if s.Expected {
+		return s.State == ItemShared || s.State == ItemLayered
 	}
+	return s.State == ItemLocal || s.State == ItemMissing
+}
 
-	allItems := append(codex.ShareableItems, codex.OptionalShareableItems...)
-	for _, item := range allItems {
+// Status returns the sharing status of ~/.codex, holding account: the
+// mode, where account shares, and the state of every shareable item.
+func (m *Manager) Status(account string) (mode Mode, target string, items []ItemStatus) {
+	mode = m.config.Mode
+	target, shared := m.SharedItems(account)
+	expected := make(map[string]bool)
+	for _, item := range shared {
+		expected[item] = true
+	}
+
+	for _, item := range m.Items() {
+		status := ItemStatus{Item: item, Expected: expected[item]}
 		src := filepath.Join(m.paths.Home, item)
-		if link, err := os.Readlink(src); err == nil {
-			symlinks[item] = link
+		dest := filepath.Join(target, item)
+		if info, err := os.Lstat(src); err == nil && info.Mode().IsRegular() && item == configItem && expected[item] && m.layered() {
+			status.State = ItemLayered
+			status.Target = dest
+		} else if link, err := os.Readlink(src); err == nil {
+			status.Target = link
+			_, statErr := os.Stat(src)
+			switch {
+			case statErr != nil:
+				status.State = ItemBroken
+			case target != "" && link == dest:
+				status.State = ItemShared
+			case m.inShareTarget(link):
+				status.State = ItemStray
+			default:
+				status.State = ItemLocal
+			}
 		} else if _, err := os.Stat(src); err == nil {
-			symlinks[item] = "(local)"
+			status.State = ItemLocal
+			if m.linkedItem(item) && target != "" {
+				if _, err := os.Stat(dest); err == nil {
+					status.State = ItemShared
+					status.Target = dest
+				}
+			}
 		} else {
-			symlinks[item] = "(missing)"
+			status.State = ItemMissing
 		}
+		items = append(items, status)
 	}
 
 	return
@@ -273,34 +535,7 @@ func copyPath(src, dst string) error {
 	}
 
 	if info.IsDir() {
-		return copyDir(src, dst)
+		return fscopy.Tree(src, dst, fscopy.Options{})
 	}
-	return copyFile(src, dst)
-}
-
-func copyDir(src, dst string) error {
-	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		relPath, _ := filepath.Rel(src, path)
-		dstPath := filepath.Join(dst, relPath)
-
-		if info.IsDir() {
-			return os.MkdirAll(dstPath, info.Mode())
-		}
-
-		return copyFile(path, dstPath)
-	})
-}
-
-func copyFile(src, dst string) error {
-	data, err := os.ReadFile(src)
-	if err != nil {
-		return err
-	}
-
-	info, _ := os.Stat(src)
-	return os.WriteFile(dst, data, info.Mode())
+	return fscopy.File(src, dst, 0, nil)
 }
@@ -3,9 +3,14 @@ package sharing_test
 import (
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/delhombre/cxa/internal/config"
 	"github.com/delhombre/cxa/internal/sharing"
+	"github.com/delhombre/cxa/pkg/codex"
 )
 
 func TestManager_EnableDisable(t *testing.T) {
@@ -20,10 +25,9 @@ func TestManager_EnableDisable(t *testing.T) {
 		t.Fatalf("failed to create sqlite dir: %v", err)
 	}
 
-	os.Setenv("HOME", tmpDir)
-	defer os.Unsetenv("HOME")
+	paths := codex.NewPathsAt(tmpDir)
 
-	manager := sharing.NewManager()
+	manager := sharing.NewManagerWithPaths(paths)
 
 	// Initially disabled
 	if manager.IsEnabled() {
@@ -36,7 +40,7 @@ func TestManager_EnableDisable(t *testing.T) {
 	}
 
 	// Reload and check
-	manager2 := sharing.NewManager()
+	manager2 := sharing.NewManagerWithPaths(paths)
 	if err := manager2.LoadConfig(); err != nil {
 		t.Fatalf("LoadConfig failed: %v", err)
 	}
@@ -54,7 +58,7 @@ func TestManager_EnableDisable(t *testing.T) {
 		t.Fatalf("Disable failed: %v", err)
 	}
 
-	manager3 := sharing.NewManager()
+	manager3 := sharing.NewManagerWithPaths(paths)
 	if err := manager3.LoadConfig(); err != nil {
 		t.Fatalf("LoadConfig failed: %v", err)
 	}
@@ -81,10 +85,9 @@ func TestManager_SymlinkCreation(t *testing.T) {
 		t.Fatalf("failed to write test file: %v", err)
 	}
 
-	os.Setenv("HOME", tmpDir)
-	defer os.Unsetenv("HOME")
+	paths := codex.NewPathsAt(tmpDir)
 
-	manager := sharing.NewManager()
+	manager := sharing.NewManagerWithPaths(paths)
 	if err := manager.Enable(false); err != nil {
 		t.Fatalf("Enable failed: %v", err)
 	}
@@ -105,3 +108,907 @@ func TestManager_SymlinkCreation(t *testing.T) {
 		t.Error("test file should have been migrated to shared location")
 	}
 }
+
+func TestManager_SharedItems(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, ".codex"), 0755); err != nil {
+		t.Fatalf("failed to create ~/.codex: %v", err)
+	}
+	paths := codex.NewPathsAt(tmpDir)
+
+	manager := sharing.NewManagerWithPaths(paths)
+	if target, items := manager.SharedItems("work"); target != "" || items != nil {
+		t.Errorf("expected nothing shared while disabled, got %s %v", target, items)
+	}
+
+	if err := manager.Enable(false); err != nil {
+		t.Fatalf("Enable failed: %v", err)
+	}
+	target, items := manager.SharedItems("work")
+	if target != filepath.Join(tmpDir, "codex-data", "shared") {
+		t.Errorf("unexpected share target %s", target)
+	}
+	if len(items) != len(codex.ShareableItems) {
+		t.Errorf("expected %v, got %v", codex.ShareableItems, items)
+	}
+
+	if err := manager.Enable(true); err != nil {
+		t.Fatalf("Enable failed: %v", err)
+	}
+	if _, items := manager.SharedItems("work"); len(items) != len(codex.ShareableItems)+len(codex.OptionalShareableItems) {
+		t.Errorf("expected settings to be shared too, got %v", items)
+	}
+}
+
+func TestManager_Groups(t *testing.T) {
+	tmpDir := t.TempDir()
+	homeDir := filepath.Join(tmpDir, ".codex")
+	if err := os.MkdirAll(filepath.Join(homeDir, "sessions"), 0755); err != nil {
+		t.Fatalf("failed to create sessions dir: %v", err)
+	}
+	paths := codex.NewPathsAt(tmpDir)
+	groupDir := filepath.Join(paths.GroupsDir, "team")
+
+	manager := sharing.NewManagerWithPaths(paths)
+	if err := manager.Assign("work", "team", ""); err == nil {
+		t.Error("assigning to a missing group should fail")
+	}
+	if err := manager.CreateGroup("team"); err != nil {
+		t.Fatalf("CreateGroup failed: %v", err)
+	}
+	if err := manager.CreateGroup("team"); err == nil {
+		t.Error("creating an existing group should fail")
+	}
+	if err := manager.EnableMode(sharing.ModeGroup, sharing.StrategySymlink, false, "work"); err != nil {
+		t.Fatalf("EnableMode failed: %v", err)
+	}
+	if _, err := os.Readlink(filepath.Join(homeDir, "sessions")); err == nil {
+		t.Error("an account outside every group should not share")
+	}
+
+	if err := manager.Assign("work", "team", "work"); err != nil {
+		t.Fatalf("Assign failed: %v", err)
+	}
+	link, err := os.Readlink(filepath.Join(homeDir, "sessions"))
+	if err != nil || link != filepath.Join(groupDir, "sessions") {
+		t.Errorf("expected sessions linked into the group, got %q (%v)", link, err)
+	}
+	if target, _ := manager.SharedItems("work"); target != groupDir {
+		t.Errorf("unexpected share target %s", target)
+	}
+	if target, items := manager.SharedItems("personal"); target != "" || items != nil {
+		t.Errorf("expected nothing shared for an account outside every group, got %s %v", target, items)
+	}
+
+	reloaded := sharing.NewManagerWithPaths(paths)
+	if err := reloaded.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	groups, err := reloaded.Groups()
+	if err != nil {
+		t.Fatalf("Groups failed: %v", err)
+	}
+	if len(groups) != 1 || len(groups["team"]) != 1 || groups["team"][0] != "work" {
+		t.Errorf("unexpected groups %v", groups)
+	}
+
+	if err := reloaded.DeleteGroup("team"); err == nil {
+		t.Error("deleting a group with accounts should fail")
+	}
+	if err := reloaded.Assign("work", "", "work"); err != nil {
+		t.Fatalf("Assign failed: %v", err)
+	}
+	if info, err := os.Lstat(filepath.Join(homeDir, "sessions")); err != nil || !info.IsDir() {
+		t.Error("sessions should be copied back once the account leaves its group")
+	}
+	if err := reloaded.DeleteGroup("team"); err != nil {
+		t.Fatalf("DeleteGroup failed: %v", err)
+	}
+	if _, err := os.Stat(groupDir); !os.IsNotExist(err) {
+		t.Error("the group directory should be removed")
+	}
+}
+
+func TestManager_Hardlink(t *testing.T) {
+	tmpDir := t.TempDir()
+	homeDir := filepath.Join(tmpDir, ".codex")
+	if err := os.MkdirAll(filepath.Join(homeDir, "sessions"), 0755); err != nil {
+		t.Fatalf("failed to create sessions dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(homeDir, "sessions", "a.jsonl"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	paths := codex.NewPathsAt(tmpDir)
+	sharedSessions := filepath.Join(paths.SharedDir, "sessions")
+
+	manager := sharing.NewManagerWithPaths(paths)
+	if err := manager.EnableMode(sharing.ModeGlobal, sharing.StrategyHardlink, false, "work"); err != nil {
+		t.Fatalf("EnableMode failed: %v", err)
+	}
+	sameFile := func(rel string) bool {
+		t.Helper()
+		live, err := os.Stat(filepath.Join(homeDir, "sessions", rel))
+		if err != nil {
+			return false
+		}
+		shared, err := os.Stat(filepath.Join(sharedSessions, rel))
+		return err == nil && os.SameFile(live, shared)
+	}
+	if info, err := os.Lstat(filepath.Join(homeDir, "sessions")); err != nil || !info.IsDir() {
+		t.Fatal("sessions should stay a real directory")
+	}
+	if !sameFile("a.jsonl") {
+		t.Error("a.jsonl should be hardlinked to the shared copy")
+	}
+
+	// A new file on either side, and a file replaced rather than written
+	// in place, are linked again by Reconcile
+	if err := os.WriteFile(filepath.Join(homeDir, "sessions", "b.jsonl"), []byte("b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sharedSessions, "c.jsonl"), []byte("c"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	replaced := filepath.Join(homeDir, "sessions", "a.jsonl.new")
+	if err := os.WriteFile(replaced, []byte("a2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(replaced, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(replaced, filepath.Join(homeDir, "sessions", "a.jsonl")); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Reconcile("work"); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	for _, rel := range []string{"a.jsonl", "b.jsonl", "c.jsonl"} {
+		if !sameFile(rel) {
+			t.Errorf("%s should be hardlinked after Reconcile", rel)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(sharedSessions, "a.jsonl")); string(data) != "a2" {
+		t.Errorf("the newer a.jsonl should win, got %q", data)
+	}
+
+	if err := manager.Disable(); err != nil {
+		t.Fatalf("Disable failed: %v", err)
+	}
+	if sameFile("a.jsonl") {
+		t.Error("files should get copies of their own once sharing is disabled")
+	}
+	if data, _ := os.ReadFile(filepath.Join(homeDir, "sessions", "c.jsonl")); string(data) != "c" {
+		t.Errorf("expected c.jsonl kept, got %q", data)
+	}
+}
+
+func TestManager_Repair(t *testing.T) {
+	tmpDir := t.TempDir()
+	homeDir := filepath.Join(tmpDir, ".codex")
+	if err := os.MkdirAll(filepath.Join(homeDir, "sessions"), 0755); err != nil {
+		t.Fatalf("failed to create sessions dir: %v", err)
+	}
+	paths := codex.NewPathsAt(tmpDir)
+
+	manager := sharing.NewManagerWithPaths(paths)
+	if _, err := manager.Repair("work"); err == nil {
+		t.Error("repair should fail while sharing is disabled")
+	}
+	if err := manager.Enable(false); err != nil {
+		t.Fatalf("Enable failed: %v", err)
+	}
+
+	// A shared copy deleted behind cxa's back, a settings file linked into
+	// the shared directory without settings being shared, and a settings
+	// file of the user's own
+	sessions := filepath.Join(paths.SharedDir, "sessions")
+	if err := os.RemoveAll(sessions); err != nil {
+		t.Fatal(err)
+	}
+	sharedConfig := filepath.Join(paths.SharedDir, "config.toml")
+	if err := os.WriteFile(sharedConfig, []byte("model = \"o3\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(sharedConfig, filepath.Join(homeDir, "config.toml")); err != nil {
+		t.Fatal(err)
+	}
+	dotfiles := filepath.Join(tmpDir, "settings.json")
+	if err := os.WriteFile(dotfiles, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(dotfiles, filepath.Join(homeDir, "settings.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := manager.Repair("work")
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	changed := make(map[string]sharing.Change)
+	for _, change := range changes {
+		changed[change.Item] = change
+	}
+	if c := changed["sessions"]; c.Before != sharing.ItemBroken || c.After != sharing.ItemShared {
+		t.Errorf("expected sessions repaired, got %+v", c)
+	}
+	if c := changed["config.toml"]; c.After != sharing.ItemLocal {
+		t.Errorf("expected config.toml unshared, got %+v", c)
+	}
+	if _, ok := changed["settings.json"]; ok {
+		t.Error("a symlink of the user's own should be left alone")
+	}
+	if info, err := os.Stat(sessions); err != nil || !info.IsDir() {
+		t.Errorf("expected the shared sessions directory recreated: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(homeDir, "config.toml")); err != nil || string(data) != "model = \"o3\"\n" {
+		t.Errorf("expected config.toml copied back, got %q (%v)", data, err)
+	}
+
+	if changes, err := manager.Repair("work"); err != nil || len(changes) != 0 {
+		t.Errorf("expected a second repair to change nothing, got %v (%v)", changes, err)
+	}
+}
+
+func TestManager_Selected(t *testing.T) {
+	tmpDir := t.TempDir()
+	homeDir := filepath.Join(tmpDir, ".codex")
+	if err := os.MkdirAll(filepath.Join(homeDir, "sessions"), 0755); err != nil {
+		t.Fatalf("failed to create sessions dir: %v", err)
+	}
+	paths := codex.NewPathsAt(tmpDir)
+	sessions := filepath.Join(homeDir, "sessions")
+
+	manager := sharing.NewManagerWithPaths(paths)
+	if err := manager.Select("work-a", true, "work-a"); err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	if _, err := os.Readlink(sessions); err == nil {
+		t.Error("choosing an account should not share before selected mode is enabled")
+	}
+	if err := manager.EnableMode(sharing.ModeSelected, sharing.StrategySymlink, false, "work-a"); err != nil {
+		t.Fatalf("EnableMode failed: %v", err)
+	}
+	link, err := os.Readlink(sessions)
+	if err != nil || link != filepath.Join(paths.SharedDir, "sessions") {
+		t.Errorf("expected sessions linked into the shared directory, got %q (%v)", link, err)
+	}
+	if target, items := manager.SharedItems("personal"); target != "" || items != nil {
+		t.Errorf("expected nothing shared for an account not chosen, got %s %v", target, items)
+	}
+
+	if err := manager.Select("work-b", true, "work-a"); err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	if err := manager.Select("work-a", false, "work-a"); err != nil {
+		t.Fatalf("Select failed: %v", err)
+	}
+	if _, err := os.Readlink(sessions); err == nil {
+		t.Error("an account taken out should stop sharing at once when current")
+	}
+
+	reloaded := sharing.NewManagerWithPaths(paths)
+	if err := reloaded.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if accounts := reloaded.Selected(); len(accounts) != 1 || accounts[0] != "work-b" {
+		t.Errorf("unexpected chosen accounts %v", accounts)
+	}
+	if !reloaded.IsSelected("work-b") || reloaded.GetMode() != sharing.ModeSelected {
+		t.Errorf("unexpected reloaded configuration: mode %s", reloaded.GetMode())
+	}
+}
+
+func TestManager_CustomItems(t *testing.T) {
+	tmpDir := t.TempDir()
+	homeDir := filepath.Join(tmpDir, ".codex")
+	prompts := filepath.Join(homeDir, "prompts")
+	if err := os.MkdirAll(prompts, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(prompts, "review.md"), []byte("review"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	paths := codex.NewPathsAt(tmpDir)
+
+	manager := sharing.NewManagerWithPaths(paths)
+	if err := manager.Enable(false); err != nil {
+		t.Fatalf("Enable failed: %v", err)
+	}
+
+	for _, path := range []string{"auth.json", "../outside", "sessions", "sessions/2025", ".", filepath.Join(tmpDir, "elsewhere")} {
+		if _, err := manager.ValidateItem(path); err == nil {
+			t.Errorf("expected %s to be refused", path)
+		}
+	}
+
+	item, err := manager.AddItem(filepath.Join(homeDir, "prompts"), "work")
+	if err != nil {
+		t.Fatalf("AddItem failed: %v", err)
+	}
+	if item != "prompts" {
+		t.Errorf("expected the item stored relative to ~/.codex, got %s", item)
+	}
+	if _, err := manager.AddItem("prompts/review.md", "work"); err == nil {
+		t.Error("an item inside a shared one should be refused")
+	}
+	link, err := os.Readlink(prompts)
+	if err != nil || link != filepath.Join(paths.SharedDir, "prompts") {
+		t.Errorf("expected prompts linked into the shared directory, got %q (%v)", link, err)
+	}
+	if _, err := os.Stat(filepath.Join(paths.SharedDir, "prompts", "review.md")); err != nil {
+		t.Errorf("expected the prompts moved to the shared directory: %v", err)
+	}
+
+	reloaded := sharing.NewManagerWithPaths(paths)
+	if err := reloaded.LoadConfig(); err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if items := reloaded.CustomItems(); len(items) != 1 || items[0] != "prompts" {
+		t.Errorf("unexpected custom items %v", items)
+	}
+
+	if _, err := reloaded.RemoveItem("sessions"); err == nil {
+		t.Error("a built-in item should not be removable")
+	}
+	if _, err := reloaded.RemoveItem("prompts"); err != nil {
+		t.Fatalf("RemoveItem failed: %v", err)
+	}
+	if info, err := os.Lstat(prompts); err != nil || !info.IsDir() {
+		t.Errorf("expected prompts copied back as a directory: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(prompts, "review.md")); err != nil {
+		t.Errorf("expected the prompts kept: %v", err)
+	}
+}
+
+func TestManager_Junction(t *testing.T) {
+	tmpDir := t.TempDir()
+	homeDir := filepath.Join(tmpDir, ".codex")
+	if err := os.MkdirAll(filepath.Join(homeDir, "sessions"), 0755); err != nil {
+		t.Fatalf("failed to create sessions dir: %v", err)
+	}
+	history := filepath.Join(homeDir, "history.jsonl")
+	if err := os.WriteFile(history, []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	paths := codex.NewPathsAt(tmpDir)
+
+	manager := sharing.NewManagerWithPaths(paths)
+	if err := manager.EnableMode(sharing.ModeGlobal, sharing.StrategyJunction, false, "work"); err != nil {
+		t.Fatalf("EnableMode failed: %v", err)
+	}
+
+	// Directories link to their shared copy, files are hardlinks of it
+	if link, err := os.Readlink(filepath.Join(homeDir, "sessions")); err != nil || link != filepath.Join(paths.SharedDir, "sessions") {
+		t.Errorf("expected sessions linked into the shared directory, got %q (%v)", link, err)
+	}
+	live, err := os.Lstat(history)
+	if err != nil || !live.Mode().IsRegular() {
+		t.Fatalf("expected history.jsonl to stay a file: %v", err)
+	}
+	shared, err := os.Stat(filepath.Join(paths.SharedDir, "history.jsonl"))
+	if err != nil || !os.SameFile(live, shared) {
+		t.Errorf("expected history.jsonl hardlinked to the shared copy: %v", err)
+	}
+
+	_, _, items := manager.Status("work")
+	for _, item := range items {
+		if !item.Healthy() {
+			t.Errorf("expected %s healthy, got %+v", item.Item, item)
+		}
+	}
+
+	if err := manager.Disable(); err != nil {
+		t.Fatalf("Disable failed: %v", err)
+	}
+	if info, err := os.Lstat(filepath.Join(homeDir, "sessions")); err != nil || !info.IsDir() {
+		t.Errorf("expected sessions copied back as a directory: %v", err)
+	}
+	live, _ = os.Stat(history)
+	shared, _ = os.Stat(filepath.Join(paths.SharedDir, "history.jsonl"))
+	if live == nil || shared == nil || os.SameFile(live, shared) {
+		t.Error("expected history.jsonl to get a copy of its own")
+	}
+}
+
+func TestManager_Conflicts(t *testing.T) {
+	setup := func(t *testing.T) (*codex.Paths, string) {
+		tmpDir := t.TempDir()
+		paths := codex.NewPathsAt(tmpDir)
+		homeDir := filepath.Join(tmpDir, ".codex")
+		for dir, files := range map[string]map[string]string{
+			filepath.Join(homeDir, "sessions"):         {"a.jsonl": "a\n", "local.jsonl": "l\n"},
+			filepath.Join(paths.SharedDir, "sessions"): {"a.jsonl": "a\n"},
+		} {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			for name, data := range files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if err := os.WriteFile(filepath.Join(homeDir, "history.jsonl"), []byte("1\n3\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(paths.SharedDir, "history.jsonl"), []byte("1\n2\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return paths, homeDir
+	}
+
+	t.Run("moved aside without a resolver", func(t *testing.T) {
+		paths, homeDir := setup(t)
+		manager := sharing.NewManagerWithPaths(paths)
+		if err := manager.Enable(false); err != nil {
+			t.Fatalf("Enable failed: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(paths.SharedDir, "sessions", "local.jsonl")); err == nil {
+			t.Error("the local copy should not be merged without a choice")
+		}
+		aside, _ := filepath.Glob(filepath.Join(paths.DataDir, "conflicts", "*", "sessions", "local.jsonl"))
+		if len(aside) != 1 {
+			t.Errorf("expected the local sessions moved aside, found %v", aside)
+		}
+		if link, err := os.Readlink(filepath.Join(homeDir, "sessions")); err != nil || link != filepath.Join(paths.SharedDir, "sessions") {
+			t.Errorf("expected sessions shared, got %q (%v)", link, err)
+		}
+	})
+
+	t.Run("merged when chosen", func(t *testing.T) {
+		paths, _ := setup(t)
+		manager := sharing.NewManagerWithPaths(paths)
+		var asked []sharing.Conflict
+		manager.SetResolver(func(c sharing.Conflict) (sharing.Resolution, error) {
+			asked = append(asked, c)
+			return sharing.ResolveMerge, nil
+		})
+		if err := manager.Enable(false); err != nil {
+			t.Fatalf("Enable failed: %v", err)
+		}
+		if len(asked) != 2 || asked[0].Item != "sessions" || asked[0].OnlyLocal != 1 || asked[0].Differ != 0 {
+			t.Errorf("unexpected conflicts %+v", asked)
+		}
+		if _, err := os.Stat(filepath.Join(paths.SharedDir, "sessions", "local.jsonl")); err != nil {
+			t.Errorf("expected the local session merged: %v", err)
+		}
+		if data, err := os.ReadFile(filepath.Join(paths.SharedDir, "history.jsonl")); err != nil || string(data) != "1\n2\n3\n" {
+			t.Errorf("expected the history lines merged, got %q (%v)", data, err)
+		}
+	})
+
+	t.Run("shared kept when chosen", func(t *testing.T) {
+		paths, homeDir := setup(t)
+		manager := sharing.NewManagerWithPaths(paths)
+		manager.SetResolver(func(c sharing.Conflict) (sharing.Resolution, error) {
+			return sharing.ResolveKeepShared, nil
+		})
+		if err := manager.Enable(false); err != nil {
+			t.Fatalf("Enable failed: %v", err)
+		}
+		if data, err := os.ReadFile(filepath.Join(homeDir, "history.jsonl")); err != nil || string(data) != "1\n2\n" {
+			t.Errorf("expected the shared history kept, got %q (%v)", data, err)
+		}
+	})
+
+	t.Run("left unshared when cancelled", func(t *testing.T) {
+		paths, homeDir := setup(t)
+		manager := sharing.NewManagerWithPaths(paths)
+		asked := map[string]int{}
+		manager.SetResolver(func(c sharing.Conflict) (sharing.Resolution, error) {
+			asked[c.Item]++
+			if c.Item == "sessions" {
+				return "", sharing.ErrLeftUnshared
+			}
+			return sharing.ResolveMerge, nil
+		})
+		if err := manager.Enable(false); err != nil {
+			t.Fatalf("Enable failed: %v", err)
+		}
+		if info, err := os.Lstat(filepath.Join(homeDir, "sessions")); err != nil || !info.IsDir() {
+			t.Errorf("expected sessions left as they were: %v", err)
+		}
+		if _, err := os.Readlink(filepath.Join(homeDir, "history.jsonl")); err != nil {
+			t.Errorf("expected the items after it still shared: %v", err)
+		}
+		if err := manager.Verify(""); err != nil {
+			t.Errorf("an item left unshared by choice should verify, got %v", err)
+		}
+		if _, err := manager.Repair(""); err != nil {
+			t.Fatalf("Repair failed: %v", err)
+		}
+		if asked["sessions"] != 1 || asked["history.jsonl"] != 1 {
+			t.Errorf("expected each conflict asked about once, got %v", asked)
+		}
+	})
+}
+
+func TestManager_Plan(t *testing.T) {
+	tmpDir := t.TempDir()
+	homeDir := filepath.Join(tmpDir, ".codex")
+	if err := os.MkdirAll(filepath.Join(homeDir, "sessions"), 0755); err != nil {
+		t.Fatalf("failed to create sessions dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(homeDir, "sessions", "a.jsonl"), []byte("12345"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	paths := codex.NewPathsAt(tmpDir)
+	manager := sharing.NewManagerWithPaths(paths)
+
+	plan, err := manager.PlanEnable(sharing.ModeGlobal, sharing.StrategySymlink, false, "work", nil)
+	if err != nil {
+		t.Fatalf("PlanEnable failed: %v", err)
+	}
+	ops := make(map[string][]sharing.Op)
+	for _, action := range plan.Actions {
+		ops[action.Item] = append(ops[action.Item], action.Op)
+	}
+	if got := ops["sessions"]; len(got) != 2 || got[0] != sharing.OpMove || got[1] != sharing.OpLink {
+		t.Errorf("expected sessions moved and linked, got %v", got)
+	}
+	if got := ops["history.jsonl"]; len(got) != 2 || got[0] != sharing.OpCreate || got[1] != sharing.OpLink {
+		t.Errorf("expected history.jsonl created and linked, got %v", got)
+	}
+	if plan.Bytes != 5 {
+		t.Errorf("expected 5 bytes moved, got %d", plan.Bytes)
+	}
+	if _, err := os.Readlink(filepath.Join(homeDir, "sessions")); err == nil {
+		t.Error("planning should not change anything")
+	}
+
+	if err := manager.Enable(false); err != nil {
+		t.Fatalf("Enable failed: %v", err)
+	}
+	plan, err = manager.PlanEnable(sharing.ModeGlobal, sharing.StrategySymlink, false, "work", nil)
+	if err != nil || len(plan.Actions) != 0 {
+		t.Errorf("expected nothing left to do once enabled, got %+v (%v)", plan, err)
+	}
+
+	plan, err = manager.PlanDisable()
+	if err != nil {
+		t.Fatalf("PlanDisable failed: %v", err)
+	}
+	if len(plan.Actions) != 4 || plan.Actions[0].Op != sharing.OpCopyBack || plan.Bytes != 5 {
+		t.Errorf("expected every shared item copied back, got %+v", plan)
+	}
+}
+
+func TestManager_SetAside(t *testing.T) {
+	tmpDir := t.TempDir()
+	homeDir := filepath.Join(tmpDir, ".codex")
+	if err := os.MkdirAll(filepath.Join(homeDir, "sessions"), 0755); err != nil {
+		t.Fatalf("failed to create sessions dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(homeDir, "sessions", "a.jsonl"), []byte("local"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(homeDir, "config.toml"), []byte("model = \"o3\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	paths := codex.NewPathsAt(tmpDir)
+	manager := sharing.NewManagerWithPaths(paths)
+
+	backup, err := manager.SetAside()
+	if err != nil {
+		t.Fatalf("SetAside failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(backup, "sessions", "a.jsonl")); err != nil || string(data) != "local" {
+		t.Errorf("expected the sessions backed up, got %q (%v)", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(homeDir, "config.toml")); err != nil {
+		t.Errorf("expected settings left alone: %v", err)
+	}
+
+	if err := manager.Enable(false); err != nil {
+		t.Fatalf("Enable failed: %v", err)
+	}
+	entries, err := os.ReadDir(filepath.Join(paths.SharedDir, "sessions"))
+	if err != nil || len(entries) != 0 {
+		t.Errorf("expected sharing to start without the local sessions, got %v (%v)", entries, err)
+	}
+
+	if backup, err := manager.SetAside(); err != nil || backup != "" {
+		t.Errorf("expected nothing to set aside once shared, got %q (%v)", backup, err)
+	}
+}
+
+func TestManager_ReadOnly(t *testing.T) {
+	tmpDir := t.TempDir()
+	homeDir := filepath.Join(tmpDir, ".codex")
+	paths := codex.NewPathsAt(tmpDir)
+	write := func(path, data string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(paths.SharedDir, "sessions", "a.jsonl"), "shared\n")
+	write(filepath.Join(paths.SharedDir, "history.jsonl"), "h1\n")
+	write(filepath.Join(homeDir, "sessions", "b.jsonl"), "mine\n")
+	write(filepath.Join(homeDir, "history.jsonl"), "h2\n")
+
+	manager := sharing.NewManagerWithPaths(paths)
+	if err := manager.EnableMode(sharing.ModeGlobal, sharing.StrategyReadOnly, false, "work"); err != nil {
+		t.Fatalf("EnableMode failed: %v", err)
+	}
+
+	// The shared files are attached, and nothing flows back
+	if info, err := os.Lstat(filepath.Join(homeDir, "sessions")); err != nil || !info.IsDir() {
+		t.Fatalf("expected sessions to stay a real directory: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(homeDir, "sessions", "a.jsonl")); err != nil || string(data) != "shared\n" {
+		t.Errorf("expected the shared session attached, got %q (%v)", data, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(homeDir, "history.jsonl")); string(data) != "h2\nh1\n" {
+		t.Errorf("expected the shared history merged in, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(paths.SharedDir, "sessions", "b.jsonl")); !os.IsNotExist(err) {
+		t.Error("local sessions should not be shared before they are promoted")
+	}
+
+	files, err := manager.Promote("work", nil, true)
+	if err != nil {
+		t.Fatalf("Promote failed: %v", err)
+	}
+	if len(files) != 2 || files[0] != "sessions/b.jsonl" || files[1] != "history.jsonl" {
+		t.Errorf("expected the new session and history lines, got %v", files)
+	}
+	if _, err := os.Stat(filepath.Join(paths.SharedDir, "sessions", "b.jsonl")); !os.IsNotExist(err) {
+		t.Error("a dry run should not promote anything")
+	}
+
+	if _, err := manager.Promote("work", []string{"sessions"}, false); err != nil {
+		t.Fatalf("Promote failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(paths.SharedDir, "sessions", "b.jsonl")); err != nil || string(data) != "mine\n" {
+		t.Errorf("expected the session promoted, got %q (%v)", data, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(paths.SharedDir, "history.jsonl")); string(data) != "h1\n" {
+		t.Errorf("expected history left alone, got %q", data)
+	}
+	if _, err := manager.Promote("work", []string{"auth.json"}, false); err == nil {
+		t.Error("expected an item that is not shared to be refused")
+	}
+
+	files, _ = manager.Promote("work", nil, false)
+	if data, _ := os.ReadFile(filepath.Join(paths.SharedDir, "history.jsonl")); len(files) != 1 || string(data) != "h1\nh2\n" {
+		t.Errorf("expected the history lines promoted, got %v and %q", files, data)
+	}
+}
+
+func TestManager_Hooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook scripts are shell scripts here")
+	}
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, ".codex"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	paths := codex.NewPathsAt(tmpDir)
+	log := filepath.Join(tmpDir, "hooks.log")
+
+	if err := os.MkdirAll(paths.HooksDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	script := "#!/bin/sh\necho \"script $CXA_HOOK $CXA_ACCOUNT $CXA_SHARE_MODE\" >> " + log + "\n"
+	if err := os.WriteFile(filepath.Join(paths.HooksDir(), "share-enable.sh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Hooks: map[string]string{config.HookShareDisable: "echo \"command $CXA_HOOK\" >> " + log}}
+	if err := cfg.Save(paths.ConfigFile()); err != nil {
+		t.Fatal(err)
+	}
+
+	manager := sharing.NewManagerWithPaths(paths)
+	if err := manager.EnableMode(sharing.ModeGlobal, sharing.StrategySymlink, false, "work"); err != nil {
+		t.Fatalf("EnableMode failed: %v", err)
+	}
+	if err := manager.Disable(); err != nil {
+		t.Fatalf("Disable failed: %v", err)
+	}
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatalf("expected the hooks to run: %v", err)
+	}
+	if want := "script share-enable work global\ncommand share-disable\n"; string(data) != want {
+		t.Errorf("expected hooks log %q, got %q", want, data)
+	}
+}
+
+func TestManager_Verify(t *testing.T) {
+	tmpDir := t.TempDir()
+	homeDir := filepath.Join(tmpDir, ".codex")
+	if err := os.MkdirAll(homeDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	paths := codex.NewPathsAt(tmpDir)
+	manager := sharing.NewManagerWithPaths(paths)
+
+	if err := manager.Enable(false); err != nil {
+		t.Fatalf("Enable failed: %v", err)
+	}
+	if err := manager.Preflight("work"); err != nil {
+		t.Errorf("expected nothing in the way of switching: %v", err)
+	}
+	if err := manager.Verify("work"); err != nil {
+		t.Errorf("expected sharing intact: %v", err)
+	}
+
+	// A shared copy removed from under its link
+	if err := os.RemoveAll(filepath.Join(paths.SharedDir, "sessions")); err != nil {
+		t.Fatal(err)
+	}
+	err := manager.Verify("work")
+	if err == nil || !strings.Contains(err.Error(), "sessions links to") {
+		t.Fatalf("expected the missing shared sessions reported, got %v", err)
+	}
+	if _, err := manager.Repair("work"); err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	if err := manager.Verify("work"); err != nil {
+		t.Errorf("expected sharing intact once repaired: %v", err)
+	}
+
+	// A shared directory that cannot be created
+	if err := os.RemoveAll(paths.SharedDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(paths.SharedDir, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Preflight("work"); err == nil {
+		t.Error("expected an unwritable shared directory to stop the switch")
+	}
+}
+
+func TestManager_SessionFilters(t *testing.T) {
+	tmpDir := t.TempDir()
+	homeDir := filepath.Join(tmpDir, ".codex")
+	paths := codex.NewPathsAt(tmpDir)
+	day := filepath.Join("sessions", "2026", "01", "02")
+	session := func(dir, name, meta string) string {
+		t.Helper()
+		path := filepath.Join(dir, day, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(meta+"\n{\"type\":\"response_item\"}\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	work := session(homeDir, "work.jsonl", `{"type":"session_meta","payload":{"cwd":"/src/work/acme","git":{"repository_url":"git@github.com:acme/api.git"}}}`)
+	session(homeDir, "blog.jsonl", `{"type":"session_meta","payload":{"cwd":"/src/me/blog"}}`)
+	session(homeDir, "old.jsonl", `{"id":"1","timestamp":"2025-01-01"}`)
+
+	manager := sharing.NewManagerWithPaths(paths)
+	if _, err := sharing.ParseSessionFilter("cwd=", ""); err == nil {
+		t.Error("expected an empty pattern to be refused")
+	}
+	rule, err := sharing.ParseSessionFilter("/src/work", "")
+	if err != nil || rule != "cwd=/src/work" {
+		t.Fatalf("expected a cwd rule, got %q (%v)", rule, err)
+	}
+	if err := manager.AddSessionFilter(rule, "work"); err != nil {
+		t.Fatalf("AddSessionFilter failed: %v", err)
+	}
+	if err := manager.Enable(false); err != nil {
+		t.Fatalf("Enable failed: %v", err)
+	}
+
+	shared := filepath.Join(paths.SharedDir, day)
+	info, err := os.Lstat(filepath.Join(homeDir, "sessions"))
+	if err != nil || !info.IsDir() {
+		t.Fatalf("expected sessions to become a real directory: %v", err)
+	}
+	liveInfo, _ := os.Stat(work)
+	if sharedInfo, err := os.Stat(filepath.Join(shared, "work.jsonl")); err != nil || !os.SameFile(liveInfo, sharedInfo) {
+		t.Errorf("expected the work session shared: %v", err)
+	}
+	for _, name := range []string{"blog.jsonl", "old.jsonl"} {
+		if _, err := os.Stat(filepath.Join(shared, name)); !os.IsNotExist(err) {
+			t.Errorf("expected %s kept local", name)
+		}
+		if _, err := os.Stat(filepath.Join(homeDir, day, name)); err != nil {
+			t.Errorf("expected %s still in ~/.codex: %v", name, err)
+		}
+	}
+	if err := manager.Verify("work"); err != nil {
+		t.Errorf("expected filtered sharing to be intact: %v", err)
+	}
+
+	// Sessions shared by other accounts still come in, and metadata
+	// rules match on any key
+	session(paths.SharedDir, "other.jsonl", `{"type":"session_meta","payload":{"cwd":"/elsewhere"}}`)
+	repo, _ := sharing.ParseSessionFilter("git.repository_url=*:acme/*", "")
+	session(homeDir, "api.jsonl", `{"type":"session_meta","payload":{"cwd":"/tmp","git":{"repository_url":"git@github.com:acme/web.git"}}}`)
+	if err := manager.AddSessionFilter(repo, "work"); err != nil {
+		t.Fatalf("AddSessionFilter failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(homeDir, day, "other.jsonl")); err != nil {
+		t.Errorf("expected shared sessions linked in: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(shared, "api.jsonl")); err != nil {
+		t.Errorf("expected the session matching the repository shared: %v", err)
+	}
+
+	if err := manager.RemoveSessionFilter("cwd=/nowhere"); err == nil {
+		t.Error("expected removing an unknown filter to fail")
+	}
+	if err := manager.RemoveSessionFilter(repo); err != nil {
+		t.Fatalf("RemoveSessionFilter failed: %v", err)
+	}
+	if got := manager.SessionFilters(); len(got) != 1 || got[0] != rule {
+		t.Errorf("expected one filter left, got %v", got)
+	}
+}
+
+func TestManager_HistoryProvenance(t *testing.T) {
+	tmpDir := t.TempDir()
+	homeDir := filepath.Join(tmpDir, ".codex")
+	if err := os.MkdirAll(homeDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	paths := codex.NewPathsAt(tmpDir)
+	history := filepath.Join(homeDir, "history.jsonl")
+	if err := os.WriteFile(history, []byte(`{"session_id":"s1","ts":100,"text":"first"}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	manager := sharing.NewManagerWithPaths(paths)
+	if err := manager.EnableMode(sharing.ModeGlobal, sharing.StrategySymlink, false, "work"); err != nil {
+		t.Fatalf("EnableMode failed: %v", err)
+	}
+
+	// Written through the link while personal is in ~/.codex, the last
+	// line still being written
+	f, err := os.OpenFile(history, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"session_id":"s2","ts":200,"text":"second"}` + "\n")
+	f.WriteString(`{"session_id":"s1","ts":300,"text":"more"}` + "\n")
+	f.WriteString(`{"session_id":"s3",`)
+	f.Close()
+	if err := manager.Reconcile("personal"); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	sessions, err := manager.HistorySessions("work")
+	if err != nil {
+		t.Fatalf("HistorySessions failed: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 complete sessions, got %+v", sessions)
+	}
+	if s := sessions[0]; s.ID != "s1" || s.Account != "work" || s.Entries != 2 || s.Text != "first" || s.Last.Unix() != 300 {
+		t.Errorf("expected s1 from work, most recent, got %+v", s)
+	}
+	if s := sessions[1]; s.ID != "s2" || s.Account != "personal" {
+		t.Errorf("expected s2 from personal, got %+v", s)
+	}
+	if data, err := os.ReadFile(history); err != nil || strings.Contains(string(data), "personal") {
+		t.Errorf("expected history.jsonl left as Codex wrote it: %v", err)
+	}
+
+	// Finished once work is back, the last line is work's
+	f, err = os.OpenFile(history, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`"ts":400,"text":"third"}` + "\n")
+	f.Close()
+	sessions, err = manager.HistorySessions("work")
+	if err != nil {
+		t.Fatalf("HistorySessions failed: %v", err)
+	}
+	if len(sessions) != 3 || sessions[0].ID != "s3" || sessions[0].Account != "work" {
+		t.Errorf("expected s3 from work, got %+v", sessions)
+	}
+}
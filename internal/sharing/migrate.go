@@ -0,0 +1,152 @@
+package sharing
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/delhombre/cxa/internal/fscopy"
+	"github.com/delhombre/cxa/pkg/codex"
+)
+
+// progressInterval is how often a move reports its progress at most.
+const progressInterval = 100 * time.Millisecond
+
+// moveSuffix marks the copy of an item being moved to another filesystem,
+// renamed into place once complete.
+const moveSuffix = ".cxa-move"
+
+// Progress is how far moving an item to its shared location has copied.
+type Progress struct {
+	Item       string `json:"item"`
+	Bytes      int64  `json:"bytes"`
+	TotalBytes int64  `json:"total_bytes"`
+}
+
+// Percent returns how much of the move is done, from 0 to 100.
+func (p Progress) Percent() int {
+	if p.TotalBytes == 0 {
+		return 100
+	}
+	return int(p.Bytes * 100 / p.TotalBytes)
+}
+
+// SetProgress makes moves of local data to the shared location call fn as
+// they copy, at most every progressInterval. Moves within a filesystem are
+// renames and never report. nil stops reporting.
+func (m *Manager) SetProgress(fn func(Progress)) {
+	m.progress = fn
+}
+
+// move moves the local copy of item at src to its shared location at
+// dest. Where the two are on different filesystems it is copied, with
+// progress reported, and then removed.
+func (m *Manager) move(item, src, dest string) error {
+	err := os.Rename(src, dest)
+	var linkErr *os.LinkError
+	if !errors.As(err, &linkErr) {
+		return err
+	}
+
+	total, err := treeSize(src)
+	if err != nil {
+		return err
+	}
+	counter := &byteCounter{fn: m.progress, p: Progress{Item: item, TotalBytes: total}, last: time.Now()}
+	wrap := func(w io.Writer) io.Writer {
+		return &countingWriter{w: w, counter: counter}
+	}
+
+	staging := dest + moveSuffix
+	os.RemoveAll(staging)
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		err = fscopy.Tree(src, staging, fscopy.Options{Root: dest, Wrap: wrap})
+	} else {
+		err = fscopy.File(src, staging, 0, wrap)
+	}
+	if err != nil {
+		os.RemoveAll(staging)
+		return err
+	}
+	if err := os.Rename(staging, dest); err != nil {
+		os.RemoveAll(staging)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// byteCounter adds up the bytes a move has copied and reports them.
+type byteCounter struct {
+	fn func(Progress)
+
+	mu   sync.Mutex
+	p    Progress
+	last time.Time
+}
+
+func (c *byteCounter) add(n int) {
+	if c.fn == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.p.Bytes += int64(n)
+	if time.Since(c.last) < progressInterval {
+		return
+	}
+	c.last = time.Now()
+	c.fn(c.p)
+}
+
+// countingWriter counts what is written through it.
+type countingWriter struct {
+	w       io.Writer
+	counter *byteCounter
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.counter.add(n)
+	return n, err
+}
+
+// SetAside moves the local copies in ~/.codex of the sessions, history,
+// and items added with AddItem out of the way, into a directory named for
+// the time under backups/ in the data directory, so that sharing starts
+// from what is already shared, or from nothing, rather than from them.
+// Settings, and items already linked, are left alone. It returns the
+// directory, or "" if there was nothing to move.
+func (m *Manager) SetAside() (string, error) {
+	backup := filepath.Join(m.paths.DataDir, "backups", time.Now().Format("20060102-150405"))
+	moved := false
+	for _, item := range append(slices.Clone(codex.ShareableItems), m.config.Items...) {
+		src := filepath.Join(m.paths.Home, item)
+		info, err := os.Lstat(src)
+		if os.IsNotExist(err) || (err == nil && info.Mode()&(os.ModeSymlink|os.ModeIrregular) != 0) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		dest := filepath.Join(backup, item)
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return "", err
+		}
+		if err := m.move(item, src, dest); err != nil {
+			return "", err
+		}
+		moved = true
+	}
+	if !moved {
+		return "", nil
+	}
+	return backup, nil
+}
@@ -0,0 +1,340 @@
+package sharing
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Op is what an Action does to an item.
+type Op string
+
+const (
+	// OpMove moves the local copy of an item to the shared location.
+	OpMove Op = "move"
+	// OpCreate creates an empty shared copy of an item.
+	OpCreate Op = "create"
+	// OpLink links an item in ~/.codex to its shared copy.
+	OpLink Op = "link"
+	// OpRelink replaces a link to somewhere else, or to nothing.
+	OpRelink Op = "relink"
+	// OpDiscard removes a local copy holding nothing the shared copy
+	// lacks.
+	OpDiscard Op = "discard"
+	// OpConflict settles a local copy that differs from the shared one,
+	// asking how if it can, or else moving it aside.
+	OpConflict Op = "conflict"
+	// OpAttach copies the shared files an item in ~/.codex lacks into it.
+	OpAttach Op = "attach"
+	// OpLayer writes config.toml from the shared one and the overrides.
+	OpLayer Op = "layer"
+	// OpCopyBack replaces a link with a copy of what it points to.
+	OpCopyBack Op = "copy-back"
+	// OpUnlink gives hardlinked files in ~/.codex copies of their own.
+	OpUnlink Op = "unlink"
+	// OpRemove removes a link to nothing.
+	OpRemove Op = "remove"
+)
+
+// Action is one change enabling or disabling sharing would make.
+type Action struct {
+	Item string `json:"item"`
+	Op   Op     `json:"op"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+
+	// Bytes is how much data the action moves or copies.
+	Bytes int64 `json:"bytes"`
+}
+
+// Plan is what enabling or disabling sharing would do, in order.
+type Plan struct {
+	Actions []Action `json:"actions"`
+	Bytes   int64    `json:"bytes"`
+}
+
+func (p *Plan) add(a Action) {
+	p.Actions = append(p.Actions, a)
+	p.Bytes += a.Bytes
+}
+
+// PlanEnable returns what EnableMode would do to ~/.codex, holding account,
+// without changing anything. In selected mode, the accounts in selected
+// count as sharing too.
+func (m *Manager) PlanEnable(mode Mode, strategy Strategy, includeSettings bool, account string, selected []string) (*Plan, error) {
+	plan := &Plan{Actions: []Action{}}
+	if m.IsEnabled() && (m.config.Mode != mode || m.Strategy() != strategy) {
+		if err := m.planRemove(plan); err != nil {
+			return nil, err
+		}
+	}
+
+	config := *m.config
+	config.Mode = mode
+	config.Strategy = strategy
+	config.IncludeSettings = includeSettings
+	config.Accounts = append(slices.Clone(config.Accounts), selected...)
+	next := &Manager{paths: m.paths, config: &config, warnings: m.warnings}
+
+	targetDir, items := next.SharedItems(account)
+	if targetDir == "" {
+		return plan, nil
+	}
+	for _, item := range items {
+		var err error
+		switch {
+		case item == configItem && next.layered():
+			err = next.planLayered(plan, targetDir)
+		case strategy == StrategyReadOnly:
+			err = next.planAttach(plan, item, targetDir)
+		case strategy == StrategyHardlink, item == sessionsItem && next.filtered():
+			err = next.planLinked(plan, item, targetDir)
+		case strategy == StrategyJunction && !isDirItem(filepath.Join(m.paths.Home, item), filepath.Join(targetDir, item)):
+			err = next.planLinked(plan, item, targetDir)
+		default:
+			err = next.planLink(plan, item, targetDir)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// PlanDisable returns what Disable would do to ~/.codex without changing
+// anything.
+func (m *Manager) PlanDisable() (*Plan, error) {
+	plan := &Plan{Actions: []Action{}}
+	if !m.IsEnabled() {
+		return plan, nil
+	}
+	if err := m.planRemove(plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// planLink plans what setupLink does.
+func (m *Manager) planLink(plan *Plan, item, targetDir string) error {
+	src := filepath.Join(m.paths.Home, item)
+	dest := filepath.Join(targetDir, item)
+
+	_, destErr := os.Stat(dest)
+	destExists := destErr == nil
+	if link, err := os.Readlink(src); err == nil {
+		switch {
+		case link == dest && destExists:
+			return nil
+		case !destExists:
+			plan.add(Action{Item: item, Op: OpCreate, To: dest})
+		}
+		plan.add(Action{Item: item, Op: OpRelink, From: link, To: dest})
+		return nil
+	}
+
+	if _, err := os.Lstat(src); err == nil {
+		size, err := treeSize(src)
+		if err != nil {
+			return err
+		}
+		if !destExists {
+			plan.add(Action{Item: item, Op: OpMove, From: src, To: dest, Bytes: size})
+		} else {
+			c, err := diverge(src, dest)
+			if err != nil {
+				return err
+			}
+			if c.OnlyLocal == 0 && c.Differ == 0 {
+				plan.add(Action{Item: item, Op: OpDiscard, From: src})
+			} else {
+				plan.add(Action{Item: item, Op: OpConflict, From: src, To: dest, Bytes: size})
+			}
+		}
+	} else if !destExists {
+		plan.add(Action{Item: item, Op: OpCreate, To: dest})
+	}
+	plan.add(Action{Item: item, Op: OpLink, From: src, To: dest})
+	return nil
+}
+
+// planLinked plans what setupLinked does: the files of item linked with
+// their shared copies, or copied where no link can be made.
+func (m *Manager) planLinked(plan *Plan, item, targetDir string) error {
+	src := filepath.Join(m.paths.Home, item)
+	dest := filepath.Join(targetDir, item)
+
+	_, srcErr := os.Lstat(src)
+	_, destErr := os.Lstat(dest)
+	if os.IsNotExist(srcErr) && os.IsNotExist(destErr) {
+		plan.add(Action{Item: item, Op: OpCreate, To: dest})
+	}
+	if link, err := os.Readlink(src); err == nil {
+		plan.add(Action{Item: item, Op: OpRelink, From: link, To: dest})
+		return nil
+	}
+
+	// Only files not yet linked with their shared copy change
+	var size int64
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if other, err := os.Stat(filepath.Join(dest, rel)); err != nil || !os.SameFile(info, other) {
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if size > 0 || (destErr == nil && os.IsNotExist(srcErr)) {
+		plan.add(Action{Item: item, Op: OpLink, From: src, To: dest, Bytes: size})
+	}
+	return nil
+}
+
+// planAttach plans what setupReadOnly does.
+func (m *Manager) planAttach(plan *Plan, item, targetDir string) error {
+	src := filepath.Join(m.paths.Home, item)
+	dest := filepath.Join(targetDir, item)
+
+	if _, err := os.Lstat(dest); os.IsNotExist(err) {
+		plan.add(Action{Item: item, Op: OpCreate, To: dest})
+	}
+	// A symlink is replaced by a copy; otherwise only the files ~/.codex
+	// lacks are copied
+	info, err := os.Lstat(src)
+	linked := err == nil && info.Mode()&os.ModeSymlink != 0
+	missing := os.IsNotExist(err)
+
+	var size int64
+	err = filepath.WalkDir(dest, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dest, path)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(filepath.Join(src, rel)); err == nil && !linked {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if size > 0 || linked || missing {
+		plan.add(Action{Item: item, Op: OpAttach, From: dest, To: src, Bytes: size})
+	}
+	return nil
+}
+
+// planLayered plans what setupLayered does.
+func (m *Manager) planLayered(plan *Plan, targetDir string) error {
+	src := filepath.Join(m.paths.Home, configItem)
+	dest := filepath.Join(targetDir, configItem)
+	info, err := os.Lstat(src)
+	if _, destErr := os.Stat(dest); os.IsNotExist(destErr) {
+		if err == nil && info.Mode().IsRegular() {
+			plan.add(Action{Item: configItem, Op: OpMove, From: src, To: dest, Bytes: info.Size()})
+		} else {
+			plan.add(Action{Item: configItem, Op: OpCreate, To: dest})
+		}
+	}
+	plan.add(Action{Item: configItem, Op: OpLayer, From: dest, To: src})
+	return nil
+}
+
+// planRemove plans what RemoveSymlinks does.
+func (m *Manager) planRemove(plan *Plan) error {
+	for _, item := range m.Items() {
+		src := filepath.Join(m.paths.Home, item)
+		link, err := os.Readlink(src)
+		if err != nil {
+			if !m.linkedItem(item) {
+				continue
+			}
+			size, err := m.linkedSize(item)
+			if err != nil {
+				return err
+			}
+			if size > 0 {
+				plan.add(Action{Item: item, Op: OpUnlink, From: src, Bytes: size})
+			}
+			continue
+		}
+		if _, err := os.Stat(link); err != nil {
+			plan.add(Action{Item: item, Op: OpRemove, From: src})
+			continue
+		}
+		size, err := treeSize(link)
+		if err != nil {
+			return err
+		}
+		plan.add(Action{Item: item, Op: OpCopyBack, From: link, To: src, Bytes: size})
+	}
+	return nil
+}
+
+// linkedSize returns the size of the files of item in ~/.codex hardlinked
+// with a shared copy, which unlinkTree copies.
+func (m *Manager) linkedSize(item string) (int64, error) {
+	live := filepath.Join(m.paths.Home, item)
+	var size int64
+	err := filepath.WalkDir(live, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(live, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		for _, dir := range m.shareTargets() {
+			if other, err := os.Lstat(filepath.Join(dir, item, rel)); err == nil && os.SameFile(info, other) {
+				size += info.Size()
+				break
+			}
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	return size, err
+}
+
+// treeSize returns the total size of the files under path.
+func treeSize(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
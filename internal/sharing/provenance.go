@@ -0,0 +1,199 @@
+package sharing
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/delhombre/cxa/pkg/codex"
+)
+
+// historyItem is the shareable item Codex appends each prompt to.
+const historyItem = "history.jsonl"
+
+// provenanceFile, beside a shared history.jsonl, records the account each
+// of its sessions came from.
+const provenanceFile = "history.accounts.json"
+
+// provenance is what provenanceFile holds: how far into history.jsonl
+// entries have been attributed, and the account of each session ID.
+type provenance struct {
+	Offset   int64             `json:"offset"`
+	Sessions map[string]string `json:"sessions"`
+}
+
+// HistorySession is a session of a shared history.jsonl: its entries,
+// from the first to the last, the text of the first, and the account it
+// came from, if known.
+type HistorySession struct {
+	ID      string    `json:"id"`
+	Account string    `json:"account,omitempty"`
+	Entries int       `json:"entries"`
+	First   time.Time `json:"first"`
+	Last    time.Time `json:"last"`
+	Text    string    `json:"text"`
+}
+
+// Attribute records account, the account in ~/.codex, as where the
+// entries added to the history.jsonl it shares since the last time came
+// from, as everything written there while it was in ~/.codex was. Codex
+// owns the lines and knows nothing of accounts, so rather than tagged in
+// the file, their sessions are mapped to accounts in provenanceFile beside
+// it, each to the first account seen adding to it. A line Codex is still
+// writing waits for the next time. It does nothing unless account shares
+// history.jsonl.
+func (m *Manager) Attribute(account string) error {
+	if !m.IsEnabled() || account == "" {
+		return nil
+	}
+	targetDir, shared := m.SharedItems(account)
+	if targetDir == "" || !slices.Contains(shared, historyItem) {
+		return nil
+	}
+
+	f, err := os.Open(filepath.Join(targetDir, historyItem))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	p, err := loadProvenance(targetDir)
+	if err != nil {
+		return err
+	}
+	if info.Size() < p.Offset {
+		// Rewritten since, as by pruning: go over it again, keeping the
+		// accounts already known
+		p.Offset = 0
+	}
+	if info.Size() == p.Offset {
+		return nil
+	}
+	if _, err := f.Seek(p.Offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		p.Offset += int64(len(line))
+		var entry codex.HistoryEntry
+		if json.Unmarshal(line, &entry) != nil || entry.SessionID == "" {
+			continue
+		}
+		if _, ok := p.Sessions[entry.SessionID]; !ok {
+			p.Sessions[entry.SessionID] = account
+		}
+	}
+	return saveProvenance(targetDir, p)
+}
+
+// HistorySessions returns the sessions of the history.jsonl account, the
+// account in ~/.codex, shares, most recent first, once what it added is
+// attributed to it.
+func (m *Manager) HistorySessions(account string) ([]HistorySession, error) {
+	if !m.IsEnabled() {
+		return nil, fmt.Errorf("sharing is not enabled")
+	}
+	targetDir, shared := m.SharedItems(account)
+	if targetDir == "" || !slices.Contains(shared, historyItem) {
+		return nil, fmt.Errorf("'%s' does not share %s", account, historyItem)
+	}
+	if err := m.Attribute(account); err != nil {
+		return nil, fmt.Errorf("failed to attribute history: %w", err)
+	}
+	p, err := loadProvenance(targetDir)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(targetDir, historyItem))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	sessions := []HistorySession{}
+	index := map[string]int{}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var entry codex.HistoryEntry
+		if json.Unmarshal(line, &entry) != nil || entry.SessionID == "" {
+			continue
+		}
+		at := time.Unix(entry.Ts, 0)
+		i, ok := index[entry.SessionID]
+		if !ok {
+			i = len(sessions)
+			index[entry.SessionID] = i
+			sessions = append(sessions, HistorySession{
+				ID:      entry.SessionID,
+				Account: p.Sessions[entry.SessionID],
+				First:   at,
+				Text:    entry.Text,
+			})
+		}
+		sessions[i].Entries++
+		sessions[i].Last = at
+	}
+
+	slices.SortStableFunc(sessions, func(a, b HistorySession) int {
+		return b.Last.Compare(a.Last)
+	})
+	return sessions, nil
+}
+
+// loadProvenance reads provenanceFile in targetDir, if there is one yet.
+func loadProvenance(targetDir string) (*provenance, error) {
+	p := &provenance{Sessions: map[string]string{}}
+	data, err := os.ReadFile(filepath.Join(targetDir, provenanceFile))
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", provenanceFile, err)
+	}
+	if p.Sessions == nil {
+		p.Sessions = map[string]string{}
+	}
+	return p, nil
+}
+
+// saveProvenance writes provenanceFile in targetDir through a temporary
+// file, so that it is never seen half written.
+func saveProvenance(targetDir string, p *provenance) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(targetDir, provenanceFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
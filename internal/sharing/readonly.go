@@ -0,0 +1,192 @@
+package sharing
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/delhombre/cxa/internal/flock"
+	"github.com/delhombre/cxa/internal/fscopy"
+)
+
+// setupReadOnly attaches the shared copy of item in targetDir to ~/.codex
+// read-only: the item stays a real file or directory of the account's own,
+// into which the shared files it lacks are copied, and the JSON Lines
+// files it has gain the shared lines they lack. Nothing flows the other
+// way until Promote.
+func (m *Manager) setupReadOnly(item, targetDir string) error {
+	src := filepath.Join(m.paths.Home, item)
+	dest := filepath.Join(targetDir, item)
+
+	// A symlink left by the symlink strategy points at the shared copy,
+	// which is attached again below
+	if info, err := os.Lstat(src); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(src); err != nil {
+			return err
+		}
+	}
+	if _, err := os.Lstat(dest); os.IsNotExist(err) {
+		if err := createTarget(item, dest); err != nil {
+			return err
+		}
+	}
+	return attachTree(dest, src)
+}
+
+// attachTree copies each regular file under shared into live where live
+// lacks it, and appends to the JSON Lines files of live the lines of
+// their shared counterparts they lack, unless both have the same size and
+// modification time, as a copy attached and left alone does.
+func attachTree(shared, live string) error {
+	return filepath.Walk(shared, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(shared, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(live, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		existing, err := os.Stat(target)
+		switch {
+		case os.IsNotExist(err):
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			return fscopy.File(path, target, 0, nil)
+		case err != nil:
+			return err
+		case filepath.Ext(path) == ".jsonl" && (info.Size() != existing.Size() || !info.ModTime().Equal(existing.ModTime())):
+			return mergeLines(path, target)
+		}
+		return nil
+	})
+}
+
+// Promote copies what the account in ~/.codex wrote under the read-only
+// strategy into where it shares, account: the files only ~/.codex has,
+// the lines its JSON Lines files have that the shared ones lack, and any
+// other file it changed since the shared one. With session filters, only
+// the new sessions they match are promoted. items limits it to those
+// shared items; none means all. With dryRun nothing is copied. History
+// promoted is attributed to account. It returns the files, relative to
+// ~/.codex, that were or would be promoted.
+func (m *Manager) Promote(account string, items []string, dryRun bool) ([]string, error) {
+	if !m.IsEnabled() {
+		return nil, fmt.Errorf("sharing is not enabled")
+	}
+	if m.Strategy() != StrategyReadOnly {
+		return nil, fmt.Errorf("only the read-only strategy keeps writes local; under %s they are shared already", m.Strategy())
+	}
+	targetDir, shared := m.SharedItems(account)
+	if targetDir == "" {
+		return nil, fmt.Errorf("'%s' does not share", account)
+	}
+	for _, item := range items {
+		if !slices.Contains(shared, item) {
+			return nil, fmt.Errorf("%s is not shared", item)
+		}
+	}
+	if len(items) == 0 {
+		items = shared
+	}
+
+	unlock, err := flock.Acquire(m.paths.LockFile(), flock.DefaultWait)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	promoted := []string{}
+	for _, item := range items {
+		if item == configItem && m.layered() {
+			// Written from the shared config.toml and the overrides
+			continue
+		}
+		var keep func(path string) bool
+		if item == sessionsItem && m.filtered() {
+			keep = m.sessionShared
+		}
+		files, err := promoteTree(filepath.Join(m.paths.Home, item), filepath.Join(targetDir, item), keep, dryRun)
+		if err != nil {
+			return promoted, fmt.Errorf("failed to promote %s: %w", item, err)
+		}
+		for _, rel := range files {
+			promoted = append(promoted, filepath.ToSlash(filepath.Join(item, rel)))
+		}
+	}
+	if !dryRun {
+		if err := m.Attribute(account); err != nil {
+			return promoted, fmt.Errorf("failed to attribute history: %w", err)
+		}
+	}
+	return promoted, nil
+}
+
+// promoteTree copies into shared what live holds that shared lacks, as
+// Promote describes, and returns the files it copied, relative to live.
+// Files shared lacks are left out unless keep, if set, reports true of
+// them. With dryRun it only returns them.
+func promoteTree(live, shared string, keep func(path string) bool, dryRun bool) ([]string, error) {
+	var files []string
+	err := filepath.Walk(live, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(live, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(shared, rel)
+
+		existing, err := os.Stat(target)
+		switch {
+		case os.IsNotExist(err):
+			if keep != nil && !keep(path) {
+				return nil
+			}
+		case err != nil:
+			return err
+		case info.Size() == existing.Size() && info.ModTime().Equal(existing.ModTime()):
+			// An attached copy left alone
+			return nil
+		case filepath.Ext(path) == ".jsonl":
+			extra, err := extraLines(path, target)
+			if err != nil || len(extra) == 0 {
+				return err
+			}
+			files = append(files, rel)
+			if dryRun {
+				return nil
+			}
+			return mergeLines(path, target)
+		case !info.ModTime().After(existing.ModTime()):
+			return nil
+		default:
+			if same, err := sameContents(path, target); err != nil || same {
+				return err
+			}
+		}
+
+		files = append(files, rel)
+		if dryRun {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return fscopy.File(path, target, 0, nil)
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return files, err
+}
@@ -0,0 +1,80 @@
+package sharing
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/delhombre/cxa/internal/config"
+	"github.com/delhombre/cxa/internal/flock"
+)
+
+// Change is what Repair did to a shareable item.
+type Change struct {
+	Item   string    `json:"item"`
+	Before ItemState `json:"before"`
+	After  ItemState `json:"after"`
+}
+
+// Repair puts the shareable items of ~/.codex, holding account, back the
+// way sharing expects them. Items that should be shared are relinked,
+// recreating shared copies that went missing. A symlink into a sharing
+// directory that should not be there is replaced by a copy of what it
+// points to, or removed if that is gone. Symlinks of the user's own are
+// left alone. It returns the items whose state changed, and runs the
+// share-repair hooks if there are any; running it again changes nothing.
+func (m *Manager) Repair(account string) ([]Change, error) {
+	if !m.IsEnabled() {
+		return nil, fmt.Errorf("sharing is not enabled")
+	}
+
+	_, _, before := m.Status(account)
+	if err := m.unshareStray(before); err != nil {
+		return nil, err
+	}
+	if err := m.SetupSymlinks(account); err != nil {
+		return nil, err
+	}
+	_, _, after := m.Status(account)
+
+	changes := []Change{}
+	for i, item := range after {
+		if item.State != before[i].State || item.Target != before[i].Target {
+			changes = append(changes, Change{Item: item.Item, Before: before[i].State, After: item.State})
+		}
+	}
+	if len(changes) > 0 {
+		m.fire(config.HookShareRepair, account)
+	}
+	return changes, nil
+}
+
+// unshareStray replaces each symlink among items that should not be shared
+// but link into a sharing directory with a copy of what it points to.
+func (m *Manager) unshareStray(items []ItemStatus) error {
+	unlock, err := flock.Acquire(m.paths.LockFile(), flock.DefaultWait)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for _, item := range items {
+		if item.Expected {
+			continue
+		}
+		src := filepath.Join(m.paths.Home, item.Item)
+		link, err := os.Readlink(src)
+		if err != nil || !m.inShareTarget(link) {
+			continue
+		}
+		if err := os.Remove(src); err != nil {
+			return err
+		}
+		if _, err := os.Stat(link); err == nil {
+			if err := copyPath(link, src); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package sharing
+
+import (
+	"slices"
+	"sort"
+)
+
+// Selected returns the accounts that share in selected mode, in name
+// order.
+func (m *Manager) Selected() []string {
+	accounts := slices.Clone(m.config.Accounts)
+	sort.Strings(accounts)
+	return accounts
+}
+
+// IsSelected reports whether account shares in selected mode.
+func (m *Manager) IsSelected(account string) bool {
+	return slices.Contains(m.config.Accounts, account)
+}
+
+// Select adds account to the accounts that share in selected mode, or
+// takes it out when selected is false, and saves the configuration. Like
+// Assign, it takes effect the next time the account is switched to, or at
+// once for current, the account in ~/.codex.
+func (m *Manager) Select(account string, selected bool, current string) error {
+	if m.IsSelected(account) == selected {
+		return nil
+	}
+	if selected {
+		m.config.Accounts = append(m.config.Accounts, account)
+	} else {
+		m.config.Accounts = slices.DeleteFunc(m.config.Accounts, func(name string) bool {
+			return name == account
+		})
+	}
+	if err := m.SaveConfig(); err != nil {
+		return err
+	}
+	if account == current && m.config.Mode == ModeSelected {
+		return m.SetupSymlinks(current)
+	}
+	return nil
+}
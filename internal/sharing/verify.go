@@ -0,0 +1,109 @@
+package sharing
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// databaseItem is the shareable item holding Codex's SQLite databases.
+const databaseItem = "sqlite"
+
+// Preflight checks, before switching to account, that sharing can be set
+// up for it without pulling anything from under a running Codex: the
+// databases in ~/.codex are not locked by another process, and where
+// account shares can be written to. It does nothing when sharing is
+// disabled.
+func (m *Manager) Preflight(account string) error {
+	if !m.IsEnabled() {
+		return nil
+	}
+	locked, err := lockedFiles(filepath.Join(m.paths.Home, databaseItem))
+	if err != nil {
+		return err
+	}
+	if len(locked) > 0 {
+		return fmt.Errorf("%s is in use, probably by a running Codex; quit it and try again", locked[0])
+	}
+	if target := m.getShareTarget(account); target != "" {
+		if err := writable(target); err != nil {
+			return fmt.Errorf("cannot write to %s, where '%s' shares: %w", target, account, err)
+		}
+	}
+	return nil
+}
+
+// Verify checks that ~/.codex, holding account, is shared as it should
+// be: every item is as Status expects it, which means shared items link
+// to copies that exist, and where account shares can be written to. Items
+// a Resolver left unshared are as the user wants them. It returns every
+// problem found, or nil.
+func (m *Manager) Verify(account string) error {
+	if !m.IsEnabled() {
+		return nil
+	}
+	var problems []error
+	_, target, items := m.Status(account)
+	for _, item := range items {
+		if item.Healthy() || m.unshared[item.Item] {
+			continue
+		}
+		switch {
+		case item.Expected && item.State == ItemBroken:
+			problems = append(problems, fmt.Errorf("%s links to %s, which is missing", item.Item, item.Target))
+		case item.Expected:
+			problems = append(problems, fmt.Errorf("%s is %s instead of shared", item.Item, item.State))
+		default:
+			problems = append(problems, fmt.Errorf("%s is %s but should not be shared", item.Item, item.State))
+		}
+	}
+	if target != "" {
+		if err := writable(target); err != nil {
+			problems = append(problems, fmt.Errorf("cannot write to %s: %w", target, err))
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// writable checks that files can be created in dir, creating it if need
+// be.
+func writable(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".cxa-write-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// lockedFiles returns the files in dir, following a link to it, that
+// another process holds a lock on, as SQLite does while a database is
+// open.
+func lockedFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var locked []string
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		held, err := lockHeld(path)
+		if err != nil {
+			return nil, err
+		}
+		if held {
+			locked = append(locked, path)
+		}
+	}
+	return locked, nil
+}
@@ -0,0 +1,270 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/delhombre/cxa/internal/account"
+	"github.com/delhombre/cxa/internal/remote"
+)
+
+// BackendDirectory is the default backend, which keeps accounts in the
+// data directory and nowhere else.
+const BackendDirectory = "directory"
+
+// Backend is a storage strategy behind the repository. The data directory
+// stays the working copy every operation reads and writes; a backend keeps
+// it in step with wherever accounts are really kept, so the commands using
+// the repository never deal with backends themselves.
+type Backend interface {
+	// Stored is called after op, such as "save", "import", "rollback" or
+	// "metadata", changed the stored copy of an account.
+	Stored(op, name string) error
+
+	// Removed is called after op took an account away: "delete", or
+	// "rename" with the old name.
+	Removed(op, name string) error
+
+	// Fetch brings the stored copy of an account up to date before it is
+	// activated, which may be the first time this machine sees it.
+	Fetch(name string) error
+}
+
+// BackendFactory opens a backend for the repository r. url is the
+// backend_url setting, which means whatever the backend makes of it.
+type BackendFactory func(r *DirectoryRepository, url string) (Backend, error)
+
+var backends = map[string]BackendFactory{
+	BackendDirectory: nil,
+	"remote":         openRemoteBackend,
+	"s3":             openS3Backend,
+}
+
+// RegisterBackend makes a backend available under name. It is meant to be
+// called from init functions, and panics if name is taken.
+func RegisterBackend(name string, open BackendFactory) {
+	if _, ok := backends[name]; ok {
+		panic(fmt.Sprintf("storage: backend %s registered twice", name))
+	}
+	backends[name] = open
+}
+
+// Backends returns the names of the registered backends, sorted.
+func Backends() []string {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetBackend opens the backend registered as name with url and puts it
+// behind the repository. An empty name means BackendDirectory.
+func (r *DirectoryRepository) SetBackend(name, url string) error {
+	if name == "" {
+		name = BackendDirectory
+	}
+	open, ok := backends[name]
+	if !ok {
+		return fmt.Errorf("unknown storage backend '%s' (available: %s)", name, strings.Join(Backends(), ", "))
+	}
+	r.backend, r.backendName = nil, name
+	if open == nil {
+		return nil
+	}
+	b, err := open(r, url)
+	if err != nil {
+		return fmt.Errorf("failed to open %s backend: %w", name, err)
+	}
+	r.backend = b
+	return nil
+}
+
+// Backend returns the name of the backend behind the repository.
+func (r *DirectoryRepository) Backend() string {
+	if r.backendName == "" {
+		return BackendDirectory
+	}
+	return r.backendName
+}
+
+// stored tells the backend op changed an account. The change is already
+// in the data directory, so a failure is kept as a warning.
+func (r *DirectoryRepository) stored(op, name string) {
+	if r.backend == nil {
+		return
+	}
+	if err := r.backend.Stored(op, name); err != nil {
+		r.warnings.Record("backend", fmt.Sprintf("failed to store %s of '%s' in the %s backend: %v", op, name, r.backendName, err))
+	}
+}
+
+// removed tells the backend op took an account away.
+func (r *DirectoryRepository) removed(op, name string) {
+	if r.backend == nil {
+		return
+	}
+	if err := r.backend.Removed(op, name); err != nil {
+		r.warnings.Record("backend", fmt.Sprintf("failed to remove '%s' from the %s backend: %v", name, r.backendName, err))
+	}
+}
+
+// fetch asks the backend for the latest copy of an account. When it
+// cannot be had, the copy already in the data directory is used.
+func (r *DirectoryRepository) fetch(name string) {
+	if r.backend == nil {
+		return
+	}
+	if err := r.backend.Fetch(name); err != nil {
+		r.warnings.Record("backend", fmt.Sprintf("failed to fetch '%s' from the %s backend: %v", name, r.backendName, err))
+	}
+}
+
+// backendSync is the sync state entry the remote backend keeps its base
+// hashes under, apart from the remotes of cxa sync.
+const backendSync = ".backend"
+
+// remoteBackend keeps accounts on a sync remote, as cxa sync does, pushing
+// each change as it happens and pulling an account before activating it.
+type remoteBackend struct {
+	r *DirectoryRepository
+	t remote.Transport
+}
+
+func openRemoteBackend(r *DirectoryRepository, url string) (Backend, error) {
+	t, err := remote.Open(url)
+	if err != nil {
+		return nil, err
+	}
+	return &remoteBackend{r: r, t: t}, nil
+}
+
+// openS3Backend is the remote backend on an S3-compatible bucket, with
+// backend_url naming the bucket and prefix with or without s3://.
+func openS3Backend(r *DirectoryRepository, url string) (Backend, error) {
+	if !strings.HasPrefix(url, "s3://") {
+		url = "s3://" + url
+	}
+	return openRemoteBackend(r, url)
+}
+
+func (b *remoteBackend) Stored(op, name string) error {
+	if err := account.ValidateName(name); err != nil {
+		return err
+	}
+	index, err := b.t.ReadIndex()
+	if err != nil {
+		return err
+	}
+	return b.push(index, name)
+}
+
+func (b *remoteBackend) push(index *remote.Index, name string) error {
+	acc, err := b.r.Get(name)
+	if err != nil {
+		return err
+	}
+	accountPath := b.r.paths.AccountPath(name)
+	hash, err := hashTree(accountPath)
+	if err != nil {
+		return err
+	}
+	if err := b.t.Push(name, accountPath); err != nil {
+		return err
+	}
+	host, _ := os.Hostname()
+	index.Accounts[name] = remote.IndexEntry{
+		Hash:      hash,
+		UpdatedAt: acc.UpdatedAt,
+		PushedAt:  time.Now(),
+		PushedBy:  host,
+	}
+	if err := b.t.WriteIndex(index); err != nil {
+		return err
+	}
+	return b.setBase(name, hash)
+}
+
+// Removed drops the account from the remote index, so other machines no
+// longer fetch it. Its files stay on the remote.
+func (b *remoteBackend) Removed(op, name string) error {
+	index, err := b.t.ReadIndex()
+	if err != nil {
+		return err
+	}
+	if _, ok := index.Accounts[name]; ok {
+		delete(index.Accounts, name)
+		if err := b.t.WriteIndex(index); err != nil {
+			return err
+		}
+	}
+	return b.setBase(name, "")
+}
+
+// Fetch pulls the account when the remote has a copy newer than the last
+// one synced, and pushes it when only the local copy changed since, such
+// as after a failed push. When both changed, the local copy is kept.
+func (b *remoteBackend) Fetch(name string) error {
+	if err := account.ValidateName(name); err != nil {
+		return err
+	}
+	index, err := b.t.ReadIndex()
+	if err != nil {
+		return err
+	}
+	entry, ok := index.Accounts[name]
+	if !ok {
+		return nil
+	}
+
+	state, err := b.r.loadSyncState()
+	if err != nil {
+		return err
+	}
+	base := state[backendSync][name]
+	local := ""
+	if _, err := os.Stat(b.r.paths.AccountPath(name)); err == nil {
+		if local, err = hashTree(b.r.paths.AccountPath(name)); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case local == entry.Hash:
+		if base != local {
+			return b.setBase(name, local)
+		}
+		return nil
+	case local == "" || local == base:
+		if err := b.r.pull(b.t, name, entry.Hash); err != nil {
+			return err
+		}
+		return b.setBase(name, entry.Hash)
+	case entry.Hash == base:
+		return b.push(index, name)
+	default:
+		return fmt.Errorf("'%s' changed both here and on the remote; keeping the local copy, settle it with cxa sync", name)
+	}
+}
+
+// setBase records hash as the last synced contents of the account, or
+// forgets it when hash is empty.
+func (b *remoteBackend) setBase(name, hash string) error {
+	state, err := b.r.loadSyncState()
+	if err != nil {
+		return err
+	}
+	if state[backendSync] == nil {
+		state[backendSync] = make(map[string]string)
+	}
+	if hash == "" {
+		delete(state[backendSync], name)
+	} else {
+		state[backendSync][name] = hash
+	}
+	return b.r.saveSyncState(state)
+}
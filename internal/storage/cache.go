@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/delhombre/cxa/internal/account"
+)
+
+// WarmCache pre-stages the size most recently used accounts, other than the
+// current one and archived, compressed, or encrypted ones, in the cache
+// directory of the data directory, so Activate can swap them in instead of
+// copying. Entries are brought up to date incrementally, and entries for
+// accounts that dropped out of the top size are removed. It returns the
+// names of the cached accounts.
+func (r *DirectoryRepository) WarmCache(size int) ([]string, error) {
+	unlock, err := r.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	accounts, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+	current, _ := r.Current()
+
+	account.SortRecent(accounts)
+
+	keep := make(map[string]bool)
+	var warmed []string
+	for _, acc := range accounts {
+		if len(warmed) >= size {
+			break
+		}
+		if acc.Name == current || acc.Archived || acc.Compressed || acc.Encrypted {
+			continue
+		}
+		keep[acc.Name] = true
+		opts := r.copyOptions()
+		opts.root = r.paths.Home
+		if err := resumeCopy(r.paths.AccountPath(acc.Name), r.paths.CachePath(acc.Name), opts); err != nil {
+			return warmed, err
+		}
+		warmed = append(warmed, acc.Name)
+	}
+
+	entries, err := os.ReadDir(r.paths.CacheDir())
+	if err != nil && !os.IsNotExist(err) {
+		return warmed, err
+	}
+	for _, entry := range entries {
+		if !keep[entry.Name()] {
+			if err := os.RemoveAll(r.paths.CachePath(entry.Name())); err != nil {
+				return warmed, err
+			}
+		}
+	}
+	return warmed, nil
+}
+
+// SetCacheSize sets how many accounts the warm cache keeps up to date by
+// itself, after every switch and every save Watch makes. Zero leaves it to
+// WarmCache.
+func (r *DirectoryRepository) SetCacheSize(size int) {
+	r.cacheSize = size
+}
+
+// refreshCache brings the warm cache up to date when SetCacheSize enabled
+// it. A failure is kept as a warning: the cache only makes switches faster.
+func (r *DirectoryRepository) refreshCache() {
+	if r.cacheSize <= 0 {
+		return
+	}
+	if _, err := r.WarmCache(r.cacheSize); err != nil {
+		r.warnings.Record("cache", fmt.Sprintf("failed to refresh the warm cache: %v", err))
+	}
+}
+
+// ClearCache removes every pre-staged account.
+func (r *DirectoryRepository) ClearCache() error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return os.RemoveAll(r.paths.CacheDir())
+}
+
+// cachedStaging returns the warm cache entry for name if there is one. It
+// is a staging directory for the account, so Activate only has to top it up
+// before renaming it into place.
+func (r *DirectoryRepository) cachedStaging(name string) (string, bool) {
+	path := r.paths.CachePath(name)
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		return "", false
+	}
+	return path, true
+}
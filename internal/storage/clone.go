@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// clonePrefix names scratch clones inside the data directory.
+const clonePrefix = ".clone-"
+
+// Clone copies a saved account into a new scratch directory next to the
+// accounts, so it can be used without touching the saved copy and later
+// committed back with Commit or thrown away. On APFS the copy is a
+// copy-on-write clone, which costs no time or space until files change.
+// The caller owns the returned directory.
+func (r *DirectoryRepository) Clone(name string) (string, error) {
+	accountPath, release, err := r.openDir(name)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	if err := r.paths.EnsureDirs(); err != nil {
+		return "", err
+	}
+
+	dir, err := os.MkdirTemp(r.paths.DataDir, clonePrefix+name+"-")
+	if err != nil {
+		return "", err
+	}
+	// The name is reserved; the clone takes the place of the empty
+	// directory
+	if err := os.Remove(dir); err != nil {
+		return "", err
+	}
+	if err := cloneStaged(accountPath, dir, dir+activateStagingSuffix, r.copyOptions()); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to clone account: %w", err)
+	}
+	if err := r.restoreAuth(name, dir); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// CloneChanged reports whether the clone at dir differs from the saved
+// account, ignoring metadata.
+func (r *DirectoryRepository) CloneChanged(name, dir string) (bool, error) {
+	accountPath, release, err := r.openDir(name)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	// Credentials kept in the keychain are compared on their own
+	auth, err := r.keyringAuth(name)
+	if err != nil {
+		return false, err
+	}
+	var skip []string
+	if auth != nil {
+		skip = append(skip, authFile)
+		cloned, err := os.ReadFile(filepath.Join(dir, authFile))
+		if err != nil || !bytes.Equal(auth, cloned) {
+			return true, nil
+		}
+	}
+
+	saved, err := hashTree(accountPath, skip...)
+	if err != nil {
+		return false, err
+	}
+	cloned, err := hashTree(dir, skip...)
+	if err != nil {
+		return false, err
+	}
+	return saved != cloned, nil
+}
+
+// Commit replaces the saved account with the clone at dir, keeping the
+// account's metadata, and removes the clone.
+func (r *DirectoryRepository) Commit(name, dir string) error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	acc, err := r.Get(name)
+	if err != nil {
+		return err
+	}
+	if err := acc.CheckWritable(); err != nil {
+		return err
+	}
+
+	accountPath := r.paths.AccountPath(name)
+	r.snapshot(name, accountPath)
+	if err := copyStaged(dir, accountPath, accountPath+saveStagingSuffix, r.updateOptions(accountPath)); err != nil {
+		return fmt.Errorf("failed to commit clone: %w", err)
+	}
+	if err := r.stowAuth(name, accountPath, acc); err != nil {
+		return fmt.Errorf("failed to commit clone: %w", err)
+	}
+	if err := writeManifest(accountPath); err != nil {
+		return fmt.Errorf("failed to record manifest: %w", err)
+	}
+
+	acc.UpdatedAt = time.Now()
+	if err := r.writeMetadata(accountPath, acc); err != nil {
+		return err
+	}
+	if err := r.sealSaved(name, accountPath); err != nil {
+		return err
+	}
+	r.dedupAccount(name, accountPath)
+	r.stored("commit", name)
+	return os.RemoveAll(dir)
+}
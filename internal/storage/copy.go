@@ -0,0 +1,456 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/delhombre/cxa/internal/fscopy"
+)
+
+// Staging suffixes for copies into a destination directory. The staging
+// directory sits next to its destination so the final rename never crosses
+// filesystems.
+const (
+	saveStagingSuffix     = ".saving"
+	activateStagingSuffix = ".tmp"
+)
+
+// replacedSuffix names the previous destination while a completed staging
+// directory is swapped in. If cxa dies between the two renames, the next
+// copy to the same destination puts it back first.
+const replacedSuffix = ".old"
+
+// copyAttempts is how many times a staged copy is resumed before giving up.
+const copyAttempts = 3
+
+// journalName is the file inside a staging directory that records which
+// source files have been started, so an interrupted copy can pick up where
+// it left off instead of restarting from zero.
+const journalName = ".cxa-copy.journal"
+
+// journalHeader is the first line of a journal and ties the staging
+// directory to its source.
+type journalHeader struct {
+	Source string `json:"source"`
+}
+
+// journalEntry records the source signature of a file whose copy started.
+type journalEntry struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// copyOptions tunes a single copy operation.
+type copyOptions struct {
+	// throttle limits write throughput; nil means unlimited.
+	throttle *ioThrottle
+
+	// base, if set, is an earlier copy of src, normally the destination
+	// being replaced. Files in it that still match src are hardlinked into
+	// staging instead of copied, so only changed files cost any I/O. The
+	// link outlives base only as the single name of the file once base is
+	// replaced, so nothing ends up shared.
+	base string
+
+	// exclude names paths under src to leave out of the copy.
+	exclude fscopy.Excludes
+
+	// root is where the staging directory ends up, for rewriting symlinks
+	// that point inside the source. copyStaged sets it to its destination.
+	root string
+
+	// ctx stops the copy between files when it is done; nil means the
+	// copy runs to the end.
+	ctx context.Context
+
+	// progress counts the files copied; nil means nobody is watching.
+	progress *progressTracker
+
+	// partial, if set, picks the paths under src to copy. Everything else
+	// is carried over from dst as it was, for a save that updates only
+	// part of an account.
+	partial *Selection
+}
+
+// skips reports whether the path rel under src, a directory if dir is
+// true, is left out of the copy.
+func (o copyOptions) skips(rel string, dir bool) bool {
+	rel = filepath.ToSlash(rel)
+	return o.exclude.Match(rel, dir) || (o.partial != nil && !o.partial.takes(rel, dir))
+}
+
+// cancelled returns why the copy's context is done, if it is.
+func (o copyOptions) cancelled() error {
+	if o.ctx == nil {
+		return nil
+	}
+	return o.ctx.Err()
+}
+
+// copyJournal tracks progress of a staged copy.
+type copyJournal struct {
+	file    *os.File
+	entries map[string]journalEntry
+}
+
+// copyStaged copies src into staging, resuming from any previous
+// interrupted attempt, then replaces dst with the completed staging
+// directory. On failure the staging directory is left in place so the next
+// attempt can resume it, and dst is left as it was.
+func copyStaged(src, dst, staging string, opts copyOptions) error {
+	if err := recoverSwap(dst); err != nil {
+		return err
+	}
+
+	opts.root = dst
+	var err error
+	for attempt := 0; attempt < copyAttempts; attempt++ {
+		// A cancelled copy is not retried; staging keeps what it got
+		if err = resumeCopy(src, staging, opts); err == nil || opts.cancelled() != nil {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if opts.partial != nil {
+		if err := carryOver(dst, staging, *opts.partial); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Remove(filepath.Join(staging, journalName)); err != nil {
+		return err
+	}
+	if info, err := os.Stat(src); err == nil {
+		_ = os.Chtimes(staging, info.ModTime(), info.ModTime())
+	}
+	return swapIn(staging, dst)
+}
+
+// cloneStaged makes dst, which should not exist yet, a copy-on-write
+// clone of src where the filesystem clones whole trees at once, and
+// otherwise copies it with copyStaged. Only copies that take src as it is
+// are cloned: opts still applies to the fallback copy.
+func cloneStaged(src, dst, staging string, opts copyOptions) error {
+	_, dstErr := os.Lstat(dst)
+	_, stagingErr := os.Lstat(staging)
+	if os.IsNotExist(dstErr) && os.IsNotExist(stagingErr) {
+		if err := fscopy.CloneTree(src, staging, dst); err == nil {
+			return os.Rename(staging, dst)
+		}
+		_ = os.RemoveAll(staging)
+	}
+	return copyStaged(src, dst, staging, opts)
+}
+
+// swapIn replaces dst with staging. The previous dst is moved aside rather
+// than deleted until staging is in place, and moved back if that fails, so
+// dst is never missing for longer than between two renames.
+func swapIn(staging, dst string) error {
+	replaced := dst + replacedSuffix
+	if err := os.RemoveAll(replaced); err != nil {
+		return err
+	}
+	hadDst := true
+	if err := os.Rename(dst, replaced); err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		hadDst = false
+	}
+
+	err := fault(OpRename, dst)
+	if err == nil {
+		err = os.Rename(staging, dst)
+	}
+	if err != nil {
+		if hadDst {
+			if restoreErr := os.Rename(replaced, dst); restoreErr != nil {
+				return fmt.Errorf("%w; the previous %s is left at %s: %v", err, dst, replaced, restoreErr)
+			}
+		}
+		return err
+	}
+
+	// What was replaced is no longer needed; a leftover is cleared by the
+	// next swap
+	_ = os.RemoveAll(replaced)
+	return nil
+}
+
+// recoverSwap puts back a destination that a previous swapIn moved aside
+// but never replaced.
+func recoverSwap(dst string) error {
+	replaced := dst + replacedSuffix
+	if _, err := os.Lstat(dst); !os.IsNotExist(err) {
+		return nil
+	}
+	if _, err := os.Lstat(replaced); err != nil {
+		return nil
+	}
+	return os.Rename(replaced, dst)
+}
+
+// resumeCopy brings staging in line with src, skipping files the journal
+// shows were already copied and continuing partially copied ones. Files
+// are copied on the fscopy worker pool.
+func resumeCopy(src, staging string, opts copyOptions) error {
+	journal, err := openJournal(src, staging)
+	if err != nil {
+		return err
+	}
+	defer journal.file.Close()
+
+	root := opts.root
+	if root == "" {
+		root = staging
+	}
+	opts.progress.start(src, opts.skips)
+	pool := fscopy.NewPool()
+	var dirs fscopy.DirTimes
+	err = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := pool.Err(); err != nil {
+			return err
+		}
+		if err := opts.cancelled(); err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if opts.skips(relPath, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		dstPath := filepath.Join(staging, relPath)
+
+		// Handle symlinks
+		if info.Mode()&os.ModeSymlink != 0 {
+			if err := fault(OpSymlink, dstPath); err != nil {
+				return err
+			}
+			return fscopy.Symlink(path, dstPath, src, root)
+		}
+
+		// Handle directories
+		if info.IsDir() {
+			if existing, err := os.Lstat(dstPath); err == nil && !existing.IsDir() {
+				if err := os.Remove(dstPath); err != nil {
+					return err
+				}
+			}
+			if err := fault(OpMkdir, dstPath); err != nil {
+				return err
+			}
+			if err := os.MkdirAll(dstPath, info.Mode()); err != nil {
+				return err
+			}
+			dirs.Add(dstPath, info)
+			return os.Chmod(dstPath, info.Mode().Perm())
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		// Copy file, resuming if a previous attempt got part of the way
+		offset, ok := journal.resumeOffset(relPath, info, dstPath)
+		if ok && offset == info.Size() {
+			opts.progress.done(info.Size())
+			return nil
+		}
+		if !ok {
+			if err := os.RemoveAll(dstPath); err != nil {
+				return err
+			}
+			if err := journal.record(relPath, info); err != nil {
+				return err
+			}
+		}
+		pool.Go(func() error {
+			if err := copyEntry(path, dstPath, relPath, info, offset, opts); err != nil {
+				return err
+			}
+			opts.progress.done(info.Size())
+			return nil
+		})
+		return nil
+	})
+	if waitErr := pool.Wait(); err == nil {
+		err = waitErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := pruneStaging(src, staging, opts.skips); err != nil {
+		return err
+	}
+	return dirs.Apply()
+}
+
+// copyEntry copies the regular file at path to dstPath, continuing from
+// offset.
+func copyEntry(path, dstPath, relPath string, info os.FileInfo, offset int64, opts copyOptions) error {
+	if err := fault(OpCopy, dstPath); err != nil {
+		return err
+	}
+	if offset == 0 && reuseBase(opts.base, relPath, info, dstPath) {
+		return nil
+	}
+
+	// fscopy.File reflinks where it can. Hardlinks are no substitute:
+	// Codex appends to its session logs in place, and those writes would
+	// land in the saved account too.
+	return fscopy.File(path, dstPath, offset, opts.throttle.writer)
+}
+
+// pruneStaging removes entries from staging that no longer exist in src,
+// which happens when files are deleted between interrupted attempts, or
+// that are left out.
+func pruneStaging(src, staging string, skips func(rel string, dir bool) bool) error {
+	return filepath.Walk(staging, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(staging, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." || relPath == journalName {
+			return nil
+		}
+
+		_, err = os.Lstat(filepath.Join(src, relPath))
+		if os.IsNotExist(err) || skips(relPath, info.IsDir()) {
+			if err := os.RemoveAll(path); err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+}
+
+// openJournal opens the journal in staging, discarding the staging
+// directory if it belongs to a different source or has no journal.
+func openJournal(src, staging string) (*copyJournal, error) {
+	path := filepath.Join(staging, journalName)
+	entries, ok := readJournal(path, src)
+	if !ok {
+		if err := os.RemoveAll(staging); err != nil {
+			return nil, err
+		}
+		entries = make(map[string]journalEntry)
+	}
+
+	if err := os.MkdirAll(staging, 0755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	journal := &copyJournal{file: file, entries: entries}
+	if !ok {
+		if err := journal.append(journalHeader{Source: src}); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return journal, nil
+}
+
+// readJournal loads the journal at path. It reports false if the journal
+// is missing, unreadable, or was written for a different source.
+func readJournal(path, src string) (map[string]journalEntry, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		return nil, false
+	}
+	var header journalHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil || header.Source != src {
+		return nil, false
+	}
+
+	entries := make(map[string]journalEntry)
+	for scanner.Scan() {
+		var entry journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			break // Torn final line from an interrupted write
+		}
+		entries[entry.Path] = entry
+	}
+	return entries, true
+}
+
+// resumeOffset returns how many bytes of the file at relPath are already in
+// staging. It reports false when the file must be copied from scratch.
+func (j *copyJournal) resumeOffset(relPath string, info os.FileInfo, dstPath string) (int64, bool) {
+	entry, ok := j.entries[relPath]
+	if !ok || entry.Size != info.Size() || !entry.ModTime.Equal(info.ModTime()) {
+		return 0, false
+	}
+
+	staged, err := os.Lstat(dstPath)
+	if err != nil || !staged.Mode().IsRegular() || staged.Size() > info.Size() {
+		return 0, false
+	}
+	return staged.Size(), true
+}
+
+func (j *copyJournal) record(relPath string, info os.FileInfo) error {
+	entry := journalEntry{Path: relPath, Size: info.Size(), ModTime: info.ModTime()}
+	j.entries[relPath] = entry
+	return j.append(entry)
+}
+
+func (j *copyJournal) append(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = j.file.Write(append(data, '\n'))
+	return err
+}
+
+// reuseBase hardlinks the file at relPath in base to dstPath if it has the
+// same size, mode, and modification time as the source file described by
+// info. It reports whether it did. Copies keep the source's modification
+// time, so an unchanged file always matches.
+func reuseBase(base, relPath string, info os.FileInfo, dstPath string) bool {
+	if base == "" {
+		return false
+	}
+	basePath := filepath.Join(base, relPath)
+	existing, err := os.Lstat(basePath)
+	if err != nil || !existing.Mode().IsRegular() || existing.Mode() != info.Mode() ||
+		existing.Size() != info.Size() || !existing.ModTime().Equal(info.ModTime()) {
+		return false
+	}
+	return os.Link(basePath, dstPath) == nil
+}
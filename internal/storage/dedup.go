@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/delhombre/cxa/internal/fscopy"
+)
+
+// dedupDir is the part of an account whose files are deduplicated.
+// Session files are what accounts most often have in common, and once
+// saved nothing writes into them but Codex through a linked ~/.codex,
+// which is given private copies first.
+const dedupDir = "sessions"
+
+// dedupLinkSuffix names the link to a blob while it is renamed over the
+// file it replaces.
+const dedupLinkSuffix = ".cxa-link"
+
+// SetDedup makes saving and importing store session files once in the
+// blob store, with every account holding the same file linked to it. Only
+// files with the same contents, mode, and modification time are shared, so
+// an account reads exactly as it would without deduplication. Where link
+// counts cannot be read (Windows), it has no effect, as GC could not tell
+// which blobs are still in use.
+func (r *DirectoryRepository) SetDedup(on bool) {
+	r.dedup = on
+}
+
+// dedupAccount links the session files of the account at accountPath to
+// the blob store, adding blobs for files seen first. Encrypted, compressed
+// and linked accounts are left alone. Deduplication only saves space, so a
+// failure is kept as a warning.
+func (r *DirectoryRepository) dedupAccount(name, accountPath string) {
+	if !r.dedup || sealed(accountPath) || packed(accountPath) || r.isLinked(accountPath) {
+		return
+	}
+	if info, err := os.Stat(accountPath); err != nil {
+		return
+	} else if _, ok := linkCount(info); !ok {
+		return
+	}
+	linked, err := dedupTree(accountPath, r.paths.BlobsDir())
+	if err != nil {
+		r.warnings.Record("dedup", fmt.Sprintf("failed to deduplicate the sessions of '%s': %v", name, err))
+		return
+	}
+	r.log.Debug("deduplicated account", "account", name, "linked", linked)
+}
+
+// dedupTree replaces the session files of the account at dir with links
+// to blobs in blobs, named by the hashes the manifest recorded for them,
+// and returns how many it replaced. It runs right after the manifest is
+// written, while the hashes still hold.
+func dedupTree(dir, blobs string) (int, error) {
+	m, err := readManifest(dir)
+	if err != nil || m == nil {
+		return 0, err
+	}
+
+	linked := 0
+	for _, entry := range m.Files {
+		if entry.SHA256 == "" || entry.Size == 0 || !strings.HasPrefix(entry.Path, dedupDir+"/") {
+			continue
+		}
+		path := filepath.Join(dir, filepath.FromSlash(entry.Path))
+		info, err := os.Lstat(path)
+		if err != nil || !info.Mode().IsRegular() || info.Size() != entry.Size {
+			continue
+		}
+
+		blob := filepath.Join(blobs, entry.SHA256[:2], entry.SHA256)
+		existing, err := os.Lstat(blob)
+		switch {
+		case os.IsNotExist(err):
+			// First seen here: the file becomes the blob
+			if err := os.MkdirAll(filepath.Dir(blob), 0700); err != nil {
+				return linked, err
+			}
+			if err := os.Link(path, blob); err != nil {
+				return linked, err
+			}
+		case err != nil:
+			return linked, err
+		case os.SameFile(info, existing):
+		case existing.Mode() != info.Mode() || existing.Size() != info.Size() || !existing.ModTime().Equal(info.ModTime()):
+			// Same contents, but sharing would change how the file reads
+		default:
+			tmp := path + dedupLinkSuffix
+			_ = os.Remove(tmp)
+			if err := os.Link(blob, tmp); err != nil {
+				return linked, err
+			}
+			if err := os.Rename(tmp, path); err != nil {
+				_ = os.Remove(tmp)
+				return linked, err
+			}
+			linked++
+		}
+	}
+	return linked, nil
+}
+
+// unshareSessions gives the account at dir private copies of its session
+// files that have other names, in the blob store or in snapshots, before
+// ~/.codex is linked to it and Codex writes into them.
+func unshareSessions(dir string) error {
+	err := filepath.Walk(filepath.Join(dir, dedupDir), func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		if n, ok := linkCount(info); !ok || n < 2 {
+			return nil
+		}
+		tmp := path + dedupLinkSuffix
+		if err := fscopy.File(path, tmp, 0, nil); err != nil {
+			_ = os.Remove(tmp)
+			return err
+		}
+		return os.Rename(tmp, path)
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// GCResult is what GC removed, or would remove.
+type GCResult struct {
+	Blobs int   `json:"blobs"`
+	Bytes int64 `json:"bytes"`
+}
+
+// GC removes the blobs no saved account, snapshot, or deleted account
+// links to any more. With dryRun, they are only counted.
+func (r *DirectoryRepository) GC(dryRun bool) (*GCResult, error) {
+	unlock, err := r.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	result := &GCResult{}
+	root := r.paths.BlobsDir()
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		n, ok := linkCount(info)
+		if !ok {
+			return errors.New("cannot tell which blobs are in use on this platform")
+		}
+		if n > 1 {
+			return nil
+		}
+		result.Blobs++
+		result.Bytes += info.Size()
+		if dryRun {
+			return nil
+		}
+		return os.Remove(path)
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+
+	// Drop fan-out directories left empty
+	if !dryRun {
+		dirs, _ := os.ReadDir(root)
+		for _, d := range dirs {
+			_ = os.Remove(filepath.Join(root, d.Name()))
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,308 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// DiffKind says how an entry differs between two trees.
+type DiffKind string
+
+const (
+	OnlyLeft  DiffKind = "only_left"
+	OnlyRight DiffKind = "only_right"
+	Changed   DiffKind = "changed"
+)
+
+// DiffEntry is a path that differs between two trees. Directories present
+// on one side only are reported once, without their contents.
+type DiffEntry struct {
+	Path      string   `json:"path"`
+	Kind      DiffKind `json:"kind"`
+	LeftSize  int64    `json:"left_size"`
+	RightSize int64    `json:"right_size"`
+}
+
+// SettingChange is a setting that differs between two settings files.
+// Left or Right is empty when the setting is absent on that side.
+type SettingChange struct {
+	File  string `json:"file"`
+	Key   string `json:"key"`
+	Left  string `json:"left,omitempty"`
+	Right string `json:"right,omitempty"`
+}
+
+// Diff is the comparison of two account trees.
+type Diff struct {
+	Left     string          `json:"left"`
+	Right    string          `json:"right"`
+	Entries  []DiffEntry     `json:"entries"`
+	Settings []SettingChange `json:"settings"`
+}
+
+// Empty reports whether the trees are identical.
+func (d *Diff) Empty() bool {
+	return len(d.Entries) == 0
+}
+
+// settingsFiles are summarized key by key rather than only flagged.
+var settingsFiles = map[string]func([]byte) map[string]string{
+	"config.toml":   tomlSettings,
+	"settings.json": jsonSettings,
+}
+
+// Diff compares two saved accounts. An empty right compares against the
+// live ~/.codex.
+func (r *DirectoryRepository) Diff(left, right string) (*Diff, error) {
+	leftDir, release, err := r.openDir(left)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	rightDir, rightLabel := r.liveDir(), "~/.codex"
+	if right != "" {
+		var releaseRight func()
+		if rightDir, releaseRight, err = r.openDir(right); err != nil {
+			return nil, err
+		}
+		defer releaseRight()
+		rightLabel = right
+	}
+
+	d, err := DiffDirs(leftDir, rightDir)
+	if err != nil {
+		return nil, err
+	}
+	// Credentials kept in the keychain are missing from the account
+	// directory without having been removed
+	if r.inKeychain(left) || (right != "" && r.inKeychain(right)) {
+		d.Entries = slices.DeleteFunc(d.Entries, func(e DiffEntry) bool { return e.Path == authFile })
+	}
+	d.Left, d.Right = left, rightLabel
+	return d, nil
+}
+
+// DiffDirs compares two directory trees by presence, size, and content,
+// ignoring account metadata and manifests. Symlinks are compared by target,
+// with absolute targets inside left taken as the same place under right,
+// as copies rewrite them.
+func DiffDirs(left, right string) (*Diff, error) {
+	d := &Diff{Left: left, Right: right, Entries: []DiffEntry{}, Settings: []SettingChange{}}
+	if err := diffLevel(d, left, right, "."); err != nil {
+		return nil, err
+	}
+
+	for name, parse := range settingsFiles {
+		leftData, _ := os.ReadFile(filepath.Join(left, name))
+		rightData, _ := os.ReadFile(filepath.Join(right, name))
+		if bytes.Equal(leftData, rightData) {
+			continue
+		}
+		d.Settings = append(d.Settings, diffSettings(name, parse(leftData), parse(rightData))...)
+	}
+	sort.Slice(d.Settings, func(i, j int) bool {
+		if d.Settings[i].File != d.Settings[j].File {
+			return d.Settings[i].File < d.Settings[j].File
+		}
+		return d.Settings[i].Key < d.Settings[j].Key
+	})
+	return d, nil
+}
+
+func diffLevel(d *Diff, left, right, rel string) error {
+	leftEntries, err := readDirMap(filepath.Join(left, rel))
+	if err != nil {
+		return err
+	}
+	rightEntries, err := readDirMap(filepath.Join(right, rel))
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(leftEntries)+len(rightEntries))
+	for name := range leftEntries {
+		names = append(names, name)
+	}
+	for name := range rightEntries {
+		if _, ok := leftEntries[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := filepath.Join(rel, name)
+		if path == ".account.json" || path == manifestName {
+			continue
+		}
+		l, inLeft := leftEntries[name]
+		r, inRight := rightEntries[name]
+
+		switch {
+		case !inRight:
+			d.Entries = append(d.Entries, DiffEntry{Path: filepath.ToSlash(path), Kind: OnlyLeft, LeftSize: l.Size()})
+		case !inLeft:
+			d.Entries = append(d.Entries, DiffEntry{Path: filepath.ToSlash(path), Kind: OnlyRight, RightSize: r.Size()})
+		case l.IsDir() && r.IsDir():
+			if err := diffLevel(d, left, right, path); err != nil {
+				return err
+			}
+		default:
+			same, err := sameEntry(left, right, path, l, r)
+			if err != nil {
+				return err
+			}
+			if !same {
+				d.Entries = append(d.Entries, DiffEntry{Path: filepath.ToSlash(path), Kind: Changed, LeftSize: l.Size(), RightSize: r.Size()})
+			}
+		}
+	}
+	return nil
+}
+
+// readDirMap lists dir by name without following symlinks. A missing dir
+// is empty.
+func readDirMap(dir string) (map[string]os.FileInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]os.FileInfo{}, nil
+		}
+		return nil, err
+	}
+
+	infos := make(map[string]os.FileInfo, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		infos[entry.Name()] = info
+	}
+	return infos, nil
+}
+
+func sameEntry(left, right, path string, l, r os.FileInfo) (bool, error) {
+	leftPath, rightPath := filepath.Join(left, path), filepath.Join(right, path)
+	if l.Mode().Type() != r.Mode().Type() {
+		return false, nil
+	}
+	if l.Mode()&os.ModeSymlink != 0 {
+		lt, err := os.Readlink(leftPath)
+		if err != nil {
+			return false, err
+		}
+		rt, err := os.Readlink(rightPath)
+		if err != nil {
+			return false, err
+		}
+		if rel, err := filepath.Rel(left, lt); err == nil && filepath.IsAbs(lt) && !strings.HasPrefix(rel, "..") {
+			lt = filepath.Join(right, rel)
+		}
+		return lt == rt, nil
+	}
+	if !l.Mode().IsRegular() || l.Size() != r.Size() {
+		return l.Size() == r.Size(), nil
+	}
+
+	lh, err := fileHash(leftPath)
+	if err != nil {
+		return false, err
+	}
+	rh, err := fileHash(rightPath)
+	if err != nil {
+		return false, err
+	}
+	return lh == rh, nil
+}
+
+func fileHash(path string) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+	f, err := os.Open(path)
+	if err != nil {
+		return sum, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+func diffSettings(file string, left, right map[string]string) []SettingChange {
+	var changes []SettingChange
+	for key, lv := range left {
+		if rv, ok := right[key]; !ok || rv != lv {
+			changes = append(changes, SettingChange{File: file, Key: key, Left: lv, Right: right[key]})
+		}
+	}
+	for key, rv := range right {
+		if _, ok := left[key]; !ok {
+			changes = append(changes, SettingChange{File: file, Key: key, Right: rv})
+		}
+	}
+	return changes
+}
+
+// tomlSettings flattens the key = value lines of a TOML file into
+// section.key entries. Values are kept as written; multi-line values are
+// summarized by their first line.
+func tomlSettings(data []byte) map[string]string {
+	settings := make(map[string]string)
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "["):
+			section = strings.Trim(line, "[] ")
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if section != "" {
+			key = section + "." + key
+		}
+		settings[key] = strings.TrimSpace(value)
+	}
+	return settings
+}
+
+// jsonSettings flattens the top-level keys of a JSON object.
+func jsonSettings(data []byte) map[string]string {
+	settings := make(map[string]string)
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		if len(data) > 0 {
+			settings["(file)"] = fmt.Sprintf("invalid JSON: %v", err)
+		}
+		return settings
+	}
+	for key, value := range obj {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, value); err != nil {
+			compact.Write(value)
+		}
+		settings[key] = compact.String()
+	}
+	return settings
+}
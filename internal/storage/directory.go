@@ -2,33 +2,175 @@
 package storage
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/delhombre/cxa/internal/account"
+	"github.com/delhombre/cxa/internal/config"
+	"github.com/delhombre/cxa/internal/flock"
+	"github.com/delhombre/cxa/internal/fscopy"
+	"github.com/delhombre/cxa/internal/history"
+	"github.com/delhombre/cxa/internal/logging"
+	"github.com/delhombre/cxa/internal/policy"
+	"github.com/delhombre/cxa/internal/schema"
 	"github.com/delhombre/cxa/internal/sharing"
+	"github.com/delhombre/cxa/internal/transfer"
+	"github.com/delhombre/cxa/internal/warnings"
 	"github.com/delhombre/cxa/pkg/codex"
 )
 
-// DirectoryRepository implements account.Repository using directories.
+// DirectoryRepository implements account.ContextRepository using directories.
 // This is much faster than zip-based storage.
 type DirectoryRepository struct {
-	paths *codex.Paths
+	paths    *codex.Paths
+	warnings *warnings.Store
+	history  *history.Log
+	policy   *policy.Policy
+	ioLimit  int64
+	log      *slog.Logger
+	progress func(Progress)
+	dedup    bool
+	resolve  sharing.Resolver
+
+	activation string
+	excludes   fscopy.Excludes
+	encrypt    bool
+	key        KeySource
+	unlocked   []byte
+	keyring    Keyring
+	storeAuth  bool
+	snapshots  int
+	restoring  string
+	cacheSize  int
+
+	trashRetention time.Duration
+
+	backend     Backend
+	backendName string
+
+	codexVersion     string
+	codexVersionOnce sync.Once
 }
 
-// NewDirectoryRepository creates a new directory-based repository.
+// NewDirectoryRepository creates a new directory-based repository at the
+// default locations.
 func NewDirectoryRepository() *DirectoryRepository {
+	return NewDirectoryRepositoryWithPaths(DefaultPaths())
+}
+
+// DefaultPaths returns the locations cxa works on: the defaults, as
+// relocated by the environment, the data directory and homes the cxa
+// config records, and the data directory the system policy pins.
+func DefaultPaths() *codex.Paths {
+	return codex.NewPathsWith(func(configFile string) codex.Settings {
+		var s codex.Settings
+		if cfg, err := config.Load(configFile); err == nil {
+			s.DataDir, s.Homes = cfg.DataDir, cfg.Homes
+		}
+		if p, err := policy.System(); err == nil {
+			s.PinnedDataDir = p.DataDir
+		}
+		return s
+	})
+}
+
+// NewDirectoryRepositoryWithPaths creates a directory-based repository
+// working on paths. The repository keeps paths and updates it when the
+// data directory moves, so callers sharing it see the same locations.
+func NewDirectoryRepositoryWithPaths(paths *codex.Paths) *DirectoryRepository {
+	// A policy that cannot be loaded refuses every store
+	sysPolicy, _ := policy.System()
 	return &DirectoryRepository{
-		paths: codex.NewPaths(),
+		paths:     paths,
+		warnings:  warnings.NewStore(paths.WarningsFile()),
+		history:   history.NewLog(paths.HistoryFile()),
+		policy:    sysPolicy,
+		log:       logging.Discard,
+		snapshots: DefaultSnapshots,
+
+		trashRetention: DefaultTrashRetention,
+	}
+}
+
+// Paths returns the locations the repository works on.
+func (r *DirectoryRepository) Paths() *codex.Paths {
+	return r.paths
+}
+
+// SetLogger sets where the repository reports the steps of its operations.
+func (r *DirectoryRepository) SetLogger(log *slog.Logger) {
+	r.log = log
+}
+
+// SetIOLimit caps the write throughput of Save and Activate copies in bytes
+// per second. Background operations use it to avoid saturating the disk
+// while codex is busy. Zero removes the limit.
+func (r *DirectoryRepository) SetIOLimit(bytesPerSec int64) {
+	r.ioLimit = bytesPerSec
+}
+
+// record appends e to the history log. History is informational, so a
+// failure to write it is kept as a warning rather than failing the
+// operation.
+func (r *DirectoryRepository) record(e history.Entry) {
+	if err := r.history.Append(e); err != nil {
+		r.warnings.Record("history", fmt.Sprintf("failed to record %s of '%s': %v", e.Op, e.Account, err))
+	}
+}
+
+// copyOptions returns the options for a new copy operation.
+func (r *DirectoryRepository) copyOptions() copyOptions {
+	return copyOptions{throttle: newIOThrottle(r.ioLimit)}
+}
+
+// lock serializes operations that change accounts or ~/.codex across cxa
+// processes. Every exported mutating method holds it for its duration.
+// Accounts left moved aside by a swap cut short by a crash are put back
+// first, as no other process can be in the middle of one.
+func (r *DirectoryRepository) lock() (func(), error) {
+	unlock, err := flock.Acquire(r.paths.LockFile(), flock.DefaultWait)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.recoverSwaps(); err != nil {
+		r.warnings.Record("storage", fmt.Sprintf("failed to recover accounts from an interrupted save: %v", err))
 	}
+	return unlock, nil
+}
+
+// SetShareResolver sets how a switch settles an account's sessions or
+// history that differ from the shared ones, when sharing is enabled. nil
+// moves them aside without asking.
+func (r *DirectoryRepository) SetShareResolver(resolve sharing.Resolver) {
+	r.resolve = resolve
+}
+
+// SetExcludes sets glob patterns, as described by fscopy.Excludes, naming
+// paths in ~/.codex that saving leaves out of the account.
+func (r *DirectoryRepository) SetExcludes(patterns []string) {
+	r.excludes = patterns
+}
+
+// updateOptions returns the options for a copy that replaces the account
+// at accountPath, reusing its unchanged files and leaving out excluded ones.
+func (r *DirectoryRepository) updateOptions(accountPath string) copyOptions {
+	opts := r.copyOptions()
+	opts.base = accountPath
+	opts.exclude = r.excludes
+	return opts
 }
 
-// List returns all saved accounts.
+// List returns all saved accounts. Metadata comes from the account index
+// where it is still current, and the index is brought up to date.
 func (r *DirectoryRepository) List() ([]*account.Account, error) {
 	accountsDir := r.paths.AccountsDir()
 	if err := r.paths.EnsureDirs(); err != nil {
@@ -43,21 +185,81 @@ func (r *DirectoryRepository) List() ([]*account.Account, error) {
 		return nil, err
 	}
 
+	idx := r.loadIndex()
+	fresh := &accountIndex{Version: indexVersion, Accounts: make(map[string]indexEntry)}
+	changed := false
+
 	var accounts []*account.Account
 	for _, entry := range entries {
-		if !entry.IsDir() {
+		if !entry.IsDir() || strings.HasSuffix(entry.Name(), saveStagingSuffix) {
+			continue
+		}
+		name := entry.Name()
+		if r.replacedCopy(name) {
 			continue
 		}
-		acc, err := r.Get(entry.Name())
+		s, stampErr := stamp(r.paths.AccountPath(name))
+		if cached, ok := idx.Accounts[name]; ok && stampErr == nil && cached.matches(s) {
+			accounts = append(accounts, cached.Account)
+			fresh.Accounts[name] = cached
+			continue
+		}
+
+		acc, err := r.Get(name)
 		if err != nil {
-			continue // Skip invalid accounts
+			// Skip invalid accounts
+			r.warnings.Record("storage", fmt.Sprintf("skipped invalid account '%s': %v", name, err))
+			continue
 		}
 		accounts = append(accounts, acc)
+		if stampErr == nil {
+			s.Account = acc
+			fresh.Accounts[name] = s
+			changed = true
+		}
 	}
 
+	if changed || len(fresh.Accounts) != len(idx.Accounts) {
+		r.saveIndex(fresh)
+	}
 	return accounts, nil
 }
 
+// replacedCopy reports whether the entry name in the accounts directory is
+// the previous copy of an account being swapped out, rather than an
+// account whose name happens to end the same way.
+func (r *DirectoryRepository) replacedCopy(name string) bool {
+	base, ok := strings.CutSuffix(name, replacedSuffix)
+	if !ok || base == "" {
+		return false
+	}
+	data, err := os.ReadFile(filepath.Join(r.paths.AccountPath(name), ".account.json"))
+	if err != nil {
+		return false
+	}
+	var acc account.Account
+	return json.Unmarshal(data, &acc) == nil && acc.Name == base
+}
+
+// recoverSwaps runs recoverSwap on every account moved aside.
+func (r *DirectoryRepository) recoverSwaps() error {
+	entries, err := os.ReadDir(r.paths.AccountsDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		if r.replacedCopy(entry.Name()) {
+			if err := recoverSwap(r.paths.AccountPath(strings.TrimSuffix(entry.Name(), replacedSuffix))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // Get retrieves an account by name.
 func (r *DirectoryRepository) Get(name string) (*account.Account, error) {
 	accountPath := r.paths.AccountPath(name)
@@ -80,19 +282,85 @@ func (r *DirectoryRepository) Get(name string) (*account.Account, error) {
 		return nil, err
 	}
 
+	// Metadata from a newer cxa is read as far as it is understood, and
+	// refused for writing by CheckWritable
+	var newer *schema.NewerError
+	if data, err = account.Format.Upgrade(data); err != nil && !errors.As(err, &newer) {
+		return nil, err
+	}
 	var acc account.Account
 	if err := json.Unmarshal(data, &acc); err != nil {
+		// A newer schema may have changed the type of a known field
+		if newer != nil {
+			return nil, &account.SchemaError{Name: name, Found: newer.Found}
+		}
 		return nil, err
 	}
 
+	acc.Compressed = packed(accountPath)
+	acc.Encrypted = sealed(accountPath)
 	return &acc, nil
 }
 
-// Save stores the current ~/.codex as the given account.
+// checkWritable refuses changes to an account written by a newer cxa.
+// Accounts that do not exist or have unreadable metadata are writable.
+func (r *DirectoryRepository) checkWritable(name string) error {
+	acc, err := r.Get(name)
+	if err != nil {
+		var schemaErr *account.SchemaError
+		if errors.As(err, &schemaErr) {
+			return err
+		}
+		return nil
+	}
+	return acc.CheckWritable()
+}
+
+// Save stores the current ~/.codex as the given account. Saving over a
+// locked account fails with an *account.ProtectedError.
 func (r *DirectoryRepository) Save(name string) (*account.Account, error) {
+	return r.save(context.Background(), name, false, nil)
+}
+
+// SaveForce is Save, overwriting the account even if it is locked.
+func (r *DirectoryRepository) SaveForce(name string) (*account.Account, error) {
+	return r.save(context.Background(), name, true, nil)
+}
+
+// SaveContext is Save, giving up when ctx is done. A save cut short leaves
+// the account as it was, and the next save resumes the copy.
+func (r *DirectoryRepository) SaveContext(ctx context.Context, name string) (*account.Account, error) {
+	return r.save(ctx, name, false, nil)
+}
+
+// SaveForceContext is SaveForce, giving up when ctx is done.
+func (r *DirectoryRepository) SaveForceContext(ctx context.Context, name string) (*account.Account, error) {
+	return r.save(ctx, name, true, nil)
+}
+
+// SaveSelected is SaveContext, or SaveForceContext with force, taking only
+// the paths of ~/.codex that sel picks: the rest of the account is left as
+// it was saved before, or left out of an account saved for the first time.
+func (r *DirectoryRepository) SaveSelected(ctx context.Context, name string, sel Selection, force bool) (*account.Account, error) {
+	return r.save(ctx, name, force, &sel)
+}
+
+func (r *DirectoryRepository) save(ctx context.Context, name string, force bool, sel *Selection) (*account.Account, error) {
+	unlock, err := r.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	if !r.paths.CodexExists() {
 		return nil, errors.New("~/.codex not found - please login first with 'codex login'")
 	}
+	if err := r.policy.CheckStore(r.encrypt); err != nil {
+		return nil, err
+	}
 
 	if err := r.paths.EnsureDirs(); err != nil {
 		return nil, err
@@ -100,86 +368,685 @@ func (r *DirectoryRepository) Save(name string) (*account.Account, error) {
 
 	accountPath := r.paths.AccountPath(name)
 
-	// Remove existing account data if exists
-	_ = os.RemoveAll(accountPath)
+	if err := r.checkWritable(name); err != nil {
+		return nil, err
+	}
 
-	// Copy ~/.codex to account directory
-	if err := copyDir(r.paths.Home, accountPath); err != nil {
-		return nil, fmt.Errorf("failed to save account: %w", err)
+	// Keep metadata from a previous save of this account
+	acc, err := r.Get(name)
+	if err != nil {
+		acc = account.NewAccount(name)
+	}
+	if acc.Protected && !force {
+		return nil, &account.ProtectedError{Name: name, Op: "save over"}
 	}
 
-	// Create account metadata
-	acc := &account.Account{
-		Name:      name,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	// Copy ~/.codex to account directory via staging, resuming an
+	// interrupted save if one is found
+	start := time.Now()
+	if r.isLinked(accountPath) {
+		// ~/.codex is this very directory; there is nothing to copy
+		r.log.Debug("saving linked account in place", "account", name)
+	} else {
+		live := r.liveDir()
+		r.snapshot(name, accountPath)
+		r.log.Debug("saving account", "account", name, "from", live, "to", accountPath)
+		opts := r.updateOptions(accountPath)
+		opts.ctx = ctx
+		opts.progress = r.newProgress(string(history.OpSave), name)
+		if sel != nil {
+			// What is carried over must be readable files
+			if err := r.unseal(name, accountPath); err != nil {
+				return nil, err
+			}
+			if err := r.expand(name, accountPath); err != nil {
+				return nil, err
+			}
+			opts.partial = sel
+		}
+		if err := copyStaged(live, accountPath, accountPath+saveStagingSuffix, opts); err != nil {
+			r.log.Error("save copy failed", "account", name, "err", err)
+			return nil, fmt.Errorf("failed to save account: %w", err)
+		}
+	}
+	// Credentials left as they were may be in the keyring
+	if sel == nil || sel.takes(authFile, false) {
+		if err := r.stowAuth(name, accountPath, acc); err != nil {
+			return nil, fmt.Errorf("failed to save account: %w", err)
+		}
+	}
+	if err := writeManifest(accountPath); err != nil {
+		return nil, fmt.Errorf("failed to record manifest: %w", err)
+	}
+
+	// Update account metadata
+	now := time.Now()
+	acc.Name = name
+	acc.UpdatedAt = now
+	acc.LastUsedAt = now
+
+	if version := r.installedCodexVersion(); version != "" {
+		acc.CodexVersion = version
+	}
+	if host, err := os.Hostname(); err == nil {
+		acc.Hostname = host
+	}
+	if m, err := readManifest(accountPath); err == nil && m != nil {
+		acc.Size, acc.Files = m.totals()
 	}
 
 	// Note: Email extraction from auth.json JWT could be added here
 
-	// Save metadata
-	metaPath := filepath.Join(accountPath, ".account.json")
-	metaData, _ := json.MarshalIndent(acc, "", "  ")
-	if err := os.WriteFile(metaPath, metaData, 0644); err != nil {
+	if err := r.writeMetadata(accountPath, acc); err != nil {
+		return nil, err
+	}
+	if err := r.sealSaved(name, accountPath); err != nil {
 		return nil, err
 	}
+	r.dedupAccount(name, accountPath)
 
 	// Update current account state
 	if err := r.saveState(name); err != nil {
 		return nil, err
 	}
+	r.record(history.Entry{Op: history.OpSave, Account: name})
+	r.stored(string(history.OpSave), name)
+	r.log.Debug("saved account", "account", name, "took", time.Since(start))
+
+	return acc, nil
+}
+
+// AccountDir returns the directory holding the stored account.
+func (r *DirectoryRepository) AccountDir(name string) (string, error) {
+	accountPath := r.paths.AccountPath(name)
+	if _, err := os.Stat(accountPath); os.IsNotExist(err) {
+		return "", fmt.Errorf("account '%s' not found", name)
+	}
+	return accountPath, nil
+}
+
+// Import registers the account contained in an export archive under name,
+// replacing any existing account of that name. Metadata is fresh except for
+// the email carried by the archive. Importing over a locked account fails
+// with an *account.ProtectedError, and importing over the current account
+// refreshes ~/.codex from the import, so the next switch does not save
+// the old contents back over it.
+func (r *DirectoryRepository) Import(name string, archive io.Reader) (*account.Account, error) {
+	return r.importArchive(name, archive, false)
+}
+
+// ImportForce is Import, replacing the account even if it is locked.
+func (r *DirectoryRepository) ImportForce(name string, archive io.Reader) (*account.Account, error) {
+	return r.importArchive(name, archive, true)
+}
+
+func (r *DirectoryRepository) importArchive(name string, archive io.Reader, force bool) (*account.Account, error) {
+	unlock, err := r.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if err := account.ValidateName(name); err != nil {
+		return nil, err
+	}
+	if err := r.policy.CheckStore(r.encrypt); err != nil {
+		return nil, err
+	}
+	if err := r.paths.EnsureDirs(); err != nil {
+		return nil, err
+	}
+
+	if err := r.checkWritable(name); err != nil {
+		return nil, err
+	}
+	if existing, err := r.Get(name); err == nil && existing.Protected && !force {
+		return nil, &account.ProtectedError{Name: name, Op: "import over"}
+	}
+
+	accountPath := r.paths.AccountPath(name)
+	staging := accountPath + saveStagingSuffix
+	if err := os.RemoveAll(staging); err != nil {
+		return nil, err
+	}
+
+	manifest, err := transfer.Extract(archive, staging)
+	if err != nil {
+		_ = os.RemoveAll(staging)
+		return nil, err
+	}
+
+	acc := account.NewAccount(name)
+	acc.Email = manifest.Account.Email
+
+	if err := r.stowAuth(name, staging, acc); err != nil {
+		_ = os.RemoveAll(staging)
+		return nil, err
+	}
+	if err := writeManifest(staging); err != nil {
+		_ = os.RemoveAll(staging)
+		return nil, fmt.Errorf("failed to record manifest: %w", err)
+	}
+	if err := r.writeMetadata(staging, acc); err != nil {
+		_ = os.RemoveAll(staging)
+		return nil, err
+	}
+
+	r.snapshot(name, accountPath)
+	if err := os.RemoveAll(r.paths.CachePath(name)); err != nil {
+		_ = os.RemoveAll(staging)
+		return nil, err
+	}
+	if err := swapIn(staging, accountPath); err != nil {
+		_ = os.RemoveAll(staging)
+		return nil, err
+	}
+	if err := r.sealSaved(name, accountPath); err != nil {
+		return nil, err
+	}
+	r.dedupAccount(name, accountPath)
+	r.stored("import", name)
+
+	if current, _ := r.Current(); current == name {
+		if err := r.Activate(name); err != nil {
+			return nil, fmt.Errorf("imported '%s', but failed to refresh ~/.codex: %w", name, err)
+		}
+	}
+	return acc, nil
+}
+
+// UpdateMetadata applies update to the stored metadata of an account and
+// saves it, leaving the account's files untouched.
+func (r *DirectoryRepository) UpdateMetadata(name string, update func(*account.Account)) (*account.Account, error) {
+	unlock, err := r.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	accountPath, err := r.AccountDir(name)
+	if err != nil {
+		return nil, err
+	}
 
+	acc, err := r.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := acc.CheckWritable(); err != nil {
+		return nil, err
+	}
+
+	update(acc)
+	acc.Name = name
+	acc.UpdatedAt = time.Now()
+	if err := r.writeMetadata(accountPath, acc); err != nil {
+		return nil, err
+	}
+	r.stored("metadata", name)
 	return acc, nil
 }
 
-// Delete removes an account.
+// RemoveItems deletes the given top-level items (such as auth.json) from a
+// saved account, leaving the rest of it in place. It returns the items that
+// were present.
+func (r *DirectoryRepository) RemoveItems(name string, items ...string) ([]string, error) {
+	unlock, err := r.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	accountPath, err := r.AccountDir(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.checkWritable(name); err != nil {
+		return nil, err
+	}
+
+	removed, err := removeItems(accountPath, items)
+	if err != nil || len(removed) == 0 {
+		return removed, err
+	}
+	if err := writeManifest(accountPath); err != nil {
+		return removed, fmt.Errorf("failed to record manifest: %w", err)
+	}
+
+	acc, err := r.Get(name)
+	if err != nil {
+		return removed, err
+	}
+	acc.UpdatedAt = time.Now()
+	return removed, r.writeMetadata(accountPath, acc)
+}
+
+// RemoveActiveItems deletes the given top-level items from the live
+// ~/.codex. It returns the items that were present.
+func (r *DirectoryRepository) RemoveActiveItems(items ...string) ([]string, error) {
+	unlock, err := r.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if !r.paths.CodexExists() {
+		return nil, errors.New("~/.codex not found")
+	}
+	return removeItems(r.paths.Home, items)
+}
+
+func removeItems(dir string, items []string) ([]string, error) {
+	var removed []string
+	for _, item := range items {
+		path := filepath.Join(dir, item)
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, item)
+	}
+	return removed, nil
+}
+
+// Delete removes an account. Deleting a locked account fails with an
+// *account.ProtectedError.
 func (r *DirectoryRepository) Delete(name string) error {
+	return r.delete(context.Background(), name, false)
+}
+
+// DeleteForce is Delete, removing the account even if it is locked.
+func (r *DirectoryRepository) DeleteForce(name string) error {
+	return r.delete(context.Background(), name, true)
+}
+
+// DeleteContext is Delete, giving up when ctx is done before the account
+// is removed.
+func (r *DirectoryRepository) DeleteContext(ctx context.Context, name string) error {
+	return r.delete(ctx, name, false)
+}
+
+func (r *DirectoryRepository) delete(ctx context.Context, name string, force bool) error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	accountPath := r.paths.AccountPath(name)
 	if _, err := os.Stat(accountPath); os.IsNotExist(err) {
 		return fmt.Errorf("account '%s' not found", name)
 	}
-	return os.RemoveAll(accountPath)
+	if err := r.checkWritable(name); err != nil {
+		return err
+	}
+	if acc, err := r.Get(name); err == nil && acc.Protected && !force {
+		return &account.ProtectedError{Name: name, Op: "delete"}
+	}
+	if r.isLinked(accountPath) {
+		return fmt.Errorf("account '%s' is linked as ~/.codex; switch to another account before deleting it", name)
+	}
+	if err := os.RemoveAll(r.paths.CachePath(name)); err != nil {
+		return err
+	}
+	acc, _ := r.Get(name)
+	if r.trashRetention > 0 {
+		if err := r.trash(name, accountPath, acc); err != nil {
+			return fmt.Errorf("failed to move '%s' to the trash: %w", name, err)
+		}
+		r.purgeExpiredTrash()
+	} else {
+		if err := os.RemoveAll(accountPath); err != nil {
+			return err
+		}
+		if err := os.RemoveAll(r.paths.SnapshotsDir(name)); err != nil {
+			r.warnings.Record("snapshot", fmt.Sprintf("failed to remove the snapshots of deleted account '%s': %v", name, err))
+		}
+		if acc != nil && acc.Keychain && r.keyring != nil {
+			if err := r.keyring.Delete(name); err != nil {
+				r.warnings.Record("keychain", fmt.Sprintf("failed to remove the credentials of deleted account '%s': %v", name, err))
+			}
+		}
+	}
+	r.forgetIndexed(name)
+	r.record(history.Entry{Op: history.OpDelete, Account: name})
+	r.removed(string(history.OpDelete), name)
+	r.log.Debug("deleted account", "account", name)
+	return nil
+}
+
+// Rename changes an account's name, keeping its data and metadata, and
+// updates the tracked state to match.
+func (r *DirectoryRepository) Rename(oldName, newName string) error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := account.ValidateName(newName); err != nil {
+		return err
+	}
+
+	oldPath := r.paths.AccountPath(oldName)
+	if _, err := os.Stat(oldPath); os.IsNotExist(err) {
+		return fmt.Errorf("account '%s' not found", oldName)
+	}
+	newPath := r.paths.AccountPath(newName)
+	if _, err := os.Stat(newPath); err == nil {
+		return fmt.Errorf("account '%s' already exists", newName)
+	}
+
+	acc, err := r.Get(oldName)
+	if err != nil {
+		return err
+	}
+	if err := acc.CheckWritable(); err != nil {
+		return err
+	}
+
+	// Credentials in the keyring are filed under the account name
+	if acc.Keychain {
+		auth, err := r.keyringAuth(oldName)
+		if err != nil {
+			return err
+		}
+		if err := r.keyring.Set(newName, auth); err != nil {
+			return err
+		}
+	}
+
+	linked := r.isLinked(oldPath)
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return err
+	}
+	if acc.Keychain {
+		if err := r.keyring.Delete(oldName); err != nil {
+			r.warnings.Record("keychain", fmt.Sprintf("failed to remove the credentials filed under '%s': %v", oldName, err))
+		}
+	}
+	if linked {
+		if err := r.linkHome(newPath); err != nil {
+			return fmt.Errorf("renamed, but failed to relink ~/.codex: %w", err)
+		}
+	}
+	_ = os.RemoveAll(r.paths.CachePath(oldName))
+	if err := os.Rename(r.paths.SnapshotsDir(oldName), r.paths.SnapshotsDir(newName)); err != nil && !os.IsNotExist(err) {
+		r.warnings.Record("snapshot", fmt.Sprintf("failed to move the snapshots of '%s' to '%s': %v", oldName, newName, err))
+	}
+
+	acc.Name = newName
+	acc.UpdatedAt = time.Now()
+	if err := r.writeMetadata(newPath, acc); err != nil {
+		return err
+	}
+	r.record(history.Entry{Op: history.OpRename, Account: newName, From: oldName})
+	r.removed(string(history.OpRename), oldName)
+	r.stored(string(history.OpRename), newName)
+	r.log.Debug("renamed account", "from", oldName, "to", newName)
+
+	state, _ := r.loadState()
+	if state.Current != oldName && state.Previous != oldName {
+		return nil
+	}
+	if state.Current == oldName {
+		state.Current = newName
+	}
+	if state.Previous == oldName {
+		state.Previous = newName
+	}
+	return r.SetState(state)
 }
 
 // Activate switches to the given account.
 func (r *DirectoryRepository) Activate(name string) error {
+	return r.ActivateContext(context.Background(), name)
+}
+
+// ActivateContext is Activate, giving up when ctx is done. A switch cut
+// short leaves ~/.codex as it was, and the next switch resumes the copy.
+func (r *DirectoryRepository) ActivateContext(ctx context.Context, name string) error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := account.ValidateName(name); err != nil {
+		return err
+	}
+
+	r.fetch(name)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	accountPath := r.paths.AccountPath(name)
 	if _, err := os.Stat(accountPath); os.IsNotExist(err) {
 		return fmt.Errorf("account '%s' not found", name)
 	}
+	if acc, err := r.Get(name); err == nil && acc.Archived {
+		return fmt.Errorf("account '%s' is archived; unarchive it first with cxa unarchive %s", name, name)
+	}
+	if err := r.expand(name, accountPath); err != nil {
+		return err
+	}
+
+	// Read credentials kept in the keychain up front, so a locked or
+	// missing keychain fails the switch before anything changes
+	auth, err := r.keyringAuth(name)
+	if err != nil {
+		return err
+	}
+
+	// An encrypted account is decrypted into a scratch directory, so the
+	// stored copy stays sealed
+	src := accountPath
+	if sealed(accountPath) {
+		if r.activation == ActivateLink {
+			return fmt.Errorf("account '%s' is encrypted and cannot be linked to ~/.codex; decrypt it first with cxa decrypt %s", name, name)
+		}
+		if src, err = r.unsealScratch(name, accountPath); err != nil {
+			return err
+		}
+		defer os.RemoveAll(src)
+	}
+
+	// Put back a ~/.codex left aside by an interrupted switch, so it is
+	// saved below rather than lost
+	if err := recoverSwap(r.paths.Home); err != nil {
+		return fmt.Errorf("failed to recover ~/.codex: %w", err)
+	}
+
+	// Refuse to move sharing from under a running Codex, or into a
+	// directory that cannot be written, before anything changes
+	preflight := sharing.NewManagerWithPaths(r.paths)
+	if err := preflight.LoadConfig(); err == nil {
+		if err := preflight.Preflight(name); err != nil {
+			return fmt.Errorf("cannot switch to '%s': %w", name, err)
+		}
+	}
 
 	// Get current account to save it first
+	start := time.Now()
 	current, _ := r.Current()
+	r.log.Debug("activating account", "account", name, "current", current)
 	if current != "" && current != name {
-		// Save current state before switching
-		if r.paths.CodexExists() {
-			if _, err := r.Save(current); err != nil {
-				return fmt.Errorf("failed to save current account: %w", err)
+		// Hand what the current account shares over before it is saved
+		// and replaced
+		shareManager := sharing.NewManagerWithPaths(r.paths)
+		if err := shareManager.LoadConfig(); err == nil {
+			if err := shareManager.Reconcile(current); err != nil {
+				r.warnings.Record("sharing", fmt.Sprintf("failed to reconcile what '%s' shares: %v", current, err))
 			}
 		}
-	}
 
-	// Remove current ~/.codex
-	if err := os.RemoveAll(r.paths.Home); err != nil {
-		return fmt.Errorf("failed to clear ~/.codex: %w", err)
+		// Save current state before switching
+		if err := r.saveActive(ctx, current); err != nil {
+			return fmt.Errorf("failed to save current account: %w", err)
+		}
 	}
 
-	// Copy account to ~/.codex
-	if err := copyDir(accountPath, r.paths.Home); err != nil {
-		return fmt.Errorf("failed to activate account: %w", err)
+	// Link ~/.codex to the account, or swap in a warm cache entry if there
+	// is one, or else copy the account to ~/.codex via staging, resuming an
+	// interrupted switch if one is found
+	activated := false
+	if r.activation == ActivateLink {
+		// Codex writes into a linked account, so nothing may share its files
+		if err := unshareSessions(accountPath); err != nil {
+			return fmt.Errorf("failed to activate account: %w", err)
+		}
+		if err := r.linkHome(accountPath); err != nil {
+			r.log.Error("activate link failed", "account", name, "err", err)
+			return fmt.Errorf("failed to activate account: %w", err)
+		}
+		activated = true
+		r.log.Debug("linked ~/.codex to account", "account", name)
+	} else if cached, ok := r.cachedStaging(name); ok {
+		opts := r.copyOptions()
+		opts.ctx = ctx
+		opts.progress = r.newProgress(string(history.OpSwitch), name)
+		if err := copyStaged(accountPath, r.paths.Home, cached, opts); err == nil {
+			activated = true
+			r.log.Debug("swapped in warm cache entry", "account", name)
+		} else if ctx.Err() != nil {
+			return fmt.Errorf("failed to activate account: %w", err)
+		} else {
+			// The cache may sit on another filesystem; fall back to a copy
+			r.log.Warn("warm cache swap failed, copying instead", "account", name, "err", err)
+			_ = os.RemoveAll(cached)
+		}
+	}
+	if !activated {
+		opts := r.copyOptions()
+		opts.ctx = ctx
+		opts.progress = r.newProgress(string(history.OpSwitch), name)
+		if err := copyStaged(src, r.paths.Home, r.paths.Home+activateStagingSuffix, opts); err != nil {
+			r.log.Error("activate copy failed", "account", name, "err", err)
+			return fmt.Errorf("failed to activate account: %w", err)
+		}
+	}
+	if auth != nil {
+		if err := os.WriteFile(filepath.Join(r.paths.Home, authFile), auth, 0600); err != nil {
+			return fmt.Errorf("failed to write credentials: %w", err)
+		}
 	}
 
-	// Re-setup sharing symlinks if enabled
-	shareManager := sharing.NewManager()
+	// Re-setup sharing symlinks if enabled, and check that they work,
+	// repairing them once if not
+	var shareErr error
+	shareManager := sharing.NewManagerWithPaths(r.paths)
+	shareManager.SetResolver(r.resolve)
 	if err := shareManager.LoadConfig(); err == nil && shareManager.IsEnabled() {
-		_ = shareManager.SetupSymlinks()
+		if err := shareManager.SetupSymlinks(name); err != nil {
+			r.log.Warn("failed to restore sharing", "account", name, "err", err)
+		}
+		if err := shareManager.Verify(name); err != nil {
+			r.log.Warn("sharing is not intact, repairing", "account", name, "err", err)
+			if _, shareErr = shareManager.Repair(name); shareErr == nil {
+				shareErr = shareManager.Verify(name)
+			}
+		}
+		if shareErr != nil {
+			r.warnings.Record("sharing", fmt.Sprintf("sharing is broken after switching to '%s': %v", name, shareErr))
+		}
+	}
+
+	// Record when the account was last used, unless a newer cxa owns its
+	// metadata
+	if acc, err := r.Get(name); err == nil && acc.CheckWritable() == nil {
+		acc.LastUsedAt = time.Now()
+		if err := r.writeMetadata(r.paths.AccountPath(name), acc); err != nil {
+			return err
+		}
 	}
 
 	// Update state
 	if err := r.saveState(name); err != nil {
 		return err
 	}
+	entry := history.Entry{Op: history.OpSwitch, Account: name}
+	if current != name {
+		entry.From = current
+	}
+	r.record(entry)
+	r.log.Debug("activated account", "account", name, "took", time.Since(start))
+
+	// The account left is now among the most recently used, and the one
+	// switched to no longer needs staging
+	r.refreshCache()
+
+	if shareErr != nil {
+		return fmt.Errorf("switched to '%s', but sharing is broken and could not be repaired: %w (see cxa share status)", name, shareErr)
+	}
+	return nil
+}
 
+// installedCodexVersion returns the version of the installed Codex CLI, or
+// "" if it cannot be determined. It is probed once per repository.
+func (r *DirectoryRepository) installedCodexVersion() string {
+	r.codexVersionOnce.Do(func() {
+		version, err := codex.Version()
+		if err != nil {
+			r.log.Debug("codex version unknown", "err", err)
+			return
+		}
+		r.codexVersion = version
+	})
+	return r.codexVersion
+}
+
+// CodexMismatch reports whether the account was saved with a Codex CLI
+// version meaningfully different from the installed one, whose config or
+// session layout may have changed in between. It returns both versions.
+func (r *DirectoryRepository) CodexMismatch(name string) (saved, installed string, mismatch bool) {
+	acc, err := r.Get(name)
+	if err != nil || acc.CodexVersion == "" {
+		return "", "", false
+	}
+	installed = r.installedCodexVersion()
+	if installed == "" {
+		return acc.CodexVersion, "", false
+	}
+	return acc.CodexVersion, installed, !codex.VersionsCompatible(acc.CodexVersion, installed)
+}
+
+// saveActive saves ~/.codex into the current account before cxa replaces
+// or copies it. A locked account keeps its stored copy as it was, so its
+// live changes are dropped, which is the point of locking it.
+func (r *DirectoryRepository) saveActive(ctx context.Context, current string) error {
+	if !r.paths.CodexExists() {
+		return nil
+	}
+	if acc, err := r.Get(current); err == nil && acc.Protected {
+		r.log.Debug("not saving locked account", "account", current)
+		return nil
+	}
+	_, err := r.save(ctx, current, false, nil)
+	return err
+}
+
+// writeMetadata stores acc as the .account.json of the account at dir.
+func (r *DirectoryRepository) writeMetadata(dir string, acc *account.Account) error {
+	if err := acc.CheckWritable(); err != nil {
+		return err
+	}
+	acc.SchemaVersion = account.SchemaVersion
+
+	data, err := json.MarshalIndent(acc, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".account.json"), data, 0644); err != nil {
+		return err
+	}
+	r.forgetIndexed(acc.Name)
 	return nil
 }
 
@@ -194,27 +1061,45 @@ func (r *DirectoryRepository) Current() (string, error) {
 
 // State tracks the current and previous accounts.
 type State struct {
+	// SchemaVersion is the state.json schema the file was written with.
+	SchemaVersion int `json:"schema_version"`
+
 	Current  string `json:"current"`
 	Previous string `json:"previous"`
 }
 
-func (r *DirectoryRepository) loadState() (*State, error) {
-	data, err := os.ReadFile(r.paths.StateFile())
-	if err != nil {
-		return &State{}, nil
-	}
-	var state State
-	if err := json.Unmarshal(data, &state); err != nil {
-		return &State{}, nil
-	}
-	return &state, nil
+// stateSchemaVersion is the newest state.json schema this build
+// understands.
+const stateSchemaVersion = 1
+
+// StateFormat is the state.json file format. Add a migration with every
+// bump of stateSchemaVersion.
+var StateFormat = &schema.Format{
+	Name:    "state.json",
+	Version: stateSchemaVersion,
+	Migrations: []schema.Migration{
+		// 0 to 1: schema_version was added
+		nil,
+	},
 }
 
-func (r *DirectoryRepository) saveState(current string) error {
-	state, _ := r.loadState()
-	state.Previous = state.Current
-	state.Current = current
+// State returns the tracked current and previous accounts.
+func (r *DirectoryRepository) State() (*State, error) {
+	return r.loadState()
+}
 
+// SetState overwrites the tracked current and previous accounts.
+func (r *DirectoryRepository) SetState(state *State) error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := StateFormat.CheckWritable(r.paths.StateFile()); err != nil {
+		return err
+	}
+	state.SchemaVersion = stateSchemaVersion
 	data, err := json.MarshalIndent(state, "", "  ")
 	if err != nil {
 		return err
@@ -224,60 +1109,127 @@ func (r *DirectoryRepository) saveState(current string) error {
 		return err
 	}
 
-	return os.WriteFile(r.paths.StateFile(), data, 0644)
+	return writeFileAtomic(r.paths.StateFile(), data, 0644)
 }
 
-// copyDir recursively copies a directory.
-func copyDir(src, dst string) error {
-	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Calculate destination path
-		relPath, err := filepath.Rel(src, path)
-		if err != nil {
-			return err
-		}
-		dstPath := filepath.Join(dst, relPath)
+// loadState reads state.json. A missing file is an empty state; one that
+// cannot be read is rebuilt with recoverState.
+func (r *DirectoryRepository) loadState() (*State, error) {
+	state, err := r.readState()
+	if err != nil {
+		return r.recoverState(err), nil
+	}
+	return state, nil
+}
 
-		// Handle symlinks
-		if info.Mode()&os.ModeSymlink != 0 {
-			link, err := os.Readlink(path)
-			if err != nil {
-				return err
-			}
-			return os.Symlink(link, dstPath)
-		}
+func (r *DirectoryRepository) readState() (*State, error) {
+	data, err := os.ReadFile(r.paths.StateFile())
+	if os.IsNotExist(err) {
+		return &State{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// A newer state.json is read as far as it is understood; SetState
+	// refuses to overwrite it
+	var newer *schema.NewerError
+	if data, err = StateFormat.Upgrade(data); err != nil && !errors.As(err, &newer) {
+		return nil, err
+	}
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
 
-		// Handle directories
-		if info.IsDir() {
-			return os.MkdirAll(dstPath, info.Mode())
-		}
+// recoverState rebuilds a state.json that cannot be read, from the
+// account ~/.codex is linked to or was copied from, and the order in which
+// accounts were last used. The unreadable file is kept beside the new one
+// as state.json.corrupt.
+func (r *DirectoryRepository) recoverState(cause error) *State {
+	unlock, err := r.lock()
+	if err != nil {
+		// Someone else holds the lock, and may be fixing the file; answer
+		// from the accounts without writing anything
+		return r.rebuildState()
+	}
+	defer unlock()
+	if state, err := r.readState(); err == nil {
+		return state
+	}
 
-		// Copy file
-		return copyFile(path, dstPath)
-	})
+	state := r.rebuildState()
+	path := r.paths.StateFile()
+	_ = os.Rename(path, path+".corrupt")
+	if err := r.SetState(state); err != nil {
+		r.log.Warn("failed to write rebuilt state", "err", err)
+	}
+	r.log.Warn("rebuilt unreadable state.json", "err", cause, "current", state.Current)
+	r.warnings.Record("state", fmt.Sprintf("state.json could not be read (%v) and was rebuilt; the current account is now '%s'", cause, state.Current))
+	return state
 }
 
-func copyFile(src, dst string) error {
-	srcFile, err := os.Open(src)
+// RebuildState works out the current and previous accounts again from the
+// account directories, and writes them to state.json.
+func (r *DirectoryRepository) RebuildState() (*State, error) {
+	unlock, err := r.lock()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer srcFile.Close()
+	defer unlock()
 
-	srcInfo, err := srcFile.Stat()
+	state := r.rebuildState()
+	if err := r.SetState(state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// rebuildState works out the current and previous accounts from the
+// account directories.
+func (r *DirectoryRepository) rebuildState() *State {
+	state := &State{}
+	if target, ok := r.homeLink(); ok {
+		if name := filepath.Base(target); r.isLinked(r.paths.AccountPath(name)) {
+			state.Current = name
+		}
+	} else if data, err := os.ReadFile(filepath.Join(r.paths.Home, ".account.json")); err == nil {
+		// Copying an account into ~/.codex takes its metadata along
+		var acc account.Account
+		if json.Unmarshal(data, &acc) == nil && acc.Name != "" {
+			if _, err := os.Stat(r.paths.AccountPath(acc.Name)); err == nil {
+				state.Current = acc.Name
+			}
+		}
+	}
+
+	accounts, err := r.List()
 	if err != nil {
-		return err
+		return state
 	}
+	account.SortRecent(accounts)
+	for _, acc := range accounts {
+		if acc.Name != state.Current {
+			state.Previous = acc.Name
+			break
+		}
+	}
+	return state
+}
 
-	dstFile, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, srcInfo.Mode())
+// saveState records current as the current account, and the account it
+// replaces as the previous one.
+func (r *DirectoryRepository) saveState(current string) error {
+	unlock, err := r.lock()
 	if err != nil {
 		return err
 	}
-	defer dstFile.Close()
+	defer unlock()
 
-	_, err = io.Copy(dstFile, srcFile)
-	return err
+	state, _ := r.loadState()
+	state.Previous = state.Current
+	state.Current = current
+
+	return r.SetState(state)
 }
@@ -1,13 +1,54 @@
 package storage_test
 
 import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
 	"testing"
+	"time"
 
+	"github.com/delhombre/cxa/internal/account"
+	"github.com/delhombre/cxa/internal/crypt"
+	"github.com/delhombre/cxa/internal/history"
+	"github.com/delhombre/cxa/internal/policy"
+	"github.com/delhombre/cxa/internal/remote"
+	"github.com/delhombre/cxa/internal/schema"
+	"github.com/delhombre/cxa/internal/sharing"
 	"github.com/delhombre/cxa/internal/storage"
+	"github.com/delhombre/cxa/internal/transfer"
+	"github.com/delhombre/cxa/internal/warnings"
+	"github.com/delhombre/cxa/pkg/codex"
 )
 
+// TestMain keeps every directory cxa works on under the HOME each test
+// sets, so that no test reaches the real ~/.codex or cxa data.
+func TestMain(m *testing.M) {
+	for _, key := range []string{"XDG_DATA_HOME", "XDG_STATE_HOME", codex.EnvCodexHome, codex.EnvDataDir, codex.EnvStateDir, codex.EnvHome} {
+		os.Unsetenv(key)
+	}
+	os.Exit(m.Run())
+}
+
+// dataPath joins elem to the data directory a repository uses under the
+// current HOME.
+func dataPath(elem ...string) string {
+	return filepath.Join(append([]string{storage.DefaultPaths().DataDir}, elem...)...)
+}
+
+// statePath joins elem to the state directory a repository uses under the
+// current HOME.
+func statePath(elem ...string) string {
+	return filepath.Join(append([]string{storage.DefaultPaths().StateDir}, elem...)...)
+}
+
 func TestDirectoryRepository_SaveAndList(t *testing.T) {
 	// Setup temp directories
 	tmpDir := t.TempDir()
@@ -26,12 +67,11 @@ func TestDirectoryRepository_SaveAndList(t *testing.T) {
 		t.Fatalf("failed to write auth file: %v", err)
 	}
 
-	// Override paths (would need to inject paths in real implementation)
-	os.Setenv("HOME", tmpDir)
-	defer os.Unsetenv("HOME")
+	// Point HOME elsewhere: the repository must only use its paths
+	t.Setenv("HOME", t.TempDir())
 
 	// Create repository
-	repo := storage.NewDirectoryRepository()
+	repo := storage.NewDirectoryRepositoryWithPaths(codex.NewPathsAt(tmpDir))
 
 	// Save account
 	acc, err := repo.Save("test-account")
@@ -42,6 +82,12 @@ func TestDirectoryRepository_SaveAndList(t *testing.T) {
 	if acc.Name != "test-account" {
 		t.Errorf("expected name 'test-account', got '%s'", acc.Name)
 	}
+	if host, _ := os.Hostname(); acc.Hostname != host {
+		t.Errorf("expected hostname %q, got %q", host, acc.Hostname)
+	}
+	if acc.Size != int64(len(`{"test": true}`)) || acc.Files != 1 {
+		t.Errorf("expected 1 file of %d bytes, got %d files of %d bytes", len(`{"test": true}`), acc.Files, acc.Size)
+	}
 
 	// List accounts
 	accounts, err := repo.List()
@@ -155,3 +201,2888 @@ func TestDirectoryRepository_Delete(t *testing.T) {
 		}
 	}
 }
+
+func TestDirectoryRepository_SaveDiscardsStaleStaging(t *testing.T) {
+	tmpDir := t.TempDir()
+	homeDir := filepath.Join(tmpDir, ".codex")
+	t.Setenv("HOME", tmpDir)
+
+	if err := os.MkdirAll(filepath.Join(homeDir, "sessions"), 0755); err != nil {
+		t.Fatalf("failed to create sessions dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(homeDir, "sessions", "a.json"), []byte("session-a"), 0644); err != nil {
+		t.Fatalf("failed to write session: %v", err)
+	}
+
+	// Simulate a save that was interrupted before its journal was written
+	staging := dataPath("accounts", "work.saving")
+	if err := os.MkdirAll(staging, 0755); err != nil {
+		t.Fatalf("failed to create staging dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(staging, "stale.txt"), []byte("stale"), 0644); err != nil {
+		t.Fatalf("failed to write stale file: %v", err)
+	}
+
+	repo := storage.NewDirectoryRepository()
+
+	// Staging directories are never listed as accounts
+	accounts, err := repo.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(accounts) != 0 {
+		t.Errorf("expected 0 accounts, got %d", len(accounts))
+	}
+
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	accountDir := dataPath("accounts", "work")
+	data, err := os.ReadFile(filepath.Join(accountDir, "sessions", "a.json"))
+	if err != nil {
+		t.Fatalf("failed to read saved session: %v", err)
+	}
+	if string(data) != "session-a" {
+		t.Errorf("expected 'session-a', got '%s'", string(data))
+	}
+
+	if _, err := os.Stat(filepath.Join(accountDir, "stale.txt")); !os.IsNotExist(err) {
+		t.Error("stale staging content should not end up in the account")
+	}
+	if _, err := os.Stat(staging); !os.IsNotExist(err) {
+		t.Error("staging directory should be removed after a successful save")
+	}
+}
+
+func TestDirectoryRepository_ScanHomesAndImportDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	backups := filepath.Join(tmpDir, "backups")
+
+	writeHome := func(dir, auth string) {
+		if err := os.MkdirAll(filepath.Join(backups, dir), 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", dir, err)
+		}
+		if err := os.WriteFile(filepath.Join(backups, dir, "auth.json"), []byte(auth), 0644); err != nil {
+			t.Fatalf("failed to write auth for %s: %v", dir, err)
+		}
+	}
+	writeHome("codex-work", `{"id": "work"}`)
+	writeHome("codex-work-old", `{"id": "work"}`)
+	writeHome(".codex_personal", `{"id": "personal"}`)
+	if err := os.MkdirAll(filepath.Join(backups, "photos"), 0755); err != nil {
+		t.Fatalf("failed to create unrelated dir: %v", err)
+	}
+
+	os.Setenv("HOME", tmpDir)
+	defer os.Unsetenv("HOME")
+
+	repo := storage.NewDirectoryRepository()
+
+	candidates, err := repo.ScanHomes(backups)
+	if err != nil {
+		t.Fatalf("ScanHomes failed: %v", err)
+	}
+	if len(candidates) != 3 {
+		t.Fatalf("expected 3 candidates, got %d", len(candidates))
+	}
+
+	names := make(map[string]bool)
+	duplicates := 0
+	for _, c := range candidates {
+		if c.DuplicateOf != "" {
+			duplicates++
+			continue
+		}
+		names[c.Name] = true
+		if _, err := repo.ImportDir(c.Name, c.Path); err != nil {
+			t.Fatalf("ImportDir(%s) failed: %v", c.Name, err)
+		}
+	}
+
+	if duplicates != 1 {
+		t.Errorf("expected 1 duplicate, got %d", duplicates)
+	}
+	if !names["work"] || !names["personal"] {
+		t.Errorf("expected names 'work' and 'personal', got %v", names)
+	}
+
+	// A second scan finds everything already imported
+	candidates, err = repo.ScanHomes(backups)
+	if err != nil {
+		t.Fatalf("ScanHomes failed: %v", err)
+	}
+	for _, c := range candidates {
+		if c.DuplicateOf == "" {
+			t.Errorf("%s should be a duplicate of an imported account", c.Path)
+		}
+	}
+}
+
+func TestDirectoryRepository_FindPrunable(t *testing.T) {
+	tmpDir := t.TempDir()
+	homeDir := filepath.Join(tmpDir, ".codex")
+	t.Setenv("HOME", tmpDir)
+	accountsDir := dataPath("accounts")
+
+	if err := os.MkdirAll(homeDir, 0755); err != nil {
+		t.Fatalf("failed to create home dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(homeDir, "auth.json"), []byte(`{}`), 0644); err != nil {
+		t.Fatalf("failed to write auth file: %v", err)
+	}
+
+	repo := storage.NewDirectoryRepository()
+
+	if _, err := repo.Save("active"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// Broken and stale accounts
+	if err := os.MkdirAll(filepath.Join(accountsDir, "empty"), 0755); err != nil {
+		t.Fatalf("failed to create empty account: %v", err)
+	}
+	writeAccount := func(name, meta string) {
+		dir := filepath.Join(accountsDir, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "auth.json"), []byte(`{}`), 0644); err != nil {
+			t.Fatalf("failed to write auth for %s: %v", name, err)
+		}
+		if meta != "" {
+			if err := os.WriteFile(filepath.Join(dir, ".account.json"), []byte(meta), 0644); err != nil {
+				t.Fatalf("failed to write metadata for %s: %v", name, err)
+			}
+		}
+	}
+	writeAccount("no-meta", "")
+	writeAccount("corrupt", "{not json")
+	writeAccount("stale", `{"name": "stale", "last_used_at": "2020-01-01T00:00:00Z"}`)
+
+	candidates, err := repo.FindPrunable(30 * 24 * time.Hour)
+	if err != nil {
+		t.Fatalf("FindPrunable failed: %v", err)
+	}
+
+	got := make(map[string]string)
+	for _, c := range candidates {
+		got[c.Name] = c.Reason
+	}
+
+	for _, name := range []string{"empty", "no-meta", "corrupt", "stale"} {
+		if _, ok := got[name]; !ok {
+			t.Errorf("expected %s to be a prune candidate", name)
+		}
+	}
+	if _, ok := got["active"]; ok {
+		t.Error("the active account should not be a prune candidate")
+	}
+
+	// Without a staleness window only broken accounts are reported
+	candidates, err = repo.FindPrunable(0)
+	if err != nil {
+		t.Fatalf("FindPrunable failed: %v", err)
+	}
+	if len(candidates) != 3 {
+		t.Errorf("expected 3 candidates, got %d", len(candidates))
+	}
+}
+
+func TestDirectoryRepository_Rename(t *testing.T) {
+	tmpDir := t.TempDir()
+	homeDir := filepath.Join(tmpDir, ".codex")
+
+	if err := os.MkdirAll(homeDir, 0755); err != nil {
+		t.Fatalf("failed to create home dir: %v", err)
+	}
+
+	os.Setenv("HOME", tmpDir)
+	defer os.Unsetenv("HOME")
+
+	repo := storage.NewDirectoryRepository()
+
+	if _, err := repo.Save("old"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err := repo.Save("other"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	if err := repo.Rename("old", "other"); err == nil {
+		t.Error("renaming onto an existing account should fail")
+	}
+	if err := repo.Rename("old", "new"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+
+	acc, err := repo.Get("new")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if acc.Name != "new" {
+		t.Errorf("expected metadata name 'new', got '%s'", acc.Name)
+	}
+	if _, err := repo.Get("old"); err == nil {
+		t.Error("old name should no longer exist")
+	}
+
+	state, _ := repo.State()
+	if state.Previous != "new" {
+		t.Errorf("expected previous account 'new', got '%s'", state.Previous)
+	}
+}
+
+func TestDirectoryRepository_WarmCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	homeDir := filepath.Join(tmpDir, ".codex")
+	t.Setenv("HOME", tmpDir)
+
+	repo := storage.NewDirectoryRepository()
+	for _, name := range []string{"alpha", "beta", "gamma"} {
+		if err := os.MkdirAll(homeDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(homeDir, "auth.json"), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.Save(name); err != nil {
+			t.Fatalf("Save %s failed: %v", name, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// gamma is current, so the most recent others are beta then alpha
+	warmed, err := repo.WarmCache(1)
+	if err != nil {
+		t.Fatalf("WarmCache failed: %v", err)
+	}
+	if len(warmed) != 1 || warmed[0] != "beta" {
+		t.Fatalf("expected [beta] cached, got %v", warmed)
+	}
+	cached := dataPath("cache", "beta")
+	if _, err := os.Stat(filepath.Join(cached, "auth.json")); err != nil {
+		t.Fatalf("beta was not staged: %v", err)
+	}
+
+	// Changes to the saved account reach ~/.codex through the cache
+	if err := os.WriteFile(dataPath("accounts", "beta", "auth.json"), []byte("beta-2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Activate("beta"); err != nil {
+		t.Fatalf("Activate failed: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(homeDir, "auth.json"))
+	if string(data) != "beta-2" {
+		t.Errorf("expected activated auth 'beta-2', got %q", data)
+	}
+	if _, err := os.Stat(cached); !os.IsNotExist(err) {
+		t.Error("cache entry should be consumed by Activate")
+	}
+
+	// Shrinking the cache drops entries that fell out of it
+	if _, err := repo.WarmCache(2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.WarmCache(0); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(dataPath("cache"))
+	if len(entries) != 0 {
+		t.Errorf("expected empty cache, got %d entries", len(entries))
+	}
+}
+
+func TestDirectoryRepository_WarmCacheFollowsSwitches(t *testing.T) {
+	tmpDir := t.TempDir()
+	homeDir := filepath.Join(tmpDir, ".codex")
+	t.Setenv("HOME", tmpDir)
+	if err := os.MkdirAll(homeDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := storage.NewDirectoryRepository()
+	repo.SetCacheSize(1)
+	for _, name := range []string{"alpha", "beta"} {
+		if err := os.WriteFile(filepath.Join(homeDir, "auth.json"), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.Save(name); err != nil {
+			t.Fatalf("Save %s failed: %v", name, err)
+		}
+	}
+
+	// The account switched away from is staged, changes made while it was
+	// current included
+	if err := os.WriteFile(filepath.Join(homeDir, "auth.json"), []byte("beta-2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Activate("alpha"); err != nil {
+		t.Fatalf("Activate failed: %v", err)
+	}
+	if data, err := os.ReadFile(dataPath("cache", "beta", "auth.json")); err != nil || string(data) != "beta-2" {
+		t.Errorf("expected beta staged after switching away, got %q (%v)", data, err)
+	}
+	if _, err := os.Stat(dataPath("cache", "alpha")); !os.IsNotExist(err) {
+		t.Error("the current account should not be staged")
+	}
+}
+
+func TestDirectoryRepository_RemoveItems(t *testing.T) {
+	tmpDir := t.TempDir()
+	homeDir := filepath.Join(tmpDir, ".codex")
+	t.Setenv("HOME", tmpDir)
+
+	if err := os.MkdirAll(filepath.Join(homeDir, "sessions"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"auth.json", "license.secret", "sessions/s.json"} {
+		if err := os.WriteFile(filepath.Join(homeDir, name), []byte("x"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repo := storage.NewDirectoryRepository()
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	removed, err := repo.RemoveItems("work", "auth.json", "missing.json")
+	if err != nil {
+		t.Fatalf("RemoveItems failed: %v", err)
+	}
+	if len(removed) != 1 || removed[0] != "auth.json" {
+		t.Errorf("expected [auth.json] removed, got %v", removed)
+	}
+	accountDir := dataPath("accounts", "work")
+	if _, err := os.Stat(filepath.Join(accountDir, "auth.json")); !os.IsNotExist(err) {
+		t.Error("auth.json should be removed from the saved account")
+	}
+	if _, err := os.Stat(filepath.Join(accountDir, "sessions", "s.json")); err != nil {
+		t.Error("sessions should be kept")
+	}
+
+	if _, err := repo.RemoveActiveItems("auth.json", "license.secret"); err != nil {
+		t.Fatalf("RemoveActiveItems failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(homeDir, "license.secret")); !os.IsNotExist(err) {
+		t.Error("license.secret should be removed from ~/.codex")
+	}
+
+	if _, err := repo.RemoveItems("nope", "auth.json"); err == nil {
+		t.Error("expected error for missing account")
+	}
+}
+
+func TestDirectoryRepository_NewerSchemaIsReadOnly(t *testing.T) {
+	tmpDir := t.TempDir()
+	homeDir := filepath.Join(tmpDir, ".codex")
+	t.Setenv("HOME", tmpDir)
+
+	if err := os.MkdirAll(homeDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(homeDir, "auth.json"), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := storage.NewDirectoryRepository()
+	if _, err := repo.Save("future"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// Simulate metadata written by a newer cxa with an extra field
+	metaPath := dataPath("accounts", "future", ".account.json")
+	meta := `{"schema_version": 99, "name": "future", "email": "me@example.com", "quota": {"daily": 5}}`
+	if err := os.WriteFile(metaPath, []byte(meta), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	acc, err := repo.Get("future")
+	if err != nil {
+		t.Fatalf("Get should read recognized fields: %v", err)
+	}
+	if acc.Email != "me@example.com" {
+		t.Errorf("expected email to be read, got %q", acc.Email)
+	}
+
+	var schemaErr *account.SchemaError
+	if _, err := repo.Save("future"); !errors.As(err, &schemaErr) {
+		t.Errorf("Save should refuse with SchemaError, got %v", err)
+	}
+	if err := repo.Rename("future", "past"); !errors.As(err, &schemaErr) {
+		t.Errorf("Rename should refuse with SchemaError, got %v", err)
+	}
+	if err := repo.Delete("future"); !errors.As(err, &schemaErr) {
+		t.Errorf("Delete should refuse with SchemaError, got %v", err)
+	}
+
+	data, _ := os.ReadFile(metaPath)
+	if string(data) != meta {
+		t.Error("metadata from a newer schema must not be rewritten")
+	}
+}
+
+func TestDirectoryRepository_StateSchema(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	repo := storage.NewDirectoryRepository()
+
+	// state.json from before versioning is upgraded as it is read
+	statePath := statePath("state.json")
+	if err := os.MkdirAll(filepath.Dir(statePath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(statePath, []byte(`{"current": "work", "previous": "personal"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	state, err := repo.State()
+	if err != nil {
+		t.Fatalf("State failed: %v", err)
+	}
+	if state.Current != "work" || state.Previous != "personal" || state.SchemaVersion != storage.StateFormat.Version {
+		t.Errorf("expected the old state upgraded, got %+v", state)
+	}
+	if err := repo.SetState(state); err != nil {
+		t.Fatalf("SetState failed: %v", err)
+	}
+	data, _ := os.ReadFile(statePath)
+	if schema.Version(data) != storage.StateFormat.Version {
+		t.Errorf("expected state.json stamped with its schema, got %s", data)
+	}
+
+	// One from a newer cxa is read but not overwritten
+	newer := `{"schema_version": 99, "current": "work", "previous": "", "pinned": ["work"]}`
+	if err := os.WriteFile(statePath, []byte(newer), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := repo.State(); state.Current != "work" {
+		t.Errorf("expected the newer state read, got %+v", state)
+	}
+	var newerErr *schema.NewerError
+	if err := repo.SetState(&storage.State{Current: "personal"}); !errors.As(err, &newerErr) {
+		t.Errorf("SetState should refuse with NewerError, got %v", err)
+	}
+	if data, _ := os.ReadFile(statePath); string(data) != newer {
+		t.Error("state.json from a newer schema must not be rewritten")
+	}
+}
+
+func TestDirectoryRepository_StateRecovery(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	if err := os.MkdirAll(filepath.Join(tmpDir, ".codex"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	repo := storage.NewDirectoryRepository()
+	for _, name := range []string{"personal", "work"} {
+		if _, err := repo.Save(name); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	if err := repo.Activate("personal"); err != nil {
+		t.Fatalf("Activate failed: %v", err)
+	}
+	if err := repo.Activate("work"); err != nil {
+		t.Fatalf("Activate failed: %v", err)
+	}
+
+	statePath := statePath("state.json")
+	if tmp, _ := filepath.Glob(statePath + ".*.tmp"); len(tmp) > 0 {
+		t.Errorf("no temporary file should be left beside state.json, found %v", tmp)
+	}
+	if err := os.WriteFile(statePath, []byte(`{"current": "wo`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := repo.Current()
+	if err != nil {
+		t.Fatalf("Current failed: %v", err)
+	}
+	if current != "work" {
+		t.Errorf("expected the current account recovered as work, got %q", current)
+	}
+	state, err := repo.State()
+	if err != nil {
+		t.Fatalf("State failed: %v", err)
+	}
+	if state.Previous != "personal" {
+		t.Errorf("expected the previous account recovered as personal, got %q", state.Previous)
+	}
+	if data, _ := os.ReadFile(statePath); schema.Version(data) != storage.StateFormat.Version {
+		t.Errorf("expected state.json rewritten, got %s", data)
+	}
+	if data, _ := os.ReadFile(statePath + ".corrupt"); string(data) != `{"current": "wo` {
+		t.Errorf("expected the unreadable state.json kept aside, got %q", data)
+	}
+}
+
+func TestDirectoryRepository_UpdateMetadata(t *testing.T) {
+	tmpDir := t.TempDir()
+	homeDir := filepath.Join(tmpDir, ".codex")
+	t.Setenv("HOME", tmpDir)
+
+	if err := os.MkdirAll(homeDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := storage.NewDirectoryRepository()
+	saved, err := repo.Save("client")
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	if _, err := repo.UpdateMetadata("client", func(acc *account.Account) {
+		acc.Reminder = "no code uploads"
+		acc.Name = "ignored"
+	}); err != nil {
+		t.Fatalf("UpdateMetadata failed: %v", err)
+	}
+
+	acc, err := repo.Get("client")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if acc.Reminder != "no code uploads" {
+		t.Errorf("expected reminder to be stored, got %q", acc.Reminder)
+	}
+	if acc.Name != "client" || !acc.CreatedAt.Equal(saved.CreatedAt) {
+		t.Errorf("name and creation time should be preserved: %+v", acc)
+	}
+
+	if _, err := repo.UpdateMetadata("missing", func(*account.Account) {}); err == nil {
+		t.Error("expected error for missing account")
+	}
+}
+
+func TestDirectoryRepository_Tags(t *testing.T) {
+	tmpDir := t.TempDir()
+	homeDir := filepath.Join(tmpDir, ".codex")
+	t.Setenv("HOME", tmpDir)
+
+	if err := os.MkdirAll(homeDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := storage.NewDirectoryRepository()
+	for _, name := range []string{"client", "personal", "work"} {
+		if _, err := repo.Save(name); err != nil {
+			t.Fatalf("Save %s failed: %v", name, err)
+		}
+	}
+
+	tag := func(name string, tags ...string) {
+		t.Helper()
+		if _, err := repo.UpdateMetadata(name, func(acc *account.Account) {
+			for _, tag := range tags {
+				acc.AddTag(tag)
+			}
+		}); err != nil {
+			t.Fatalf("UpdateMetadata %s failed: %v", name, err)
+		}
+	}
+	tag("client", "paid", "team")
+	tag("work", "team", "paid")
+	tag("work", "team")
+	tag("personal", "free")
+
+	acc, err := repo.Get("work")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !slices.Equal(acc.Tags, []string{"paid", "team"}) {
+		t.Errorf("expected sorted tags without duplicates, got %v", acc.Tags)
+	}
+
+	filtered := func(tags ...string) []string {
+		t.Helper()
+		accounts, err := repo.List()
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		var names []string
+		for _, acc := range account.FilterTags(accounts, tags) {
+			names = append(names, acc.Name)
+		}
+		return names
+	}
+	if got := filtered(); len(got) != 3 {
+		t.Errorf("expected no tags to keep every account, got %v", got)
+	}
+	if got := filtered("team"); !slices.Equal(got, []string{"client", "work"}) {
+		t.Errorf("expected client and work tagged team, got %v", got)
+	}
+	if got := filtered("team", "free"); len(got) != 0 {
+		t.Errorf("expected accounts to need every tag, got %v", got)
+	}
+
+	if _, err := repo.UpdateMetadata("client", func(acc *account.Account) {
+		if !acc.RemoveTag("team") || acc.RemoveTag("team") {
+			t.Error("expected RemoveTag to report whether the tag was there")
+		}
+	}); err != nil {
+		t.Fatalf("UpdateMetadata failed: %v", err)
+	}
+	if got := filtered("team"); !slices.Equal(got, []string{"work"}) {
+		t.Errorf("expected only work tagged team after removal, got %v", got)
+	}
+
+	if _, err := repo.UpdateMetadata("personal", func(acc *account.Account) { acc.RemoveTag("free") }); err != nil {
+		t.Fatalf("UpdateMetadata failed: %v", err)
+	}
+	if acc, _ := repo.Get("personal"); acc.Tags != nil {
+		t.Errorf("expected no tags left on personal, got %v", acc.Tags)
+	}
+}
+
+func TestDirectoryRepository_Inspect(t *testing.T) {
+	tmpDir := t.TempDir()
+	homeDir := filepath.Join(tmpDir, ".codex")
+	t.Setenv("HOME", tmpDir)
+
+	if err := os.MkdirAll(filepath.Join(homeDir, "sessions"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(homeDir, "auth.json"), []byte(`{"token": "secret"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(homeDir, "sessions", "a.jsonl"), []byte("prompt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := storage.NewDirectoryRepository()
+	if _, err := repo.Save("old"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	dir, err := repo.Inspect("old")
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "sessions", "a.jsonl")); string(data) != "prompt" {
+		t.Errorf("expected the session in the view, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "auth.json")); !os.IsNotExist(err) {
+		t.Error("the view must not hold credentials")
+	}
+	for _, path := range []string{dir, filepath.Join(dir, "sessions"), filepath.Join(dir, "sessions", "a.jsonl")} {
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm()&0222 != 0 {
+			t.Errorf("%s should be read-only, got %v (%v)", path, info.Mode(), err)
+		}
+	}
+
+	if views, _ := repo.Views(); len(views) != 1 || views[0] != dir {
+		t.Errorf("expected the view listed, got %v", views)
+	}
+	if err := storage.RemoveView(dir); err != nil {
+		t.Fatalf("RemoveView failed: %v", err)
+	}
+	if views, _ := repo.Views(); len(views) != 0 {
+		t.Errorf("expected no views left, got %v", views)
+	}
+	if _, err := os.Stat(dataPath("accounts", "old", "auth.json")); err != nil {
+		t.Error("inspecting must not touch the saved account")
+	}
+}
+
+func TestDirectoryRepository_CloneAndCommit(t *testing.T) {
+	tmpDir := t.TempDir()
+	homeDir := filepath.Join(tmpDir, ".codex")
+	t.Setenv("HOME", tmpDir)
+
+	if err := os.MkdirAll(homeDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(homeDir, "config.toml"), []byte(`model = "a"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := storage.NewDirectoryRepository()
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	dir, err := repo.Clone("work")
+	if err != nil {
+		t.Fatalf("Clone failed: %v", err)
+	}
+	if changed, err := repo.CloneChanged("work", dir); err != nil || changed {
+		t.Fatalf("fresh clone should be unchanged: %v, %v", changed, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "config.toml"), []byte(`model = "b"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if changed, _ := repo.CloneChanged("work", dir); !changed {
+		t.Fatal("edited clone should be changed")
+	}
+
+	savedConfig := dataPath("accounts", "work", "config.toml")
+	if data, _ := os.ReadFile(savedConfig); string(data) != `model = "a"` {
+		t.Error("editing the clone must not touch the saved account")
+	}
+
+	if err := repo.Commit("work", dir); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if data, _ := os.ReadFile(savedConfig); string(data) != `model = "b"` {
+		t.Errorf("commit should update the saved account, got %q", data)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Error("commit should remove the clone")
+	}
+	if _, err := repo.Get("work"); err != nil {
+		t.Errorf("metadata should survive commit: %v", err)
+	}
+}
+
+func TestDiffDirs(t *testing.T) {
+	left := t.TempDir()
+	right := t.TempDir()
+
+	write := func(dir, name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(left, "auth.json", "same")
+	write(right, "auth.json", "same")
+	write(left, "history.jsonl", "aaaa")
+	write(right, "history.jsonl", "bbbb")
+	write(left, "sessions/old.json", "{}")
+	write(right, "notes/new.md", "hi")
+	write(left, ".account.json", `{"name": "left"}`)
+	write(left, "config.toml", "model = \"o3\"\n[tools]\nweb = true\n")
+	write(right, "config.toml", "model = \"gpt-5\"\n[tools]\nweb = true\nshell = false\n")
+
+	d, err := storage.DiffDirs(left, right)
+	if err != nil {
+		t.Fatalf("DiffDirs failed: %v", err)
+	}
+
+	want := map[string]storage.DiffKind{
+		"config.toml":   storage.Changed,
+		"history.jsonl": storage.Changed,
+		"notes":         storage.OnlyRight,
+		"sessions":      storage.OnlyLeft,
+	}
+	got := make(map[string]storage.DiffKind)
+	for _, e := range d.Entries {
+		got[e.Path] = e.Kind
+	}
+	if len(got) != len(want) {
+		t.Errorf("expected entries %v, got %v", want, got)
+	}
+	for path, kind := range want {
+		if got[path] != kind {
+			t.Errorf("%s: expected %s, got %s", path, kind, got[path])
+		}
+	}
+
+	if len(d.Settings) != 2 {
+		t.Fatalf("expected 2 setting changes, got %+v", d.Settings)
+	}
+	if s := d.Settings[0]; s.Key != "model" || s.Left != `"o3"` || s.Right != `"gpt-5"` {
+		t.Errorf("unexpected model change: %+v", s)
+	}
+	if s := d.Settings[1]; s.Key != "tools.shell" || s.Left != "" || s.Right != "false" {
+		t.Errorf("unexpected added setting: %+v", s)
+	}
+}
+
+func TestDirectoryRepository_MoveData(t *testing.T) {
+	tmpDir := t.TempDir()
+	homeDir := filepath.Join(tmpDir, ".codex")
+	t.Setenv("HOME", tmpDir)
+
+	if err := os.MkdirAll(homeDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(homeDir, "auth.json"), []byte(`{"token": "work"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// A sharing link from ~/.codex into the data directory
+	oldData := filepath.Join(tmpDir, "codex-data")
+	if err := os.MkdirAll(filepath.Join(oldData, "shared", "sessions"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(oldData, "shared", "sessions"), filepath.Join(homeDir, "sessions")); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := storage.NewDirectoryRepository()
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// A failed persist leaves everything where it was
+	failing := errors.New("disk full")
+	if err := repo.MoveData(filepath.Join(tmpDir, "elsewhere"), func(string) error { return failing }); !errors.Is(err, failing) {
+		t.Fatalf("expected persist error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "elsewhere")); !os.IsNotExist(err) {
+		t.Error("a failed move should discard the copy")
+	}
+
+	newData := filepath.Join(tmpDir, "drive", "cxa")
+	var persisted string
+	if err := repo.MoveData(newData, func(dir string) error { persisted = dir; return nil }); err != nil {
+		t.Fatalf("MoveData failed: %v", err)
+	}
+	if persisted != newData {
+		t.Errorf("expected %s to be persisted, got %s", newData, persisted)
+	}
+	if _, err := os.Stat(oldData); !os.IsNotExist(err) {
+		t.Error("old data directory should be removed")
+	}
+
+	if _, err := repo.Get("work"); err != nil {
+		t.Errorf("account should be readable from the new location: %v", err)
+	}
+	link, err := os.Readlink(filepath.Join(homeDir, "sessions"))
+	if err != nil || link != filepath.Join(newData, "shared", "sessions") {
+		t.Errorf("sharing link should follow the move, got %s (%v)", link, err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(tmpDir, "full"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "full", "x"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.MoveData(filepath.Join(tmpDir, "full"), func(string) error { return nil }); err == nil {
+		t.Error("moving into a non-empty directory should fail")
+	}
+}
+
+func TestDirectoryRepository_MigrateArchive(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	dataDir := filepath.Join(tmpDir, "codex-data")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	modified := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	writeZip := func(name string, files map[string]string) {
+		t.Helper()
+		f, err := os.Create(filepath.Join(dataDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		zw := zip.NewWriter(f)
+		for path, content := range files {
+			w, err := zw.CreateHeader(&zip.FileHeader{Name: path, Method: zip.Deflate, Modified: modified})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Write([]byte(content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeZip("work.zip", map[string]string{
+		"auth.json":               `{"OPENAI_API_KEY": "sk-test"}`,
+		"sessions/2024/01/a.json": "{}",
+	})
+	writeZip("personal.zip", map[string]string{
+		".codex/auth.json":   `{"OPENAI_API_KEY": "sk-personal"}`,
+		".codex/config.toml": `model = "o3"`,
+	})
+	writeZip("evil.zip", map[string]string{"../escape": "x"})
+
+	repo := storage.NewDirectoryRepository()
+	archives, err := repo.FindLegacyArchives("")
+	if err != nil {
+		t.Fatalf("FindLegacyArchives failed: %v", err)
+	}
+	if len(archives) != 3 || archives[0].Name != "evil" || archives[1].Name != "personal" || archives[2].Name != "work" {
+		t.Fatalf("unexpected archives: %+v", archives)
+	}
+
+	if _, err := repo.MigrateArchive(archives[0]); err == nil {
+		t.Error("an archive escaping its directory should be rejected")
+	}
+	if _, err := os.Stat(dataPath("accounts", "escape")); !os.IsNotExist(err) {
+		t.Error("escaping entry must not be written")
+	}
+
+	for _, archive := range archives[1:] {
+		acc, err := repo.MigrateArchive(archive)
+		if err != nil {
+			t.Fatalf("MigrateArchive(%s) failed: %v", archive.Name, err)
+		}
+		if !acc.CreatedAt.Equal(modified) {
+			t.Errorf("%s: expected creation time from the archive, got %v", archive.Name, acc.CreatedAt)
+		}
+	}
+
+	accountsDir := filepath.Join(dataDir, "accounts")
+	for _, path := range []string{"work/sessions/2024/01/a.json", "personal/config.toml", "personal/auth.json"} {
+		if _, err := os.Stat(filepath.Join(accountsDir, path)); err != nil {
+			t.Errorf("expected %s to be migrated: %v", path, err)
+		}
+	}
+
+	archives, _ = repo.FindLegacyArchives("")
+	if !archives[1].Exists || !archives[2].Exists {
+		t.Error("migrated archives should be reported as existing")
+	}
+	if _, err := repo.MigrateArchive(archives[2]); err == nil {
+		t.Error("migrating over an existing account should fail")
+	}
+}
+
+func TestDirectoryRepository_Verify(t *testing.T) {
+	tmpDir := t.TempDir()
+	homeDir := filepath.Join(tmpDir, ".codex")
+	t.Setenv("HOME", tmpDir)
+
+	if err := os.MkdirAll(filepath.Join(homeDir, "sessions"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"auth.json":        `{"token": "work"}`,
+		"config.toml":      `model = "o3"`,
+		"sessions/a.jsonl": "{}",
+	} {
+		if err := os.WriteFile(filepath.Join(homeDir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repo := storage.NewDirectoryRepository()
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	result, err := repo.Verify("work")
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !result.OK() {
+		t.Fatalf("freshly saved account should verify, got %+v", result)
+	}
+
+	accountDir := dataPath("accounts", "work")
+	if err := os.WriteFile(filepath.Join(accountDir, "config.toml"), []byte(`model = "o4"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(accountDir, "auth.json")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(accountDir, "planted"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = repo.Verify("work")
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	want := []storage.VerifyIssue{
+		{Path: "auth.json", Kind: storage.IssueMissing},
+		{Path: "config.toml", Kind: storage.IssueModified},
+		{Path: "planted", Kind: storage.IssueUnexpected},
+	}
+	if len(result.Issues) != len(want) {
+		t.Fatalf("expected %v, got %v", want, result.Issues)
+	}
+	for i := range want {
+		if result.Issues[i] != want[i] {
+			t.Errorf("issue %d: expected %v, got %v", i, want[i], result.Issues[i])
+		}
+	}
+
+	// New files in ~/.codex are normal use; changed ones are reported
+	if err := os.WriteFile(filepath.Join(homeDir, "sessions", "b.jsonl"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(homeDir, "auth.json"), []byte(`{"token": "other"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	result, err = repo.VerifyActive()
+	if err != nil {
+		t.Fatalf("VerifyActive failed: %v", err)
+	}
+	if len(result.Issues) != 1 || result.Issues[0].Path != "auth.json" {
+		t.Errorf("expected only auth.json to be reported, got %v", result.Issues)
+	}
+
+	// Every way of writing an account records a manifest
+	if err := repo.Activate("work"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.ImportDir("imported", homeDir); err != nil {
+		t.Fatalf("ImportDir failed: %v", err)
+	}
+	if _, err := repo.RemoveItems("imported", "sessions"); err != nil {
+		t.Fatalf("RemoveItems failed: %v", err)
+	}
+	if result, err := repo.Verify("imported"); err != nil || !result.OK() {
+		t.Errorf("imported account should verify, got %+v, %v", result, err)
+	}
+}
+
+func TestDirectoryRepository_RecordsHistory(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	if err := os.MkdirAll(filepath.Join(tmpDir, ".codex"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := storage.NewDirectoryRepository()
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err := repo.Save("personal"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := repo.Activate("work"); err != nil {
+		t.Fatalf("Activate failed: %v", err)
+	}
+	if err := repo.Rename("personal", "home"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if err := repo.Delete("home"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	entries, err := history.NewLog(statePath("history.log")).Recent("", 0)
+	if err != nil {
+		t.Fatalf("Recent failed: %v", err)
+	}
+	want := []history.Entry{
+		{Op: history.OpDelete, Account: "home"},
+		{Op: history.OpRename, Account: "home", From: "personal"},
+		{Op: history.OpSwitch, Account: "work", From: "personal"},
+		{Op: history.OpSave, Account: "personal"}, // saved before switching away
+		{Op: history.OpSave, Account: "personal"},
+		{Op: history.OpSave, Account: "work"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("expected %d entries, got %+v", len(want), entries)
+	}
+	for i := range want {
+		entries[i].Time = time.Time{}
+		if entries[i] != want[i] {
+			t.Errorf("entry %d: expected %+v, got %+v", i, want[i], entries[i])
+		}
+	}
+}
+
+func TestDirectoryRepository_Sync(t *testing.T) {
+	remoteDir := filepath.Join(t.TempDir(), "remote")
+	tr, err := remote.Open(remoteDir)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	// Two machines sharing one remote
+	type machine struct {
+		home string
+		repo *storage.DirectoryRepository
+	}
+	newMachine := func() *machine {
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		if err := os.MkdirAll(filepath.Join(home, ".codex"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		return &machine{home: home, repo: storage.NewDirectoryRepository()}
+	}
+	writeConfig := func(m *machine, content string) {
+		t.Helper()
+		t.Setenv("HOME", m.home)
+		if err := os.WriteFile(filepath.Join(m.home, ".codex", "config.toml"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.repo.Save("work"); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	sync := func(m *machine, opts storage.SyncOptions) storage.SyncResult {
+		t.Helper()
+		t.Setenv("HOME", m.home)
+		results, err := m.repo.Sync(tr, "drive", opts)
+		if err != nil {
+			t.Fatalf("Sync failed: %v", err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("expected one result, got %+v", results)
+		}
+		if results[0].Error != "" {
+			t.Fatalf("sync of %s failed: %s", results[0].Account, results[0].Error)
+		}
+		return results[0]
+	}
+	config := func(m *machine) string {
+		data, _ := os.ReadFile(filepath.Join(m.repo.Paths().DataDir, "accounts", "work", "config.toml"))
+		return string(data)
+	}
+
+	laptop, desktop := newMachine(), newMachine()
+
+	writeConfig(laptop, "v1")
+	if r := sync(laptop, storage.SyncOptions{}); r.Action != storage.SyncPush {
+		t.Errorf("new account should be pushed, got %s", r.Action)
+	}
+	if r := sync(desktop, storage.SyncOptions{}); r.Action != storage.SyncPull || config(desktop) != "v1" {
+		t.Errorf("desktop should pull v1, got %s with %q", r.Action, config(desktop))
+	}
+	if r := sync(desktop, storage.SyncOptions{}); r.Action != storage.SyncNone {
+		t.Errorf("unchanged account should be in sync, got %s", r.Action)
+	}
+
+	writeConfig(desktop, "v2")
+	if r := sync(desktop, storage.SyncOptions{}); r.Action != storage.SyncPush {
+		t.Errorf("desktop change should be pushed, got %s", r.Action)
+	}
+	if r := sync(laptop, storage.SyncOptions{}); r.Action != storage.SyncPull || config(laptop) != "v2" {
+		t.Errorf("laptop should pull v2, got %s with %q", r.Action, config(laptop))
+	}
+
+	// Both sides change: a conflict until a preference settles it
+	writeConfig(desktop, "desktop")
+	sync(desktop, storage.SyncOptions{})
+	time.Sleep(10 * time.Millisecond)
+	writeConfig(laptop, "laptop")
+	if r := sync(laptop, storage.SyncOptions{}); r.Action != storage.SyncConflict {
+		t.Errorf("changes on both sides should conflict, got %s", r.Action)
+	}
+	if config(laptop) != "laptop" {
+		t.Error("an unresolved conflict must not change the account")
+	}
+	if r := sync(laptop, storage.SyncOptions{Prefer: storage.PreferNewer, DryRun: true}); r.Action != storage.SyncPush || !r.Resolved {
+		t.Errorf("the newer laptop copy should win, got %s", r.Action)
+	}
+	if r := sync(laptop, storage.SyncOptions{Prefer: storage.PreferRemote}); r.Action != storage.SyncPull || config(laptop) != "desktop" {
+		t.Errorf("preferring the remote should pull it, got %s with %q", r.Action, config(laptop))
+	}
+}
+
+func TestDirectoryRepository_SyncRefusesTamperedIndex(t *testing.T) {
+	remoteDir := filepath.Join(t.TempDir(), "remote")
+	tr, err := remote.Open(remoteDir)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	home := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(home, ".codex"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	repo := storage.NewDirectoryRepositoryWithPaths(codex.NewPathsAt(home))
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err := repo.Sync(tr, "drive", storage.SyncOptions{}); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	// A directory outside the data directory that an index entry names,
+	// with a copy on the remote, outside its own accounts, to pull over it
+	const evil = "../../victim"
+	victim := filepath.Join(repo.Paths().AccountPath(evil), "keep")
+	if err := os.MkdirAll(filepath.Dir(victim), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(victim, []byte("mine"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	index, err := tr.ReadIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	index.Accounts[evil] = index.Accounts["work"]
+	if err := os.CopyFS(filepath.Join(remoteDir, "accounts", evil), os.DirFS(filepath.Join(remoteDir, "accounts", "work"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.WriteIndex(index); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := repo.Sync(tr, "drive", storage.SyncOptions{})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	var refused bool
+	for _, r := range results {
+		switch r.Account {
+		case evil:
+			refused = r.Error != "" && r.Action == ""
+		case "work":
+			if r.Error != "" || r.Action != storage.SyncNone {
+				t.Errorf("work should stay in sync, got %+v", r)
+			}
+		}
+	}
+	if !refused {
+		t.Errorf("expected the tampered entry reported as an error, got %+v", results)
+	}
+	results, err = repo.Sync(tr, "drive", storage.SyncOptions{Accounts: []string{evil}, Prefer: storage.PreferRemote})
+	if err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Error == "" {
+		t.Errorf("expected the tampered entry refused even when asked for, got %+v", results)
+	}
+	if err := repo.SetBackend("remote", remoteDir); err != nil {
+		t.Fatalf("SetBackend failed: %v", err)
+	}
+	if err := repo.Activate(evil); err == nil {
+		t.Error("expected activating the tampered entry to fail")
+	}
+	if data, err := os.ReadFile(victim); err != nil || string(data) != "mine" {
+		t.Errorf("the directory named by the index was touched: %q (%v)", data, err)
+	}
+}
+
+func TestDirectoryRepository_Watch(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	codexDir := filepath.Join(tmpDir, ".codex")
+	if err := os.MkdirAll(codexDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := storage.NewDirectoryRepository()
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	saves := make(chan error, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- repo.Watch(ctx, storage.WatchOptions{
+			Interval: 5 * time.Millisecond,
+			Settle:   20 * time.Millisecond,
+			OnSave:   func(name string, err error) { saves <- err },
+		})
+	}()
+
+	if err := os.WriteFile(filepath.Join(codexDir, "session.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-saves:
+		if err != nil {
+			t.Fatalf("save failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("change was not saved")
+	}
+	if _, err := os.Stat(dataPath("accounts", "work", "session.json")); err != nil {
+		t.Errorf("new file missing from the account: %v", err)
+	}
+
+	// A change still settling is saved on the way out
+	if err := os.WriteFile(filepath.Join(codexDir, "late.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	if _, err := os.Stat(dataPath("accounts", "work", "late.json")); err != nil {
+		t.Errorf("pending change was not saved on exit: %v", err)
+	}
+}
+
+func TestDirectoryRepository_Protected(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	codexDir := filepath.Join(tmpDir, ".codex")
+	if err := os.MkdirAll(codexDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := storage.NewDirectoryRepository()
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err := repo.UpdateMetadata("work", func(acc *account.Account) { acc.Protected = true }); err != nil {
+		t.Fatalf("UpdateMetadata failed: %v", err)
+	}
+
+	var protectedErr *account.ProtectedError
+	if _, err := repo.Save("work"); !errors.As(err, &protectedErr) {
+		t.Errorf("saving over a locked account should fail, got %v", err)
+	}
+	if err := repo.Delete("work"); !errors.As(err, &protectedErr) {
+		t.Errorf("deleting a locked account should fail, got %v", err)
+	}
+
+	// Switching away keeps the locked copy as it was
+	if err := os.WriteFile(filepath.Join(codexDir, "scratch.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.SaveForce("personal"); err != nil {
+		t.Fatalf("SaveForce failed: %v", err)
+	}
+	if err := repo.Activate("work"); err != nil {
+		t.Fatalf("Activate failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(codexDir, "changed.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Activate("personal"); err != nil {
+		t.Fatalf("switching away from a locked account failed: %v", err)
+	}
+	if _, err := os.Stat(dataPath("accounts", "work", "changed.txt")); !os.IsNotExist(err) {
+		t.Error("changes made while a locked account was active should not be saved")
+	}
+
+	if err := repo.DeleteForce("work"); err != nil {
+		t.Errorf("DeleteForce failed: %v", err)
+	}
+}
+
+func TestDirectoryRepository_ImportOverCurrent(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	codexDir := filepath.Join(tmpDir, ".codex")
+	if err := os.MkdirAll(codexDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(codexDir, "notes.txt"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := storage.NewDirectoryRepository()
+	for _, name := range []string{"personal", "work"} {
+		if _, err := repo.Save(name); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	if _, err := repo.UpdateMetadata("work", func(acc *account.Account) { acc.Protected = true }); err != nil {
+		t.Fatalf("UpdateMetadata failed: %v", err)
+	}
+
+	exported := filepath.Join(tmpDir, "exported")
+	if err := os.MkdirAll(exported, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(exported, "notes.txt"), []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var archive bytes.Buffer
+	if err := transfer.Export(&archive, exported, account.NewAccount("work"), transfer.ExportOptions{}); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	var protectedErr *account.ProtectedError
+	if _, err := repo.Import("work", bytes.NewReader(archive.Bytes())); !errors.As(err, &protectedErr) {
+		t.Fatalf("importing over a locked account should fail, got %v", err)
+	}
+	if _, err := repo.ImportForce("work", bytes.NewReader(archive.Bytes())); err != nil {
+		t.Fatalf("ImportForce failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(codexDir, "notes.txt")); err != nil || string(data) != "new" {
+		t.Errorf("expected ~/.codex refreshed from the import, got %q (%v)", data, err)
+	}
+
+	// The next switch saves the import, not what ~/.codex held before it
+	if _, err := repo.UpdateMetadata("work", func(acc *account.Account) { acc.Protected = false }); err != nil {
+		t.Fatalf("UpdateMetadata failed: %v", err)
+	}
+	if err := repo.Activate("personal"); err != nil {
+		t.Fatalf("Activate failed: %v", err)
+	}
+	if data, err := os.ReadFile(dataPath("accounts", "work", "notes.txt")); err != nil || string(data) != "new" {
+		t.Errorf("expected the import kept across switches, got %q (%v)", data, err)
+	}
+}
+
+func TestDirectoryRepository_ShareConflictLeftUnshared(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	codexDir := filepath.Join(tmpDir, ".codex")
+	if err := os.MkdirAll(codexDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := storage.NewDirectoryRepository()
+	manager := sharing.NewManagerWithPaths(repo.Paths())
+	if err := manager.Enable(false); err != nil {
+		t.Fatalf("Enable failed: %v", err)
+	}
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err := repo.Save("personal"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	// Sessions of work's own that the shared ones lack
+	sessions := dataPath("accounts", "work", "sessions")
+	if err := os.RemoveAll(sessions); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(sessions, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sessions, "local.jsonl"), []byte("l\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	asked := 0
+	repo.SetShareResolver(func(c sharing.Conflict) (sharing.Resolution, error) {
+		asked++
+		if c.Item != "sessions" {
+			t.Errorf("unexpected conflict over %s", c.Item)
+		}
+		return "", sharing.ErrLeftUnshared
+	})
+	for switches := 1; switches <= 2; switches++ {
+		if err := repo.Activate("work"); err != nil {
+			t.Fatalf("Activate failed: %v", err)
+		}
+		if asked != switches {
+			t.Errorf("asked %d times after %d switches, want once per switch", asked, switches)
+		}
+		if info, err := os.Lstat(filepath.Join(codexDir, "sessions")); err != nil || !info.IsDir() {
+			t.Errorf("expected sessions left unshared in ~/.codex: %v", err)
+		}
+		if link, err := os.Readlink(filepath.Join(codexDir, "history.jsonl")); err != nil || link != filepath.Join(repo.Paths().SharedDir, "history.jsonl") {
+			t.Errorf("expected history.jsonl still shared, got %q (%v)", link, err)
+		}
+		if w, _ := warnings.NewStore(repo.Paths().WarningsFile()).List(); len(w) > 0 {
+			t.Errorf("leaving an item unshared should not warn: %+v", w)
+		}
+		if err := repo.Activate("personal"); err != nil {
+			t.Fatalf("Activate failed: %v", err)
+		}
+	}
+}
+
+func TestDirectoryRepository_ArchivedCannotBeActivated(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	if err := os.MkdirAll(filepath.Join(tmpDir, ".codex"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := storage.NewDirectoryRepository()
+	for _, name := range []string{"old-client", "work"} {
+		if _, err := repo.Save(name); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	if _, err := repo.UpdateMetadata("old-client", func(acc *account.Account) { acc.Archived = true }); err != nil {
+		t.Fatalf("UpdateMetadata failed: %v", err)
+	}
+
+	if err := repo.Activate("old-client"); err == nil {
+		t.Error("activating an archived account should fail")
+	}
+	if warmed, err := repo.WarmCache(5); err != nil || len(warmed) != 0 {
+		t.Errorf("archived accounts should not be cached, got %v (%v)", warmed, err)
+	}
+
+	if _, err := repo.UpdateMetadata("old-client", func(acc *account.Account) { acc.Archived = false }); err != nil {
+		t.Fatalf("UpdateMetadata failed: %v", err)
+	}
+	if err := repo.Activate("old-client"); err != nil {
+		t.Errorf("Activate after unarchiving failed: %v", err)
+	}
+}
+
+func TestDirectoryRepository_CodexVersion(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	if err := os.MkdirAll(filepath.Join(tmpDir, ".codex"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A stand-in codex on PATH reporting the version to probe
+	binDir := t.TempDir()
+	t.Setenv("PATH", binDir)
+	installCodex := func(version string) {
+		t.Helper()
+		script := "#!/bin/sh\necho codex-cli " + version + "\n"
+		if err := os.WriteFile(filepath.Join(binDir, "codex"), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	installCodex("0.40.1")
+	acc, err := storage.NewDirectoryRepository().Save("work")
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if acc.CodexVersion != "0.40.1" {
+		t.Errorf("expected codex version 0.40.1, got %q", acc.CodexVersion)
+	}
+
+	installCodex("0.40.7")
+	if _, _, mismatch := storage.NewDirectoryRepository().CodexMismatch("work"); mismatch {
+		t.Error("a patch release should be compatible")
+	}
+
+	installCodex("0.46.0")
+	saved, installed, mismatch := storage.NewDirectoryRepository().CodexMismatch("work")
+	if !mismatch || saved != "0.40.1" || installed != "0.46.0" {
+		t.Errorf("expected a mismatch between 0.40.1 and 0.46.0, got %q %q %v", saved, installed, mismatch)
+	}
+}
+
+func TestDirectoryRepository_LinkActivation(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	codexDir := filepath.Join(tmpDir, ".codex")
+	accountsDir := dataPath("accounts")
+	if err := os.MkdirAll(codexDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := storage.NewDirectoryRepository()
+	repo.SetActivation(storage.ActivateLink)
+	for _, name := range []string{"personal", "work"} {
+		if _, err := repo.Save(name); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	if err := repo.Activate("personal"); err != nil {
+		t.Fatalf("Activate failed: %v", err)
+	}
+	if target, err := os.Readlink(codexDir); err != nil || target != filepath.Join(accountsDir, "personal") {
+		t.Fatalf("~/.codex should link to the personal account, got %q (%v)", target, err)
+	}
+
+	// Writes through the link land in the account, and saving keeps them
+	if err := os.WriteFile(filepath.Join(codexDir, "auth.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Save("personal"); err != nil {
+		t.Fatalf("Save through the link failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(accountsDir, "personal", "auth.json")); err != nil {
+		t.Errorf("saving the linked account lost its files: %v", err)
+	}
+	if err := repo.Delete("personal"); err == nil {
+		t.Error("deleting the linked account should fail")
+	}
+
+	if err := repo.Rename("personal", "home"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if target, err := os.Readlink(codexDir); err != nil || target != filepath.Join(accountsDir, "home") {
+		t.Errorf("~/.codex should follow the rename, got %q (%v)", target, err)
+	}
+
+	// Switching back to copying replaces the link with a real directory
+	repo.SetActivation(storage.ActivateCopy)
+	if err := repo.Activate("work"); err != nil {
+		t.Fatalf("Activate failed: %v", err)
+	}
+	info, err := os.Lstat(codexDir)
+	if err != nil || !info.IsDir() {
+		t.Fatalf("~/.codex should be a real directory again, got %v (%v)", info, err)
+	}
+	if _, err := os.Stat(filepath.Join(accountsDir, "home", "auth.json")); err != nil {
+		t.Errorf("switching away from a linked account lost its files: %v", err)
+	}
+
+	repo.SetActivation(storage.ActivateLink)
+	if err := repo.Activate("home"); err != nil {
+		t.Fatalf("Activate failed: %v", err)
+	}
+	if err := repo.Materialize(); err != nil {
+		t.Fatalf("Materialize failed: %v", err)
+	}
+	if info, err := os.Lstat(codexDir); err != nil || !info.IsDir() {
+		t.Errorf("Materialize should leave a real ~/.codex, got %v (%v)", info, err)
+	}
+	if _, err := os.Stat(filepath.Join(codexDir, "auth.json")); err != nil {
+		t.Errorf("Materialize lost files: %v", err)
+	}
+}
+
+func TestDirectoryRepository_SaveIsIncremental(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	codexDir := filepath.Join(tmpDir, ".codex")
+	accountDir := dataPath("accounts", "work")
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(codexDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("sessions/old.jsonl", "old session\n")
+	write("sessions/live.jsonl", "first turn\n")
+	write("scratch.txt", "temporary")
+
+	repo := storage.NewDirectoryRepository()
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	before, err := os.Stat(filepath.Join(accountDir, "sessions", "old.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write("sessions/live.jsonl", "first turn\nsecond turn\n")
+	if err := os.Remove(filepath.Join(codexDir, "scratch.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	after, err := os.Stat(filepath.Join(accountDir, "sessions", "old.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(before, after) {
+		t.Error("an unchanged file should be kept, not copied again")
+	}
+	if data, _ := os.ReadFile(filepath.Join(accountDir, "sessions", "live.jsonl")); string(data) != "first turn\nsecond turn\n" {
+		t.Errorf("changed file not saved, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(accountDir, "scratch.txt")); !os.IsNotExist(err) {
+		t.Error("a file deleted from ~/.codex should be removed from the account")
+	}
+}
+
+func TestDirectoryRepository_ActivateRollsBack(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	codexDir := filepath.Join(tmpDir, ".codex")
+	if err := os.MkdirAll(codexDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(codexDir, "auth.json"), []byte("personal"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := storage.NewDirectoryRepository()
+	if _, err := repo.Save("personal"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(codexDir, "auth.json"), []byte("work"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	errSwap := errors.New("injected rename failure")
+	restore := storage.SetFaultHook(func(op, path string) error {
+		if op == storage.OpRename && path == codexDir {
+			return errSwap
+		}
+		return nil
+	})
+	err := repo.Activate("personal")
+	restore()
+	if !errors.Is(err, errSwap) {
+		t.Fatalf("expected the injected failure, got %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(codexDir, "auth.json")); err != nil || string(data) != "work" {
+		t.Errorf("a failed switch should leave ~/.codex as it was, got %q (%v)", data, err)
+	}
+
+	// A swap interrupted between its two renames is undone by the next
+	// switch, before ~/.codex is saved
+	if err := os.WriteFile(filepath.Join(codexDir, "auth.json"), []byte("work, refreshed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(codexDir, codexDir+".old"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Activate("personal"); err != nil {
+		t.Fatalf("Activate failed: %v", err)
+	}
+	if data, err := os.ReadFile(dataPath("accounts", "work", "auth.json")); err != nil || string(data) != "work, refreshed" {
+		t.Errorf("the interrupted ~/.codex should have been recovered and saved, got %q (%v)", data, err)
+	}
+	if _, err := os.Stat(codexDir + ".old"); !os.IsNotExist(err) {
+		t.Error("no replaced directory should be left behind")
+	}
+}
+
+func TestDirectoryRepository_ActivateContext(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	codexDir := filepath.Join(tmpDir, ".codex")
+	if err := os.MkdirAll(codexDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	repo := storage.NewDirectoryRepository()
+	for _, name := range []string{"personal", "work"} {
+		if err := os.WriteFile(filepath.Join(codexDir, "auth.json"), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repo.Save(name); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	// Cancelled as the copy into ~/.codex starts, such as on Ctrl+C
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	restore := storage.SetFaultHook(func(op, path string) error {
+		if op == storage.OpMkdir && path == codexDir+".tmp" {
+			cancel()
+		}
+		return nil
+	})
+	err := repo.ActivateContext(ctx, "personal")
+	restore()
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the switch to be cancelled, got %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(codexDir, "auth.json")); err != nil || string(data) != "work" {
+		t.Errorf("a cancelled switch should leave ~/.codex as it was, got %q (%v)", data, err)
+	}
+	if current, _ := repo.Current(); current != "work" {
+		t.Errorf("expected work to stay current, got %s", current)
+	}
+
+	// Nothing starts under a context that is already done
+	if _, err := repo.SaveContext(ctx, "other"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected SaveContext to be cancelled, got %v", err)
+	}
+	if _, err := repo.Get("other"); err == nil {
+		t.Error("a cancelled save should not create the account")
+	}
+	if err := repo.DeleteContext(ctx, "work"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected DeleteContext to be cancelled, got %v", err)
+	}
+
+	// The next switch picks up where the cancelled one stopped
+	if err := repo.ActivateContext(context.Background(), "personal"); err != nil {
+		t.Fatalf("ActivateContext failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(codexDir, "auth.json")); err != nil || string(data) != "personal" {
+		t.Errorf("expected personal in ~/.codex, got %q (%v)", data, err)
+	}
+}
+
+func TestDirectoryRepository_Progress(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	sessions := filepath.Join(tmpDir, ".codex", "sessions")
+	if err := os.MkdirAll(sessions, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i := range 10 {
+		if err := os.WriteFile(filepath.Join(sessions, fmt.Sprintf("%d.jsonl", i)), make([]byte, 10_000), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Slow enough to report several times
+	repo := storage.NewDirectoryRepository()
+	repo.SetIOLimit(100_000)
+	var reports []storage.Progress
+	repo.SetProgress(func(p storage.Progress) {
+		reports = append(reports, p)
+	})
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	if len(reports) == 0 {
+		t.Fatal("expected progress reports")
+	}
+	for i, p := range reports {
+		if p.Op != "save" || p.Account != "work" || p.TotalFiles != 10 || p.TotalBytes != 100_000 {
+			t.Errorf("unexpected report %+v", p)
+		}
+		if p.Files > p.TotalFiles || p.Percent() > 100 || (i > 0 && p.Bytes < reports[i-1].Bytes) {
+			t.Errorf("report %d out of order: %+v", i, p)
+		}
+	}
+}
+
+func TestDirectoryRepository_Dedup(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	sessions := filepath.Join(tmpDir, ".codex", "sessions")
+	if err := os.MkdirAll(sessions, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sessions, "run.jsonl"), []byte("turn"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := storage.NewDirectoryRepository()
+	repo.SetDedup(true)
+	repo.SetSnapshots(0)
+	repo.SetTrashRetention(0)
+	for _, name := range []string{"work", "home"} {
+		if _, err := repo.Save(name); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	sameFile := func(a, b string) bool {
+		ai, err := os.Stat(dataPath("accounts", a, "sessions", "run.jsonl"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		bi, err := os.Stat(dataPath("accounts", b, "sessions", "run.jsonl"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return os.SameFile(ai, bi)
+	}
+	if !sameFile("work", "home") {
+		t.Fatal("identical session files should be stored once")
+	}
+
+	// Codex writes into a linked account, which gets its own copy
+	repo.SetActivation(storage.ActivateLink)
+	if err := repo.Activate("work"); err != nil {
+		t.Fatalf("Activate failed: %v", err)
+	}
+	if sameFile("work", "home") {
+		t.Error("a linked account should not share its session files")
+	}
+
+	// The blob is in use until the last account linking it goes
+	if result, err := repo.GC(false); err != nil || result.Blobs != 0 {
+		t.Fatalf("GC = %+v, %v; want nothing removed", result, err)
+	}
+	if err := repo.Delete("home"); err != nil {
+		t.Fatal(err)
+	}
+	result, err := repo.GC(true)
+	if err != nil || result.Blobs != 1 || result.Bytes != 4 {
+		t.Fatalf("GC dry run = %+v, %v; want one blob of 4 bytes", result, err)
+	}
+	if result, err := repo.GC(false); err != nil || result.Blobs != 1 {
+		t.Fatalf("GC = %+v, %v; want one blob removed", result, err)
+	}
+	if entries, _ := os.ReadDir(dataPath("blobs")); len(entries) != 0 {
+		t.Errorf("expected an empty blob store, got %d entries", len(entries))
+	}
+}
+
+func TestDirectoryRepository_SaveRecoversInterruptedSwap(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	codexDir := filepath.Join(tmpDir, ".codex")
+	if err := os.MkdirAll(codexDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(codexDir, "auth.json"), []byte("work"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := storage.NewDirectoryRepository()
+	for _, name := range []string{"work", "notes.old"} {
+		if _, err := repo.Save(name); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	// A save that died between its two renames leaves the account moved
+	// aside, which listing does not mistake for an account
+	if err := os.Rename(dataPath("accounts", "work"), dataPath("accounts", "work.old")); err != nil {
+		t.Fatal(err)
+	}
+	accounts, err := repo.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, acc := range accounts {
+		if acc.Name == "work.old" || acc.Name == "work" {
+			t.Errorf("List should skip the moved-aside copy, got %s", acc.Name)
+		}
+	}
+
+	// The next change puts it back, and leaves a real account alone
+	if _, err := repo.Save("home"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(dataPath("accounts", "work", "auth.json")); err != nil || string(data) != "work" {
+		t.Errorf("work should be recovered, got %q (%v)", data, err)
+	}
+	if _, err := repo.Get("notes.old"); err != nil {
+		t.Errorf("an account named notes.old should be kept: %v", err)
+	}
+}
+
+func TestDirectoryRepository_Repair(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	codexDir := filepath.Join(tmpDir, ".codex")
+	if err := os.MkdirAll(codexDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(codexDir, "auth.json"), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := storage.NewDirectoryRepository()
+	for _, name := range []string{"work", "home", "broken"} {
+		if _, err := repo.Save(name); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	// A copy made by hand, an account renamed by hand, corrupt metadata
+	// and state naming an account that is gone
+	copied := dataPath("accounts", "copied")
+	if err := os.MkdirAll(copied, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(copied, "auth.json"), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(dataPath("accounts", "home"), dataPath("accounts", "personal")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dataPath("accounts", "broken", ".account.json"), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SetState(&storage.State{Current: "gone", Previous: "work"}); err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := func(results []storage.RepairResult) map[string]string {
+		got := make(map[string]string)
+		for _, result := range results {
+			got[result.Name] = result.Kind
+		}
+		return got
+	}
+	want := map[string]string{
+		"copied":   storage.RepairMetadata,
+		"personal": storage.RepairName,
+		"broken":   storage.RepairUnreadable,
+		"gone":     storage.RepairState,
+	}
+
+	results, err := repo.Repair(true)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	if got := kinds(results); !maps.Equal(got, want) {
+		t.Errorf("dry run = %v, want %v", got, want)
+	}
+	for _, result := range results {
+		if result.Fixed {
+			t.Errorf("dry run should fix nothing, fixed %s", result.Name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(copied, ".account.json")); !os.IsNotExist(err) {
+		t.Error("dry run should not write metadata")
+	}
+
+	results, err = repo.Repair(false)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	if got := kinds(results); !maps.Equal(got, want) {
+		t.Errorf("Repair = %v, want %v", got, want)
+	}
+	for _, result := range results {
+		if result.Fixed == (result.Kind == storage.RepairUnreadable) {
+			t.Errorf("%s: fixed = %v", result.Name, result.Fixed)
+		}
+	}
+
+	if acc, err := repo.Get("copied"); err != nil || acc.Name != "copied" {
+		t.Errorf("copied should have metadata, got %v (%v)", acc, err)
+	}
+	if acc, err := repo.Get("personal"); err != nil || acc.Name != "personal" {
+		t.Errorf("personal should be named after its directory, got %v (%v)", acc, err)
+	}
+	state, err := repo.State()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Current != "" || state.Previous != "work" {
+		t.Errorf("state = %+v, want only work as previous", state)
+	}
+
+	// Only what cannot be fixed is left
+	results, err = repo.Repair(false)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	if got := kinds(results); !maps.Equal(got, map[string]string{"broken": storage.RepairUnreadable}) {
+		t.Errorf("second Repair = %v", got)
+	}
+}
+
+func TestDirectoryRepository_SaveExcludes(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	codexDir := filepath.Join(tmpDir, ".codex")
+	accountDir := dataPath("accounts", "work")
+	for name, content := range map[string]string{
+		"auth.json":                  "{}",
+		"cache/models.bin":           "large",
+		"sessions/2024/run.log":      "noise",
+		"sessions/2024/run.jsonl":    "turn",
+		"sessions/2024/nested/x.log": "noise",
+	} {
+		path := filepath.Join(codexDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repo := storage.NewDirectoryRepository()
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	repo.SetExcludes([]string{"cache/", "sessions/**/*.log"})
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	for _, name := range []string{"cache", "sessions/2024/run.log", "sessions/2024/nested/x.log"} {
+		if _, err := os.Stat(filepath.Join(accountDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s should be left out of the account", name)
+		}
+	}
+	for _, name := range []string{"auth.json", "sessions/2024/run.jsonl"} {
+		if _, err := os.Stat(filepath.Join(accountDir, name)); err != nil {
+			t.Errorf("%s should be saved: %v", name, err)
+		}
+	}
+}
+
+func TestDirectoryRepository_SaveSelected(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	codexDir := filepath.Join(tmpDir, ".codex")
+	accountDir := dataPath("accounts", "work")
+	write := func(files map[string]string) {
+		t.Helper()
+		for name, content := range files {
+			path := filepath.Join(codexDir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	check := func(want map[string]string) {
+		t.Helper()
+		for name, content := range want {
+			data, err := os.ReadFile(filepath.Join(accountDir, name))
+			if err != nil {
+				t.Errorf("%s should be in the account: %v", name, err)
+			} else if string(data) != content {
+				t.Errorf("%s = %q, want %q", name, data, content)
+			}
+		}
+	}
+
+	write(map[string]string{
+		"auth.json":             "old-auth",
+		"config.toml":           "old-config",
+		"sessions/2024/a.jsonl": "old-session",
+	})
+	repo := storage.NewDirectoryRepository()
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	write(map[string]string{
+		"auth.json":             "new-auth",
+		"config.toml":           "new-config",
+		"sessions/2024/a.jsonl": "new-session",
+		"sessions/2024/b.jsonl": "other-session",
+	})
+	sel := storage.Selection{Include: []string{"auth.json", "config.toml"}}
+	if _, err := repo.SaveSelected(context.Background(), "work", sel, false); err != nil {
+		t.Fatalf("SaveSelected failed: %v", err)
+	}
+	check(map[string]string{
+		"auth.json":             "new-auth",
+		"config.toml":           "new-config",
+		"sessions/2024/a.jsonl": "old-session",
+	})
+	if _, err := os.Stat(filepath.Join(accountDir, "sessions", "2024", "b.jsonl")); !os.IsNotExist(err) {
+		t.Error("a session left out of the save should not be added")
+	}
+
+	write(map[string]string{"config.toml": "newer-config"})
+	sel = storage.Selection{Exclude: []string{"config.toml"}}
+	if _, err := repo.SaveSelected(context.Background(), "work", sel, false); err != nil {
+		t.Fatalf("SaveSelected failed: %v", err)
+	}
+	check(map[string]string{
+		"config.toml":           "new-config",
+		"sessions/2024/a.jsonl": "new-session",
+		"sessions/2024/b.jsonl": "other-session",
+	})
+
+	sel = storage.Selection{Include: []string{"auth.json"}}
+	if _, err := repo.SaveSelected(context.Background(), "fresh", sel, false); err != nil {
+		t.Fatalf("SaveSelected failed: %v", err)
+	}
+	if _, err := os.Stat(dataPath("accounts", "fresh", "config.toml")); !os.IsNotExist(err) {
+		t.Error("a new account should only get the paths saved")
+	}
+}
+
+func TestDirectoryRepository_Compress(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	codexDir := filepath.Join(tmpDir, ".codex")
+	accountDir := dataPath("accounts", "old")
+	if err := os.MkdirAll(filepath.Join(codexDir, "sessions", "2024"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(codexDir, "auth.json"), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(codexDir, "sessions", "2024", "run.jsonl"), []byte("turn\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := storage.NewDirectoryRepository()
+	if _, err := repo.Save("old"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := repo.Compress("old"); err == nil {
+		t.Error("compressing the current account should fail")
+	}
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	if err := repo.Compress("old"); err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(accountDir, "sessions")); !os.IsNotExist(err) {
+		t.Error("sessions should be packed away")
+	}
+	if _, err := os.Stat(filepath.Join(accountDir, "auth.json")); err != nil {
+		t.Errorf("top-level files should stay readable: %v", err)
+	}
+	if acc, err := repo.Get("old"); err != nil || !acc.Compressed {
+		t.Errorf("account should report being compressed, got %+v (%v)", acc, err)
+	}
+
+	if err := repo.Activate("old"); err != nil {
+		t.Fatalf("Activate failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(codexDir, "sessions", "2024", "run.jsonl")); err != nil || string(data) != "turn\n" {
+		t.Errorf("switching should unpack the sessions, got %q (%v)", data, err)
+	}
+	if acc, err := repo.Get("old"); err != nil || acc.Compressed {
+		t.Errorf("account should be unpacked after switching, got %+v (%v)", acc, err)
+	}
+	if d, err := repo.Diff("old", ""); err != nil || !d.Empty() {
+		t.Errorf("unpacked account should match ~/.codex, got %+v (%v)", d, err)
+	}
+}
+
+func TestDirectoryRepository_Encrypt(t *testing.T) {
+	crypt.WorkFactor = 10
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	codexDir := filepath.Join(tmpDir, ".codex")
+	accountDir := dataPath("accounts", "work")
+	if err := os.MkdirAll(filepath.Join(codexDir, "sessions"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(codexDir, "auth.json"), []byte(`{"token":"secret"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(codexDir, "sessions", "run.jsonl"), []byte("turn\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	asked := 0
+	repo := storage.NewDirectoryRepository()
+	repo.SetKeySource(func() ([]byte, error) {
+		asked++
+		return []byte("hunter2"), nil
+	})
+	repo.SetEncryption(true)
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(accountDir, "auth.json")); !os.IsNotExist(err) {
+		t.Error("auth.json should only be stored sealed")
+	}
+	if acc, err := repo.Get("work"); err != nil || !acc.Encrypted {
+		t.Errorf("account should report being encrypted, got %+v (%v)", acc, err)
+	}
+	if d, err := repo.Diff("work", ""); err != nil || !d.Empty() {
+		t.Errorf("sealed account should match ~/.codex, got %+v (%v)", d, err)
+	}
+	if _, err := os.Stat(filepath.Join(accountDir, "auth.json")); !os.IsNotExist(err) {
+		t.Error("reading the account should leave it sealed")
+	}
+
+	if _, err := repo.Save("other"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(codexDir, "auth.json"), []byte(`{"token":"other"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Activate("work"); err != nil {
+		t.Fatalf("Activate failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(codexDir, "auth.json")); err != nil || string(data) != `{"token":"secret"}` {
+		t.Errorf("switching should decrypt into ~/.codex, got %q (%v)", data, err)
+	}
+	if asked != 1 {
+		t.Errorf("passphrase asked %d times, want once per repository", asked)
+	}
+
+	wrong := storage.NewDirectoryRepository()
+	wrong.SetKeySource(func() ([]byte, error) { return []byte("wrong"), nil })
+	if err := wrong.Activate("other"); !errors.Is(err, crypt.ErrDecrypt) {
+		t.Errorf("wrong passphrase should fail with ErrDecrypt, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(codexDir, "auth.json")); string(data) != `{"token":"secret"}` {
+		t.Error("a failed decryption should leave ~/.codex alone")
+	}
+
+	if err := repo.Unseal("other"); err != nil {
+		t.Fatalf("Unseal failed: %v", err)
+	}
+	otherDir := dataPath("accounts", "other")
+	if data, err := os.ReadFile(filepath.Join(otherDir, "auth.json")); err != nil || string(data) != `{"token":"other"}` {
+		t.Errorf("decrypted account should be readable, got %q (%v)", data, err)
+	}
+}
+
+func TestDirectoryRepository_RequireEncryption(t *testing.T) {
+	crypt.WorkFactor = 10
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	codexDir := filepath.Join(tmpDir, ".codex")
+	if err := os.MkdirAll(filepath.Join(codexDir, "sessions"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(codexDir, "auth.json"), []byte(`{"token":"secret"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(codexDir, "sessions", "run.jsonl"), []byte("secret turn\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := storage.NewDirectoryRepository()
+	repo.SetPolicy(&policy.Policy{RequireEncryption: true})
+	repo.SetKeySource(func() ([]byte, error) { return []byte("hunter2"), nil })
+	if _, err := repo.Save("work"); !errors.Is(err, policy.ErrEncryptionRequired) {
+		t.Fatalf("expected saving unencrypted to be refused, got %v", err)
+	}
+
+	repo.SetEncryption(true)
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save with encryption on failed: %v", err)
+	}
+
+	entries, err := os.ReadDir(dataPath("accounts", "work"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if !slices.Equal(names, []string{".account.json", ".cxa-sealed"}) {
+		t.Errorf("expected only metadata and the sealed archive stored, got %v", names)
+	}
+	err = filepath.WalkDir(dataPath(), func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if bytes.Contains(data, []byte("secret")) {
+			t.Errorf("%s holds account data in the clear", path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// memKeyring is a Keyring kept in memory.
+type memKeyring map[string][]byte
+
+func (k memKeyring) Set(name string, secret []byte) error { k[name] = secret; return nil }
+
+func (k memKeyring) Get(name string) ([]byte, error) {
+	secret, ok := k[name]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return secret, nil
+}
+
+func (k memKeyring) Delete(name string) error { delete(k, name); return nil }
+
+func TestDirectoryRepository_Keyring(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	codexDir := filepath.Join(tmpDir, ".codex")
+	accountsDir := dataPath("accounts")
+	if err := os.MkdirAll(codexDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeAuth := func(token string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(codexDir, "auth.json"), []byte(token), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keyring := memKeyring{}
+	repo := storage.NewDirectoryRepository()
+	repo.SetKeyring(keyring, true)
+	writeAuth("work-token")
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(accountsDir, "work", "auth.json")); !os.IsNotExist(err) {
+		t.Error("auth.json should not be stored in the account directory")
+	}
+	if string(keyring["work"]) != "work-token" {
+		t.Errorf("keyring holds %q, want the saved token", keyring["work"])
+	}
+	if acc, err := repo.Get("work"); err != nil || !acc.Keychain {
+		t.Errorf("account should report its credentials in the keychain, got %+v (%v)", acc, err)
+	}
+	if d, err := repo.Diff("work", ""); err != nil || !d.Empty() {
+		t.Errorf("credentials in the keychain should not show as a difference, got %+v (%v)", d, err)
+	}
+	if result, err := repo.Verify("work"); err != nil || len(result.Issues) > 0 {
+		t.Errorf("account should verify, got %+v (%v)", result, err)
+	}
+
+	writeAuth("home-token")
+	if _, err := repo.Save("home"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := repo.Activate("work"); err != nil {
+		t.Fatalf("Activate failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(codexDir, "auth.json")); err != nil || string(data) != "work-token" {
+		t.Errorf("switching should write the credentials into ~/.codex, got %q (%v)", data, err)
+	}
+
+	if err := repo.Rename("home", "personal"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if _, ok := keyring["home"]; ok || string(keyring["personal"]) != "home-token" {
+		t.Errorf("rename should move the credentials, keyring is %v", keyring)
+	}
+
+	if err := repo.Delete("personal"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, ok := keyring["personal"]; ok {
+		t.Error("deleting the account should remove its credentials")
+	}
+	if n, err := repo.EmptyTrash(); err != nil || n != 1 {
+		t.Fatalf("EmptyTrash = %d, %v", n, err)
+	}
+
+	// Turning the keychain off moves credentials back on the next stow
+	repo.SetKeyring(keyring, false)
+	if err := repo.Stow("work"); err != nil {
+		t.Fatalf("Stow failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(accountsDir, "work", "auth.json")); err != nil || string(data) != "work-token" {
+		t.Errorf("credentials should be back in the account, got %q (%v)", data, err)
+	}
+	if len(keyring) != 0 {
+		t.Errorf("restored credentials should leave the keyring, keyring is %v", keyring)
+	}
+}
+
+func TestDirectoryRepository_Index(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	codexDir := filepath.Join(tmpDir, ".codex")
+	indexFile := statePath("index.json")
+	if err := os.MkdirAll(codexDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(codexDir, "auth.json"), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := storage.NewDirectoryRepository()
+	for _, name := range []string{"work", "home"} {
+		if _, err := repo.Save(name); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	if accounts, err := repo.List(); err != nil || len(accounts) != 2 {
+		t.Fatalf("List = %v, %v", accounts, err)
+	}
+	if _, err := os.Stat(indexFile); err != nil {
+		t.Fatalf("List should write the index: %v", err)
+	}
+
+	// Metadata edited behind cxa's back is read again
+	if _, err := repo.UpdateMetadata("work", func(acc *account.Account) { acc.Description = "day job" }); err != nil {
+		t.Fatal(err)
+	}
+	metaPath := dataPath("accounts", "home", ".account.json")
+	data, err := os.ReadFile(metaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var meta map[string]any
+	if err := json.Unmarshal(data, &meta); err != nil {
+		t.Fatal(err)
+	}
+	meta["description"] = "edited by hand"
+	if data, err = json.Marshal(meta); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(metaPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	accounts, err := repo.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	descriptions := map[string]string{}
+	for _, acc := range accounts {
+		descriptions[acc.Name] = acc.Description
+	}
+	if descriptions["work"] != "day job" || descriptions["home"] != "edited by hand" {
+		t.Errorf("List should see every change, got %v", descriptions)
+	}
+
+	if err := repo.DeleteForce("work"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(indexFile, []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if accounts, err := repo.List(); err != nil || len(accounts) != 1 || accounts[0].Name != "home" {
+		t.Errorf("a corrupt index should be rebuilt, got %v (%v)", accounts, err)
+	}
+	if n, err := repo.RebuildIndex(); err != nil || n != 1 {
+		t.Errorf("RebuildIndex = %d, %v", n, err)
+	}
+}
+
+func TestDirectoryRepository_Snapshots(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	codexDir := filepath.Join(tmpDir, ".codex")
+	if err := os.MkdirAll(codexDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(codexDir, "auth.json"), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	writeNotes := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(codexDir, "notes.txt"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repo := storage.NewDirectoryRepository()
+	repo.SetSnapshots(2)
+	writeNotes("v1")
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if snapshots, err := repo.Snapshots("work"); err != nil || len(snapshots) != 0 {
+		t.Fatalf("a new account has no snapshots, got %v, %v", snapshots, err)
+	}
+
+	writeNotes("v2")
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	snapshots, err := repo.Snapshots("work")
+	if err != nil || len(snapshots) != 1 {
+		t.Fatalf("Snapshots = %v, %v", snapshots, err)
+	}
+	first := snapshots[0].ID
+
+	// Saving the same files twice keeps one snapshot of them
+	for range 2 {
+		if _, err := repo.Save("work"); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	if snapshots, err = repo.Snapshots("work"); err != nil || len(snapshots) != 2 {
+		t.Fatalf("Snapshots = %v, %v", snapshots, err)
+	}
+
+	// Only the newest snapshots are kept
+	writeNotes("v3")
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	writeNotes("v4")
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if snapshots, err = repo.Snapshots("work"); err != nil || len(snapshots) != 2 {
+		t.Fatalf("Snapshots = %v, %v", snapshots, err)
+	}
+	for _, s := range snapshots {
+		if s.ID == first {
+			t.Errorf("the oldest snapshot %s should be dropped", first)
+		}
+	}
+
+	// Rolling back the current account restores ~/.codex too
+	target := snapshots[1].ID
+	if err := repo.Rollback("work", target); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(codexDir, "notes.txt"))
+	if err != nil || string(data) != "v2" {
+		t.Errorf("notes after rollback = %q, %v", data, err)
+	}
+	if snapshots, err = repo.Snapshots("work"); err != nil || len(snapshots) != 2 {
+		t.Fatalf("Snapshots = %v, %v", snapshots, err)
+	}
+	if err := repo.Rollback("work", "nope"); err == nil {
+		t.Error("Rollback to an unknown snapshot should fail")
+	}
+
+	if err := repo.Rename("work", "job"); err != nil {
+		t.Fatal(err)
+	}
+	if snapshots, err = repo.Snapshots("job"); err != nil || len(snapshots) != 2 {
+		t.Errorf("snapshots should follow a rename, got %v, %v", snapshots, err)
+	}
+}
+
+func TestDirectoryRepository_Trash(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	codexDir := filepath.Join(tmpDir, ".codex")
+	if err := os.MkdirAll(codexDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(codexDir, "auth.json"), []byte("work-token"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	keyring := memKeyring{}
+	repo := storage.NewDirectoryRepository()
+	repo.SetKeyring(keyring, true)
+	for range 2 {
+		if _, err := repo.Save("work"); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	if _, err := repo.UpdateMetadata("work", func(acc *account.Account) { acc.Description = "day job" }); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete("work"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := repo.Get("work"); err == nil {
+		t.Fatal("a deleted account should be gone")
+	}
+
+	entries, err := repo.Trash()
+	if err != nil || len(entries) != 1 || entries[0].Name != "work" {
+		t.Fatalf("Trash = %v, %v", entries, err)
+	}
+	acc, err := repo.RestoreTrash(entries[0].ID, "")
+	if err != nil {
+		t.Fatalf("RestoreTrash failed: %v", err)
+	}
+	if acc.Description != "day job" || !acc.Keychain || string(keyring["work"]) != "work-token" {
+		t.Errorf("restored account lost its metadata or credentials: %+v, keyring %v", acc, keyring)
+	}
+	if entries, err := repo.Trash(); err != nil || len(entries) != 0 {
+		t.Errorf("a restored account should leave the trash, got %v, %v", entries, err)
+	}
+
+	// Restoring over an existing account needs another name
+	if err := repo.Delete("work"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ = repo.Trash()
+	if _, err := repo.RestoreTrash(entries[0].ID, ""); err == nil {
+		t.Error("restoring over an existing account should fail")
+	}
+	if acc, err := repo.RestoreTrash(entries[0].ID, "old-work"); err != nil || acc.Name != "old-work" {
+		t.Errorf("RestoreTrash under a new name = %+v, %v", acc, err)
+	}
+
+	// Without retention, deleting removes the account at once
+	repo.SetTrashRetention(0)
+	if err := repo.Delete("old-work"); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := repo.Trash(); err != nil || len(entries) != 0 {
+		t.Errorf("Trash = %v, %v", entries, err)
+	}
+	if _, ok := keyring["old-work"]; ok {
+		t.Error("deleting for good should remove the credentials")
+	}
+}
+
+func TestDirectoryRepository_MoveState(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	codexDir := filepath.Join(tmpDir, ".codex")
+	if err := os.MkdirAll(codexDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(codexDir, "auth.json"), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := storage.NewDirectoryRepository()
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	src := repo.Paths().StateDir
+	dst := filepath.Join(tmpDir, "state")
+	if err := repo.MoveState(dst); err != nil {
+		t.Fatalf("MoveState failed: %v", err)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Error("the old state directory should be removed")
+	}
+	if repo.Paths().StateDir != dst {
+		t.Errorf("StateDir = %s, want %s", repo.Paths().StateDir, dst)
+	}
+	if current, err := repo.Current(); err != nil || current != "work" {
+		t.Errorf("Current = %q, %v", current, err)
+	}
+
+	// Later operations record into the new location
+	if _, err := repo.Save("home"); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := history.NewLog(filepath.Join(dst, "history.log")).Recent("", 0)
+	if err != nil || len(entries) != 2 {
+		t.Errorf("history = %v, %v", entries, err)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Error("nothing should write to the old state directory")
+	}
+}
+
+func TestDirectoryRepository_RemoteBackend(t *testing.T) {
+	remoteDir := filepath.Join(t.TempDir(), "remote")
+
+	// Two machines keeping accounts on one remote
+	newMachine := func() (string, *storage.DirectoryRepository) {
+		home := t.TempDir()
+		if err := os.MkdirAll(filepath.Join(home, ".codex"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		repo := storage.NewDirectoryRepositoryWithPaths(codex.NewPathsAt(home))
+		if err := repo.SetBackend("remote", remoteDir); err != nil {
+			t.Fatalf("SetBackend failed: %v", err)
+		}
+		return home, repo
+	}
+	writeConfig := func(home, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(home, ".codex", "config.toml"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	liveConfig := func(home string) string {
+		data, _ := os.ReadFile(filepath.Join(home, ".codex", "config.toml"))
+		return string(data)
+	}
+
+	laptopHome, laptop := newMachine()
+	desktopHome, desktop := newMachine()
+	if laptop.Backend() != "remote" {
+		t.Errorf("Backend = %s, want remote", laptop.Backend())
+	}
+
+	// Saving pushes, and activating on another machine pulls
+	writeConfig(laptopHome, "model = \"a\"")
+	if _, err := laptop.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := desktop.Activate("work"); err != nil {
+		t.Fatalf("Activate of an account only on the remote failed: %v", err)
+	}
+	if got := liveConfig(desktopHome); got != "model = \"a\"" {
+		t.Errorf("desktop config = %q after activating", got)
+	}
+
+	// Changes flow back the other way
+	writeConfig(desktopHome, "model = \"b\"")
+	if _, err := desktop.Save("work"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := laptop.Save("home"); err != nil {
+		t.Fatal(err)
+	}
+	if err := laptop.Activate("work"); err != nil {
+		t.Fatal(err)
+	}
+	if got := liveConfig(laptopHome); got != "model = \"b\"" {
+		t.Errorf("laptop config = %q, want the desktop's change", got)
+	}
+	if w, _ := warnings.NewStore(laptop.Paths().WarningsFile()).List(); len(w) > 0 {
+		t.Errorf("unexpected warnings: %+v", w)
+	}
+
+	// Deleting takes the account off the remote index
+	if err := desktop.DeleteForce("home"); err == nil {
+		t.Error("home was never fetched on the desktop, so deleting it should fail")
+	}
+	if err := laptop.Delete("home"); err != nil {
+		t.Fatal(err)
+	}
+	tr, _ := remote.Open(remoteDir)
+	index, err := tr.ReadIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := index.Accounts["home"]; ok {
+		t.Error("deleted account should leave the remote index")
+	}
+	if _, ok := index.Accounts["work"]; !ok {
+		t.Error("work should stay on the remote")
+	}
+
+	// A remote copy that does not match the index is never pulled
+	if err := os.WriteFile(filepath.Join(remoteDir, "accounts", "work", "config.toml"), []byte("tampered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, fresh := newMachine()
+	if err := fresh.Activate("work"); err == nil {
+		t.Error("activating a damaged remote copy should fail")
+	}
+	if w, _ := warnings.NewStore(fresh.Paths().WarningsFile()).List(); len(w) == 0 {
+		t.Error("the refused pull should be kept as a warning")
+	}
+
+	if err := laptop.SetBackend("nope", ""); err == nil {
+		t.Error("an unknown backend should be refused")
+	}
+}
+
+func TestDirectoryRepository_GitBackend(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	// Keep the user's git configuration out of the test
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	origin := filepath.Join(t.TempDir(), "accounts.git")
+	if out, err := exec.Command("git", "init", "--quiet", "--bare", origin).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v: %s", err, out)
+	}
+	gitLog := func(repo *storage.DirectoryRepository) string {
+		t.Helper()
+		out, err := exec.Command("git", "-C", repo.Paths().DataDir, "log", "--format=%s").Output()
+		if err != nil {
+			t.Fatalf("git log failed: %v", err)
+		}
+		return string(out)
+	}
+	newMachine := func() (string, *storage.DirectoryRepository) {
+		home := t.TempDir()
+		if err := os.MkdirAll(filepath.Join(home, ".codex"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		repo := storage.NewDirectoryRepositoryWithPaths(codex.NewPathsAt(home))
+		if err := repo.SetBackend("git", origin); err != nil {
+			t.Fatalf("SetBackend failed: %v", err)
+		}
+		return home, repo
+	}
+	writeConfig := func(home, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(home, ".codex", "config.toml"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	laptopHome, laptop := newMachine()
+	writeConfig(laptopHome, "model = \"a\"")
+	if _, err := laptop.Save("work"); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := laptop.Rename("work", "job"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if got := gitLog(laptop); got != "rename work to job\nsave work\ntrack accounts\n" {
+		t.Errorf("git log = %q", got)
+	}
+
+	// A second machine picks up the pushed history and its accounts
+	desktopHome, desktop := newMachine()
+	if err := desktop.Activate("job"); err != nil {
+		t.Fatalf("Activate failed: %v", err)
+	}
+	writeConfig(desktopHome, "model = \"b\"")
+	if _, err := desktop.Save("job"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := laptop.Activate("job"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(filepath.Join(laptopHome, ".codex", "config.toml"))
+	if string(data) != "model = \"b\"" {
+		t.Errorf("laptop config = %q, want the desktop's change", data)
+	}
+	if w, _ := warnings.NewStore(laptop.Paths().WarningsFile()).List(); len(w) > 0 {
+		t.Errorf("unexpected warnings: %+v", w)
+	}
+	if accounts, err := laptop.List(); err != nil || len(accounts) != 1 {
+		t.Errorf("List = %v, %v; the repository should not show up as an account", accounts, err)
+	}
+
+	// Commits made on both machines in between are put in a line
+	if _, err := desktop.Save("home"); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(laptopHome, "model = \"c\"")
+	if _, err := laptop.Save("job"); err != nil {
+		t.Fatal(err)
+	}
+	if w, _ := warnings.NewStore(laptop.Paths().WarningsFile()).List(); len(w) > 0 {
+		t.Errorf("unexpected warnings after pushing over another machine: %+v", w)
+	}
+	if _, err := os.Stat(laptop.Paths().AccountPath("home")); err != nil {
+		t.Errorf("the desktop's new account should be pulled: %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package storage
+
+import "sync"
+
+// Filesystem operations reported to a fault hook.
+const (
+	OpCopy    = "copy"
+	OpMkdir   = "mkdir"
+	OpSymlink = "symlink"
+	OpRename  = "rename"
+)
+
+var (
+	faultMu   sync.RWMutex
+	faultHook func(op, path string) error
+)
+
+// SetFaultHook installs a hook called before each filesystem operation of
+// the copy engine with the operation and target path. A non-nil error from
+// the hook fails the operation. It exists for failure-injection tests and
+// returns a function restoring the previous hook.
+func SetFaultHook(hook func(op, path string) error) (restore func()) {
+	faultMu.Lock()
+	prev := faultHook
+	faultHook = hook
+	faultMu.Unlock()
+
+	return func() {
+		faultMu.Lock()
+		faultHook = prev
+		faultMu.Unlock()
+	}
+}
+
+// fault consults the installed fault hook, if any.
+func fault(op, path string) error {
+	faultMu.RLock()
+	hook := faultHook
+	faultMu.RUnlock()
+
+	if hook == nil {
+		return nil
+	}
+	return hook(op, path)
+}
@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// gitBranch is the branch the git backend commits to and syncs.
+const gitBranch = "main"
+
+// gitIgnore keeps everything but the accounts out of the git repository,
+// and within them the staging directories of unfinished copies.
+const gitIgnore = `/*
+!/.gitignore
+!/accounts/
+*` + saveStagingSuffix + `/
+*` + replacedSuffix + `/
+`
+
+func init() {
+	RegisterBackend("git", openGitBackend)
+}
+
+// gitBackend keeps the accounts directory in a git repository at the root
+// of the data directory, committing every change with a message naming
+// the operation and the account. With a URL, commits are pushed to it and
+// activating an account pulls from it first.
+type gitBackend struct {
+	r   *DirectoryRepository
+	url string
+
+	// renamed is the old name of an account being renamed, whose removal
+	// is committed together with the new name.
+	renamed string
+}
+
+func openGitBackend(r *DirectoryRepository, url string) (Backend, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git is not installed")
+	}
+	b := &gitBackend{r: r, url: url}
+	if _, err := os.Stat(filepath.Join(r.paths.DataDir, ".git")); err == nil {
+		return b, nil
+	}
+	if err := b.init(); err != nil {
+		return nil, fmt.Errorf("failed to set up git repository in %s: %w", r.paths.DataDir, err)
+	}
+	return b, nil
+}
+
+// init creates the repository and commits the accounts already saved.
+func (b *gitBackend) init() error {
+	if err := os.MkdirAll(b.r.paths.AccountsDir(), 0700); err != nil {
+		return err
+	}
+	if _, err := b.git("init", "--quiet"); err != nil {
+		return err
+	}
+	if _, err := b.git("symbolic-ref", "HEAD", "refs/heads/"+gitBranch); err != nil {
+		return err
+	}
+	// Commits need an author; use the user's own where git has one
+	if email, _ := b.git("config", "user.email"); email == "" {
+		host, _ := os.Hostname()
+		if _, err := b.git("config", "user.name", "cxa"); err != nil {
+			return err
+		}
+		if _, err := b.git("config", "user.email", "cxa@"+host); err != nil {
+			return err
+		}
+	}
+	if err := b.adopt(); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(b.r.paths.DataDir, ".gitignore"), []byte(gitIgnore), 0644); err != nil {
+		return err
+	}
+	if _, err := b.git("add", "--all", "--", ".gitignore", "accounts"); err != nil {
+		return err
+	}
+	if _, err := b.git("diff", "--cached", "--quiet"); err == nil {
+		return nil
+	}
+	if _, err := b.git("commit", "--quiet", "--message", "track accounts"); err != nil {
+		return err
+	}
+	b.r.log.Debug("created git repository", "dir", b.r.paths.DataDir)
+	return nil
+}
+
+// adopt builds on the history already at the URL, if any, so machines
+// sharing it can fast-forward to each other. Accounts only at the URL are
+// checked out; accounts saved here are committed over it by init.
+func (b *gitBackend) adopt() error {
+	if b.url == "" {
+		return nil
+	}
+	if _, err := b.git("fetch", "--quiet", b.url, gitBranch); err != nil {
+		if strings.Contains(err.Error(), "couldn't find remote ref") {
+			return nil
+		}
+		return err
+	}
+	if _, err := b.git("reset", "--quiet", "FETCH_HEAD"); err != nil {
+		return err
+	}
+	names, err := b.git("ls-tree", "--name-only", "FETCH_HEAD", "accounts/")
+	if err != nil {
+		return err
+	}
+	for _, path := range strings.Fields(names) {
+		if _, err := os.Lstat(filepath.Join(b.r.paths.DataDir, path)); os.IsNotExist(err) {
+			if _, err := b.git("checkout", "FETCH_HEAD", "--", path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (b *gitBackend) Stored(op, name string) error {
+	msg := op + " " + name
+	if b.renamed != "" {
+		msg = fmt.Sprintf("%s %s to %s", op, b.renamed, name)
+		b.renamed = ""
+	}
+	return b.commit(msg, name)
+}
+
+func (b *gitBackend) Removed(op, name string) error {
+	if op == "rename" {
+		// Staged only; the new name commits it
+		b.renamed = name
+		_, err := b.git("add", "--all", "--", "accounts/"+name)
+		return err
+	}
+	return b.commit(op+" "+name, name)
+}
+
+// commit records the account's changes, if it has any, and pushes them.
+func (b *gitBackend) commit(msg, name string) error {
+	if _, err := b.git("add", "--all", "--", "accounts/"+name); err != nil {
+		return err
+	}
+	if _, err := b.git("diff", "--cached", "--quiet"); err == nil {
+		return nil
+	}
+	if _, err := b.git("commit", "--quiet", "--message", msg); err != nil {
+		return err
+	}
+	b.r.log.Debug("committed account", "account", name, "message", msg)
+	if b.url == "" {
+		return nil
+	}
+	if _, err := b.git("push", "--quiet", b.url, "HEAD:refs/heads/"+gitBranch); err == nil {
+		return nil
+	}
+	// Another machine pushed first; go on from its commits
+	if err := b.pull(); err != nil {
+		return err
+	}
+	_, err := b.git("push", "--quiet", b.url, "HEAD:refs/heads/"+gitBranch)
+	return err
+}
+
+// Fetch pulls what other machines pushed, when there is a URL. Changes
+// not committed yet, such as when accounts were last used, are committed
+// first so the pull can replay them on top.
+func (b *gitBackend) Fetch(name string) error {
+	if b.url == "" {
+		return nil
+	}
+	if _, err := b.git("add", "--all", "--", "accounts"); err != nil {
+		return err
+	}
+	if _, err := b.git("diff", "--cached", "--quiet"); err != nil {
+		changed, err := b.git("diff", "--cached", "--name-only")
+		if err != nil {
+			return err
+		}
+		if _, err := b.git("commit", "--quiet", "--message", "update "+strings.Join(accountNames(changed), ", ")); err != nil {
+			return err
+		}
+	}
+	return b.pull()
+}
+
+// pull rebases local commits onto the branch at the URL. When both sides
+// changed the same file, the rebase is undone and left for the user to
+// settle with git.
+func (b *gitBackend) pull() error {
+	before, _ := b.git("rev-parse", "HEAD")
+	if _, err := b.git("pull", "--quiet", "--rebase", b.url, gitBranch); err != nil {
+		if strings.Contains(err.Error(), "couldn't find remote ref") {
+			// Nothing pushed yet
+			return nil
+		}
+		if _, abortErr := b.git("rebase", "--abort"); abortErr == nil {
+			return fmt.Errorf("accounts changed both here and at %s; merge them with git in %s", b.url, b.r.paths.DataDir)
+		}
+		return err
+	}
+	after, _ := b.git("rev-parse", "HEAD")
+	if before == "" || before == after {
+		return nil
+	}
+
+	// Accounts pulled in are not what their warm cache entries hold
+	changed, err := b.git("diff", "--name-only", before, after, "--", "accounts")
+	if err != nil {
+		return err
+	}
+	for _, name := range accountNames(changed) {
+		_ = os.RemoveAll(b.r.paths.CachePath(name))
+	}
+	return nil
+}
+
+// accountNames returns the accounts the paths git listed are in.
+func accountNames(paths string) []string {
+	var names []string
+	for _, path := range strings.Split(paths, "\n") {
+		parts := strings.Split(path, "/")
+		if len(parts) > 1 && parts[0] == "accounts" && !slices.Contains(names, parts[1]) {
+			names = append(names, parts[1])
+		}
+	}
+	return names
+}
+
+// git runs git on the repository in the data directory and returns its
+// output.
+func (b *gitBackend) git(args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", b.r.paths.DataDir}, args...)...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
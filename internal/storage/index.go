@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/delhombre/cxa/internal/account"
+)
+
+// indexVersion is bumped whenever the index layout changes, so an index
+// written by another version is rebuilt rather than misread.
+const indexVersion = 1
+
+// accountIndex caches the metadata of every account in one file, so List
+// reads a single file rather than one per account. Each entry records the
+// size and time of the metadata it was read from and the time of the
+// account directory; an entry whose account changed since, through cxa or
+// behind its back, is read again. The index is only ever a cache: losing
+// it costs one slow List.
+type accountIndex struct {
+	Version  int                   `json:"version"`
+	Accounts map[string]indexEntry `json:"accounts"`
+}
+
+type indexEntry struct {
+	Account  *account.Account `json:"account"`
+	MetaSize int64            `json:"meta_size"`
+	MetaTime time.Time        `json:"meta_time"`
+	DirTime  time.Time        `json:"dir_time"`
+}
+
+// stamp returns what an index entry for the account at accountPath is
+// checked against. The directory time changes when the account is
+// compressed, encrypted, or has its credentials moved.
+func stamp(accountPath string) (indexEntry, error) {
+	dir, err := os.Stat(accountPath)
+	if err != nil {
+		return indexEntry{}, err
+	}
+	meta, err := os.Stat(filepath.Join(accountPath, ".account.json"))
+	if err != nil {
+		return indexEntry{}, err
+	}
+	return indexEntry{MetaSize: meta.Size(), MetaTime: meta.ModTime(), DirTime: dir.ModTime()}, nil
+}
+
+func (e indexEntry) matches(s indexEntry) bool {
+	return e.Account != nil && e.MetaSize == s.MetaSize && e.MetaTime.Equal(s.MetaTime) && e.DirTime.Equal(s.DirTime)
+}
+
+// loadIndex reads the account index. A missing, unreadable, or outdated
+// index reads as empty.
+func (r *DirectoryRepository) loadIndex() *accountIndex {
+	idx := &accountIndex{Version: indexVersion, Accounts: make(map[string]indexEntry)}
+	data, err := os.ReadFile(r.paths.IndexFile())
+	if err != nil {
+		return idx
+	}
+	var stored accountIndex
+	if json.Unmarshal(data, &stored) != nil || stored.Version != indexVersion || stored.Accounts == nil {
+		r.log.Debug("ignoring unreadable account index")
+		return idx
+	}
+	return &stored
+}
+
+// saveIndex writes the account index. It is a cache, so failing to write
+// it only costs speed and is not reported.
+func (r *DirectoryRepository) saveIndex(idx *accountIndex) {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return
+	}
+	if err := writeFileAtomic(r.paths.IndexFile(), data, 0644); err != nil {
+		r.log.Debug("failed to write account index", "err", err)
+	}
+}
+
+// forgetIndexed drops the index entry of an account whose metadata was
+// just written, so the next List reads it again even where file times are
+// too coarse to show the change.
+func (r *DirectoryRepository) forgetIndexed(name string) {
+	idx := r.loadIndex()
+	if _, ok := idx.Accounts[name]; !ok {
+		return
+	}
+	delete(idx.Accounts, name)
+	r.saveIndex(idx)
+}
+
+// RebuildIndex discards the account index and builds it again from the
+// account directories. It returns the number of accounts indexed.
+func (r *DirectoryRepository) RebuildIndex() (int, error) {
+	if err := os.Remove(r.paths.IndexFile()); err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	accounts, err := r.List()
+	if err != nil {
+		return 0, err
+	}
+	return len(accounts), nil
+}
@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/delhombre/cxa/internal/account"
+)
+
+// codexMarkers are entries whose presence identifies a codex home copy.
+var codexMarkers = []string{"auth.json", "config.toml", "history.jsonl", "sessions"}
+
+// Candidate is a codex home copy found by ScanHomes.
+type Candidate struct {
+	Path string
+	Name string
+	Hash string
+
+	// DuplicateOf names the account or earlier candidate with identical
+	// content. Duplicates are not imported.
+	DuplicateOf string
+}
+
+// ScanHomes looks for codex home copies directly inside dir, such as old
+// manual backups named codex-work or codex-personal. Names are inferred from
+// directory names, made unique against existing accounts, and copies whose
+// content matches an existing account or an earlier copy are marked as
+// duplicates.
+func (r *DirectoryRepository) ScanHomes(dir string) ([]*Candidate, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+
+	taken := make(map[string]bool)
+	seen := make(map[string]string) // content hash -> name
+	for _, acc := range existing {
+		taken[acc.Name] = true
+		hash, err := hashTree(r.paths.AccountPath(acc.Name))
+		if err != nil {
+			continue
+		}
+		seen[hash] = acc.Name
+	}
+
+	var candidates []*Candidate
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !entry.IsDir() || !isCodexHome(path) {
+			continue
+		}
+
+		hash, err := hashTree(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		candidate := &Candidate{Path: path, Hash: hash}
+		if name, ok := seen[hash]; ok {
+			candidate.DuplicateOf = name
+		} else {
+			candidate.Name = uniqueName(inferName(entry.Name()), taken)
+			taken[candidate.Name] = true
+			seen[hash] = candidate.Name
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	return candidates, nil
+}
+
+// ImportDir stores a copy of the codex home at src as a new account with
+// fresh metadata.
+func (r *DirectoryRepository) ImportDir(name, src string) (*account.Account, error) {
+	unlock, err := r.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if err := account.ValidateName(name); err != nil {
+		return nil, err
+	}
+	if err := r.policy.CheckStore(r.encrypt); err != nil {
+		return nil, err
+	}
+	if err := r.paths.EnsureDirs(); err != nil {
+		return nil, err
+	}
+
+	accountPath := r.paths.AccountPath(name)
+	r.snapshot(name, accountPath)
+	if err := copyStaged(src, accountPath, accountPath+saveStagingSuffix, r.updateOptions(accountPath)); err != nil {
+		return nil, fmt.Errorf("failed to import %s: %w", src, err)
+	}
+
+	acc := account.NewAccount(name)
+	if err := r.stowAuth(name, accountPath, acc); err != nil {
+		return nil, err
+	}
+	if err := writeManifest(accountPath); err != nil {
+		return nil, fmt.Errorf("failed to record manifest: %w", err)
+	}
+	if err := r.writeMetadata(accountPath, acc); err != nil {
+		return nil, err
+	}
+	if err := r.sealSaved(name, accountPath); err != nil {
+		return nil, err
+	}
+	r.dedupAccount(name, accountPath)
+	r.stored("import", name)
+
+	return acc, nil
+}
+
+func isCodexHome(dir string) bool {
+	for _, marker := range codexMarkers {
+		if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	codexAffix  = regexp.MustCompile(`(?i)^\.?codex[-_.]?|[-_.]?codex$`)
+	invalidName = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+)
+
+// inferName derives an account name from a backup directory name, e.g.
+// "codex-work" becomes "work".
+func inferName(dirName string) string {
+	name := codexAffix.ReplaceAllString(dirName, "")
+	name = invalidName.ReplaceAllString(name, "-")
+	name = strings.Trim(name, "-.")
+	if name == "" {
+		return "imported"
+	}
+	return strings.ToLower(name)
+}
+
+// uniqueName appends a numeric suffix to name until it is not taken.
+func uniqueName(name string, taken map[string]bool) string {
+	if !taken[name] {
+		return name
+	}
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s-%d", name, i)
+		if !taken[candidate] {
+			return candidate
+		}
+	}
+}
+
+// hashTree returns a content hash of the directory tree at dir, ignoring
+// cxa's own metadata and manifest, and the paths in skip.
+func hashTree(dir string, skip ...string) (string, error) {
+	h := sha256.New()
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if relPath == ".account.json" || relPath == manifestName || slices.Contains(skip, relPath) {
+			return nil
+		}
+
+		fmt.Fprintf(h, "%s\x00%s\x00", filepath.ToSlash(relPath), info.Mode().Type())
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			io.WriteString(h, link)
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(h, f)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
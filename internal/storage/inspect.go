@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// inspectPrefix names read-only views inside the data directory.
+const inspectPrefix = ".inspect-"
+
+// Inspect copies a saved account into a new read-only directory, to browse
+// its sessions and prompts without activating it. Credentials and cxa's
+// own metadata are left out, so the view holds no tokens and nothing run
+// in it can reach the account. On APFS the copy is a copy-on-write clone.
+// The caller removes the view with RemoveView.
+func (r *DirectoryRepository) Inspect(name string) (string, error) {
+	accountPath, release, err := r.openDir(name)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	if err := r.paths.EnsureDirs(); err != nil {
+		return "", err
+	}
+
+	dir, err := os.MkdirTemp(r.paths.DataDir, inspectPrefix+name+"-")
+	if err != nil {
+		return "", err
+	}
+	// The name is reserved; the view takes the place of the empty
+	// directory
+	if err := os.Remove(dir); err != nil {
+		return "", err
+	}
+	if err := cloneStaged(accountPath, dir, dir+activateStagingSuffix, r.copyOptions()); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to copy account: %w", err)
+	}
+	for _, private := range []string{authFile, "license.secret", ".account.json", manifestName} {
+		_ = os.Remove(filepath.Join(dir, private))
+	}
+	if err := setReadOnly(dir, true); err != nil {
+		_ = RemoveView(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// Views returns the views made by Inspect that are still in place.
+func (r *DirectoryRepository) Views() ([]string, error) {
+	views, err := filepath.Glob(filepath.Join(r.paths.DataDir, inspectPrefix+"*"))
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, view := range views {
+		if info, err := os.Stat(view); err == nil && info.IsDir() {
+			dirs = append(dirs, view)
+		}
+	}
+	return dirs, nil
+}
+
+// RemoveView removes a view made by Inspect.
+func RemoveView(dir string) error {
+	if err := setReadOnly(dir, false); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// setReadOnly takes the write permission away from everything under dir,
+// or gives it back to the owner. Directories are done last on the way in
+// and first on the way out, so the walk can always list them.
+func setReadOnly(dir string, on bool) error {
+	var dirs []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		mode := info.Mode().Perm()
+		if on {
+			mode &^= 0222
+		} else {
+			mode |= 0200
+		}
+		if d.IsDir() {
+			if !on {
+				return os.Chmod(path, mode|0100)
+			}
+			dirs = append(dirs, path)
+			return nil
+		}
+		return os.Chmod(path, mode)
+	})
+	if err != nil {
+		return err
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		info, err := os.Stat(dirs[i])
+		if err != nil {
+			return err
+		}
+		if err := os.Chmod(dirs[i], info.Mode().Perm()&^0222); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/delhombre/cxa/internal/account"
+)
+
+// authFile holds the OAuth tokens Codex signs in with.
+const authFile = "auth.json"
+
+// Keyring keeps account credentials outside the data directory, such as
+// the credential store of the operating system (see keychain.Keychain).
+// Secrets are filed under the account name.
+type Keyring interface {
+	Set(name string, secret []byte) error
+	Get(name string) ([]byte, error)
+	Delete(name string) error
+}
+
+// SetKeyring sets where account credentials are kept outside the data
+// directory, and whether saving moves each account's auth.json there.
+// With store off the keyring is still used for credentials moved there
+// earlier, until their accounts are saved again.
+func (r *DirectoryRepository) SetKeyring(k Keyring, store bool) {
+	r.keyring = k
+	r.storeAuth = store
+}
+
+// stowAuth moves the auth.json of a freshly written account into the
+// keyring when credentials are kept there, and records in acc where they
+// are. A keyring entry that the new files supersede is removed.
+func (r *DirectoryRepository) stowAuth(name, accountPath string, acc *account.Account) error {
+	path := filepath.Join(accountPath, authFile)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// A linked account is ~/.codex itself, where Codex needs the file
+	if err == nil && r.storeAuth && !r.isLinked(accountPath) {
+		if r.keyring == nil {
+			return errors.New("no keychain available for account credentials")
+		}
+		if err := r.keyring.Set(name, data); err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		acc.Keychain = true
+		r.log.Debug("moved credentials to the keychain", "account", name)
+		return nil
+	}
+
+	if acc.Keychain && r.keyring != nil {
+		if err := r.keyring.Delete(name); err != nil {
+			r.warnings.Record("keychain", fmt.Sprintf("failed to remove the old credentials of '%s': %v", name, err))
+		}
+	}
+	acc.Keychain = false
+	return nil
+}
+
+// inKeychain reports whether an account keeps its auth.json in the
+// keyring.
+func (r *DirectoryRepository) inKeychain(name string) bool {
+	acc, err := r.Get(name)
+	return err == nil && acc.Keychain
+}
+
+// keyringAuth returns the auth.json kept in the keyring for an account,
+// or nil if the account keeps it in its directory.
+func (r *DirectoryRepository) keyringAuth(name string) ([]byte, error) {
+	acc, err := r.Get(name)
+	if err != nil || !acc.Keychain {
+		return nil, nil
+	}
+	if r.keyring == nil {
+		return nil, fmt.Errorf("the credentials of '%s' are in the keychain, but no keychain is available", name)
+	}
+	data, err := r.keyring.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the credentials of '%s' from the keychain: %w", name, err)
+	}
+	return data, nil
+}
+
+// restoreAuth writes the auth.json an account keeps in the keyring into
+// dir. It does nothing for accounts that keep it in their directory.
+func (r *DirectoryRepository) restoreAuth(name, dir string) error {
+	data, err := r.keyringAuth(name)
+	if err != nil || data == nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, authFile), data, 0600)
+}
+
+// Stow puts an account expanded with Expand back in its stored form:
+// auth.json moves to the keyring and the account is sealed, as
+// configured. It is also how existing accounts are moved in or out of the
+// keyring after SetKeyring.
+func (r *DirectoryRepository) Stow(name string) error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	accountPath, err := r.AccountDir(name)
+	if err != nil {
+		return err
+	}
+	if r.isLinked(accountPath) {
+		return nil
+	}
+	acc, err := r.Get(name)
+	if err != nil {
+		return err
+	}
+	if err := acc.CheckWritable(); err != nil {
+		return err
+	}
+
+	if sealed(accountPath) {
+		if acc.Keychain == r.storeAuth {
+			return nil
+		}
+		// The credentials are in the sealed files, or have to go there
+		if err := r.unseal(name, accountPath); err != nil {
+			return err
+		}
+	}
+	if err := r.restoreAuth(name, accountPath); err != nil {
+		return err
+	}
+
+	if err := r.stowAuth(name, accountPath, acc); err != nil {
+		return err
+	}
+	if err := writeManifest(accountPath); err != nil {
+		return fmt.Errorf("failed to record manifest: %w", err)
+	}
+	if err := r.writeMetadata(accountPath, acc); err != nil {
+		return err
+	}
+	return r.sealSaved(name, accountPath)
+}
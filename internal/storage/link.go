@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Activation strategies for SetActivation.
+const (
+	// ActivateCopy copies the account into a real ~/.codex directory.
+	ActivateCopy = "copy"
+
+	// ActivateLink makes ~/.codex a symlink to the account directory, so
+	// switching is a link swap whatever the size of the sessions, and the
+	// stored account is always up to date.
+	ActivateLink = "symlink"
+)
+
+// linkStagingSuffix marks the new link to ~/.codex before it is renamed
+// into place.
+const linkStagingSuffix = ".link"
+
+// SetActivation chooses how Activate puts an account in place: by copying
+// (ActivateCopy, the default) or by linking (ActivateLink). Switching
+// strategies needs no migration: the next Activate replaces ~/.codex in
+// the new way, and the current account is saved first either way.
+func (r *DirectoryRepository) SetActivation(strategy string) {
+	r.activation = strategy
+}
+
+// homeLink returns the directory ~/.codex links to, if it is a symlink to
+// an existing directory.
+func (r *DirectoryRepository) homeLink() (string, bool) {
+	info, err := os.Lstat(r.paths.Home)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return "", false
+	}
+	target, err := filepath.EvalSymlinks(r.paths.Home)
+	if err != nil {
+		return "", false
+	}
+	return target, true
+}
+
+// liveDir returns the directory holding the live Codex home: ~/.codex
+// itself, or the account directory it links to.
+func (r *DirectoryRepository) liveDir() string {
+	if target, ok := r.homeLink(); ok {
+		return target
+	}
+	return r.paths.Home
+}
+
+// isLinked reports whether ~/.codex is a link to the account directory at
+// accountPath.
+func (r *DirectoryRepository) isLinked(accountPath string) bool {
+	target, ok := r.homeLink()
+	if !ok {
+		return false
+	}
+	resolved, err := filepath.EvalSymlinks(accountPath)
+	return err == nil && resolved == target
+}
+
+// linkHome points ~/.codex at accountPath, swapping the link in the same
+// way copies are so ~/.codex is restored if it fails.
+func (r *DirectoryRepository) linkHome(accountPath string) error {
+	if err := recoverSwap(r.paths.Home); err != nil {
+		return err
+	}
+	staging := r.paths.Home + linkStagingSuffix
+	if err := os.Remove(staging); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Symlink(accountPath, staging); err != nil {
+		return err
+	}
+	if err := swapIn(staging, r.paths.Home); err != nil {
+		os.Remove(staging)
+		return err
+	}
+	return nil
+}
+
+// Materialize replaces a linked ~/.codex with a real copy of the account
+// it links to, so ~/.codex keeps working without the data directory. It
+// does nothing when ~/.codex is not a link.
+func (r *DirectoryRepository) Materialize() error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	target, ok := r.homeLink()
+	if !ok {
+		return nil
+	}
+	if err := copyStaged(target, r.paths.Home, r.paths.Home+activateStagingSuffix, r.copyOptions()); err != nil {
+		return fmt.Errorf("failed to copy %s into ~/.codex: %w", target, err)
+	}
+	return nil
+}
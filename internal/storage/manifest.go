@@ -0,0 +1,257 @@
+package storage
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/delhombre/cxa/internal/sharing"
+	"github.com/delhombre/cxa/pkg/codex"
+)
+
+// manifestName is the file in an account directory listing the hash of
+// every file as of the last save, the baseline for verification.
+const manifestName = ".cxa-manifest.json"
+
+// ManifestEntry records one file or symlink of an account.
+type ManifestEntry struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	Link   string `json:"link,omitempty"`
+}
+
+// Manifest lists the contents of an account as of its last save.
+type Manifest struct {
+	CreatedAt time.Time       `json:"created_at"`
+	Files     []ManifestEntry `json:"files"`
+}
+
+// Issue kinds reported by Verify.
+const (
+	IssueMissing    = "missing"
+	IssueModified   = "modified"
+	IssueUnexpected = "unexpected"
+)
+
+// VerifyIssue is a file that does not match the manifest.
+type VerifyIssue struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"`
+}
+
+// VerifyResult is the outcome of checking a directory against an
+// account's manifest.
+type VerifyResult struct {
+	Account string `json:"account"`
+	Dir     string `json:"dir"`
+
+	// HasManifest is false for accounts not saved since manifests were
+	// introduced; nothing can be verified for them.
+	HasManifest bool          `json:"has_manifest"`
+	Issues      []VerifyIssue `json:"issues"`
+}
+
+// OK reports whether the directory matches the manifest.
+func (v *VerifyResult) OK() bool {
+	return v.HasManifest && len(v.Issues) == 0
+}
+
+// Manifest returns the manifest written when the account was last saved,
+// or nil if it has none.
+func (r *DirectoryRepository) Manifest(name string) (*Manifest, error) {
+	dir, err := r.AccountDir(name)
+	if err != nil {
+		return nil, err
+	}
+	return readManifest(dir)
+}
+
+// Verify checks a stored account against its manifest, reporting files
+// that are missing, modified, or were added behind cxa's back.
+func (r *DirectoryRepository) Verify(name string) (*VerifyResult, error) {
+	dir, release, err := r.openDir(name)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	m, err := readManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &VerifyResult{Account: name, Dir: r.paths.AccountPath(name), Issues: []VerifyIssue{}}
+	if m == nil {
+		return result, nil
+	}
+	result.HasManifest = true
+
+	current, err := buildManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	result.Issues = compareManifests(m, current, true)
+	return result, nil
+}
+
+// VerifyActive checks ~/.codex against the manifest of the current
+// account. Codex adds files as it is used, so only files that went missing
+// or changed since the account was saved are reported, and shared items,
+// which live outside the account, are skipped.
+func (r *DirectoryRepository) VerifyActive() (*VerifyResult, error) {
+	name, err := r.Current()
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, fmt.Errorf("no current account")
+	}
+	dir, err := r.AccountDir(name)
+	if err != nil {
+		return nil, err
+	}
+	m, err := readManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &VerifyResult{Account: name, Dir: r.paths.Home, Issues: []VerifyIssue{}}
+	if m == nil {
+		return result, nil
+	}
+	result.HasManifest = true
+
+	shared := append(slices.Clone(codex.ShareableItems), codex.OptionalShareableItems...)
+	if shareManager := sharing.NewManagerWithPaths(r.paths); shareManager.LoadConfig() == nil {
+		shared = shareManager.Items()
+	}
+	m.Files = slices.DeleteFunc(m.Files, func(e ManifestEntry) bool {
+		return slices.ContainsFunc(shared, func(item string) bool {
+			return e.Path == item || strings.HasPrefix(e.Path, item+"/")
+		})
+	})
+
+	live, err := buildManifest(r.liveDir())
+	if err != nil {
+		return nil, err
+	}
+	result.Issues = compareManifests(m, live, false)
+	return result, nil
+}
+
+// compareManifests reports how got differs from want. Files only in got
+// are reported when strict.
+func compareManifests(want, got *Manifest, strict bool) []VerifyIssue {
+	gotByPath := make(map[string]ManifestEntry, len(got.Files))
+	for _, e := range got.Files {
+		gotByPath[e.Path] = e
+	}
+
+	issues := []VerifyIssue{}
+	seen := make(map[string]bool, len(want.Files))
+	for _, e := range want.Files {
+		seen[e.Path] = true
+		g, ok := gotByPath[e.Path]
+		switch {
+		case !ok:
+			issues = append(issues, VerifyIssue{Path: e.Path, Kind: IssueMissing})
+		case g != e:
+			issues = append(issues, VerifyIssue{Path: e.Path, Kind: IssueModified})
+		}
+	}
+	if strict {
+		for _, e := range got.Files {
+			if !seen[e.Path] {
+				issues = append(issues, VerifyIssue{Path: e.Path, Kind: IssueUnexpected})
+			}
+		}
+	}
+
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Path < issues[j].Path })
+	return issues
+}
+
+// buildManifest hashes every file under dir, skipping cxa's own files.
+func buildManifest(dir string) (*Manifest, error) {
+	m := &Manifest{CreatedAt: time.Now(), Files: []ManifestEntry{}}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		switch relPath {
+		case ".", ".account.json", manifestName, journalName:
+			return nil
+		}
+
+		entry := ManifestEntry{Path: filepath.ToSlash(relPath)}
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			if entry.Link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			sum, err := fileHash(path)
+			if err != nil {
+				return err
+			}
+			entry.Size = info.Size()
+			entry.SHA256 = hex.EncodeToString(sum[:])
+		default:
+			return nil
+		}
+		m.Files = append(m.Files, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func readManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest in %s: %w", dir, err)
+	}
+	return &m, nil
+}
+
+// totals returns the total size and the number of the files listed.
+func (m *Manifest) totals() (size int64, files int) {
+	for _, entry := range m.Files {
+		size += entry.Size
+		files++
+	}
+	return size, files
+}
+
+// writeManifest records the current contents of dir in its manifest.
+func writeManifest(dir string) error {
+	m, err := buildManifest(dir)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, manifestName), data, 0644)
+}
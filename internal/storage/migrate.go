@@ -0,0 +1,233 @@
+package storage
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/delhombre/cxa/internal/account"
+	"github.com/delhombre/cxa/internal/auth"
+)
+
+// LegacyArchive is an account kept by the zip-based storage that directory
+// storage replaced: a <name>.zip holding a copy of ~/.codex.
+type LegacyArchive struct {
+	Path    string
+	Name    string
+	ModTime time.Time
+
+	// Exists is set when an account of the same name is already saved,
+	// typically because the archive was migrated before.
+	Exists bool
+}
+
+// FindLegacyArchives lists the zip archives of accounts in dir, which
+// defaults to the data directory.
+func (r *DirectoryRepository) FindLegacyArchives(dir string) ([]*LegacyArchive, error) {
+	if dir == "" {
+		dir = r.paths.DataDir
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var archives []*LegacyArchive
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".zip")
+		if !ok || entry.IsDir() || account.ValidateName(name) != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		_, statErr := os.Stat(r.paths.AccountPath(name))
+		archives = append(archives, &LegacyArchive{
+			Path:    filepath.Join(dir, entry.Name()),
+			Name:    name,
+			ModTime: info.ModTime(),
+			Exists:  statErr == nil,
+		})
+	}
+	sort.Slice(archives, func(i, j int) bool { return archives[i].Name < archives[j].Name })
+	return archives, nil
+}
+
+// MigrateArchive unpacks a legacy archive into a new account. Metadata is
+// reconstructed from the archive: it was last used when the zip was
+// written, created no later than its oldest file, and the email comes from
+// its auth.json. The archive itself is left in place.
+func (r *DirectoryRepository) MigrateArchive(archive *LegacyArchive) (*account.Account, error) {
+	unlock, err := r.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	name := archive.Name
+	if err := account.ValidateName(name); err != nil {
+		return nil, err
+	}
+	if err := r.policy.CheckStore(r.encrypt); err != nil {
+		return nil, err
+	}
+	if err := r.paths.EnsureDirs(); err != nil {
+		return nil, err
+	}
+
+	accountPath := r.paths.AccountPath(name)
+	if _, err := os.Stat(accountPath); err == nil {
+		return nil, fmt.Errorf("account '%s' already exists", name)
+	}
+
+	staging := accountPath + saveStagingSuffix
+	if err := os.RemoveAll(staging); err != nil {
+		return nil, err
+	}
+	oldest, err := extractZip(archive.Path, staging)
+	if err != nil {
+		os.RemoveAll(staging)
+		return nil, fmt.Errorf("failed to unpack %s: %w", archive.Path, err)
+	}
+
+	acc := account.NewAccount(name)
+	acc.UpdatedAt = archive.ModTime
+	acc.LastUsedAt = archive.ModTime
+	acc.CreatedAt = archive.ModTime
+	if !oldest.IsZero() && oldest.Before(acc.CreatedAt) {
+		acc.CreatedAt = oldest
+	}
+	if f, err := auth.Load(filepath.Join(staging, "auth.json")); err == nil {
+		if id, err := f.Identity(); err == nil {
+			acc.Email = id.Email
+		}
+	}
+
+	if err := writeManifest(staging); err != nil {
+		os.RemoveAll(staging)
+		return nil, fmt.Errorf("failed to record manifest: %w", err)
+	}
+	if err := r.writeMetadata(staging, acc); err != nil {
+		os.RemoveAll(staging)
+		return nil, err
+	}
+	if err := os.Rename(staging, accountPath); err != nil {
+		os.RemoveAll(staging)
+		return nil, err
+	}
+	if err := r.sealSaved(name, accountPath); err != nil {
+		return nil, err
+	}
+	return acc, nil
+}
+
+// extractZip unpacks the zip at path into dir and returns the oldest file
+// time it contains. Archives of the .codex directory itself are unpacked
+// from inside it. Symlinks are created last so no entry is written through
+// one.
+func extractZip(path, dir string) (time.Time, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer zr.Close()
+
+	prefix := ".codex/"
+	for _, f := range zr.File {
+		if !strings.HasPrefix(f.Name, prefix) {
+			prefix = ""
+			break
+		}
+	}
+
+	var oldest time.Time
+	var links []*zip.File
+	for _, f := range zr.File {
+		rel := filepath.FromSlash(strings.TrimPrefix(f.Name, prefix))
+		if rel == "" || rel == "." {
+			continue
+		}
+		if !filepath.IsLocal(rel) {
+			return time.Time{}, fmt.Errorf("entry %q escapes the archive", f.Name)
+		}
+		target := filepath.Join(dir, rel)
+
+		switch {
+		case f.Mode()&os.ModeSymlink != 0:
+			links = append(links, f)
+			continue
+		case f.FileInfo().IsDir():
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return time.Time{}, err
+			}
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return time.Time{}, err
+		}
+		if err := extractZipFile(f, target); err != nil {
+			return time.Time{}, err
+		}
+		if !f.Modified.IsZero() && (oldest.IsZero() || f.Modified.Before(oldest)) {
+			oldest = f.Modified
+		}
+	}
+
+	for _, f := range links {
+		rc, err := f.Open()
+		if err != nil {
+			return time.Time{}, err
+		}
+		link, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return time.Time{}, err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(f.Name, prefix)))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return time.Time{}, err
+		}
+		if err := os.Symlink(string(link), target); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return oldest, nil
+}
+
+func extractZipFile(f *zip.File, target string) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	perm := f.Mode().Perm()
+	if perm == 0 {
+		perm = 0644
+	}
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, rc); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if !f.Modified.IsZero() {
+		return os.Chtimes(target, f.Modified, f.Modified)
+	}
+	return nil
+}
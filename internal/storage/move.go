@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/delhombre/cxa/internal/fscopy"
+	"github.com/delhombre/cxa/internal/history"
+	"github.com/delhombre/cxa/internal/warnings"
+	"github.com/delhombre/cxa/pkg/codex"
+)
+
+// ErrDataDirPinned is returned when moving a data directory that the
+// administrator policy pins in place.
+var ErrDataDirPinned = errors.New("the data directory is pinned by the administrator policy and cannot be moved")
+
+// MoveData relocates the data directory to dst, which must not exist or
+// be empty. The data is copied and verified before persist records the new
+// location; only then are symlinks into the old location, such as the
+// sharing links in ~/.codex, retargeted and the old directory removed. If
+// anything fails before persist succeeds, the copy is discarded and the
+// old location stays in use.
+func (r *DirectoryRepository) MoveData(dst string, persist func(dataDir string) error) error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if r.policy.DataDir != "" {
+		return ErrDataDirPinned
+	}
+	if os.Getenv(codex.EnvDataDir) != "" {
+		return fmt.Errorf("the data directory is set by %s; move it and change the variable instead", codex.EnvDataDir)
+	}
+
+	dst, err = filepath.Abs(dst)
+	if err != nil {
+		return err
+	}
+	src := r.paths.DataDir
+	if rel, err := filepath.Rel(src, dst); err == nil && !strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("cannot move %s into itself", src)
+	}
+	if entries, err := os.ReadDir(dst); err == nil && len(entries) > 0 {
+		return fmt.Errorf("%s is not empty", dst)
+	} else if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			return err
+		}
+	} else {
+		// Cache entries are journaled against their source paths, so
+		// rebuild them rather than carry them over
+		if err := r.ClearCache(); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		if err := copyStaged(src, dst, dst+saveStagingSuffix, r.copyOptions()); err != nil {
+			return fmt.Errorf("failed to copy data: %w", err)
+		}
+
+		// The copy already pointed links within the data directory at dst
+		d, err := DiffDirs(src, dst)
+		if err == nil && !d.Empty() {
+			err = fmt.Errorf("%d entries differ after copying, first %s", len(d.Entries), d.Entries[0].Path)
+		}
+		if err != nil {
+			os.RemoveAll(dst)
+			return fmt.Errorf("verification failed: %w", err)
+		}
+	}
+
+	if err := persist(dst); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+
+	r.paths.SetDataDir(dst)
+
+	if err := retargetLinks(r.paths.Home, src, dst); err != nil {
+		return fmt.Errorf("moved data, but failed to update links in ~/.codex: %w", err)
+	}
+	return os.RemoveAll(src)
+}
+
+// MoveState relocates the state directory, with the cxa config and
+// history, to dst, which must not exist or be empty. The lock and the
+// agent socket are not carried over: they belong to the processes using
+// the old location.
+func (r *DirectoryRepository) MoveState(dst string) error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if os.Getenv(codex.EnvStateDir) != "" {
+		return fmt.Errorf("the state directory is set by %s; move it and change the variable instead", codex.EnvStateDir)
+	}
+	dst, err = filepath.Abs(dst)
+	if err != nil {
+		return err
+	}
+	src := r.paths.StateDir
+	if rel, err := filepath.Rel(src, dst); err == nil && !strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("cannot move %s into itself", src)
+	}
+	if entries, err := os.ReadDir(dst); err == nil && len(entries) > 0 {
+		return fmt.Errorf("%s is not empty", dst)
+	} else if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	opts := r.copyOptions()
+	opts.exclude = fscopy.Excludes{filepath.Base(r.paths.LockFile()), filepath.Base(r.paths.AgentSocket())}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := copyStaged(src, dst, dst+saveStagingSuffix, opts); err != nil {
+		return fmt.Errorf("failed to copy state: %w", err)
+	}
+
+	r.paths.StateDir = dst
+	r.warnings = warnings.NewStore(r.paths.WarningsFile())
+	r.history = history.NewLog(r.paths.HistoryFile())
+	return os.RemoveAll(src)
+}
+
+// retargetLinks rewrites symlinks under dir that point into from so they
+// point at the same place under to.
+func retargetLinks(dir, from, to string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			return nil
+		}
+
+		link, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, link)
+		if !filepath.IsAbs(link) || err != nil || strings.HasPrefix(rel, "..") {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		return os.Symlink(filepath.Join(to, rel), path)
+	})
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package storage
+
+import "os"
+
+// linkCount cannot tell how many names a file has on this platform, so
+// deduplication is not available.
+func linkCount(info os.FileInfo) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,291 @@
+package storage
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// packName is the archive holding the packed part of a compressed account.
+// Only directories and links are packed: top-level files such as auth.json
+// and config.toml stay readable without unpacking, and they are small.
+const packName = ".cxa-packed.tar.gz"
+
+// packed reports whether the account at accountPath is compressed.
+func packed(accountPath string) bool {
+	_, err := os.Stat(filepath.Join(accountPath, packName))
+	return err == nil
+}
+
+// Compress packs the directories of a saved account, its sessions and logs
+// among them, into a compressed archive inside the account, trading switch
+// speed for disk space. The account is unpacked again by anything that
+// reads its files, such as Activate, Diff, or Expand. The current account
+// cannot be compressed.
+func (r *DirectoryRepository) Compress(name string) error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	accountPath, err := r.AccountDir(name)
+	if err != nil {
+		return err
+	}
+	if current, _ := r.Current(); current == name || r.isLinked(accountPath) {
+		return fmt.Errorf("'%s' is the current account; switch to another account before compressing it", name)
+	}
+	if packed(accountPath) || sealed(accountPath) {
+		// Sealed accounts are compressed as they are encrypted
+		return nil
+	}
+
+	entries, err := os.ReadDir(accountPath)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	archive := filepath.Join(accountPath, packName)
+	if err := writePack(archive+".tmp", accountPath, names); err != nil {
+		os.Remove(archive + ".tmp")
+		return fmt.Errorf("failed to compress account: %w", err)
+	}
+	if err := os.Rename(archive+".tmp", archive); err != nil {
+		return err
+	}
+	for _, n := range names {
+		if err := os.RemoveAll(filepath.Join(accountPath, n)); err != nil {
+			return err
+		}
+	}
+
+	// A cache entry would swap the full account back in behind our back
+	if err := os.RemoveAll(r.paths.CachePath(name)); err != nil {
+		return err
+	}
+	r.log.Debug("compressed account", "account", name)
+	return nil
+}
+
+// Expand unpacks an account compressed with Compress, decrypts one sealed
+// with Seal, and writes back an auth.json kept in the keychain, so its
+// files can be used in place. Stow puts it back as it was stored.
+func (r *DirectoryRepository) Expand(name string) error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	accountPath, err := r.AccountDir(name)
+	if err != nil {
+		return err
+	}
+	if err := r.expand(name, accountPath); err != nil {
+		return err
+	}
+	if err := r.unseal(name, accountPath); err != nil {
+		return err
+	}
+	return r.restoreAuth(name, accountPath)
+}
+
+// expand unpacks the account at accountPath if it is compressed. An
+// interrupted unpack leaves the archive in place, so it is simply redone.
+func (r *DirectoryRepository) expand(name, accountPath string) error {
+	archive := filepath.Join(accountPath, packName)
+	f, err := os.Open(archive)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r.log.Debug("expanding compressed account", "account", name)
+	if err := readPack(f, accountPath); err != nil {
+		return fmt.Errorf("failed to unpack account '%s': %w", name, err)
+	}
+	return os.Remove(archive)
+}
+
+// writePack archives the named entries of dir into a gzipped tarball at
+// dst.
+func writePack(dst, dir string, names []string) error {
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := packTo(f, dir, names); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// packTo writes the named entries of dir to w as a gzipped tarball.
+func packTo(w io.Writer, dir string, names []string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, name := range names {
+		err := filepath.Walk(filepath.Join(dir, name), func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(dir, p)
+			if err != nil {
+				return err
+			}
+			return addPackEntry(tw, p, filepath.ToSlash(rel), info)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func addPackEntry(tw *tar.Writer, p, name string, info os.FileInfo) error {
+	link := ""
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		var err error
+		if link, err = os.Readlink(p); err != nil {
+			return err
+		}
+	case !info.IsDir() && !info.Mode().IsRegular():
+		return nil
+	}
+
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if info.IsDir() {
+		hdr.Name += "/"
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// readPack unpacks a tarball written by writePack into dir, keeping modes,
+// modification times, and symlinks, and replacing anything already there.
+func readPack(r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	var dirs []*tar.Header
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean(hdr.Name)
+		if name == "." || name == ".." || strings.HasPrefix(name, "../") || path.IsAbs(name) {
+			return fmt.Errorf("unsafe entry %s", hdr.Name)
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(name))
+		mode := os.FileMode(hdr.Mode).Perm()
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(dst, mode|0700); err != nil {
+				return err
+			}
+			dirs = append(dirs, hdr)
+		case tar.TypeSymlink:
+			if err := os.RemoveAll(dst); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, dst); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writePackFile(tr, dst, mode); err != nil {
+				return err
+			}
+			if err := os.Chtimes(dst, hdr.ModTime, hdr.ModTime); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported entry type for %s", hdr.Name)
+		}
+	}
+
+	// Directory times last, once nothing more is written into them
+	for _, hdr := range dirs {
+		dst := filepath.Join(dir, filepath.FromSlash(path.Clean(hdr.Name)))
+		if err := os.Chmod(dst, os.FileMode(hdr.Mode).Perm()); err != nil {
+			return err
+		}
+		if err := os.Chtimes(dst, hdr.ModTime, hdr.ModTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writePackFile(r io.Reader, dst string, mode os.FileMode) error {
+	if info, err := os.Lstat(dst); err == nil && !info.Mode().IsRegular() {
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/delhombre/cxa/internal/fscopy"
+)
+
+// Selection picks the paths of ~/.codex that a save takes, such as
+// auth.json, config.toml or sessions, written as fscopy.Excludes patterns.
+// What it leaves out is kept in the account as it was saved before.
+type Selection struct {
+	// Include, if not empty, names the only paths taken.
+	Include fscopy.Excludes
+
+	// Exclude names paths that are not taken, even if included.
+	Exclude fscopy.Excludes
+}
+
+// takes reports whether a save with s takes the path rel, a directory if
+// dir is true. Directories are walked into unless excluded, as included
+// paths may be inside them.
+func (s Selection) takes(rel string, dir bool) bool {
+	if s.Exclude.Match(rel, dir) {
+		return false
+	}
+	return dir || len(s.Include) == 0 || s.Include.Match(rel, false)
+}
+
+// carryOver fills staging with the files of dst that sel does not take,
+// so they outlive dst being replaced. Files are hardlinked where possible:
+// dst goes away once staging takes its place, so nothing ends up shared.
+func carryOver(dst, staging string, sel Selection) error {
+	if _, err := os.Lstat(dst); os.IsNotExist(err) {
+		return nil
+	}
+
+	var dirs fscopy.DirTimes
+	err := filepath.Walk(dst, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dst, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		target := filepath.Join(staging, rel)
+
+		if info.IsDir() {
+			if _, err := os.Lstat(target); err == nil {
+				return nil
+			}
+			if err := os.Mkdir(target, info.Mode().Perm()); err != nil {
+				return err
+			}
+			dirs.Add(target, info)
+			return nil
+		}
+		if sel.takes(filepath.ToSlash(rel), false) {
+			return nil
+		}
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fscopy.Symlink(path, target, dst, dst)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		if err := os.Link(path, target); err == nil {
+			return nil
+		}
+		return fscopy.File(path, target, 0, nil)
+	})
+	if err != nil {
+		return err
+	}
+	return dirs.Apply()
+}
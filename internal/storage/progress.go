@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// progressInterval is how often a copy reports its progress at most.
+// Copies finishing sooner never report, so quick saves draw nothing.
+const progressInterval = 100 * time.Millisecond
+
+// Progress is how far a save or a switch has copied.
+type Progress struct {
+	// Op is "save" or "switch", and Account the account being saved or
+	// switched to. Switching saves the current account first, reported
+	// as a save of it.
+	Op      string `json:"op"`
+	Account string `json:"account"`
+
+	Files      int   `json:"files"`
+	TotalFiles int   `json:"total_files"`
+	Bytes      int64 `json:"bytes"`
+	TotalBytes int64 `json:"total_bytes"`
+}
+
+// Percent returns how much of the copy is done, from 0 to 100, by bytes
+// or, when the files are all empty, by count.
+func (p Progress) Percent() int {
+	switch {
+	case p.TotalBytes > 0:
+		return int(p.Bytes * 100 / p.TotalBytes)
+	case p.TotalFiles > 0:
+		return p.Files * 100 / p.TotalFiles
+	}
+	return 100
+}
+
+// SetProgress makes saves and switches call fn as they copy, at most every
+// progressInterval. fn may be called from any goroutine, but never from
+// two at once. nil stops reporting.
+func (r *DirectoryRepository) SetProgress(fn func(Progress)) {
+	r.progress = fn
+}
+
+// progressTracker counts the files of one copy and reports them. A nil
+// tracker does nothing, so copies nobody watches pay nothing for it.
+type progressTracker struct {
+	fn func(Progress)
+
+	mu   sync.Mutex
+	p    Progress
+	last time.Time
+}
+
+// newProgress returns a tracker reporting op of the named account, or nil
+// when nothing is watching.
+func (r *DirectoryRepository) newProgress(op, name string) *progressTracker {
+	if r.progress == nil {
+		return nil
+	}
+	return &progressTracker{fn: r.progress, p: Progress{Op: op, Account: name}}
+}
+
+// start sizes up the regular files under src that the copy takes, and
+// starts counting from zero. A resumed copy starts over, with the files it
+// skips counted as they are passed.
+func (t *progressTracker) start(src string, skips func(rel string, dir bool) bool) {
+	if t == nil {
+		return
+	}
+	var files int
+	var size int64
+	_ = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return nil
+		}
+		if skips(rel, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			files++
+			size += info.Size()
+		}
+		return nil
+	})
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.p.Files, t.p.Bytes = 0, 0
+	t.p.TotalFiles, t.p.TotalBytes = files, size
+	t.last = time.Now()
+}
+
+// done counts a file of size bytes as copied.
+func (t *progressTracker) done(size int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.p.Files++
+	t.p.Bytes += size
+	if time.Since(t.last) < progressInterval {
+		return
+	}
+	t.last = time.Now()
+	t.fn(t.p)
+}
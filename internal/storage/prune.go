@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/delhombre/cxa/internal/account"
+)
+
+// PruneCandidate is an account directory that looks safe to remove.
+type PruneCandidate struct {
+	Name   string
+	Reason string
+}
+
+// FindPrunable scans the accounts directory for empty directories, accounts
+// with missing or corrupt metadata, and accounts not used within staleAfter.
+// A zero staleAfter disables the staleness check. The current account and
+// locked accounts are never reported as stale.
+func (r *DirectoryRepository) FindPrunable(staleAfter time.Duration) ([]PruneCandidate, error) {
+	entries, err := os.ReadDir(r.paths.AccountsDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	current, _ := r.Current()
+	cutoff := time.Now().Add(-staleAfter)
+
+	var candidates []PruneCandidate
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || strings.HasSuffix(name, saveStagingSuffix) {
+			continue
+		}
+		accountPath := r.paths.AccountPath(name)
+
+		contents, err := os.ReadDir(accountPath)
+		if err != nil {
+			candidates = append(candidates, PruneCandidate{Name: name, Reason: "unreadable directory"})
+			continue
+		}
+		if len(contents) == 0 {
+			candidates = append(candidates, PruneCandidate{Name: name, Reason: "empty directory"})
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(accountPath, ".account.json"))
+		if err != nil {
+			candidates = append(candidates, PruneCandidate{Name: name, Reason: "missing .account.json"})
+			continue
+		}
+		var acc account.Account
+		if err := json.Unmarshal(data, &acc); err != nil {
+			candidates = append(candidates, PruneCandidate{Name: name, Reason: "corrupt .account.json"})
+			continue
+		}
+
+		if staleAfter > 0 && name != current && !acc.Protected && acc.LastUsed().Before(cutoff) {
+			days := int(time.Since(acc.LastUsed()).Hours() / 24)
+			candidates = append(candidates, PruneCandidate{
+				Name:   name,
+				Reason: fmt.Sprintf("not used in %d days", days),
+			})
+		}
+	}
+
+	return candidates, nil
+}
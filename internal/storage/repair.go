@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/delhombre/cxa/internal/account"
+	"github.com/delhombre/cxa/internal/auth"
+)
+
+// Kinds of RepairResult.
+const (
+	// RepairMetadata is an account whose missing metadata was written.
+	RepairMetadata = "metadata"
+
+	// RepairName is an account whose metadata named another account,
+	// such as after its directory was renamed by hand.
+	RepairName = "name"
+
+	// RepairUnreadable is an entry Repair cannot fix, left as it is.
+	RepairUnreadable = "unreadable"
+
+	// RepairState is state.json naming an account that does not exist,
+	// or not parsing at all.
+	RepairState = "state"
+)
+
+// RepairResult is one thing Repair fixed, or would fix, or could not.
+type RepairResult struct {
+	Name   string `json:"name"`
+	Kind   string `json:"kind"`
+	Detail string `json:"detail"`
+
+	// Fixed is false for unreadable entries, and for every result of a
+	// dry run.
+	Fixed bool `json:"fixed"`
+}
+
+// Repair scans the accounts directory for accounts Get would otherwise
+// paper over: it writes metadata for directories that have none, corrects
+// metadata naming another account, reports entries it cannot read, and
+// clears accounts state.json tracks that no longer exist. With dryRun,
+// nothing is changed.
+func (r *DirectoryRepository) Repair(dryRun bool) ([]RepairResult, error) {
+	unlock, err := r.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	entries, err := os.ReadDir(r.paths.AccountsDir())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	results := []RepairResult{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") || strings.HasSuffix(name, saveStagingSuffix) || r.replacedCopy(name) {
+			continue
+		}
+		if result := r.repairAccount(name, entry, dryRun); result != nil {
+			results = append(results, *result)
+		}
+	}
+
+	stateResults, err := r.repairState(dryRun)
+	if err != nil {
+		return results, err
+	}
+	return append(results, stateResults...), nil
+}
+
+func (r *DirectoryRepository) repairAccount(name string, entry os.DirEntry, dryRun bool) *RepairResult {
+	unreadable := func(detail string) *RepairResult {
+		return &RepairResult{Name: name, Kind: RepairUnreadable, Detail: detail}
+	}
+	if !entry.IsDir() {
+		return unreadable("not a directory")
+	}
+	accountPath := r.paths.AccountPath(name)
+	if _, err := os.ReadDir(accountPath); err != nil {
+		return unreadable(fmt.Sprintf("cannot read directory: %v", err))
+	}
+
+	_, err := os.Stat(filepath.Join(accountPath, ".account.json"))
+	if os.IsNotExist(err) {
+		result := &RepairResult{Name: name, Kind: RepairMetadata, Detail: "wrote missing .account.json"}
+		if dryRun {
+			result.Detail = "missing .account.json"
+			return result
+		}
+		if err := r.regenerateMetadata(name, accountPath); err != nil {
+			return unreadable(fmt.Sprintf("failed to write .account.json: %v", err))
+		}
+		result.Fixed = true
+		return result
+	}
+	if err != nil {
+		return unreadable(fmt.Sprintf("cannot read .account.json: %v", err))
+	}
+
+	acc, err := r.Get(name)
+	if err != nil {
+		var schemaErr *account.SchemaError
+		if errors.As(err, &schemaErr) {
+			// Readable by the newer cxa that wrote it
+			return nil
+		}
+		return unreadable(fmt.Sprintf("corrupt .account.json: %v; remove the account with cxa prune or fix the file by hand", err))
+	}
+	if acc.Name == name {
+		return nil
+	}
+
+	result := &RepairResult{Name: name, Kind: RepairName, Detail: fmt.Sprintf("metadata named '%s'", acc.Name)}
+	if dryRun {
+		return result
+	}
+	acc.Name = name
+	if err := r.writeMetadata(accountPath, acc); err != nil {
+		return unreadable(fmt.Sprintf("failed to correct .account.json: %v", err))
+	}
+	result.Fixed = true
+	return result
+}
+
+// regenerateMetadata writes metadata for an account directory that has
+// none, dated from the directory and with the email from its auth.json.
+func (r *DirectoryRepository) regenerateMetadata(name, accountPath string) error {
+	info, err := os.Stat(accountPath)
+	if err != nil {
+		return err
+	}
+	acc := account.NewAccount(name)
+	acc.CreatedAt = info.ModTime()
+	acc.UpdatedAt = info.ModTime()
+	if f, err := auth.Load(filepath.Join(accountPath, authFile)); err == nil {
+		if id, err := f.Identity(); err == nil {
+			acc.Email = id.Email
+		}
+	}
+	if m, err := readManifest(accountPath); err == nil && m == nil && !sealed(accountPath) && !packed(accountPath) {
+		if err := writeManifest(accountPath); err != nil {
+			return err
+		}
+	}
+	return r.writeMetadata(accountPath, acc)
+}
+
+// repairState clears the current and previous accounts of state.json
+// when they no longer exist, and rebuilds a state.json that does not
+// parse.
+func (r *DirectoryRepository) repairState(dryRun bool) ([]RepairResult, error) {
+	var results []RepairResult
+	state := &State{}
+	rewrite := false
+
+	data, err := os.ReadFile(r.paths.StateFile())
+	if err == nil {
+		if err := json.Unmarshal(data, state); err != nil {
+			state = r.rebuildState()
+			rewrite = true
+			results = append(results, RepairResult{Kind: RepairState, Detail: "state.json does not parse; rebuilt it from the accounts"})
+		}
+	}
+	for _, tracked := range []*string{&state.Current, &state.Previous} {
+		if *tracked == "" {
+			continue
+		}
+		if _, err := os.Stat(r.paths.AccountPath(*tracked)); os.IsNotExist(err) {
+			results = append(results, RepairResult{Name: *tracked, Kind: RepairState, Detail: "tracked in state.json but not saved; forgot it"})
+			*tracked = ""
+			rewrite = true
+		}
+	}
+	if !rewrite || dryRun {
+		return results, nil
+	}
+	if err := r.SetState(state); err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Fixed = true
+	}
+	return results, nil
+}
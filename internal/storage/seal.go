@@ -0,0 +1,339 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/delhombre/cxa/internal/crypt"
+	"github.com/delhombre/cxa/internal/policy"
+)
+
+// sealName is the encrypted archive holding the files of an encrypted
+// account. The account metadata is left out so accounts can be listed
+// without the passphrase; everything Codex wrote, auth.json and sessions
+// included, is sealed.
+const sealName = ".cxa-sealed"
+
+// unsealPrefix names the scratch directories encrypted accounts are
+// decrypted into for reading.
+const unsealPrefix = ".unsealed-"
+
+// KeySource returns the passphrase encrypted accounts are sealed with. It
+// is only called when an account has to be sealed or unsealed, so a
+// prompt behind it is not shown to commands that do not need it.
+type KeySource func() ([]byte, error)
+
+// sealed reports whether the account at accountPath is encrypted.
+func sealed(accountPath string) bool {
+	_, err := os.Stat(filepath.Join(accountPath, sealName))
+	return err == nil
+}
+
+// SetEncryption sets whether saving an account seals it with the storage
+// passphrase, so its tokens and sessions are unreadable at rest.
+func (r *DirectoryRepository) SetEncryption(on bool) {
+	r.encrypt = on
+}
+
+// SetPolicy sets the policy the repository enforces in place of the
+// system policy.
+func (r *DirectoryRepository) SetPolicy(p *policy.Policy) {
+	r.policy = p
+}
+
+// SetKeySource sets where the storage passphrase comes from.
+func (r *DirectoryRepository) SetKeySource(key KeySource) {
+	r.key = key
+	r.unlocked = nil
+}
+
+// HasKey reports whether a storage passphrase has been chosen, which
+// happens the first time an account is encrypted.
+func (r *DirectoryRepository) HasKey() bool {
+	_, err := os.Stat(r.paths.KeyCheckFile())
+	return err == nil
+}
+
+// CheckKey reports whether key is the storage passphrase, failing with
+// crypt.ErrDecrypt if it is not. When no passphrase has been chosen yet,
+// key becomes the storage passphrase, so every account is sealed with the
+// same one.
+func (r *DirectoryRepository) CheckKey(key []byte) error {
+	path := r.paths.KeyCheckFile()
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return writeKeyCheck(path, key)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cr, err := crypt.NewReader(f, key)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(io.Discard, cr)
+	return err
+}
+
+// writeKeyCheck seals a known value with key at path, for CheckKey.
+func writeKeyCheck(path string, key []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path+".tmp", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path + ".tmp")
+	defer f.Close()
+
+	cw, err := crypt.NewWriter(f, key)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(cw, "cxa"); err != nil {
+		return err
+	}
+	if err := cw.Close(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// passphrase returns the storage passphrase, asking the key source once
+// per repository and checking what it returns.
+func (r *DirectoryRepository) passphrase() ([]byte, error) {
+	if r.unlocked != nil {
+		return r.unlocked, nil
+	}
+	if r.key == nil {
+		return nil, errors.New("no passphrase available for encrypted accounts")
+	}
+	key, err := r.key()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.CheckKey(key); err != nil {
+		return nil, err
+	}
+	r.unlocked = key
+	return key, nil
+}
+
+// Seal encrypts a saved account with the storage passphrase. Its files,
+// compressed on the way, can then only be read by cxa with the
+// passphrase; switching to it decrypts it straight into ~/.codex. An
+// account linked to ~/.codex cannot be sealed, as Codex uses it in place.
+func (r *DirectoryRepository) Seal(name string) error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	accountPath, err := r.AccountDir(name)
+	if err != nil {
+		return err
+	}
+	if r.isLinked(accountPath) {
+		return fmt.Errorf("'%s' is linked to ~/.codex and cannot be encrypted; switch to another account first", name)
+	}
+	return r.seal(name, accountPath)
+}
+
+// Unseal decrypts an account sealed with Seal, leaving its files readable
+// in the data directory. It does nothing for accounts that are not
+// encrypted.
+func (r *DirectoryRepository) Unseal(name string) error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	accountPath, err := r.AccountDir(name)
+	if err != nil {
+		return err
+	}
+	return r.unseal(name, accountPath)
+}
+
+// sealSaved seals an account that was just written, when encryption is
+// on. Linked accounts are left alone. Saves are staged and compared file by
+// file against the stored copy, so the files are sealed only once in
+// place: until then, for the length of the save, they sit unencrypted in
+// the data directory, with the permissions they have in ~/.codex.
+func (r *DirectoryRepository) sealSaved(name, accountPath string) error {
+	if !r.encrypt || r.isLinked(accountPath) {
+		return nil
+	}
+	if err := r.seal(name, accountPath); err != nil {
+		return fmt.Errorf("account '%s' was saved but not encrypted: %w", name, err)
+	}
+	return nil
+}
+
+func (r *DirectoryRepository) seal(name, accountPath string) error {
+	if sealed(accountPath) {
+		return nil
+	}
+	key, err := r.passphrase()
+	if err != nil {
+		return err
+	}
+	if err := r.expand(name, accountPath); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(accountPath)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.Name() != ".account.json" {
+			names = append(names, entry.Name())
+		}
+	}
+
+	archive := filepath.Join(accountPath, sealName)
+	if err := writeSealed(archive+".tmp", accountPath, names, key); err != nil {
+		os.Remove(archive + ".tmp")
+		return fmt.Errorf("failed to encrypt account: %w", err)
+	}
+	if err := os.Rename(archive+".tmp", archive); err != nil {
+		return err
+	}
+	for _, n := range names {
+		if err := os.RemoveAll(filepath.Join(accountPath, n)); err != nil {
+			return err
+		}
+	}
+
+	// A cache entry is a readable copy of the account
+	if err := os.RemoveAll(r.paths.CachePath(name)); err != nil {
+		return err
+	}
+	r.log.Debug("encrypted account", "account", name)
+	return nil
+}
+
+// unseal decrypts the account at accountPath in place if it is sealed.
+// An interrupted decryption leaves the archive in place, so it is simply
+// redone.
+func (r *DirectoryRepository) unseal(name, accountPath string) error {
+	archive := filepath.Join(accountPath, sealName)
+	if _, err := os.Stat(archive); os.IsNotExist(err) {
+		return nil
+	}
+	key, err := r.passphrase()
+	if err != nil {
+		return err
+	}
+
+	r.log.Debug("decrypting account", "account", name)
+	if err := readSealed(archive, accountPath, key); err != nil {
+		return fmt.Errorf("failed to decrypt account '%s': %w", name, err)
+	}
+	return os.Remove(archive)
+}
+
+// unsealScratch decrypts the sealed account at accountPath into a new
+// scratch directory in the data directory, leaving the stored copy
+// sealed. The caller removes the directory.
+func (r *DirectoryRepository) unsealScratch(name, accountPath string) (string, error) {
+	key, err := r.passphrase()
+	if err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp(r.paths.DataDir, unsealPrefix+name+"-")
+	if err != nil {
+		return "", err
+	}
+	if err := readSealed(filepath.Join(accountPath, sealName), dir, key); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to decrypt account '%s': %w", name, err)
+	}
+	return dir, nil
+}
+
+// openDir returns a directory holding the files of a saved account for
+// reading, unpacking it first if it is compressed. An encrypted account is
+// decrypted into a scratch directory that release removes.
+func (r *DirectoryRepository) openDir(name string) (dir string, release func(), err error) {
+	unlock, err := r.lock()
+	if err != nil {
+		return "", nil, err
+	}
+	defer unlock()
+
+	accountPath, err := r.AccountDir(name)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := r.expand(name, accountPath); err != nil {
+		return "", nil, err
+	}
+	if !sealed(accountPath) {
+		return accountPath, func() {}, nil
+	}
+	dir, err = r.unsealScratch(name, accountPath)
+	if err != nil {
+		return "", nil, err
+	}
+	return dir, func() { _ = os.RemoveAll(dir) }, nil
+}
+
+// writeSealed archives the named entries of dir, encrypted with key, at
+// dst.
+func writeSealed(dst, dir string, names []string, key []byte) error {
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cw, err := crypt.NewWriter(f, key)
+	if err != nil {
+		return err
+	}
+	if err := packTo(cw, dir, names); err != nil {
+		return err
+	}
+	if err := cw.Close(); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// readSealed decrypts the archive at path with key and unpacks it into
+// dir.
+func readSealed(path, dir string, key []byte) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cr, err := crypt.NewReader(f, key)
+	if err != nil {
+		return err
+	}
+	if err := readPack(cr, dir); err != nil {
+		return err
+	}
+	// Read to the end so a truncated archive is detected
+	_, err = io.Copy(io.Discard, cr)
+	return err
+}
@@ -0,0 +1,263 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/delhombre/cxa/internal/history"
+)
+
+// snapshotLayout names snapshots by when they were taken, so they sort by
+// age. Snapshots taken within the same second get a numeric suffix.
+const snapshotLayout = "20060102-150405"
+
+// DefaultSnapshots is how many snapshots of each account are kept unless
+// SetSnapshots says otherwise.
+const DefaultSnapshots = 5
+
+// Snapshot is a stored earlier version of an account.
+type Snapshot struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	Size int64     `json:"size"`
+}
+
+// SetSnapshots sets how many earlier versions of each account are kept.
+// Zero turns snapshots off.
+func (r *DirectoryRepository) SetSnapshots(keep int) {
+	r.snapshots = keep
+}
+
+// snapshot keeps the stored copy of an account as a snapshot before it is
+// replaced, unless it matches the newest snapshot already, and drops the
+// oldest snapshots past the configured count. Files unchanged since the
+// newest snapshot are hardlinked to it, which is safe because nothing
+// writes into snapshots; on APFS the snapshot is a copy-on-write clone
+// instead, taken in one step. A failure is kept as a warning: it should not
+// stop the save that replaces the account.
+func (r *DirectoryRepository) snapshot(name, accountPath string) {
+	if r.snapshots <= 0 {
+		return
+	}
+	if _, err := os.Stat(accountPath); err != nil {
+		return
+	}
+	if err := r.takeSnapshot(name, accountPath); err != nil {
+		r.warnings.Record("snapshot", fmt.Sprintf("failed to snapshot '%s' before replacing it: %v", name, err))
+		return
+	}
+	r.pruneSnapshots(name)
+}
+
+func (r *DirectoryRepository) takeSnapshot(name, accountPath string) error {
+	snapshots, err := r.Snapshots(name)
+	if err != nil {
+		return err
+	}
+
+	opts := r.copyOptions()
+	if len(snapshots) > 0 {
+		latest := filepath.Join(r.paths.SnapshotsDir(name), snapshots[0].ID)
+		current, err := contentKey(accountPath)
+		if err != nil {
+			return err
+		}
+		if previous, err := contentKey(latest); err == nil && previous == current {
+			return nil
+		}
+		opts.base = latest
+	}
+
+	dir := r.paths.SnapshotsDir(name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	// Number snapshots within a second past every one kept, so a new
+	// snapshot never sorts before an older one
+	now := time.Now().Format(snapshotLayout)
+	id := now
+	seq := 0
+	for _, s := range snapshots {
+		if strings.HasPrefix(s.ID, now) {
+			seq = max(seq, snapshotSeq(s.ID))
+		}
+	}
+	if seq > 0 {
+		id = fmt.Sprintf("%s-%d", now, seq+1)
+	}
+
+	dst := filepath.Join(dir, id)
+	if err := cloneStaged(accountPath, dst, dst+saveStagingSuffix, opts); err != nil {
+		_ = os.RemoveAll(dst + saveStagingSuffix)
+		return err
+	}
+	r.log.Debug("took snapshot", "account", name, "snapshot", id)
+	return nil
+}
+
+// contentKey identifies the contents of an account directory, from its
+// manifest where there is one, as hashing every file is slow.
+func contentKey(dir string) (string, error) {
+	m, err := readManifest(dir)
+	if err != nil || m == nil {
+		return hashTree(dir)
+	}
+	data, err := json.Marshal(m.Files)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return "manifest:" + hex.EncodeToString(sum[:]), nil
+}
+
+// pruneSnapshots removes all but the configured number of newest
+// snapshots of an account, sparing one being restored.
+func (r *DirectoryRepository) pruneSnapshots(name string) {
+	snapshots, err := r.Snapshots(name)
+	if err == nil && len(snapshots) > r.snapshots {
+		for _, s := range snapshots[r.snapshots:] {
+			if s.ID == r.restoring {
+				continue
+			}
+			if err = os.RemoveAll(filepath.Join(r.paths.SnapshotsDir(name), s.ID)); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		r.warnings.Record("snapshot", fmt.Sprintf("failed to drop old snapshots of '%s': %v", name, err))
+	}
+}
+
+// Snapshots returns the snapshots of an account, newest first.
+func (r *DirectoryRepository) Snapshots(name string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(r.paths.SnapshotsDir(name))
+	if os.IsNotExist(err) {
+		return []Snapshot{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []Snapshot{}
+	for _, entry := range entries {
+		id := entry.Name()
+		if !entry.IsDir() || strings.HasSuffix(id, saveStagingSuffix) {
+			continue
+		}
+		t, err := time.ParseInLocation(snapshotLayout, id[:min(len(id), len(snapshotLayout))], time.Local)
+		if err != nil {
+			continue
+		}
+		size, err := dirSize(filepath.Join(r.paths.SnapshotsDir(name), id))
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, Snapshot{ID: id, Time: t, Size: size})
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		if !snapshots[i].Time.Equal(snapshots[j].Time) {
+			return snapshots[i].Time.After(snapshots[j].Time)
+		}
+		return snapshotSeq(snapshots[i].ID) > snapshotSeq(snapshots[j].ID)
+	})
+	return snapshots, nil
+}
+
+// snapshotSeq returns the suffix telling apart snapshots taken within the
+// same second, or 1 for the first of them.
+func snapshotSeq(id string) int {
+	_, suffix, ok := strings.Cut(id[min(len(id), len(snapshotLayout)):], "-")
+	if !ok {
+		return 1
+	}
+	n, _ := strconv.Atoi(suffix)
+	return n
+}
+
+// Rollback replaces the stored copy of an account with one of its
+// snapshots, keeping the account's current metadata. What it replaces is
+// snapshotted first, so a rollback can be rolled back. Rolling back the
+// current account also puts the snapshot into ~/.codex.
+func (r *DirectoryRepository) Rollback(name, id string) error {
+	unlock, err := r.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	accountPath, err := r.AccountDir(name)
+	if err != nil {
+		return err
+	}
+	acc, err := r.Get(name)
+	if err != nil {
+		return err
+	}
+	if err := acc.CheckWritable(); err != nil {
+		return err
+	}
+	src := filepath.Join(r.paths.SnapshotsDir(name), id)
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return fmt.Errorf("invalid snapshot '%s'", id)
+	}
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("snapshot '%s' of '%s' not found", id, name)
+	}
+
+	// Snapshotting what is replaced must not drop the snapshot restored
+	r.restoring = id
+	defer func() { r.restoring = "" }()
+
+	current, _ := r.Current()
+	if current == name {
+		// Keep live changes as part of what is replaced
+		if err := r.saveActive(context.Background(), name); err != nil {
+			return fmt.Errorf("failed to save current account: %w", err)
+		}
+	}
+	r.snapshot(name, accountPath)
+
+	if err := copyStaged(src, accountPath, accountPath+saveStagingSuffix, r.copyOptions()); err != nil {
+		return fmt.Errorf("failed to roll back: %w", err)
+	}
+	r.restoring = ""
+	if r.snapshots > 0 {
+		r.pruneSnapshots(name)
+	}
+	// Credentials kept in the keychain are not versioned; ones the
+	// snapshot carries go where credentials are kept now
+	if _, err := os.Stat(filepath.Join(accountPath, authFile)); err == nil {
+		if err := r.stowAuth(name, accountPath, acc); err != nil {
+			return fmt.Errorf("failed to roll back: %w", err)
+		}
+		if err := writeManifest(accountPath); err != nil {
+			return fmt.Errorf("failed to record manifest: %w", err)
+		}
+	}
+	acc.UpdatedAt = time.Now()
+	if err := r.writeMetadata(accountPath, acc); err != nil {
+		return err
+	}
+	if err := r.sealSaved(name, accountPath); err != nil {
+		return err
+	}
+	r.dedupAccount(name, accountPath)
+	r.record(history.Entry{Op: history.OpRollback, Account: name, Snapshot: id})
+	r.stored(string(history.OpRollback), name)
+	r.log.Debug("rolled back account", "account", name, "snapshot", id)
+
+	if current == name {
+		return r.Activate(name)
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/delhombre/cxa/internal/account"
+	"github.com/delhombre/cxa/internal/remote"
+)
+
+// SyncAction is what syncing does with an account.
+type SyncAction string
+
+const (
+	SyncNone     SyncAction = "in_sync"
+	SyncPush     SyncAction = "push"
+	SyncPull     SyncAction = "pull"
+	SyncConflict SyncAction = "conflict"
+)
+
+// Conflict preferences for SyncOptions.Prefer.
+const (
+	PreferLocal  = "local"
+	PreferRemote = "remote"
+	PreferNewer  = "newer"
+)
+
+// SyncOptions tunes a sync.
+type SyncOptions struct {
+	// Accounts limits the sync to these accounts; empty means all.
+	Accounts []string
+
+	// Prefer resolves conflicts: PreferLocal, PreferRemote, or
+	// PreferNewer by UpdatedAt. Empty leaves conflicts alone.
+	Prefer string
+
+	// DryRun plans the sync without changing anything.
+	DryRun bool
+}
+
+// SyncResult is the outcome of syncing one account.
+type SyncResult struct {
+	Account string     `json:"account"`
+	Action  SyncAction `json:"action"`
+
+	// Resolved is set when a conflict was settled by SyncOptions.Prefer;
+	// Action is then the push or pull that settled it.
+	Resolved bool `json:"resolved,omitempty"`
+
+	LocalUpdatedAt  *time.Time `json:"local_updated_at,omitempty"`
+	RemoteUpdatedAt *time.Time `json:"remote_updated_at,omitempty"`
+	Error           string     `json:"error,omitempty"`
+}
+
+// syncState records, per remote, the content hash of each account as of
+// its last sync. An account that still has that hash on one side has only
+// changed on the other.
+type syncState map[string]map[string]string
+
+// Sync reconciles saved accounts with the remote named remoteName reached
+// through t. Accounts changed on one side since the last sync are copied
+// to the other; accounts changed on both sides, or found on both sides at
+// the first sync with different contents, are conflicts. The current
+// account is saved first so the live ~/.codex takes part, and refreshed
+// afterwards if it was pulled.
+func (r *DirectoryRepository) Sync(t remote.Transport, remoteName string, opts SyncOptions) ([]SyncResult, error) {
+	unlock, err := r.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	current, _ := r.Current()
+	if current != "" && !opts.DryRun {
+		if err := r.saveActive(context.Background(), current); err != nil {
+			return nil, fmt.Errorf("failed to save current account: %w", err)
+		}
+	}
+
+	index, err := t.ReadIndex()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read remote: %w", err)
+	}
+	state, err := r.loadSyncState()
+	if err != nil {
+		return nil, err
+	}
+	if state[remoteName] == nil {
+		state[remoteName] = make(map[string]string)
+	}
+	base := state[remoteName]
+
+	names := opts.Accounts
+	if len(names) == 0 {
+		seen := make(map[string]bool)
+		accounts, err := r.List()
+		if err != nil {
+			return nil, err
+		}
+		for _, acc := range accounts {
+			seen[acc.Name] = true
+		}
+		for name := range index.Accounts {
+			seen[name] = true
+		}
+		for name := range seen {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	host, _ := os.Hostname()
+	results := make([]SyncResult, 0, len(names))
+	indexChanged := false
+	for _, name := range names {
+		result := SyncResult{Account: name}
+
+		// Names in the index come from the remote, which may not be
+		// trusted to keep them inside the data directory
+		if err := account.ValidateName(name); err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		localHash := ""
+		acc, err := r.Get(name)
+		if err == nil {
+			result.LocalUpdatedAt = &acc.UpdatedAt
+			if localHash, err = hashTree(r.paths.AccountPath(name)); err != nil {
+				result.Error = err.Error()
+				results = append(results, result)
+				continue
+			}
+		}
+		entry, onRemote := index.Accounts[name]
+		if onRemote {
+			result.RemoteUpdatedAt = &entry.UpdatedAt
+		}
+
+		switch {
+		case localHash == "" && !onRemote:
+			result.Error = fmt.Sprintf("account '%s' not found locally or on the remote", name)
+		case localHash == entry.Hash:
+			result.Action = SyncNone
+		case localHash == "":
+			result.Action = SyncPull
+		case !onRemote || base[name] == entry.Hash:
+			result.Action = SyncPush
+		case base[name] == localHash:
+			result.Action = SyncPull
+		default:
+			result.Action = SyncConflict
+			switch opts.Prefer {
+			case PreferLocal:
+				result.Action, result.Resolved = SyncPush, true
+			case PreferRemote:
+				result.Action, result.Resolved = SyncPull, true
+			case PreferNewer:
+				result.Resolved = true
+				if acc.UpdatedAt.After(entry.UpdatedAt) {
+					result.Action = SyncPush
+				} else {
+					result.Action = SyncPull
+				}
+			}
+		}
+		if result.Error != "" || opts.DryRun {
+			results = append(results, result)
+			continue
+		}
+
+		switch result.Action {
+		case SyncNone:
+			base[name] = localHash
+		case SyncPush:
+			if err := t.Push(name, r.paths.AccountPath(name)); err != nil {
+				result.Error = err.Error()
+				break
+			}
+			index.Accounts[name] = remote.IndexEntry{
+				Hash:      localHash,
+				UpdatedAt: acc.UpdatedAt,
+				PushedAt:  time.Now(),
+				PushedBy:  host,
+			}
+			indexChanged = true
+			base[name] = localHash
+		case SyncPull:
+			if err := r.pull(t, name, entry.Hash); err != nil {
+				result.Error = err.Error()
+				break
+			}
+			base[name] = entry.Hash
+			if name == current {
+				if err := r.Activate(name); err != nil {
+					result.Error = fmt.Sprintf("pulled, but failed to refresh ~/.codex: %v", err)
+				}
+			}
+		}
+		results = append(results, result)
+	}
+
+	if opts.DryRun {
+		return results, nil
+	}
+	if indexChanged {
+		if err := t.WriteIndex(index); err != nil {
+			return results, fmt.Errorf("failed to update remote index: %w", err)
+		}
+	}
+	return results, r.saveSyncState(state)
+}
+
+// pull replaces the saved account with the remote copy, via staging so a
+// failed download leaves the saved account intact. A copy whose contents
+// do not hash to want, as recorded in the remote index, is refused.
+func (r *DirectoryRepository) pull(t remote.Transport, name, want string) error {
+	if err := account.ValidateName(name); err != nil {
+		return err
+	}
+	if err := r.checkWritable(name); err != nil {
+		return err
+	}
+	if acc, err := r.Get(name); err == nil && acc.Protected {
+		return &account.ProtectedError{Name: name}
+	}
+	if err := r.paths.EnsureDirs(); err != nil {
+		return err
+	}
+
+	accountPath := r.paths.AccountPath(name)
+	staging := accountPath + saveStagingSuffix
+	if err := os.RemoveAll(staging); err != nil {
+		return err
+	}
+	if err := t.Pull(name, staging); err != nil {
+		os.RemoveAll(staging)
+		return err
+	}
+	if got, err := hashTree(staging); err != nil || got != want {
+		os.RemoveAll(staging)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("copy of '%s' on the remote does not match the remote index; it may be damaged or still being pushed", name)
+	}
+	if err := os.RemoveAll(r.paths.CachePath(name)); err != nil {
+		return err
+	}
+	if err := swapIn(staging, accountPath); err != nil {
+		return err
+	}
+	return retargetLinks(accountPath, staging, accountPath)
+}
+
+func (r *DirectoryRepository) loadSyncState() (syncState, error) {
+	data, err := os.ReadFile(r.paths.SyncStateFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return syncState{}, nil
+		}
+		return nil, err
+	}
+
+	state := syncState{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid sync state: %w", err)
+	}
+	return state, nil
+}
+
+func (r *DirectoryRepository) saveSyncState(state syncState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.paths.SyncStateFile()), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.paths.SyncStateFile(), data, 0644)
+}
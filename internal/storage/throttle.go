@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// ioThrottle paces writes across a whole copy operation so that its
+// average throughput stays under a fixed number of bytes per second.
+type ioThrottle struct {
+	mu      sync.Mutex
+	limit   int64
+	start   time.Time
+	written int64
+}
+
+// newIOThrottle returns a throttle for limit bytes per second, or nil if
+// limit is not positive.
+func newIOThrottle(limit int64) *ioThrottle {
+	if limit <= 0 {
+		return nil
+	}
+	return &ioThrottle{limit: limit, start: time.Now()}
+}
+
+// wait records n written bytes and sleeps until the running average is
+// back under the limit.
+func (t *ioThrottle) wait(n int) {
+	t.mu.Lock()
+	t.written += int64(n)
+	due := time.Duration(float64(t.written) / float64(t.limit) * float64(time.Second))
+	t.mu.Unlock()
+	if d := due - time.Since(t.start); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// writer wraps w so writes through it are throttled. A nil throttle
+// returns w unchanged.
+func (t *ioThrottle) writer(w io.Writer) io.Writer {
+	if t == nil {
+		return w
+	}
+	return &throttledWriter{w: w, throttle: t}
+}
+
+type throttledWriter struct {
+	w        io.Writer
+	throttle *ioThrottle
+}
+
+func (tw *throttledWriter) Write(p []byte) (int, error) {
+	n, err := tw.w.Write(p)
+	tw.throttle.wait(n)
+	return n, err
+}
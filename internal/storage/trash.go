@@ -0,0 +1,279 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/delhombre/cxa/internal/account"
+	"github.com/delhombre/cxa/internal/history"
+)
+
+// DefaultTrashRetention is how long deleted accounts are kept unless
+// SetTrashRetention says otherwise.
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// Layout of a trash entry: the account directory, its snapshots, and a
+// record of what was deleted when.
+const (
+	trashAccount   = "account"
+	trashSnapshots = "snapshots"
+	trashInfo      = "trash.json"
+)
+
+// TrashEntry is a deleted account kept in the trash.
+type TrashEntry struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	DeletedAt time.Time `json:"deleted_at"`
+	Size      int64     `json:"size"`
+
+	// Keychain is set when the credentials of the account were moved
+	// aside in the keyring rather than into the trash.
+	Keychain bool `json:"keychain,omitempty"`
+}
+
+// SetTrashRetention sets how long deleted accounts are kept in the trash
+// before they are removed for good. Zero turns the trash off, so deleting
+// removes accounts at once.
+func (r *DirectoryRepository) SetTrashRetention(d time.Duration) {
+	r.trashRetention = d
+}
+
+// trashKey files the keyring credentials of a trashed account. Account
+// names cannot start with a dot, so it never names an account.
+func trashKey(id string) string {
+	return ".trash-" + id
+}
+
+// trash moves a deleted account, with its snapshots, into the trash. The
+// caller holds the lock and has checked the account may be deleted.
+func (r *DirectoryRepository) trash(name, accountPath string, acc *account.Account) error {
+	now := time.Now()
+	id := now.Format(snapshotLayout) + "-" + name
+	dir := filepath.Join(r.paths.TrashDir(), id)
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(dir); os.IsNotExist(err) {
+			break
+		}
+		id = fmt.Sprintf("%s-%s-%d", now.Format(snapshotLayout), name, i)
+		dir = filepath.Join(r.paths.TrashDir(), id)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	entry := TrashEntry{ID: id, Name: name, DeletedAt: now}
+	if acc != nil && acc.Keychain && r.keyring != nil {
+		auth, err := r.keyringAuth(name)
+		if err == nil {
+			err = r.keyring.Set(trashKey(id), auth)
+		}
+		if err != nil {
+			_ = os.RemoveAll(dir)
+			return fmt.Errorf("failed to keep the credentials of '%s': %w", name, err)
+		}
+		entry.Keychain = true
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, trashInfo), data, 0600)
+	}
+	if err == nil {
+		err = os.Rename(accountPath, filepath.Join(dir, trashAccount))
+	}
+	if err != nil {
+		if entry.Keychain {
+			_ = r.keyring.Delete(trashKey(id))
+		}
+		_ = os.RemoveAll(dir)
+		return err
+	}
+
+	if entry.Keychain {
+		if err := r.keyring.Delete(name); err != nil {
+			r.warnings.Record("keychain", fmt.Sprintf("failed to remove the credentials of deleted account '%s': %v", name, err))
+		}
+	}
+	if err := os.Rename(r.paths.SnapshotsDir(name), filepath.Join(dir, trashSnapshots)); err != nil && !os.IsNotExist(err) {
+		r.warnings.Record("snapshot", fmt.Sprintf("failed to move the snapshots of deleted account '%s': %v", name, err))
+	}
+	r.log.Debug("moved account to the trash", "account", name, "id", id)
+	return nil
+}
+
+// Trash returns the deleted accounts kept in the trash, newest first.
+func (r *DirectoryRepository) Trash() ([]TrashEntry, error) {
+	dirs, err := os.ReadDir(r.paths.TrashDir())
+	if os.IsNotExist(err) {
+		return []TrashEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []TrashEntry{}
+	for _, d := range dirs {
+		entry, err := r.trashEntry(d.Name())
+		if err != nil {
+			// Left by an interrupted delete; purging clears it
+			continue
+		}
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].DeletedAt.After(entries[j].DeletedAt)
+	})
+	return entries, nil
+}
+
+func (r *DirectoryRepository) trashEntry(id string) (*TrashEntry, error) {
+	dir := filepath.Join(r.paths.TrashDir(), id)
+	data, err := os.ReadFile(filepath.Join(dir, trashInfo))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("'%s' is not in the trash", id)
+		}
+		return nil, err
+	}
+	var entry TrashEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("invalid trash entry %s: %w", id, err)
+	}
+	entry.ID = id
+	entry.Size, _ = dirSize(filepath.Join(dir, trashAccount))
+	return &entry, nil
+}
+
+// RestoreTrash puts a deleted account back from the trash, under name or
+// under its old name when name is empty.
+func (r *DirectoryRepository) RestoreTrash(id, name string) (*account.Account, error) {
+	unlock, err := r.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if id == "" || filepath.Base(id) != id {
+		return nil, fmt.Errorf("'%s' is not in the trash", id)
+	}
+	entry, err := r.trashEntry(id)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = entry.Name
+	}
+	if err := account.ValidateName(name); err != nil {
+		return nil, err
+	}
+	if err := r.policy.CheckStore(r.encrypt); err != nil {
+		return nil, err
+	}
+	if err := r.paths.EnsureDirs(); err != nil {
+		return nil, err
+	}
+	accountPath := r.paths.AccountPath(name)
+	if _, err := os.Stat(accountPath); err == nil {
+		return nil, fmt.Errorf("account '%s' already exists; restore it under another name", name)
+	}
+
+	dir := filepath.Join(r.paths.TrashDir(), id)
+	if entry.Keychain {
+		auth, err := r.keyring.Get(trashKey(id))
+		if err == nil {
+			err = r.keyring.Set(name, auth)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore the credentials of '%s': %w", entry.Name, err)
+		}
+	}
+	if err := os.Rename(filepath.Join(dir, trashAccount), accountPath); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(filepath.Join(dir, trashSnapshots), r.paths.SnapshotsDir(name)); err != nil && !os.IsNotExist(err) {
+		r.warnings.Record("snapshot", fmt.Sprintf("failed to restore the snapshots of '%s': %v", name, err))
+	}
+	r.removeTrash(id, entry.Keychain)
+	if err := r.sealSaved(name, accountPath); err != nil {
+		return nil, err
+	}
+
+	acc, err := r.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	if acc.Name != name {
+		acc.Name = name
+		if err := r.writeMetadata(accountPath, acc); err != nil {
+			return nil, err
+		}
+	}
+	r.record(history.Entry{Op: history.OpRestore, Account: name, From: entry.Name})
+	r.stored(string(history.OpRestore), name)
+	r.log.Debug("restored account from the trash", "account", name, "id", id)
+	return acc, nil
+}
+
+// EmptyTrash removes every deleted account for good. It returns how many
+// were removed.
+func (r *DirectoryRepository) EmptyTrash() (int, error) {
+	unlock, err := r.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	return r.purgeTrash(0)
+}
+
+// purgeTrash removes the deleted accounts kept longer than retention, and
+// anything left by an interrupted delete.
+func (r *DirectoryRepository) purgeTrash(retention time.Duration) (int, error) {
+	dirs, err := os.ReadDir(r.paths.TrashDir())
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var purged int
+	for _, d := range dirs {
+		entry, err := r.trashEntry(d.Name())
+		if err == nil && time.Since(entry.DeletedAt) < retention {
+			continue
+		}
+		if err := r.removeTrash(d.Name(), err == nil && entry.Keychain); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// removeTrash removes a trash entry and the credentials filed for it.
+func (r *DirectoryRepository) removeTrash(id string, keychain bool) error {
+	if keychain && r.keyring != nil {
+		if err := r.keyring.Delete(trashKey(id)); err != nil {
+			r.warnings.Record("keychain", fmt.Sprintf("failed to remove the credentials of trashed account '%s': %v", id, err))
+		}
+	}
+	return os.RemoveAll(filepath.Join(r.paths.TrashDir(), id))
+}
+
+// purgeExpiredTrash is purgeTrash with the configured retention, keeping
+// a failure as a warning.
+func (r *DirectoryRepository) purgeExpiredTrash() {
+	if r.trashRetention <= 0 {
+		return
+	}
+	if n, err := r.purgeTrash(r.trashRetention); err != nil {
+		r.warnings.Record("trash", fmt.Sprintf("failed to remove expired accounts from the trash: %v", err))
+	} else if n > 0 {
+		r.log.Debug("removed expired accounts from the trash", "count", n)
+	}
+}
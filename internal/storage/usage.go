@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// DiskUsage returns the total size in bytes of the cxa data directory.
+func (r *DirectoryRepository) DiskUsage() (int64, error) {
+	return dirSize(r.paths.DataDir)
+}
+
+// AccountSize returns the size in bytes of a saved account.
+func (r *DirectoryRepository) AccountSize(name string) (int64, error) {
+	dir, err := r.AccountDir(name)
+	if err != nil {
+		return 0, err
+	}
+	return dirSize(dir)
+}
+
+// dirSize sums the sizes of regular files under dir without following
+// symlinks. A missing dir has size zero.
+func dirSize(dir string) (int64, error) {
+	var total int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/delhombre/cxa/internal/account"
+)
+
+// WatchOptions tunes Watch.
+type WatchOptions struct {
+	// Interval is how often ~/.codex is scanned for changes.
+	Interval time.Duration
+
+	// Settle is how long ~/.codex must stay unchanged before it is saved,
+	// so a burst of writes from Codex becomes one save.
+	Settle time.Duration
+
+	// OnSave, if set, is called after every save attempt.
+	OnSave func(name string, err error)
+}
+
+// Watch saves ~/.codex into the current account whenever it changes, until
+// ctx is cancelled, so the stored copy never falls far behind the live one.
+// Changes are found by polling file sizes and modification times, which
+// costs little for a directory the size of ~/.codex and works the same on
+// every platform. A pending change is saved before Watch returns. When the
+// current account changes, as after cxa switch, the new ~/.codex is taken
+// as already saved.
+func (r *DirectoryRepository) Watch(ctx context.Context, opts WatchOptions) error {
+	if opts.Interval <= 0 {
+		opts.Interval = 2 * time.Second
+	}
+	if opts.Settle < opts.Interval {
+		opts.Settle = opts.Interval
+	}
+
+	watched, err := r.Current()
+	if err != nil {
+		return err
+	}
+	seen, err := fingerprintTree(r.liveDir())
+	if err != nil {
+		return err
+	}
+	saved := seen
+
+	// Anything changed since the last save counts as pending from the start
+	pending := false
+	changedAt := time.Now()
+	if watched != "" {
+		live, err := hashTree(r.liveDir())
+		if err != nil {
+			return err
+		}
+		stored, err := hashTree(r.paths.AccountPath(watched))
+		pending = err != nil || live != stored
+	}
+
+	save := func() {
+		_, err := r.Save(watched)
+		var protected *account.ProtectedError
+		if errors.As(err, &protected) {
+			// Locked accounts are left as they were saved
+			saved, pending = seen, false
+			return
+		}
+		if opts.OnSave != nil {
+			opts.OnSave(watched, err)
+		}
+		if err != nil {
+			// Try again once the next settle period has passed
+			changedAt = time.Now()
+			return
+		}
+		saved = seen
+		pending = false
+		r.refreshCache()
+	}
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if fp, err := fingerprintTree(r.liveDir()); err == nil && fp != seen {
+				seen, pending = fp, fp != saved
+			}
+			if pending && watched != "" {
+				save()
+			}
+			return nil
+		case <-ticker.C:
+		}
+
+		current, err := r.Current()
+		if err != nil {
+			continue
+		}
+		fp, err := fingerprintTree(r.liveDir())
+		if err != nil {
+			// ~/.codex is briefly missing while an account is swapped in
+			continue
+		}
+
+		if current != watched {
+			watched, seen, saved, pending = current, fp, fp, false
+			continue
+		}
+		if fp != seen {
+			seen, changedAt = fp, time.Now()
+			pending = fp != saved
+			continue
+		}
+		if pending && watched != "" && time.Since(changedAt) >= opts.Settle {
+			save()
+		}
+	}
+}
+
+// fingerprintTree summarizes the names, sizes, and modification times of
+// everything under dir. It changes whenever a file is written, without
+// reading any file contents.
+func fingerprintTree(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\x00%d\n", filepath.ToSlash(relPath), info.Mode(), info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,171 @@
+// Package transfer packages accounts into portable archives.
+package transfer
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/delhombre/cxa/internal/account"
+	"github.com/delhombre/cxa/pkg/codex"
+)
+
+// FormatVersion is the archive layout version written by Export.
+const FormatVersion = 1
+
+// Archive layout: a manifest followed by the account files under a prefix.
+const (
+	manifestName  = "cxa-export.json"
+	accountPrefix = "account"
+	metadataName  = ".account.json"
+)
+
+// Manifest describes an exported account archive.
+type Manifest struct {
+	FormatVersion int              `json:"format_version"`
+	Account       *account.Account `json:"account"`
+	ExportedAt    time.Time        `json:"exported_at"`
+	Excluded      []string         `json:"excluded,omitempty"`
+}
+
+// ExportOptions controls what goes into an export.
+type ExportOptions struct {
+	// ExcludeSessions leaves out sessions to keep the archive small.
+	ExcludeSessions bool
+
+	// ExcludeSecrets leaves out credentials so the archive can be shared
+	// without granting access to the account.
+	ExcludeSecrets bool
+}
+
+// Export writes the account stored in dir to w as a tar.gz archive.
+// Symlinks (such as those created by session sharing) are followed so the
+// archive is self-contained on another machine.
+func Export(w io.Writer, dir string, acc *account.Account, opts ExportOptions) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	manifest := Manifest{
+		FormatVersion: FormatVersion,
+		Account:       acc,
+		ExportedAt:    time.Now(),
+	}
+	excluded := map[string]bool{metadataName: true}
+	if opts.ExcludeSessions {
+		excluded["sessions"] = true
+		manifest.Excluded = append(manifest.Excluded, "sessions")
+	}
+	if opts.ExcludeSecrets {
+		for _, item := range codex.AccountSpecificItems {
+			excluded[item] = true
+			manifest.Excluded = append(manifest.Excluded, item)
+		}
+	}
+
+	if err := writeManifest(tw, &manifest); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if excluded[entry.Name()] {
+			continue
+		}
+		src := filepath.Join(dir, entry.Name())
+		if err := addTree(tw, src, accountPrefix+"/"+entry.Name()); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeManifest(tw *tar.Writer, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	hdr := &tar.Header{
+		Name:    manifestName,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: manifest.ExportedAt,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+// addTree adds src to the archive under name, following symlinks.
+// Broken symlinks are skipped.
+func addTree(tw *tar.Writer, src, name string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if !info.IsDir() {
+		return addFile(tw, src, name, info)
+	}
+
+	hdr := &tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     name + "/",
+		Mode:     int64(info.Mode().Perm()),
+		ModTime:  info.ModTime(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := addTree(tw, filepath.Join(src, entry.Name()), name+"/"+entry.Name()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addFile(tw *tar.Writer, src, name string, info os.FileInfo) error {
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    int64(info.Mode().Perm()),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.CopyN(tw, f, info.Size())
+	return err
+}
@@ -0,0 +1,150 @@
+package transfer
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/delhombre/cxa/internal/account"
+)
+
+// ErrInvalidArchive is returned when an archive is not a cxa export.
+var ErrInvalidArchive = errors.New("not a valid cxa export archive")
+
+// Inspect reads and validates a whole archive without extracting it, and
+// returns its manifest.
+func Inspect(r io.Reader) (*Manifest, error) {
+	return walkArchive(r, func(name string, hdr *tar.Header, tr *tar.Reader) error {
+		return nil
+	})
+}
+
+// Extract validates the archive and writes the account tree into dir,
+// which must not exist yet. It returns the archive's manifest.
+func Extract(r io.Reader, dir string) (*Manifest, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return walkArchive(r, func(name string, hdr *tar.Header, tr *tar.Reader) error {
+		dst := filepath.Join(dir, filepath.FromSlash(name))
+		mode := os.FileMode(hdr.Mode).Perm()
+
+		if hdr.Typeflag == tar.TypeDir {
+			if err := os.MkdirAll(dst, mode|0700); err != nil {
+				return err
+			}
+			return os.Chtimes(dst, hdr.ModTime, hdr.ModTime)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, tr); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		return os.Chtimes(dst, hdr.ModTime, hdr.ModTime)
+	})
+}
+
+// walkArchive validates the archive layout and calls fn for every account
+// entry with its path relative to the account root.
+func walkArchive(r io.Reader, fn func(name string, hdr *tar.Header, tr *tar.Reader) error) (*Manifest, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	manifest, err := readManifest(tr)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+
+		name, err := entryName(hdr)
+		if err != nil {
+			return nil, err
+		}
+		if name == "" {
+			continue
+		}
+		if err := fn(name, hdr, tr); err != nil {
+			return nil, err
+		}
+	}
+
+	return manifest, nil
+}
+
+func readManifest(tr *tar.Reader) (*Manifest, error) {
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	if hdr.Name != manifestName {
+		return nil, fmt.Errorf("%w: missing %s", ErrInvalidArchive, manifestName)
+	}
+
+	var manifest Manifest
+	if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("%w: bad manifest: %v", ErrInvalidArchive, err)
+	}
+	if manifest.FormatVersion < 1 || manifest.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("%w: unsupported format version %d (this cxa reads up to %d)",
+			ErrInvalidArchive, manifest.FormatVersion, FormatVersion)
+	}
+	if manifest.Account == nil {
+		return nil, fmt.Errorf("%w: manifest has no account", ErrInvalidArchive)
+	}
+	if err := account.ValidateName(manifest.Account.Name); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+
+	return &manifest, nil
+}
+
+// entryName returns the path of hdr relative to the account root. It
+// returns "" for the root itself and rejects anything that could escape it.
+func entryName(hdr *tar.Header) (string, error) {
+	if hdr.Typeflag != tar.TypeDir && hdr.Typeflag != tar.TypeReg {
+		return "", fmt.Errorf("%w: unsupported entry type for %s", ErrInvalidArchive, hdr.Name)
+	}
+
+	clean := path.Clean(hdr.Name)
+	if clean == accountPrefix {
+		return "", nil
+	}
+	name, ok := strings.CutPrefix(clean, accountPrefix+"/")
+	if !ok || name == ".." || strings.HasPrefix(name, "../") {
+		return "", fmt.Errorf("%w: unexpected entry %s", ErrInvalidArchive, hdr.Name)
+	}
+	if name == metadataName {
+		return "", nil
+	}
+	return name, nil
+}
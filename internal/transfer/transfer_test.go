@@ -0,0 +1,266 @@
+package transfer_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/delhombre/cxa/internal/account"
+	"github.com/delhombre/cxa/internal/crypt"
+	"github.com/delhombre/cxa/internal/transfer"
+)
+
+// readArchive returns the file contents of a tar.gz archive keyed by name.
+func readArchive(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to open gzip: %v", err)
+	}
+	tr := tar.NewReader(gz)
+
+	files := make(map[string][]byte)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read tar: %v", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", hdr.Name, err)
+		}
+		files[hdr.Name] = content
+	}
+	return files
+}
+
+func TestExport(t *testing.T) {
+	tmpDir := t.TempDir()
+	accountDir := filepath.Join(tmpDir, "work")
+	sharedDir := filepath.Join(tmpDir, "shared")
+
+	if err := os.MkdirAll(accountDir, 0755); err != nil {
+		t.Fatalf("failed to create account dir: %v", err)
+	}
+	if err := os.MkdirAll(sharedDir, 0755); err != nil {
+		t.Fatalf("failed to create shared dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(accountDir, "auth.json"), []byte(`{"token": "x"}`), 0600); err != nil {
+		t.Fatalf("failed to write auth: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(accountDir, ".account.json"), []byte(`{}`), 0644); err != nil {
+		t.Fatalf("failed to write metadata: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sharedDir, "history.jsonl"), []byte("line\n"), 0644); err != nil {
+		t.Fatalf("failed to write history: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(sharedDir, "history.jsonl"), filepath.Join(accountDir, "history.jsonl")); err != nil {
+		t.Fatalf("failed to create symlink: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(accountDir, "sessions"), 0755); err != nil {
+		t.Fatalf("failed to create sessions dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(accountDir, "sessions", "s.json"), []byte("{}"), 0644); err != nil {
+		t.Fatalf("failed to write session: %v", err)
+	}
+
+	var buf bytes.Buffer
+	acc := account.NewAccount("work")
+	if err := transfer.Export(&buf, accountDir, acc, transfer.ExportOptions{ExcludeSessions: true}); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	files := readArchive(t, buf.Bytes())
+
+	var manifest transfer.Manifest
+	if err := json.Unmarshal(files["cxa-export.json"], &manifest); err != nil {
+		t.Fatalf("failed to parse manifest: %v", err)
+	}
+	if manifest.FormatVersion != transfer.FormatVersion {
+		t.Errorf("expected format version %d, got %d", transfer.FormatVersion, manifest.FormatVersion)
+	}
+	if manifest.Account == nil || manifest.Account.Name != "work" {
+		t.Error("manifest should carry the account metadata")
+	}
+
+	if string(files["account/auth.json"]) != `{"token": "x"}` {
+		t.Error("auth.json should be exported")
+	}
+	if string(files["account/history.jsonl"]) != "line\n" {
+		t.Error("symlinked history.jsonl should be exported by content")
+	}
+	if _, ok := files["account/sessions/s.json"]; ok {
+		t.Error("sessions should be excluded")
+	}
+	if _, ok := files["account/.account.json"]; ok {
+		t.Error(".account.json should be carried by the manifest, not the tree")
+	}
+}
+
+func TestExport_ExcludeSecrets(t *testing.T) {
+	accountDir := filepath.Join(t.TempDir(), "work")
+	if err := os.MkdirAll(accountDir, 0755); err != nil {
+		t.Fatalf("failed to create account dir: %v", err)
+	}
+	for _, name := range []string{"auth.json", "license.secret", "config.toml"} {
+		if err := os.WriteFile(filepath.Join(accountDir, name), []byte("x"), 0600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := transfer.Export(&buf, accountDir, account.NewAccount("work"), transfer.ExportOptions{ExcludeSecrets: true}); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	files := readArchive(t, buf.Bytes())
+	for _, name := range []string{"account/auth.json", "account/license.secret"} {
+		if _, ok := files[name]; ok {
+			t.Errorf("%s should be excluded", name)
+		}
+	}
+	if _, ok := files["account/config.toml"]; !ok {
+		t.Error("config.toml should be exported")
+	}
+}
+
+func TestExtract_RoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+	accountDir := filepath.Join(tmpDir, "work")
+
+	if err := os.MkdirAll(filepath.Join(accountDir, "sessions"), 0755); err != nil {
+		t.Fatalf("failed to create sessions dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(accountDir, "sessions", "s.json"), []byte("{}"), 0644); err != nil {
+		t.Fatalf("failed to write session: %v", err)
+	}
+
+	acc := account.NewAccount("work")
+	acc.Email = "me@example.com"
+
+	var buf bytes.Buffer
+	if err := transfer.Export(&buf, accountDir, acc, transfer.ExportOptions{}); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	manifest, err := transfer.Inspect(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if manifest.Account.Email != "me@example.com" {
+		t.Errorf("expected email 'me@example.com', got '%s'", manifest.Account.Email)
+	}
+
+	dest := filepath.Join(tmpDir, "restored")
+	if _, err := transfer.Extract(bytes.NewReader(buf.Bytes()), dest); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dest, "sessions", "s.json"))
+	if err != nil {
+		t.Fatalf("failed to read restored session: %v", err)
+	}
+	if string(data) != "{}" {
+		t.Errorf("expected '{}', got '%s'", string(data))
+	}
+}
+
+func TestExtract_EncryptedRoundTrip(t *testing.T) {
+	crypt.WorkFactor = 10 // Keep the test fast; the stream records the work factor
+
+	tmpDir := t.TempDir()
+	accountDir := filepath.Join(tmpDir, "work")
+	if err := os.MkdirAll(accountDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(accountDir, "auth.json"), []byte(`{"token":"t"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	w, err := crypt.NewWriter(&buf, []byte("secret"))
+	if err != nil {
+		t.Fatalf("NewWriter failed: %v", err)
+	}
+	if err := transfer.Export(w, accountDir, account.NewAccount("work"), transfer.ExportOptions{}); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if bytes.Contains(buf.Bytes(), []byte(`"token"`)) {
+		t.Error("credentials should not appear in the encrypted archive")
+	}
+
+	r, err := crypt.NewReader(bytes.NewReader(buf.Bytes()), []byte("secret"))
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	dest := filepath.Join(tmpDir, "restored")
+	if _, err := transfer.Extract(r, dest); err != nil {
+		t.Fatalf("Extract failed: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dest, "auth.json")); err != nil || string(data) != `{"token":"t"}` {
+		t.Errorf("expected auth.json restored, got %q (%v)", data, err)
+	}
+
+	// A wrong passphrase extracts nothing
+	r, err = crypt.NewReader(bytes.NewReader(buf.Bytes()), []byte("guess"))
+	if err == nil {
+		_, err = transfer.Extract(r, filepath.Join(tmpDir, "wrong"))
+	}
+	if err == nil {
+		t.Error("expected a wrong passphrase to fail")
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "wrong", "auth.json")); !os.IsNotExist(err) {
+		t.Error("a wrong passphrase should not extract anything")
+	}
+}
+
+func TestInspect_RejectsInvalidArchives(t *testing.T) {
+	build := func(entries map[string]string) []byte {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gz)
+		for _, name := range []string{"cxa-export.json", "account/../escape.txt", "other/file.txt"} {
+			content, ok := entries[name]
+			if !ok {
+				continue
+			}
+			tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))})
+			tw.Write([]byte(content))
+		}
+		tw.Close()
+		gz.Close()
+		return buf.Bytes()
+	}
+
+	manifest := `{"format_version": 1, "account": {"name": "work"}}`
+	tests := map[string][]byte{
+		"not gzip":         []byte("plain text"),
+		"missing manifest": build(map[string]string{"other/file.txt": "x"}),
+		"future version":   build(map[string]string{"cxa-export.json": `{"format_version": 99, "account": {"name": "work"}}`}),
+		"bad account name": build(map[string]string{"cxa-export.json": `{"format_version": 1, "account": {"name": "../x"}}`}),
+		"path traversal":   build(map[string]string{"cxa-export.json": manifest, "account/../escape.txt": "x"}),
+		"foreign entry":    build(map[string]string{"cxa-export.json": manifest, "other/file.txt": "x"}),
+	}
+
+	for name, data := range tests {
+		if _, err := transfer.Inspect(bytes.NewReader(data)); !errors.Is(err, transfer.ErrInvalidArchive) {
+			t.Errorf("%s: expected ErrInvalidArchive, got %v", name, err)
+		}
+	}
+}
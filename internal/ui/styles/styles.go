@@ -86,6 +86,7 @@ var (
 	Arrow     = PrimaryStyle.Render("→")
 	Dash      = MutedStyle.Render("─")
 	Caret     = PrimaryStyle.Render("›")
+	Lock      = WarningStyle.Render("⊘")
 )
 
 // Spinner styles
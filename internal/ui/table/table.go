@@ -0,0 +1,158 @@
+// Package table renders aligned text tables that stay readable with wide
+// (CJK) characters and on narrow terminals.
+package table
+
+import (
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	// gap is the number of spaces between columns.
+	gap = 2
+	// minColumnWidth is the narrowest a column is shrunk to when truncating.
+	minColumnWidth = 6
+	// ellipsis marks truncated cells.
+	ellipsis = "…"
+)
+
+// Table is a set of rows rendered in aligned columns. Cells are plain text;
+// styling is applied after layout so widths are measured correctly.
+type Table struct {
+	headers  []string
+	rows     [][]string
+	maxWidth int
+	indent   string
+	style    func(row, col int) lipgloss.Style
+}
+
+// New creates a table with the given column headers. Its width budget
+// defaults to the terminal width, or unlimited when stdout is not a
+// terminal.
+func New(headers ...string) *Table {
+	return &Table{
+		headers:  headers,
+		maxWidth: TerminalWidth(),
+	}
+}
+
+// TerminalWidth returns the width of the terminal on stdout, or 0 if stdout
+// is not a terminal.
+func TerminalWidth() int {
+	w, _, err := term.GetSize(os.Stdout.Fd())
+	if err != nil {
+		return 0
+	}
+	return w
+}
+
+// Row appends a row. Missing cells are rendered empty.
+func (t *Table) Row(cells ...string) *Table {
+	t.rows = append(t.rows, cells)
+	return t
+}
+
+// MaxWidth sets the total width budget, including the indent. Zero means
+// cells are never truncated.
+func (t *Table) MaxWidth(w int) *Table {
+	t.maxWidth = w
+	return t
+}
+
+// Indent prefixes every line with indent.
+func (t *Table) Indent(indent string) *Table {
+	t.indent = indent
+	return t
+}
+
+// Style sets a function styling each cell after layout. Row -1 is the
+// header.
+func (t *Table) Style(fn func(row, col int) lipgloss.Style) *Table {
+	t.style = fn
+	return t
+}
+
+// Render lays out and returns the table.
+func (t *Table) Render() string {
+	widths := t.columnWidths()
+
+	var b strings.Builder
+	t.renderRow(&b, -1, t.headers, widths)
+	for i, row := range t.rows {
+		t.renderRow(&b, i, row, widths)
+	}
+	return b.String()
+}
+
+func (t *Table) renderRow(b *strings.Builder, index int, cells []string, widths []int) {
+	b.WriteString(t.indent)
+	for col, width := range widths {
+		cell := ""
+		if col < len(cells) {
+			cell = cells[col]
+		}
+		if runewidth.StringWidth(cell) > width {
+			cell = runewidth.Truncate(cell, width, ellipsis)
+		}
+
+		last := col == len(widths)-1
+		if !last {
+			cell = runewidth.FillRight(cell, width)
+		}
+		if t.style != nil {
+			cell = t.style(index, col).Render(cell)
+		}
+		b.WriteString(cell)
+		if !last {
+			b.WriteString(strings.Repeat(" ", gap))
+		}
+	}
+	b.WriteString("\n")
+}
+
+// columnWidths returns each column's natural width, shrinking the widest
+// columns until the table fits the width budget.
+func (t *Table) columnWidths() []int {
+	widths := make([]int, len(t.headers))
+	for col, header := range t.headers {
+		widths[col] = runewidth.StringWidth(header)
+	}
+	for _, row := range t.rows {
+		for col := 0; col < len(widths) && col < len(row); col++ {
+			if w := runewidth.StringWidth(row[col]); w > widths[col] {
+				widths[col] = w
+			}
+		}
+	}
+
+	if t.maxWidth <= 0 {
+		return widths
+	}
+
+	budget := t.maxWidth - runewidth.StringWidth(t.indent) - gap*(len(widths)-1)
+	for total(widths) > budget {
+		widest := 0
+		for col := range widths {
+			if widths[col] > widths[widest] {
+				widest = col
+			}
+		}
+		if widths[widest] <= minColumnWidth {
+			break // Cannot shrink further; let the terminal wrap
+		}
+		widths[widest]--
+	}
+	return widths
+}
+
+func total(widths []int) int {
+	sum := 0
+	for _, w := range widths {
+		sum += w
+	}
+	return sum
+}
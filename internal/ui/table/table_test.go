@@ -0,0 +1,64 @@
+package table_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/delhombre/cxa/internal/ui/table"
+	"github.com/mattn/go-runewidth"
+)
+
+func TestRender_AlignsWideCharacters(t *testing.T) {
+	out := table.New("NAME", "EMAIL").
+		MaxWidth(0).
+		Row("仕事", "work@example.com").
+		Row("personal", "me@example.com").
+		Render()
+
+	lines := strings.Split(strings.TrimRight(out, "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d", len(lines))
+	}
+
+	// The email column starts at the same display column on every line
+	col := -1
+	for _, line := range lines[1:] {
+		idx := strings.Index(line, "@")
+		prefix := line[:idx]
+		at := runewidth.StringWidth(prefix[:strings.LastIndex(prefix, " ")+1])
+		if col == -1 {
+			col = at
+		} else if at != col {
+			t.Errorf("misaligned column: %q", line)
+		}
+	}
+}
+
+func TestRender_TruncatesToWidth(t *testing.T) {
+	long := strings.Repeat("x", 60)
+	out := table.New("NAME", "DESCRIPTION").
+		MaxWidth(40).
+		Row("work", long).
+		Render()
+
+	for _, line := range strings.Split(strings.TrimRight(out, "\n"), "\n") {
+		if w := runewidth.StringWidth(line); w > 40 {
+			t.Errorf("line exceeds width budget (%d): %q", w, line)
+		}
+	}
+	if !strings.Contains(out, "…") {
+		t.Error("truncated cell should end with an ellipsis")
+	}
+}
+
+func TestRender_NoLimitKeepsFullCells(t *testing.T) {
+	long := strings.Repeat("界", 50)
+	out := table.New("NAME", "DESCRIPTION").
+		MaxWidth(0).
+		Row("work", long).
+		Render()
+
+	if !strings.Contains(out, long) || strings.Contains(out, "…") {
+		t.Error("cells should not be truncated without a width budget")
+	}
+}
@@ -2,14 +2,18 @@
 package tui
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/delhombre/cxa/internal/account"
+	"github.com/delhombre/cxa/internal/storage"
 	"github.com/delhombre/cxa/internal/ui/styles"
 )
 
@@ -17,8 +21,19 @@ import (
 type Repository interface {
 	List() ([]*account.Account, error)
 	Current() (string, error)
-	Activate(name string) error
+	ActivateContext(ctx context.Context, name string) error
 	Save(name string) (*account.Account, error)
+	SetProgress(fn func(storage.Progress))
+}
+
+// progressMsg is progress reported by the switch running in the
+// background.
+type progressMsg storage.Progress
+
+// switchedMsg ends the switch running in the background.
+type switchedMsg struct {
+	name string
+	err  error
 }
 
 // accountItem implements list.Item for accounts
@@ -28,21 +43,33 @@ type accountItem struct {
 }
 
 func (i accountItem) Title() string {
+	title := i.account.Name
 	if i.isCurrent {
-		return styles.CurrentAccountStyle.Render(i.account.Name) + " " + styles.MutedStyle.Render("(current)")
+		title = styles.CurrentAccountStyle.Render(i.account.Name) + " " + styles.MutedStyle.Render("(current)")
 	}
-	return i.account.Name
+	if i.account.Protected {
+		title += " " + styles.Lock
+	}
+	return title
 }
 
 func (i accountItem) Description() string {
+	var parts []string
 	if i.account.Email != "" {
-		return i.account.Email
+		parts = append(parts, i.account.Email)
+	}
+	if len(i.account.Tags) > 0 {
+		parts = append(parts, styles.PrimaryStyle.Render("#"+strings.Join(i.account.Tags, " #")))
 	}
-	return styles.MutedStyle.Render("Press enter to switch")
+	if len(parts) == 0 {
+		return styles.MutedStyle.Render("Press enter to switch")
+	}
+	return strings.Join(parts, "  ")
 }
 
+// FilterValue matches the account name and its tags.
 func (i accountItem) FilterValue() string {
-	return i.account.Name
+	return strings.Join(append([]string{i.account.Name}, i.account.Tags...), " ")
 }
 
 // Model is the main TUI model
@@ -53,6 +80,15 @@ type Model struct {
 	quitting bool
 	message  string
 	err      error
+
+	// switching is the account being switched to while the copy runs in
+	// the background, and progress how far it got, as read from updates.
+	// cancel stops it.
+	switching string
+	progress  storage.Progress
+	updates   <-chan storage.Progress
+	cancel    context.CancelFunc
+	spinner   spinner.Model
 }
 
 // NewModel creates a new TUI model
@@ -63,14 +99,7 @@ func NewModel(repo Repository) (*Model, error) {
 	}
 
 	current, _ := repo.Current()
-
-	items := make([]list.Item, len(accounts))
-	for i, acc := range accounts {
-		items[i] = accountItem{
-			account:   acc,
-			isCurrent: acc.Name == current,
-		}
-	}
+	items := listItems(accounts, current)
 
 	delegate := list.NewDefaultDelegate()
 	delegate.Styles.SelectedTitle = lipgloss.NewStyle().
@@ -92,6 +121,7 @@ func NewModel(repo Repository) (*Model, error) {
 		list:    l,
 		repo:    repo,
 		current: current,
+		spinner: spinner.New(spinner.WithSpinner(spinner.Dot), spinner.WithStyle(styles.SpinnerStyle)),
 	}, nil
 }
 
@@ -105,25 +135,50 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch {
+		case m.switching != "" && key.Matches(msg, key.NewBinding(key.WithKeys("ctrl+c"))):
+			// Quitting now would cut the copy off; stop it cleanly first
+			m.cancel()
+			m.message = styles.MutedStyle.Render("Cancelling...")
+			return m, nil
+
+		case m.switching != "" && key.Matches(msg, key.NewBinding(key.WithKeys("q", "enter"))):
+			return m, nil
+
 		case key.Matches(msg, key.NewBinding(key.WithKeys("q", "ctrl+c"))):
 			m.quitting = true
 			return m, tea.Quit
 
 		case key.Matches(msg, key.NewBinding(key.WithKeys("enter"))):
-			if item, ok := m.list.SelectedItem().(accountItem); ok {
-				if item.account.Name != m.current {
-					if err := m.repo.Activate(item.account.Name); err != nil {
-						m.err = err
-						m.message = styles.RenderError(err.Error())
-					} else {
-						m.current = item.account.Name
-						m.message = styles.RenderSuccess(fmt.Sprintf("Switched to %s", item.account.Name))
-						// Refresh list
-						m.refreshList()
-					}
-				}
+			if item, ok := m.list.SelectedItem().(accountItem); ok && item.account.Name != m.current {
+				return m, m.startSwitch(item.account.Name)
 			}
 		}
+	case progressMsg:
+		m.progress = storage.Progress(msg)
+		return m, waitProgress(m.updates)
+	case switchedMsg:
+		m.switching = ""
+		switch {
+		case errors.Is(msg.err, context.Canceled):
+			m.message = styles.MutedStyle.Render("Switch cancelled; nothing changed.")
+		case msg.err != nil:
+			m.err = msg.err
+			m.message = styles.RenderError(msg.err.Error())
+		default:
+			m.current = msg.name
+			m.message = styles.RenderSuccess(fmt.Sprintf("Switched to %s", msg.name))
+			// Refresh list; the account just used moves to the top
+			m.refreshList()
+			m.list.Select(0)
+		}
+		return m, nil
+	case spinner.TickMsg:
+		if m.switching == "" {
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
 	case tea.WindowSizeMsg:
 		m.list.SetWidth(msg.Width)
 		h := msg.Height - 4
@@ -138,16 +193,60 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// startSwitch switches to name in the background, so the spinner and the
+// progress of the copy keep drawing meanwhile.
+func (m *Model) startSwitch(name string) tea.Cmd {
+	updates := make(chan storage.Progress, 1)
+	m.repo.SetProgress(func(p storage.Progress) {
+		// Drop progress the view has not caught up with
+		select {
+		case updates <- p:
+		default:
+		}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	m.switching, m.progress, m.updates, m.cancel, m.message = name, storage.Progress{}, updates, cancel, ""
+
+	repo := m.repo
+	activate := func() tea.Msg {
+		defer close(updates)
+		defer cancel()
+		return switchedMsg{name: name, err: repo.ActivateContext(ctx, name)}
+	}
+	return tea.Batch(m.spinner.Tick, activate, waitProgress(updates))
+}
+
+// waitProgress delivers the next progress of a switch, until it ends.
+func waitProgress(updates <-chan storage.Progress) tea.Cmd {
+	return func() tea.Msg {
+		p, ok := <-updates
+		if !ok {
+			return nil
+		}
+		return progressMsg(p)
+	}
+}
+
 func (m *Model) refreshList() {
 	accounts, _ := m.repo.List()
-	items := make([]list.Item, len(accounts))
-	for i, acc := range accounts {
-		items[i] = accountItem{
-			account:   acc,
-			isCurrent: acc.Name == m.current,
+	m.list.SetItems(listItems(accounts, m.current))
+}
+
+// listItems returns the accounts to offer, most recently used first,
+// leaving out archived ones.
+func listItems(accounts []*account.Account, current string) []list.Item {
+	account.SortRecent(accounts)
+	items := make([]list.Item, 0, len(accounts))
+	for _, acc := range accounts {
+		if acc.Archived {
+			continue
 		}
+		items = append(items, accountItem{
+			account:   acc,
+			isCurrent: acc.Name == current,
+		})
 	}
-	m.list.SetItems(items)
+	return items
 }
 
 // View renders the UI
@@ -161,6 +260,12 @@ func (m Model) View() string {
 	// Main list
 	b.WriteString(m.list.View())
 
+	// Switch in progress
+	if m.switching != "" {
+		b.WriteString("\n\n")
+		b.WriteString(m.spinner.View() + " " + switchingStatus(m.switching, m.progress))
+	}
+
 	// Message/error
 	if m.message != "" {
 		b.WriteString("\n\n")
@@ -174,6 +279,19 @@ func (m Model) View() string {
 	return b.String()
 }
 
+// switchingStatus describes how far the switch to name got.
+func switchingStatus(name string, p storage.Progress) string {
+	if p.TotalFiles == 0 {
+		return fmt.Sprintf("Switching to %s...", name)
+	}
+	what := "Switching to " + name
+	if p.Op == "save" {
+		what = "Saving " + p.Account
+	}
+	return fmt.Sprintf("%s %d%% %s", what, p.Percent(),
+		styles.MutedStyle.Render(fmt.Sprintf("(%d/%d files)", p.Files, p.TotalFiles)))
+}
+
 // Run starts the TUI
 func Run(repo Repository) error {
 	model, err := NewModel(repo)
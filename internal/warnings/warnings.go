@@ -0,0 +1,106 @@
+// Package warnings persists non-fatal warnings across runs so they can be
+// reviewed later instead of scrolling away.
+package warnings
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// maxWarnings caps the store; the oldest warnings are dropped first.
+const maxWarnings = 100
+
+// Warning is a recorded non-fatal problem.
+type Warning struct {
+	Source    string    `json:"source"`
+	Message   string    `json:"message"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Count     int       `json:"count"`
+}
+
+// Store is a warnings file on disk.
+type Store struct {
+	path string
+}
+
+// NewStore returns a store backed by the file at path.
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// Add records a warning. Repeats of an unacknowledged warning with the same
+// source and message are folded into it.
+func (s *Store) Add(source, message string) error {
+	list, err := s.List()
+	if err != nil {
+		list = nil // Start over rather than lose new warnings to a corrupt file
+	}
+
+	now := time.Now()
+	for i := range list {
+		if list[i].Source == source && list[i].Message == message {
+			list[i].LastSeen = now
+			list[i].Count++
+			return s.write(list)
+		}
+	}
+
+	list = append(list, Warning{
+		Source:    source,
+		Message:   message,
+		FirstSeen: now,
+		LastSeen:  now,
+		Count:     1,
+	})
+	if len(list) > maxWarnings {
+		list = list[len(list)-maxWarnings:]
+	}
+	return s.write(list)
+}
+
+// Record adds a warning, ignoring failures to persist it. Warnings are
+// best-effort and must never break the operation that raised them.
+func (s *Store) Record(source, message string) {
+	_ = s.Add(source, message)
+}
+
+// List returns all unacknowledged warnings, oldest first.
+func (s *Store) List() ([]Warning, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var list []Warning
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Ack acknowledges and clears all warnings, returning how many there were.
+func (s *Store) Ack() (int, error) {
+	list, _ := s.List()
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	return len(list), nil
+}
+
+func (s *Store) write(list []Warning) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0644)
+}
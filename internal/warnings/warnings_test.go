@@ -0,0 +1,46 @@
+package warnings_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/delhombre/cxa/internal/warnings"
+)
+
+func TestStore_AddListAck(t *testing.T) {
+	store := warnings.NewStore(filepath.Join(t.TempDir(), "warnings.json"))
+
+	if err := store.Add("storage", "skipped invalid account 'x'"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := store.Add("storage", "skipped invalid account 'x'"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := store.Add("sharing", "repaired dangling symlink sessions"); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	list, err := store.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 warnings, got %d", len(list))
+	}
+	if list[0].Count != 2 {
+		t.Errorf("expected repeated warning to be folded (count 2), got %d", list[0].Count)
+	}
+
+	n, err := store.Ack()
+	if err != nil {
+		t.Fatalf("Ack failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("expected 2 acknowledged, got %d", n)
+	}
+
+	list, _ = store.List()
+	if len(list) != 0 {
+		t.Errorf("expected no warnings after Ack, got %d", len(list))
+	}
+}
@@ -0,0 +1,10 @@
+package codex
+
+// HistoryEntry is a line of history.jsonl, to which Codex appends each
+// prompt: the prompt, the session it was sent in, and when, in seconds
+// since the epoch.
+type HistoryEntry struct {
+	SessionID string `json:"session_id"`
+	Ts        int64  `json:"ts"`
+	Text      string `json:"text"`
+}
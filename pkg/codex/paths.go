@@ -2,6 +2,7 @@
 package codex
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 )
@@ -13,6 +14,12 @@ type Paths struct {
 	StateDir  string // ~/.codex-switch (state tracking)
 	SharedDir string // ~/codex-data/shared
 	GroupsDir string // ~/codex-data/groups
+
+	// home is the name of the Codex home in use, from the homes setting,
+	// and configDir the state directory the cxa config is kept in, which
+	// every home shares. Both are empty for the default home.
+	home      string
+	configDir string
 }
 
 // ShareableItems are the items that can be shared between accounts.
@@ -35,16 +42,196 @@ var OptionalShareableItems = []string{
 	"settings.json",
 }
 
-// NewPaths creates a new Paths instance with default locations.
+// ConfigOverrideFile holds an account's own config.toml settings, layered
+// over the shared config.toml when settings are shared. It stays in the
+// account, so it switches with it.
+const ConfigOverrideFile = "config.override.toml"
+
+// Environment variables that relocate the directories cxa works on. Each
+// must hold an absolute path.
+const (
+	// EnvCodexHome is Codex's own variable for its home, used instead of
+	// ~/.codex.
+	EnvCodexHome = "CODEX_HOME"
+	// EnvDataDir relocates the data directory.
+	EnvDataDir = "CXA_DATA_DIR"
+	// EnvStateDir relocates the state directory, and with it the cxa
+	// config.
+	EnvStateDir = "CXA_STATE_DIR"
+	// EnvHome names the Codex home to work on, one of those the homes
+	// setting of the cxa config lists. It is a name, not a path.
+	EnvHome = "CXA_HOME"
+)
+
+// Settings are the locations cxa's own configuration, rather than the
+// environment, sets, for NewPathsWith to apply.
+type Settings struct {
+	// DataDir is the data directory recorded in the cxa config.
+	DataDir string
+	// PinnedDataDir is the data directory pinned by the system policy,
+	// which wins over every other.
+	PinnedDataDir string
+	// Homes are the Codex homes CXA_HOME may name, from the homes setting
+	// of the cxa config.
+	Homes map[string]string
+}
+
+// NewPaths creates a new Paths instance with default locations, as
+// relocated by the environment alone. See NewPathsWith.
 func NewPaths() *Paths {
+	return NewPathsWith(nil)
+}
+
+// NewPathsWith creates a new Paths instance with default locations, as
+// relocated by the environment and by the settings that settings, if set,
+// returns for the cxa config file the environment leads to. The default
+// layout is the XDG one unless cxa already keeps data in the legacy one
+// (see UsesXDG). The data directory is, from lowest to highest
+// precedence, the default, Settings.DataDir, CXA_DATA_DIR, and
+// Settings.PinnedDataDir. CXA_HOME then switches to another Codex home
+// among Settings.Homes, with accounts of its own. Variables that fail
+// CheckEnv are ignored.
+func NewPathsWith(settings func(configFile string) Settings) *Paths {
 	home, _ := os.UserHomeDir()
-	return &Paths{
-		Home:      filepath.Join(home, ".codex"),
-		DataDir:   filepath.Join(home, "codex-data"),
-		StateDir:  filepath.Join(home, ".codex-switch"),
-		SharedDir: filepath.Join(home, "codex-data", "shared"),
-		GroupsDir: filepath.Join(home, "codex-data", "groups"),
+	p := NewPathsAt(home)
+	if UsesXDG(home) {
+		p = NewXDGPathsAt(home)
+	}
+	if dir := envDir(EnvCodexHome); dir != "" {
+		p.Home = dir
+	}
+	if dir := envDir(EnvStateDir); dir != "" {
+		p.StateDir = dir
+	}
+	var s Settings
+	if settings != nil {
+		s = settings(p.ConfigFile())
+	}
+	if s.DataDir != "" {
+		p.SetDataDir(s.DataDir)
+	}
+	if dir := envDir(EnvDataDir); dir != "" {
+		p.SetDataDir(dir)
+	}
+	if s.PinnedDataDir != "" {
+		p.SetDataDir(s.PinnedDataDir)
+	}
+	if name := os.Getenv(EnvHome); name != "" {
+		if dir, ok := s.Homes[name]; ok {
+			p.UseHome(name, dir)
+		}
+	}
+	return p
+}
+
+// UseHome points p at the Codex home dir, listed in the homes setting as
+// name. Its accounts, state, and sharing are kept apart from those of
+// every other home, under homes/<name> in the data and state directories;
+// the cxa config stays shared.
+func (p *Paths) UseHome(name, dir string) {
+	if p.configDir == "" {
+		p.configDir = p.StateDir
+	}
+	p.home = name
+	p.Home = dir
+	p.StateDir = filepath.Join(p.configDir, "homes", name)
+	p.SetDataDir(filepath.Join(p.DataDir, "homes", name))
+}
+
+// HomeName returns the name of the Codex home p works on, or an empty
+// string for the default one.
+func (p *Paths) HomeName() string {
+	return p.home
+}
+
+// envDir returns the directory named by the environment variable key, or
+// an empty string if it is unset or not an absolute path.
+func envDir(key string) string {
+	dir := os.Getenv(key)
+	if dir == "" || !filepath.IsAbs(dir) {
+		return ""
 	}
+	return filepath.Clean(dir)
+}
+
+// CheckEnv reports environment variables that cannot relocate cxa's
+// directories: relative paths, an unknown home, and directories that would
+// end up in the same place in p, the paths NewPathsWith made.
+func CheckEnv(p *Paths) error {
+	for _, key := range []string{EnvCodexHome, EnvDataDir, EnvStateDir} {
+		if dir := os.Getenv(key); dir != "" && !filepath.IsAbs(dir) {
+			return fmt.Errorf("%s must be an absolute path, got %q", key, dir)
+		}
+	}
+
+	if name := os.Getenv(EnvHome); name != "" && p.HomeName() != name {
+		return fmt.Errorf("unknown Codex home '%s' in %s; add it with cxa home add %s <dir>", name, EnvHome, name)
+	}
+	dirs := map[string]string{p.Home: "the Codex home", p.DataDir: "the data directory", p.StateDir: "the state directory"}
+	if len(dirs) < 3 {
+		return fmt.Errorf("the Codex home (%s), data directory (%s), and state directory (%s) must all differ; check %s, %s, and %s",
+			p.Home, p.DataDir, p.StateDir, EnvCodexHome, EnvDataDir, EnvStateDir)
+	}
+	return nil
+}
+
+// NewPathsAt returns the legacy layout under home, ~/codex-data and
+// ~/.codex-switch, ignoring the environment, the cxa config, and the
+// system policy. It gives tests and tools working on another root a
+// complete set of paths.
+func NewPathsAt(home string) *Paths {
+	p := &Paths{
+		Home:     filepath.Join(home, ".codex"),
+		StateDir: filepath.Join(home, ".codex-switch"),
+	}
+	p.SetDataDir(filepath.Join(home, "codex-data"))
+	return p
+}
+
+// NewXDGPathsAt returns the XDG Base Directory layout under home: data in
+// $XDG_DATA_HOME/cxa and state in $XDG_STATE_HOME/cxa, which default to
+// ~/.local/share/cxa and ~/.local/state/cxa.
+func NewXDGPathsAt(home string) *Paths {
+	p := &Paths{
+		Home:     filepath.Join(home, ".codex"),
+		StateDir: filepath.Join(xdgDir("XDG_STATE_HOME", home, ".local", "state"), "cxa"),
+	}
+	p.SetDataDir(filepath.Join(xdgDir("XDG_DATA_HOME", home, ".local", "share"), "cxa"))
+	return p
+}
+
+// xdgDir returns the base directory named by the XDG variable key, or its
+// default under home. The specification ignores relative paths.
+func xdgDir(key, home string, fallback ...string) string {
+	if dir := envDir(key); dir != "" {
+		return dir
+	}
+	return filepath.Join(append([]string{home}, fallback...)...)
+}
+
+// UsesXDG reports whether cxa keeps its directories in the XDG layout under
+// home: when the XDG state directory exists, or on a fresh install where
+// neither legacy directory does. Existing installs keep the legacy layout
+// until moved with cxa storage migrate.
+func UsesXDG(home string) bool {
+	if _, err := os.Stat(NewXDGPathsAt(home).StateDir); err == nil {
+		return true
+	}
+	legacy := NewPathsAt(home)
+	for _, dir := range []string{legacy.StateDir, legacy.DataDir} {
+		if _, err := os.Stat(dir); err == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// SetDataDir moves the data directory, and the shared and group
+// directories inside it, to dir.
+func (p *Paths) SetDataDir(dir string) {
+	p.DataDir = dir
+	p.SharedDir = filepath.Join(dir, "shared")
+	p.GroupsDir = filepath.Join(dir, "groups")
 }
 
 // AccountsDir returns the path to the accounts directory.
@@ -57,6 +244,36 @@ func (p *Paths) AccountPath(name string) string {
 	return filepath.Join(p.AccountsDir(), name)
 }
 
+// CacheDir returns the path to the warm cache of pre-staged accounts.
+func (p *Paths) CacheDir() string {
+	return filepath.Join(p.DataDir, "cache")
+}
+
+// CachePath returns the warm cache path for a specific account.
+func (p *Paths) CachePath(name string) string {
+	return filepath.Join(p.CacheDir(), name)
+}
+
+// SnapshotsDir returns the path to the earlier versions kept of an account.
+func (p *Paths) SnapshotsDir(name string) string {
+	return filepath.Join(p.DataDir, "snapshots", name)
+}
+
+// BlobsDir returns the path to the deduplicated store of session files.
+func (p *Paths) BlobsDir() string {
+	return filepath.Join(p.DataDir, "blobs")
+}
+
+// TrashDir returns the path to deleted accounts kept for restoring.
+func (p *Paths) TrashDir() string {
+	return filepath.Join(p.DataDir, "trash")
+}
+
+// AuthFile returns the path to the active auth.json.
+func (p *Paths) AuthFile() string {
+	return filepath.Join(p.Home, "auth.json")
+}
+
 // StateFile returns the path to the state file.
 func (p *Paths) StateFile() string {
 	return filepath.Join(p.StateDir, "state.json")
@@ -67,6 +284,69 @@ func (p *Paths) SharingConfigFile() string {
 	return filepath.Join(p.StateDir, "sharing.json")
 }
 
+// ConfigFile returns the path to the cxa config.
+func (p *Paths) ConfigFile() string {
+	if p.configDir != "" {
+		return filepath.Join(p.configDir, "config.json")
+	}
+	return filepath.Join(p.StateDir, "config.json")
+}
+
+// HooksDir returns the directory of hook scripts, kept beside the cxa
+// config.
+func (p *Paths) HooksDir() string {
+	return filepath.Join(filepath.Dir(p.ConfigFile()), "hooks")
+}
+
+// WarningsFile returns the path to the persisted warnings.
+func (p *Paths) WarningsFile() string {
+	return filepath.Join(p.StateDir, "warnings.json")
+}
+
+// HistoryFile returns the path to the log of account operations.
+func (p *Paths) HistoryFile() string {
+	return filepath.Join(p.StateDir, "history.log")
+}
+
+// SyncStateFile returns the path to the record of what was last synced
+// with each remote.
+func (p *Paths) SyncStateFile() string {
+	return filepath.Join(p.StateDir, "sync.json")
+}
+
+// LogFile returns the path to the debug log.
+func (p *Paths) LogFile() string {
+	return filepath.Join(p.StateDir, "debug.log")
+}
+
+// DecisionFile returns the path to the record of the last automatic switch.
+func (p *Paths) DecisionFile() string {
+	return filepath.Join(p.StateDir, "last-decision.json")
+}
+
+// LockFile returns the path to the file locked by operations that change
+// accounts, so only one cxa process changes them at a time.
+func (p *Paths) LockFile() string {
+	return filepath.Join(p.StateDir, "lock")
+}
+
+// IndexFile returns the path to the cached metadata of every account.
+func (p *Paths) IndexFile() string {
+	return filepath.Join(p.StateDir, "index.json")
+}
+
+// KeyCheckFile returns the path to the file that tells whether a
+// passphrase is the one encrypted accounts are sealed with.
+func (p *Paths) KeyCheckFile() string {
+	return filepath.Join(p.StateDir, "key-check")
+}
+
+// AgentSocket returns the path to the socket of the agent holding the
+// passphrase of encrypted accounts.
+func (p *Paths) AgentSocket() string {
+	return filepath.Join(p.StateDir, "agent.sock")
+}
+
 // EnsureDirs creates all necessary directories.
 func (p *Paths) EnsureDirs() error {
 	dirs := []string{
@@ -0,0 +1,147 @@
+package codex_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/delhombre/cxa/pkg/codex"
+)
+
+func TestNewPaths_Env(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(codex.EnvCodexHome, "")
+	t.Setenv(codex.EnvDataDir, "")
+	t.Setenv(codex.EnvStateDir, "")
+	t.Setenv(codex.EnvHome, "")
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("XDG_STATE_HOME", "")
+
+	if p := codex.NewPaths(); *p != *codex.NewXDGPathsAt(home) {
+		t.Errorf("without overrides NewPaths = %+v", p)
+	}
+
+	root := t.TempDir()
+	t.Setenv(codex.EnvCodexHome, filepath.Join(root, "codex"))
+	t.Setenv(codex.EnvDataDir, filepath.Join(root, "data"))
+	t.Setenv(codex.EnvStateDir, filepath.Join(root, "state"))
+	p := codex.NewPaths()
+	if p.Home != filepath.Join(root, "codex") || p.StateDir != filepath.Join(root, "state") {
+		t.Errorf("NewPaths = %+v", p)
+	}
+	if p.DataDir != filepath.Join(root, "data") || p.SharedDir != filepath.Join(root, "data", "shared") {
+		t.Errorf("CXA_DATA_DIR should move the data directory and what is inside it, got %+v", p)
+	}
+	if err := codex.CheckEnv(codex.NewPaths()); err != nil {
+		t.Errorf("CheckEnv = %v", err)
+	}
+
+	t.Setenv(codex.EnvDataDir, "relative/data")
+	if err := codex.CheckEnv(codex.NewPaths()); err == nil {
+		t.Error("CheckEnv should reject a relative path")
+	}
+	if p := codex.NewPaths(); p.DataDir != filepath.Join(home, ".local", "share", "cxa") {
+		t.Errorf("an invalid override should be ignored, got %s", p.DataDir)
+	}
+
+	t.Setenv(codex.EnvDataDir, filepath.Join(root, "state"))
+	if err := codex.CheckEnv(codex.NewPaths()); err == nil {
+		t.Error("CheckEnv should reject a data directory that is the state directory")
+	}
+}
+
+func TestNewPaths_Home(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("HOME", root)
+	t.Setenv(codex.EnvCodexHome, "")
+	t.Setenv(codex.EnvDataDir, filepath.Join(root, "data"))
+	t.Setenv(codex.EnvStateDir, filepath.Join(root, "state"))
+	t.Setenv(codex.EnvHome, "work")
+
+	// Unknown until listed in the settings
+	if p := codex.NewPaths(); p.HomeName() != "" || p.Home != filepath.Join(root, ".codex") {
+		t.Errorf("an unknown home should be ignored, got %+v", p)
+	} else if err := codex.CheckEnv(p); err == nil {
+		t.Error("CheckEnv should reject an unknown home")
+	}
+
+	workHome := filepath.Join(root, "work-codex")
+	p := codex.NewPathsWith(func(configFile string) codex.Settings {
+		if configFile != filepath.Join(root, "state", "config.json") {
+			t.Errorf("settings asked for %s", configFile)
+		}
+		return codex.Settings{Homes: map[string]string{"work": workHome}}
+	})
+	if p.HomeName() != "work" || p.Home != workHome {
+		t.Errorf("expected the work home, got %+v", p)
+	}
+	if p.AccountsDir() != filepath.Join(root, "data", "homes", "work", "accounts") || p.StateFile() != filepath.Join(root, "state", "homes", "work", "state.json") {
+		t.Errorf("a home should keep its accounts and state apart, got %+v", p)
+	}
+	if p.ConfigFile() != filepath.Join(root, "state", "config.json") {
+		t.Errorf("every home should share the config, got %s", p.ConfigFile())
+	}
+	if err := codex.CheckEnv(p); err != nil {
+		t.Errorf("CheckEnv = %v", err)
+	}
+}
+
+func TestNewPathsWith_DataDir(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("HOME", root)
+	t.Setenv(codex.EnvCodexHome, "")
+	t.Setenv(codex.EnvDataDir, "")
+	t.Setenv(codex.EnvStateDir, "")
+	t.Setenv(codex.EnvHome, "")
+
+	settings := codex.Settings{DataDir: filepath.Join(root, "configured")}
+	newPaths := func() *codex.Paths {
+		return codex.NewPathsWith(func(string) codex.Settings { return settings })
+	}
+	if p := newPaths(); p.DataDir != settings.DataDir {
+		t.Errorf("expected the configured data directory, got %s", p.DataDir)
+	}
+	t.Setenv(codex.EnvDataDir, filepath.Join(root, "env"))
+	if p := newPaths(); p.DataDir != filepath.Join(root, "env") {
+		t.Errorf("CXA_DATA_DIR should win over the config, got %s", p.DataDir)
+	}
+	settings.PinnedDataDir = filepath.Join(root, "pinned")
+	if p := newPaths(); p.DataDir != settings.PinnedDataDir {
+		t.Errorf("the pinned data directory should win over everything, got %s", p.DataDir)
+	}
+}
+
+func TestNewPaths_Layout(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(codex.EnvCodexHome, "")
+	t.Setenv(codex.EnvDataDir, "")
+	t.Setenv(codex.EnvStateDir, "")
+	t.Setenv(codex.EnvHome, "")
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv("XDG_STATE_HOME", "")
+
+	// A fresh install uses the XDG layout
+	if p := codex.NewPaths(); p.StateDir != filepath.Join(home, ".local", "state", "cxa") {
+		t.Errorf("fresh install state dir = %s", p.StateDir)
+	}
+
+	// An existing install keeps the legacy one
+	if err := os.MkdirAll(filepath.Join(home, ".codex-switch"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if p := codex.NewPaths(); *p != *codex.NewPathsAt(home) {
+		t.Errorf("legacy install paths = %+v", p)
+	}
+
+	// Until it is migrated
+	xdgState := filepath.Join(t.TempDir(), "state")
+	t.Setenv("XDG_STATE_HOME", xdgState)
+	if err := os.MkdirAll(filepath.Join(xdgState, "cxa"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if p := codex.NewPaths(); p.StateDir != filepath.Join(xdgState, "cxa") {
+		t.Errorf("migrated state dir = %s", p.StateDir)
+	}
+}
@@ -0,0 +1,41 @@
+package codex
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// SessionMeta returns what the session file at path records about its
+// session on its first line: the payload of a session_meta line, such as
+// cwd, originator, and git, or the whole line in files written before
+// Codex had those.
+func SessionMeta(path string) (map[string]any, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	var first struct {
+		Type    string         `json:"type"`
+		Payload map[string]any `json:"payload"`
+	}
+	if err := json.Unmarshal(line, &first); err != nil {
+		return nil, fmt.Errorf("%s has no session metadata: %w", path, err)
+	}
+	if first.Type == "session_meta" && first.Payload != nil {
+		return first.Payload, nil
+	}
+	var meta map[string]any
+	if err := json.Unmarshal(line, &meta); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
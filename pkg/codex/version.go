@@ -0,0 +1,62 @@
+package codex
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Version runs codex --version and returns the installed Codex CLI
+// version, such as "0.46.0".
+func Version() (string, error) {
+	output, err := exec.Command("codex", "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run codex --version: %w", err)
+	}
+	return ParseVersion(string(output))
+}
+
+// ParseVersion extracts the version from codex --version output, which
+// looks like "codex-cli 0.46.0".
+func ParseVersion(output string) (string, error) {
+	line, _, _ := strings.Cut(strings.TrimSpace(output), "\n")
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty codex version output")
+	}
+	version := strings.TrimPrefix(fields[len(fields)-1], "v")
+	if _, _, ok := majorMinor(version); !ok {
+		return "", fmt.Errorf("unrecognized codex version %q", line)
+	}
+	return version, nil
+}
+
+// VersionsCompatible reports whether data written by Codex version a can
+// be expected to work with version b: they share a major and a minor
+// version. Codex is pre-1.0 and changes its config and session layout in
+// minor releases. Unparsable versions are assumed compatible.
+func VersionsCompatible(a, b string) bool {
+	aMajor, aMinor, okA := majorMinor(a)
+	bMajor, bMinor, okB := majorMinor(b)
+	if !okA || !okB {
+		return true
+	}
+	return aMajor == bMajor && aMinor == bMinor
+}
+
+func majorMinor(version string) (major, minor int, ok bool) {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	minor, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	return major, minor, true
+}
@@ -0,0 +1,280 @@
+// Package cxatest provides helpers for testing code built on cxa without
+// touching the real home directory: an in-memory account repository, a
+// throwaway HOME, and filesystem failure injection for cxa's own account
+// storage.
+package cxatest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/delhombre/cxa/internal/storage"
+	"github.com/delhombre/cxa/pkg/codex"
+)
+
+// ErrInjected is a convenient error for failure injection.
+var ErrInjected = errors.New("cxatest: injected failure")
+
+// Filesystem operations of cxa's copy engine that InjectFault can fail.
+const (
+	OpCopy    = storage.OpCopy
+	OpMkdir   = storage.OpMkdir
+	OpSymlink = storage.OpSymlink
+	OpRename  = storage.OpRename
+)
+
+// Account is an account held by a Repository.
+type Account struct {
+	Name       string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	LastUsedAt time.Time
+}
+
+// Repository is an in-memory account repository with the methods of cxa's
+// own. It is safe for concurrent use.
+type Repository struct {
+	mu       sync.Mutex
+	accounts map[string]*Account
+	current  string
+	failures map[string]error
+
+	// Now returns the time used for account timestamps. It defaults to a
+	// fixed instant so results are deterministic.
+	Now func() time.Time
+}
+
+// NewRepository returns a repository holding the named accounts. The first
+// name, if any, is the current account.
+func NewRepository(names ...string) *Repository {
+	r := &Repository{
+		accounts: make(map[string]*Account),
+		failures: make(map[string]error),
+		Now: func() time.Time {
+			return time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		},
+	}
+	for _, name := range names {
+		now := r.Now()
+		r.accounts[name] = &Account{Name: name, CreatedAt: now, UpdatedAt: now}
+	}
+	if len(names) > 0 {
+		r.current = names[0]
+	}
+	return r
+}
+
+// FailOn makes every later call to method (e.g. "Activate") return err.
+// A nil err clears the failure.
+func (r *Repository) FailOn(method string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err == nil {
+		delete(r.failures, method)
+		return
+	}
+	r.failures[method] = err
+}
+
+// List returns all accounts sorted by name.
+func (r *Repository) List() ([]*Account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.failures["List"]; err != nil {
+		return nil, err
+	}
+
+	accounts := make([]*Account, 0, len(r.accounts))
+	for _, acc := range r.accounts {
+		copied := *acc
+		accounts = append(accounts, &copied)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].Name < accounts[j].Name
+	})
+	return accounts, nil
+}
+
+// Get retrieves an account by name.
+func (r *Repository) Get(name string) (*Account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.failures["Get"]; err != nil {
+		return nil, err
+	}
+
+	acc, ok := r.accounts[name]
+	if !ok {
+		return nil, fmt.Errorf("account '%s' not found", name)
+	}
+	copied := *acc
+	return &copied, nil
+}
+
+// Save creates or updates the named account and makes it current.
+func (r *Repository) Save(name string) (*Account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.failures["Save"]; err != nil {
+		return nil, err
+	}
+
+	now := r.Now()
+	acc, ok := r.accounts[name]
+	if !ok {
+		acc = &Account{Name: name, CreatedAt: now}
+		r.accounts[name] = acc
+	}
+	acc.UpdatedAt = now
+	acc.LastUsedAt = now
+	r.current = name
+
+	copied := *acc
+	return &copied, nil
+}
+
+// Delete removes an account.
+func (r *Repository) Delete(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.failures["Delete"]; err != nil {
+		return err
+	}
+
+	if _, ok := r.accounts[name]; !ok {
+		return fmt.Errorf("account '%s' not found", name)
+	}
+	delete(r.accounts, name)
+	return nil
+}
+
+// Activate makes the named account current.
+func (r *Repository) Activate(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.failures["Activate"]; err != nil {
+		return err
+	}
+
+	acc, ok := r.accounts[name]
+	if !ok {
+		return fmt.Errorf("account '%s' not found", name)
+	}
+	acc.LastUsedAt = r.Now()
+	r.current = name
+	return nil
+}
+
+// SaveContext is Save, failing with ctx.Err() once ctx is done.
+func (r *Repository) SaveContext(ctx context.Context, name string) (*Account, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.Save(name)
+}
+
+// DeleteContext is Delete, failing with ctx.Err() once ctx is done.
+func (r *Repository) DeleteContext(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.Delete(name)
+}
+
+// ActivateContext is Activate, failing with ctx.Err() once ctx is done.
+func (r *Repository) ActivateContext(ctx context.Context, name string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.Activate(name)
+}
+
+// Current returns the current account name.
+func (r *Repository) Current() (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.failures["Current"]; err != nil {
+		return "", err
+	}
+	return r.current, nil
+}
+
+// Home points HOME at a fresh temporary directory containing a minimal
+// ~/.codex logged in with a placeholder API key, restoring HOME when the
+// test ends. It returns the new HOME.
+func Home(t testing.TB) string {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	// Keep XDG directories, and every directory the environment could
+	// relocate, under HOME too
+	for _, key := range []string{"XDG_DATA_HOME", "XDG_STATE_HOME", codex.EnvCodexHome, codex.EnvDataDir, codex.EnvStateDir, codex.EnvHome} {
+		t.Setenv(key, "")
+	}
+
+	codexHome := filepath.Join(home, ".codex")
+	if err := os.MkdirAll(codexHome, 0755); err != nil {
+		t.Fatalf("cxatest: failed to create %s: %v", codexHome, err)
+	}
+	if err := os.WriteFile(filepath.Join(codexHome, "auth.json"), []byte(`{"OPENAI_API_KEY": "sk-test"}`), 0600); err != nil {
+		t.Fatalf("cxatest: failed to write auth.json: %v", err)
+	}
+	return home
+}
+
+// Fault is a filesystem failure injected with InjectFault.
+type Fault struct {
+	mu      sync.Mutex
+	hits    int
+	restore func()
+	once    sync.Once
+}
+
+// InjectFault makes the directory repository's copy engine fail with err
+// whenever it performs op (one of the Op constants, or "" for any)
+// on a path containing pathContains. The fault is cleared when the test
+// ends.
+func InjectFault(t testing.TB, op, pathContains string, err error) *Fault {
+	t.Helper()
+
+	f := &Fault{}
+	f.restore = storage.SetFaultHook(func(gotOp, path string) error {
+		if (op != "" && gotOp != op) || !strings.Contains(path, pathContains) {
+			return nil
+		}
+		f.mu.Lock()
+		f.hits++
+		f.mu.Unlock()
+		return err
+	})
+	t.Cleanup(f.Clear)
+	return f
+}
+
+// Hits returns how many operations the fault has failed.
+func (f *Fault) Hits() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.hits
+}
+
+// Clear removes the fault so later operations succeed.
+func (f *Fault) Clear() {
+	f.once.Do(f.restore)
+}
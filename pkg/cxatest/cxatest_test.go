@@ -0,0 +1,81 @@
+package cxatest_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/delhombre/cxa/internal/storage"
+	"github.com/delhombre/cxa/pkg/cxatest"
+)
+
+func TestRepository(t *testing.T) {
+	repo := cxatest.NewRepository("personal", "work")
+
+	current, _ := repo.Current()
+	if current != "personal" {
+		t.Errorf("expected current 'personal', got '%s'", current)
+	}
+
+	if err := repo.Activate("work"); err != nil {
+		t.Fatalf("Activate failed: %v", err)
+	}
+	current, _ = repo.Current()
+	if current != "work" {
+		t.Errorf("expected current 'work', got '%s'", current)
+	}
+
+	repo.FailOn("Activate", cxatest.ErrInjected)
+	if err := repo.Activate("personal"); !errors.Is(err, cxatest.ErrInjected) {
+		t.Errorf("expected injected error, got %v", err)
+	}
+	repo.FailOn("Activate", nil)
+	if err := repo.Activate("personal"); err != nil {
+		t.Errorf("Activate should succeed after clearing the failure: %v", err)
+	}
+
+	if err := repo.Delete("work"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	accounts, _ := repo.List()
+	if len(accounts) != 1 {
+		t.Errorf("expected 1 account, got %d", len(accounts))
+	}
+}
+
+func TestInjectFault_InterruptedSaveResumes(t *testing.T) {
+	home := cxatest.Home(t)
+	codexHome := filepath.Join(home, ".codex")
+	if err := os.WriteFile(filepath.Join(codexHome, "history.jsonl"), []byte("entry\n"), 0644); err != nil {
+		t.Fatalf("failed to write history: %v", err)
+	}
+
+	repo := storage.NewDirectoryRepository()
+
+	fault := cxatest.InjectFault(t, cxatest.OpCopy, "history.jsonl", cxatest.ErrInjected)
+	if _, err := repo.Save("work"); !errors.Is(err, cxatest.ErrInjected) {
+		t.Fatalf("expected injected error, got %v", err)
+	}
+	if fault.Hits() == 0 {
+		t.Error("fault should have been hit")
+	}
+
+	accountPath := repo.Paths().AccountPath("work")
+	if _, err := os.Stat(accountPath); !os.IsNotExist(err) {
+		t.Error("a failed save should not create the account")
+	}
+
+	fault.Clear()
+	if _, err := repo.Save("work"); err != nil {
+		t.Fatalf("Save failed after clearing fault: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(accountPath, "history.jsonl"))
+	if err != nil {
+		t.Fatalf("failed to read saved history: %v", err)
+	}
+	if string(data) != "entry\n" {
+		t.Errorf("expected 'entry\\n', got %q", string(data))
+	}
+}